@@ -0,0 +1,121 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// affinityKey 是 WithAffinity 的 context 键
+type affinityKey struct{}
+
+// WithAffinity 返回带有亲和键 key 的 ctx 副本，用于在一个 Transport 中
+// 模拟多个互相独立的会话，如每个账号一个
+//
+// 亲和键相同的请求组成一个亲和组：
+//   - 连接池按亲和组划分，同组请求复用同组的连接，不同组 (包括没有亲和键的请求)
+//     从不共享连接，HTTP/2 连接也是如此。同组的请求因此使用相同的连接和指纹
+//   - Transport.Proxy 对每组的每个目标 (协议和 host:port) 只调用一次，之后同组
+//     到该目标的请求固定使用它返回的代理；ProxyFailover 切换代理成功后改为固定
+//     使用新代理。不同目标各自选择代理，NoProxy 和 EgressPolicy 照常生效
+//   - TLS 会话缓存按组隔离，一组的会话票据不会被另一组用于恢复会话
+//
+// MaxConnsPerHost 和 MaxIdleConnsPerHost 对每组分别计算。key 为空时等同于没有亲和键。
+// 亲和组不再使用时调用 Transport.ReleaseAffinity。
+func WithAffinity(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+// AffinityFromContext 返回 WithAffinity 在 ctx 中设置的亲和键
+func AffinityFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(affinityKey{}).(string)
+	return key, ok && key != ""
+}
+
+// ReleaseAffinity 结束亲和组 key：关闭它的空闲连接，不再固定它的代理
+//
+// 之后使用 key 的请求组成新的亲和组，重新调用 Transport.Proxy 选择代理，
+// 按 Transport.Rotation 重新分配指纹，按 Transport.ProxySessions 重新分配会话。
+// 正在使用的连接不受影响，用完后仍放回原组。
+func (t *Transport) ReleaseAffinity(key string) {
+	if key == "" {
+		return
+	}
+	t.affinity.release(key)
+	t.rotation.release(key)
+	t.rotateProxySession(key, nil)
+	t.closeIdleMatching(func(k connectMethodKey) bool { return k.partition.affinity == key })
+	if t2, ok := t.H2Transport.(*HTTP2Transport); ok {
+		if p := t2.clientConnPool(); p != nil {
+			p.closeIdleConnectionsMatching(func(_ string, part connPartition) bool { return part.affinity == key })
+		}
+	}
+}
+
+// affinityProxies 记录各亲和组对每个目标固定使用的代理，nil 表示直连
+type affinityProxies struct {
+	mu sync.Mutex
+	m  map[affinityTarget]*url.URL
+}
+
+// affinityTarget 是亲和组中的一个目标
+type affinityTarget struct {
+	affinity string
+	scheme   string
+	addr     string // 目标的 host:port
+}
+
+// proxy 返回亲和组在目标 k 上固定的代理，还没有时调用 pick 选择并固定
+// 并发的首次调用各自调用 pick，最先完成的结果生效
+func (p *affinityProxies) proxy(k affinityTarget, pick func() (*url.URL, error)) (*url.URL, error) {
+	p.mu.Lock()
+	u, ok := p.m[k]
+	p.mu.Unlock()
+	if ok {
+		return u, nil
+	}
+	u, err := pick()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pinned, ok := p.m[k]; ok {
+		return pinned, nil
+	}
+	if p.m == nil {
+		p.m = make(map[affinityTarget]*url.URL)
+	}
+	p.m[k] = u
+	return u, nil
+}
+
+// pin 将亲和组在目标 k 上固定的代理改为 u
+func (p *affinityProxies) pin(k affinityTarget, u *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.m == nil {
+		p.m = make(map[affinityTarget]*url.URL)
+	}
+	p.m[k] = u
+}
+
+// release 删除亲和组 key 在所有目标上固定的代理
+func (p *affinityProxies) release(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k := range p.m {
+		if k.affinity == key {
+			delete(p.m, k)
+		}
+	}
+}
+
+// affinityTarget 返回 cm 的亲和组和目标
+func (cm *connectMethod) affinityTarget() affinityTarget {
+	return affinityTarget{affinity: cm.partition.affinity, scheme: cm.targetScheme, addr: cm.targetAddr}
+}
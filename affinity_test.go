@@ -0,0 +1,142 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTransportAffinity 测试亲和组之间不共享连接，ReleaseAffinity 关闭组的空闲连接
+func TestTransportAffinity(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			io.WriteString(w, r.RemoteAddr)
+		}))
+		ts.EnableHTTP2 = h2
+		ts.StartTLS()
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			ForceAttemptHTTP2: h2,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		get := func(key string) string {
+			t.Helper()
+			ctx := context.Background()
+			if key != "" {
+				ctx = WithAffinity(ctx, key)
+			}
+			req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.ProtoMajor == 2; got != h2 {
+				t.Fatalf("HTTP/2 got %v, want %v", got, h2)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			return string(b)
+		}
+
+		a, b, none := get("a"), get("b"), get("")
+		if a == b || a == none || b == none {
+			t.Errorf("h2 %v: 不同亲和组共享了连接: a %s, b %s, 无亲和键 %s", h2, a, b, none)
+		}
+		if got := get("a"); got != a {
+			t.Errorf("h2 %v: 亲和组 a 没有复用连接: got %s, want %s", h2, got, a)
+		}
+		tr.ReleaseAffinity("a")
+		if got := get("a"); got == a {
+			t.Errorf("h2 %v: ReleaseAffinity 后仍复用了连接 %s", h2, got)
+		}
+		if got := get("b"); got != b {
+			t.Errorf("h2 %v: ReleaseAffinity 影响了亲和组 b: got %s, want %s", h2, got, b)
+		}
+		tr.CloseIdleConnections()
+		ts.Close()
+	}
+}
+
+// TestAffinityProxy 测试亲和组对每个目标固定使用 Proxy 首次返回的代理
+func TestAffinityProxy(t *testing.T) {
+	var calls int
+	tr := &Transport{Proxy: func(req *Request) (*url.URL, error) {
+		if req.URL.Host == "direct.test" {
+			return nil, nil
+		}
+		calls++
+		return &url.URL{Scheme: "http", Host: "proxy" + string(rune('0'+calls)) + ":8080"}, nil
+	}}
+	proxyFor := func(key, target string) string {
+		t.Helper()
+		ctx := context.Background()
+		if key != "" {
+			ctx = WithAffinity(ctx, key)
+		}
+		req, _ := NewRequestWithContext(ctx, "GET", target, nil)
+		cm, err := tr.connectMethodForRequest(&transportRequest{Request: req, ctx: ctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cm.proxyURL == nil {
+			return ""
+		}
+		return cm.proxyURL.Host
+	}
+
+	const target = "https://example.com/"
+	tests := []struct {
+		key    string
+		target string
+		want   string
+	}{
+		{"a", target, "proxy1:8080"},
+		{"b", target, "proxy2:8080"},
+		{"a", target, "proxy1:8080"},
+		{"", target, "proxy3:8080"},
+		{"", target, "proxy4:8080"},
+		{"b", target, "proxy2:8080"},
+		// 不同的目标各自选择代理，直连的目标不受其他目标影响
+		{"a", "https://direct.test/", ""},
+		{"a", "http://example.com/", "proxy5:8080"},
+		{"a", "http://example.com/", "proxy5:8080"},
+		{"a", target, "proxy1:8080"},
+	}
+	for i, tt := range tests {
+		if got := proxyFor(tt.key, tt.target); got != tt.want {
+			t.Errorf("#%d 亲和键 %q 目标 %s: got %v, want %v", i, tt.key, tt.target, got, tt.want)
+		}
+	}
+	tr.ReleaseAffinity("a")
+	if got, want := proxyFor("a", target), "proxy6:8080"; got != want {
+		t.Errorf("ReleaseAffinity 后 got %v, want %v", got, want)
+	}
+	if got, want := proxyFor("a", "http://example.com/"), "proxy7:8080"; got != want {
+		t.Errorf("ReleaseAffinity 后 got %v, want %v", got, want)
+	}
+}
+
+// TestAffinitySessionCache 测试亲和组之间不共享 TLS 会话
+func TestAffinitySessionCache(t *testing.T) {
+	cache := tls.NewLRUClientSessionCache(0)
+	a := partitionSessionCache{cache, "a"}
+	a.Put("example.com", &tls.ClientSessionState{})
+	if _, ok := a.Get("example.com"); !ok {
+		t.Error("亲和组 a 取不到自己的会话")
+	}
+	if _, ok := (partitionSessionCache{cache, "b"}).Get("example.com"); ok {
+		t.Error("亲和组 b 取到了 a 的会话")
+	}
+	if _, ok := cache.Get("example.com"); ok {
+		t.Error("没有亲和键时取到了 a 的会话")
+	}
+}
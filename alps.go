@@ -0,0 +1,67 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"maps"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// ALPSConfig 控制指纹连接的 ALPS (Application-Layer Protocol Settings)，
+// 即 Chrome 发送的 application_settings 扩展，旧码点 17513，Chrome 133 起为 17613。
+// 见 Transport.ALPS
+type ALPSConfig struct {
+	// Protocols 非 nil 时替换 17513 和 17613 扩展声明的协议，如 []string{"h2"}；
+	// nil 时保持指纹中的协议。只改变指纹中已有的扩展，不添加它
+	Protocols []string
+
+	// Settings 是服务端接受 ALPS 后，客户端在加密扩展中为协商出的 ALPN 协议发送的设置，
+	// 键为协议名。没有对应协议的条目时发送空设置。
+	// nil 时使用 TLSClientConfig.ApplicationSettings
+	Settings map[string][]byte
+
+	// Omit 为 true 时从 ClientHello 中删除 17513 和 17613 扩展，
+	// 用于 JA3 来自 Chrome 而 UserAgent 为 Firefox、Safari 等不支持 ALPS 的浏览器的情况
+	Omit bool
+}
+
+// applyALPS 按 t.ALPS 修改或删除 spec 中的 ALPS 扩展
+func (t *Transport) applyALPS(spec *tls.ClientHelloSpec) {
+	a := t.ALPS
+	if a == nil {
+		return
+	}
+	if a.Omit {
+		spec.Extensions = slices.DeleteFunc(spec.Extensions, func(e tls.TLSExtension) bool {
+			switch e.(type) {
+			case *tls.ApplicationSettingsExtension, *tls.ApplicationSettingsExtensionNew:
+				return true
+			}
+			return false
+		})
+		return
+	}
+	if a.Protocols == nil {
+		return
+	}
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.ApplicationSettingsExtension:
+			e.SupportedProtocols = slices.Clone(a.Protocols)
+		case *tls.ApplicationSettingsExtensionNew:
+			e.SupportedProtocols = slices.Clone(a.Protocols)
+		}
+	}
+}
+
+// alpsSettings 返回握手时使用的 ALPS 客户端设置
+func (t *Transport) alpsSettings(cfg *tls.Config) map[string][]byte {
+	if t.ALPS != nil && t.ALPS.Settings != nil {
+		return maps.Clone(t.ALPS.Settings)
+	}
+	return cfg.ApplicationSettings
+}
@@ -0,0 +1,273 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AuditSeverity 是审计发现的严重程度
+type AuditSeverity int
+
+const (
+	AuditInfo    AuditSeverity = iota // 提示，不影响伪装效果
+	AuditWarning                      // 可能被识别的不一致
+	AuditError                        // 几乎必然被识别的矛盾配置
+)
+
+func (s AuditSeverity) String() string {
+	switch s {
+	case AuditInfo:
+		return "info"
+	case AuditWarning:
+		return "warning"
+	case AuditError:
+		return "error"
+	}
+	return fmt.Sprintf("AuditSeverity(%d)", int(s))
+}
+
+// AuditFinding 是一条审计发现
+type AuditFinding struct {
+	Severity AuditSeverity
+	Code     string // 稳定的标识，如 "ua-ja3-mismatch"，可用于在 CI 中过滤
+	Message  string // 可读的说明
+}
+
+// AuditReport 是 Audit 的结果
+type AuditReport struct {
+	Findings []AuditFinding
+
+	// Score 是 0 到 100 的伪装评分：每个 error 扣 25 分，
+	// 每个 warning 扣 10 分，每个 info 扣 2 分
+	Score int
+}
+
+// Failed 报告是否存在严重程度不低于 min 的发现，便于在 CI 中断言
+func (r *AuditReport) Failed(min AuditSeverity) bool {
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// Has 报告是否存在标识为 code 的发现
+func (r *AuditReport) Has(code string) bool {
+	for _, f := range r.Findings {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回每行一条发现的文本报告
+func (r *AuditReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "score %d/100\n", r.Score)
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", f.Severity, f.Code, f.Message)
+	}
+	return b.String()
+}
+
+func (r *AuditReport) add(sev AuditSeverity, code, format string, args ...any) {
+	r.Findings = append(r.Findings, AuditFinding{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Audit 检查 t 的指纹配置中容易暴露客户端身份的不一致之处
+//
+// 等价于 AuditWithHeaders(t, nil)，不检查请求头相关的问题。
+func Audit(t *Transport) *AuditReport {
+	return AuditWithHeaders(t, nil)
+}
+
+// AuditWithHeaders 检查 t 的指纹配置以及随请求发送的 headers 是否自洽
+//
+// 检查项包括：User-Agent 与 JA3 所属浏览器不一致、ALPN 与 ForceHTTP1
+// 矛盾、Chrome 身份却关闭了 GREASE、Accept-Encoding 声明了本包无法解码
+// 的编码、Chromium 身份缺少 sec-ch-ua 系列请求头等。
+// headers 为 nil 时跳过请求头相关的检查。
+func AuditWithHeaders(t *Transport, headers Header) *AuditReport {
+	r := &AuditReport{}
+	ja3, ua, preset := auditFingerprint(t)
+	uaFamily := userAgentFamily(ua)
+	if hua := headers.Get("User-Agent"); hua != "" {
+		if ua != "" && hua != ua {
+			r.add(AuditError, "ua-header-mismatch", "请求头 User-Agent 与 Transport 指纹使用的 UserAgent 不同")
+		}
+		if ua == "" {
+			uaFamily = userAgentFamily(hua)
+		}
+	}
+
+	tlsFamily := ""
+	switch {
+	case ja3 != "":
+		tlsFamily = ja3Family(ja3)
+	case preset != "":
+		tlsFamily = userAgentFamily(preset)
+	case t.ClientHelloHexStream == "" && (t.TLSFingerprint == nil || t.TLSFingerprint.ClientHelloHexStream == "") && !t.UseCustomTLS:
+		r.add(AuditWarning, "default-tls", "未配置 TLS 指纹，将发送容易识别的 Go 默认 ClientHello")
+	}
+	if tlsFamily != "" && uaFamily != "" && tlsFamily != tlsFamilyOf(uaFamily) {
+		r.add(AuditError, "ua-ja3-mismatch", "User-Agent 声明为 %s，但 TLS 指纹属于 %s", uaFamily, tlsFamily)
+	}
+	if ja3 != "" && ua == "" {
+		r.add(AuditWarning, "ua-missing", "设置了 JA3 但没有设置 UserAgent，扩展细节按 Chrome 处理")
+	}
+	if t.RandomJA3 || t.RandomizeFingerprint || isRandomizedID(t.ClientHelloID) {
+		r.add(AuditInfo, "randomized", "指纹随机化后的 ClientHello 不对应任何真实浏览器版本")
+	}
+
+	// ALPN 与 HTTP/1 强制
+	forceH1 := t.ForceHTTP1 || (t.TLSFingerprint != nil && t.TLSFingerprint.ForceHTTP1)
+	if forceH1 {
+		if t.CustomALPN && slices.Contains(t.ALPNProtocols, "h2") {
+			r.add(AuditError, "alpn-force-http1", "ALPNProtocols 声明了 h2，但 ForceHTTP1 禁用了 HTTP/2")
+		}
+		if t.HTTP2Settings != nil {
+			r.add(AuditInfo, "http2-settings-unused", "ForceHTTP1 时 HTTP2Settings 不会生效")
+		}
+		if tlsFamily != "" {
+			r.add(AuditWarning, "browser-http1", "现代浏览器都会协商 HTTP/2，强制 HTTP/1.1 与 %s 指纹不符", tlsFamily)
+		}
+	} else if t.CustomALPN && len(t.ALPNProtocols) > 0 && !slices.Contains(t.ALPNProtocols, "h2") && tlsFamily != "" {
+		r.add(AuditWarning, "alpn-no-h2", "ALPNProtocols 没有 h2，与 %s 指纹不符", tlsFamily)
+	}
+
+	// GREASE
+	chromium := uaFamily == "chrome" || uaFamily == "edge" || (uaFamily == "" && tlsFamily == "chrome")
+	if chromium && auditGREASEDisabled(t) {
+		r.add(AuditError, "grease-disabled", "Chrome 身份关闭了 GREASE，真实的 Chrome 总会发送 GREASE 值")
+	}
+
+	if headers != nil {
+		auditHeaders(r, t, headers, uaFamily, chromium)
+	}
+
+	r.Score = 100
+	for _, f := range r.Findings {
+		switch f.Severity {
+		case AuditError:
+			r.Score -= 25
+		case AuditWarning:
+			r.Score -= 10
+		case AuditInfo:
+			r.Score -= 2
+		}
+	}
+	r.Score = max(r.Score, 0)
+	return r
+}
+
+// auditHeaders 检查请求头与浏览器身份是否一致
+func auditHeaders(r *AuditReport, t *Transport, h Header, uaFamily string, chromium bool) {
+	if ae := h.Get("Accept-Encoding"); ae != "" {
+		for _, enc := range strings.Split(ae, ",") {
+			enc, _, _ = strings.Cut(strings.TrimSpace(enc), ";")
+			if enc == "br" || enc == "zstd" {
+				r.add(AuditWarning, "undecodable-encoding", "Accept-Encoding 声明了 %s，但本包不会解码该编码，需要自行处理响应体", enc)
+			}
+		}
+	} else if !t.DisableCompression && uaFamily != "" {
+		r.add(AuditWarning, "accept-encoding", "Transport 只会自动发送 Accept-Encoding: gzip，与浏览器不符")
+	}
+
+	hasClientHints := h.Get("Sec-Ch-Ua") != ""
+	switch {
+	case chromium:
+		for _, k := range []string{"Sec-Ch-Ua", "Sec-Ch-Ua-Mobile", "Sec-Ch-Ua-Platform"} {
+			if h.Get(k) == "" {
+				r.add(AuditWarning, "sec-ch-missing", "Chromium 身份缺少 %s 请求头", k)
+			}
+		}
+	case hasClientHints && (uaFamily == "firefox" || uaFamily == "safari"):
+		r.add(AuditError, "sec-ch-unexpected", "%s 不会发送 sec-ch-ua 系列请求头", uaFamily)
+	}
+	if uaFamily != "" && h.Get("Accept-Language") == "" {
+		r.add(AuditInfo, "accept-language-missing", "浏览器总会发送 Accept-Language 请求头")
+	}
+}
+
+// auditFingerprint 返回 t 实际使用的 JA3、UserAgent 和预设名称，优先级与建连时一致
+func auditFingerprint(t *Transport) (ja3, ua, preset string) {
+	ua = t.UserAgent
+	if t.JA3 != "" || t.ClientHelloHexStream != "" {
+		return t.JA3, ua, ""
+	}
+	if f := t.TLSFingerprint; f != nil {
+		if f.UserAgent != "" {
+			ua = f.UserAgent
+		}
+		if f.ClientHelloHexStream != "" {
+			return "", ua, ""
+		}
+		return f.JA3, ua, f.PresetFingerprint
+	}
+	return "", ua, ""
+}
+
+// auditGREASEDisabled 报告 t 的扩展配置是否关闭了 GREASE
+func auditGREASEDisabled(t *Transport) bool {
+	if t.TLSExtensions != nil && t.TLSExtensions.NotUsedGREASE {
+		return true
+	}
+	f := t.TLSFingerprint
+	return f != nil && f.CustomExtensions != nil && f.CustomExtensions.NotUsedGREASE
+}
+
+// userAgentFamily 从 User-Agent 或预设名称识别浏览器，无法识别时返回空
+func userAgentFamily(ua string) string {
+	s := strings.ToLower(ua)
+	switch {
+	case s == "":
+		return ""
+	case strings.Contains(s, "edg"):
+		return "edge"
+	case strings.Contains(s, "firefox"):
+		return "firefox"
+	case strings.Contains(s, "chrome"), strings.Contains(s, "crios"):
+		return "chrome"
+	case strings.Contains(s, "safari"), strings.Contains(s, "ios"):
+		return "safari"
+	}
+	return ""
+}
+
+// tlsFamilyOf 返回浏览器所用 TLS 栈的家族，Edge 与 Chrome 相同
+func tlsFamilyOf(family string) string {
+	if family == "edge" {
+		return "chrome"
+	}
+	return family
+}
+
+// ja3Family 根据 JA3 的特征扩展和密码套件推断所属浏览器家族，无法判断时返回空
+//
+// Firefox 发送 record_size_limit (28) 和 ffdhe 组；Safari 仍提供 3DES
+// 套件 (49160、10)；Chrome 发送 ALPS (17513/17613) 和证书压缩 (27)。
+func ja3Family(ja3 string) string {
+	parts := strings.Split(ja3, ",")
+	if len(parts) != 5 {
+		return ""
+	}
+	ciphers := strings.Split(parts[1], "-")
+	exts := strings.Split(parts[2], "-")
+	groups := strings.Split(parts[3], "-")
+	switch {
+	case slices.Contains(exts, "28") || slices.Contains(groups, "256"):
+		return "firefox"
+	case slices.Contains(ciphers, "49160") || slices.Contains(ciphers, "10"):
+		return "safari"
+	case slices.Contains(exts, "17513") || slices.Contains(exts, "17613") || slices.Contains(exts, "27"):
+		return "chrome"
+	}
+	return ""
+}
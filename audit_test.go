@@ -0,0 +1,101 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	auditChromeJA3  = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0"
+	auditFirefoxJA3 = "771,4865-4867-4866-49195-49199-52393-52392-49196-49200-49162-49161-49171-49172-156-157-47-53,51-10-23-34-65281-13-18-35-11-27-43-5-0-45-16-65037-28-41,29-23-24-25-256-257,0"
+	auditChromeUA   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	auditFirefoxUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"
+)
+
+// TestAudit 测试各类不一致配置能被识别
+func TestAudit(t *testing.T) {
+	chromeHeaders := func() Header {
+		return Header{
+			"User-Agent":         {auditChromeUA},
+			"Accept-Encoding":    {"gzip, deflate"},
+			"Accept-Language":    {"en-US,en;q=0.9"},
+			"Sec-Ch-Ua":          {`"Chromium";v="120"`},
+			"Sec-Ch-Ua-Mobile":   {"?0"},
+			"Sec-Ch-Ua-Platform": {`"Windows"`},
+		}
+	}
+	tests := []struct {
+		name    string
+		tr      *Transport
+		headers Header
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:    "一致的 Chrome 配置",
+			tr:      &Transport{JA3: auditChromeJA3, UserAgent: auditChromeUA},
+			headers: chromeHeaders(),
+			wantNot: []string{"ua-ja3-mismatch", "sec-ch-missing", "grease-disabled", "default-tls"},
+		},
+		{
+			name: "UA 与 JA3 不一致",
+			tr:   &Transport{JA3: auditFirefoxJA3, UserAgent: auditChromeUA},
+			want: []string{"ua-ja3-mismatch"},
+		},
+		{
+			name: "ALPN 与 ForceHTTP1 矛盾",
+			tr:   &Transport{JA3: auditChromeJA3, UserAgent: auditChromeUA, ForceHTTP1: true, CustomALPN: true, ALPNProtocols: []string{"h2", "http/1.1"}},
+			want: []string{"alpn-force-http1", "browser-http1"},
+		},
+		{
+			name: "Chrome 关闭 GREASE",
+			tr:   &Transport{JA3: auditChromeJA3, UserAgent: auditChromeUA, TLSExtensions: &TLSExtensionsConfig{NotUsedGREASE: true}},
+			want: []string{"grease-disabled"},
+		},
+		{
+			name: "默认 TLS",
+			tr:   &Transport{},
+			want: []string{"default-tls"},
+		},
+		{
+			name:    "缺少 sec-ch 且声明 br",
+			tr:      &Transport{JA3: auditChromeJA3, UserAgent: auditChromeUA},
+			headers: Header{"User-Agent": {auditChromeUA}, "Accept-Encoding": {"gzip, br"}},
+			want:    []string{"sec-ch-missing", "undecodable-encoding", "accept-language-missing"},
+		},
+		{
+			name:    "Firefox 发送 sec-ch-ua",
+			tr:      &Transport{TLSFingerprint: &TLSFingerprintConfig{JA3: auditFirefoxJA3, UserAgent: auditFirefoxUA}},
+			headers: Header{"User-Agent": {auditFirefoxUA}, "Sec-Ch-Ua": {"x"}},
+			want:    []string{"sec-ch-unexpected", "accept-encoding"},
+			wantNot: []string{"ua-ja3-mismatch", "grease-disabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := AuditWithHeaders(tt.tr, tt.headers)
+			for _, code := range tt.want {
+				if !r.Has(code) {
+					t.Errorf("缺少发现 %q:\n%s", code, r)
+				}
+			}
+			for _, code := range tt.wantNot {
+				if r.Has(code) {
+					t.Errorf("不应有发现 %q:\n%s", code, r)
+				}
+			}
+		})
+	}
+
+	r := Audit(&Transport{JA3: auditFirefoxJA3, UserAgent: auditChromeUA})
+	if !r.Failed(AuditError) || r.Score != 75 {
+		t.Errorf("Failed = %v, Score = %d, want true, 75", r.Failed(AuditError), r.Score)
+	}
+	if !strings.Contains(r.String(), "[error] ua-ja3-mismatch") {
+		t.Errorf("String() = %q", r.String())
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrBodyBudgetExceeded 在一个响应体的长度超过 BodyBudget.Limit 时由 Body.Read 返回
+var ErrBodyBudgetExceeded = errors.New("响应体超过了 BodyBudget 的上限")
+
+// BodyBudget 限制所有响应体中已读取但尚未关闭的总字节数，防止大量并发的大响应耗尽内存
+//
+// 设置为 Transport.BodyBudget 后，响应体在读取前从预算中申请字节，预算不足时 Read 等待
+// 其他响应体关闭，请求的 context 结束时返回其原因。读到的字节在 Body.Close 时才归还，
+// 即假定调用方在关闭前一直持有读到的数据，如 io.ReadAll 后再关闭。
+//
+// 长度已知的响应体在第一次 Read 时一次申请全部长度，超过 Limit 时返回 ErrBodyBudgetExceeded；
+// 长度未知的 (分块传输、自动解压) 每次 Read 申请本次最多读取的字节数，累计超过 Limit 时
+// 返回 ErrBodyBudgetExceeded。多个长度未知的响应体各自持有部分预算并互相等待时，
+// 只能等到请求的 context 结束，因此使用预算时应为请求设置超时。
+//
+// 一个 BodyBudget 可以由多个 Transport 共享，作为进程级的预算。零值可以使用，
+// Limit 不大于 0 时不限制，只统计。边读边写入文件等不缓冲响应体的请求可以用
+// WithoutBodyBudget 跳过预算。
+type BodyBudget struct {
+	// Limit 是已读取且未关闭的响应体字节数上限，开始使用后不应修改
+	Limit int64
+
+	mu       sync.Mutex
+	inUse    int64
+	peak     int64
+	waiting  int64
+	waits    int64
+	waitTime time.Duration
+	exceeded int64
+	released chan struct{} // 有字节归还时关闭，唤醒等待的 Read
+}
+
+// BodyBudgetStats 是 BodyBudget 的统计，由 BodyBudget.Stats 返回
+type BodyBudgetStats struct {
+	Limit    int64         // BodyBudget.Limit
+	InUse    int64         // 已读取且未关闭的响应体字节数
+	Peak     int64         // InUse 的最大值
+	Waiting  int64         // 正在等待预算的 Read 数量
+	Waits    int64         // 因预算不足而等待的总次数
+	WaitTime time.Duration // 等待预算的总时长
+	Exceeded int64         // 返回 ErrBodyBudgetExceeded 的次数
+}
+
+// Stats 返回 b 当前的统计
+func (b *BodyBudget) Stats() BodyBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BodyBudgetStats{
+		Limit:    b.Limit,
+		InUse:    b.inUse,
+		Peak:     b.peak,
+		Waiting:  b.waiting,
+		Waits:    b.waits,
+		WaitTime: b.waitTime,
+		Exceeded: b.exceeded,
+	}
+}
+
+// bodyBudgetOptOutKey 是 WithoutBodyBudget 的 context 键
+type bodyBudgetOptOutKey struct{}
+
+// WithoutBodyBudget 返回 ctx 的副本，使用它的请求的响应体不占用 Transport.BodyBudget
+func WithoutBodyBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bodyBudgetOptOutKey{}, true)
+}
+
+// acquire 申请 n 字节，预算不足时等待。n 不能超过 b.Limit
+func (b *BodyBudget) acquire(ctx context.Context, n int64) error {
+	b.mu.Lock()
+	var start time.Time
+	for b.Limit > 0 && b.inUse+n > b.Limit {
+		if start.IsZero() {
+			start = time.Now()
+			b.waits++
+			b.waiting++
+		}
+		if b.released == nil {
+			b.released = make(chan struct{})
+		}
+		released := b.released
+		b.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.waiting--
+			b.waitTime += time.Since(start)
+			b.mu.Unlock()
+			return context.Cause(ctx)
+		}
+		b.mu.Lock()
+	}
+	if !start.IsZero() {
+		b.waiting--
+		b.waitTime += time.Since(start)
+	}
+	b.inUse += n
+	b.peak = max(b.peak, b.inUse)
+	b.mu.Unlock()
+	return nil
+}
+
+// release 归还 n 字节
+func (b *BodyBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.inUse -= n
+	if b.released != nil {
+		close(b.released)
+		b.released = nil
+	}
+	b.mu.Unlock()
+}
+
+func (b *BodyBudget) exceed() error {
+	b.mu.Lock()
+	b.exceeded++
+	b.mu.Unlock()
+	return ErrBodyBudgetExceeded
+}
+
+// applyBodyBudget 让 resp 的响应体占用 t.BodyBudget
+func (t *Transport) applyBodyBudget(ctx context.Context, resp *Response) {
+	b := t.BodyBudget
+	if b == nil || resp.Body == nil || resp.Body == NoBody || resp.StatusCode == StatusSwitchingProtocols {
+		return
+	}
+	if optOut, _ := ctx.Value(bodyBudgetOptOutKey{}).(bool); optOut {
+		return
+	}
+	resp.Body = &budgetBody{rc: resp.Body, b: b, ctx: ctx, length: resp.ContentLength}
+}
+
+// budgetBody 是占用 BodyBudget 的响应体
+type budgetBody struct {
+	rc     io.ReadCloser
+	b      *BodyBudget
+	ctx    context.Context
+	length int64 // 响应体长度，未知时为 -1
+
+	mu     sync.Mutex
+	held   int64 // 已申请的字节数
+	closed bool
+}
+
+func (bb *budgetBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return bb.rc.Read(p)
+	}
+	bb.mu.Lock()
+	held := bb.held
+	bb.mu.Unlock()
+	limit := bb.b.Limit
+
+	var want int64
+	if bb.length >= 0 {
+		// 长度已知时第一次 Read 申请全部长度
+		if held == 0 && bb.length > 0 {
+			if limit > 0 && bb.length > limit {
+				return 0, bb.b.exceed()
+			}
+			want = bb.length
+		}
+	} else {
+		want = int64(len(p))
+		if limit > 0 {
+			if held >= limit {
+				// 已用完上限时只有读到 EOF 才不算超出
+				if n, err := bb.rc.Read(p[:1]); n == 0 && err != nil {
+					return 0, err
+				}
+				return 0, bb.b.exceed()
+			}
+			want = min(want, limit-held)
+		}
+		p = p[:want]
+	}
+	if want > 0 {
+		if err := bb.b.acquire(bb.ctx, want); err != nil {
+			return 0, err
+		}
+		bb.mu.Lock()
+		if bb.closed {
+			bb.mu.Unlock()
+			bb.b.release(want)
+			return 0, ErrBodyReadAfterClose
+		}
+		bb.held += want
+		bb.mu.Unlock()
+	}
+
+	n, err := bb.rc.Read(p)
+	if bb.length < 0 {
+		// 长度未知时只保留实际读到的字节
+		var unused int64
+		bb.mu.Lock()
+		if !bb.closed {
+			unused = want - int64(n)
+			bb.held -= unused
+		}
+		bb.mu.Unlock()
+		bb.b.release(unused)
+	}
+	return n, err
+}
+
+func (bb *budgetBody) Close() error {
+	bb.mu.Lock()
+	held := bb.held
+	bb.held = 0
+	bb.closed = true
+	bb.mu.Unlock()
+	bb.b.release(held)
+	return bb.rc.Close()
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBudgetServer 返回 /n?chunked=1 时以分块传输返回 n 字节响应体的服务器
+func newBudgetServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		body := strings.Repeat("x", n)
+		if r.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(n))
+			io.WriteString(w, body)
+			return
+		}
+		w.(nethttp.Flusher).Flush()
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func budgetGet(t *testing.T, tr *Transport, ctx context.Context, url string) *Response {
+	t.Helper()
+	req, _ := NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestBodyBudget(t *testing.T) {
+	ts := newBudgetServer(t)
+	budget := &BodyBudget{Limit: 100}
+	tr := &Transport{BodyBudget: budget}
+	defer tr.CloseIdleConnections()
+	ctx := context.Background()
+
+	resp1 := budgetGet(t, tr, ctx, ts.URL+"/60")
+	if _, err := io.ReadAll(resp1.Body); err != nil {
+		t.Fatal(err)
+	}
+	if got := budget.Stats().InUse; got != 60 {
+		t.Errorf("InUse got %d, want 60", got)
+	}
+
+	// 预算不足时等待，context 结束时返回其原因
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	resp2 := budgetGet(t, tr, tctx, ts.URL+"/60")
+	if _, err := io.ReadAll(resp2.Body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	cancel()
+	resp2.Body.Close()
+
+	// 其他响应体关闭后继续读取
+	resp3 := budgetGet(t, tr, ctx, ts.URL+"/60")
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp3.Body)
+		done <- err
+	}()
+	for budget.Stats().Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	resp1.Body.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	resp3.Body.Close()
+
+	st := budget.Stats()
+	if st.InUse != 0 || st.Peak != 60 || st.Waits != 2 || st.WaitTime <= 0 {
+		t.Errorf("got %+v", st)
+	}
+}
+
+func TestBodyBudgetExceeded(t *testing.T) {
+	ts := newBudgetServer(t)
+	budget := &BodyBudget{Limit: 100}
+	tr := &Transport{BodyBudget: budget}
+	defer tr.CloseIdleConnections()
+	ctx := context.Background()
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/100", false},
+		{"/101", true},
+		{"/100?chunked=1", false},
+		{"/101?chunked=1", true},
+	}
+	for _, tt := range tests {
+		resp := budgetGet(t, tr, ctx, ts.URL+tt.path)
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if tt.wantErr != errors.Is(err, ErrBodyBudgetExceeded) {
+			t.Errorf("%s: got %v", tt.path, err)
+		}
+		if !tt.wantErr && len(b) != 100 {
+			t.Errorf("%s: 读取 %d 字节, want 100", tt.path, len(b))
+		}
+	}
+	if st := budget.Stats(); st.InUse != 0 || st.Exceeded != 2 {
+		t.Errorf("got %+v", st)
+	}
+
+	// 跳过预算的请求不受上限限制
+	resp := budgetGet(t, tr, WithoutBodyBudget(ctx), ts.URL+"/1000")
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(b) != 1000 {
+		t.Errorf("WithoutBodyBudget: 读取 %d 字节, err = %v", len(b), err)
+	}
+	if st := budget.Stats(); st.Peak != 100 {
+		t.Errorf("Peak got %d, want 100", st.Peak)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// 各浏览器 compress_certificate (27) 扩展声明的算法，顺序与浏览器实际发送的一致
+var (
+	chromeCertCompression  = []tls.CertCompressionAlgo{tls.CertCompressionBrotli}
+	firefoxCertCompression = []tls.CertCompressionAlgo{tls.CertCompressionZlib, tls.CertCompressionBrotli, tls.CertCompressionZstd}
+	safariCertCompression  = []tls.CertCompressionAlgo{tls.CertCompressionZlib}
+)
+
+// certCompressionFor 返回 userAgent 所属浏览器声明的证书压缩算法
+// 无法识别浏览器时按 Chrome 处理，返回的切片可以自由修改
+func certCompressionFor(userAgent string) []tls.CertCompressionAlgo {
+	switch userAgentFamily(userAgent) {
+	case "firefox":
+		return slices.Clone(firefoxCertCompression)
+	case "safari":
+		return slices.Clone(safariCertCompression)
+	}
+	return slices.Clone(chromeCertCompression)
+}
+
+// setCertCompression 设置扩展映射表中 27 扩展的内容
+//
+// 默认使用 userAgent 所属浏览器的算法，override.CertCompressionAlgo 非 nil 时以其为准
+func setCertCompression(extMap map[string]tls.TLSExtension, userAgent string, override *TLSExtensionsConfig) {
+	algs := certCompressionFor(userAgent)
+	if override != nil && override.CertCompressionAlgo != nil {
+		algs = slices.Clone(override.CertCompressionAlgo.Algorithms)
+	}
+	extMap["27"] = &tls.UtlsCompressCertExtension{Algorithms: algs}
+}
+
+// applyCertCompression 按 t.CertCompression 替换 spec 中 27 扩展的算法，
+// t.CertCompression 为空切片时删除该扩展
+func (t *Transport) applyCertCompression(spec *tls.ClientHelloSpec) {
+	if t.CertCompression == nil {
+		return
+	}
+	if len(t.CertCompression) == 0 {
+		spec.Extensions = slices.DeleteFunc(spec.Extensions, func(e tls.TLSExtension) bool {
+			_, ok := e.(*tls.UtlsCompressCertExtension)
+			return ok
+		})
+		return
+	}
+	for _, e := range spec.Extensions {
+		if e, ok := e.(*tls.UtlsCompressCertExtension); ok {
+			e.Algorithms = slices.Clone(t.CertCompression)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// maxCertIntelRoutes 是 Transport 记录证书链的目标和代理组合的最大数量，
+// 超过时丢弃最久未见的记录
+const maxCertIntelRoutes = 1024
+
+// CertInfo 描述服务端证书链中的一个证书
+type CertInfo struct {
+	Subject    string
+	Issuer     string
+	NotBefore  time.Time
+	NotAfter   time.Time
+	JA4X       string // 证书的 JA4X，见 JA4X
+	SPKISHA256 string // 公钥的 SPKI 哈希，见 SPKIHash
+	SHA256     string // 整个证书 DER 编码的 SHA-256，十六进制
+}
+
+// CertIntel 是经某个代理 (或直连) 访问一个目标时服务端提供的证书链
+//
+// 同一目标经不同代理看到的叶子证书公钥不同，或同一路线的公钥在有效期内改变，
+// 通常说明有透明代理在中间解密流量。
+type CertIntel struct {
+	Addr  string     // 目标地址 host:port
+	Proxy string     // 使用的代理 (密码已脱敏)，直连时为空
+	Chain []CertInfo // 最近一次握手的证书链，叶子证书在前
+
+	FirstSeen time.Time // 第一次看到当前叶子证书公钥的时间
+	LastSeen  time.Time // 最近一次看到该证书链的时间
+
+	// Changes 是该路线的叶子证书公钥改变的次数，Previous 是改变前的证书链
+	Changes  int
+	Previous []CertInfo
+
+	// Mismatch 为 true 表示同一目标的各路线看到的叶子证书公钥不一致
+	Mismatch bool
+}
+
+// SPKIHash 返回证书公钥 (SubjectPublicKeyInfo) 的 SHA-256 的 base64 编码，
+// 与 HPKP 和 curl --pinnedpubkey 使用的格式相同
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// certRoute 是证书链记录的键
+type certRoute struct {
+	addr, proxy string
+}
+
+// certIntelCache 按目标和代理记录服务端证书链，零值可以直接使用
+type certIntelCache struct {
+	mu     sync.Mutex
+	routes map[certRoute]*CertIntel
+	certs  map[[sha256.Size]byte]CertInfo // 已解析的证书，避免重复计算 JA4X
+}
+
+// certInfo 返回 cert 的 CertInfo，ja4x 非空时直接使用。调用时持有 c.mu
+func (c *certIntelCache) certInfo(cert *x509.Certificate, ja4x string) CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	if info, ok := c.certs[sum]; ok {
+		return info
+	}
+	if ja4x == "" {
+		ja4x = JA4X(cert)
+	}
+	info := CertInfo{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		JA4X:       ja4x,
+		SPKISHA256: SPKIHash(cert),
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+	if c.certs == nil {
+		c.certs = make(map[[sha256.Size]byte]CertInfo)
+	}
+	if len(c.certs) >= 4*maxCertIntelRoutes {
+		clear(c.certs)
+	}
+	c.certs[sum] = info
+	return info
+}
+
+// record 记录经 proxy 访问 addr 时握手 cs 中的证书链，ja4x 为 peerJA4X 的结果
+func (c *certIntelCache) record(addr, proxy string, cs *tls.ConnectionState, ja4x []string, now time.Time) {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chain := make([]CertInfo, len(cs.PeerCertificates))
+	for i, cert := range cs.PeerCertificates {
+		var x string
+		if i < len(ja4x) {
+			x = ja4x[i]
+		}
+		chain[i] = c.certInfo(cert, x)
+	}
+
+	key := certRoute{addr, proxy}
+	ci := c.routes[key]
+	if ci == nil {
+		if c.routes == nil {
+			c.routes = make(map[certRoute]*CertIntel)
+		}
+		if len(c.routes) >= maxCertIntelRoutes {
+			c.evictOldest()
+		}
+		ci = &CertIntel{Addr: addr, Proxy: proxy, FirstSeen: now}
+		c.routes[key] = ci
+	} else if ci.Chain[0].SPKISHA256 != chain[0].SPKISHA256 {
+		ci.Changes++
+		ci.Previous = ci.Chain
+		ci.FirstSeen = now
+	}
+	ci.Chain = chain
+	ci.LastSeen = now
+}
+
+// evictOldest 丢弃最久未见的记录。调用时持有 c.mu
+func (c *certIntelCache) evictOldest() {
+	var oldest certRoute
+	var oldestAt time.Time
+	for k, ci := range c.routes {
+		if oldestAt.IsZero() || ci.LastSeen.Before(oldestAt) {
+			oldest, oldestAt = k, ci.LastSeen
+		}
+	}
+	delete(c.routes, oldest)
+}
+
+// CertIntel 返回访问 addr (host:port) 时各路线最近看到的服务端证书链，
+// 按代理排序，直连在前。addr 为空时返回所有目标，按目标和代理排序
+func (t *Transport) CertIntel(addr string) []CertIntel {
+	c := &t.certIntel
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	leaves := make(map[string]string) // 目标第一个路线的叶子证书公钥
+	mismatch := make(map[string]bool)
+	var out []CertIntel
+	for k, ci := range c.routes {
+		if spki, ok := leaves[k.addr]; !ok {
+			leaves[k.addr] = ci.Chain[0].SPKISHA256
+		} else if spki != ci.Chain[0].SPKISHA256 {
+			mismatch[k.addr] = true
+		}
+		if addr == "" || k.addr == addr {
+			v := *ci
+			v.Chain = slices.Clone(ci.Chain)
+			v.Previous = slices.Clone(ci.Previous)
+			out = append(out, v)
+		}
+	}
+	for i := range out {
+		out[i].Mismatch = mismatch[out[i].Addr]
+	}
+	slices.SortFunc(out, func(a, b CertIntel) int {
+		if c := strings.Compare(a.Addr, b.Addr); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Proxy, b.Proxy)
+	})
+	return out
+}
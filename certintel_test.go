@@ -0,0 +1,125 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTransportCertIntel 测试按目标记录服务端证书链
+func TestTransportCertIntel(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}
+	c := &Client{Transport: tr}
+	for range 2 {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	addr := strings.TrimPrefix(ts.URL, "https://")
+	got := tr.CertIntel(addr)
+	if len(got) != 1 {
+		t.Fatalf("got %d 条记录, want 1", len(got))
+	}
+	cert := ts.Certificate()
+	ci := got[0]
+	if ci.Addr != addr || ci.Proxy != "" || len(ci.Chain) != 1 {
+		t.Fatalf("got %+v", ci)
+	}
+	if ci.Chain[0].JA4X != JA4X(cert) || ci.Chain[0].SPKISHA256 != SPKIHash(cert) {
+		t.Errorf("Chain[0] got %+v, want JA4X %v, SPKI %v", ci.Chain[0], JA4X(cert), SPKIHash(cert))
+	}
+	if ci.Changes != 0 || ci.Mismatch {
+		t.Errorf("Changes got %v, Mismatch got %v, want 0, false", ci.Changes, ci.Mismatch)
+	}
+	if n := len(tr.CertIntel("")); n != 1 {
+		t.Errorf("CertIntel(\"\") got %d 条记录, want 1", n)
+	}
+}
+
+// newTestCert 返回一个使用新密钥的自签名证书
+func newTestCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestCertIntelMITM 测试证书公钥改变和各路线证书不一致的检测
+func TestCertIntelMITM(t *testing.T) {
+	origin, mitm := newTestCert(t, "example.com"), newTestCert(t, "example.com")
+	state := func(c *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{c}}
+	}
+	const addr = "example.com:443"
+	now := time.Now()
+	tests := []struct {
+		name         string
+		proxy        string
+		cert         *x509.Certificate
+		wantChanges  int
+		wantMismatch bool
+	}{
+		{"直连", "", origin, 0, false},
+		{"代理返回相同证书", "http://p1:8080", origin, 0, false},
+		{"代理返回不同证书", "http://p2:8080", mitm, 0, true},
+		{"直连证书改变", "", mitm, 1, true},
+	}
+	var tr Transport
+	for i, tt := range tests {
+		tr.certIntel.record(addr, tt.proxy, state(tt.cert), nil, now.Add(time.Duration(i)*time.Second))
+		var ci *CertIntel
+		for _, v := range tr.CertIntel(addr) {
+			if v.Proxy == tt.proxy {
+				ci = &v
+			}
+		}
+		if ci == nil {
+			t.Fatalf("%s: 没有记录", tt.name)
+		}
+		if ci.Changes != tt.wantChanges || ci.Mismatch != tt.wantMismatch {
+			t.Errorf("%s: Changes got %v, Mismatch got %v, want %v, %v", tt.name, ci.Changes, ci.Mismatch, tt.wantChanges, tt.wantMismatch)
+		}
+		if ci.Chain[0].SPKISHA256 != SPKIHash(tt.cert) {
+			t.Errorf("%s: SPKISHA256 got %v, want %v", tt.name, ci.Chain[0].SPKISHA256, SPKIHash(tt.cert))
+		}
+	}
+}
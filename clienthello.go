@@ -0,0 +1,129 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// clientHelloInfo 是从原始 ClientHello 消息中解析出的指纹相关字段
+// 各字段保持报文中的原始顺序，包括 GREASE 值
+type clientHelloInfo struct {
+	version         uint16   // legacy_version
+	cipherSuites    []uint16 // 密码套件
+	extensions      []uint16 // 扩展类型
+	supportedGroups []uint16 // supported_groups (10)
+	pointFormats    []uint8  // ec_point_formats (11)
+	paddingLen      int      // padding (21) 的数据长度，没有该扩展时为 -1
+}
+
+var errMalformedClientHello = errors.New("ClientHello 格式错误")
+
+// parseClientHello 解析原始 ClientHello 消息
+// raw 可以带也可以不带 4 字节的握手消息头
+func parseClientHello(raw []byte) (*clientHelloInfo, error) {
+	if len(raw) >= 4 && raw[0] == 1 && int(raw[1])<<16|int(raw[2])<<8|int(raw[3]) == len(raw)-4 {
+		raw = raw[4:]
+	}
+	s := cryptobyte.String(raw)
+	h := &clientHelloInfo{paddingLen: -1}
+	var sessionID, suites, compression cryptobyte.String
+	if !s.ReadUint16(&h.version) || !s.Skip(32) ||
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!s.ReadUint16LengthPrefixed(&suites) ||
+		!s.ReadUint8LengthPrefixed(&compression) {
+		return nil, errMalformedClientHello
+	}
+	for !suites.Empty() {
+		var suite uint16
+		if !suites.ReadUint16(&suite) {
+			return nil, errMalformedClientHello
+		}
+		h.cipherSuites = append(h.cipherSuites, suite)
+	}
+	if s.Empty() {
+		return h, nil
+	}
+	var exts cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&exts) || !s.Empty() {
+		return nil, errMalformedClientHello
+	}
+	for !exts.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !exts.ReadUint16(&typ) || !exts.ReadUint16LengthPrefixed(&data) {
+			return nil, errMalformedClientHello
+		}
+		h.extensions = append(h.extensions, typ)
+		switch typ {
+		case 10:
+			var groups cryptobyte.String
+			if !data.ReadUint16LengthPrefixed(&groups) {
+				return nil, errMalformedClientHello
+			}
+			for !groups.Empty() {
+				var g uint16
+				if !groups.ReadUint16(&g) {
+					return nil, errMalformedClientHello
+				}
+				h.supportedGroups = append(h.supportedGroups, g)
+			}
+		case 11:
+			var formats cryptobyte.String
+			if !data.ReadUint8LengthPrefixed(&formats) {
+				return nil, errMalformedClientHello
+			}
+			h.pointFormats = append(h.pointFormats, formats...)
+		case 21:
+			h.paddingLen = len(data)
+		}
+	}
+	return h, nil
+}
+
+// isGREASEValue 报告 v 是否为 RFC 8701 定义的 GREASE 值
+func isGREASEValue(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// ja3 返回 ClientHello 的 JA3 字符串（去除 GREASE 值）
+func (h *clientHelloInfo) ja3() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(h.version)))
+	for _, list := range [][]uint16{h.cipherSuites, h.extensions, h.supportedGroups} {
+		b.WriteByte(',')
+		first := true
+		for _, v := range list {
+			if isGREASEValue(v) {
+				continue
+			}
+			if !first {
+				b.WriteByte('-')
+			}
+			first = false
+			b.WriteString(strconv.Itoa(int(v)))
+		}
+	}
+	b.WriteByte(',')
+	for i, f := range h.pointFormats {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(strconv.Itoa(int(f)))
+	}
+	return b.String()
+}
+
+// ja3Hash 返回 JA3 字符串的 MD5 十六进制摘要
+func ja3Hash(ja3 string) string {
+	sum := md5.Sum([]byte(ja3))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// cloneClientHelloSpec 深拷贝 spec，每个连接使用自己的副本
+//
+// utls 的扩展在握手时保存状态 (如 key_share 的私钥、ECH GREASE 的负载、
+// PSK 的长度缓存)，多个连接共享同一个扩展会互相影响，之后的策略处理也会修改 spec。
+// 导出字段中的指针、切片和 map 都会复制；未导出字段只浅拷贝，
+// 它们是握手时才设置的状态，在从未用于握手的 spec 中为零值。
+func cloneClientHelloSpec(spec *tls.ClientHelloSpec) *tls.ClientHelloSpec {
+	return deepCopy(reflect.ValueOf(spec)).Interface().(*tls.ClientHelloSpec)
+}
+
+// cloneTLSExtension 深拷贝扩展 e，见 cloneClientHelloSpec
+func cloneTLSExtension(e tls.TLSExtension) tls.TLSExtension {
+	return deepCopy(reflect.ValueOf(e)).Interface().(tls.TLSExtension)
+}
+
+// deepCopy 返回 v 的深拷贝，函数和 channel 不复制
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(deepCopy(v.Elem()))
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(deepCopy(v.Elem()))
+		return n
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		for i := range n.NumField() {
+			if f := n.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return n
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			n.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return n
+	}
+	return v
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock is a source of time and timers for a Transport.
+// See Transport.Clock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A Timer is a time.Timer as an interface, so that Clock
+// implementations can provide synthetic timers.
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// systemTimer adapts a *time.Timer to the Timer interface.
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+func (t *Transport) now() time.Time {
+	if t.Clock != nil {
+		return t.Clock.Now()
+	}
+	return time.Now()
+}
+
+func (t *Transport) newTimer(d time.Duration) Timer {
+	if t.Clock != nil {
+		return t.Clock.NewTimer(d)
+	}
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (t *Transport) afterFunc(d time.Duration, f func()) Timer {
+	if t.Clock != nil {
+		return t.Clock.AfterFunc(d, f)
+	}
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+// FakeClock is a Clock whose time only moves when Advance is called.
+// It is intended for tests that exercise Transport timeouts without
+// sleeping. The zero value is not usable; use NewFakeClock.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock whose current time is start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a Timer that fires d after the clock's current time.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	return c.newTimer(d, nil)
+}
+
+// AfterFunc returns a Timer that calls f d after the clock's current time.
+// f is called synchronously from Advance.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.newTimer(d, f)
+}
+
+func (c *FakeClock) newTimer(d time.Duration, f func()) *fakeTimer {
+	t := &fakeTimer{c: c, f: f}
+	if f == nil {
+		t.ch = make(chan time.Time, 1)
+	}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer whose
+// deadline is reached, in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		next := -1
+		for i, t := range c.timers {
+			if !t.when.After(end) && (next < 0 || t.when.Before(c.timers[next].when)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		t := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		now := c.now
+		c.mu.Unlock()
+		t.fire(now)
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// PendingTimers reports the number of timers that have not yet fired
+// or been stopped. Tests can poll it to wait until the code under test
+// has armed its timers before calling Advance.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	c    *FakeClock
+	when time.Time
+	ch   chan time.Time
+	f    func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+// removeLocked removes t from the clock's pending timers and reports
+// whether it was pending. c.mu must be held.
+func (t *fakeTimer) removeLocked() bool {
+	for i, o := range t.c.timers {
+		if o == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	active := t.removeLocked()
+	t.when = t.c.now.Add(d)
+	t.c.timers = append(t.c.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	return t.removeLocked()
+}
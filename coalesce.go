@@ -0,0 +1,119 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"strconv"
+)
+
+// CoalescingPolicy 决定 HTTP/2 请求能否复用到为其他主机名建立的连接上 (连接合并)
+//
+// 浏览器在证书同时覆盖两个主机名、且两者解析到同一地址时共用一个 HTTP/2 连接，
+// 因此访问 www.example.com 和 static.example.com 只会建立一个连接；
+// 每个主机名都建立新连接的客户端在服务端看来与浏览器不同。
+//
+// 合并只发生在端口相同、连接池划分相同 (见 WithFingerprint、WithAffinity、
+// IsolateCredentials) 的直连连接之间，经代理的连接不合并。连接的证书链按
+// TLSClientConfig.RootCAs (为 nil 时使用系统根证书) 对新主机名重新验证，
+// 即使设置了 InsecureSkipVerify 也是如此，未通过验证时总是建立新连接。
+type CoalescingPolicy int
+
+const (
+	// CoalescingOff 是默认策略，每个主机名使用自己的连接
+	CoalescingOff CoalescingPolicy = iota
+
+	// CoalescingIP 同 Chrome：证书对新主机名有效，且新主机名解析出的地址
+	// 包含连接的对端地址时复用连接
+	CoalescingIP
+
+	// CoalescingOrigin 同 Firefox：服务端通过 ORIGIN 帧 (RFC 8336) 声明了源集合时，
+	// 只复用到源集合中的主机名，且不要求地址相同；没有声明时同 CoalescingIP
+	CoalescingOrigin
+)
+
+func (p CoalescingPolicy) String() string {
+	switch p {
+	case CoalescingOff:
+		return "off"
+	case CoalescingIP:
+		return "ip"
+	case CoalescingOrigin:
+		return "origin"
+	}
+	return "CoalescingPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// lookupIPAddr 解析连接合并时的主机名，测试中替换
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// coalesceLookup 在一次连接合并中只解析一次目标主机名
+type coalesceLookup struct {
+	host   string
+	done   bool
+	ips    []net.IPAddr
+	lookup func(context.Context, string) ([]net.IPAddr, error)
+}
+
+func (l *coalesceLookup) get(ctx context.Context) []net.IPAddr {
+	if !l.done {
+		l.done = true
+		if ip := net.ParseIP(l.host); ip != nil {
+			l.ips = []net.IPAddr{{IP: ip}}
+		} else {
+			l.ips, _ = l.lookup(ctx, l.host)
+		}
+	}
+	return l.ips
+}
+
+// canCoalesce 报告发往 addr (host:port) 的请求能否复用 HTTP/2 连接 cc
+func (t *Transport) canCoalesce(ctx context.Context, cc *http2ClientConn, addr string, lookup *coalesceLookup) bool {
+	if t.HTTP2Coalescing == CoalescingOff || cc.tlsState == nil || len(cc.tlsState.PeerCertificates) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if !t.coalesceCertValid(cc.tlsState.PeerCertificates, host) {
+		return false
+	}
+	if t.HTTP2Coalescing == CoalescingOrigin {
+		if ok, known := cc.inOriginSet(addr); known {
+			return ok
+		}
+	}
+	remote, _, err := net.SplitHostPort(cc.tconn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+	remoteIP := net.ParseIP(remote)
+	for _, ip := range lookup.get(ctx) {
+		if ip.IP.Equal(remoteIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// coalesceCertValid 报告证书链 certs 是否可以验证为 host 的证书
+func (t *Transport) coalesceCertValid(certs []*x509.Certificate, host string) bool {
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   t.now(),
+	}
+	if t.TLSClientConfig != nil {
+		opts.Roots = t.TLSClientConfig.RootCAs
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(opts)
+	return err == nil
+}
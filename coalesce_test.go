@@ -0,0 +1,162 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// coalesceTransport 返回把所有主机名都连接到 ts 的 Transport 和建连计数
+func coalesceTransport(t *testing.T, ts *httptest.Server, policy CoalescingPolicy) (*Transport, *atomic.Int32) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	var dials atomic.Int32
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		HTTP2Coalescing:   policy,
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dials.Add(1)
+			var d net.Dialer
+			return d.DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	}
+	t.Cleanup(tr.CloseIdleConnections)
+	return tr, &dials
+}
+
+// fakeLookup 在测试期间以 addrs 解析主机名
+func fakeLookup(t *testing.T, addrs map[string]string) {
+	var mu sync.Mutex
+	orig := lookupIPAddr
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if a, ok := addrs[host]; ok {
+			return []net.IPAddr{{IP: net.ParseIP(a)}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	t.Cleanup(func() { lookupIPAddr = orig })
+}
+
+// TestHTTP2Coalescing 测试按证书和地址合并 HTTP/2 连接
+func TestHTTP2Coalescing(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	// httptest 的证书覆盖 example.com 和 *.example.com，不覆盖 example.org
+	fakeLookup(t, map[string]string{
+		"a.example.com": "127.0.0.1",
+		"b.example.com": "127.0.0.1",
+		"c.example.com": "10.0.0.1",
+	})
+
+	for _, tt := range []struct {
+		policy CoalescingPolicy
+		host   string
+		dials  int32
+	}{
+		{CoalescingIP, "b.example.com", 1},
+		{CoalescingOrigin, "b.example.com", 1},
+		{CoalescingIP, "c.example.com", 2}, // 地址不同
+		{CoalescingOff, "b.example.com", 2},
+	} {
+		tr, dials := coalesceTransport(t, ts, tt.policy)
+		for _, host := range []string{"a.example.com", tt.host, tt.host} {
+			req, _ := NewRequest("GET", "https://"+net.JoinHostPort(host, port)+"/", nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("%v %s: %v", tt.policy, host, err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if want := net.JoinHostPort(host, port); string(b) != want {
+				t.Errorf("%v: Host got %s, want %s", tt.policy, b, want)
+			}
+			// 合并的连接在 ResponseMeta 中报告共用连接的主机
+			coalesced := tt.dials == 1 && host == tt.host
+			want := []string{"a.example.com:" + port}
+			if coalesced {
+				want = append(want, net.JoinHostPort(host, port))
+			} else if host != "a.example.com" {
+				want = []string{net.JoinHostPort(host, port)}
+			}
+			if resp.Meta.Coalesced != coalesced || !slices.Equal(resp.Meta.ConnHosts, want) {
+				t.Errorf("%v %s: Coalesced %v ConnHosts %v, want %v %v", tt.policy, host, resp.Meta.Coalesced, resp.Meta.ConnHosts, coalesced, want)
+			}
+		}
+		if got := dials.Load(); got != tt.dials {
+			t.Errorf("%v %s: 建连 %d 次, want %d", tt.policy, tt.host, got, tt.dials)
+		}
+	}
+
+	// 证书必须对新主机名有效
+	tr, _ := coalesceTransport(t, ts, CoalescingIP)
+	certs := []*x509.Certificate{ts.Certificate()}
+	if !tr.coalesceCertValid(certs, "b.example.com") || tr.coalesceCertValid(certs, "example.org") {
+		t.Error("coalesceCertValid 应只接受证书覆盖的主机名")
+	}
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	if tr.coalesceCertValid(certs, "b.example.com") {
+		t.Error("InsecureSkipVerify 时不应跳过合并的证书验证")
+	}
+}
+
+// TestHTTP2CoalescingOrigin 测试服务端的 ORIGIN 帧限定可合并的主机名
+func TestHTTP2CoalescingOrigin(t *testing.T) {
+	ts := newH2FrameServer(t)
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	ts.greet = func(fr *http2Framer) {
+		o := "https://b.example.com:" + port
+		fr.WriteRawFrame(http2frameOrigin, 0, 0, append(binary.BigEndian.AppendUint16(nil, uint16(len(o))), o...))
+	}
+	fakeLookup(t, map[string]string{
+		"a.example.com": "127.0.0.1",
+		"b.example.com": "10.0.0.1",  // 地址不同，但在源集合中
+		"c.example.com": "127.0.0.1", // 地址相同，但不在源集合中
+	})
+
+	for _, tt := range []struct {
+		policy CoalescingPolicy
+		host   string
+		dials  int32
+	}{
+		{CoalescingOrigin, "b.example.com", 1},
+		{CoalescingOrigin, "c.example.com", 2},
+		{CoalescingIP, "b.example.com", 2},
+		{CoalescingIP, "c.example.com", 1},
+	} {
+		tr, dials := coalesceTransport(t, ts.Server, tt.policy)
+		for _, host := range []string{"a.example.com", tt.host} {
+			req, _ := NewRequest("GET", "https://"+net.JoinHostPort(host, port)+"/", nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("%v %s: %v", tt.policy, host, err)
+			}
+			resp.Body.Close()
+		}
+		if got := dials.Load(); got != tt.dials {
+			t.Errorf("%v %s: 建连 %d 次, want %d", tt.policy, tt.host, got, tt.dials)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// 兼容性契约测试：常用标识符与标准库 net/http 的签名相同，相同的客户端和服务端
+// 场景在两边的行为相同。已知的差异见 docs/MIGRATION.md
+
+// compatPkgs 是本模块的包对应的标准库包，比较签名时视为同一个包
+var compatPkgs = map[string]string{
+	"github.com/vanling1111/tlshttp":           "net/http",
+	"github.com/vanling1111/tlshttp/httptrace": "net/http/httptrace",
+	"github.com/refraction-networking/utls":    "crypto/tls", // Transport.TLSClientConfig、Request.TLS 等使用 utls
+}
+
+// compatDeltas 是已知与标准库不同的成员，见 docs/MIGRATION.md
+var compatDeltas = map[string]bool{
+	"Transport.TLSNextProto": true, // 连接可能是 *utls.UConn，参数为 interface{}
+}
+
+// compatShape 比较 a (本包) 和 b (标准库) 的结构，具名类型只比较包和名称
+func compatShape(a, b reflect.Type) error {
+	if a.Name() != "" || b.Name() != "" {
+		pa := a.PkgPath()
+		if p, ok := compatPkgs[pa]; ok {
+			pa = p
+		}
+		if pa != b.PkgPath() || a.Name() != b.Name() {
+			return fmt.Errorf("%v 对应 %v", a, b)
+		}
+		return nil
+	}
+	if a.Kind() != b.Kind() {
+		return fmt.Errorf("%v 对应 %v", a, b)
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Chan:
+		return compatShape(a.Elem(), b.Elem())
+	case reflect.Array:
+		if a.Len() != b.Len() {
+			return fmt.Errorf("%v 对应 %v", a, b)
+		}
+		return compatShape(a.Elem(), b.Elem())
+	case reflect.Map:
+		if err := compatShape(a.Key(), b.Key()); err != nil {
+			return err
+		}
+		return compatShape(a.Elem(), b.Elem())
+	case reflect.Func:
+		if a.NumIn() != b.NumIn() || a.NumOut() != b.NumOut() || a.IsVariadic() != b.IsVariadic() {
+			return fmt.Errorf("%v 对应 %v", a, b)
+		}
+		for i := range a.NumIn() {
+			if err := compatShape(a.In(i), b.In(i)); err != nil {
+				return fmt.Errorf("参数 %d: %w", i, err)
+			}
+		}
+		for i := range a.NumOut() {
+			if err := compatShape(a.Out(i), b.Out(i)); err != nil {
+				return fmt.Errorf("结果 %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// TestCompatSignatures 测试常用函数和方法的签名与标准库相同
+func TestCompatSignatures(t *testing.T) {
+	funcs := []struct {
+		name      string
+		ours, std any
+	}{
+		{"Get", Get, nethttp.Get},
+		{"Head", Head, nethttp.Head},
+		{"Post", Post, nethttp.Post},
+		{"PostForm", PostForm, nethttp.PostForm},
+		{"NewRequest", NewRequest, nethttp.NewRequest},
+		{"NewRequestWithContext", NewRequestWithContext, nethttp.NewRequestWithContext},
+		{"ReadRequest", ReadRequest, nethttp.ReadRequest},
+		{"ReadResponse", ReadResponse, nethttp.ReadResponse},
+		{"Handle", Handle, nethttp.Handle},
+		{"HandleFunc", HandleFunc, nethttp.HandleFunc},
+		{"NewServeMux", NewServeMux, nethttp.NewServeMux},
+		{"ListenAndServe", ListenAndServe, nethttp.ListenAndServe},
+		{"ListenAndServeTLS", ListenAndServeTLS, nethttp.ListenAndServeTLS},
+		{"Serve", Serve, nethttp.Serve},
+		{"ServeTLS", ServeTLS, nethttp.ServeTLS},
+		{"FileServer", FileServer, nethttp.FileServer},
+		{"FileServerFS", FileServerFS, nethttp.FileServerFS},
+		{"ServeFile", ServeFile, nethttp.ServeFile},
+		{"ServeFileFS", ServeFileFS, nethttp.ServeFileFS},
+		{"ServeContent", ServeContent, nethttp.ServeContent},
+		{"StripPrefix", StripPrefix, nethttp.StripPrefix},
+		{"TimeoutHandler", TimeoutHandler, nethttp.TimeoutHandler},
+		{"MaxBytesHandler", MaxBytesHandler, nethttp.MaxBytesHandler},
+		{"MaxBytesReader", MaxBytesReader, nethttp.MaxBytesReader},
+		{"AllowQuerySemicolons", AllowQuerySemicolons, nethttp.AllowQuerySemicolons},
+		{"Redirect", Redirect, nethttp.Redirect},
+		{"RedirectHandler", RedirectHandler, nethttp.RedirectHandler},
+		{"NotFound", NotFound, nethttp.NotFound},
+		{"NotFoundHandler", NotFoundHandler, nethttp.NotFoundHandler},
+		{"Error", Error, nethttp.Error},
+		{"StatusText", StatusText, nethttp.StatusText},
+		{"SetCookie", SetCookie, nethttp.SetCookie},
+		{"ParseCookie", ParseCookie, nethttp.ParseCookie},
+		{"ParseSetCookie", ParseSetCookie, nethttp.ParseSetCookie},
+		{"DetectContentType", DetectContentType, nethttp.DetectContentType},
+		{"CanonicalHeaderKey", CanonicalHeaderKey, nethttp.CanonicalHeaderKey},
+		{"ParseHTTPVersion", ParseHTTPVersion, nethttp.ParseHTTPVersion},
+		{"ParseTime", ParseTime, nethttp.ParseTime},
+		{"ProxyFromEnvironment", ProxyFromEnvironment, nethttp.ProxyFromEnvironment},
+		{"ProxyURL", ProxyURL, nethttp.ProxyURL},
+		{"NewFileTransport", NewFileTransport, nethttp.NewFileTransport},
+		{"NewResponseController", NewResponseController, nethttp.NewResponseController},
+		{"Client.Do", (*Client).Do, (*nethttp.Client).Do},
+		{"Client.Get", (*Client).Get, (*nethttp.Client).Get},
+		{"Client.Post", (*Client).Post, (*nethttp.Client).Post},
+		{"Client.PostForm", (*Client).PostForm, (*nethttp.Client).PostForm},
+		{"Client.Head", (*Client).Head, (*nethttp.Client).Head},
+		{"Client.CloseIdleConnections", (*Client).CloseIdleConnections, (*nethttp.Client).CloseIdleConnections},
+		{"Transport.RoundTrip", (*Transport).RoundTrip, (*nethttp.Transport).RoundTrip},
+		{"Transport.Clone", (*Transport).Clone, (*nethttp.Transport).Clone},
+		{"Server.ListenAndServe", (*Server).ListenAndServe, (*nethttp.Server).ListenAndServe},
+		{"Server.Serve", (*Server).Serve, (*nethttp.Server).Serve},
+		{"Server.Shutdown", (*Server).Shutdown, (*nethttp.Server).Shutdown},
+		{"ServeMux.Handle", (*ServeMux).Handle, (*nethttp.ServeMux).Handle},
+		{"ServeMux.HandleFunc", (*ServeMux).HandleFunc, (*nethttp.ServeMux).HandleFunc},
+		{"ServeMux.Handler", (*ServeMux).Handler, (*nethttp.ServeMux).Handler},
+		{"Request.WithContext", (*Request).WithContext, (*nethttp.Request).WithContext},
+		{"Request.Clone", (*Request).Clone, (*nethttp.Request).Clone},
+		{"Request.FormValue", (*Request).FormValue, (*nethttp.Request).FormValue},
+		{"Request.PathValue", (*Request).PathValue, (*nethttp.Request).PathValue},
+		{"Request.Cookie", (*Request).Cookie, (*nethttp.Request).Cookie},
+		{"Request.BasicAuth", (*Request).BasicAuth, (*nethttp.Request).BasicAuth},
+		{"Response.Cookies", (*Response).Cookies, (*nethttp.Response).Cookies},
+		{"Response.Location", (*Response).Location, (*nethttp.Response).Location},
+		{"Header.Get", Header.Get, nethttp.Header.Get},
+		{"Header.Values", Header.Values, nethttp.Header.Values},
+		{"Header.Clone", Header.Clone, nethttp.Header.Clone},
+	}
+	for _, f := range funcs {
+		if err := compatShape(reflect.TypeOf(f.ours), reflect.TypeOf(f.std)); err != nil {
+			t.Errorf("%s: %v", f.name, err)
+		}
+	}
+
+	// 两边都有的导出字段和方法类型相同。只比较共有的成员，
+	// 较新的 Go 版本新增的成员见 docs/MIGRATION.md
+	types := []struct{ ours, std reflect.Type }{
+		{reflect.TypeFor[Request](), reflect.TypeFor[nethttp.Request]()},
+		{reflect.TypeFor[Response](), reflect.TypeFor[nethttp.Response]()},
+		{reflect.TypeFor[Client](), reflect.TypeFor[nethttp.Client]()},
+		{reflect.TypeFor[Server](), reflect.TypeFor[nethttp.Server]()},
+		{reflect.TypeFor[Cookie](), reflect.TypeFor[nethttp.Cookie]()},
+		{reflect.TypeFor[Transport](), reflect.TypeFor[nethttp.Transport]()},
+		{reflect.TypeFor[ResponseWriter](), reflect.TypeFor[nethttp.ResponseWriter]()},
+		{reflect.TypeFor[Handler](), reflect.TypeFor[nethttp.Handler]()},
+		{reflect.TypeFor[RoundTripper](), reflect.TypeFor[nethttp.RoundTripper]()},
+		{reflect.TypeFor[CookieJar](), reflect.TypeFor[nethttp.CookieJar]()},
+	}
+	for _, tt := range types {
+		if tt.std.Kind() == reflect.Struct {
+			for i := range tt.std.NumField() {
+				sf := tt.std.Field(i)
+				if of, ok := tt.ours.FieldByName(sf.Name); ok && sf.IsExported() && !compatDeltas[tt.std.Name()+"."+sf.Name] {
+					if err := compatShape(of.Type, sf.Type); err != nil {
+						t.Errorf("%s.%s: %v", tt.std.Name(), sf.Name, err)
+					}
+				}
+			}
+		}
+		ours, std := tt.ours, tt.std
+		if std.Kind() != reflect.Interface {
+			ours, std = reflect.PointerTo(ours), reflect.PointerTo(std)
+		}
+		for i := range std.NumMethod() {
+			sm := std.Method(i)
+			om, ok := ours.MethodByName(sm.Name)
+			if !ok {
+				if std.Kind() == reflect.Interface {
+					t.Errorf("%s 缺少方法 %s", tt.std.Name(), sm.Name)
+				}
+				continue
+			}
+			if err := compatShape(om.Type, sm.Type); err != nil {
+				t.Errorf("%s.%s: %v", tt.std.Name(), sm.Name, err)
+			}
+		}
+	}
+}
+
+// compatObserved 是一次请求中服务端收到和客户端得到的内容
+type compatObserved struct {
+	Method, Path, Body string
+	Header             map[string]string // 服务端收到的部分头部
+	Status             int
+	Location, Got      string // 客户端得到的最终 URL 和响应体
+}
+
+// TestCompatClient 测试相同的请求由本包和标准库的 Client 发出时，服务端收到的请求和
+// 客户端得到的响应相同
+func TestCompatClient(t *testing.T) {
+	var last compatObserved
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		last = compatObserved{Method: r.Method, Path: r.URL.RequestURI(), Body: string(body), Header: map[string]string{}}
+		for _, k := range []string{"User-Agent", "Accept-Encoding", "Content-Type", "Content-Length", "Cookie", "Authorization"} {
+			last.Header[k] = strings.Join(r.Header.Values(k), "; ")
+		}
+		switch r.URL.Path {
+		case "/found":
+			nethttp.Redirect(w, r, "/final?from=found", nethttp.StatusFound)
+		case "/temporary":
+			nethttp.Redirect(w, r, "/final?from=temporary", nethttp.StatusTemporaryRedirect)
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xcaH\xcd\xc9\xc9\x07\x04\x00\x00\xff\xff\x86\xa6\x106\x05\x00\x00\x00"))
+		case "/missing":
+			nethttp.NotFound(w, r)
+		default:
+			fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	type do func(method, url, contentType, body string, cookie, user string) (int, string, string, error)
+	ours := &Client{Transport: &Transport{}}
+	std := &nethttp.Client{Transport: &nethttp.Transport{}}
+	clients := map[string]do{
+		"tlshttp": func(method, u, ct, body, cookie, user string) (int, string, string, error) {
+			req, err := NewRequest(method, u, strings.NewReader(body))
+			if err != nil {
+				return 0, "", "", err
+			}
+			if body == "" {
+				req.Body, req.ContentLength = nil, 0
+			}
+			if ct != "" {
+				req.Header.Set("Content-Type", ct)
+			}
+			if cookie != "" {
+				req.AddCookie(&Cookie{Name: cookie, Value: "1"})
+			}
+			if user != "" {
+				req.SetBasicAuth(user, "pw")
+			}
+			resp, err := ours.Do(req)
+			if err != nil {
+				return 0, "", "", err
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			return resp.StatusCode, resp.Request.URL.RequestURI(), string(b), err
+		},
+		"net/http": func(method, u, ct, body, cookie, user string) (int, string, string, error) {
+			req, err := nethttp.NewRequest(method, u, strings.NewReader(body))
+			if err != nil {
+				return 0, "", "", err
+			}
+			if body == "" {
+				req.Body, req.ContentLength = nil, 0
+			}
+			if ct != "" {
+				req.Header.Set("Content-Type", ct)
+			}
+			if cookie != "" {
+				req.AddCookie(&nethttp.Cookie{Name: cookie, Value: "1"})
+			}
+			if user != "" {
+				req.SetBasicAuth(user, "pw")
+			}
+			resp, err := std.Do(req)
+			if err != nil {
+				return 0, "", "", err
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			return resp.StatusCode, resp.Request.URL.RequestURI(), string(b), err
+		},
+	}
+	defer ours.CloseIdleConnections()
+	defer std.CloseIdleConnections()
+
+	form := url.Values{"a": {"1"}, "b": {"x y"}}.Encode()
+	scenarios := []struct {
+		name, method, path, contentType, body, cookie, user string
+	}{
+		{name: "GET", method: "GET", path: "/get?q=1"},
+		{name: "HEAD", method: "HEAD", path: "/head"},
+		{name: "POST 表单", method: "POST", path: "/post", contentType: "application/x-www-form-urlencoded", body: form},
+		{name: "302 将 POST 改为 GET", method: "POST", path: "/found", contentType: "text/plain", body: "data"},
+		{name: "307 保留方法和请求体", method: "PUT", path: "/temporary", contentType: "text/plain", body: "data"},
+		{name: "透明 gzip 解压", method: "GET", path: "/gzip"},
+		{name: "404", method: "GET", path: "/missing"},
+		{name: "Cookie 和 Basic 认证", method: "GET", path: "/auth", cookie: "sid", user: "alice"},
+	}
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			got := map[string]compatObserved{}
+			for name, c := range clients {
+				last = compatObserved{}
+				status, final, body, err := c(sc.method, ts.URL+sc.path, sc.contentType, sc.body, sc.cookie, sc.user)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				obs := last
+				obs.Status, obs.Location, obs.Got = status, final, body
+				got[name] = obs
+			}
+			if a, b := got["tlshttp"], got["net/http"]; !reflect.DeepEqual(a, b) {
+				t.Errorf("tlshttp: %+v\nnet/http: %+v", a, b)
+			}
+		})
+	}
+}
+
+// TestCompatServer 测试以相同方式配置的本包和标准库的 Server、ServeMux、FileServer
+// 对相同的请求给出相同的响应
+func TestCompatServer(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":     {Data: []byte("<h1>home</h1>")},
+		"static/app.js":  {Data: []byte("console.log(1)")},
+		"static/a b.txt": {Data: []byte("space")},
+	}
+
+	oursMux := NewServeMux()
+	oursMux.Handle("/files/", StripPrefix("/files", FileServerFS(files)))
+	oursMux.HandleFunc("GET /items/{id}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "item %s", r.PathValue("id"))
+	})
+	oursMux.HandleFunc("POST /items", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusCreated)
+		fmt.Fprint(w, r.FormValue("name"))
+	})
+	oursMux.Handle("/old", RedirectHandler("/items/1", StatusMovedPermanently))
+	oursMux.HandleFunc("/error", func(w ResponseWriter, r *Request) {
+		Error(w, "boom", StatusTeapot)
+	})
+	oursMux.HandleFunc("/cookie", func(w ResponseWriter, r *Request) {
+		SetCookie(w, &Cookie{Name: "sid", Value: "abc", Path: "/", HttpOnly: true, SameSite: SameSiteLaxMode})
+	})
+
+	stdMux := nethttp.NewServeMux()
+	stdMux.Handle("/files/", nethttp.StripPrefix("/files", nethttp.FileServerFS(files)))
+	stdMux.HandleFunc("GET /items/{id}", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		fmt.Fprintf(w, "item %s", r.PathValue("id"))
+	})
+	stdMux.HandleFunc("POST /items", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusCreated)
+		fmt.Fprint(w, r.FormValue("name"))
+	})
+	stdMux.Handle("/old", nethttp.RedirectHandler("/items/1", nethttp.StatusMovedPermanently))
+	stdMux.HandleFunc("/error", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		nethttp.Error(w, "boom", nethttp.StatusTeapot)
+	})
+	stdMux.HandleFunc("/cookie", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		nethttp.SetCookie(w, &nethttp.Cookie{Name: "sid", Value: "abc", Path: "/", HttpOnly: true, SameSite: nethttp.SameSiteLaxMode})
+	})
+
+	servers := map[string]string{}
+	for name, srv := range map[string]interface {
+		Serve(net.Listener) error
+		Close() error
+	}{
+		"tlshttp":  &Server{Handler: oursMux},
+		"net/http": &nethttp.Server{Handler: stdMux},
+	} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.Serve(ln)
+		defer srv.Close()
+		servers[name] = "http://" + ln.Addr().String()
+	}
+
+	client := &nethttp.Client{
+		Transport:     &nethttp.Transport{},
+		CheckRedirect: func(*nethttp.Request, []*nethttp.Request) error { return nethttp.ErrUseLastResponse },
+	}
+	defer client.CloseIdleConnections()
+	requests := []struct{ method, path, body string }{
+		{"GET", "/files/", ""},
+		{"GET", "/files/index.html", ""},
+		{"GET", "/files/static/app.js", ""},
+		{"GET", "/files/static/a%20b.txt", ""},
+		{"GET", "/files/static", ""},
+		{"GET", "/files/missing", ""},
+		{"GET", "/items/42", ""},
+		{"DELETE", "/items/42", ""},
+		{"POST", "/items", "name=widget"},
+		{"GET", "/old", ""},
+		{"GET", "/error", ""},
+		{"GET", "/cookie", ""},
+		{"GET", "/nowhere", ""},
+	}
+	for _, r := range requests {
+		type result struct {
+			Status int
+			Header map[string][]string
+			Body   string
+		}
+		got := map[string]result{}
+		for name, base := range servers {
+			req, _ := nethttp.NewRequest(r.method, base+r.path, strings.NewReader(r.body))
+			if r.body != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s %s: %v", name, r.method, r.path, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			h := maps.Clone(resp.Header)
+			delete(h, "Date")
+			got[name] = result{resp.StatusCode, h, string(body)}
+		}
+		if a, b := got["tlshttp"], got["net/http"]; !reflect.DeepEqual(a, b) {
+			keys := slices.Sorted(maps.Keys(a.Header))
+			t.Errorf("%s %s:\ntlshttp:  %d %v %q (%v)\nnet/http: %d %v %q", r.method, r.path, a.Status, a.Header, a.Body, keys, b.Status, b.Header, b.Body)
+		}
+	}
+}
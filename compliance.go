@@ -0,0 +1,118 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// weakCipherSuites 是 StrictTLS 拒绝发送的密码套件及其原因
+var weakCipherSuites = map[uint16]string{
+	// NULL 加密
+	0x0000: "NULL", 0x0001: "NULL", 0x0002: "NULL", 0x002c: "NULL", 0x002d: "NULL",
+	0x002e: "NULL", 0x003b: "NULL", 0x00b0: "NULL", 0x00b1: "NULL", 0x00b4: "NULL",
+	0x00b5: "NULL", 0x00b8: "NULL", 0x00b9: "NULL", 0xc001: "NULL", 0xc006: "NULL",
+	0xc00b: "NULL", 0xc010: "NULL", 0xc015: "NULL", 0xc039: "NULL", 0xc03a: "NULL",
+	0xc03b: "NULL",
+
+	// 出口级 (EXPORT) 弱加密
+	0x0003: "EXPORT", 0x0006: "EXPORT", 0x0008: "EXPORT", 0x000b: "EXPORT", 0x000e: "EXPORT",
+	0x0011: "EXPORT", 0x0014: "EXPORT", 0x0017: "EXPORT", 0x0019: "EXPORT", 0x0026: "EXPORT",
+	0x0027: "EXPORT", 0x0028: "EXPORT", 0x0029: "EXPORT", 0x002a: "EXPORT", 0x002b: "EXPORT",
+	0x0062: "EXPORT", 0x0063: "EXPORT", 0x0064: "EXPORT", 0x0065: "EXPORT",
+
+	// RC4
+	0x0004: "RC4", 0x0005: "RC4", 0x0018: "RC4", 0x0020: "RC4", 0x0024: "RC4",
+	0x008a: "RC4", 0x008e: "RC4", 0x0092: "RC4", 0xc002: "RC4", 0xc007: "RC4",
+	0xc00c: "RC4", 0xc011: "RC4", 0xc016: "RC4", 0xc033: "RC4",
+}
+
+// weakExtensions 是 StrictTLS 拒绝发送的 SSL3/早期 TLS 时代扩展
+var weakExtensions = map[uint16]string{
+	2:  "client_certificate_url",
+	4:  "truncated_hmac",
+	6:  "user_mapping",
+	9:  "cert_type",
+	15: "heartbeat",
+}
+
+// WeakTLSError 表示 StrictTLS 模式下 ClientHello 包含不安全的组件
+type WeakTLSError struct {
+	Ciphers    []uint16 // 不安全的密码套件
+	Extensions []uint16 // 已废弃的不安全扩展
+}
+
+func (e *WeakTLSError) Error() string {
+	var parts []string
+	for _, c := range e.Ciphers {
+		parts = append(parts, fmt.Sprintf("密码套件 %d (%s)", c, weakCipherSuites[c]))
+	}
+	for _, id := range e.Extensions {
+		parts = append(parts, fmt.Sprintf("扩展 %d (%s)", id, weakExtensions[id]))
+	}
+	return "ClientHello 包含不安全的组件: " + strings.Join(parts, ", ") + "；如确需发送请设置 AllowWeakTLS"
+}
+
+// CheckJA3Compliance 检查 JA3 字符串是否包含 StrictTLS 会拒绝的组件
+//
+// 返回 nil 表示通过；包含 EXPORT、NULL、RC4 密码套件或 SSL3 时代扩展时
+// 返回 *WeakTLSError。可用于在使用前校验从抓包工具复制的 JA3。
+func CheckJA3Compliance(ja3 string) error {
+	parts := strings.Split(ja3, ",")
+	if len(parts) != 5 {
+		return fmt.Errorf("无效的 JA3 格式，应为 5 个部分，实际为 %d 个", len(parts))
+	}
+	var ciphers, exts []uint16
+	for i, field := range parts[1:3] {
+		for _, s := range strings.Split(field, "-") {
+			if s == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				return fmt.Errorf("无效的 JA3 值: %s", s)
+			}
+			if i == 0 {
+				ciphers = append(ciphers, uint16(v))
+			} else {
+				exts = append(exts, uint16(v))
+			}
+		}
+	}
+	return checkWeakTLS(ciphers, exts)
+}
+
+// checkSpecCompliance 检查 spec 是否包含 StrictTLS 会拒绝的组件
+func checkSpecCompliance(spec *tls.ClientHelloSpec) error {
+	var exts []uint16
+	for _, e := range spec.Extensions {
+		if g, ok := e.(*tls.GenericExtension); ok {
+			exts = append(exts, g.Id)
+		}
+	}
+	return checkWeakTLS(spec.CipherSuites, exts)
+}
+
+func checkWeakTLS(ciphers, exts []uint16) error {
+	e := &WeakTLSError{}
+	for _, c := range ciphers {
+		if _, weak := weakCipherSuites[c]; weak {
+			e.Ciphers = append(e.Ciphers, c)
+		}
+	}
+	for _, id := range exts {
+		if _, weak := weakExtensions[id]; weak {
+			e.Extensions = append(e.Extensions, id)
+		}
+	}
+	if len(e.Ciphers) == 0 && len(e.Extensions) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestCheckJA3Compliance 测试 JA3 中不安全组件的识别
+func TestCheckJA3Compliance(t *testing.T) {
+	tests := []struct {
+		name           string
+		ja3            string
+		wantCiphers    []uint16
+		wantExtensions []uint16
+		wantErr        bool
+	}{
+		{
+			name: "现代浏览器",
+			ja3:  "771,4865-4866-4867-49195-49199,0-10-11-13-43-51,29-23-24,0",
+		},
+		{
+			name:        "RC4 和 NULL 密码套件",
+			ja3:         "771,49195-5-2,0-10-11,29-23,0",
+			wantCiphers: []uint16{5, 2},
+			wantErr:     true,
+		},
+		{
+			name:           "EXPORT 密码套件和 heartbeat 扩展",
+			ja3:            "771,49195-3,0-10-11-15,29-23,0",
+			wantCiphers:    []uint16{3},
+			wantExtensions: []uint16{15},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckJA3Compliance(tt.ja3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckJA3Compliance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			var we *WeakTLSError
+			if !errors.As(err, &we) {
+				t.Fatalf("got %T, want *WeakTLSError", err)
+			}
+			if !slices.Equal(we.Ciphers, tt.wantCiphers) {
+				t.Errorf("Ciphers got %v, want %v", we.Ciphers, tt.wantCiphers)
+			}
+			if !slices.Equal(we.Extensions, tt.wantExtensions) {
+				t.Errorf("Extensions got %v, want %v", we.Extensions, tt.wantExtensions)
+			}
+		})
+	}
+}
+
+// TestTransportStrictTLS 测试合规模式拒绝建连以及 AllowWeakTLS 覆盖
+func TestTransportStrictTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+
+	// 服务端不接受 RC4，但会协商同时提供的 ECDHE-AES 套件
+	const weakJA3 = "771,49199-5,0-10-11-13-65281,29-23,0"
+	tests := []struct {
+		name        string
+		strict      bool
+		allowWeak   bool
+		wantWeakErr bool
+	}{
+		{name: "默认不检查"},
+		{name: "合规模式", strict: true, wantWeakErr: true},
+		{name: "显式允许", strict: true, allowWeak: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:             weakJA3,
+				UserAgent:       "Mozilla/5.0 Firefox/120.0",
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				StrictTLS:       tt.strict,
+				AllowWeakTLS:    tt.allowWeak,
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(ts.URL)
+			var we *WeakTLSError
+			if got := errors.As(err, &we); got != tt.wantWeakErr {
+				t.Fatalf("WeakTLSError got %v, want %v (err = %v)", got, tt.wantWeakErr, err)
+			}
+			if tt.wantWeakErr {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() 失败: %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// connIdentity 记录连接在连接池中的身份，用于填充 httptrace.GotConnInfo
+// 在 dialConn 中建立连接后生成，之后不再修改
+type connIdentity struct {
+	key             string          // connectMethodKey 的字符串形式（代理密码已脱敏）
+	proxy           string          // 使用的代理 URL（密码已脱敏），直连时为空
+	fingerprintHash string          // 实际发送的 ClientHello 的 JA3 哈希，未使用 utls 时为空
+	ja3n            string          // 同一 ClientHello 的 JA3N 哈希
+	ja4x            []string        // 服务端证书链的 JA4X，叶子证书在前，非 TLS 连接为空
+	ja4l            string          // 握手测得的服务端 JA4L 延迟部分，非 TLS 连接为空
+	ja3s, ja4s      string          // 服务端 ServerHello 的 JA3S 哈希和 JA4S，非 TLS 连接为空
+	echAccepted     bool            // 服务端是否接受了加密的 ClientHello
+	scts            []httptrace.SCT // 服务端提供的 SCT 及其校验结果，未设置 CTLogs 时为空
+	partition       connPartition   // 连接在 HTTP/2 连接池中的划分
+}
+
+func newConnIdentity(cm connectMethod, pconn *persistConn, ja4x []string, scts []httptrace.SCT) *connIdentity {
+	id := &connIdentity{fingerprintHash: pconn.fingerprintHash, ja3n: pconn.ja3n, ja4x: ja4x, ja4l: pconn.ja4l, ja3s: pconn.ja3s, ja4s: pconn.ja4s, scts: scts, partition: cm.key().h2Partition()}
+	if pconn.tlsState != nil {
+		id.echAccepted = pconn.tlsState.ECHAccepted
+	}
+	k := cm.key()
+	if cm.proxyURL != nil {
+		id.proxy = cm.proxyURL.Redacted()
+		k.proxy = id.proxy
+	}
+	id.key = k.String()
+	return id
+}
+
+// fill 将身份信息写入 info，protocol 为实际使用的应用层协议
+func (id *connIdentity) fill(info *httptrace.GotConnInfo, protocol string) {
+	info.Protocol = protocol
+	if id == nil {
+		return
+	}
+	info.ConnKey = id.key
+	info.Proxy = id.proxy
+	info.FingerprintHash = id.fingerprintHash
+	info.JA3N = id.ja3n
+	info.JA4X = id.ja4x
+	info.JA4L = id.ja4l
+	info.JA3S = id.ja3s
+	info.JA4S = id.ja4s
+	info.ECHAccepted = id.echAccepted
+	info.SCTs = id.scts
+}
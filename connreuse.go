@@ -0,0 +1,82 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"time"
+)
+
+// ConnReuseInfo 描述一个即将被复用的连接，传给 Transport.ShouldReuseConnection
+type ConnReuseInfo struct {
+	Addr  string // 目标地址 host:port
+	Proto string // "HTTP/1.1" 或 "HTTP/2.0"
+
+	// Age 是连接建立以来的时间
+	Age time.Duration
+
+	// IdleTime 是连接从空闲连接池取出时已空闲的时间，请求刚完成时为 0
+	IdleTime time.Duration
+
+	// Requests 是连接上已完成的请求数，HTTP/2 为已发出的请求数 (包括进行中的)
+	Requests int
+}
+
+var errConnRetired = errors.New("http: putIdleConn: connection retired by ConnMaxLifetime or ShouldReuseConnection")
+
+// shouldReuseConn 报告按 ConnMaxLifetime 和 ShouldReuseConnection，
+// info 描述的连接能否用于新请求
+func (t *Transport) shouldReuseConn(info ConnReuseInfo) bool {
+	if t.ConnMaxLifetime > 0 && info.Age >= t.ConnMaxLifetime {
+		return false
+	}
+	return t.ShouldReuseConnection == nil || t.ShouldReuseConnection(info)
+}
+
+// reuseInfo 返回 HTTP/1 连接 pc 的 ConnReuseInfo，idle 是已空闲的时间
+func (pc *persistConn) reuseInfo(idle time.Duration) ConnReuseInfo {
+	pc.mu.Lock()
+	requests := pc.requests
+	pc.mu.Unlock()
+	return ConnReuseInfo{
+		Addr:     pc.cacheKey.addr,
+		Proto:    "HTTP/1.1",
+		Age:      pc.t.now().Sub(pc.createdAt),
+		IdleTime: idle,
+		Requests: requests,
+	}
+}
+
+// retiredLocked 报告按 Transport 的 ConnMaxLifetime 和 ShouldReuseConnection，
+// HTTP/2 连接 cc 是否不能再用于新请求。不能时标记 doNotReuse，连接在没有
+// 进行中的请求后关闭。cc.mu 必须已锁定
+func (cc *http2ClientConn) retiredLocked() bool {
+	t1 := cc.t.t1
+	if cc.doNotReuse || t1 == nil || t1.ConnMaxLifetime <= 0 && t1.ShouldReuseConnection == nil {
+		return cc.doNotReuse
+	}
+	now := cc.t.now()
+	info := ConnReuseInfo{
+		Proto:    "HTTP/2.0",
+		Age:      now.Sub(cc.createdAt),
+		Requests: cc.requests,
+	}
+	if len(cc.hosts) > 0 {
+		info.Addr = cc.hosts[0]
+	}
+	idle := len(cc.streams) == 0 && cc.streamsReserved == 0
+	if idle && !cc.lastActive.IsZero() {
+		info.IdleTime = now.Sub(cc.lastActive)
+	}
+	if t1.shouldReuseConn(info) {
+		return false
+	}
+	cc.doNotReuse = true
+	if idle {
+		// 没有请求会再让它关闭
+		go cc.closeIfIdle()
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// connCountingServer 返回记录建立的连接数的服务端
+func connCountingServer(t *testing.T, h2 bool) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	conns := 0
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.Config.ConnState = func(c net.Conn, s nethttp.ConnState) {
+		if s == nethttp.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	if h2 {
+		ts.EnableHTTP2 = true
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return ts, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return conns
+	}
+}
+
+// TestShouldReuseConnection 测试 ShouldReuseConnection 按请求数更换连接
+func TestShouldReuseConnection(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		ts, conns := connCountingServer(t, h2)
+		var mu sync.Mutex
+		var infos []ConnReuseInfo
+		tr := &Transport{
+			ShouldReuseConnection: func(info ConnReuseInfo) bool {
+				mu.Lock()
+				infos = append(infos, info)
+				mu.Unlock()
+				return info.Requests < 3
+			},
+		}
+		if h2 {
+			tr.JA3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+			tr.ForceAttemptHTTP2 = true
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		for i := 0; i < 7; i++ {
+			req, _ := NewRequest("GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if h2 && resp.ProtoMajor != 2 {
+				t.Fatalf("got %s, want HTTP/2", resp.Proto)
+			}
+		}
+		tr.CloseIdleConnections()
+
+		if got := conns(); got != 3 {
+			t.Errorf("h2=%v: 7 个请求建立了 %d 个连接, want 3", h2, got)
+		}
+		mu.Lock()
+		if len(infos) == 0 {
+			t.Fatalf("h2=%v: ShouldReuseConnection 没有被调用", h2)
+		}
+		wantProto := "HTTP/1.1"
+		if h2 {
+			wantProto = "HTTP/2.0"
+		}
+		for _, info := range infos {
+			if info.Proto != wantProto || info.Addr != ts.Listener.Addr().String() || info.Requests > 3 {
+				t.Errorf("h2=%v: got %+v", h2, info)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+// TestConnMaxLifetime 测试超过 ConnMaxLifetime 的空闲连接不再复用
+func TestConnMaxLifetime(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		ts, conns := connCountingServer(t, h2)
+		clock := NewFakeClock(time.Now())
+		var ages []time.Duration
+		tr := &Transport{
+			Clock:           clock,
+			ConnMaxLifetime: time.Minute,
+			ShouldReuseConnection: func(info ConnReuseInfo) bool {
+				ages = append(ages, info.Age)
+				return true
+			},
+		}
+		if h2 {
+			tr.JA3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+			tr.ForceAttemptHTTP2 = true
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		get := func() {
+			t.Helper()
+			req, _ := NewRequest("GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		get()
+		clock.Advance(30 * time.Second)
+		get()
+		if got := conns(); got != 1 {
+			t.Errorf("h2=%v: 未超过 ConnMaxLifetime 时建立了 %d 个连接, want 1", h2, got)
+		}
+		clock.Advance(31 * time.Second)
+		get()
+		if got := conns(); got != 2 {
+			t.Errorf("h2=%v: 超过 ConnMaxLifetime 后建立了 %d 个连接, want 2", h2, got)
+		}
+		tr.CloseIdleConnections()
+		if !slices.Contains(ages, 30*time.Second) {
+			t.Errorf("h2=%v: ShouldReuseConnection 收到的 Age got %v", h2, ages)
+		}
+	}
+}
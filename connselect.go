@@ -0,0 +1,45 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import "strconv"
+
+// ConnSelectionPolicy 决定连接池中有多个可用连接时，请求使用哪一个
+//
+// 默认的 ConnSelectionMRU 会把流量集中在一个连接上，其余连接长期空闲，
+// 既不像浏览器的行为，也容易触发服务端对单连接的请求数限制。
+type ConnSelectionPolicy int
+
+const (
+	// ConnSelectionMRU 是默认策略：HTTP/1 使用最近放回的空闲连接，
+	// HTTP/2 按建连顺序使用第一个还能承载新请求的连接
+	ConnSelectionMRU ConnSelectionPolicy = iota
+
+	// ConnSelectionLRU 使用空闲最久的连接：HTTP/1 取最早放回的空闲连接，
+	// HTTP/2 取最久没有活动的连接
+	ConnSelectionLRU
+
+	// ConnSelectionRoundRobin 轮流使用连接：HTTP/2 每个请求从上次使用的
+	// 连接的下一个开始查找；HTTP/1 的空闲连接按放回顺序排队，同 ConnSelectionLRU
+	ConnSelectionRoundRobin
+
+	// ConnSelectionLeastLoaded 使用活跃流最少的 HTTP/2 连接，相同时按建连顺序；
+	// HTTP/1 的空闲连接没有负载之分，同 ConnSelectionLRU
+	ConnSelectionLeastLoaded
+)
+
+func (p ConnSelectionPolicy) String() string {
+	switch p {
+	case ConnSelectionMRU:
+		return "mru"
+	case ConnSelectionLRU:
+		return "lru"
+	case ConnSelectionRoundRobin:
+		return "round-robin"
+	case ConnSelectionLeastLoaded:
+		return "least-loaded"
+	}
+	return "ConnSelectionPolicy(" + strconv.Itoa(int(p)) + ")"
+}
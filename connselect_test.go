@@ -0,0 +1,117 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConnSelectionHTTP1 测试 HTTP/1 空闲连接的选择顺序
+func TestConnSelectionHTTP1(t *testing.T) {
+	tests := []struct {
+		policy ConnSelectionPolicy
+		rotate bool // 连续请求是否轮流使用两个连接
+	}{
+		{ConnSelectionMRU, false},
+		{ConnSelectionLRU, true},
+		{ConnSelectionRoundRobin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(2)
+			srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				if r.URL.Path == "/warm" {
+					// 让两个请求同时占用连接，池中留下两个空闲连接
+					wg.Done()
+					wg.Wait()
+				}
+				io.WriteString(w, r.RemoteAddr)
+			}))
+			defer srv.Close()
+			tr := &Transport{ConnSelection: tt.policy}
+			defer tr.CloseIdleConnections()
+			c := &Client{Transport: tr}
+			get := func(path string) string {
+				resp, err := c.Get(srv.URL + path)
+				if err != nil {
+					t.Error(err)
+					return ""
+				}
+				defer resp.Body.Close()
+				b, _ := io.ReadAll(resp.Body)
+				return string(b)
+			}
+
+			var warm sync.WaitGroup
+			for range 2 {
+				warm.Add(1)
+				go func() {
+					defer warm.Done()
+					get("/warm")
+				}()
+			}
+			warm.Wait()
+
+			var addrs []string
+			for range 4 {
+				addrs = append(addrs, get("/"))
+			}
+			rotated := addrs[0] != addrs[1] && addrs[0] == addrs[2] && addrs[1] == addrs[3]
+			same := addrs[0] == addrs[1] && addrs[1] == addrs[2] && addrs[2] == addrs[3]
+			if tt.rotate && !rotated || !tt.rotate && !same {
+				t.Errorf("连接顺序 got %v, rotate %v", strings.Join(addrs, " "), tt.rotate)
+			}
+		})
+	}
+}
+
+// TestConnSelectionHTTP2 测试 HTTP/2 连接的选择顺序
+func TestConnSelectionHTTP2(t *testing.T) {
+	now := time.Now()
+	newConn := func(idle time.Duration, streams int) *http2ClientConn {
+		cc := &http2ClientConn{lastActive: now.Add(-idle), streams: make(map[uint32]*http2clientStream)}
+		for i := range streams {
+			cc.streams[uint32(2*i+1)] = nil
+		}
+		return cc
+	}
+	a, b, c := newConn(time.Second, 3), newConn(3*time.Second, 1), newConn(2*time.Second, 2)
+
+	tests := []struct {
+		policy ConnSelectionPolicy
+		want   [][]*http2ClientConn // 连续两次选择的顺序
+	}{
+		{ConnSelectionMRU, [][]*http2ClientConn{{a, b, c}, {a, b, c}}},
+		{ConnSelectionLRU, [][]*http2ClientConn{{b, c, a}, {b, c, a}}},
+		{ConnSelectionRoundRobin, [][]*http2ClientConn{{a, b, c}, {b, c, a}}},
+		{ConnSelectionLeastLoaded, [][]*http2ClientConn{{b, c, a}, {b, c, a}}},
+	}
+	names := map[*http2ClientConn]string{a: "a", b: "b", c: "c"}
+	str := func(conns []*http2ClientConn) string {
+		var s []string
+		for _, cc := range conns {
+			s = append(s, names[cc])
+		}
+		return strings.Join(s, "")
+	}
+	for _, tt := range tests {
+		p := &http2clientConnPool{
+			t:     &HTTP2Transport{t1: &Transport{ConnSelection: tt.policy}},
+			conns: map[string][]*http2ClientConn{"example.com:443": {a, b, c}},
+		}
+		for i, want := range tt.want {
+			if got := p.selectLocked("example.com:443"); str(got) != str(want) {
+				t.Errorf("%v 第 %d 次 got %v, want %v", tt.policy, i+1, str(got), str(want))
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ package cookiejar
 
 import (
 	"cmp"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -57,11 +58,19 @@ type Options struct {
 	// secure: it means that the HTTP server for foo.co.uk can set a cookie
 	// for bar.co.uk.
 	PublicSuffixList PublicSuffixList
+
+	// Storage, if non-nil, persists the jar's persistent cookies (those
+	// with Expires or Max-Age) in the http.StorageNamespaceCookies
+	// namespace, keyed by eTLD+1. Cookies for a key are loaded from
+	// Storage when the key is first used. Session cookies are kept in
+	// memory only, as in a browser. Storage errors are ignored.
+	Storage http.Storage
 }
 
 // Jar implements the http.CookieJar interface from the net/http package.
 type Jar struct {
-	psList PublicSuffixList
+	psList  PublicSuffixList
+	storage http.Storage
 
 	// mu locks the remaining fields.
 	mu sync.Mutex
@@ -70,6 +79,10 @@ type Jar struct {
 	// their name/domain/path.
 	entries map[string]map[string]entry
 
+	// loaded is the set of keys whose entries have been loaded from
+	// storage.
+	loaded map[string]bool
+
 	// nextSeqNum is the next sequence number assigned to a new cookie
 	// created SetCookies.
 	nextSeqNum uint64
@@ -83,6 +96,7 @@ func New(o *Options) (*Jar, error) {
 	}
 	if o != nil {
 		jar.psList = o.PublicSuffixList
+		jar.storage = o.Storage
 	}
 	return jar, nil
 }
@@ -175,7 +189,7 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	submap := j.entries[key]
+	submap := j.loadLocked(key)
 	if submap == nil {
 		return cookies
 	}
@@ -186,12 +200,12 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 		path = "/"
 	}
 
-	modified := false
+	modified, expired := false, false
 	var selected []entry
 	for id, e := range submap {
 		if e.Persistent && !e.Expires.After(now) {
 			delete(submap, id)
-			modified = true
+			modified, expired = true, true
 			continue
 		}
 		if !e.shouldSend(https, host, path) {
@@ -209,6 +223,9 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 			j.entries[key] = submap
 		}
 	}
+	if expired {
+		j.saveLocked(key, now)
+	}
 
 	// sort according to RFC 6265 section 5.4 point 2: by longest
 	// path and then by earliest creation time.
@@ -253,7 +270,7 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	submap := j.entries[key]
+	submap := j.loadLocked(key)
 
 	modified := false
 	for _, cookie := range cookies {
@@ -294,7 +311,73 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 		} else {
 			j.entries[key] = submap
 		}
+		j.saveLocked(key, now)
+	}
+}
+
+// loadLocked returns the entries for key, loading them from j.storage
+// the first time key is used.
+func (j *Jar) loadLocked(key string) map[string]entry {
+	if j.storage == nil || j.loaded[key] {
+		return j.entries[key]
+	}
+	if j.loaded == nil {
+		j.loaded = make(map[string]bool)
+	}
+	j.loaded[key] = true
+	data, ok, err := j.storage.Get(http.StorageNamespaceCookies, key)
+	if !ok || err != nil {
+		return j.entries[key]
+	}
+	var stored []entry
+	if json.Unmarshal(data, &stored) != nil {
+		return j.entries[key]
+	}
+	submap := j.entries[key]
+	for _, e := range stored {
+		if submap == nil {
+			submap = make(map[string]entry)
+		}
+		id := e.id()
+		if _, ok := submap[id]; ok {
+			continue
+		}
+		e.seqNum = j.nextSeqNum
+		j.nextSeqNum++
+		submap[id] = e
+	}
+	if submap != nil {
+		j.entries[key] = submap
+	}
+	return submap
+}
+
+// saveLocked writes the persistent entries for key to j.storage,
+// expiring the record with the last of them.
+func (j *Jar) saveLocked(key string, now time.Time) {
+	if j.storage == nil {
+		return
+	}
+	var stored []entry
+	var last time.Time
+	for _, e := range j.entries[key] {
+		if e.Persistent && e.Expires.After(now) {
+			stored = append(stored, e)
+			if e.Expires.After(last) {
+				last = e.Expires
+			}
+		}
+	}
+	if len(stored) == 0 {
+		j.storage.Delete(http.StorageNamespaceCookies, key)
+		return
+	}
+	slices.SortFunc(stored, func(a, b entry) int { return cmp.Compare(a.seqNum, b.seqNum) })
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return
 	}
+	j.storage.Set(http.StorageNamespaceCookies, key, data, last.Sub(now))
 }
 
 // canonicalHost strips port from host if present and returns the canonicalized
@@ -1376,3 +1376,32 @@ func TestIssue19384(t *testing.T) {
 		}
 	}
 }
+
+func TestStorage(t *testing.T) {
+	storage := &http.MemoryStorage{}
+	u, _ := url.Parse("https://www.example.com/")
+	jar, _ := New(&Options{PublicSuffixList: testPSL{}, Storage: storage})
+	jar.setCookies(u, []*http.Cookie{
+		{Name: "persistent", Value: "1", MaxAge: 3600},
+		{Name: "session", Value: "2"},
+		{Name: "short", Value: "3", MaxAge: 60},
+	}, tNow)
+
+	// A new jar sharing the storage sees only the persistent cookies.
+	jar2, _ := New(&Options{PublicSuffixList: testPSL{}, Storage: storage})
+	if got := fmt.Sprint(jar2.cookies(u, tNow)); got != "[persistent=1 short=3]" {
+		t.Errorf("got %s, want [persistent=1 short=3]", got)
+	}
+
+	// Expired and deleted cookies are removed from storage.
+	jar2.cookies(u, tNow.Add(2*time.Minute))
+	jar2.setCookies(u, []*http.Cookie{{Name: "added", Value: "4", MaxAge: 3600}}, tNow.Add(2*time.Minute))
+	jar3, _ := New(&Options{PublicSuffixList: testPSL{}, Storage: storage})
+	if got := fmt.Sprint(jar3.cookies(u, tNow.Add(2*time.Minute))); got != "[persistent=1 added=4]" {
+		t.Errorf("got %s, want [persistent=1 added=4]", got)
+	}
+	jar3.setCookies(u, []*http.Cookie{{Name: "persistent", MaxAge: -1}, {Name: "added", MaxAge: -1}}, tNow.Add(2*time.Minute))
+	if _, ok, _ := storage.Get(http.StorageNamespaceCookies, "example.com"); ok {
+		t.Error("storage still has an entry after all cookies were deleted")
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CORSError 在 Transport.CORSPreflight 的预检请求失败时由 RoundTrip 返回，
+// 此时与浏览器一样不发出实际的请求
+type CORSError struct {
+	URL    string // 实际请求的 URL
+	Origin string // 发起请求的页面的源
+	Reason string // 预检失败的原因
+}
+
+func (e *CORSError) Error() string {
+	return fmt.Sprintf("CORS 预检失败: 源 %s 不能请求 %s: %s", e.Origin, e.URL, e.Reason)
+}
+
+// 预检结果默认缓存 5 秒，Chrome 最多缓存 2 小时
+const (
+	corsDefaultMaxAge = 5 * time.Second
+	corsMaxMaxAge     = 2 * time.Hour
+)
+
+// corsSimpleMethods 是不需要预检的方法
+var corsSimpleMethods = []string{"GET", "HEAD", "POST"}
+
+// corsSafelistedHeaders 是不需要预检的请求头部 (Content-Type 还要求值是表单或纯文本)
+var corsSafelistedHeaders = map[string]bool{
+	"Accept":           true,
+	"Accept-Language":  true,
+	"Content-Language": true,
+	"Content-Type":     true,
+	"Range":            true,
+}
+
+// corsBrowserHeaders 是由浏览器而不是页面脚本设置的头部，不计入预检
+var corsBrowserHeaders = map[string]bool{
+	"Accept-Charset":    true,
+	"Accept-Encoding":   true,
+	"Cache-Control":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Cookie":            true,
+	"Date":              true,
+	"Dnt":               true,
+	"Expect":            true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Origin":            true,
+	"Pragma":            true,
+	"Priority":          true,
+	"Referer":           true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"User-Agent":        true,
+	"Via":               true,
+}
+
+// corsPreflightHeaderOrder 是 Chrome 预检请求的头部顺序
+var corsPreflightHeaderOrder = []string{
+	"accept", "access-control-request-method", "access-control-request-headers", "origin",
+	"user-agent", "sec-fetch-mode", "sec-fetch-site", "sec-fetch-dest", "referer",
+	"accept-encoding", "accept-language", "priority",
+}
+
+// corsPreflightCache 缓存预检结果，零值可以直接使用
+type corsPreflightCache struct {
+	mu      sync.Mutex
+	entries map[string]corsPreflightResult // 键为源、URL 和是否带凭据
+}
+
+// corsPreflightResult 是一次成功的预检允许的方法和头部
+type corsPreflightResult struct {
+	methods []string // 允许的方法，含 "*" 时允许所有方法
+	headers []string // 允许的头部，小写，含 "*" 时允许除 Authorization 外的所有头部
+	expires time.Time
+}
+
+// allows 报告 r 是否允许以 method 发送 headers (小写)
+func (r corsPreflightResult) allows(method string, headers []string, credentials bool) bool {
+	if !slices.Contains(corsSimpleMethods, method) && !slices.Contains(r.methods, method) &&
+		(credentials || !slices.Contains(r.methods, "*")) {
+		return false
+	}
+	for _, h := range headers {
+		if !slices.Contains(r.headers, h) && (credentials || h == "authorization" || !slices.Contains(r.headers, "*")) {
+			return false
+		}
+	}
+	return true
+}
+
+// corsPreflight 在 t.CORSPreflight 为 true 且 req 是 WithDocument 页面发起的需要预检的
+// 跨源请求时，像浏览器一样先发送 OPTIONS 预检请求，预检失败时返回 *CORSError。
+// 预检结果按 Access-Control-Max-Age 缓存，缓存允许时不再预检
+func (t *Transport) corsPreflight(req *Request) error {
+	if !t.CORSPreflight || req.Header.Get("Access-Control-Request-Method") != "" {
+		return nil
+	}
+	doc, ok := documentFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+	switch req.Header.Get("Sec-Fetch-Mode") {
+	case "", "cors":
+	default:
+		// 导航、no-cors 的子资源等不预检
+		return nil
+	}
+	origin := doc.origin()
+	if origin == serializeOrigin(req.URL) {
+		return nil
+	}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	headers := corsUnsafeHeaders(req.Header)
+	if slices.Contains(corsSimpleMethods, method) && len(headers) == 0 {
+		return nil
+	}
+	credentials := req.Header.Get("Cookie") != "" || req.Header.Get("Authorization") != ""
+
+	u := *req.URL
+	u.Fragment = ""
+	key := origin + " " + u.String() + " " + strconv.FormatBool(credentials)
+	now := t.now()
+	t.corsCache.mu.Lock()
+	cached, ok := t.corsCache.entries[key]
+	t.corsCache.mu.Unlock()
+	if ok && now.Before(cached.expires) && cached.allows(method, headers, credentials) {
+		return nil
+	}
+
+	preq, err := NewRequestWithContext(req.Context(), "OPTIONS", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	preq.Host = req.Host
+	preq.Header.Set("Accept", "*/*")
+	preq.Header.Set("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		preq.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ","))
+	}
+	preq.Header.Set("Origin", origin)
+	for _, h := range []string{"User-Agent", "Referer", "Accept-Language"} {
+		if v := req.Header.Get(h); v != "" {
+			preq.Header.Set(h, v)
+		}
+	}
+	preq.Header.Set("Sec-Fetch-Mode", "cors")
+	preq.Header.Set("Sec-Fetch-Site", doc.site(req.URL))
+	preq.Header.Set("Sec-Fetch-Dest", "empty")
+	preq.Header[HeaderOrderKey] = corsPreflightHeaderOrder
+
+	resp, err := t.RoundTrip(preq)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+
+	fail := func(format string, args ...any) error {
+		return &CORSError{URL: req.URL.String(), Origin: origin, Reason: fmt.Sprintf(format, args...)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail("预检响应的状态码为 %d", resp.StatusCode)
+	}
+	switch allowOrigin := resp.Header.Get("Access-Control-Allow-Origin"); {
+	case allowOrigin == "*" && credentials:
+		return fail("带凭据的请求不能使用 Access-Control-Allow-Origin: *")
+	case allowOrigin != "*" && allowOrigin != origin:
+		return fail("Access-Control-Allow-Origin 为 %q", allowOrigin)
+	}
+	if credentials && resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+		return fail("带凭据的请求需要 Access-Control-Allow-Credentials: true")
+	}
+	result := corsPreflightResult{
+		methods: corsHeaderList(resp.Header, "Access-Control-Allow-Methods", false),
+		headers: corsHeaderList(resp.Header, "Access-Control-Allow-Headers", true),
+		expires: now.Add(corsDefaultMaxAge),
+	}
+	if !result.allows(method, nil, credentials) {
+		return fail("Access-Control-Allow-Methods 不允许 %s", method)
+	}
+	for _, h := range headers {
+		if !result.allows(method, []string{h}, credentials) {
+			return fail("Access-Control-Allow-Headers 不允许 %s", h)
+		}
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("Access-Control-Max-Age")); err == nil {
+		result.expires = now.Add(min(time.Duration(v)*time.Second, corsMaxMaxAge))
+	}
+	t.corsCache.mu.Lock()
+	if t.corsCache.entries == nil {
+		t.corsCache.entries = make(map[string]corsPreflightResult)
+	}
+	t.corsCache.entries[key] = result
+	t.corsCache.mu.Unlock()
+	return nil
+}
+
+// corsUnsafeHeaders 返回 h 中需要预检的头部名称，小写并排序，与浏览器的
+// Access-Control-Request-Headers 相同
+func corsUnsafeHeaders(h Header) []string {
+	var names []string
+	for k := range h {
+		ck := CanonicalHeaderKey(k)
+		if strings.HasSuffix(k, ":") || corsBrowserHeaders[ck] ||
+			strings.HasPrefix(ck, "Sec-") || strings.HasPrefix(ck, "Proxy-") {
+			continue
+		}
+		if corsSafelistedHeaders[ck] && (ck != "Content-Type" || corsSafelistedContentType(h.Get(k))) {
+			continue
+		}
+		names = append(names, strings.ToLower(k))
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// corsSafelistedContentType 报告 Content-Type 为 v 的请求是否不需要预检
+func corsSafelistedContentType(v string) bool {
+	mt, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+		return true
+	}
+	return false
+}
+
+// corsHeaderList 返回预检响应头部 name 中逗号分隔的值，lower 为 true 时转为小写
+func corsHeaderList(h Header, name string, lower bool) []string {
+	var vs []string
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				if lower {
+					s = strings.ToLower(s)
+				}
+				vs = append(vs, s)
+			}
+		}
+	}
+	return vs
+}
+
+// serializeOrigin 返回 u 的源，如 https://example.com，默认端口省略
+func serializeOrigin(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	return u.Scheme + "://" + host
+}
+
+// defaultPorts 是各协议的默认端口
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+// secFetchSite 返回页面 doc 请求 u 时浏览器发送的 Sec-Fetch-Site 值
+func secFetchSite(doc, u *url.URL) string {
+	if serializeOrigin(doc) == serializeOrigin(u) {
+		return "same-origin"
+	}
+	if doc.Scheme == u.Scheme && registrableDomain(doc.Hostname()) == registrableDomain(u.Hostname()) {
+		return "same-site"
+	}
+	return "cross-site"
+}
+
+// registrableDomain 返回 host 的可注册域名，如 www.example.co.uk 返回 example.co.uk，
+// IP 地址和无法确定时返回 host 本身
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCORSPreflight 测试跨源请求按需发送预检请求并遵守预检结果
+func TestCORSPreflight(t *testing.T) {
+	var mu sync.Mutex
+	var got []*nethttp.Request
+	allowOrigin := "http://app.example.com"
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		got = append(got, r)
+		origin := allowOrigin
+		mu.Unlock()
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "X-Token, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "60")
+			w.WriteHeader(204)
+		}
+	}))
+	defer ts.Close()
+	tr := &Transport{CORSPreflight: true}
+	defer tr.CloseIdleConnections()
+
+	doc, _ := url.Parse("http://app.example.com/page")
+	do := func(method, mode string, header map[string]string) error {
+		t.Helper()
+		mu.Lock()
+		got = nil
+		mu.Unlock()
+		req, _ := NewRequestWithContext(WithDocument(context.Background(), doc), method, ts.URL+"/api", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		if mode != "" {
+			req.Header.Set("Sec-Fetch-Mode", mode)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	methods := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		var ms []string
+		for _, r := range got {
+			ms = append(ms, r.Method)
+		}
+		return strings.Join(ms, ",")
+	}
+
+	// 简单请求不预检
+	if err := do("GET", "", map[string]string{"Accept": "application/json"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := methods(); m != "GET" {
+		t.Errorf("简单请求 got %s, want GET", m)
+	}
+
+	// 自定义头部和方法需要预检
+	if err := do("PUT", "cors", map[string]string{"X-Token": "1", "Content-Type": "application/json"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := methods(); m != "OPTIONS,PUT" {
+		t.Fatalf("got %s, want OPTIONS,PUT", m)
+	}
+	p := got[0]
+	for k, want := range map[string]string{
+		"Origin":                         "http://app.example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "content-type,x-token",
+		"Sec-Fetch-Mode":                 "cors",
+		"Sec-Fetch-Site":                 "cross-site",
+		"Sec-Fetch-Dest":                 "empty",
+		"User-Agent":                     "test-agent",
+		"X-Token":                        "",
+	} {
+		if v := p.Header.Get(k); v != want {
+			t.Errorf("预检请求 %s got %q, want %q", k, v, want)
+		}
+	}
+
+	// 预检结果被缓存
+	if err := do("DELETE", "", map[string]string{"X-Token": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := methods(); m != "DELETE" {
+		t.Errorf("缓存的预检 got %s, want DELETE", m)
+	}
+
+	// 缓存不允许的头部重新预检，预检失败时不发出请求
+	err := do("PUT", "", map[string]string{"X-Other": "1"})
+	var ce *CORSError
+	if !errors.As(err, &ce) || !strings.Contains(ce.Reason, "x-other") {
+		t.Errorf("got %v, want 不允许 x-other 的 *CORSError", err)
+	}
+	if m := methods(); m != "OPTIONS" {
+		t.Errorf("预检失败 got %s, want OPTIONS", m)
+	}
+
+	// no-cors 的子资源不预检
+	if err := do("GET", "no-cors", map[string]string{"X-Other": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := methods(); m != "GET" {
+		t.Errorf("no-cors got %s, want GET", m)
+	}
+
+	// 源不符
+	mu.Lock()
+	allowOrigin = "http://other.example.com"
+	mu.Unlock()
+	tr.corsCache = corsPreflightCache{}
+	if err := do("PUT", "", nil); !errors.As(err, &ce) {
+		t.Errorf("got %v, want *CORSError", err)
+	}
+	if m := methods(); m != "OPTIONS" {
+		t.Errorf("源不符 got %s, want OPTIONS", m)
+	}
+
+	// 同源请求不预检
+	doc, _ = url.Parse(ts.URL + "/page")
+	if err := do("PUT", "", map[string]string{"X-Other": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := methods(); m != "PUT" {
+		t.Errorf("同源 got %s, want PUT", m)
+	}
+}
+
+func TestSecFetchSite(t *testing.T) {
+	for _, tt := range []struct{ doc, u, want string }{
+		{"https://a.example.com/", "https://a.example.com:443/x", "same-origin"},
+		{"https://a.example.com/", "https://b.example.com/", "same-site"},
+		{"https://www.example.co.uk/", "https://api.example.co.uk/", "same-site"},
+		{"https://a.example.com/", "http://a.example.com/", "cross-site"},
+		{"https://a.github.io/", "https://b.github.io/", "cross-site"},
+		{"https://example.com/", "https://example.org/", "cross-site"},
+		{"http://127.0.0.1:80/", "http://127.0.0.1:8080/", "same-site"},
+	} {
+		doc, _ := url.Parse(tt.doc)
+		u, _ := url.Parse(tt.u)
+		if got := secFetchSite(doc, u); got != tt.want {
+			t.Errorf("secFetchSite(%s, %s) got %s, want %s", tt.doc, tt.u, got, tt.want)
+		}
+	}
+	for in, want := range map[string]string{
+		"https://Example.com:443/a": "https://example.com",
+		"http://example.com:8080/":  "http://example.com:8080",
+		"http://[::1]:80/":          "http://[::1]",
+	} {
+		u, _ := url.Parse(in)
+		if got := serializeOrigin(u); got != want {
+			t.Errorf("serializeOrigin(%s) got %s, want %s", in, got, want)
+		}
+	}
+}
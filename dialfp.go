@@ -0,0 +1,48 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// DialFingerprinted 像发送 HTTPS 请求一样建立到 addr (host:port) 的 TLS 连接，
+// 返回已完成握手的连接，用于 MQTT over TLS 等非 HTTP 协议
+//
+// 连接使用 t 的全部身份配置：Proxy 和 NoProxy、EgressPolicy、拨号器、
+// TLS 指纹、ECH、会话缓存、JA4L 节奏和 OnClientHello 等，与请求
+// https://addr 时建立的连接完全相同。连接不经过连接池，不受 MaxConnsPerHost
+// 限制，也不会被复用，由调用方负责关闭。ALPN 由指纹决定，与之后使用的协议无关。
+// network 只支持 "tcp"。
+func (t *Transport) DialFingerprinted(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("DialFingerprinted 不支持网络类型 %q", network)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, err
+	}
+	req := (&Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Scheme: "https", Host: addr},
+		Host:   addr,
+		Header: make(Header),
+	}).WithContext(ctx)
+	treq := &transportRequest{Request: req, ctx: ctx}
+	cm, err := t.connectMethodForRequest(treq)
+	if err == nil && t.EgressPolicy != nil {
+		err = t.applyEgressPolicy(treq, &cm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pconn, err := t.dialAndHandshake(ctx, cm)
+	if err != nil {
+		return nil, err
+	}
+	return pconn.conn, nil
+}
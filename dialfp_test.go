@@ -0,0 +1,72 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	ctls "crypto/tls"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestDialFingerprinted 测试建立带指纹的 TLS 连接并在其上使用自定义协议
+func TestDialFingerprinted(t *testing.T) {
+	cert := httptest.NewTLSServer(nil)
+	certs := cert.TLS.Certificates
+	cert.Close()
+
+	ciphers := make(chan []uint16, 1)
+	ln, err := ctls.Listen("tcp", "127.0.0.1:0", &ctls.Config{
+		Certificates: certs,
+		GetConfigForClient: func(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+			ciphers <- hello.CipherSuites
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		line, _ := bufio.NewReader(c).ReadString('\n')
+		io.WriteString(c, "echo "+line)
+	}()
+
+	tr := &Transport{
+		JA3:             "771,4865-4866-49195-49199,0-10-11-13-43-51-65281,29-23,0",
+		UserAgent:       "Mozilla/5.0 Firefox/120.0",
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	conn, err := tr.DialFingerprinted(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tls.UConn); !ok {
+		t.Errorf("连接类型 got %T, want *tls.UConn", conn)
+	}
+	if want := []uint16{4865, 4866, 49195, 49199}; !slices.Equal(<-ciphers, want) {
+		t.Errorf("ClientHello 密码套件与指纹不一致, want %v", want)
+	}
+	io.WriteString(conn, "ping\n")
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || got != "echo ping\n" {
+		t.Errorf("got %q, %v, want %q", got, err, "echo ping\n")
+	}
+
+	if _, err := tr.DialFingerprinted(context.Background(), "udp", ln.Addr().String()); err == nil {
+		t.Error("udp 应返回错误")
+	}
+}
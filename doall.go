@@ -0,0 +1,103 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"iter"
+	"slices"
+)
+
+// DoResult 是 Client.DoAll 和 Client.DoStream 中一个请求的结果
+type DoResult struct {
+	Index    int // 请求在输入中的序号，从 0 开始
+	Request  *Request
+	Response *Response // Err 为 nil 时非 nil，调用方负责关闭 Body
+	Err      error
+}
+
+// DoAll 以最多 concurrency 个并发调用 c.Do 发送 reqs，按 reqs 的顺序返回结果
+//
+// 请求经 c.Do 发送，Transport 的 MaxConnsPerHost 等每目标限制、代理和指纹设置照常生效。
+// ctx 取消后不再发送新请求，未发送的请求的 Err 为 ctx.Err()；
+// 已发送的请求由各自的 context 控制。concurrency 小于 1 时按 1 处理。
+// 所有请求完成后才返回，需要边完成边处理时使用 DoStream。
+func (c *Client) DoAll(ctx context.Context, reqs []*Request, concurrency int) []DoResult {
+	results := make([]DoResult, 0, len(reqs))
+	for r := range c.DoStream(ctx, slices.Values(reqs), concurrency) {
+		results = append(results, r)
+	}
+	// DoStream 在 ctx 取消后停止读取 reqs
+	for i := len(results); i < len(reqs); i++ {
+		results = append(results, DoResult{Index: i, Request: reqs[i], Err: ctx.Err()})
+	}
+	return results
+}
+
+// DoStream 是 DoAll 的流式版本：边读取 reqs 边发送，按 reqs 的顺序逐个产生结果
+//
+// 最多 concurrency 个请求同时进行，已完成但还没有按顺序产生的结果的数量
+// 也受 concurrency 限制，因此 reqs 可以很长甚至是无限的。reqs 在另一个 goroutine 中读取。
+// ctx 取消后不再读取 reqs，已读取的请求的结果产生完后迭代结束。
+// 调用方提前结束迭代时立即返回，不再读取 reqs，已发送的请求的响应在后台关闭。
+func (c *Client) DoStream(ctx context.Context, reqs iter.Seq[*Request], concurrency int) iter.Seq[DoResult] {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return func(yield func(DoResult) bool) {
+		sem := make(chan struct{}, concurrency)
+		pending := make(chan chan DoResult, concurrency)
+		done := make(chan struct{})
+		go func() {
+			defer close(pending)
+			next, stop := iter.Pull(reqs)
+			defer stop()
+			for i := 0; ; i++ {
+				// 取得并发名额后才读取下一个请求
+				select {
+				case sem <- struct{}{}:
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				}
+				req, ok := next()
+				if !ok || ctx.Err() != nil {
+					<-sem
+					return
+				}
+				res := make(chan DoResult, 1)
+				select {
+				case pending <- res:
+				case <-done:
+					<-sem
+					return
+				}
+				go func(r DoResult) {
+					defer func() { <-sem }()
+					if r.Err = ctx.Err(); r.Err == nil {
+						r.Response, r.Err = c.Do(r.Request)
+					}
+					res <- r
+				}(DoResult{Index: i, Request: req})
+			}
+		}()
+
+		for res := range pending {
+			if r := <-res; !yield(r) {
+				close(done)
+				// 读取 reqs 可能阻塞，在后台等待已发送的请求并关闭响应
+				go func() {
+					for res := range pending {
+						if r := <-res; r.Response != nil {
+							r.Response.Body.Close()
+						}
+					}
+				}()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestClientDoAll 测试并发数受限且结果按请求顺序返回
+func TestClientDoAll(t *testing.T) {
+	var active, peak atomic.Int32
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// 序号小的请求更慢，完成顺序与请求顺序相反
+		i, _ := strconv.Atoi(r.URL.Query().Get("i"))
+		time.Sleep(time.Duration(10-i) * 2 * time.Millisecond)
+		io.WriteString(w, r.URL.Query().Get("i"))
+	}))
+	defer ts.Close()
+
+	var reqs []*Request
+	for i := 0; i < 10; i++ {
+		req, _ := NewRequest("GET", fmt.Sprintf("%s/?i=%d", ts.URL, i), nil)
+		reqs = append(reqs, req)
+	}
+	c := &Client{Transport: &Transport{}}
+	defer c.CloseIdleConnections()
+	results := c.DoAll(context.Background(), reqs, 3)
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d 个结果, want %d", len(results), len(reqs))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("#%d: %v", i, r.Err)
+		}
+		b, _ := io.ReadAll(r.Response.Body)
+		r.Response.Body.Close()
+		if r.Index != i || r.Request != reqs[i] || string(b) != strconv.Itoa(i) {
+			t.Errorf("#%d: got Index %d, body %q", i, r.Index, b)
+		}
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("最大并发 got %d, want <= 3", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range c.DoAll(ctx, reqs[:2], 2) {
+		if r.Err != context.Canceled {
+			t.Errorf("ctx 取消后 #%d: got %v, want %v", r.Index, r.Err, context.Canceled)
+		}
+	}
+}
+
+// TestClientDoStream 测试流式读取请求，提前结束迭代后不再读取请求
+func TestClientDoStream(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, r.URL.Query().Get("i"))
+	}))
+	defer ts.Close()
+
+	var produced atomic.Int32
+	reqs := func(yield func(*Request) bool) {
+		for i := 0; ; i++ {
+			produced.Add(1)
+			req, _ := NewRequest("GET", fmt.Sprintf("%s/?i=%d", ts.URL, i), nil)
+			if !yield(req) {
+				return
+			}
+		}
+	}
+	c := &Client{Transport: &Transport{}}
+	defer c.CloseIdleConnections()
+	n := 0
+	for r := range c.DoStream(context.Background(), reqs, 2) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		b, _ := io.ReadAll(r.Response.Body)
+		r.Response.Body.Close()
+		if string(b) != strconv.Itoa(n) {
+			t.Errorf("#%d: got %q", n, b)
+		}
+		if n++; n == 5 {
+			break
+		}
+	}
+	// 读取请求的 goroutine 超前的数量受并发数限制，迭代结束后停止读取
+	time.Sleep(10 * time.Millisecond)
+	if p := produced.Load(); p > 5+2*2+1 {
+		t.Errorf("提前结束后仍在读取请求: 共 %d 个", p)
+	}
+}
+
+// TestClientDoStreamStop 测试 ctx 取消后停止读取无限的请求，以及读取请求阻塞时
+// 提前结束迭代不会阻塞调用方
+func TestClientDoStreamStop(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	c := &Client{Transport: &Transport{}}
+	defer c.CloseIdleConnections()
+
+	t.Run("ctx 取消", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		reqs := func(yield func(*Request) bool) {
+			for {
+				req, _ := NewRequest("GET", ts.URL, nil)
+				if !yield(req) {
+					return
+				}
+			}
+		}
+		finished := make(chan int)
+		go func() {
+			n := 0
+			for r := range c.DoStream(ctx, reqs, 2) {
+				if r.Response != nil {
+					r.Response.Body.Close()
+				}
+				if n++; n == 3 {
+					cancel()
+				}
+			}
+			finished <- n
+		}()
+		select {
+		case n := <-finished:
+			// 已读取的请求至多为并发数加上等待产生的结果数
+			if n > 3+2*2 {
+				t.Errorf("ctx 取消后仍产生了 %d 个结果", n-3)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("ctx 取消后迭代没有结束")
+		}
+	})
+
+	t.Run("提前结束", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		reqs := func(yield func(*Request) bool) {
+			req, _ := NewRequest("GET", ts.URL, nil)
+			if !yield(req) {
+				return
+			}
+			<-block
+		}
+		finished := make(chan struct{})
+		go func() {
+			for r := range c.DoStream(context.Background(), reqs, 2) {
+				if r.Response != nil {
+					r.Response.Body.Close()
+				}
+				break
+			}
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatal("读取请求阻塞时提前结束迭代阻塞了调用方")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"net/url"
+)
+
+// documentKey 是 WithDocument 的 context 键
+type documentKey struct{}
+
+// document 是 WithDocument 或 WithSandboxedDocument 设置的页面
+type document struct {
+	url       *url.URL
+	sandboxed bool // 没有 allow-same-origin 的沙箱 iframe，源是不透明的
+}
+
+// WithDocument 返回带有页面 URL doc 的 ctx 副本，表示请求是 doc 页面发起的
+// 子资源请求 (脚本、样式、图片、fetch、WebSocket 等)，用于模拟浏览器加载页面
+//
+// Transport 据此像浏览器一样处理混合内容、CORS 预检和 Origin、Sec-Fetch-* 头部，
+// 见 Transport.MixedContent、Transport.CORSPreflight 和 Transport.FetchMetadata。
+// doc 为 nil 时等同于没有页面。
+func WithDocument(ctx context.Context, doc *url.URL) context.Context {
+	return context.WithValue(ctx, documentKey{}, document{url: doc})
+}
+
+// WithSandboxedDocument 与 WithDocument 相同，但 doc 在没有 allow-same-origin 的
+// 沙箱 iframe 中，其源是不透明的，浏览器发送的 Origin 为 "null"
+func WithSandboxedDocument(ctx context.Context, doc *url.URL) context.Context {
+	return context.WithValue(ctx, documentKey{}, document{url: doc, sandboxed: true})
+}
+
+// DocumentFromContext 返回 WithDocument 在 ctx 中设置的页面 URL
+func DocumentFromContext(ctx context.Context) (*url.URL, bool) {
+	d, ok := documentFromContext(ctx)
+	return d.url, ok
+}
+
+func documentFromContext(ctx context.Context) (document, bool) {
+	d, ok := ctx.Value(documentKey{}).(document)
+	return d, ok && d.url != nil
+}
+
+// origin 返回页面的源，不透明的源 (沙箱、data:、file: 等页面) 为 "null"
+func (d document) origin() string {
+	if d.sandboxed {
+		return "null"
+	}
+	u := d.url
+	if u.Scheme == "blob" {
+		// blob: URL 的源是其内部 URL 的源
+		inner, err := url.Parse(u.Opaque)
+		if err != nil || inner.Scheme == "blob" {
+			return "null"
+		}
+		u = inner
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss", "ftp":
+		return serializeOrigin(u)
+	}
+	return "null"
+}
+
+// site 返回页面请求 u 时浏览器发送的 Sec-Fetch-Site 值，不透明的源总是 cross-site
+func (d document) site(u *url.URL) string {
+	origin := d.origin()
+	if origin == "null" {
+		return "cross-site"
+	}
+	o, _ := url.Parse(origin)
+	return secFetchSite(o, u)
+}
@@ -0,0 +1,374 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	dnsTypeHTTPS   dnsmessage.Type = 65 // RFC 9460
+	svcParamECH                    = 5  // SvcParamKey ech
+	echNegativeTTL                 = 5 * time.Minute
+	echErrorTTL                    = 30 * time.Second
+	echMaxTTL                      = 24 * time.Hour
+)
+
+// ECHResolver 通过 DNS HTTPS 记录 (RFC 9460) 获取服务端的 ECHConfigList，
+// 用于加密 ClientHello (ECH)
+//
+// 设置为 Transport.ECH 后，指纹中包含 encrypted_client_hello 扩展 (如 Chrome、
+// Firefox 的预设) 的 HTTPS 连接会先查询目标的 HTTPS 记录，有 ECH 配置时发送
+// 真正的 ECH，否则保持 GREASE ECH。服务端拒绝 ECH 并返回新配置时，
+// 新配置替换缓存并重试一次。查询结果按 TTL 缓存，没有 ECH 配置的结果缓存 5 分钟，
+// 查询失败缓存 30 秒，期间不再查询。零值可以直接使用，并发安全。
+type ECHResolver struct {
+	// Server 是 DNS 服务器地址 host:port，为空时使用 /etc/resolv.conf
+	// 中的第一个 nameserver
+	Server string
+
+	// Timeout 是单次查询的超时时间，零表示 5 秒
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	cache map[string]echCacheEntry // 键为查询的域名
+}
+
+type echCacheEntry struct {
+	configList []byte // 为空表示没有 ECH 配置
+	err        error  // 查询失败时的错误
+	expires    time.Time
+}
+
+// Lookup 返回 host:port 的 ECHConfigList，没有 ECH 配置时返回 nil
+func (r *ECHResolver) Lookup(ctx context.Context, addr string) ([]byte, error) {
+	name, err := echQueryName(addr)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	e, ok := r.cache[name]
+	r.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.configList, e.err
+	}
+
+	configList, ttl, err := r.query(ctx, name)
+	if err != nil {
+		// 调用方取消的查询不代表 DNS 服务器的状态，不缓存
+		if ctx.Err() == nil {
+			r.store(name, echCacheEntry{err: err}, echErrorTTL)
+		}
+		return nil, err
+	}
+	if configList == nil {
+		ttl = echNegativeTTL
+	}
+	r.store(name, echCacheEntry{configList: configList}, min(ttl, echMaxTTL))
+	return configList, nil
+}
+
+// setRetryConfigs 用服务端拒绝 ECH 时返回的配置替换 addr 的缓存
+func (r *ECHResolver) setRetryConfigs(addr string, configList []byte) {
+	if name, err := echQueryName(addr); err == nil {
+		r.store(name, echCacheEntry{configList: configList}, echNegativeTTL)
+	}
+}
+
+// store 缓存 name 的查询结果 e，ttl 后过期
+func (r *ECHResolver) store(name string, e echCacheEntry, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = make(map[string]echCacheEntry)
+	}
+	now := time.Now()
+	for k, old := range r.cache {
+		if now.After(old.expires) {
+			delete(r.cache, k)
+		}
+	}
+	e.expires = now.Add(ttl)
+	r.cache[name] = e
+}
+
+// echQueryName 返回 host:port 对应的 HTTPS 记录查询名，非 443 端口使用
+// _port._https.host 形式
+func echQueryName(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return "", fmt.Errorf("IP 地址 %s 没有 HTTPS 记录", host)
+	}
+	name := strings.TrimSuffix(host, ".") + "."
+	if port != "443" {
+		name = "_" + port + "._https." + name
+	}
+	return name, nil
+}
+
+// query 查询 name 的 HTTPS 记录，返回第一个带 ech 参数的 ServiceMode 记录的配置和 TTL
+func (r *ECHResolver) query(ctx context.Context, name string) ([]byte, time.Duration, error) {
+	server := r.Server
+	if server == "" {
+		var err error
+		if server, err = systemNameserver(); err != nil {
+			return nil, 0, err
+		}
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	var idb [2]byte
+	rand.Read(idb[:])
+	id := binary.BigEndian.Uint16(idb[:])
+	b := dnsmessage.NewBuilder(make([]byte, 2, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: qname, Type: dnsTypeHTTPS, Class: dnsmessage.ClassINET})
+	b.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false)
+	b.OPTResource(opt, dnsmessage.OPTResource{})
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	msg = msg[2:]
+
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		configList, ttl, err := parseHTTPSResponse(buf[:n], id)
+		if err == errDNSWrongID {
+			continue
+		}
+		return configList, ttl, err
+	}
+}
+
+var errDNSWrongID = errors.New("DNS 响应 ID 不匹配")
+
+// parseHTTPSResponse 解析 HTTPS 记录查询的响应
+func parseHTTPSResponse(msg []byte, id uint16) ([]byte, time.Duration, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if h.ID != id || !h.Response {
+		return nil, 0, errDNSWrongID
+	}
+	if h.Truncated {
+		return nil, 0, errors.New("DNS 响应被截断")
+	}
+	if h.RCode != dnsmessage.RCodeSuccess && h.RCode != dnsmessage.RCodeNameError {
+		return nil, 0, fmt.Errorf("DNS 查询失败: %v", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, err
+	}
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return nil, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if rh.Type != dnsTypeHTTPS {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		res, err := p.UnknownResource()
+		if err != nil {
+			return nil, 0, err
+		}
+		if ech := svcbECH(res.Data); ech != nil {
+			return ech, time.Duration(rh.TTL) * time.Second, nil
+		}
+	}
+}
+
+// svcbECH 返回 SVCB/HTTPS 记录数据中的 ech 参数，AliasMode 记录和没有 ech 参数时返回 nil
+func svcbECH(data []byte) []byte {
+	if len(data) < 2 || binary.BigEndian.Uint16(data) == 0 {
+		return nil
+	}
+	data = data[2:]
+	// TargetName 不压缩 (RFC 9460 第 2.2 节)
+	for {
+		if len(data) == 0 {
+			return nil
+		}
+		n := int(data[0])
+		if n > 63 || len(data) < 1+n {
+			return nil
+		}
+		data = data[1+n:]
+		if n == 0 {
+			break
+		}
+	}
+	for len(data) >= 4 {
+		key := binary.BigEndian.Uint16(data)
+		n := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < 4+n {
+			return nil
+		}
+		if key == svcParamECH {
+			return data[4 : 4+n]
+		}
+		data = data[4+n:]
+	}
+	return nil
+}
+
+// systemNameserver 返回 /etc/resolv.conf 中的第一个 nameserver
+func systemNameserver() (string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", errors.New("/etc/resolv.conf 中没有 nameserver")
+}
+
+// specUsesECH 报告 ClientHello 中是否有 encrypted_client_hello 扩展
+func specUsesECH(spec *tls.ClientHelloSpec) bool {
+	for _, ext := range spec.Extensions {
+		if _, ok := ext.(tls.EncryptedClientHelloExtension); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// echPublicName 返回 ECHConfigList 中第一个配置的 public_name
+func echPublicName(configList []byte) string {
+	s := cryptobyte.String(configList)
+	var configs cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&configs) {
+		return ""
+	}
+	for !configs.Empty() {
+		var version uint16
+		var contents cryptobyte.String
+		if !configs.ReadUint16(&version) || !configs.ReadUint16LengthPrefixed(&contents) {
+			return ""
+		}
+		if version != 0xfe0d {
+			continue
+		}
+		var kem uint16
+		var id, maxNameLen uint8
+		var publicKey, suites, publicName cryptobyte.String
+		if !contents.ReadUint8(&id) || !contents.ReadUint16(&kem) ||
+			!contents.ReadUint16LengthPrefixed(&publicKey) ||
+			!contents.ReadUint16LengthPrefixed(&suites) ||
+			!contents.ReadUint8(&maxNameLen) ||
+			!contents.ReadUint8LengthPrefixed(&publicName) {
+			return ""
+		}
+		return string(publicName)
+	}
+	return ""
+}
+
+// echRejectionVerify 返回服务端拒绝 ECH 时校验证书的函数
+//
+// 此时服务端用 ECH 配置中 public_name 的证书完成握手，
+// utls 默认按 ServerName 校验且不理会 InsecureSkipVerify，这里改为按 public_name
+// 校验，InsecureSkipVerify 时跳过。
+func echRejectionVerify(cfg *tls.Config, publicName string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if cfg.InsecureSkipVerify {
+			return nil
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("tls: 服务端拒绝 ECH 且没有提供证书")
+		}
+		opts := x509.VerifyOptions{
+			Roots:         cfg.RootCAs,
+			DNSName:       publicName,
+			Intermediates: x509.NewCertPool(),
+		}
+		if cfg.Time != nil {
+			opts.CurrentTime = cfg.Time()
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+}
+
+// echConfigList 返回本次连接使用的 ECHConfigList，查询失败时不使用 ECH，
+// 与浏览器的行为一致
+func (t *Transport) echConfigList(ctx context.Context, addr string) []byte {
+	if t.ECH == nil {
+		return nil
+	}
+	configList, err := t.ECH.Lookup(ctx, addr)
+	if err != nil {
+		return nil
+	}
+	return configList
+}
+
+// echRetryable 报告 err 是否为带有新配置的 ECH 拒绝，是时新配置已写入 t.ECH
+func (t *Transport) echRetryable(err error) bool {
+	var rej *tls.ECHRejectionError
+	return t.ECH != nil && errors.As(err, &rej) && len(rej.RetryConfigList) > 0
+}
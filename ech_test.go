@@ -0,0 +1,225 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	stdtls "crypto/tls"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newECHKey 生成一个 X25519 的 ECHConfig 和对应的服务端密钥
+func newECHKey(t *testing.T, id uint8, publicName string) stdtls.EncryptedClientHelloKey {
+	t.Helper()
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b cryptobyte.Builder
+	b.AddUint16(0xfe0d)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(key.PublicKey().Bytes()) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(0x0001) // HKDF-SHA256
+			b.AddUint16(0x0001) // AES-128-GCM
+		})
+		b.AddUint8(32)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(publicName)) })
+		b.AddUint16(0)
+	})
+	return stdtls.EncryptedClientHelloKey{Config: b.BytesOrPanic(), PrivateKey: key.Bytes(), SendAsRetry: true}
+}
+
+func echConfigListOf(configs ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range configs {
+			b.AddBytes(c)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+// httpsRecord 返回带 ech 参数的 HTTPS 记录数据
+func httpsRecord(ech []byte) []byte {
+	data := []byte{0, 1, 0}                                // SvcPriority 1，TargetName "."
+	data = append(data, 0, 1, 0, 3, 2, 'h', '2')           // alpn=h2
+	data = append(data, 0, svcParamECH, 0, byte(len(ech))) // ech
+	return append(data, ech...)
+}
+
+// startDNSServer 启动一个对所有 HTTPS 查询返回 record 的 UDP DNS 服务，
+// record 为 nil 时返回 SERVFAIL
+func startDNSServer(t *testing.T, record []byte, queries *atomic.Int32) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			queries.Add(1)
+			rh := dnsmessage.Header{ID: h.ID, Response: true}
+			if record == nil {
+				rh.RCode = dnsmessage.RCodeServerFailure
+			}
+			b := dnsmessage.NewBuilder(nil, rh)
+			b.StartQuestions()
+			b.Question(q)
+			b.StartAnswers()
+			if record != nil {
+				b.UnknownResource(dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 300},
+					dnsmessage.UnknownResource{Type: q.Type, Data: record})
+			}
+			msg, _ := b.Finish()
+			pc.WriteTo(msg, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// TestSVCBECH 测试从 HTTPS 记录中取出 ech 参数
+func TestSVCBECH(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"ServiceMode", httpsRecord([]byte{1, 2, 3}), []byte{1, 2, 3}},
+		{"AliasMode", []byte{0, 0, 3, 'f', 'o', 'o', 0}, nil},
+		{"没有 ech", []byte{0, 1, 0, 0, 1, 0, 3, 2, 'h', '2'}, nil},
+		{"截断", httpsRecord([]byte{1, 2, 3})[:12], nil},
+	}
+	for _, tt := range tests {
+		if got := svcbECH(tt.data); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestECHPublicName 测试从 ECHConfigList 中取出 public_name
+func TestECHPublicName(t *testing.T) {
+	key := newECHKey(t, 1, "public.example.com")
+	if got := echPublicName(echConfigListOf(key.Config)); got != "public.example.com" {
+		t.Errorf("got %v, want public.example.com", got)
+	}
+	if got := echPublicName([]byte{0, 3, 1}); got != "" {
+		t.Errorf("格式错误时 got %v, want 空", got)
+	}
+}
+
+// TestECHResolverLookup 测试 HTTPS 记录的查询和缓存
+func TestECHResolverLookup(t *testing.T) {
+	var queries atomic.Int32
+	r := &ECHResolver{Server: startDNSServer(t, httpsRecord([]byte{9, 9}), &queries)}
+	for range 2 {
+		got, err := r.Lookup(context.Background(), "example.test:443")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, []byte{9, 9}) {
+			t.Errorf("got %v, want [9 9]", got)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("查询次数 got %v, want 1", n)
+	}
+	if _, err := r.Lookup(context.Background(), "127.0.0.1:443"); err == nil {
+		t.Error("IP 地址应返回错误")
+	}
+
+	// 查询失败同样缓存，短时间内不再查询
+	queries.Store(0)
+	r = &ECHResolver{Server: startDNSServer(t, nil, &queries)}
+	for range 2 {
+		if _, err := r.Lookup(context.Background(), "example.test:443"); err == nil {
+			t.Fatal("SERVFAIL 应返回错误")
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("查询失败后的查询次数 got %v, want 1", n)
+	}
+}
+
+// TestTransportECH 测试按 HTTPS 记录发送 ECH 以及按服务端返回的配置重试
+func TestTransportECH(t *testing.T) {
+	key := newECHKey(t, 1, "example.com")
+	stale := newECHKey(t, 2, "example.com")
+	srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, strconv.FormatBool(r.TLS.ECHAccepted))
+	}))
+	srv.TLS = &stdtls.Config{EncryptedClientHelloKeys: []stdtls.EncryptedClientHelloKey{key}}
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	tests := []struct {
+		name   string
+		config []byte
+		ja3    string
+		want   string
+	}{
+		{"ECH", key.Config, "771,4865-4866-4867,0-10-11-13-16-23-43-45-51-65281-65037,29-23-24,0", "true"},
+		{"按重试配置重连", stale.Config, "771,4865-4866-4867,0-10-11-13-16-23-43-45-51-65281-65037,29-23-24,0", "true"},
+		{"指纹不含 ECH", key.Config, "771,4865-4866-4867,0-10-11-13-16-23-43-45-51-65281,29-23-24,0", "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries atomic.Int32
+			tr := &Transport{
+				JA3:             tt.ja3,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				ECH:             &ECHResolver{Server: startDNSServer(t, httpsRecord(echConfigListOf(tt.config)), &queries)},
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return net.Dial(network, srv.Listener.Addr().String())
+				},
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get("https://example.test:" + port)
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if got := strings.TrimSpace(string(b)); got != tt.want {
+				t.Errorf("服务端 ECHAccepted got %v, want %v", got, tt.want)
+			}
+			if got := strconv.FormatBool(resp.Meta.ECHAccepted); got != tt.want {
+				t.Errorf("Meta.ECHAccepted got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
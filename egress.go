@@ -0,0 +1,191 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// An EgressPhase identifies when a Transport consults its EgressPolicy.
+type EgressPhase int
+
+const (
+	// EgressBeforeRoundTrip is the check made for every request
+	// (including retries) before a connection is obtained for it.
+	EgressBeforeRoundTrip EgressPhase = iota
+
+	// EgressAfterDial is the check made when a new connection has
+	// been established but before any bytes have been written to it,
+	// when the remote IP address is known. With DialTLS or
+	// DialTLSContext, which write the TLS handshake themselves, it is
+	// made just before calling them instead, and EgressInfo.IP is only
+	// set if the dialed address is an IP literal.
+	EgressAfterDial
+)
+
+func (p EgressPhase) String() string {
+	switch p {
+	case EgressBeforeRoundTrip:
+		return "before-roundtrip"
+	case EgressAfterDial:
+		return "after-dial"
+	}
+	return fmt.Sprintf("EgressPhase(%d)", int(p))
+}
+
+// EgressInfo describes outgoing traffic for an EgressPolicy.
+type EgressInfo struct {
+	Phase EgressPhase
+
+	// Request is the request being sent. It is nil in the
+	// EgressAfterDial phase, since a dialed connection may end up
+	// serving a different request than the one that caused the dial.
+	Request *Request
+
+	// Host and Port are the target of the request.
+	Host string
+	Port string
+
+	// IP is the IP address traffic goes to. In EgressBeforeRoundTrip
+	// it is only set if Host is an IP literal. In EgressAfterDial it
+	// is the remote address of the new connection, which is the
+	// proxy's address when a proxy is used, except with DialTLS (see
+	// EgressAfterDial).
+	IP net.IP
+
+	// Proxy is the proxy the traffic goes through, or nil for a
+	// direct connection.
+	Proxy *url.URL
+
+	// Fingerprint is the configured TLS fingerprint: the JA3 string,
+	// the name of ClientHelloID (such as "Chrome-120"), "custom" for
+	// ClientHelloSpec, or the preset name of TLSFingerprint. It is empty
+	// for plain HTTP and for the default TLS ClientHello.
+	Fingerprint string
+}
+
+// An EgressAction is the outcome of an EgressPolicy.
+type EgressAction int
+
+const (
+	EgressAllow    EgressAction = iota // proceed unchanged
+	EgressDeny                         // fail with *EgressDeniedError
+	EgressRedirect                     // proceed via EgressDecision.Proxy or Addr
+)
+
+// EgressDecision is returned by an EgressPolicy.
+type EgressDecision struct {
+	Action EgressAction
+
+	// Reason is reported in the *EgressDeniedError for EgressDeny.
+	Reason string
+
+	// For EgressRedirect, Proxy, if non-nil, replaces the proxy
+	// chosen by Transport.Proxy, and Addr, if non-empty, is a
+	// "host:port" to dial instead of the target for direct
+	// connections. The request's Host header and TLS server name
+	// are unchanged. Redirects are only honored in the
+	// EgressBeforeRoundTrip phase.
+	Proxy *url.URL
+	Addr  string
+}
+
+// EgressDeniedError is returned by Transport.RoundTrip when the
+// Transport's EgressPolicy denies a request.
+type EgressDeniedError struct {
+	Phase  EgressPhase
+	Host   string
+	IP     net.IP // nil if unknown
+	Reason string
+}
+
+func (e *EgressDeniedError) Error() string {
+	target := e.Host
+	if e.IP != nil {
+		target += " (" + e.IP.String() + ")"
+	}
+	msg := "net/http: egress to " + target + " denied by policy"
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// configuredFingerprint returns the TLS fingerprint reported in
+// EgressInfo.Fingerprint for requests using scheme.
+func (t *Transport) configuredFingerprint(scheme string) string {
+	if scheme != "https" {
+		return ""
+	}
+	if t.ClientHelloSpec != nil {
+		return "custom"
+	}
+	if t.ClientHelloID.Client != "" {
+		return t.ClientHelloID.Str()
+	}
+	if t.JA3 != "" {
+		return t.JA3
+	}
+	if f := t.TLSFingerprint; f != nil {
+		if f.JA3 != "" {
+			return f.JA3
+		}
+		return f.PresetFingerprint
+	}
+	return ""
+}
+
+// egressInfo returns the EgressInfo for traffic described by cm.
+func (t *Transport) egressInfo(phase EgressPhase, req *Request, cm *connectMethod) *EgressInfo {
+	host, port, _ := net.SplitHostPort(cm.targetAddr)
+	info := &EgressInfo{
+		Phase:       phase,
+		Request:     req,
+		Host:        host,
+		Port:        port,
+		IP:          net.ParseIP(host),
+		Proxy:       cm.proxyURL,
+		Fingerprint: t.configuredFingerprint(cm.targetScheme),
+	}
+	if cm.fingerprint != nil && cm.targetScheme == "https" {
+		info.Fingerprint = cm.fingerprint.JA3
+	}
+	return info
+}
+
+// applyEgressPolicy runs t.EgressPolicy for treq, updating cm for
+// redirects.
+func (t *Transport) applyEgressPolicy(treq *transportRequest, cm *connectMethod) error {
+	info := t.egressInfo(EgressBeforeRoundTrip, treq.Request, cm)
+	d := t.EgressPolicy(info)
+	switch d.Action {
+	case EgressDeny:
+		return &EgressDeniedError{Phase: info.Phase, Host: info.Host, IP: info.IP, Reason: d.Reason}
+	case EgressRedirect:
+		if d.Proxy != nil {
+			cm.proxyURL = d.Proxy
+		}
+		if d.Addr != "" {
+			if _, _, err := net.SplitHostPort(d.Addr); err != nil {
+				return fmt.Errorf("net/http: invalid egress redirect address %q: %w", d.Addr, err)
+			}
+			cm.dialAddr = d.Addr
+		}
+	}
+	return nil
+}
+
+// checkDialedEgress runs t.EgressPolicy for a conn to ip, which may
+// be nil if unknown.
+func (t *Transport) checkDialedEgress(cm *connectMethod, ip net.IP) error {
+	info := t.egressInfo(EgressAfterDial, nil, cm)
+	info.IP = ip
+	if d := t.EgressPolicy(info); d.Action == EgressDeny {
+		return &EgressDeniedError{Phase: info.Phase, Host: info.Host, IP: info.IP, Reason: d.Reason}
+	}
+	return nil
+}
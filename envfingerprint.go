@@ -0,0 +1,98 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// 配置 DefaultTransport 指纹的环境变量，在包初始化时读取一次。
+// 把 import "net/http" 换成本包的程序无需修改调用处就能使用浏览器指纹
+//
+//	TLSHTTP_PRESET=chrome133 ./app
+//	TLSHTTP_JA3=771,4865-4866-...,0-10-11-...,29-23-24,0 TLSHTTP_USER_AGENT="Mozilla/5.0 ..." ./app
+//
+// 同时设置 TLSHTTP_PRESET 和 TLSHTTP_JA3 时以 TLSHTTP_JA3 为准。
+// 变量无效时记录日志，DefaultTransport 保持不变
+const (
+	EnvPreset    = "TLSHTTP_PRESET"     // envPresets 中的预设名称，见 Transport.ClientHelloID
+	EnvJA3       = "TLSHTTP_JA3"        // JA3 字符串，见 Transport.JA3
+	EnvUserAgent = "TLSHTTP_USER_AGENT" // 覆盖预设的 User-Agent，见 Transport.UserAgent
+)
+
+// envPreset 是 TLSHTTP_PRESET 可以使用的一个预设
+type envPreset struct {
+	id        tls.ClientHelloID
+	userAgent string
+}
+
+// envPresets 是 TLSHTTP_PRESET 的预设，名称与 presets 包相同的预设是同一个浏览器版本，
+// 不带版本号的名称使用 utls 的最新版本
+var envPresets = map[string]envPreset{
+	"chrome":     {tls.HelloChrome_Auto, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"},
+	"chrome120":  {tls.HelloChrome_120, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+	"chrome131":  {tls.HelloChrome_131, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"},
+	"chrome133":  {tls.HelloChrome_133, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"},
+	"firefox":    {tls.HelloFirefox_Auto, "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"},
+	"firefox120": {tls.HelloFirefox_120, "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"},
+	"safari":     {tls.HelloSafari_Auto, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15"},
+	"ios":        {tls.HelloIOS_Auto, "Mozilla/5.0 (iPhone; CPU iPhone OS 14_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1"},
+	"edge":       {tls.HelloEdge_Auto, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/85.0.4183.102 Safari/537.36 Edg/85.0.564.51"},
+	"randomized": {tls.HelloRandomized, ""},
+}
+
+func init() {
+	if t, ok := DefaultTransport.(*Transport); ok {
+		if err := t.applyEnvFingerprint(os.Getenv); err != nil {
+			log.Printf("http: %v", err)
+		}
+	}
+}
+
+// applyEnvFingerprint 按 getenv 返回的 TLSHTTP_* 变量设置 t 的指纹，
+// 没有设置这些变量时 t 不变
+func (t *Transport) applyEnvFingerprint(getenv func(string) string) error {
+	name := strings.ToLower(strings.TrimSpace(getenv(EnvPreset)))
+	ja3 := strings.TrimSpace(getenv(EnvJA3))
+	ua := getenv(EnvUserAgent)
+
+	var preset envPreset
+	if name != "" && ja3 == "" {
+		var ok bool
+		if preset, ok = envPresets[name]; !ok {
+			names := make([]string, 0, len(envPresets))
+			for n := range envPresets {
+				names = append(names, n)
+			}
+			slices.Sort(names)
+			return fmt.Errorf("%s: 未知的预设 %q，可用的预设: %s", EnvPreset, name, strings.Join(names, ", "))
+		}
+	}
+	if ja3 != "" {
+		for _, is := range ValidateJA3(ja3) {
+			if is.Severity == IssueError {
+				return fmt.Errorf("%s: %v", EnvJA3, is)
+			}
+		}
+	}
+
+	switch {
+	case ja3 != "":
+		t.JA3 = ja3
+	case name != "":
+		t.ClientHelloID = preset.id
+		t.UserAgent = preset.userAgent
+	}
+	if ua != "" {
+		t.UserAgent = ua
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+const envTestJA3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+
+func TestApplyEnvFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantID  tls.ClientHelloID
+		wantJA3 string
+		wantUA  string
+		wantErr string
+	}{
+		{name: "未设置"},
+		{
+			name:   "预设",
+			env:    map[string]string{EnvPreset: " Chrome120 "},
+			wantID: tls.HelloChrome_120, wantUA: envPresets["chrome120"].userAgent,
+		},
+		{
+			name:   "预设和 User-Agent",
+			env:    map[string]string{EnvPreset: "firefox", EnvUserAgent: "custom"},
+			wantID: tls.HelloFirefox_Auto, wantUA: "custom",
+		},
+		{
+			name:    "JA3 优先于预设",
+			env:     map[string]string{EnvPreset: "chrome", EnvJA3: envTestJA3, EnvUserAgent: "ua"},
+			wantJA3: envTestJA3, wantUA: "ua",
+		},
+		{name: "未知预设", env: map[string]string{EnvPreset: "netscape"}, wantErr: "未知的预设"},
+		{name: "无效 JA3", env: map[string]string{EnvJA3: "771,4865"}, wantErr: EnvJA3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{}
+			err := tr.applyEnvFingerprint(func(k string) string { return tt.env[k] })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err got %v, want 包含 %q", err, tt.wantErr)
+				}
+				if tr.JA3 != "" || tr.ClientHelloID.Client != "" {
+					t.Error("出错时修改了 Transport")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.ClientHelloID != tt.wantID || tr.JA3 != tt.wantJA3 || tr.UserAgent != tt.wantUA {
+				t.Errorf("got %v, %q, %q, want %v, %q, %q", tr.ClientHelloID.Str(), tr.JA3, tr.UserAgent, tt.wantID.Str(), tt.wantJA3, tt.wantUA)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"github.com/vanling1111/tlshttp/internal/ascii"
+)
+
+// fetchDestModes 是 Sec-Fetch-Dest 对应的默认 Sec-Fetch-Mode，不在表中的为 no-cors
+var fetchDestModes = map[string]string{
+	"document":  "navigate",
+	"iframe":    "navigate",
+	"frame":     "navigate",
+	"empty":     "cors",
+	"websocket": "websocket",
+}
+
+// applyFetchMetadata 在 t.FetchMetadata 为 true 时，按浏览器的规则为 WithDocument
+// 页面发起的请求补全 Origin 和 Sec-Fetch-Site、Sec-Fetch-Mode、Sec-Fetch-Dest 头部，
+// 返回带有新头部的请求副本。已经设置的头部保持不变
+//
+// 请求未设置 Sec-Fetch-Dest 时按 fetch 处理，WebSocket 升级请求按 websocket 处理。
+// Origin 在跨源的 fetch、WebSocket 和 GET、HEAD 以外的请求中发送，
+// 不透明的源为 "null"。与 Chrome 一样，Sec-Fetch-* 只发往 https:// 和本地地址
+func (t *Transport) applyFetchMetadata(req *Request) *Request {
+	if !t.FetchMetadata {
+		return req
+	}
+	doc, ok := documentFromContext(req.Context())
+	if !ok {
+		return req
+	}
+	dest := req.Header.Get("Sec-Fetch-Dest")
+	if dest == "" {
+		dest = "empty"
+		if ascii.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+			dest = "websocket"
+		}
+	}
+	mode := req.Header.Get("Sec-Fetch-Mode")
+	if mode == "" {
+		mode = fetchDestModes[dest]
+		if mode == "" {
+			mode = "no-cors"
+		}
+	}
+	site := doc.site(req.URL)
+
+	h := req.Header.Clone()
+	if h == nil {
+		h = make(Header)
+	}
+	if h.Get("Origin") == "" {
+		simple := req.Method == "" || req.Method == "GET" || req.Method == "HEAD"
+		switch {
+		case mode == "websocket", !simple:
+			h.Set("Origin", doc.origin())
+		case mode == "cors" && site != "same-origin":
+			h.Set("Origin", doc.origin())
+		}
+	}
+	if req.URL.Scheme == "https" || isTrustworthyHost(req.URL.Hostname()) {
+		for k, v := range map[string]string{"Sec-Fetch-Site": site, "Sec-Fetch-Mode": mode, "Sec-Fetch-Dest": dest} {
+			if h.Get(k) == "" {
+				h.Set(k, v)
+			}
+		}
+	}
+	r2 := *req
+	r2.Header = h
+	return &r2
+}
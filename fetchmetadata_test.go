@@ -0,0 +1,146 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+func TestApplyFetchMetadata(t *testing.T) {
+	page, _ := url.Parse("https://app.example.com/page")
+	dataPage, _ := url.Parse("data:text/html,<p>")
+	blobPage, _ := url.Parse("blob:https://app.example.com/0d1e")
+	tests := []struct {
+		name   string
+		ctx    func(context.Context, *url.URL) context.Context
+		doc    *url.URL
+		method string
+		url    string
+		header map[string]string
+		want   map[string]string // "" 表示没有该头部
+	}{
+		{
+			name: "跨站 fetch", doc: page, url: "https://api.other.com/x",
+			want: map[string]string{"Origin": "https://app.example.com", "Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "cors", "Sec-Fetch-Dest": "empty"},
+		},
+		{
+			name: "同站 fetch", doc: page, url: "https://api.example.com/x",
+			want: map[string]string{"Origin": "https://app.example.com", "Sec-Fetch-Site": "same-site"},
+		},
+		{
+			name: "同源 GET", doc: page, url: "https://app.example.com/x",
+			want: map[string]string{"Origin": "", "Sec-Fetch-Site": "same-origin", "Sec-Fetch-Mode": "cors"},
+		},
+		{
+			name: "同源 POST", doc: page, method: "POST", url: "https://app.example.com/x",
+			want: map[string]string{"Origin": "https://app.example.com", "Sec-Fetch-Site": "same-origin"},
+		},
+		{
+			name: "跨站图片", doc: page, url: "https://cdn.other.com/a.png", header: map[string]string{"Sec-Fetch-Dest": "image"},
+			want: map[string]string{"Origin": "", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image"},
+		},
+		{
+			name: "WebSocket", doc: page, url: "https://app.example.com/ws",
+			header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"},
+			want:   map[string]string{"Origin": "https://app.example.com", "Sec-Fetch-Mode": "websocket", "Sec-Fetch-Dest": "websocket"},
+		},
+		{
+			name: "沙箱 iframe", ctx: WithSandboxedDocument, doc: page, url: "https://app.example.com/x",
+			want: map[string]string{"Origin": "null", "Sec-Fetch-Site": "cross-site"},
+		},
+		{
+			name: "data: 页面", doc: dataPage, url: "https://api.other.com/x",
+			want: map[string]string{"Origin": "null", "Sec-Fetch-Site": "cross-site"},
+		},
+		{
+			name: "blob: 页面", doc: blobPage, url: "https://app.example.com/x",
+			want: map[string]string{"Origin": "", "Sec-Fetch-Site": "same-origin"},
+		},
+		{
+			name: "http:// 不发送 Sec-Fetch", doc: page, url: "http://api.other.com/x",
+			want: map[string]string{"Origin": "https://app.example.com", "Sec-Fetch-Site": "", "Sec-Fetch-Mode": ""},
+		},
+		{
+			name: "已有的头部不变", doc: page, url: "https://api.other.com/x",
+			header: map[string]string{"Origin": "https://custom.example", "Sec-Fetch-Mode": "no-cors"},
+			want:   map[string]string{"Origin": "https://custom.example", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "empty"},
+		},
+	}
+	tr := &Transport{FetchMetadata: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDoc := tt.ctx
+			if withDoc == nil {
+				withDoc = WithDocument
+			}
+			req, _ := NewRequestWithContext(withDoc(context.Background(), tt.doc), tt.method, tt.url, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			before := req.Header.Clone()
+			got := tr.applyFetchMetadata(req)
+			for k, want := range tt.want {
+				if v := got.Header.Get(k); v != want {
+					t.Errorf("%s got %q, want %q", k, v, want)
+				}
+			}
+			if len(req.Header) != len(before) {
+				t.Errorf("修改了原请求的头部: %v", req.Header)
+			}
+		})
+	}
+
+	// 没有页面或未开启时不修改请求
+	req, _ := NewRequest("GET", "https://api.other.com/x", nil)
+	if got := tr.applyFetchMetadata(req); got != req {
+		t.Error("没有页面时返回了新请求")
+	}
+	req, _ = NewRequestWithContext(WithDocument(context.Background(), page), "GET", "https://api.other.com/x", nil)
+	if got := (&Transport{}).applyFetchMetadata(req); got != req {
+		t.Error("FetchMetadata 为 false 时返回了新请求")
+	}
+}
+
+// TestFetchMetadataRoundTrip 测试 RoundTrip 发出的 WebSocket 升级请求带有 Origin 和 Sec-Fetch-* 头部，
+// 且不修改调用方的请求
+func TestFetchMetadataRoundTrip(t *testing.T) {
+	var got nethttp.Header
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		got = r.Header.Clone()
+	}))
+	defer ts.Close()
+	tr := &Transport{FetchMetadata: true, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer tr.CloseIdleConnections()
+
+	page, _ := url.Parse("https://app.example.com/chat")
+	req, _ := NewRequestWithContext(WithDocument(context.Background(), page), "GET", ts.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// 头部添加在副本上，resp.Request 仍是调用方的请求
+	if resp.Request != req || req.Header.Get("Sec-Fetch-Mode") != "" {
+		t.Errorf("resp.Request 不是调用方的请求或调用方的头部被修改: %v", req.Header)
+	}
+	for k, want := range map[string]string{
+		"Origin":         "https://app.example.com",
+		"Sec-Fetch-Site": "cross-site",
+		"Sec-Fetch-Mode": "websocket",
+		"Sec-Fetch-Dest": "websocket",
+	} {
+		if v := got.Get(k); v != want {
+			t.Errorf("%s got %q, want %q", k, v, want)
+		}
+	}
+}
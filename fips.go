@@ -0,0 +1,132 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/fips140"
+	"fmt"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// fipsCipherSuites 是 FIPS 模式下允许发送的密码套件
+var fipsCipherSuites = map[uint16]bool{
+	tls.TLS_AES_128_GCM_SHA256:                  true,
+	tls.TLS_AES_256_GCM_SHA384:                  true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: true,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   true,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   true,
+}
+
+// fipsGroups 是 FIPS 模式下允许的密钥交换组
+var fipsGroups = map[tls.CurveID]bool{
+	tls.CurveP256: true,
+	tls.CurveP384: true,
+	tls.CurveP521: true,
+}
+
+// fipsSignatureSchemes 是 FIPS 模式下允许的签名算法
+var fipsSignatureSchemes = map[tls.SignatureScheme]bool{
+	tls.ECDSAWithP256AndSHA256: true,
+	tls.ECDSAWithP384AndSHA384: true,
+	tls.ECDSAWithP521AndSHA512: true,
+	tls.PSSWithSHA256:          true,
+	tls.PSSWithSHA384:          true,
+	tls.PSSWithSHA512:          true,
+	tls.PKCS1WithSHA256:        true,
+	tls.PKCS1WithSHA384:        true,
+	tls.PKCS1WithSHA512:        true,
+	tls.Ed25519:                true,
+}
+
+// FIPSError 表示指纹在 FIPS 模式下无法降级为可用的 ClientHello
+//
+// 在 GODEBUG=fips140=on/only 或 Transport.FIPSMode 下，ClientHello 只保留
+// FIPS 140-3 批准的算法，以下指纹特性不可用：
+//
+//   - ChaCha20-Poly1305、CBC、3DES 等密码套件，只保留 AES-GCM 套件
+//   - X25519 及其混合后量子组，只保留 P-256、P-384、P-521
+//   - SHA-1 签名算法
+//
+// 因此 FIPS 模式下发送的 ClientHello 与真实浏览器不同，JA3/JA4 会变化。
+// 不批准的组件会被静默移除；移除后没有可用的组件时建连返回 *FIPSError，
+// 而不是在握手阶段才失败。
+type FIPSError struct {
+	Component string // 无可用算法的组件，如 "cipher_suites"、"supported_groups"
+}
+
+func (e *FIPSError) Error() string {
+	return fmt.Sprintf("FIPS 模式下指纹的 %s 没有批准的算法", e.Component)
+}
+
+// FIPSEnabled 报告当前进程是否运行在 FIPS 140-3 模式 (GODEBUG=fips140)
+func FIPSEnabled() bool {
+	return fips140.Enabled()
+}
+
+// fipsMode 报告 t 建连时是否按 FIPS 限制处理 ClientHello
+func (t *Transport) fipsMode() bool {
+	return t.FIPSMode || fips140.Enabled()
+}
+
+// applyFIPSPolicy 移除 spec 中 FIPS 不批准的密码套件、组和签名算法
+// GREASE 值不参与协商，予以保留
+func applyFIPSPolicy(spec *tls.ClientHelloSpec) error {
+	spec.CipherSuites = slices.DeleteFunc(spec.CipherSuites, func(c uint16) bool {
+		return !fipsCipherSuites[c] && !isGREASEValue(c)
+	})
+	if !slices.ContainsFunc(spec.CipherSuites, func(c uint16) bool { return !isGREASEValue(c) }) {
+		return &FIPSError{Component: "cipher_suites"}
+	}
+
+	var group tls.CurveID // 第一个批准的组
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.SupportedCurvesExtension:
+			e.Curves = slices.DeleteFunc(e.Curves, func(g tls.CurveID) bool {
+				return !fipsGroups[g] && !isGREASEValue(uint16(g))
+			})
+			i := slices.IndexFunc(e.Curves, func(g tls.CurveID) bool { return fipsGroups[g] })
+			if i < 0 {
+				return &FIPSError{Component: "supported_groups"}
+			}
+			group = e.Curves[i]
+		case *tls.SignatureAlgorithmsExtension:
+			e.SupportedSignatureAlgorithms = slices.DeleteFunc(e.SupportedSignatureAlgorithms, notFIPSSignature)
+			if len(e.SupportedSignatureAlgorithms) == 0 {
+				return &FIPSError{Component: "signature_algorithms"}
+			}
+		case *tls.SignatureAlgorithmsCertExtension:
+			e.SupportedSignatureAlgorithms = slices.DeleteFunc(e.SupportedSignatureAlgorithms, notFIPSSignature)
+			if len(e.SupportedSignatureAlgorithms) == 0 {
+				return &FIPSError{Component: "signature_algorithms_cert"}
+			}
+		}
+	}
+
+	for _, e := range spec.Extensions {
+		ks, ok := e.(*tls.KeyShareExtension)
+		if !ok {
+			continue
+		}
+		ks.KeyShares = slices.DeleteFunc(ks.KeyShares, func(s tls.KeyShare) bool {
+			return !fipsGroups[s.Group] && !isGREASEValue(uint16(s.Group))
+		})
+		if !slices.ContainsFunc(ks.KeyShares, func(s tls.KeyShare) bool { return fipsGroups[s.Group] }) {
+			// 原有的密钥共享都被移除，改为第一个批准的组生成密钥
+			if group == 0 {
+				group = tls.CurveP256
+			}
+			ks.KeyShares = append(ks.KeyShares, tls.KeyShare{Group: group})
+		}
+	}
+	return nil
+}
+
+func notFIPSSignature(s tls.SignatureScheme) bool {
+	return !fipsSignatureSchemes[s]
+}
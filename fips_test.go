@@ -0,0 +1,93 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestApplyFIPSPolicy 测试 FIPS 模式下 ClientHello 降级为批准的算法
+func TestApplyFIPSPolicy(t *testing.T) {
+	const ja3 = "771,4865-4866-4867-49195-49199-52393-52392-49171,0-10-11-13-43-51,29-23-24,0"
+	spec, err := (&TLSExtensionsConfig{NotUsedGREASE: true}).StringToSpec(ja3, "Mozilla/5.0 Firefox/120.0", false, false)
+	if err != nil {
+		t.Fatalf("StringToSpec() 失败: %v", err)
+	}
+	if err := applyFIPSPolicy(spec); err != nil {
+		t.Fatalf("applyFIPSPolicy() 失败: %v", err)
+	}
+
+	wantCiphers := []uint16{4865, 4866, 49195, 49199}
+	if !slices.Equal(spec.CipherSuites, wantCiphers) {
+		t.Errorf("CipherSuites got %v, want %v", spec.CipherSuites, wantCiphers)
+	}
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.SupportedCurvesExtension:
+			if want := []tls.CurveID{tls.CurveP256, tls.CurveP384}; !slices.Equal(e.Curves, want) {
+				t.Errorf("supported_groups got %v, want %v", e.Curves, want)
+			}
+		case *tls.KeyShareExtension:
+			for _, s := range e.KeyShares {
+				if !fipsGroups[s.Group] {
+					t.Errorf("key_share 包含未批准的组 %v", s.Group)
+				}
+			}
+		case *tls.SignatureAlgorithmsExtension:
+			if slices.Contains(e.SupportedSignatureAlgorithms, tls.ECDSAWithSHA1) {
+				t.Error("signature_algorithms 仍包含 SHA-1")
+			}
+		}
+	}
+}
+
+// TestTransportFIPSMode 测试 FIPS 模式下的建连以及无法降级时的错误
+func TestTransportFIPSMode(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		ja3           string
+		wantComponent string
+	}{
+		{name: "降级后握手成功", ja3: "771,4865-4867-49195-52393,0-10-11-13-43-51-65281,29-23,0"},
+		{name: "只有 ChaCha20", ja3: "771,4867-52393,0-10-11-13-43-51-65281,29-23,0", wantComponent: "cipher_suites"},
+		{name: "只有 X25519", ja3: "771,4865,0-10-11-13-43-51-65281,29,0", wantComponent: "supported_groups"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:             tt.ja3,
+				UserAgent:       "Mozilla/5.0 Firefox/120.0",
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				FIPSMode:        true,
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(ts.URL)
+			var fe *FIPSError
+			if tt.wantComponent == "" {
+				if err != nil {
+					t.Fatalf("Get() 失败: %v", err)
+				}
+				resp.Body.Close()
+				return
+			}
+			if !errors.As(err, &fe) {
+				t.Fatalf("got %v, want *FIPSError", err)
+			}
+			if fe.Component != tt.wantComponent {
+				t.Errorf("Component got %q, want %q", fe.Component, tt.wantComponent)
+			}
+		})
+	}
+}
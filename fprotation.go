@@ -0,0 +1,158 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// FingerprintRotation 让 Transport 按策略在多个指纹之间自动轮换
+//
+// 轮换的 HTTPS 请求与使用 WithFingerprint 的请求相同：连接池按指纹划分，
+// 切换指纹后旧指纹的空闲连接被关闭，正在使用的连接不受影响。
+// 请求已通过 WithFingerprint 指定指纹时不参与轮换。
+// 亲和组 (见 WithAffinity) 固定使用首次分配的指纹 (PerHost 为 true 时按目标分别固定)，
+// 不按 Every 和 Interval 切换，直到 Transport.ReleaseAffinity。
+//
+// Every 和 Interval 都为 0 且 PerHost 为 false 时始终使用第一个指纹。
+// 首次使用后不应再修改其字段。
+type FingerprintRotation struct {
+	// Fingerprints 是轮换使用的指纹，按顺序循环
+	Fingerprints []RequestFingerprint
+
+	// Every 大于 0 时，每发送 Every 个请求切换到下一个指纹
+	Every int
+
+	// Interval 大于 0 时，使用一个指纹满 Interval 后切换到下一个指纹
+	Interval time.Duration
+
+	// PerHost 为 true 时每个目标地址 (host:port) 独立计数和切换，
+	// 新出现的目标从下一个指纹开始，不同目标因此分散使用各个指纹
+	PerHost bool
+
+	// OnRotate 非 nil 时，在切换指纹后以目标地址 (PerHost 为 false 时为空)
+	// 和切换前后的指纹下标调用
+	OnRotate func(addr string, from, to int)
+}
+
+// rotationState 记录 Transport.Rotation 的轮换状态
+type rotationState struct {
+	mu     sync.Mutex
+	next   int                      // 下一个新目标使用的指纹
+	slots  map[string]*rotationSlot // 按目标地址，PerHost 为 false 时只有 ""
+	pinned map[rotationPin]int      // 亲和组在各目标上固定的指纹
+}
+
+// rotationSlot 是一个目标当前使用的指纹
+type rotationSlot struct {
+	index int       // 指纹下标
+	count int       // 已用它发送的请求数
+	since time.Time // 开始使用它的时间
+}
+
+// rotationPin 是亲和组在一个目标上的轮换状态的键
+type rotationPin struct {
+	affinity, addr string
+}
+
+// pick 返回发往 addr 的请求使用的指纹下标，切换了指纹时 retired 为被替换的下标，
+// 否则为 -1
+func (s *rotationState) pick(r *FingerprintRotation, addr, affinity string, now time.Time) (index, retired int) {
+	n := len(r.Fingerprints)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !r.PerHost {
+		addr = ""
+	}
+	if affinity != "" {
+		pin := rotationPin{affinity, addr}
+		if i, ok := s.pinned[pin]; ok {
+			return i, -1
+		}
+		if s.pinned == nil {
+			s.pinned = make(map[rotationPin]int)
+		}
+		i := s.slotLocked(r, addr, now).index
+		s.pinned[pin] = i
+		return i, -1
+	}
+	slot := s.slotLocked(r, addr, now)
+	retired = -1
+	if (r.Every > 0 && slot.count >= r.Every) || (r.Interval > 0 && now.Sub(slot.since) >= r.Interval) {
+		retired = slot.index
+		slot.index = (slot.index + 1) % n
+		slot.count = 0
+		slot.since = now
+	}
+	slot.count++
+	return slot.index, retired
+}
+
+// slotLocked 返回 addr 的轮换状态，没有时创建
+func (s *rotationState) slotLocked(r *FingerprintRotation, addr string, now time.Time) *rotationSlot {
+	slot := s.slots[addr]
+	if slot == nil {
+		if s.slots == nil {
+			s.slots = make(map[string]*rotationSlot)
+		}
+		slot = &rotationSlot{index: s.next % len(r.Fingerprints), since: now}
+		if r.PerHost {
+			s.next++
+		}
+		s.slots[addr] = slot
+	}
+	return slot
+}
+
+// release 清除亲和组 affinity 固定的指纹
+func (s *rotationState) release(affinity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pin := range s.pinned {
+		if pin.affinity == affinity {
+			delete(s.pinned, pin)
+		}
+	}
+}
+
+// rotateFingerprint 按 t.Rotation 为 req 选择指纹，返回带有该指纹的请求
+func (t *Transport) rotateFingerprint(req *Request) *Request {
+	r := t.Rotation
+	if r == nil || len(r.Fingerprints) == 0 || req.URL.Scheme != "https" {
+		return req
+	}
+	ctx := req.Context()
+	if _, ok := FingerprintFromContext(ctx); ok {
+		return req
+	}
+	addr := canonicalAddr(req.URL)
+	affinity, _ := AffinityFromContext(ctx)
+	i, retired := t.rotation.pick(r, addr, affinity, t.now())
+	if retired >= 0 && retired != i {
+		if !r.PerHost {
+			addr = ""
+		}
+		t.closeIdleFingerprint(r.Fingerprints[retired].poolKey(), addr)
+		if r.OnRotate != nil {
+			r.OnRotate(addr, retired, i)
+		}
+	}
+	return req.WithContext(WithFingerprint(ctx, r.Fingerprints[i]))
+}
+
+// closeIdleFingerprint 关闭以指纹摘要 helloKey 建立、不属于亲和组的空闲连接，
+// addr 非空时只关闭到 addr 的连接
+func (t *Transport) closeIdleFingerprint(helloKey, addr string) {
+	match := func(a string, p connPartition) bool {
+		return p.fingerprint == helloKey && p.affinity == "" && (addr == "" || a == addr)
+	}
+	t.closeIdleMatching(func(k connectMethodKey) bool { return match(k.addr, k.partition) })
+	if t2, ok := t.H2Transport.(*HTTP2Transport); ok {
+		if p := t2.clientConnPool(); p != nil {
+			p.closeIdleConnectionsMatching(match)
+		}
+	}
+}
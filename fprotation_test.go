@@ -0,0 +1,130 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestRotationPick 测试各轮换策略选择的指纹
+func TestRotationPick(t *testing.T) {
+	fps := make([]RequestFingerprint, 3)
+	type step struct {
+		addr, affinity string
+		advance        time.Duration
+	}
+	tests := []struct {
+		name  string
+		r     FingerprintRotation
+		steps []step
+		want  []int
+	}{
+		{
+			name:  "每 2 个请求",
+			r:     FingerprintRotation{Every: 2},
+			steps: []step{{}, {}, {}, {}, {}, {}, {}},
+			want:  []int{0, 0, 1, 1, 2, 2, 0},
+		},
+		{
+			name:  "按时间",
+			r:     FingerprintRotation{Interval: time.Minute},
+			steps: []step{{}, {advance: 30 * time.Second}, {advance: 30 * time.Second}, {}, {advance: 2 * time.Minute}},
+			want:  []int{0, 0, 1, 1, 2},
+		},
+		{
+			name:  "按目标",
+			r:     FingerprintRotation{PerHost: true},
+			steps: []step{{addr: "a:443"}, {addr: "b:443"}, {addr: "a:443"}, {addr: "c:443"}, {addr: "d:443"}, {addr: "b:443"}},
+			want:  []int{0, 1, 0, 2, 0, 1},
+		},
+		{
+			name:  "按目标每个请求",
+			r:     FingerprintRotation{PerHost: true, Every: 1},
+			steps: []step{{addr: "a:443"}, {addr: "b:443"}, {addr: "a:443"}, {addr: "b:443"}},
+			want:  []int{0, 1, 1, 2},
+		},
+		{
+			name:  "亲和组固定指纹",
+			r:     FingerprintRotation{Every: 1},
+			steps: []step{{}, {affinity: "x"}, {}, {affinity: "x"}, {affinity: "y"}, {affinity: "x"}},
+			want:  []int{0, 0, 1, 0, 1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.r.Fingerprints = fps
+			var s rotationState
+			now := time.Unix(0, 0)
+			var got []int
+			for _, st := range tt.steps {
+				now = now.Add(st.advance)
+				i, _ := s.pick(&tt.r, st.addr, st.affinity, now)
+				got = append(got, i)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTransportRotation 测试轮换的指纹用于连接，切换后旧指纹的空闲连接被关闭
+func TestTransportRotation(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	fps := []RequestFingerprint{
+		{JA3: "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"},
+		{JA3: "771,4865-4867-4866-49199-49195,0-10-11-13-16-23-43-45-51-65281,29-23,0"},
+	}
+	type rotation struct{ from, to int }
+	var rotations []rotation
+	tr := &Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Rotation: &FingerprintRotation{
+			Fingerprints: fps,
+			Every:        2,
+			OnRotate:     func(addr string, from, to int) { rotations = append(rotations, rotation{from, to}) },
+		},
+	}
+	defer tr.CloseIdleConnections()
+	idle := func(fp RequestFingerprint) int {
+		tr.idleMu.Lock()
+		defer tr.idleMu.Unlock()
+		n := 0
+		for k, conns := range tr.idleConn {
+			if k.partition.fingerprint == fp.poolKey() {
+				n += len(conns)
+			}
+		}
+		return n
+	}
+
+	for i, want := range []int{0, 0, 1, 1, 0} {
+		req, _ := NewRequestWithContext(context.Background(), "GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.Meta.Fingerprint != fps[want].JA3 {
+			t.Errorf("#%d: Fingerprint got %q, want %q", i, resp.Meta.Fingerprint, fps[want].JA3)
+		}
+		if n := idle(fps[1-want]); n != 0 {
+			t.Errorf("#%d: 旧指纹还有 %d 个空闲连接", i, n)
+		}
+	}
+	if want := []rotation{{0, 1}, {1, 0}}; !reflect.DeepEqual(rotations, want) {
+		t.Errorf("OnRotate got %v, want %v", rotations, want)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FingerprintOutcome 是一次请求的结果分类
+type FingerprintOutcome int
+
+const (
+	OutcomeSuccess          FingerprintOutcome = iota // 2xx 响应
+	OutcomeForbidden                                  // 403 响应
+	OutcomeRateLimited                                // 429 响应
+	OutcomeOtherStatus                                // 其他状态码
+	OutcomeHandshakeFailure                           // TLS 握手失败
+	OutcomeError                                      // 其他传输错误
+)
+
+// FingerprintStat 是一个指纹在一个目标地址上的请求结果统计
+type FingerprintStat struct {
+	Fingerprint       string    `json:"fingerprint"` // 配置的指纹，同 ResponseMeta.Fingerprint
+	Host              string    `json:"host"`        // 目标地址 host:port，按指纹汇总时为空
+	Total             int       `json:"total"`
+	Success           int       `json:"success"`
+	Forbidden         int       `json:"forbidden"`
+	RateLimited       int       `json:"rate_limited"`
+	OtherStatus       int       `json:"other_status"`
+	HandshakeFailures int       `json:"handshake_failures"`
+	Errors            int       `json:"errors"`
+	LastSeen          time.Time `json:"last_seen"`
+}
+
+// SuccessRate 返回 2xx 响应的比例，没有记录时返回 0
+func (s FingerprintStat) SuccessRate() float64 {
+	return s.rate(s.Success)
+}
+
+// BlockRate 返回 403 和 429 响应的比例，没有记录时返回 0
+func (s FingerprintStat) BlockRate() float64 {
+	return s.rate(s.Forbidden + s.RateLimited)
+}
+
+// HandshakeFailureRate 返回 TLS 握手失败的比例，没有记录时返回 0
+func (s FingerprintStat) HandshakeFailureRate() float64 {
+	return s.rate(s.HandshakeFailures)
+}
+
+func (s FingerprintStat) rate(n int) float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(n) / float64(s.Total)
+}
+
+func (s *FingerprintStat) add(o FingerprintOutcome) {
+	s.Total++
+	switch o {
+	case OutcomeSuccess:
+		s.Success++
+	case OutcomeForbidden:
+		s.Forbidden++
+	case OutcomeRateLimited:
+		s.RateLimited++
+	case OutcomeOtherStatus:
+		s.OtherStatus++
+	case OutcomeHandshakeFailure:
+		s.HandshakeFailures++
+	default:
+		s.Errors++
+	}
+}
+
+// FingerprintStats 按指纹和目标地址统计请求结果，用于发现被识别的指纹
+//
+// 设置为 Transport.FingerprintStats 后，每个请求结束时记录一次结果；
+// 调用方取消的请求不计入。零值可以直接使用，可在多个 Transport
+// 之间共享，并发安全。
+type FingerprintStats struct {
+	// MaxEntries 是保留的 (指纹, 目标地址) 组合数上限，超出时淘汰
+	// 最久未出现的组合，零表示 10000
+	MaxEntries int
+
+	// Storage 非 nil 时每次记录后保存该组合的统计，新组合的统计从 Storage 加载，
+	// 使统计在进程之间累积。Storage 实现了 StorageLister 时，首次使用时加载所有统计
+	Storage Storage
+
+	mu      sync.Mutex
+	entries map[fingerprintStatKey]*FingerprintStat
+	loaded  bool // 已从 Storage 加载所有统计
+}
+
+type fingerprintStatKey struct {
+	fingerprint, host string
+}
+
+// storageKey 返回组合在 Storage 中的键
+func (k fingerprintStatKey) storageKey() string {
+	return k.fingerprint + "|" + k.host
+}
+
+// loadLocked 在首次使用时从实现了 StorageLister 的 Storage 加载所有统计
+func (s *FingerprintStats) loadLocked() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	lister, ok := s.Storage.(StorageLister)
+	if !ok {
+		return
+	}
+	keys, err := lister.Keys(StorageNamespaceFingerprintStats)
+	if err != nil {
+		return
+	}
+	for _, k := range keys {
+		if e, ok := s.loadEntry(k); ok {
+			key := fingerprintStatKey{e.Fingerprint, e.Host}
+			if s.entries[key] == nil {
+				if s.entries == nil {
+					s.entries = make(map[fingerprintStatKey]*FingerprintStat)
+				}
+				s.evictLocked()
+				s.entries[key] = e
+			}
+		}
+	}
+}
+
+// loadEntry 从 Storage 加载键为 storageKey 的统计
+func (s *FingerprintStats) loadEntry(storageKey string) (*FingerprintStat, bool) {
+	if s.Storage == nil {
+		return nil, false
+	}
+	data, ok, err := s.Storage.Get(StorageNamespaceFingerprintStats, storageKey)
+	if !ok || err != nil {
+		return nil, false
+	}
+	e := new(FingerprintStat)
+	if json.Unmarshal(data, e) != nil {
+		return nil, false
+	}
+	return e, true
+}
+
+// Record 记录 fingerprint 在 host 上的一次请求结果
+func (s *FingerprintStats) Record(fingerprint, host string, o FingerprintOutcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	key := fingerprintStatKey{fingerprint, host}
+	e := s.entries[key]
+	if e == nil {
+		if s.entries == nil {
+			s.entries = make(map[fingerprintStatKey]*FingerprintStat)
+		}
+		s.evictLocked()
+		var ok bool
+		if e, ok = s.loadEntry(key.storageKey()); !ok {
+			e = &FingerprintStat{Fingerprint: fingerprint, Host: host}
+		}
+		s.entries[key] = e
+	}
+	e.add(o)
+	e.LastSeen = time.Now()
+	if s.Storage != nil {
+		data, _ := json.Marshal(e)
+		s.Storage.Set(StorageNamespaceFingerprintStats, key.storageKey(), data, 0)
+	}
+}
+
+// evictLocked 在组合数达到上限时淘汰最久未出现的组合
+func (s *FingerprintStats) evictLocked() {
+	limit := s.MaxEntries
+	if limit <= 0 {
+		limit = 10000
+	}
+	for len(s.entries) >= limit {
+		var oldest fingerprintStatKey
+		var seen time.Time
+		for k, e := range s.entries {
+			if seen.IsZero() || e.LastSeen.Before(seen) {
+				oldest, seen = k, e.LastSeen
+			}
+		}
+		delete(s.entries, oldest)
+	}
+}
+
+// Get 返回 fingerprint 在 host 上的统计，host 为空时返回该指纹在所有地址上的汇总
+func (s *FingerprintStats) Get(fingerprint, host string) FingerprintStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	if host != "" {
+		key := fingerprintStatKey{fingerprint, host}
+		if e := s.entries[key]; e != nil {
+			return *e
+		}
+		if e, ok := s.loadEntry(key.storageKey()); ok {
+			return *e
+		}
+		return FingerprintStat{Fingerprint: fingerprint, Host: host}
+	}
+	sum := FingerprintStat{Fingerprint: fingerprint}
+	for k, e := range s.entries {
+		if k.fingerprint == fingerprint {
+			mergeStat(&sum, e)
+		}
+	}
+	return sum
+}
+
+// Snapshot 返回所有 (指纹, 目标地址) 组合的统计，按指纹和地址排序
+func (s *FingerprintStats) Snapshot() []FingerprintStat {
+	s.mu.Lock()
+	s.loadLocked()
+	out := make([]FingerprintStat, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, *e)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(out, compareStats)
+	return out
+}
+
+// ByFingerprint 返回每个指纹在所有目标地址上的汇总统计，按指纹排序
+func (s *FingerprintStats) ByFingerprint() []FingerprintStat {
+	s.mu.Lock()
+	s.loadLocked()
+	sums := make(map[string]*FingerprintStat)
+	for k, e := range s.entries {
+		sum := sums[k.fingerprint]
+		if sum == nil {
+			sum = &FingerprintStat{Fingerprint: k.fingerprint}
+			sums[k.fingerprint] = sum
+		}
+		mergeStat(sum, e)
+	}
+	s.mu.Unlock()
+	out := make([]FingerprintStat, 0, len(sums))
+	for _, sum := range sums {
+		out = append(out, *sum)
+	}
+	slices.SortFunc(out, compareStats)
+	return out
+}
+
+// Reset 清空所有统计，包括 Storage 中的统计
+func (s *FingerprintStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	if s.Storage != nil {
+		for k := range s.entries {
+			s.Storage.Delete(StorageNamespaceFingerprintStats, k.storageKey())
+		}
+	}
+	s.entries = nil
+}
+
+func mergeStat(sum, e *FingerprintStat) {
+	sum.Total += e.Total
+	sum.Success += e.Success
+	sum.Forbidden += e.Forbidden
+	sum.RateLimited += e.RateLimited
+	sum.OtherStatus += e.OtherStatus
+	sum.HandshakeFailures += e.HandshakeFailures
+	sum.Errors += e.Errors
+	if e.LastSeen.After(sum.LastSeen) {
+		sum.LastSeen = e.LastSeen
+	}
+}
+
+func compareStats(a, b FingerprintStat) int {
+	return cmp.Or(cmp.Compare(a.Fingerprint, b.Fingerprint), cmp.Compare(a.Host, b.Host))
+}
+
+// classifyOutcome 返回请求结果的分类，调用方取消的请求返回 false
+func classifyOutcome(resp *Response, err error, handshakeFailed bool) (FingerprintOutcome, bool) {
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		return 0, false
+	case err != nil && handshakeFailed:
+		return OutcomeHandshakeFailure, true
+	case err != nil:
+		return OutcomeError, true
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return OutcomeSuccess, true
+	case resp.StatusCode == StatusForbidden:
+		return OutcomeForbidden, true
+	case resp.StatusCode == StatusTooManyRequests:
+		return OutcomeRateLimited, true
+	}
+	return OutcomeOtherStatus, true
+}
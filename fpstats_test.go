@@ -0,0 +1,134 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestFingerprintStatsTransport 测试 Transport 按指纹和地址记录请求结果
+func TestFingerprintStatsTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+	plain := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer plain.Close()
+
+	const ja3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+	stats := &FingerprintStats{}
+	tr := &Transport{
+		JA3:              ja3,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+		FingerprintStats: stats,
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for _, code := range []int{200, 204, 403, 429, 500} {
+		resp, err := c.Get(srv.URL + "/" + strconv.Itoa(code))
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	// 对明文服务端发起 TLS 握手
+	if _, err := c.Get(strings.Replace(plain.URL, "http://", "https://", 1)); err == nil {
+		t.Fatal("对明文服务端的 HTTPS 请求应失败")
+	}
+
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	got := stats.Get(ja3, addr)
+	want := FingerprintStat{Fingerprint: ja3, Host: addr, Total: 5, Success: 2, Forbidden: 1, RateLimited: 1, OtherStatus: 1}
+	got.LastSeen = want.LastSeen
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if r := got.BlockRate(); r != 0.4 {
+		t.Errorf("BlockRate() got %v, want 0.4", r)
+	}
+
+	failed := stats.Get(ja3, strings.TrimPrefix(plain.URL, "http://"))
+	if failed.Total != 1 || failed.HandshakeFailures != 1 {
+		t.Errorf("握手失败统计 got %+v", failed)
+	}
+	if sum := stats.Get(ja3, ""); sum.Total != 6 {
+		t.Errorf("汇总 Total got %v, want 6", sum.Total)
+	}
+	if all := stats.ByFingerprint(); len(all) != 1 || all[0].Total != 6 || all[0].Host != "" {
+		t.Errorf("ByFingerprint() got %+v", all)
+	}
+}
+
+// TestFingerprintStatsEviction 测试超出 MaxEntries 时淘汰最久未出现的组合
+func TestFingerprintStatsEviction(t *testing.T) {
+	s := &FingerprintStats{MaxEntries: 2}
+	s.Record("a", "h1:443", OutcomeSuccess)
+	s.Record("a", "h2:443", OutcomeSuccess)
+	s.Record("a", "h1:443", OutcomeForbidden)
+	s.Record("b", "h1:443", OutcomeError)
+
+	var keys []string
+	for _, st := range s.Snapshot() {
+		keys = append(keys, st.Fingerprint+"|"+st.Host)
+	}
+	if got, want := strings.Join(keys, ","), "a|h1:443,b|h1:443"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	s.Reset()
+	if n := len(s.Snapshot()); n != 0 {
+		t.Errorf("Reset 后仍有 %d 条统计", n)
+	}
+}
+
+// TestFingerprintRotatorStats 测试轮换时优先选择成功率高的候选
+func TestFingerprintRotatorStats(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusForbidden)
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	ja3 := func(ciphers string) string { return "771," + ciphers + ",0-10-11-13-16-23-43-45-51-65281,29-23-24,0" }
+	a, b, c := ja3("4865-4866-4867"), ja3("4866-4865-4867"), ja3("4867-4866-4865")
+	stats := &FingerprintStats{}
+	stats.Record(b, addr, OutcomeForbidden)
+	var events []RotationEvent
+	r := &FingerprintRotator{
+		Base: &Transport{
+			TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+			FingerprintStats: stats,
+		},
+		Candidates: []RotationCandidate{
+			{Fingerprint: &TLSFingerprintConfig{JA3: a}},
+			{Fingerprint: &TLSFingerprintConfig{JA3: b}},
+			{Fingerprint: &TLSFingerprintConfig{JA3: c}},
+		},
+		Threshold: 1,
+		OnRotate:  func(ev RotationEvent) { events = append(events, ev) },
+	}
+	defer r.CloseIdleConnections()
+
+	resp, err := (&Client{Transport: r}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// 候选 1 已被拦截过，跳过它选择没有记录的候选 2
+	if len(events) != 1 || events[0].To != 2 {
+		t.Fatalf("events = %+v, want 轮换到 2", events)
+	}
+	if st := stats.Get(a, addr); st.Forbidden != 1 {
+		t.Errorf("候选 0 的统计 got %+v", st)
+	}
+}
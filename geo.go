@@ -0,0 +1,237 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GeoConsistency 在发送请求前检查出口所在国家与请求头部声明的语言和时区是否一致，
+// 如德国 IP 却只接受 en-US，这类不一致常被用于识别代理流量
+//
+// 出口国家由 Lookup 查询并按代理缓存。不一致时调用 OnMismatch，
+// Adjust 为 true 时按出口国家改写头部后再发送。
+// 查询失败时不检查，也不缓存结果。
+type GeoConsistency struct {
+	// Lookup 返回经 proxy 访问时出口的国家代码 (ISO 3166-1 alpha-2，如 "DE")，
+	// 直连时 proxy 为 nil。ctx 是请求的 context。可能被并发调用
+	Lookup func(ctx context.Context, proxy *url.URL) (country string, err error)
+
+	// TTL 是 Lookup 结果的缓存时间，为 0 时使用 1 小时
+	TTL time.Duration
+
+	// Adjust 为 true 时，Accept-Language (和 TimezoneHeader) 缺失或与出口国家
+	// 不一致的请求改用该国家的值发送，调用方的请求不被修改
+	Adjust bool
+
+	// TimezoneHeader 是携带 IANA 时区名称 (如 "Europe/Berlin") 的头部，
+	// 如某些 API 要求的 X-Timezone，为空时不检查时区
+	TimezoneHeader string
+
+	// Languages 和 Timezones 按国家代码覆盖内置的 Accept-Language 和时区，
+	// 没有内置值也没有覆盖的国家不检查对应头部
+	Languages map[string]string
+	Timezones map[string][]string
+
+	// OnMismatch 非 nil 时，每发现一个不一致的头部调用一次 (调整之前)，
+	// 为 nil 且 Adjust 为 false 时记录日志
+	OnMismatch func(*GeoMismatch)
+}
+
+// GeoMismatch 描述一个与出口国家不一致的请求头部
+type GeoMismatch struct {
+	Request *Request
+	Proxy   *url.URL // 使用的代理，直连时为 nil
+	Country string   // 出口国家代码
+	Header  string   // 不一致的头部
+	Got     string   // 请求中的值，缺失时为空
+	Want    string   // 该国家对应的值
+}
+
+// geoLanguages 是常见国家的浏览器默认 Accept-Language
+var geoLanguages = map[string]string{
+	"US": "en-US,en;q=0.9",
+	"GB": "en-GB,en;q=0.9",
+	"CA": "en-CA,en;q=0.9,fr-CA;q=0.8",
+	"AU": "en-AU,en;q=0.9",
+	"IN": "en-IN,en;q=0.9,hi;q=0.8",
+	"DE": "de-DE,de;q=0.9,en-US;q=0.8,en;q=0.7",
+	"AT": "de-AT,de;q=0.9,en-US;q=0.8,en;q=0.7",
+	"CH": "de-CH,de;q=0.9,fr;q=0.8,en;q=0.7",
+	"FR": "fr-FR,fr;q=0.9,en-US;q=0.8,en;q=0.7",
+	"ES": "es-ES,es;q=0.9,en;q=0.8",
+	"MX": "es-MX,es;q=0.9,en;q=0.8",
+	"IT": "it-IT,it;q=0.9,en-US;q=0.8,en;q=0.7",
+	"NL": "nl-NL,nl;q=0.9,en-US;q=0.8,en;q=0.7",
+	"PL": "pl-PL,pl;q=0.9,en-US;q=0.8,en;q=0.7",
+	"SE": "sv-SE,sv;q=0.9,en-US;q=0.8,en;q=0.7",
+	"BR": "pt-BR,pt;q=0.9,en-US;q=0.8,en;q=0.7",
+	"PT": "pt-PT,pt;q=0.9,en-US;q=0.8,en;q=0.7",
+	"RU": "ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7",
+	"TR": "tr-TR,tr;q=0.9,en-US;q=0.8,en;q=0.7",
+	"JP": "ja-JP,ja;q=0.9,en-US;q=0.8,en;q=0.7",
+	"KR": "ko-KR,ko;q=0.9,en-US;q=0.8,en;q=0.7",
+	"CN": "zh-CN,zh;q=0.9,en;q=0.8",
+	"TW": "zh-TW,zh;q=0.9,en-US;q=0.8,en;q=0.7",
+	"HK": "zh-HK,zh;q=0.9,en;q=0.8",
+	"SG": "en-SG,en;q=0.9,zh;q=0.8",
+}
+
+// geoTimezones 是常见国家的时区，第一个用于调整
+var geoTimezones = map[string][]string{
+	"US": {"America/New_York", "America/Chicago", "America/Denver", "America/Phoenix", "America/Los_Angeles", "America/Anchorage", "Pacific/Honolulu"},
+	"GB": {"Europe/London"},
+	"CA": {"America/Toronto", "America/Winnipeg", "America/Edmonton", "America/Vancouver", "America/Halifax"},
+	"AU": {"Australia/Sydney", "Australia/Melbourne", "Australia/Brisbane", "Australia/Adelaide", "Australia/Perth"},
+	"IN": {"Asia/Kolkata"},
+	"DE": {"Europe/Berlin"},
+	"AT": {"Europe/Vienna"},
+	"CH": {"Europe/Zurich"},
+	"FR": {"Europe/Paris"},
+	"ES": {"Europe/Madrid", "Atlantic/Canary"},
+	"MX": {"America/Mexico_City", "America/Monterrey", "America/Tijuana", "America/Cancun"},
+	"IT": {"Europe/Rome"},
+	"NL": {"Europe/Amsterdam"},
+	"PL": {"Europe/Warsaw"},
+	"SE": {"Europe/Stockholm"},
+	"BR": {"America/Sao_Paulo", "America/Manaus", "America/Fortaleza", "America/Recife"},
+	"PT": {"Europe/Lisbon", "Atlantic/Azores"},
+	"RU": {"Europe/Moscow", "Asia/Yekaterinburg", "Asia/Novosibirsk", "Asia/Vladivostok"},
+	"TR": {"Europe/Istanbul"},
+	"JP": {"Asia/Tokyo"},
+	"KR": {"Asia/Seoul"},
+	"CN": {"Asia/Shanghai"},
+	"TW": {"Asia/Taipei"},
+	"HK": {"Asia/Hong_Kong"},
+	"SG": {"Asia/Singapore"},
+}
+
+// geoCacheEntry 是一个代理出口的国家
+type geoCacheEntry struct {
+	country string
+	expires time.Time
+}
+
+// geoCache 按代理缓存 GeoConsistency.Lookup 的结果
+type geoCache struct {
+	mu sync.Mutex
+	m  map[string]geoCacheEntry
+}
+
+// geoCountry 返回经 proxy 访问时的出口国家，缓存过期时调用 g.Lookup
+func (t *Transport) geoCountry(ctx context.Context, g *GeoConsistency, proxy *url.URL) (string, bool) {
+	key := ""
+	if proxy != nil {
+		key = proxy.String()
+	}
+	now := t.now()
+	c := &t.geo
+	c.mu.Lock()
+	e, ok := c.m[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.country, true
+	}
+	country, err := g.Lookup(ctx, proxy)
+	if err != nil || country == "" {
+		return "", false
+	}
+	country = strings.ToUpper(country)
+	ttl := g.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	c.mu.Lock()
+	if c.m == nil {
+		c.m = make(map[string]geoCacheEntry)
+	}
+	// 过期的条目在这里顺带清理，避免轮换代理时无限增长
+	for k, old := range c.m {
+		if !now.Before(old.expires) {
+			delete(c.m, k)
+		}
+	}
+	c.m[key] = geoCacheEntry{country: country, expires: now.Add(ttl)}
+	c.mu.Unlock()
+	return country, true
+}
+
+// checkGeo 按 t.GeoConsistency 检查经 proxy 发送的 req，Adjust 时返回调整后的副本
+func (t *Transport) checkGeo(req *Request, proxy *url.URL) *Request {
+	g := t.GeoConsistency
+	country, ok := t.geoCountry(req.Context(), g, proxy)
+	if !ok {
+		return req
+	}
+	var fix map[string]string
+	mismatch := func(header, got, want string) {
+		m := &GeoMismatch{Request: req, Proxy: proxy, Country: country, Header: header, Got: got, Want: want}
+		if g.OnMismatch != nil {
+			g.OnMismatch(m)
+		} else if !g.Adjust {
+			log.Printf("tlshttp: %s 的出口国家为 %s，但 %s 为 %q", req.URL.Host, country, header, got)
+		}
+		if g.Adjust {
+			if fix == nil {
+				fix = make(map[string]string)
+			}
+			fix[header] = want
+		}
+	}
+
+	want, ok := g.Languages[country]
+	if !ok {
+		want, ok = geoLanguages[country]
+	}
+	if got := req.Header.Get("Accept-Language"); ok && !languageMatches(got, want) {
+		mismatch("Accept-Language", got, want)
+	}
+	if h := g.TimezoneHeader; h != "" {
+		zones, ok := g.Timezones[country]
+		if !ok {
+			zones, ok = geoTimezones[country]
+		}
+		if got := req.Header.Get(h); ok && len(zones) > 0 && !slices.Contains(zones, got) {
+			mismatch(h, got, zones[0])
+		}
+	}
+
+	if fix == nil {
+		return req
+	}
+	r2 := *req
+	r2.Header = req.Header.Clone()
+	if r2.Header == nil {
+		r2.Header = make(Header)
+	}
+	for h, v := range fix {
+		r2.Header.Set(h, v)
+	}
+	return &r2
+}
+
+// languageMatches 报告 Accept-Language 的值 got 是否包含 want 的首选语言，
+// 如 "de-DE,de;q=0.9" 与 "de-AT,de;q=0.9" 一致，与 "en-US,en;q=0.9" 不一致
+func languageMatches(got, want string) bool {
+	primary := func(tag string) string {
+		tag, _, _ = strings.Cut(tag, ";")
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+		return strings.ToLower(tag)
+	}
+	first, _, _ := strings.Cut(want, ",")
+	lang := primary(first)
+	for tag := range strings.SplitSeq(got, ",") {
+		if primary(tag) == lang {
+			return true
+		}
+	}
+	return false
+}
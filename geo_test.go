@@ -0,0 +1,115 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestLanguageMatches 测试 Accept-Language 与国家首选语言的比较
+func TestLanguageMatches(t *testing.T) {
+	tests := []struct {
+		got, want string
+		match     bool
+	}{
+		{"de-DE,de;q=0.9", "de-DE,de;q=0.9,en;q=0.8", true},
+		{"en-US,en;q=0.9,de;q=0.8", "de-DE,de;q=0.9", true},
+		{"DE-at", "de-DE", true},
+		{"en-US,en;q=0.9", "de-DE,de;q=0.9", false},
+		{"", "de-DE", false},
+		{"zh-TW", "zh-CN,zh;q=0.9", true},
+	}
+	for _, tt := range tests {
+		if got := languageMatches(tt.got, tt.want); got != tt.match {
+			t.Errorf("languageMatches(%q, %q) got %v, want %v", tt.got, tt.want, got, tt.match)
+		}
+	}
+}
+
+// TestTransportGeoConsistency 测试按出口国家检查、调整头部和缓存查询结果
+func TestTransportGeoConsistency(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, r.Header.Get("Accept-Language")+"|"+r.Header.Get("X-Timezone"))
+	}))
+	defer ts.Close()
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	lookups := 0
+	var mismatches []string
+	tr := &Transport{
+		Clock: clock,
+		GeoConsistency: &GeoConsistency{
+			Lookup: func(ctx context.Context, proxy *url.URL) (string, error) {
+				if proxy != nil {
+					t.Errorf("直连时 proxy got %v, want nil", proxy)
+				}
+				lookups++
+				return "de", nil
+			},
+			TTL:            time.Minute,
+			TimezoneHeader: "X-Timezone",
+			OnMismatch: func(m *GeoMismatch) {
+				if m.Country != "DE" {
+					t.Errorf("Country got %q, want DE", m.Country)
+				}
+				mismatches = append(mismatches, m.Header+"="+m.Got)
+			},
+		},
+	}
+	defer tr.CloseIdleConnections()
+	get := func(lang, tz string) string {
+		t.Helper()
+		req, _ := NewRequest("GET", ts.URL, nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		req.Header.Set("X-Timezone", tz)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if resp.Request != req {
+			t.Error("resp.Request 不是原请求")
+		}
+		if got := req.Header.Get("Accept-Language"); got != lang {
+			t.Errorf("原请求被修改: got %q, want %q", got, lang)
+		}
+		return string(b)
+	}
+
+	// 只报告不调整
+	if got, want := get("en-US,en;q=0.9", "America/New_York"), "en-US,en;q=0.9|America/New_York"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := get("de-DE,de;q=0.9", "Europe/Berlin"), "de-DE,de;q=0.9|Europe/Berlin"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := []string{"Accept-Language=en-US,en;q=0.9", "X-Timezone=America/New_York"}; !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("OnMismatch got %v, want %v", mismatches, want)
+	}
+	if lookups != 1 {
+		t.Errorf("缓存期内 Lookup 调用了 %d 次, want 1", lookups)
+	}
+
+	// 调整
+	tr.GeoConsistency.Adjust = true
+	if got, want := get("", "UTC"), "de-DE,de;q=0.9,en-US;q=0.8,en;q=0.7|Europe/Berlin"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	clock.Advance(time.Minute)
+	get("de", "Europe/Berlin")
+	if lookups != 2 {
+		t.Errorf("缓存过期后 Lookup 调用了 %d 次, want 2", lookups)
+	}
+}
@@ -5,6 +5,7 @@ go 1.25
 require (
 	github.com/fxamacker/cbor v1.5.1
 	github.com/refraction-networking/utls v1.8.0
+	golang.org/x/crypto v0.42.0
 	golang.org/x/net v0.44.0
 )
 
@@ -13,7 +14,6 @@ require (
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 	github.com/xyproto/randomstring v1.2.0 // indirect
-	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 )
@@ -0,0 +1,81 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// TestHTTP2GoAway 测试收到 GOAWAY 后连接不再复用、未处理的请求在新连接上重试，
+// 以及 GotGoAway 回调
+func TestHTTP2GoAway(t *testing.T) {
+	ts := newH2FrameServer(t)
+	var n atomic.Int32
+	ts.respond = func(fr *http2Framer, h *http2MetaHeadersFrame) {
+		switch n.Add(1) {
+		case 1:
+			// 已收到的流继续处理
+			fr.WriteGoAway(h.StreamID, http2ErrCodeNo, []byte("drain"))
+		case 3:
+			// 未处理的流由客户端重试
+			fr.WriteGoAway(h.StreamID-2, http2ErrCodeNo, []byte("restart"))
+			return
+		}
+		writeTestHeaders(fr, h.StreamID, true)
+	}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+
+	tests := []struct {
+		wantGoAway []httptrace.GoAwayInfo
+		wantReused []bool
+	}{
+		{
+			wantGoAway: []httptrace.GoAwayInfo{{LastStreamID: 1, DebugData: "drain"}},
+			wantReused: []bool{false},
+		},
+		{
+			// 上一个连接已在排空，建立新连接
+			wantReused: []bool{false},
+		},
+		{
+			wantGoAway: []httptrace.GoAwayInfo{{LastStreamID: 1, DebugData: "restart", Retried: true}},
+			wantReused: []bool{true, false},
+		},
+	}
+	for i, tt := range tests {
+		var goAways []httptrace.GoAwayInfo
+		var reused []bool
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn:   func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+			GotGoAway: func(info httptrace.GoAwayInfo) { goAways = append(goAways, info) },
+		})
+		req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("请求 %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if !slices.Equal(goAways, tt.wantGoAway) {
+			t.Errorf("请求 %d: GotGoAway got %+v, want %+v", i, goAways, tt.wantGoAway)
+		}
+		if !slices.Equal(reused, tt.wantReused) {
+			t.Errorf("请求 %d: GotConn Reused got %v, want %v", i, reused, tt.wantReused)
+		}
+	}
+	if got := n.Load(); got != 4 {
+		t.Errorf("服务端收到 %d 个请求, want 4", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// debugGoroutines is set by goroutines_debug.go when the tlshttpdebug
+// build tag is set. When false, readLoop/writeLoop goroutines are not
+// counted and DebugGoroutines always reports zero.
+var debugGoroutines bool
+
+// GoroutineStats 描述 Transport 当前存活的连接 goroutine 数量
+// 每个 HTTP/1 连接各持有一个 readLoop 和一个 writeLoop
+type GoroutineStats struct {
+	ReadLoops  int64 // 存活的 persistConn.readLoop 数量
+	WriteLoops int64 // 存活的 persistConn.writeLoop 数量
+}
+
+// Total 返回存活 goroutine 的总数
+func (s GoroutineStats) Total() int64 {
+	return s.ReadLoops + s.WriteLoops
+}
+
+// DebugGoroutines 返回 t 当前存活的 readLoop/writeLoop goroutine 数量
+//
+// 统计仅在使用 -tags tlshttpdebug 构建时启用，否则始终返回零值，
+// 不会给正常构建带来任何开销。
+func (t *Transport) DebugGoroutines() GoroutineStats {
+	return GoroutineStats{
+		ReadLoops:  t.liveReadLoops.Load(),
+		WriteLoops: t.liveWriteLoops.Load(),
+	}
+}
+
+// GoroutineDebugEnabled 报告当前构建是否启用了 goroutine 统计
+func GoroutineDebugEnabled() bool {
+	return debugGoroutines
+}
+
+// GoroutineLeakError 在连接 goroutine 未能按时退出时返回
+type GoroutineLeakError struct {
+	Stats GoroutineStats // 超时时仍存活的 goroutine 数量
+}
+
+func (e *GoroutineLeakError) Error() string {
+	return fmt.Sprintf("goroutine 泄漏: readLoop=%d writeLoop=%d", e.Stats.ReadLoops, e.Stats.WriteLoops)
+}
+
+// WaitGoroutinesDrained 关闭空闲连接并等待 t 的所有 readLoop/writeLoop 退出
+//
+// 这是给混沌测试和泄漏检测使用的辅助方法：在所有响应体关闭后调用，
+// 若 ctx 结束时仍有存活的 goroutine，返回 *GoroutineLeakError。
+// 未启用 tlshttpdebug 构建标签时立即返回 nil。
+func (t *Transport) WaitGoroutinesDrained(ctx context.Context) error {
+	if !debugGoroutines {
+		return nil
+	}
+	t.CloseIdleConnections()
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		stats := t.DebugGoroutines()
+		if stats.Total() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return &GoroutineLeakError{Stats: stats}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build tlshttpdebug
+
+package http
+
+func init() {
+	debugGoroutines = true
+}
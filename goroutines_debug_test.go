@@ -0,0 +1,103 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build tlshttpdebug
+
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newLeakTestServer 启动一个本地 HTTP/1 服务器，返回其地址和关闭函数
+func newLeakTestServer(t *testing.T, h Handler) (string, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	srv := &Server{Handler: h}
+	go srv.Serve(ln)
+	return "http://" + ln.Addr().String(), func() { srv.Close() }
+}
+
+// waitDrained 等待 tr 的连接 goroutine 全部退出，超时视为泄漏
+func waitDrained(t *testing.T, tr *Transport) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tr.WaitGoroutinesDrained(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGoroutinesDrainAfterKeepAlive 测试长连接关闭后 goroutine 全部退出
+func TestGoroutinesDrainAfterKeepAlive(t *testing.T) {
+	url, closeSrv := newLeakTestServer(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer closeSrv()
+
+	tr := &Transport{}
+	client := &Client{Transport: tr}
+	for i := 0; i < 10; i++ {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if got := tr.DebugGoroutines(); got.ReadLoops != 1 || got.WriteLoops != 1 {
+		t.Errorf("空闲连接 goroutine = %+v, want 1/1", got)
+	}
+	waitDrained(t, tr)
+}
+
+// TestGoroutinesDrainUnderChaos 测试并发请求中途取消后 goroutine 不泄漏
+func TestGoroutinesDrainUnderChaos(t *testing.T) {
+	url, closeSrv := newLeakTestServer(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.(Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Duration(len(r.URL.RawQuery)) * time.Millisecond):
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer closeSrv()
+
+	tr := &Transport{MaxIdleConnsPerHost: 4}
+	client := &Client{Transport: tr}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%4)*time.Millisecond)
+			defer cancel()
+			req, err := NewRequestWithContext(ctx, "GET", url+"?"+strings.Repeat("a", i%8), nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			if i%2 == 0 {
+				io.Copy(io.Discard, resp.Body)
+			}
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+	waitDrained(t, tr)
+}
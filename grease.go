@@ -0,0 +1,228 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// GREASEMode 决定指纹连接的 GREASE 值如何选择，见 GREASEConfig
+type GREASEMode int
+
+const (
+	// GREASERandom 为每个连接随机选择 GREASE 值，与 BoringSSL 和 utls 相同
+	GREASERandom GREASEMode = iota
+
+	// GREASESeeded 从以 GREASEConfig.Seed 为种子的伪随机序列中选择，
+	// 相同种子的 Transport 依次建立的连接使用相同的值
+	GREASESeeded
+
+	// GREASEFixed 总是使用 GREASEConfig.Values 中的值
+	GREASEFixed
+)
+
+// GREASEPosition 是 ClientHello 中使用 GREASE 值的位置
+type GREASEPosition int
+
+const (
+	GREASECipher     GREASEPosition = iota // 密码套件列表
+	GREASEGroup                            // supported_groups 和 key_share 扩展，两者相同
+	GREASEVersion                          // supported_versions 扩展
+	GREASEExtension1                       // 第一个 GREASE 扩展
+	GREASEExtension2                       // 第二个 GREASE 扩展，与第一个总是不同
+	numGREASEPositions
+)
+
+// GREASEPlacement 决定 GREASE 值在 ClientHello 中的位置
+type GREASEPlacement int
+
+const (
+	// GREASEPlacementDefault 保持指纹中的位置
+	GREASEPlacementDefault GREASEPlacement = iota
+
+	// GREASEPlacementBoringSSL 与 BoringSSL 相同：列表中的 GREASE 值在开头，
+	// 第一个 GREASE 扩展在最前，第二个在最后 (padding 和 pre_shared_key 之前)
+	GREASEPlacementBoringSSL
+)
+
+// GREASEConfig 控制指纹连接 ClientHello 中 GREASE (RFC 8701) 值的选择和位置，
+// 见 Transport.GREASE。只影响指纹中已有的 GREASE 值，不添加或删除它们
+type GREASEConfig struct {
+	// Mode 决定 GREASE 值如何选择
+	Mode GREASEMode
+
+	// Seed 是 GREASESeeded 的种子
+	Seed int64
+
+	// Values 是 GREASEFixed 各位置使用的值，必须是 0x?a?a 形式的 GREASE 值，
+	// 没有给出的位置使用 0x0a0a，GREASEExtension2 与 GREASEExtension1 相同时
+	// 使用 GREASEExtension1 异或 0x1010 的值
+	Values map[GREASEPosition]uint16
+
+	// Distinct 为 true 时同一个 ClientHello 中各位置的 GREASE 值互不相同。
+	// BoringSSL 只保证两个 GREASE 扩展不同，其余位置可能重复
+	Distinct bool
+
+	// Placement 决定 GREASE 值在各列表和扩展中的位置
+	Placement GREASEPlacement
+}
+
+// greaseValue 返回随机字节 b 对应的 GREASE 值，与 BoringSSL 的计算相同
+func greaseValue(b byte) uint16 {
+	v := uint16(b&0xf0) | 0x0a
+	return v<<8 | v
+}
+
+// greaseValues 返回本次连接各位置的 GREASE 值，不需要改变 utls 选择的值时返回 nil。
+// r 是 GREASERandom 使用的随机数来源
+func (t *Transport) greaseValues(r io.Reader) (*[numGREASEPositions]uint16, error) {
+	g := t.GREASE
+	if g == nil || g.Mode == GREASERandom && !g.Distinct {
+		return nil, nil
+	}
+	var vals [numGREASEPositions]uint16
+	if g.Mode == GREASEFixed {
+		for p := range numGREASEPositions {
+			v, ok := g.Values[p]
+			if !ok {
+				v = 0x0a0a
+			} else if !isGREASEValue(v) {
+				return nil, fmt.Errorf("GREASEConfig.Values[%d]: 0x%04x 不是 GREASE 值", p, v)
+			}
+			vals[p] = v
+		}
+		if vals[GREASEExtension2] == vals[GREASEExtension1] {
+			vals[GREASEExtension2] ^= 0x1010
+		}
+		return &vals, nil
+	}
+
+	if g.Mode == GREASESeeded {
+		var err error
+		t.greaseRand.do(g.Seed, func(r *mrand.Rand) {
+			err = pickGREASEValues(&vals, r, g.Distinct)
+		})
+		return &vals, err
+	}
+	if r == nil {
+		r = rand.Reader
+	}
+	return &vals, pickGREASEValues(&vals, r, g.Distinct)
+}
+
+// pickGREASEValues 从 r 中依次取随机字节填充 vals，跳过两个 GREASE 扩展相同的值，
+// distinct 为 true 时跳过所有重复的值
+func pickGREASEValues(vals *[numGREASEPositions]uint16, r io.Reader, distinct bool) error {
+	var b [1]byte
+	for p := range numGREASEPositions {
+		for {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return err
+			}
+			v := greaseValue(b[0])
+			if distinct && slices.Contains(vals[:p], v) || p == GREASEExtension2 && v == vals[GREASEExtension1] {
+				continue
+			}
+			vals[p] = v
+			break
+		}
+	}
+	return nil
+}
+
+// applyGREASEValues 在 ApplyPreset 之后用 vals 替换 uconn 中 utls 选择的 GREASE 值
+func applyGREASEValues(uconn *tls.UConn, vals *[numGREASEPositions]uint16) {
+	if vals == nil {
+		return
+	}
+	hello := uconn.HandshakeState.Hello
+	for i, c := range hello.CipherSuites {
+		if isGREASEValue(c) {
+			hello.CipherSuites[i] = vals[GREASECipher]
+		}
+	}
+	seen := 0
+	for _, e := range uconn.Extensions {
+		switch e := e.(type) {
+		case *tls.UtlsGREASEExtension:
+			if seen < 2 {
+				e.Value = vals[GREASEExtension1+GREASEPosition(seen)]
+			}
+			seen++
+		case *tls.SupportedCurvesExtension:
+			for i, g := range e.Curves {
+				if isGREASEValue(uint16(g)) {
+					e.Curves[i] = tls.CurveID(vals[GREASEGroup])
+				}
+			}
+		case *tls.KeyShareExtension:
+			for i, s := range e.KeyShares {
+				if isGREASEValue(uint16(s.Group)) {
+					e.KeyShares[i].Group = tls.CurveID(vals[GREASEGroup])
+				}
+			}
+		case *tls.SupportedVersionsExtension:
+			for i, v := range e.Versions {
+				if isGREASEValue(v) {
+					e.Versions[i] = vals[GREASEVersion]
+				}
+			}
+		}
+	}
+}
+
+// placeGREASE 按 t.GREASE.Placement 调整 spec 中 GREASE 值的位置
+func (t *Transport) placeGREASE(spec *tls.ClientHelloSpec) {
+	if t.GREASE == nil || t.GREASE.Placement != GREASEPlacementBoringSSL {
+		return
+	}
+	spec.CipherSuites = greaseFirst(spec.CipherSuites, func(c uint16) bool { return isGREASEValue(c) })
+	var greaseExts, rest, tail []tls.TLSExtension
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.UtlsGREASEExtension:
+			greaseExts = append(greaseExts, e)
+			continue
+		case *tls.SupportedCurvesExtension:
+			e.Curves = greaseFirst(e.Curves, func(g tls.CurveID) bool { return isGREASEValue(uint16(g)) })
+		case *tls.KeyShareExtension:
+			e.KeyShares = greaseFirst(e.KeyShares, func(s tls.KeyShare) bool { return isGREASEValue(uint16(s.Group)) })
+		case *tls.SupportedVersionsExtension:
+			e.Versions = greaseFirst(e.Versions, func(v uint16) bool { return isGREASEValue(v) })
+		case *tls.UtlsPaddingExtension, tls.PreSharedKeyExtension:
+			tail = append(tail, e)
+			continue
+		}
+		rest = append(rest, e)
+	}
+	if len(greaseExts) == 0 {
+		return
+	}
+	exts := append([]tls.TLSExtension{greaseExts[0]}, rest...)
+	exts = append(exts, greaseExts[1:]...)
+	spec.Extensions = append(exts, tail...)
+}
+
+// greaseFirst 返回把 s 中满足 isGREASE 的元素移到开头的副本
+func greaseFirst[T any](s []T, isGREASE func(T) bool) []T {
+	out := make([]T, 0, len(s))
+	for _, v := range s {
+		if isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	for _, v := range s {
+		if !isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+const greaseTestUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"
+
+// greaseInHello 返回 tr 生成的 ClientHello 中各位置的 GREASE 值 (supported_versions
+// 中没有 GREASE 时为 0)，以及 GREASE 密码套件和第一个 GREASE 扩展的下标
+func greaseInHello(t *testing.T, tr *Transport) (vals [numGREASEPositions]uint16, cipherIdx, extIdx int) {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc, err := tr.NewUConn(c1, &tls.Config{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseClientHello(uc.HandshakeState.Hello.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherIdx = slices.IndexFunc(h.cipherSuites, isGREASEValue)
+	vals[GREASECipher] = h.cipherSuites[cipherIdx]
+	extIdx = slices.IndexFunc(h.extensions, isGREASEValue)
+	var exts []uint16
+	for _, e := range h.extensions {
+		if isGREASEValue(e) {
+			exts = append(exts, e)
+		}
+	}
+	vals[GREASEExtension1], vals[GREASEExtension2] = exts[0], exts[1]
+	vals[GREASEGroup] = h.supportedGroups[slices.IndexFunc(h.supportedGroups, isGREASEValue)]
+	for _, e := range uc.Extensions {
+		if sv, ok := e.(*tls.SupportedVersionsExtension); ok {
+			if i := slices.IndexFunc(sv.Versions, isGREASEValue); i >= 0 {
+				vals[GREASEVersion] = sv.Versions[i]
+			}
+		}
+		if ks, ok := e.(*tls.KeyShareExtension); ok {
+			for _, s := range ks.KeyShares {
+				if isGREASEValue(uint16(s.Group)) && s.Group != tls.CurveID(vals[GREASEGroup]) {
+					t.Errorf("key_share 的 GREASE 组 0x%04x 与 supported_groups 的 0x%04x 不同", s.Group, vals[GREASEGroup])
+				}
+			}
+		}
+	}
+	return vals, cipherIdx, extIdx
+}
+
+func newGREASETransport(g *GREASEConfig) *Transport {
+	return &Transport{
+		JA3:           "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		UserAgent:     greaseTestUA,
+		TLSExtensions: &TLSExtensionsConfig{},
+		GREASE:        g,
+	}
+}
+
+func TestGREASEFixed(t *testing.T) {
+	tr := newGREASETransport(&GREASEConfig{
+		Mode: GREASEFixed,
+		Values: map[GREASEPosition]uint16{
+			GREASECipher:     0x1a1a,
+			GREASEGroup:      0x2a2a,
+			GREASEExtension1: 0x4a4a,
+			GREASEExtension2: 0x4a4a,
+		},
+	})
+	got, _, _ := greaseInHello(t, tr)
+	// JA3 生成的 supported_versions 没有 GREASE
+	want := [numGREASEPositions]uint16{0x1a1a, 0x2a2a, 0, 0x4a4a, 0x5a5a}
+	if got != want {
+		t.Errorf("got %04x, want %04x", got, want)
+	}
+
+	// 固定值的 ClientHello 可以完成握手
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	tr.ForceAttemptHTTP2 = true
+	defer tr.CloseIdleConnections()
+	req, _ := NewRequest("GET", ts.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	tr = newGREASETransport(&GREASEConfig{Mode: GREASEFixed, Values: map[GREASEPosition]uint16{GREASECipher: 0x1234}})
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	if _, err := tr.NewUConn(c1, &tls.Config{ServerName: "example.com"}); err == nil {
+		t.Error("非 GREASE 值: 期望错误")
+	}
+}
+
+func TestGREASESeeded(t *testing.T) {
+	seq := func(seed int64) [][numGREASEPositions]uint16 {
+		tr := newGREASETransport(&GREASEConfig{Mode: GREASESeeded, Seed: seed})
+		var out [][numGREASEPositions]uint16
+		for range 3 {
+			v, _, _ := greaseInHello(t, tr)
+			if v[GREASEExtension1] == v[GREASEExtension2] {
+				t.Errorf("两个 GREASE 扩展相同: %04x", v)
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+	a, b := seq(42), seq(42)
+	if !slices.Equal(a, b) {
+		t.Errorf("相同种子 got %04x 和 %04x", a, b)
+	}
+	if slices.Equal(a, seq(43)) {
+		t.Error("不同种子生成了相同的序列")
+	}
+}
+
+func TestGREASEDistinct(t *testing.T) {
+	tr := newGREASETransport(&GREASEConfig{Distinct: true})
+	tr.ClientHelloID = tls.HelloChrome_120
+	for range 20 {
+		v, _, _ := greaseInHello(t, tr)
+		s := slices.Clone(v[:])
+		slices.Sort(s)
+		if len(slices.Compact(s)) != len(v) {
+			t.Fatalf("GREASE 值重复: %04x", v)
+		}
+	}
+}
+
+func TestGREASEPlacement(t *testing.T) {
+	spec := &tls.ClientHelloSpec{
+		CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256, tls.GREASE_PLACEHOLDER},
+		Extensions: []tls.TLSExtension{
+			&tls.SNIExtension{},
+			&tls.UtlsGREASEExtension{},
+			&tls.SupportedCurvesExtension{Curves: []tls.CurveID{tls.X25519, tls.GREASE_PLACEHOLDER}},
+			&tls.UtlsGREASEExtension{},
+			&tls.SupportedVersionsExtension{Versions: []uint16{tls.VersionTLS13, tls.GREASE_PLACEHOLDER}},
+			&tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: tls.X25519}, {Group: tls.GREASE_PLACEHOLDER, Data: []byte{0}}}},
+			&tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle},
+		},
+	}
+	tr := &Transport{ClientHelloSpec: spec, GREASE: &GREASEConfig{Placement: GREASEPlacementBoringSSL}}
+	vals, cipherIdx, extIdx := greaseInHello(t, tr)
+	if cipherIdx != 0 || extIdx != 0 {
+		t.Errorf("GREASE 密码套件位置 %d，GREASE 扩展位置 %d，want 0", cipherIdx, extIdx)
+	}
+	got, err := tr.BuildSpec("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(got.Extensions)
+	if _, ok := got.Extensions[n-2].(*tls.UtlsGREASEExtension); !ok {
+		t.Errorf("倒数第二个扩展 got %T, want GREASE", got.Extensions[n-2])
+	}
+	if _, ok := got.Extensions[n-1].(*tls.UtlsPaddingExtension); !ok {
+		t.Errorf("最后一个扩展 got %T, want padding", got.Extensions[n-1])
+	}
+	if vals[GREASEVersion] == 0 {
+		t.Error("没有 GREASE 版本")
+	}
+}
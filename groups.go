@@ -0,0 +1,121 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// applyGroupsPolicy 让 supported_groups (10) 与 key_share (51) 扩展的组保持一致
+//
+// 浏览器声明的组通常多于实际生成密钥的组，因此两者分别配置：
+// override.SupportedGroups 替换 10 扩展的组，override.KeyShareCurves
+// 替换 51 扩展的密钥共享，且其中的组必须都出现在 supported_groups 中，
+// 否则返回错误。未覆盖 key_share 时，默认的密钥共享只保留
+// supported_groups 中存在的组，都不存在时使用第一个非 GREASE 组，
+// 并为 supported_groups 中的后量子混合组加入密钥共享。
+func applyGroupsPolicy(exts []tls.TLSExtension, override *TLSExtensionsConfig) error {
+	var sc *tls.SupportedCurvesExtension
+	var ks *tls.KeyShareExtension
+	for _, e := range exts {
+		switch e := e.(type) {
+		case *tls.SupportedCurvesExtension:
+			sc = e
+		case *tls.KeyShareExtension:
+			ks = e
+		}
+	}
+	if override != nil && override.SupportedGroups != nil && sc != nil {
+		sc.Curves = slices.Clone(override.SupportedGroups.Curves)
+	}
+	if ks == nil {
+		return nil
+	}
+	if override != nil && override.KeyShareCurves != nil {
+		// 复制覆盖配置，避免多个连接共享同一个扩展实例
+		shares := make([]tls.KeyShare, 0, len(override.KeyShareCurves.KeyShares))
+		for _, s := range override.KeyShareCurves.KeyShares {
+			var data []byte
+			if isGREASEValue(uint16(s.Group)) {
+				data = []byte{0}
+			}
+			shares = append(shares, tls.KeyShare{Group: s.Group, Data: data})
+		}
+		ks.KeyShares = shares
+		if sc == nil {
+			return nil
+		}
+		return checkKeyShareGroups(sc.Curves, ks.KeyShares)
+	}
+	if sc == nil {
+		return nil
+	}
+	ks.KeyShares = hybridKeyShares(sc.Curves, defaultKeyShares(sc.Curves, ks.KeyShares))
+	return nil
+}
+
+// checkKeyShareGroups 校验 shares 中的组都出现在 groups 中
+// GREASE 组只要求 groups 中也有 GREASE 值
+func checkKeyShareGroups(groups []tls.CurveID, shares []tls.KeyShare) error {
+	for _, s := range shares {
+		if !hasGroup(groups, s.Group) {
+			return fmt.Errorf("key_share 组 %v 不在 supported_groups 中", s.Group)
+		}
+	}
+	return nil
+}
+
+// defaultKeyShares 从默认的 shares 中去掉 groups 没有声明的组
+func defaultKeyShares(groups []tls.CurveID, shares []tls.KeyShare) []tls.KeyShare {
+	var kept []tls.KeyShare
+	for _, s := range shares {
+		if hasGroup(groups, s.Group) {
+			kept = append(kept, s)
+		}
+	}
+	for _, s := range kept {
+		if !isGREASEValue(uint16(s.Group)) {
+			return kept
+		}
+	}
+	for _, g := range groups {
+		if !isGREASEValue(uint16(g)) {
+			return append(kept, tls.KeyShare{Group: g})
+		}
+	}
+	return kept
+}
+
+func hasGroup(groups []tls.CurveID, g tls.CurveID) bool {
+	if isGREASEValue(uint16(g)) {
+		return slices.ContainsFunc(groups, func(c tls.CurveID) bool { return isGREASEValue(uint16(c)) })
+	}
+	return slices.Contains(groups, g)
+}
+
+// hybridKeyShares 在 groups 声明了后量子混合组 (X25519MLKEM768 或
+// X25519Kyber768Draft00) 时，为其在 shares 中的 X25519 之前加入密钥共享
+//
+// 与 Chrome 124 及之后的版本一致：混合组和 X25519 各发送一个密钥共享，
+// 不支持混合组的服务端仍可以直接选择 X25519，不会触发 HelloRetryRequest。
+func hybridKeyShares(groups []tls.CurveID, shares []tls.KeyShare) []tls.KeyShare {
+	for _, g := range groups {
+		if g != tls.X25519MLKEM768 && g != tls.X25519Kyber768Draft00 {
+			continue
+		}
+		if slices.ContainsFunc(shares, func(s tls.KeyShare) bool { return s.Group == g }) {
+			return shares
+		}
+		i := slices.IndexFunc(shares, func(s tls.KeyShare) bool { return s.Group == tls.X25519 })
+		if i < 0 {
+			i = len(shares)
+		}
+		return slices.Insert(slices.Clone(shares), i, tls.KeyShare{Group: g})
+	}
+	return shares
+}
@@ -43,6 +43,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -770,12 +771,13 @@ type http2clientConnPool struct {
 	t *HTTP2Transport
 
 	mu sync.Mutex // TODO: maybe switch to RWMutex
-	// TODO: add support for sharing conns based on cert names
-	// (e.g. share conn for googleapis.com and appspot.com)
-	conns        map[string][]*http2ClientConn // key is host:port
+	// Conns are shared between hosts based on cert names when the
+	// Transport's HTTP2Coalescing allows it; see coalesce.
+	conns        map[string][]*http2ClientConn // key is host:port plus connPartition.String
 	dialing      map[string]*http2dialCall     // currently in-flight dials
 	keys         map[*http2ClientConn][]string
 	addConnCalls map[string]*http2addConnCall // in-flight addConnIfNeeded calls
+	next         map[string]int               // next conn index for ConnSelectionRoundRobin
 }
 
 func (p *http2clientConnPool) GetClientConn(req *Request, addr string) (*http2ClientConn, error) {
@@ -801,7 +803,7 @@ func (p *http2clientConnPool) getClientConn(req *Request, addr string, dialOnMis
 	}
 	for {
 		p.mu.Lock()
-		for _, cc := range p.conns[addr] {
+		for _, cc := range p.selectLocked(addr) {
 			if cc.ReserveNewRequest() {
 				// When a connection is presented to us by the github.com/vanling1111/tlshttp package,
 				// the GetConn hook has already been called.
@@ -814,6 +816,14 @@ func (p *http2clientConnPool) getClientConn(req *Request, addr string, dialOnMis
 				return cc, nil
 			}
 		}
+		if cands := p.coalesceCandidatesLocked(addr); len(cands) > 0 {
+			p.mu.Unlock()
+			if cc := p.coalesce(req, addr, cands); cc != nil {
+				http2traceGetConn(req, addr)
+				return cc, nil
+			}
+			p.mu.Lock()
+		}
 		if !dialOnMiss {
 			p.mu.Unlock()
 			return nil, http2ErrNoCachedConn
@@ -835,6 +845,98 @@ func (p *http2clientConnPool) getClientConn(req *Request, addr string, dialOnMis
 	}
 }
 
+// selectLocked returns the conns for addr in the order they should be
+// tried under the Transport's ConnSelection policy.
+// p.mu must be held.
+func (p *http2clientConnPool) selectLocked(addr string) []*http2ClientConn {
+	conns := p.conns[addr]
+	if len(conns) < 2 || p.t.t1 == nil {
+		return conns
+	}
+	switch p.t.t1.ConnSelection {
+	case ConnSelectionLRU:
+		lastActive := make(map[*http2ClientConn]time.Time, len(conns))
+		for _, cc := range conns {
+			cc.mu.Lock()
+			lastActive[cc] = cc.lastActive
+			cc.mu.Unlock()
+		}
+		conns = append([]*http2ClientConn(nil), conns...)
+		sort.SliceStable(conns, func(i, j int) bool {
+			return lastActive[conns[i]].Before(lastActive[conns[j]])
+		})
+	case ConnSelectionRoundRobin:
+		if p.next == nil {
+			p.next = make(map[string]int)
+		}
+		i := p.next[addr] % len(conns)
+		p.next[addr] = i + 1
+		conns = append(append([]*http2ClientConn(nil), conns[i:]...), conns[:i]...)
+	case ConnSelectionLeastLoaded:
+		load := make(map[*http2ClientConn]int, len(conns))
+		for _, cc := range conns {
+			cc.mu.Lock()
+			load[cc] = len(cc.streams) + cc.streamsReserved
+			cc.mu.Unlock()
+		}
+		conns = append([]*http2ClientConn(nil), conns...)
+		sort.SliceStable(conns, func(i, j int) bool {
+			return load[conns[i]] < load[conns[j]]
+		})
+	}
+	return conns
+}
+
+// coalesceCandidatesLocked returns the conns to other hosts that a
+// request for addr could coalesce onto under the Transport's
+// HTTP2Coalescing policy: same port and partition, not proxied.
+// p.mu must be held.
+func (p *http2clientConnPool) coalesceCandidatesLocked(addr string) []*http2ClientConn {
+	if p.t.t1 == nil || p.t.t1.HTTP2Coalescing == CoalescingOff {
+		return nil
+	}
+	hostPort, part := splitPartition(addr)
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || part.proxy != "" {
+		return nil
+	}
+	var cands []*http2ClientConn
+	for key, conns := range p.conns {
+		kHostPort, kPart := splitPartition(key)
+		kHost, kPort, err := net.SplitHostPort(kHostPort)
+		if err != nil || kPart != part || kPort != port || kHost == host {
+			continue
+		}
+		for _, cc := range conns {
+			if !slices.Contains(cands, cc) {
+				cands = append(cands, cc)
+			}
+		}
+	}
+	return cands
+}
+
+// coalesce returns the first of cands that can serve req and has
+// reserved a stream for it, and adds it to the pool under addr so
+// later requests find it directly. It returns nil if none can.
+func (p *http2clientConnPool) coalesce(req *Request, addr string, cands []*http2ClientConn) *http2ClientConn {
+	hostPort, _ := splitPartition(addr)
+	host, _, _ := net.SplitHostPort(hostPort)
+	lookup := &coalesceLookup{host: host, lookup: lookupIPAddr}
+	for _, cc := range cands {
+		if !p.t.t1.canCoalesce(req.Context(), cc, hostPort, lookup) || !cc.ReserveNewRequest() {
+			continue
+		}
+		p.mu.Lock()
+		if _, ok := p.keys[cc]; ok {
+			p.addConnLocked(addr, cc)
+		}
+		p.mu.Unlock()
+		return cc
+	}
+	return nil
+}
+
 // dialCall is an in-flight Transport dial call to a host.
 type http2dialCall struct {
 	_ http2incomparable
@@ -952,6 +1054,12 @@ func (p *http2clientConnPool) addConnLocked(key string, cc *http2ClientConn) {
 	}
 	p.conns[key] = append(p.conns[key], cc)
 	p.keys[cc] = append(p.keys[cc], key)
+	host, _ := splitPartition(key)
+	cc.mu.Lock()
+	if !slices.Contains(cc.hosts, host) {
+		cc.hosts = append(cc.hosts, host)
+	}
+	cc.mu.Unlock()
 }
 
 func (p *http2clientConnPool) MarkDead(cc *http2ClientConn) {
@@ -988,6 +1096,101 @@ func (p *http2clientConnPool) closeIdleConnections() {
 	}
 }
 
+// closeIdleConnectionsForAddr closes the idle connections to addr,
+// in all partitions.
+func (p *http2clientConnPool) closeIdleConnectionsForAddr(addr string) {
+	p.closeIdleConnectionsMatching(func(a string, _ connPartition) bool { return a == addr })
+}
+
+// closeIdleConnectionsMatching closes the idle connections whose pool
+// key, split into address and partition, satisfies match.
+func (p *http2clientConnPool) closeIdleConnectionsMatching(match func(addr string, part connPartition) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, vv := range p.conns {
+		if !match(splitPartition(key)) {
+			continue
+		}
+		for _, cc := range vv {
+			cc.closeIfIdle()
+		}
+	}
+}
+
+// trimIdle closes the least recently used idle connections beyond the
+// Transport's MaxIdleConnsPerHost and MaxIdleConns limits. If cc is
+// non-nil, it is the connection that just became idle and only its
+// hosts are checked against the per-host limit; otherwise all hosts are.
+func (p *http2clientConnPool) trimIdle(cc *http2ClientConn) {
+	perHost, total := p.t.maxIdleConnsPerHost(), p.t.maxIdleConns()
+	if perHost == 0 && total <= 0 {
+		return
+	}
+	p.mu.Lock()
+	if n := len(p.keys); (perHost == 0 || perHost > 0 && n <= perHost) && (total <= 0 || n <= total) {
+		p.mu.Unlock()
+		return
+	}
+	victims := make(map[*http2ClientConn]bool)
+	byRecency := func(conns []*http2ClientConn, lastActive map[*http2ClientConn]time.Time) {
+		sort.SliceStable(conns, func(i, j int) bool {
+			return lastActive[conns[i]].After(lastActive[conns[j]])
+		})
+	}
+	idleConns := func(conns []*http2ClientConn, lastActive map[*http2ClientConn]time.Time) []*http2ClientConn {
+		var idle []*http2ClientConn
+		for _, cc := range conns {
+			if t, ok := cc.idleSince(); ok {
+				lastActive[cc] = t
+				idle = append(idle, cc)
+			}
+		}
+		return idle
+	}
+	if perHost != 0 {
+		var keys []string
+		if cc != nil {
+			keys = p.keys[cc]
+		} else {
+			for key := range p.conns {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			vv := p.conns[key]
+			if perHost > 0 && len(vv) <= perHost {
+				continue
+			}
+			lastActive := make(map[*http2ClientConn]time.Time)
+			idle := idleConns(vv, lastActive)
+			byRecency(idle, lastActive)
+			for _, cc := range idle[min(max(perHost, 0), len(idle)):] {
+				victims[cc] = true
+			}
+		}
+	}
+	if total > 0 && len(p.keys)-len(victims) > total {
+		lastActive := make(map[*http2ClientConn]time.Time)
+		var kept []*http2ClientConn
+		for cc := range p.keys {
+			if !victims[cc] {
+				kept = append(kept, cc)
+			}
+		}
+		kept = idleConns(kept, lastActive)
+		if len(kept) > total {
+			byRecency(kept, lastActive)
+			for _, cc := range kept[total:] {
+				victims[cc] = true
+			}
+		}
+	}
+	p.mu.Unlock()
+	for cc := range victims {
+		cc.closeIfIdle()
+	}
+}
+
 func http2filterOutClientConn(in []*http2ClientConn, exclude *http2ClientConn) []*http2ClientConn {
 	out := in[:0]
 	for _, v := range in {
@@ -7204,6 +7407,11 @@ type HTTP2Settings struct {
 	ConnectionFlow int
 	HeaderPriority *HTTP2PriorityParam
 	PriorityFrames []HTTP2PriorityFrame
+
+	// PseudoHeaderOrder is the order of the request pseudo-header
+	// fields (":method", ":authority", ":scheme", ":path") for
+	// requests that do not set PHeaderOrderKey.
+	PseudoHeaderOrder []string
 }
 
 func (http2Settings *HTTP2Settings) Clone() (*HTTP2Settings, error) {
@@ -7312,6 +7520,22 @@ type HTTP2Transport struct {
 	// Zero means no limit.
 	IdleConnTimeout time.Duration
 
+	// MaxIdleConns controls the maximum number of idle connections
+	// (those with no active streams) across all hosts. Zero means to
+	// use the HTTP/1 Transport's MaxIdleConns when this Transport was
+	// configured from one, and no limit otherwise. Idle HTTP/2
+	// connections are counted separately from HTTP/1 ones.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost, if non-zero, controls the maximum number of
+	// idle connections to keep per host. Zero means to use the HTTP/1
+	// Transport's MaxIdleConnsPerHost when this Transport was configured
+	// from one, and no limit otherwise. Unlike HTTP/1, an unset limit is
+	// not replaced by DefaultMaxIdleConnsPerHost: each HTTP/2 connection
+	// multiplexes many requests, so idle ones are not capped by default.
+	// Negative means no idle connections are kept.
+	MaxIdleConnsPerHost int
+
 	// ReadIdleTimeout is the timeout after which a health check using ping
 	// frame will be carried out if no frame is received on the connection.
 	// Note that a ping response will is considered a received frame, so if
@@ -7350,6 +7574,18 @@ type HTTP2Transport struct {
 	HTTP2Settings *HTTP2Settings
 }
 
+// http2Settings returns the custom settings of t, falling back to
+// those of the HTTP/1 Transport using it.
+func (t *http2Transport) http2Settings() *HTTP2Settings {
+	if t.HTTP2Settings != nil {
+		return t.HTTP2Settings
+	}
+	if t.t1 != nil {
+		return t.t1.HTTP2Settings
+	}
+	return nil
+}
+
 // Hook points used for testing.
 // Outside of tests, t.transportTestHooks is nil and these all have minimal implementations.
 // Inside tests, see the testSyncHooks function docs.
@@ -7365,11 +7601,22 @@ func (t *HTTP2Transport) markNewGoroutine() {
 	}
 }
 
+// now returns the current time from t1.Clock, or the system clock.
+func (t *HTTP2Transport) now() time.Time {
+	if t.t1 != nil && t.t1.Clock != nil {
+		return t.t1.Clock.Now()
+	}
+	return time.Now()
+}
+
 // newTimer creates a new time.Timer, or a synthetic timer in tests.
 func (t *HTTP2Transport) newTimer(d time.Duration) http2timer {
 	if t.http2transportTestHooks != nil {
 		return t.http2transportTestHooks.group.NewTimer(d)
 	}
+	if t.t1 != nil && t.t1.Clock != nil {
+		return t.t1.Clock.NewTimer(d)
+	}
 	return http2timeTimer{time.NewTimer(d)}
 }
 
@@ -7378,6 +7625,9 @@ func (t *HTTP2Transport) afterFunc(d time.Duration, f func()) http2timer {
 	if t.http2transportTestHooks != nil {
 		return t.http2transportTestHooks.group.AfterFunc(d, f)
 	}
+	if t.t1 != nil && t.t1.Clock != nil {
+		return t.t1.Clock.AfterFunc(d, f)
+	}
 	return http2timeTimer{time.AfterFunc(d, f)}
 }
 
@@ -7473,6 +7723,9 @@ func HTTP2ConfigureTransports(t1 *Transport) (*HTTP2Transport, error) {
 		}
 
 		addr := http2authorityAddr("https", authority)
+		if id, ok := t1.connIdentities.Load(conn); ok {
+			addr += id.(*connIdentity).partition.String()
+		}
 		if used, err := connPool.addConnIfNeeded(addr, t2, conn); err != nil {
 			go conn.Close()
 			return http2erringRoundTripper{err}
@@ -7517,6 +7770,7 @@ type http2ClientConn struct {
 	reused        uint32               // whether conn is being reused; atomic
 	singleUse     bool                 // whether being used for a single http.Request
 	getConnCalled bool                 // used by clientConnPool
+	identity      *connIdentity        // pool identity from t1, reported via GotConnInfo; may be nil
 
 	// readLoop goroutine fields:
 	readerDone chan struct{} // closed on error
@@ -7536,6 +7790,9 @@ type http2ClientConn struct {
 	wantSettingsAck bool                          // we sent a SETTINGS frame and haven't heard back
 	goAway          *http2GoAwayFrame             // if non-nil, the GoAwayFrame we received
 	goAwayDebug     string                        // goAway frame's debug data, retained as a string
+	origins         []string                      // origin set from ORIGIN frames (RFC 8336), in canonical form
+	hosts           []string                      // host:port pool keys the conn serves, the dialed one first; see coalesce
+	gotOrigin       bool                          // whether an ORIGIN frame was received
 	streams         map[uint32]*http2clientStream // client-initiated
 	streamsReserved int                           // incr by ReserveNewRequest; decr on RoundTrip
 	nextStreamID    uint32
@@ -7544,6 +7801,12 @@ type http2ClientConn struct {
 	br              *bufio.Reader
 	lastActive      time.Time
 	lastIdle        time.Time // time last idle
+	createdAt       time.Time // see ConnReuseInfo.Age
+	requests        int       // streams opened for requests; see ConnReuseInfo.Requests
+
+	// prio picks Chrome-style stream dependencies; see WithPriority.
+	prio http2priorityTree
+
 	// Settings from peer: (also guarded by wmu)
 	maxFrameSize           uint32
 	maxConcurrentStreams   uint32
@@ -7767,6 +8030,15 @@ func (t *HTTP2Transport) RoundTripOpt(req *Request, opt http2RoundTripOpt) (*Res
 	}
 
 	addr := http2authorityAddr(req.URL.Scheme, req.URL.Host)
+	if t.t1 != nil {
+		// Use the partition of the HTTP/1 Transport's pool key the
+		// request was routed with, see connectMethodKey.h2Partition.
+		p, ok := req.Context().Value(h2PartitionKey{}).(connPartition)
+		if !ok {
+			p = t.t1.requestPartition(req)
+		}
+		addr += p.String()
+	}
 	for retry := 0; ; retry++ {
 		cc, err := t.connPool().GetClientConn(req, addr)
 		if err != nil {
@@ -7800,7 +8072,7 @@ func (t *HTTP2Transport) RoundTripOpt(req *Request, opt http2RoundTripOpt) (*Res
 		}
 		if err != nil {
 			t.vlogf("RoundTrip failure: %v", err)
-			return nil, err
+			return nil, http2streamResetError(err)
 		}
 		return res, nil
 	}
@@ -7815,6 +8087,27 @@ func (t *HTTP2Transport) CloseIdleConnections() {
 	}
 }
 
+// CloseIdleConnectionsFor is like CloseIdleConnections but only closes
+// idle connections to addr, a "host:port" target address.
+func (t *HTTP2Transport) CloseIdleConnectionsFor(addr string) {
+	if p := t.clientConnPool(); p != nil {
+		p.closeIdleConnectionsForAddr(addr)
+	}
+}
+
+// clientConnPool returns the default connection pool, including when it
+// is wrapped for use by an HTTP/1 Transport, or nil if ConnPool is a
+// custom pool.
+func (t *HTTP2Transport) clientConnPool() *http2clientConnPool {
+	switch p := t.connPool().(type) {
+	case *http2clientConnPool:
+		return p
+	case http2noDialClientConnPool:
+		return p.http2clientConnPool
+	}
+	return nil
+}
+
 var (
 	http2errClientConnClosed    = errors.New("http2: client conn is closed")
 	http2errClientConnUnusable  = errors.New("http2: client conn not usable")
@@ -7952,6 +8245,65 @@ func (t *HTTP2Transport) NewClientConn(c net.Conn) (*http2ClientConn, error) {
 	return t.newClientConn(c, t.disableKeepAlives())
 }
 
+// http2initialFrames are the frames a new client connection sends
+// after the preface.
+type http2initialFrames struct {
+	settings   []HTTP2Setting
+	connFlow   int // connection-level WINDOW_UPDATE increment
+	priorities []HTTP2PriorityFrame
+	inflow     int // initial connection-level receive window
+}
+
+// initialFrames returns the frames a new connection sends after the
+// preface, following HTTP2Settings when it is set.
+func (t *HTTP2Transport) initialFrames() (http2initialFrames, error) {
+	initialSettings := []HTTP2Setting{
+		{ID: HTTP2SettingEnablePush, Val: 0},
+		{ID: HTTP2SettingInitialWindowSize, Val: http2transportDefaultStreamFlow},
+	}
+	if max := t.maxFrameReadSize(); max != 0 {
+		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingMaxFrameSize, Val: max})
+	}
+	if max := t.maxHeaderListSize(); max != 0 {
+		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingMaxHeaderListSize, Val: max})
+	}
+	if maxHeaderTableSize := t.maxDecoderHeaderTableSize(); maxHeaderTableSize != http2initialHeaderTableSize {
+		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingHeaderTableSize, Val: maxHeaderTableSize})
+	}
+
+	settings := t.http2Settings()
+	if settings == nil {
+		return http2initialFrames{
+			settings: initialSettings,
+			connFlow: http2transportDefaultConnFlow,
+			inflow:   http2transportDefaultConnFlow + http2initialWindowSize,
+		}, nil
+	}
+	http2Settings, err := settings.Clone()
+	if err != nil {
+		return http2initialFrames{}, err
+	}
+	inflowValue := http2transportDefaultStreamFlow
+	for _, setting := range http2Settings.Settings {
+		if setting.ID == HTTP2SettingInitialWindowSize {
+			inflowValue = int(setting.Val)
+		}
+	}
+	f := http2initialFrames{
+		settings:   initialSettings,
+		connFlow:   http2transportDefaultConnFlow,
+		priorities: http2Settings.PriorityFrames,
+	}
+	if len(http2Settings.Settings) != 0 {
+		f.settings = http2Settings.Settings
+	}
+	if http2Settings.ConnectionFlow != 0 {
+		f.connFlow = http2Settings.ConnectionFlow
+	}
+	f.inflow = inflowValue + f.connFlow
+	return f, nil
+}
+
 func (t *HTTP2Transport) newClientConn(c net.Conn, singleUse bool) (*http2ClientConn, error) {
 	cc := &http2ClientConn{
 		t:                     t,
@@ -7967,6 +8319,7 @@ func (t *HTTP2Transport) newClientConn(c net.Conn, singleUse bool) (*http2Client
 		wantSettingsAck:       true,
 		pings:                 make(map[[8]byte]chan struct{}),
 		reqHeaderMu:           make(chan struct{}, 1),
+		createdAt:             t.now(),
 	}
 	if t.http2transportTestHooks != nil {
 		t.markNewGoroutine()
@@ -7976,6 +8329,11 @@ func (t *HTTP2Transport) newClientConn(c net.Conn, singleUse bool) (*http2Client
 	if http2VerboseLogs {
 		t.vlogf("http2: Transport creating client conn %p to %v", cc, c.RemoteAddr())
 	}
+	if t.t1 != nil {
+		if id, ok := t.t1.connIdentities.Load(c); ok {
+			cc.identity = id.(*connIdentity)
+		}
+	}
 
 	cc.cond = sync.NewCond(&cc.mu)
 	cc.flow.add(int32(http2initialWindowSize))
@@ -8012,60 +8370,18 @@ func (t *HTTP2Transport) newClientConn(c net.Conn, singleUse bool) (*http2Client
 		cc.tlsState = &state
 	}
 
-	initialSettings := []HTTP2Setting{
-		{ID: HTTP2SettingEnablePush, Val: 0},
-		{ID: HTTP2SettingInitialWindowSize, Val: http2transportDefaultStreamFlow},
-	}
-	if max := t.maxFrameReadSize(); max != 0 {
-		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingMaxFrameSize, Val: max})
-	}
-	if max := t.maxHeaderListSize(); max != 0 {
-		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingMaxHeaderListSize, Val: max})
-	}
-	if maxHeaderTableSize != http2initialHeaderTableSize {
-		initialSettings = append(initialSettings, HTTP2Setting{ID: HTTP2SettingHeaderTableSize, Val: maxHeaderTableSize})
+	init, err := t.initialFrames()
+	if err != nil {
+		return nil, err
 	}
-
 	cc.bw.Write(http2clientPreface)
-	//cc.fr.WriteSettings(initialSettings...)
-	//cc.fr.WriteWindowUpdate(0, http2transportDefaultConnFlow)
-	//cc.inflow.init(http2transportDefaultConnFlow + http2initialWindowSize)
-
-	if t.HTTP2Settings != nil {
-		http2Settings, err := t.HTTP2Settings.Clone()
-		if err != nil {
-			return nil, err
-		}
-		inflowValue := http2transportDefaultStreamFlow
-		if http2Settings.Settings != nil {
-			for _, setting := range http2Settings.Settings {
-				if setting.ID == HTTP2SettingInitialWindowSize {
-					inflowValue = int(setting.Val)
-				}
-			}
-		}
-		if len(http2Settings.Settings) != 0 {
-			cc.fr.WriteSettings(http2Settings.Settings...)
-		} else {
-			cc.fr.WriteSettings(initialSettings...)
-		}
-		var connectionFlow = http2transportDefaultConnFlow
-		if http2Settings.ConnectionFlow != 0 {
-			connectionFlow = http2Settings.ConnectionFlow
-		}
-		cc.fr.WriteWindowUpdate(0, uint32(connectionFlow))
-		if http2Settings.PriorityFrames != nil {
-			for _, frame := range http2Settings.PriorityFrames {
-				cc.fr.WritePriority(frame.StreamID, frame.HTTP2PriorityParam)
-				cc.nextStreamID = frame.StreamID + uint32(2)
-			}
-		}
-		cc.inflow.init(int32(inflowValue + connectionFlow))
-	} else {
-		cc.fr.WriteSettings(initialSettings...)
-		cc.fr.WriteWindowUpdate(0, http2transportDefaultConnFlow)
-		cc.inflow.init(http2transportDefaultConnFlow + http2initialWindowSize)
+	cc.fr.WriteSettings(init.settings...)
+	cc.fr.WriteWindowUpdate(0, uint32(init.connFlow))
+	for _, frame := range init.priorities {
+		cc.fr.WritePriority(frame.StreamID, frame.HTTP2PriorityParam)
+		cc.nextStreamID = frame.StreamID + uint32(2)
 	}
+	cc.inflow.init(int32(init.inflow))
 
 	cc.bw.Flush()
 	if cc.werr != nil {
@@ -8108,7 +8424,6 @@ func (cc *http2ClientConn) SetDoNotReuse() {
 
 func (cc *http2ClientConn) setGoAway(f *http2GoAwayFrame) {
 	cc.mu.Lock()
-	defer cc.mu.Unlock()
 
 	old := cc.goAway
 	cc.goAway = f
@@ -8121,24 +8436,40 @@ func (cc *http2ClientConn) setGoAway(f *http2GoAwayFrame) {
 		cc.goAway.ErrCode = old.ErrCode
 	}
 	last := f.LastStreamID
+	var traced []func()
 	for streamID, cs := range cc.streams {
-		if streamID <= last {
+		info := httptrace.GoAwayInfo{
+			LastStreamID: last,
+			ErrCode:      uint32(cc.goAway.ErrCode),
+			DebugData:    string(f.DebugData()),
+		}
+		switch {
+		case streamID <= last:
 			// The server's GOAWAY indicates that it received this stream.
 			// It will either finish processing it, or close the connection
 			// without doing so. Either way, leave the stream alone for now.
-			continue
-		}
-		if streamID == 1 && cc.goAway.ErrCode != http2ErrCodeNo {
+		case streamID == 1 && cc.goAway.ErrCode != http2ErrCodeNo:
 			// Don't retry the first stream on a connection if we get a non-NO error.
 			// If the server is sending an error on a new connection,
 			// retrying the request on a new one probably isn't going to work.
 			cs.abortStreamLocked(fmt.Errorf("http2: Transport received GOAWAY from server ErrCode:%v", cc.goAway.ErrCode))
-		} else {
+		default:
 			// Aborting the stream with errClentConnGotGoAway indicates that
 			// the request should be retried on a new connection.
 			cs.abortStreamLocked(http2errClientConnGotGoAway)
+			info.Retried = true
+		}
+		if cs.trace != nil && cs.trace.GotGoAway != nil {
+			fn := cs.trace.GotGoAway
+			traced = append(traced, func() { fn(info) })
 		}
 	}
+	cc.mu.Unlock()
+
+	// Call the trace hooks without cc.mu held.
+	for _, fn := range traced {
+		fn()
+	}
 }
 
 // CanTakeNewRequest reports whether the connection can take a new request,
@@ -8250,7 +8581,8 @@ func (cc *http2ClientConn) idleStateLocked() (st http2clientConnIdleState) {
 	st.canTakeNewRequest = cc.goAway == nil && !cc.closed && !cc.closing && maxConcurrentOkay &&
 		!cc.doNotReuse &&
 		int64(cc.nextStreamID)+2*int64(cc.pendingRequests) < math.MaxInt32 &&
-		!cc.tooIdleLocked()
+		!cc.tooIdleLocked() &&
+		!cc.retiredLocked()
 	return
 }
 
@@ -8266,7 +8598,7 @@ func (cc *http2ClientConn) tooIdleLocked() bool {
 	// times are compared based on their wall time. We don't want
 	// to reuse a connection that's been sitting idle during
 	// VM/laptop suspend if monotonic time was also frozen.
-	return cc.idleTimeout != 0 && !cc.lastIdle.IsZero() && time.Since(cc.lastIdle.Round(0)) > cc.idleTimeout
+	return cc.idleTimeout != 0 && !cc.lastIdle.IsZero() && cc.t.now().Sub(cc.lastIdle.Round(0)) > cc.idleTimeout
 }
 
 // onIdleTimeout is called from a time.AfterFunc goroutine. It will
@@ -8297,9 +8629,19 @@ func (cc *http2ClientConn) forceCloseConn() {
 	}
 }
 
+// idleSince reports whether cc has been used and is now idle, and when
+// its last stream finished.
+func (cc *http2ClientConn) idleSince() (time.Time, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	idle := !cc.closed && !cc.closing && cc.nextStreamID > 1 &&
+		len(cc.streams) == 0 && cc.streamsReserved == 0 && cc.pendingRequests == 0
+	return cc.lastActive, idle
+}
+
 func (cc *http2ClientConn) closeIfIdle() {
 	cc.mu.Lock()
-	if len(cc.streams) > 0 || cc.streamsReserved > 0 {
+	if len(cc.streams) > 0 || cc.streamsReserved > 0 || cc.closed {
 		cc.mu.Unlock()
 		return
 	}
@@ -8437,9 +8779,9 @@ func http2commaSeparatedTrailers(req *Request) (string, error) {
 	return "", nil
 }
 
-func (cc *http2ClientConn) responseHeaderTimeout() time.Duration {
+func (cc *http2ClientConn) responseHeaderTimeout(req *Request) time.Duration {
 	if cc.t.t1 != nil {
-		return cc.t.t1.ResponseHeaderTimeout
+		return cc.t.t1.responseHeaderTimeout(req)
 	}
 	// No way to do this (yet?) with just an http2.Transport. Probably
 	// no need. Request.Cancel this is the new way. We only need to support
@@ -8668,6 +9010,7 @@ func (cs *http2clientStream) writeRequest(req *Request, streamf func(*http2clien
 		return err
 	}
 	cc.addStreamLocked(cs) // assigns stream ID
+	cc.requests++
 	if http2isConnectionCloseRequest(req) {
 		cc.doNotReuse = true
 	}
@@ -8736,7 +9079,7 @@ func (cs *http2clientStream) writeRequest(req *Request, streamf func(*http2clien
 
 	var respHeaderTimer <-chan time.Time
 	var respHeaderRecv chan struct{}
-	if d := cc.responseHeaderTimeout(); d != 0 {
+	if d := cc.responseHeaderTimeout(req); d > 0 {
 		timer := cc.t.newTimer(d)
 		defer timer.Stop()
 		respHeaderTimer = timer.C()
@@ -8801,8 +9144,13 @@ func (cs *http2clientStream) encodeAndWriteHeaders(req *Request) error {
 
 	// Write the request.
 	endStream := !hasBody && !hasTrailers
+	if t1 := cc.t.t1; t1 != nil {
+		if v := t1.priorityUpdate(req); v != "" {
+			cc.writePriorityUpdate(cs.ID, v)
+		}
+	}
 	cs.sentHeaders = true
-	err = cc.writeHeaders(cs.ID, endStream, int(cc.maxFrameSize), hdrs)
+	err = cc.writeHeaders(cs.ID, endStream, int(cc.maxFrameSize), hdrs, PriorityFromContext(cs.ctx))
 	http2traceWroteHeaders(cs.trace)
 	return err
 }
@@ -8885,7 +9233,7 @@ func (cs *http2clientStream) cleanupWriteRequest(err error) {
 // Must hold cc.mu.
 func (cc *http2ClientConn) awaitOpenSlotForStreamLocked(cs *http2clientStream) error {
 	for {
-		cc.lastActive = time.Now()
+		cc.lastActive = cc.t.now()
 		if cc.closed || !cc.canTakeNewRequestLocked() {
 			return http2errClientConnUnusable
 		}
@@ -8904,8 +9252,21 @@ func (cc *http2ClientConn) awaitOpenSlotForStreamLocked(cs *http2clientStream) e
 	}
 }
 
+// http2framePriorityUpdate is the PRIORITY_UPDATE frame type from RFC 9218.
+const http2framePriorityUpdate http2FrameType = 0x10
+
+// writePriorityUpdate writes an RFC 9218 PRIORITY_UPDATE frame
+// for streamID with the Priority Field Value v.
+// requires cc.wmu be held
+func (cc *http2ClientConn) writePriorityUpdate(streamID uint32, v string) {
+	payload := binary.BigEndian.AppendUint32(nil, streamID&(1<<31-1))
+	cc.fr.WriteRawFrame(http2framePriorityUpdate, 0, 0, append(payload, v...))
+}
+
 // requires cc.wmu be held
-func (cc *http2ClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSize int, hdrs []byte) error {
+// prio adjusts the weight of the configured HeaderPriority and, when it
+// is exclusive, the stream dependency; see WithPriority.
+func (cc *http2ClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSize int, hdrs []byte, prio FetchPriority) error {
 	first := true // first frame written (HEADERS is first, then CONTINUATION)
 	for len(hdrs) > 0 && cc.werr == nil {
 		chunk := hdrs
@@ -8917,12 +9278,20 @@ func (cc *http2ClientConn) writeHeaders(streamID uint32, endStream bool, maxFram
 		if first {
 			headersPriorityParam := HTTP2PriorityParam{}
 
-			if cc.t.HTTP2Settings != nil && cc.t.HTTP2Settings.HeaderPriority != nil {
-				http2Settings, err := cc.t.HTTP2Settings.Clone()
+			if settings := cc.t.http2Settings(); settings != nil && settings.HeaderPriority != nil {
+				http2Settings, err := settings.Clone()
 				if err != nil {
 					return err
 				}
 				headersPriorityParam = *http2Settings.HeaderPriority
+				if w, ok := prio.h2Weight(); ok {
+					headersPriorityParam.Weight = w
+				}
+				if headersPriorityParam.Exclusive {
+					cc.mu.Lock()
+					headersPriorityParam.StreamDep = cc.prio.parent(streamID, prio)
+					cc.mu.Unlock()
+				}
 			}
 			cc.fr.WriteHeaders(http2HeadersFrameParam{
 				StreamID:      streamID,
@@ -9120,7 +9489,7 @@ func (cs *http2clientStream) writeRequestBody(req *Request) (err error) {
 	// Two ways to send END_STREAM: either with trailers, or
 	// with an empty DATA frame.
 	if len(trls) > 0 {
-		err = cc.writeHeaders(cs.ID, true, maxFrameSize, trls)
+		err = cc.writeHeaders(cs.ID, true, maxFrameSize, trls, PriorityAuto)
 	} else {
 		err = cc.fr.WriteData(cs.ID, true, nil)
 	}
@@ -9244,6 +9613,9 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 		// followed by the query production, see Sections 3.3 and 3.4 of
 		// [RFC3986]).
 		pHeaderOrder, ok := req.Header[PHeaderOrderKey]
+		if s := cc.t.http2Settings(); !ok && s != nil && len(s.PseudoHeaderOrder) > 0 {
+			pHeaderOrder, ok = s.PseudoHeaderOrder, true
+		}
 		m := req.Method
 		if m == "" {
 			m = MethodGet
@@ -9255,7 +9627,7 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 				case ":authority":
 					f(":authority", host)
 				case ":method":
-					f(":method", req.Method)
+					f(":method", m)
 				case ":path":
 					if req.Method != "CONNECT" {
 						f(":path", path)
@@ -9279,90 +9651,7 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 				f(":scheme", req.URL.Scheme)
 			}
 		}
-		if trailers != "" {
-			f("trailer", trailers)
-		}
-
-		// Should clone, because this function is called twice; to read and to write.
-		// If headers are added to the req, then headers would be added twice.
-		hdrs := req.Header.Clone()
-		if _, ok := req.Header["content-length"]; !ok && http2shouldSendReqContentLength(req.Method, contentLength) {
-			hdrs["content-length"] = []string{strconv.FormatInt(contentLength, 10)}
-		}
-
-		var didUA bool
-		var kvs []keyValues
-
-		if headerOrder, ok := hdrs[HeaderOrderKey]; ok {
-			order := make(map[string]int)
-			for i, v := range headerOrder {
-				order[strings.ToLower(v)] = i
-			}
-			kvs, _ = hdrs.sortedKeyValuesBy(order, make(map[string]bool))
-		} else {
-			kvs, _ = hdrs.sortedKeyValues(make(map[string]bool))
-		}
-
-		for _, kv := range kvs {
-			//if http2asciiEqualFold(kv.key, "host") || http2asciiEqualFold(kv.key, "content-length") {
-			//	// Host is :authority, already sent.
-			//	// Content-Length is automatic, set below.
-			//	continue
-			if http2asciiEqualFold(kv.key, "connection") ||
-				http2asciiEqualFold(kv.key, "proxy-connection") ||
-				http2asciiEqualFold(kv.key, "transfer-encoding") ||
-				http2asciiEqualFold(kv.key, "upgrade") ||
-				http2asciiEqualFold(kv.key, "keep-alive") {
-				// Per 8.1.2.2 Connection-Specific Header
-				// Fields, don't send connection-specific
-				// fields. We have already checked if any
-				// are error-worthy so just ignore the rest.
-				continue
-			} else if http2asciiEqualFold(kv.key, "user-agent") {
-				// Match Go's http1 behavior: at most one
-				// User-Agent. If set to nil or empty string,
-				// then omit it. Otherwise if not mentioned,
-				// include the default (below).
-				didUA = true
-				if len(kv.values) < 1 {
-					continue
-				}
-				kv.values = kv.values[:1]
-				if kv.values[0] == "" {
-					continue
-				}
-			} else if http2asciiEqualFold(kv.key, "cookie") {
-				// Per 8.1.2.5 To allow for better compression efficiency, the
-				// Cookie header field MAY be split into separate header fields,
-				// each with one or more cookie-pairs.
-				for _, v := range kv.values {
-					//for {
-					//	p := strings.IndexByte(v, ';')
-					//	if p < 0 {
-					//		break
-					//	}
-					//	f("cookie", v[:p])
-					//	p++
-					//	// strip space after semicolon if any.
-					//	for p+1 <= len(v) && v[p] == ' ' {
-					//		p++
-					//	}
-					//	v = v[p:]
-					//}
-					if len(v) > 0 {
-						f("cookie", v)
-					}
-				}
-				continue
-			}
-
-			for _, v := range kv.values {
-				f(kv.key, v)
-			}
-		}
-		if !didUA {
-			f("user-agent", http2defaultUserAgent)
-		}
+		http2enumerateRegularHeaders(cc.t.t1, req, trailers, contentLength, f)
 	}
 
 	// Do a first pass over the headers counting bytes to ensure
@@ -9408,6 +9697,100 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 	return cc.hbuf.Bytes(), nil
 }
 
+// enumerateRegularHeaders calls f for each non-pseudo header field
+// encodeHeaders writes for req, in order. t1 may be nil.
+func http2enumerateRegularHeaders(t1 *Transport, req *Request, trailers string, contentLength int64, f func(name, value string)) {
+	if trailers != "" {
+		f("trailer", trailers)
+	}
+
+	// Should clone, because this function is called twice; to read and to write.
+	// If headers are added to the req, then headers would be added twice.
+	hdrs := req.Header.Clone()
+	if _, ok := req.Header["content-length"]; !ok && http2shouldSendReqContentLength(req.Method, contentLength) {
+		hdrs["content-length"] = []string{strconv.FormatInt(contentLength, 10)}
+	}
+	if t1 != nil {
+		if v := t1.priorityHeader(req); v != "" {
+			hdrs["priority"] = []string{v}
+		}
+	}
+
+	var didUA bool
+	var kvs []keyValues
+
+	if headerOrder, ok := hdrs[HeaderOrderKey]; ok {
+		order := make(map[string]int)
+		for i, v := range headerOrder {
+			order[strings.ToLower(v)] = i
+		}
+		kvs, _ = hdrs.sortedKeyValuesBy(order, make(map[string]bool))
+	} else {
+		kvs, _ = hdrs.sortedKeyValues(make(map[string]bool))
+	}
+
+	for _, kv := range kvs {
+		//if http2asciiEqualFold(kv.key, "host") || http2asciiEqualFold(kv.key, "content-length") {
+		//	// Host is :authority, already sent.
+		//	// Content-Length is automatic, set below.
+		//	continue
+		if http2asciiEqualFold(kv.key, "connection") ||
+			http2asciiEqualFold(kv.key, "proxy-connection") ||
+			http2asciiEqualFold(kv.key, "transfer-encoding") ||
+			http2asciiEqualFold(kv.key, "upgrade") ||
+			http2asciiEqualFold(kv.key, "keep-alive") {
+			// Per 8.1.2.2 Connection-Specific Header
+			// Fields, don't send connection-specific
+			// fields. We have already checked if any
+			// are error-worthy so just ignore the rest.
+			continue
+		} else if http2asciiEqualFold(kv.key, "user-agent") {
+			// Match Go's http1 behavior: at most one
+			// User-Agent. If set to nil or empty string,
+			// then omit it. Otherwise if not mentioned,
+			// include the default (below).
+			didUA = true
+			if len(kv.values) < 1 {
+				continue
+			}
+			kv.values = kv.values[:1]
+			if kv.values[0] == "" {
+				continue
+			}
+		} else if http2asciiEqualFold(kv.key, "cookie") {
+			// Per 8.1.2.5 To allow for better compression efficiency, the
+			// Cookie header field MAY be split into separate header fields,
+			// each with one or more cookie-pairs.
+			for _, v := range kv.values {
+				//for {
+				//	p := strings.IndexByte(v, ';')
+				//	if p < 0 {
+				//		break
+				//	}
+				//	f("cookie", v[:p])
+				//	p++
+				//	// strip space after semicolon if any.
+				//	for p+1 <= len(v) && v[p] == ' ' {
+				//		p++
+				//	}
+				//	v = v[p:]
+				//}
+				if len(v) > 0 {
+					f("cookie", v)
+				}
+			}
+			continue
+		}
+
+		for _, v := range kv.values {
+			f(kv.key, v)
+		}
+	}
+	if !didUA {
+		f("user-agent", http2defaultUserAgent)
+	}
+}
+
 // shouldSendReqContentLength reports whether the http2.Transport should send
 // a "content-length" request header. This logic is basically a copy of the github.com/vanling1111/tlshttp
 // transferWriter.shouldSendContentLength.
@@ -9494,17 +9877,19 @@ func (cc *http2ClientConn) forgetStreamID(id uint32) {
 	if len(cc.streams) != slen-1 {
 		panic("forgetting unknown stream id")
 	}
-	cc.lastActive = time.Now()
+	cc.prio.forget(id)
+	cc.lastActive = cc.t.now()
 	if len(cc.streams) == 0 && cc.idleTimer != nil {
 		cc.idleTimer.Reset(cc.idleTimeout)
-		cc.lastIdle = time.Now()
+		cc.lastIdle = cc.t.now()
 	}
 	// Wake up writeRequestBody via clientStream.awaitFlowControl and
 	// wake up RoundTrip if there is a pending request.
 	cc.cond.Broadcast()
 
-	closeOnIdle := cc.singleUse || cc.doNotReuse || cc.t.disableKeepAlives() || cc.goAway != nil
-	if closeOnIdle && cc.streamsReserved == 0 && len(cc.streams) == 0 {
+	closeOnIdle := cc.singleUse || cc.retiredLocked() || cc.t.disableKeepAlives() || cc.goAway != nil
+	idle := cc.streamsReserved == 0 && len(cc.streams) == 0
+	if closeOnIdle && idle {
 		if http2VerboseLogs {
 			cc.vlogf("http2: Transport closing idle conn %p (forSingleUse=%v, maxStream=%v)", cc, cc.singleUse, cc.nextStreamID-2)
 		}
@@ -9513,6 +9898,14 @@ func (cc *http2ClientConn) forgetStreamID(id uint32) {
 	}
 
 	cc.mu.Unlock()
+
+	// Enforce the idle connection limits only when the last stream
+	// finishes, so busy connections don't rescan the pool.
+	if idle && !closeOnIdle {
+		if p := cc.t.clientConnPool(); p != nil {
+			p.trimIdle(cc)
+		}
+	}
 }
 
 // clientConnReadLoop is the state owned by the clientConn's frame-reading readLoop.
@@ -9677,6 +10070,12 @@ func (rl *http2clientConnReadLoop) run() error {
 			err = rl.processWindowUpdate(f)
 		case *http2PingFrame:
 			err = rl.processPing(f)
+		case *http2UnknownFrame:
+			if f.Type == http2frameOrigin {
+				rl.processOrigin(f)
+				break
+			}
+			cc.logf("Transport: unhandled response frame type %T", f)
 		default:
 			cc.logf("Transport: unhandled response frame type %T", f)
 		}
@@ -9849,9 +10248,13 @@ func (rl *http2clientConnReadLoop) handleResponse(cs *http2clientStream, f *http
 		return res, nil
 	}
 
+	strict := cs.cc.t.t1 != nil && cs.cc.t.t1.StrictContentLength && res.ContentLength >= 0
 	if f.StreamEnded() {
 		if res.ContentLength > 0 {
 			res.Body = http2missingBody{}
+			if strict {
+				res.Body = &contentLengthVerifier{body: res.Body, declared: res.ContentLength}
+			}
 		} else {
 			res.Body = http2noBody
 		}
@@ -9861,6 +10264,11 @@ func (rl *http2clientConnReadLoop) handleResponse(cs *http2clientStream, f *http
 	cs.bufPipe.setBuffer(&http2dataBuffer{expected: res.ContentLength})
 	cs.bytesRemain = res.ContentLength
 	res.Body = http2transportResponseBody{cs}
+	var verifier *contentLengthVerifier
+	if strict {
+		verifier = &contentLengthVerifier{body: res.Body, declared: res.ContentLength}
+		res.Body = verifier
+	}
 
 	if cs.requestedGzip && http2asciiEqualFold(res.Header.Get("Content-Encoding"), "gzip") {
 		res.Header.Del("Content-Encoding")
@@ -9868,6 +10276,9 @@ func (rl *http2clientConnReadLoop) handleResponse(cs *http2clientStream, f *http
 		res.ContentLength = -1
 		res.Body = &http2gzipReader{body: res.Body}
 		res.Uncompressed = true
+		if verifier != nil {
+			verifier.decompressed = true
+		}
 	}
 	return res, nil
 }
@@ -9914,6 +10325,7 @@ func (b http2transportResponseBody) Read(p []byte) (n int, err error) {
 		return 0, cs.readErr
 	}
 	n, err = b.cs.bufPipe.Read(p)
+	err = http2streamResetError(err)
 	if cs.bytesRemain != -1 {
 		if int64(n) > cs.bytesRemain {
 			n = int(cs.bytesRemain)
@@ -10371,6 +10783,73 @@ func (rl *http2clientConnReadLoop) processPing(f *http2PingFrame) error {
 	return cc.bw.Flush()
 }
 
+// http2frameOrigin is the ORIGIN frame type from RFC 8336.
+const http2frameOrigin http2FrameType = 0xc
+
+// http2maxOrigins limits the size of a connection's origin set.
+const http2maxOrigins = 1024
+
+// processOrigin adds the origins in an ORIGIN frame to the connection's
+// origin set. As RFC 8336 requires, frames on a non-zero stream are
+// ignored, as are malformed entries; a truncated payload discards the
+// rest of the frame.
+func (rl *http2clientConnReadLoop) processOrigin(f *http2UnknownFrame) {
+	if f.StreamID != 0 {
+		return
+	}
+	cc := rl.cc
+	p := f.Payload()
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.gotOrigin = true
+	for len(p) >= 2 {
+		n := int(binary.BigEndian.Uint16(p))
+		if len(p) < 2+n {
+			return
+		}
+		o, ok := http2canonicalOrigin(string(p[2 : 2+n]))
+		p = p[2+n:]
+		if ok && len(cc.origins) < http2maxOrigins && !slices.Contains(cc.origins, o) {
+			cc.origins = append(cc.origins, o)
+		}
+	}
+}
+
+// http2canonicalOrigin returns the ASCII serialization of an https
+// origin with the host lowercased and the default port removed.
+func http2canonicalOrigin(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return "https://" + host, true
+}
+
+// inOriginSet reports whether addr ("host:port") is in the origin set
+// the server advertised with ORIGIN frames. known reports whether the
+// server sent any; without them the origin set is unknown and callers
+// must fall back to DNS and certificate checks, as browsers do.
+func (cc *http2ClientConn) inOriginSet(addr string) (ok, known bool) {
+	o, valid := http2canonicalOrigin("https://" + addr)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return valid && slices.Contains(cc.origins, o), cc.gotOrigin
+}
+
+// originSet returns a copy of the origins received in ORIGIN frames.
+func (cc *http2ClientConn) originSet() []string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return slices.Clone(cc.origins)
+}
+
 func (rl *http2clientConnReadLoop) processPushPromise(f *http2PushPromiseFrame) error {
 	// We told the peer we don't want them.
 	// Spec says:
@@ -10512,6 +10991,30 @@ func (rt http2noDialH2RoundTripper) RoundTrip(req *Request) (*Response, error) {
 	return res, err
 }
 
+func (t *HTTP2Transport) maxIdleConns() int {
+	if t.MaxIdleConns != 0 {
+		return t.MaxIdleConns
+	}
+	if t.t1 != nil {
+		t.t1.idleMu.Lock()
+		defer t.t1.idleMu.Unlock()
+		return t.t1.MaxIdleConns
+	}
+	return 0
+}
+
+func (t *HTTP2Transport) maxIdleConnsPerHost() int {
+	if t.MaxIdleConnsPerHost != 0 {
+		return t.MaxIdleConnsPerHost
+	}
+	if t.t1 != nil {
+		t.t1.idleMu.Lock()
+		defer t.t1.idleMu.Unlock()
+		return t.t1.MaxIdleConnsPerHost
+	}
+	return 0
+}
+
 func (t *HTTP2Transport) idleConnTimeout() time.Duration {
 	// to keep things backwards compatible, we use non-zero values of
 	// IdleConnTimeout, followed by using the IdleConnTimeout on the underlying
@@ -10542,10 +11045,14 @@ func http2traceGotConn(req *Request, cc *http2ClientConn, reused bool) {
 	}
 	ci := httptrace.GotConnInfo{Conn: cc.tconn}
 	ci.Reused = reused
+	cc.identity.fill(&ci, "h2")
 	cc.mu.Lock()
+	ci.Origins = slices.Clone(cc.origins)
+	ci.ConnHosts = slices.Clone(cc.hosts)
+	ci.Coalesced = len(cc.hosts) > 0 && cc.hosts[0] != http2authorityAddr(req.URL.Scheme, req.URL.Host)
 	ci.WasIdle = len(cc.streams) == 0 && reused
 	if ci.WasIdle && !cc.lastActive.IsZero() {
-		ci.IdleTime = time.Since(cc.lastActive)
+		ci.IdleTime = cc.t.now().Sub(cc.lastActive)
 	}
 	cc.mu.Unlock()
 
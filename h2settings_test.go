@@ -0,0 +1,102 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	stdtls "crypto/tls"
+	"io"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/internal/testcert"
+)
+
+// TestTransportHTTP2SettingsWire 测试 Transport.HTTP2Settings 被内置的 HTTP/2
+// 传输层用于连接的 SETTINGS 帧和连接级 WINDOW_UPDATE
+func TestTransportHTTP2SettingsWire(t *testing.T) {
+	cert, err := stdtls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := stdtls.Listen("tcp", "127.0.0.1:0", &stdtls.Config{
+		Certificates: []stdtls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type frames struct {
+		settings  []HTTP2Setting
+		increment uint32
+		err       error
+	}
+	got := make(chan frames, 1)
+	go func() {
+		var f frames
+		defer func() { got <- f }()
+		c, err := ln.Accept()
+		if err != nil {
+			f.err = err
+			return
+		}
+		defer c.Close()
+		preface := make([]byte, len(http2ClientPreface))
+		if _, f.err = io.ReadFull(c, preface); f.err != nil {
+			return
+		}
+		fr := http2NewFramer(c, c)
+		for f.settings == nil || f.increment == 0 {
+			fr1, err := fr.ReadFrame()
+			if err != nil {
+				f.err = err
+				return
+			}
+			switch fr1 := fr1.(type) {
+			case *http2SettingsFrame:
+				fr1.ForeachSetting(func(s HTTP2Setting) error {
+					f.settings = append(f.settings, s)
+					return nil
+				})
+			case *http2WindowUpdateFrame:
+				if fr1.StreamID == 0 {
+					f.increment = fr1.Increment
+				}
+			}
+		}
+	}()
+
+	want := []HTTP2Setting{
+		{ID: HTTP2SettingHeaderTableSize, Val: 65536},
+		{ID: HTTP2SettingInitialWindowSize, Val: 6291456},
+		{ID: HTTP2SettingMaxHeaderListSize, Val: 262144},
+	}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		HTTP2Settings:     &HTTP2Settings{Settings: want, ConnectionFlow: 15663105},
+	}
+	defer tr.CloseIdleConnections()
+	go func() {
+		req, _ := NewRequest("GET", "https://"+ln.Addr().String(), nil)
+		if resp, err := tr.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	f := <-got
+	if f.err != nil {
+		t.Fatal(f.err)
+	}
+	if !slices.Equal(f.settings, want) {
+		t.Errorf("SETTINGS got %v, want %v", f.settings, want)
+	}
+	if f.increment != 15663105 {
+		t.Errorf("WINDOW_UPDATE got %d, want 15663105", f.increment)
+	}
+}
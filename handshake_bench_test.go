@@ -0,0 +1,167 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	stdtls "crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// 基准测试比较标准 TLS 路径和 utls 指纹路径的性能，用于发现 utls 路径的性能回退：
+//
+//	go test -run '^$' -bench 'Handshake|Throughput|ClientHelloBuild' -benchmem
+//
+// 服务端禁用了会话票据，每次握手都是完整握手。
+
+const benchBodySize = 1 << 20
+
+// benchTransports 返回参与比较的几种握手路径：不设置指纹的标准路径、JA3、
+// ClientHelloID 和 ClientHelloHexStream
+func benchTransports(b *testing.B) []struct {
+	name string
+	new  func() *Transport
+} {
+	b.Helper()
+	const ua = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"
+
+	// 十六进制流使用 JA3 生成的 ClientHello
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc, err := (&Transport{JA3: paddingTestJA3, UserAgent: ua}).NewUConn(c1, &tls.Config{ServerName: "example.com"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := uc.BuildHandshakeState(); err != nil {
+		b.Fatal(err)
+	}
+	raw := uc.HandshakeState.Hello.Raw
+	stream := hex.EncodeToString(append([]byte{0x16, 0x03, 0x01, byte(len(raw) >> 8), byte(len(raw))}, raw...))
+
+	return []struct {
+		name string
+		new  func() *Transport
+	}{
+		{"Std", func() *Transport { return &Transport{} }},
+		{"JA3", func() *Transport { return &Transport{JA3: paddingTestJA3, UserAgent: ua} }},
+		{"ClientHelloID", func() *Transport { return &Transport{ClientHelloID: tls.HelloChrome_133} }},
+		{"HexStream", func() *Transport { return &Transport{ClientHelloHexStream: stream} }},
+	}
+}
+
+// newBenchServer 启动返回 benchBodySize 字节响应体的 HTTP/2 TLS 服务器
+func newBenchServer(b *testing.B) *httptest.Server {
+	b.Helper()
+	body := make([]byte, benchBodySize)
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/body" {
+			w.Write(body)
+		}
+	}))
+	ts.EnableHTTP2 = true
+	ts.TLS = &stdtls.Config{SessionTicketsDisabled: true}
+	ts.StartTLS()
+	b.Cleanup(ts.Close)
+	return ts
+}
+
+// BenchmarkHandshake 每次请求建立新连接，报告包括握手在内的请求耗时，
+// 以及其中 TLS 握手的耗时 (handshake-ns/op)
+func BenchmarkHandshake(b *testing.B) {
+	ts := newBenchServer(b)
+	for _, bt := range benchTransports(b) {
+		b.Run(bt.name, func(b *testing.B) {
+			tr := bt.new()
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			tr.ForceAttemptHTTP2 = true
+			tr.DisableKeepAlives = true
+			defer tr.CloseIdleConnections()
+
+			var start time.Time
+			var handshake time.Duration
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				TLSHandshakeStart: func() { start = time.Now() },
+				TLSHandshakeDone:  func(tls.ConnectionState, error) { handshake += time.Since(start) },
+			})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+			b.ReportMetric(float64(handshake.Nanoseconds())/float64(b.N), "handshake-ns/op")
+		})
+	}
+}
+
+// BenchmarkThroughput 在同一个连接上反复下载 benchBodySize 字节的响应体
+func BenchmarkThroughput(b *testing.B) {
+	ts := newBenchServer(b)
+	for _, bt := range benchTransports(b) {
+		b.Run(bt.name, func(b *testing.B) {
+			tr := bt.new()
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			tr.ForceAttemptHTTP2 = true
+			defer tr.CloseIdleConnections()
+
+			get := func() {
+				req, _ := NewRequest("GET", ts.URL+"/body", nil)
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				n, err := io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if err != nil || n != benchBodySize {
+					b.Fatalf("读取 %d 字节, err = %v", n, err)
+				}
+			}
+			get() // 建立连接
+			b.SetBytes(benchBodySize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				get()
+			}
+		})
+	}
+}
+
+// BenchmarkClientHelloBuild 只构建 ClientHello，不进行网络操作，衡量 utls 路径自身的开销
+func BenchmarkClientHelloBuild(b *testing.B) {
+	for _, bt := range benchTransports(b)[1:] {
+		b.Run(bt.name, func(b *testing.B) {
+			tr := bt.new()
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			defer c2.Close()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				uc, err := tr.NewUConn(c1, &tls.Config{ServerName: "example.com"})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := uc.BuildHandshakeState(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	altSvcDefaultMaxAge = 24 * time.Hour  // Alt-Svc 未指定 ma 时的有效期
+	altSvcBrokenFor     = 5 * time.Minute // HTTP/3 失败后不再尝试的时间
+	altSvcMaxEntries    = 1000            // 缓存的源站数上限
+)
+
+// altSvc 是 Alt-Svc 头部中的一个备选服务
+type altSvc struct {
+	protocol  string        // ALPN 协议 ID，如 h3
+	authority string        // 备选服务的 host:port，host 为空表示与源站相同
+	maxAge    time.Duration // 有效期
+}
+
+// parseAltSvc 解析 Alt-Svc 头部 (RFC 7838)，clear 表示服务端要求清除已缓存的备选服务
+func parseAltSvc(v string) (svcs []altSvc, clear bool) {
+	v = strings.TrimSpace(v)
+	if v == "clear" {
+		return nil, true
+	}
+	for entry := range strings.SplitSeq(v, ",") {
+		params := strings.Split(entry, ";")
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok {
+			continue
+		}
+		protocol, err := url.PathUnescape(strings.TrimSpace(protocol))
+		if err != nil {
+			continue
+		}
+		authority, err = strconv.Unquote(strings.TrimSpace(authority))
+		if err != nil {
+			continue
+		}
+		svc := altSvc{protocol: protocol, authority: authority, maxAge: altSvcDefaultMaxAge}
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.TrimSpace(k) != "ma" {
+				continue
+			}
+			if ma, err := strconv.ParseUint(strings.Trim(strings.TrimSpace(v), `"`), 10, 32); err == nil {
+				svc.maxAge = time.Duration(ma) * time.Second
+			}
+		}
+		svcs = append(svcs, svc)
+	}
+	return svcs, false
+}
+
+// altSvcCache 记录各源站通告的 HTTP/3 服务，零值可以直接使用
+type altSvcCache struct {
+	mu      sync.Mutex
+	entries map[string]*altSvcEntry // 键为源站 host:port
+}
+
+type altSvcEntry struct {
+	authority   string    // HTTP/3 服务的 host:port
+	expires     time.Time // 通告的有效期
+	brokenUntil time.Time // HTTP/3 失败后在此之前不再尝试
+}
+
+// update 按源站 origin 的响应头更新缓存，storage 非 nil 时同时更新其中的记录
+func (c *altSvcCache) update(origin, header string, now time.Time, storage Storage) {
+	svcs, clear := parseAltSvc(header)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if clear {
+		delete(c.entries, origin)
+		if storage != nil {
+			storage.Delete(StorageNamespaceAltSvc, origin)
+		}
+		return
+	}
+	for _, svc := range svcs {
+		if svc.protocol != "h3" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(svc.authority)
+		if err != nil {
+			continue
+		}
+		if host == "" {
+			host, _, _ = net.SplitHostPort(origin)
+		}
+		e := c.entries[origin]
+		if e == nil {
+			e = c.addLocked(origin, now)
+		}
+		e.authority = net.JoinHostPort(host, port)
+		e.expires = now.Add(svc.maxAge)
+		if storage != nil {
+			data, _ := json.Marshal(storedAltSvc{Authority: e.authority, Expires: e.expires})
+			storage.Set(StorageNamespaceAltSvc, origin, data, svc.maxAge)
+		}
+		return
+	}
+}
+
+// storedAltSvc 是 Storage 中一个源站的记录
+type storedAltSvc struct {
+	Authority string    `json:"authority"`
+	Expires   time.Time `json:"expires"`
+}
+
+// addLocked 为 origin 添加一个空记录
+func (c *altSvcCache) addLocked(origin string, now time.Time) *altSvcEntry {
+	if c.entries == nil {
+		c.entries = make(map[string]*altSvcEntry)
+	}
+	c.pruneLocked(now)
+	e := &altSvcEntry{}
+	c.entries[origin] = e
+	return e
+}
+
+// pruneLocked 在达到上限时删除过期的源站，仍超出时删除最早过期的源站
+func (c *altSvcCache) pruneLocked(now time.Time) {
+	if len(c.entries) < altSvcMaxEntries {
+		return
+	}
+	var oldest string
+	for origin, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, origin)
+		} else if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+			oldest = origin
+		}
+	}
+	if len(c.entries) >= altSvcMaxEntries {
+		delete(c.entries, oldest)
+	}
+}
+
+// lookup 返回源站可用的 HTTP/3 服务地址，缓存中没有时从 storage 加载
+func (c *altSvcCache) lookup(origin string, now time.Time, storage Storage) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[origin]
+	if e == nil && storage != nil {
+		if data, ok, _ := storage.Get(StorageNamespaceAltSvc, origin); ok {
+			var stored storedAltSvc
+			if json.Unmarshal(data, &stored) == nil && stored.Authority != "" {
+				e = c.addLocked(origin, now)
+				e.authority, e.expires = stored.Authority, stored.Expires
+			}
+		}
+	}
+	switch {
+	case e == nil:
+		return "", false
+	case now.After(e.expires):
+		delete(c.entries, origin)
+		return "", false
+	case now.Before(e.brokenUntil):
+		return "", false
+	}
+	return e.authority, true
+}
+
+// markBroken 在 HTTP/3 请求失败后暂停使用该源站的 HTTP/3 服务
+func (c *altSvcCache) markBroken(origin string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.entries[origin]; e != nil {
+		e.brokenUntil = now.Add(altSvcBrokenFor)
+	}
+}
+
+// http3AddrKey 是 HTTP3AddrFromContext 的 context 键
+type http3AddrKey struct{}
+
+// HTTP3AddrFromContext 返回 Transport 交给 HTTP3 的请求应连接的地址 (host:port)，
+// 即源站通过 Alt-Svc 通告的 HTTP/3 服务
+//
+// 该地址可能与 req.URL.Host 不同。HTTP3 应连接该地址，但仍以 req.URL 的主机名作为
+// SNI 并校验证书 (RFC 7838 2.1 节)，否则通告 Alt-Svc 的服务端可以把请求引到
+// 持有其他证书的主机。
+func HTTP3AddrFromContext(ctx context.Context) (string, bool) {
+	addr, ok := ctx.Value(http3AddrKey{}).(string)
+	return addr, ok
+}
+
+// http3Request 返回交给 Transport.HTTP3 发送的请求，源站没有可用的 HTTP/3 服务时返回 nil
+//
+// 请求的 URL 仍为源站，备选服务的地址通过 HTTP3AddrFromContext 取得。
+func (t *Transport) http3Request(req *Request) *Request {
+	if t.HTTP3 == nil || req.URL.Scheme != "https" || req.requiresHTTP1() || t.ForceHTTP1 || t.JA4H != "" {
+		return nil
+	}
+	if _, ok := FingerprintFromContext(req.Context()); ok {
+		return nil
+	}
+	authority, ok := t.altSvc.lookup(canonicalAddr(req.URL), t.now(), t.Storage)
+	if !ok {
+		return nil
+	}
+	return req.WithContext(context.WithValue(req.Context(), http3AddrKey{}, authority))
+}
+
+// observeAltSvc 记录 HTTPS 响应中通告的 HTTP/3 服务
+func (t *Transport) observeAltSvc(req *Request, resp *Response) {
+	if t.HTTP3 == nil || req.URL.Scheme != "https" {
+		return
+	}
+	if v := resp.Header.Get("Alt-Svc"); v != "" {
+		t.altSvc.update(canonicalAddr(req.URL), v, t.now(), t.Storage)
+	}
+}
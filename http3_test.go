@@ -0,0 +1,168 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestParseAltSvc 测试 Alt-Svc 头部的解析
+func TestParseAltSvc(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      []altSvc
+		wantClear bool
+	}{
+		{`h3=":443"; ma=2592000, h3-29=":443"; ma=2592000`, []altSvc{
+			{"h3", ":443", 2592000 * time.Second},
+			{"h3-29", ":443", 2592000 * time.Second},
+		}, false},
+		{`h2="alt.example.com:8443", h3=":8443"; persist=1`, []altSvc{
+			{"h2", "alt.example.com:8443", altSvcDefaultMaxAge},
+			{"h3", ":8443", altSvcDefaultMaxAge},
+		}, false},
+		{"clear", nil, true},
+		{"h3", nil, false},
+	}
+	for _, tt := range tests {
+		got, clear := parseAltSvc(tt.in)
+		if clear != tt.wantClear || len(got) != len(tt.want) {
+			t.Errorf("parseAltSvc(%q) got %v %v, want %v %v", tt.in, got, clear, tt.want, tt.wantClear)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseAltSvc(%q)[%d] got %v, want %v", tt.in, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+// fakeHTTP3 记录收到的请求的源站和要连接的地址，err 非 nil 时返回错误
+type fakeHTTP3 struct {
+	hosts []string
+	err   error
+}
+
+func (f *fakeHTTP3) RoundTrip(req *Request) (*Response, error) {
+	addr, _ := HTTP3AddrFromContext(req.Context())
+	f.hosts = append(f.hosts, req.URL.Host+"|"+addr)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Response{StatusCode: 200, Header: Header{}, Body: NoBody, Request: req}, nil
+}
+
+// TestTransportHTTP3 测试按 Alt-Svc 切换到 HTTP/3 以及失败后回退到 TCP
+func TestTransportHTTP3(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Alt-Svc", `h3=":8443"; ma=60`)
+		io.WriteString(w, "tcp")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	hostname := strings.Split(host, ":")[0]
+
+	h3 := &fakeHTTP3{}
+	tr := &Transport{
+		JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		HTTP3:           h3,
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	get := func() *Response {
+		t.Helper()
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get(); resp.Meta.Protocol == "h3" {
+		t.Fatal("第一个请求应使用 TCP")
+	}
+	resp := get()
+	if resp.Meta.Protocol != "h3" {
+		t.Errorf("Meta.Protocol got %v, want h3", resp.Meta.Protocol)
+	}
+	// URL 仍为源站，证书按源站校验
+	if want := host + "|" + hostname + ":8443"; len(h3.hosts) != 1 || h3.hosts[0] != want {
+		t.Errorf("HTTP/3 请求 got %v, want [%v]", h3.hosts, want)
+	}
+	if resp.Request.URL.Host != host {
+		t.Errorf("resp.Request.URL.Host got %v, want %v", resp.Request.URL.Host, host)
+	}
+
+	h3.err = errors.New("quic: handshake timeout")
+	if resp := get(); resp.Meta.Protocol == "h3" || resp.Meta.Attempts != 2 {
+		t.Errorf("失败后应回退到 TCP，got Protocol %v, Attempts %v", resp.Meta.Protocol, resp.Meta.Attempts)
+	}
+	// 回退后暂停使用 HTTP/3
+	get()
+	if len(h3.hosts) != 2 {
+		t.Errorf("HTTP/3 请求次数 got %v, want 2", len(h3.hosts))
+	}
+}
+
+// TestTransportHTTP3Proxy 测试使用代理的请求和无效的请求不交给 Transport.HTTP3
+func TestTransportHTTP3Proxy(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Alt-Svc", `h3=":443"`)
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(nethttp.NotFoundHandler())
+	closed.Close()
+
+	h3 := &fakeHTTP3{}
+	tr := &Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, HTTP3: h3}
+	defer tr.CloseIdleConnections()
+	resp, err := tr.RoundTrip(mustNewRequest(t, "GET", srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	proxyURL, _ := url.Parse(closed.URL)
+	tr.Proxy = ProxyURL(proxyURL)
+	if _, err := tr.RoundTrip(mustNewRequest(t, "GET", srv.URL)); err == nil {
+		t.Error("代理不可用时期望错误")
+	}
+	tr.Proxy = nil
+	req := mustNewRequest(t, "GET", srv.URL)
+	req.Method = "BAD METHOD"
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Error("无效的方法: 期望错误")
+	}
+	if len(h3.hosts) != 0 {
+		t.Errorf("HTTP/3 请求 got %v, want 无", h3.hosts)
+	}
+
+	// 直连的请求使用 HTTP/3
+	if resp, err := tr.RoundTrip(mustNewRequest(t, "GET", srv.URL)); err != nil || resp.Meta.Protocol != "h3" {
+		t.Errorf("直连 got %v, %v, want h3", resp, err)
+	}
+}
+
+func mustNewRequest(t *testing.T, method, rawURL string) *Request {
+	t.Helper()
+	req, err := NewRequest(method, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
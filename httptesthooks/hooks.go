@@ -0,0 +1,52 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httptesthooks 提供 tlshttp Transport 的测试钩子
+//
+// Transport 在若干关键路径上调用这里安装的回调，
+// 下游用户和 fork 可以借此编写确定性的测试（例如在连接重试、
+// 拨号排队或 readLoop 读取下一个响应之前同步），
+// 而无需通过 linkname 访问 tlshttp 的内部变量。
+//
+// 钩子是进程级别的，会影响所有 Transport，只应在测试中使用。
+// 回调在 Transport 内部的 goroutine 上同步执行，不应长时间阻塞。
+package httptesthooks
+
+import "sync/atomic"
+
+// Hooks 是一组测试回调，nil 字段表示不关心该事件
+type Hooks struct {
+	// RoundTripRetried 在 Transport 因可重试的错误
+	// 准备在新连接上重试请求时调用
+	RoundTripRetried func()
+
+	// PrePendingDial 在为请求排队一次新的拨号时调用
+	PrePendingDial func()
+
+	// PostPendingDial 在排队的拨号完成或被取消后调用
+	PostPendingDial func()
+
+	// ReadLoopBeforeNextRead 在 HTTP/1 连接的 readLoop
+	// 处理完一个响应、准备读取下一个响应之前调用
+	ReadLoopBeforeNextRead func()
+}
+
+var current atomic.Pointer[Hooks]
+
+// Set 安装 h 作为当前钩子，并返回恢复之前钩子的函数
+//
+// 典型用法：
+//
+//	defer httptesthooks.Set(&httptesthooks.Hooks{
+//		RoundTripRetried: func() { retried.Add(1) },
+//	})()
+func Set(h *Hooks) (restore func()) {
+	prev := current.Swap(h)
+	return func() { current.Store(prev) }
+}
+
+// Current 返回当前安装的钩子，没有安装时返回 nil
+func Current() *Hooks {
+	return current.Load()
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptesthooks_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	http "github.com/vanling1111/tlshttp"
+	"github.com/vanling1111/tlshttp/httptesthooks"
+)
+
+// TestHooksCalled 测试 Transport 在请求过程中调用已安装的钩子
+func TestHooksCalled(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var preDial, postDial, readLoop atomic.Int32
+	restore := httptesthooks.Set(&httptesthooks.Hooks{
+		PrePendingDial:         func() { preDial.Add(1) },
+		PostPendingDial:        func() { postDial.Add(1) },
+		ReadLoopBeforeNextRead: func() { readLoop.Add(1) },
+	})
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	client := &http.Client{Transport: tr}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if got := preDial.Load(); got != 1 {
+		t.Errorf("PrePendingDial 调用次数 = %d, want 1（第二个请求应复用连接）", got)
+	}
+	if got := postDial.Load(); got != 1 {
+		t.Errorf("PostPendingDial 调用次数 = %d, want 1", got)
+	}
+	if got := readLoop.Load(); got < 1 {
+		t.Errorf("ReadLoopBeforeNextRead 调用次数 = %d, want >= 1", got)
+	}
+
+	restore()
+	if httptesthooks.Current() != nil {
+		t.Error("restore 之后 Current() 应该为 nil")
+	}
+}
@@ -79,6 +79,13 @@ func WithClientTrace(ctx context.Context, trace *ClientTrace) context.Context {
 //
 // See https://blog.golang.org/http-tracing for more.
 type ClientTrace struct {
+	// GotRequestID is called at the start of Transport.RoundTrip
+	// with the ID assigned to the request: the one set with
+	// http.WithRequestID, or one generated by the Transport. The
+	// same ID is used for every retry of the request and is
+	// recorded in errors returned by RoundTrip.
+	GotRequestID func(id string)
+
 	// GetConn is called before a connection is created or
 	// retrieved from an idle pool. The hostPort is the
 	// "host:port" of the target or proxy. GetConn is called even
@@ -91,6 +98,12 @@ type ClientTrace struct {
 	// Transport.RoundTrip.
 	GotConn func(GotConnInfo)
 
+	// ConnWaitDone is called just before GotConn for connections
+	// obtained by the Transport's pool, with how long the request
+	// was queued waiting for one. It is not called for requests
+	// sent on an already established HTTP/2 connection.
+	ConnWaitDone func(ConnWaitInfo)
+
 	// PutIdleConn is called when the connection is returned to
 	// the idle pool. If err is nil, the connection was
 	// successfully returned to the idle pool. If err is non-nil,
@@ -101,6 +114,12 @@ type ClientTrace struct {
 	// For HTTP/2, this hook is not currently used.
 	PutIdleConn func(err error)
 
+	// GotGoAway is called when the server sends an HTTP/2 GOAWAY
+	// frame on the connection while the request is in flight,
+	// once per frame. A server shutting down gracefully typically
+	// sends two.
+	GotGoAway func(GoAwayInfo)
+
 	// GotFirstResponseByte is called when the first byte of the response
 	// headers is available.
 	GotFirstResponseByte func()
@@ -234,6 +253,43 @@ func (t *ClientTrace) hasNetHooks() bool {
 	return t.DNSStart != nil || t.DNSDone != nil || t.ConnectStart != nil || t.ConnectDone != nil
 }
 
+// GoAwayInfo is the argument to the [ClientTrace.GotGoAway] function.
+type GoAwayInfo struct {
+	// LastStreamID is the highest stream ID the server may have
+	// processed. Streams above it were not processed.
+	LastStreamID uint32
+
+	// ErrCode is the HTTP/2 error code; zero (NO_ERROR) is a
+	// graceful shutdown, such as a load balancer restarting.
+	ErrCode uint32
+
+	// DebugData is the opaque diagnostic data the server attached
+	// to the frame, often naming the reason for the shutdown.
+	DebugData string
+
+	// Retried reports whether the request was above LastStreamID
+	// and is retried on a new connection. It is retried only if
+	// its body can be replayed; otherwise RoundTrip fails.
+	Retried bool
+}
+
+// ConnWaitInfo is the argument to the [ClientTrace.ConnWaitDone]
+// function. A request whose connection was dialed for it without
+// queuing has both fields zero; the dial itself is reported by
+// ConnectStart, ConnectDone and the TLS hooks.
+type ConnWaitInfo struct {
+	// DialWait is the time the request waited for permission to
+	// dial because the host already had Transport.MaxConnsPerHost
+	// connections.
+	DialWait time.Duration
+
+	// IdleWait is the time from GetConn until the request was
+	// handed a connection released by another request, or zero if
+	// it used a connection that was already idle or one dialed
+	// for it.
+	IdleWait time.Duration
+}
+
 // GotConnInfo is the argument to the [ClientTrace.GotConn] function and
 // contains information about the obtained connection.
 type GotConnInfo struct {
@@ -253,4 +309,96 @@ type GotConnInfo struct {
 	// IdleTime reports how long the connection was previously
 	// idle, if WasIdle is true.
 	IdleTime time.Duration
+
+	// ConnKey identifies the connection pool the connection was
+	// taken from, in the form "proxy|scheme|addr". Requests that
+	// report the same ConnKey are eligible to share connections.
+	// Proxy passwords are redacted.
+	ConnKey string
+
+	// Protocol is the application protocol used on the
+	// connection: "http/1.1" or "h2".
+	Protocol string
+
+	// FingerprintHash is the JA3 hash (hex-encoded MD5) of the
+	// ClientHello that was sent when the connection was
+	// established. It is empty for plain-text connections and
+	// connections made without a custom TLS fingerprint.
+	FingerprintHash string
+
+	// JA3N is the JA3N hash of the same ClientHello: the JA3 hash
+	// computed with the extensions sorted, which stays the same
+	// when the extension order is randomized.
+	JA3N string
+
+	// JA4X is the JA4X fingerprint of each certificate the server
+	// presented, leaf first. It is empty for plain-text connections.
+	JA4X []string
+
+	// JA4L is the latency part of the server's JA4L fingerprint:
+	// half the time, in microseconds, between sending the
+	// ClientHello and receiving the ServerHello. The TTL part is
+	// not available to a userspace client. It is empty for
+	// plain-text connections.
+	JA4L string
+
+	// JA3S is the JA3S hash (hex-encoded MD5) and JA4S the JA4S
+	// fingerprint of the ServerHello. A change for the same server
+	// often means a TLS-terminating middlebox or a different CDN.
+	// Both are empty for plain-text connections.
+	JA3S string
+	JA4S string
+
+	// ECHAccepted reports whether the server accepted the
+	// Encrypted Client Hello offered on the connection.
+	ECHAccepted bool
+
+	// Proxy is the URL of the proxy the connection goes through,
+	// with any password redacted, or empty for direct connections.
+	Proxy string
+
+	// SCTs lists the Signed Certificate Timestamps the server
+	// provided and the result of verifying each one. It is empty
+	// unless the Transport has CTLogs configured.
+	SCTs []SCT
+
+	// Origins lists the origins the server has declared, so far, that
+	// the connection is authoritative for with HTTP/2 ORIGIN frames
+	// (RFC 8336), such as "https://cdn.example.com". It is empty for
+	// HTTP/1.1 connections and servers that send no ORIGIN frames.
+	Origins []string
+
+	// ConnHosts lists the hosts ("host:port") the HTTP/2 connection
+	// has served, starting with the one it was dialed for. More than
+	// one means requests for different hostnames were coalesced onto
+	// the connection; see Transport.HTTP2Coalescing. It is empty for
+	// HTTP/1.1 connections.
+	ConnHosts []string
+
+	// Coalesced reports whether the request was sent on an HTTP/2
+	// connection that was dialed for a different host.
+	Coalesced bool
+}
+
+// SCT describes a Certificate Transparency Signed Certificate
+// Timestamp provided for the server's certificate.
+type SCT struct {
+	// LogID is the SHA-256 hash of the log's public key.
+	LogID [32]byte
+
+	// Log is the description of the log from the Transport's
+	// CTLogs, or empty if the log is unknown.
+	Log string
+
+	// Timestamp is when the log issued the SCT.
+	Timestamp time.Time
+
+	// Source is where the SCT came from: "tls" for the TLS
+	// extension, "certificate" for one embedded in the leaf
+	// certificate, or "ocsp" for a stapled OCSP response.
+	Source string
+
+	// Err is nil if the SCT's signature was verified with the
+	// log's key, and otherwise describes why it was not.
+	Err error
 }
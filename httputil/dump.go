@@ -15,6 +15,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	tlshttp "github.com/vanling1111/tlshttp"
 )
 
 // drainBody reads all of b to memory and then returns two equivalent
@@ -335,3 +337,37 @@ func DumpResponse(resp *http.Response, body bool) ([]byte, error) {
 	}
 	return b.Bytes(), nil
 }
+
+// DumpRequestOutRedacted is like [DumpRequestOut] but passes the dump
+// through r with [tlshttp.RedactWire], masking credentials such as the
+// Authorization and Cookie headers. If r is nil, [tlshttp.DefaultRedactor]
+// is used.
+func DumpRequestOutRedacted(req *http.Request, body bool, r tlshttp.Redactor) ([]byte, error) {
+	dump, err := DumpRequestOut(req, body)
+	if err != nil {
+		return nil, err
+	}
+	return tlshttp.RedactWire(dump, r), nil
+}
+
+// DumpRequestRedacted is like [DumpRequest] but passes the dump
+// through r with [tlshttp.RedactWire]. If r is nil, [tlshttp.DefaultRedactor]
+// is used.
+func DumpRequestRedacted(req *http.Request, body bool, r tlshttp.Redactor) ([]byte, error) {
+	dump, err := DumpRequest(req, body)
+	if err != nil {
+		return nil, err
+	}
+	return tlshttp.RedactWire(dump, r), nil
+}
+
+// DumpResponseRedacted is like [DumpResponse] but passes the dump
+// through r with [tlshttp.RedactWire], masking Set-Cookie and other
+// sensitive headers. If r is nil, [tlshttp.DefaultRedactor] is used.
+func DumpResponseRedacted(resp *http.Response, body bool, r tlshttp.Redactor) ([]byte, error) {
+	dump, err := DumpResponse(resp, body)
+	if err != nil {
+		return nil, err
+	}
+	return tlshttp.RedactWire(dump, r), nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httputil
+
+import (
+	"bufio"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	tlshttp "github.com/vanling1111/tlshttp"
+)
+
+// TestDumpRedacted 测试 Redacted 转储函数遮盖凭据，且不改变原转储函数的输出
+func TestDumpRedacted(t *testing.T) {
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest("POST", "https://example.com/login", strings.NewReader(`{"password":"hunter2"}`))
+		req.Header.Set("Authorization", "Bearer tok3n")
+		req.Header.Set("Cookie", "sid=s3cret")
+		return req
+	}
+	r := &tlshttp.Redaction{BodyPatterns: []*regexp.Regexp{regexp.MustCompile(`"password":"([^"]*)"`)}}
+	secrets := []string{"tok3n", "s3cret", "hunter2"}
+
+	out, err := DumpRequestOutRedacted(newReq(), true, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := DumpRequestRedacted(newReq(), true, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dump := range map[string][]byte{"DumpRequestOutRedacted": out, "DumpRequestRedacted": in} {
+		for _, secret := range secrets {
+			if strings.Contains(string(dump), secret) {
+				t.Errorf("%s 仍包含 %q:\n%s", name, secret, dump)
+			}
+		}
+		for _, want := range []string{"Authorization: Bearer ***", "Cookie: sid=***", `"password":"***"`} {
+			if !strings.Contains(string(dump), want) {
+				t.Errorf("%s 缺少 %q:\n%s", name, want, dump)
+			}
+		}
+	}
+	if plain, _ := DumpRequestOut(newReq(), false); !strings.Contains(string(plain), "tok3n") {
+		t.Errorf("DumpRequestOut 的输出不应改变:\n%s", plain)
+	}
+
+	raw := "HTTP/1.1 200 OK\r\nSet-Cookie: sid=s3cret; Path=/\r\nContent-Length: 2\r\n\r\nok"
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := DumpResponseRedacted(resp, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dump); strings.Contains(got, "s3cret") || !strings.Contains(got, "Set-Cookie: sid=***; Path=/") || !strings.HasSuffix(got, "ok") {
+		t.Errorf("DumpResponseRedacted got:\n%s", got)
+	}
+}
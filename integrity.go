@@ -0,0 +1,297 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// 支持的摘要算法名称（小写，与 RFC 9530 的算法注册名一致）
+const (
+	DigestMD5    = "md5"
+	DigestSHA1   = "sha-1"
+	DigestSHA256 = "sha-256"
+	DigestSHA384 = "sha-384"
+	DigestSHA512 = "sha-512"
+)
+
+var (
+	// ErrNoBodyDigest 表示响应中没有可校验的 Content-Digest/Digest/Content-MD5 头
+	ErrNoBodyDigest = errors.New("响应没有可校验的摘要头")
+
+	// ErrBodyDigestDecoded 表示响应体已被 Transport 自动解压，
+	// 摘要头描述的是编码后的字节，无法再校验
+	ErrBodyDigestDecoded = errors.New("响应体已被自动解压，无法校验编码后的摘要")
+)
+
+// newDigestHash 返回 alg 对应的 hash.Hash，不支持的算法返回 nil
+func newDigestHash(alg string) hash.Hash {
+	switch alg {
+	case DigestMD5:
+		return md5.New()
+	case DigestSHA1:
+		return sha1.New()
+	case DigestSHA256:
+		return sha256.New()
+	case DigestSHA384:
+		return sha512.New384()
+	case DigestSHA512:
+		return sha512.New()
+	}
+	return nil
+}
+
+// IntegrityError 在响应体摘要与期望值不一致时由 ChecksumBody.Read 返回
+type IntegrityError struct {
+	Source    string // 期望值来源，如 "Content-Digest"、"Digest"、"Content-MD5"、"integrity"
+	Algorithm string // 摘要算法
+	Expected  []byte // 期望的摘要（有多个候选值时为第一个）
+	Actual    []byte // 实际计算出的摘要
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("响应体完整性校验失败 (%s %s): 期望 %s, 实际 %s",
+		e.Source, e.Algorithm,
+		base64.StdEncoding.EncodeToString(e.Expected),
+		base64.StdEncoding.EncodeToString(e.Actual))
+}
+
+// digestExpectation 描述一个待校验的摘要，want 中任意一个匹配即视为通过
+type digestExpectation struct {
+	source string
+	alg    string
+	want   [][]byte
+}
+
+// ChecksumBody 在读取响应体的同时计算摘要，避免为了校验而二次读取
+//
+// 读到 EOF 时会校验所有期望摘要，不一致则返回 *IntegrityError 代替 io.EOF。
+// 只用于计算摘要（没有期望值）时，可在读完后通过 Sum 获取结果。
+// ChecksumBody 不是并发安全的，与普通响应体的使用方式相同。
+type ChecksumBody struct {
+	body      io.ReadCloser
+	hashes    map[string]hash.Hash
+	expect    []digestExpectation
+	n         int64
+	done      bool
+	verifyErr error
+}
+
+// NewChecksumBody 包装 body，读取时同时计算 algs 指定的摘要
+func NewChecksumBody(body io.ReadCloser, algs ...string) (*ChecksumBody, error) {
+	b := &ChecksumBody{body: body, hashes: make(map[string]hash.Hash, len(algs))}
+	for _, alg := range algs {
+		if err := b.addHash(strings.ToLower(alg)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *ChecksumBody) addHash(alg string) error {
+	if _, ok := b.hashes[alg]; ok {
+		return nil
+	}
+	h := newDigestHash(alg)
+	if h == nil {
+		return fmt.Errorf("不支持的摘要算法: %s", alg)
+	}
+	b.hashes[alg] = h
+	return nil
+}
+
+func (b *ChecksumBody) Read(p []byte) (int, error) {
+	if b.done {
+		if b.verifyErr != nil {
+			return 0, b.verifyErr
+		}
+		return 0, io.EOF
+	}
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.n += int64(n)
+		for _, h := range b.hashes {
+			h.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.done = true
+		if b.verifyErr = b.verify(); b.verifyErr != nil {
+			return n, b.verifyErr
+		}
+	}
+	return n, err
+}
+
+// Close 关闭底层响应体
+func (b *ChecksumBody) Close() error {
+	return b.body.Close()
+}
+
+// Sum 返回 alg 当前的摘要值；未计算该算法时返回 nil
+//
+// 在读到 EOF 之前调用得到的是已读部分的摘要。
+func (b *ChecksumBody) Sum(alg string) []byte {
+	h, ok := b.hashes[strings.ToLower(alg)]
+	if !ok {
+		return nil
+	}
+	return h.Sum(nil)
+}
+
+// BytesRead 返回目前已读取的字节数
+func (b *ChecksumBody) BytesRead() int64 {
+	return b.n
+}
+
+// Verified 报告是否已读到 EOF 且所有期望摘要均匹配
+func (b *ChecksumBody) Verified() bool {
+	return b.done && b.verifyErr == nil
+}
+
+func (b *ChecksumBody) verify() error {
+	for _, e := range b.expect {
+		got := b.hashes[e.alg].Sum(nil)
+		matched := false
+		for _, w := range e.want {
+			if bytes.Equal(got, w) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &IntegrityError{Source: e.source, Algorithm: e.alg, Expected: e.want[0], Actual: got}
+		}
+	}
+	return nil
+}
+
+func (b *ChecksumBody) expectDigest(source, alg string, want ...[]byte) {
+	b.addHash(alg)
+	b.expect = append(b.expect, digestExpectation{source: source, alg: alg, want: want})
+}
+
+// VerifyBodyDigest 根据响应的 Content-Digest (RFC 9530)、Digest (RFC 3230)
+// 与 Content-MD5 头校验响应体，校验在读取 resp.Body 的过程中完成
+//
+// 成功时 resp.Body 被替换为返回的 *ChecksumBody，读到 EOF 时若摘要不一致，
+// Read 返回 *IntegrityError。不认识的算法会被忽略；没有任何可校验的摘要时
+// 返回 ErrNoBodyDigest，resp.Body 保持不变。
+//
+// 这些摘要描述的是带 Content-Encoding 的原始字节，因此 Transport 已自动
+// 解压的响应（resp.Uncompressed 为 true）会返回 ErrBodyDigestDecoded；
+// 需要校验时请设置 DisableCompression 或自行发送 Accept-Encoding。
+func VerifyBodyDigest(resp *Response) (*ChecksumBody, error) {
+	b := &ChecksumBody{body: resp.Body, hashes: make(map[string]hash.Hash)}
+	for _, v := range resp.Header.Values("Content-Digest") {
+		for alg, sum := range parseDigestHeader(v, true) {
+			b.expectDigest("Content-Digest", alg, sum)
+		}
+	}
+	for _, v := range resp.Header.Values("Digest") {
+		for alg, sum := range parseDigestHeader(v, false) {
+			b.expectDigest("Digest", alg, sum)
+		}
+	}
+	if v := resp.Header.Get("Content-MD5"); v != "" {
+		if sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v)); err == nil && len(sum) == md5.Size {
+			b.expectDigest("Content-MD5", DigestMD5, sum)
+		}
+	}
+	if len(b.expect) == 0 {
+		return nil, ErrNoBodyDigest
+	}
+	if resp.Uncompressed {
+		return nil, ErrBodyDigestDecoded
+	}
+	resp.Body = b
+	return b, nil
+}
+
+// parseDigestHeader 解析摘要头，返回算法名到摘要值的映射
+//
+// structured 为 true 时按 RFC 9530 的 Content-Digest 格式（sha-256=:base64:）解析，
+// 否则按 RFC 3230 的 Digest 格式（SHA-256=base64）解析。
+func parseDigestHeader(v string, structured bool) map[string][]byte {
+	out := make(map[string][]byte)
+	for _, item := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		alg := strings.ToLower(strings.TrimSpace(name))
+		if !structured && alg == "sha" {
+			alg = DigestSHA1
+		}
+		value = strings.TrimSpace(value)
+		if structured {
+			if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				continue
+			}
+			value = value[1 : len(value)-1]
+		}
+		h := newDigestHash(alg)
+		if h == nil {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != h.Size() {
+			continue
+		}
+		out[alg] = sum
+	}
+	return out
+}
+
+// VerifySubresourceIntegrity 按子资源完整性 (SRI) 元数据校验响应体
+//
+// integrity 的格式与 HTML integrity 属性相同，如
+// "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC"。
+// 按规范只使用其中最强的算法，该算法的任意一个值匹配即通过。
+// SRI 描述的是解码后的内容，因此可以与自动解压一起使用。
+// 成功时 resp.Body 被替换为返回的 *ChecksumBody。
+func VerifySubresourceIntegrity(resp *Response, integrity string) (*ChecksumBody, error) {
+	strength := map[string]int{DigestSHA256: 1, DigestSHA384: 2, DigestSHA512: 3}
+	best := ""
+	var want [][]byte
+	for _, token := range strings.Fields(integrity) {
+		token, _, _ = strings.Cut(token, "?")
+		prefix, value, ok := strings.Cut(token, "-")
+		if !ok {
+			continue
+		}
+		alg := "sha-" + strings.TrimPrefix(strings.ToLower(prefix), "sha")
+		if strength[alg] == 0 {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != newDigestHash(alg).Size() {
+			continue
+		}
+		switch {
+		case strength[alg] > strength[best]:
+			best, want = alg, [][]byte{sum}
+		case alg == best:
+			want = append(want, sum)
+		}
+	}
+	if best == "" {
+		return nil, fmt.Errorf("无效的 integrity 元数据: %q", integrity)
+	}
+	b := &ChecksumBody{body: resp.Body, hashes: make(map[string]hash.Hash)}
+	b.expectDigest("integrity", best, want...)
+	resp.Body = b
+	return b, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestVerifyBodyDigest 测试摘要头校验
+func TestVerifyBodyDigest(t *testing.T) {
+	const body = "hello, tlshttp"
+	md5Sum := md5.Sum([]byte(body))
+	sha256Sum := sha256.Sum256([]byte(body))
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		header  Header
+		wantErr error
+		wantBad bool
+	}{
+		{"Content-Digest 正确", Header{"Content-Digest": {"sha-256=:" + b64(sha256Sum[:]) + ":"}}, nil, false},
+		{"Digest 正确", Header{"Digest": {"SHA-256=" + b64(sha256Sum[:]) + ",unixsum=30637"}}, nil, false},
+		{"Content-MD5 正确", Header{"Content-Md5": {b64(md5Sum[:])}}, nil, false},
+		{"Content-MD5 错误", Header{"Content-Md5": {b64(sha256Sum[:16])}}, nil, true},
+		{"没有摘要头", Header{}, ErrNoBodyDigest, false},
+		{"只有未知算法", Header{"Digest": {"unixsum=30637"}}, ErrNoBodyDigest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &Response{Header: tt.header, Body: io.NopCloser(strings.NewReader(body))}
+			cb, err := VerifyBodyDigest(resp)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyBodyDigest() 错误 = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := io.ReadAll(resp.Body)
+			var ie *IntegrityError
+			if errors.As(err, &ie) != tt.wantBad {
+				t.Fatalf("ReadAll() 错误 = %v, wantBad %v", err, tt.wantBad)
+			}
+			if string(got) != body {
+				t.Errorf("ReadAll() = %q, want %q", got, body)
+			}
+			if cb.Verified() == tt.wantBad {
+				t.Errorf("Verified() = %v, want %v", cb.Verified(), !tt.wantBad)
+			}
+		})
+	}
+
+	t.Run("已自动解压", func(t *testing.T) {
+		resp := &Response{
+			Header:       Header{"Content-Md5": {b64(md5Sum[:])}},
+			Body:         io.NopCloser(strings.NewReader(body)),
+			Uncompressed: true,
+		}
+		if _, err := VerifyBodyDigest(resp); !errors.Is(err, ErrBodyDigestDecoded) {
+			t.Errorf("VerifyBodyDigest() 错误 = %v, want ErrBodyDigestDecoded", err)
+		}
+	})
+}
+
+// TestVerifySubresourceIntegrity 测试 SRI 校验只使用最强算法
+func TestVerifySubresourceIntegrity(t *testing.T) {
+	const body = "alert('hi')"
+	sha256Sum := sha256.Sum256([]byte(body))
+	sha384Sum := sha512.Sum384([]byte(body))
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name      string
+		integrity string
+		wantBad   bool
+	}{
+		{"sha384 匹配", "sha384-" + b64(sha384Sum[:]), false},
+		{"多个值任一匹配", "sha384-" + b64(make([]byte, 48)) + " sha384-" + b64(sha384Sum[:]) + "?ct=js", false},
+		{"较弱算法被忽略", "sha256-" + b64(sha256Sum[:]) + " sha384-" + b64(make([]byte, 48)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &Response{Header: Header{}, Body: io.NopCloser(strings.NewReader(body))}
+			if _, err := VerifySubresourceIntegrity(resp, tt.integrity); err != nil {
+				t.Fatalf("VerifySubresourceIntegrity() 错误 = %v", err)
+			}
+			_, err := io.ReadAll(resp.Body)
+			var ie *IntegrityError
+			if errors.As(err, &ie) != tt.wantBad {
+				t.Errorf("ReadAll() 错误 = %v, wantBad %v", err, tt.wantBad)
+			}
+		})
+	}
+
+	if _, err := VerifySubresourceIntegrity(&Response{}, "md5-abc"); err == nil {
+		t.Error("不支持的 integrity 元数据应该返回错误")
+	}
+}
+
+// TestChecksumBodySum 测试流式计算摘要
+func TestChecksumBodySum(t *testing.T) {
+	const body = "archive me"
+	cb, err := NewChecksumBody(io.NopCloser(strings.NewReader(body)), "SHA-256", DigestMD5)
+	if err != nil {
+		t.Fatalf("NewChecksumBody() 错误 = %v", err)
+	}
+	if _, err := io.Copy(io.Discard, cb); err != nil {
+		t.Fatalf("io.Copy() 错误 = %v", err)
+	}
+	want := sha256.Sum256([]byte(body))
+	if got := cb.Sum(DigestSHA256); string(got) != string(want[:]) {
+		t.Errorf("Sum(sha-256) = %x, want %x", got, want)
+	}
+	if cb.BytesRead() != int64(len(body)) {
+		t.Errorf("BytesRead() = %d, want %d", cb.BytesRead(), len(body))
+	}
+	if _, err := NewChecksumBody(io.NopCloser(strings.NewReader("")), "crc32"); err == nil {
+		t.Error("不支持的算法应该返回错误")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// IPSNIPolicy 决定连接 IP 地址 (且没有设置 TLSClientConfig.ServerName) 时
+// ClientHello 是否包含 server_name 扩展
+type IPSNIPolicy int
+
+const (
+	// IPSNIOmit 与浏览器一样不发送 server_name 扩展，是默认值。
+	// 扩展从 ClientHello 中移除，其余扩展的顺序不变
+	IPSNIOmit IPSNIPolicy = iota
+
+	// IPSNISend 在 server_name 扩展中发送 IP 地址的文本形式。RFC 6066
+	// 不允许这样做，浏览器也不会，只用于要求 SNI 的服务端
+	IPSNISend
+)
+
+// sniIP 返回 serverName 表示的 IP 地址的文本形式，不是 IP 地址时返回空
+func sniIP(serverName string) string {
+	host := strings.TrimSuffix(strings.TrimPrefix(serverName, "["), "]")
+	if i := strings.LastIndex(host, "%"); i > 0 {
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// applyIPSNIPolicy 按 t.IPSNI 处理 serverName 为 IP 地址时 spec 中的 server_name 扩展
+//
+// utls 在 ServerName 为 IP 地址时输出空的 server_name 扩展，握手时跳过，
+// 但 spec 中仍保留该扩展，基于 spec 计算的 JA4 等与实际发送的不一致。
+// 这里直接移除它，或以 IP 地址的文本替换为原样发送的扩展。
+func (t *Transport) applyIPSNIPolicy(spec *tls.ClientHelloSpec, serverName string) {
+	ip := sniIP(serverName)
+	if ip == "" {
+		return
+	}
+	switch t.IPSNI {
+	case IPSNIOmit:
+		spec.Extensions = slices.DeleteFunc(spec.Extensions, func(e tls.TLSExtension) bool {
+			_, ok := e.(*tls.SNIExtension)
+			return ok
+		})
+	case IPSNISend:
+		for i, e := range spec.Extensions {
+			if _, ok := e.(*tls.SNIExtension); ok {
+				spec.Extensions[i] = &tls.GenericExtension{Id: 0, Data: sniExtensionData(ip)}
+			}
+		}
+	}
+}
+
+// sniExtensionData 返回只包含 host_name 类型名称 name 的 server_name 扩展数据
+func sniExtensionData(name string) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(0) // host_name
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(name))
+		})
+	})
+	return b.BytesOrPanic()
+}
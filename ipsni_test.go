@@ -0,0 +1,78 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	ctls "crypto/tls"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTransportIPSNI 测试连接 IP 地址时按 IPSNI 省略或发送 SNI
+func TestTransportIPSNI(t *testing.T) {
+	sni := make(chan string, 1)
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{
+		GetConfigForClient: func(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+			sni <- hello.ServerName
+			return nil, nil
+		},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "https://")
+
+	tests := []struct {
+		name    string
+		policy  IPSNIPolicy
+		wantSNI string
+		wantJA4 byte // JA4 的第 4 个字符
+	}{
+		{"省略", IPSNIOmit, "", 'i'},
+		{"发送", IPSNISend, "127.0.0.1", 'd'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				IPSNI:             tt.policy,
+				DisableKeepAlives: true,
+			}
+			resp, err := (&Client{Transport: tr}).Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := <-sni; got != tt.wantSNI {
+				t.Errorf("服务端看到的 SNI got %q, want %q", got, tt.wantSNI)
+			}
+
+			spec, err := tr.BuildSpec(host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ja4FieldsFromSpec(spec).ja4()[3]; got != tt.wantJA4 {
+				t.Errorf("JA4 SNI 字符 got %c, want %c", got, tt.wantJA4)
+			}
+			var gotSNI string
+			for _, e := range spec.Extensions {
+				if g, ok := e.(*tls.GenericExtension); ok && g.Id == 0 {
+					gotSNI = string(g.Data[5:])
+				}
+				if _, ok := e.(*tls.SNIExtension); ok {
+					t.Error("spec 中不应有 SNIExtension")
+				}
+			}
+			if gotSNI != tt.wantSNI {
+				t.Errorf("SNI got %q, want %q", gotSNI, tt.wantSNI)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/refraction-networking/utls/dicttls"
+)
+
+// IssueSeverity 是 ValidateJA3 发现的问题的严重程度
+type IssueSeverity int
+
+const (
+	// IssueError 表示使用该 JA3 建连会失败，或生成的 ClientHello 不符合 TLS 规范
+	IssueError IssueSeverity = iota
+
+	// IssueWarning 表示可以建连，但 ClientHello 与浏览器不符、可能无法协商预期的版本，
+	// 或包含不安全的组件
+	IssueWarning
+)
+
+func (s IssueSeverity) String() string {
+	switch s {
+	case IssueError:
+		return "error"
+	case IssueWarning:
+		return "warning"
+	}
+	return "IssueSeverity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Issue 是 ValidateJA3 发现的一个问题
+type Issue struct {
+	Severity IssueSeverity
+
+	// Field 是问题所在的 JA3 字段："version"、"ciphers"、"extensions"、"curves"、
+	// "point_formats"；整体格式错误时为 "format"
+	Field string
+
+	// Message 是可读的问题描述
+	Message string
+}
+
+func (i Issue) String() string {
+	return i.Severity.String() + ": " + i.Field + ": " + i.Message
+}
+
+// ja3TLS13Ciphers 是 TLS 1.3 的密码套件
+var ja3TLS13Ciphers = []uint16{0x1301, 0x1302, 0x1303, 0x1304, 0x1305}
+
+// ValidateJA3 检查 JA3 字符串，返回格式错误、未知的密码套件和扩展、矛盾的组合
+// (如 TLS 1.3 密码套件没有 supported_versions 扩展)、GREASE 位置不当等问题，
+// 没有问题时返回空。
+//
+// 建连时 JA3 错误只会得到简短的错误，这里在使用前给出完整的诊断，适合检查从
+// 抓包工具或配置文件中复制的 JA3。存在 IssueError 时建连会失败或 ClientHello 不合规。
+func ValidateJA3(ja3 string) []Issue {
+	var issues []Issue
+	add := func(sev IssueSeverity, field, format string, args ...any) {
+		issues = append(issues, Issue{sev, field, fmt.Sprintf(format, args...)})
+	}
+
+	parts := strings.Split(strings.TrimSpace(ja3), ",")
+	if len(parts) != 5 {
+		add(IssueError, "format", "JA3 应为逗号分隔的 5 个部分 (版本,密码套件,扩展,曲线,点格式)，实际为 %d 个", len(parts))
+		return issues
+	}
+
+	fields := []string{"ciphers", "extensions", "curves", "point_formats"}
+	lists := make([][]uint16, len(fields))
+	ok := true
+	for i, field := range fields {
+		var bad bool
+		lists[i], bad = lintJA3List(parts[i+1], field, add)
+		ok = ok && !bad
+	}
+	ciphers, exts, curves, points := lists[0], lists[1], lists[2], lists[3]
+	has := func(id uint16) bool { return slices.Contains(exts, id) }
+
+	// 版本
+	version, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		add(IssueError, "version", "无效的 TLS 版本 %q，应为十进制数字，如 771 (TLS 1.2)", parts[0])
+	} else {
+		extStrs := make([]string, len(exts))
+		for i, e := range exts {
+			extStrs[i] = strconv.Itoa(int(e))
+		}
+		if _, err := checkJA3Version(uint16(version), extStrs); err != nil {
+			add(IssueError, "version", "%v", err)
+		} else if !has(43) && version < tls.VersionTLS12 {
+			add(IssueError, "version", "版本 %d (%s) 低于 TLS 1.2，浏览器已不再使用", version, tls.VersionName(uint16(version)))
+		}
+	}
+	if !ok {
+		// 列表本身无法解析时，组合检查没有意义
+		return issues
+	}
+
+	// 密码套件
+	if len(ciphers) == 0 {
+		add(IssueError, "ciphers", "密码套件列表为空")
+	}
+	for _, c := range ciphers {
+		if _, known := dicttls.DictCipherSuiteValueIndexed[c]; !known && !isGREASEValue(c) {
+			add(IssueWarning, "ciphers", "未知的密码套件 %d (0x%04x)", c, c)
+		}
+	}
+
+	// 扩展
+	extMap := getCompleteExtensionMap()
+	for i, e := range exts {
+		if isGREASEValue(e) {
+			continue
+		}
+		name := specExtensionName(e)
+		switch _, builtin := extMap[strconv.Itoa(int(e))]; {
+		case name == "":
+			add(IssueWarning, "extensions", "未知的扩展 %d，将作为空扩展发送", e)
+		case !builtin && e != 10 && e != 11 && e != 16:
+			add(IssueWarning, "extensions", "扩展 %d (%s) 没有内置实现，将作为空扩展发送，"+
+				"可以用 TLSExtensionsConfig.ReplaceExtensions 或 RawExtensions 提供内容", e, name)
+		}
+		switch {
+		case e == 41 && i != len(exts)-1:
+			add(IssueError, "extensions", "pre_shared_key (41) 必须是最后一个扩展 (RFC 8446 4.2.11)")
+		case e == 21 && i != len(exts)-1 && !(i == len(exts)-2 && exts[i+1] == 41):
+			add(IssueWarning, "extensions", "padding (21) 不在最后 (或 pre_shared_key 之前)，浏览器总是把它放在末尾")
+		}
+	}
+
+	// GREASE：JA3 按定义不含 GREASE 值，Chrome 的 GREASE 由 User-Agent 自动添加
+	for i, list := range lists {
+		for j, v := range list {
+			if !isGREASEValue(v) {
+				continue
+			}
+			msg := "包含 GREASE 值 %d (0x%04x)，JA3 按定义不含 GREASE，" +
+				"Chrome 的 GREASE 会自动添加，抓包得到的 JA3 应去掉该值"
+			switch {
+			case fields[i] == "extensions" && j != 0 && j != len(list)-1:
+				msg += "；浏览器只把 GREASE 扩展放在开头或末尾"
+			case fields[i] != "extensions" && j != 0:
+				msg += "；浏览器只把 GREASE 放在列表开头"
+			}
+			add(IssueWarning, fields[i], msg, v, v)
+		}
+	}
+
+	// 矛盾的组合
+	hasTLS13 := slices.ContainsFunc(ciphers, func(c uint16) bool { return slices.Contains(ja3TLS13Ciphers, c) })
+	if hasTLS13 && !has(43) {
+		add(IssueWarning, "extensions", "有 TLS 1.3 密码套件但没有 supported_versions (43)，只能协商 TLS 1.2")
+	}
+	if has(43) && !hasTLS13 {
+		add(IssueWarning, "ciphers", "有 supported_versions (43) 但没有 TLS 1.3 密码套件 (4865-4867)，无法协商 TLS 1.3")
+	}
+	if has(43) && !has(51) {
+		add(IssueWarning, "extensions", "有 supported_versions (43) 但没有 key_share (51)，无法协商 TLS 1.3")
+	}
+	if has(51) && !has(10) {
+		add(IssueError, "extensions", "有 key_share (51) 但没有 supported_groups (10)，RFC 8446 要求两者同时发送")
+	}
+	if len(curves) > 0 && !has(10) {
+		add(IssueWarning, "curves", "列出了曲线但没有 supported_groups (10)，曲线不会发送")
+	}
+	if has(10) && len(curves) == 0 {
+		add(IssueError, "curves", "有 supported_groups (10) 但曲线列表为空")
+	}
+	if len(points) > 0 && !has(11) {
+		add(IssueWarning, "point_formats", "列出了点格式但没有 ec_point_formats (11)，点格式不会发送")
+	}
+	if has(11) && len(points) == 0 {
+		add(IssueError, "point_formats", "有 ec_point_formats (11) 但点格式列表为空")
+	}
+	hasECDHE := slices.ContainsFunc(ciphers, func(c uint16) bool {
+		return strings.Contains(dicttls.DictCipherSuiteValueIndexed[c], "_ECDHE_")
+	})
+	if hasECDHE && !has(10) {
+		add(IssueWarning, "extensions", "有 ECDHE 密码套件但没有 supported_groups (10)，服务端只能假定默认曲线")
+	}
+	if has(41) && !has(45) {
+		add(IssueError, "extensions", "有 pre_shared_key (41) 但没有 psk_key_exchange_modes (45)，RFC 8446 要求两者同时发送")
+	}
+
+	// 不安全的组件
+	if err := checkWeakTLS(ciphers, exts); err != nil {
+		add(IssueWarning, "ciphers", "%v", err)
+	}
+	return issues
+}
+
+// lintJA3List 解析 JA3 中 "-" 分隔的列表，报告无效、空和重复的值，
+// 无法解析时 bad 为 true
+func lintJA3List(s, field string, add func(IssueSeverity, string, string, ...any)) (vs []uint16, bad bool) {
+	if s == "" {
+		return nil, false
+	}
+	bits := 16
+	if field == "point_formats" {
+		bits = 8
+	}
+	for i, item := range strings.Split(s, "-") {
+		if item == "" {
+			add(IssueWarning, field, "第 %d 个值为空 (多余的 \"-\")，会被忽略", i+1)
+			continue
+		}
+		v, err := strconv.ParseUint(item, 10, bits)
+		if err != nil {
+			add(IssueError, field, "第 %d 个值 %q 不是 0-%d 的十进制数字", i+1, item, uint64(1)<<bits-1)
+			bad = true
+			continue
+		}
+		if slices.Contains(vs, uint16(v)) {
+			sev := IssueWarning
+			if field == "extensions" {
+				// 重复的扩展会被服务端拒绝 (RFC 8446 4.2)
+				sev = IssueError
+			}
+			add(sev, field, "值 %d 重复", v)
+		}
+		vs = append(vs, uint16(v))
+	}
+	return vs, bad
+}
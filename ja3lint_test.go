@@ -0,0 +1,129 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestValidateJA3 测试 JA3 检查发现的问题
+func TestValidateJA3(t *testing.T) {
+	type want struct {
+		sev   IssueSeverity
+		field string
+		msg   string // Message 中应包含的内容
+	}
+	tests := []struct {
+		name string
+		ja3  string
+		want []want
+	}{
+		{
+			name: "Chrome",
+			ja3:  "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		},
+		{
+			name: "TLS 1.2",
+			ja3:  "771,49195-49199,0-10-11-13-65281,29-23,0",
+		},
+		{
+			name: "部分数量错误",
+			ja3:  "771,4865,0-10,29",
+			want: []want{{IssueError, "format", "实际为 4 个"}},
+		},
+		{
+			name: "无效的值",
+			ja3:  "abc,4865-x,0--10,29,0",
+			want: []want{
+				{IssueError, "ciphers", `"x"`},
+				{IssueWarning, "extensions", "第 2 个值为空"},
+				{IssueError, "version", `"abc"`},
+			},
+		},
+		{
+			name: "点格式超出范围",
+			ja3:  "771,49195,0-10-11,29,256",
+			want: []want{{IssueError, "point_formats", "0-255"}},
+		},
+		{
+			name: "版本与 supported_versions 矛盾",
+			ja3:  "772,4865,0-10-43-51,29,",
+			want: []want{{IssueError, "version", "771"}},
+		},
+		{
+			name: "TLS 1.3 密码套件没有 supported_versions",
+			ja3:  "771,4865-49195,0-10-11,29,0",
+			want: []want{{IssueWarning, "extensions", "supported_versions (43)"}},
+		},
+		{
+			name: "supported_versions 没有 key_share 和 TLS 1.3 密码套件",
+			ja3:  "771,49195,0-10-11-43,29,0",
+			want: []want{
+				{IssueWarning, "ciphers", "没有 TLS 1.3 密码套件"},
+				{IssueWarning, "extensions", "key_share (51)"},
+			},
+		},
+		{
+			name: "未知和重复的扩展",
+			ja3:  "771,49195-49195,0-10-11-10-4660,29,0",
+			want: []want{
+				{IssueWarning, "ciphers", "值 49195 重复"},
+				{IssueError, "extensions", "值 10 重复"},
+				{IssueWarning, "extensions", "未知的扩展 4660"},
+			},
+		},
+		{
+			name: "pre_shared_key 和 padding 的位置",
+			ja3:  "771,4865,0-41-21-10-43-51,29,",
+			want: []want{
+				{IssueError, "extensions", "pre_shared_key (41) 必须是最后一个扩展"},
+				{IssueWarning, "extensions", "padding (21)"},
+				{IssueError, "extensions", "psk_key_exchange_modes (45)"},
+			},
+		},
+		{
+			name: "GREASE",
+			ja3:  "771,4865-2570,2570-0-10-6682-43-51,29,",
+			want: []want{
+				{IssueWarning, "ciphers", "GREASE 值 2570 (0x0a0a)，JA3 按定义不含 GREASE，Chrome 的 GREASE 会自动添加，抓包得到的 JA3 应去掉该值；浏览器只把 GREASE 放在列表开头"},
+				{IssueWarning, "extensions", "GREASE 值 2570 (0x0a0a)，JA3 按定义不含 GREASE，Chrome 的 GREASE 会自动添加，抓包得到的 JA3 应去掉该值"},
+				{IssueWarning, "extensions", "GREASE 值 6682 (0x1a1a)，JA3 按定义不含 GREASE，Chrome 的 GREASE 会自动添加，抓包得到的 JA3 应去掉该值；浏览器只把 GREASE 扩展放在开头或末尾"},
+			},
+		},
+		{
+			name: "曲线和点格式与扩展不一致",
+			ja3:  "771,49195,0-10-51,,0",
+			want: []want{
+				{IssueError, "curves", "曲线列表为空"},
+				{IssueWarning, "point_formats", "ec_point_formats (11)"},
+			},
+		},
+		{
+			name: "不安全的组件",
+			ja3:  "771,49195-5,0-10-11-15,29,0",
+			want: []want{
+				{IssueWarning, "extensions", "扩展 15 (heartbeat) 没有内置实现"},
+				{IssueWarning, "ciphers", "RC4"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := ValidateJA3(tt.ja3)
+			if len(issues) != len(tt.want) {
+				t.Fatalf("got %d 个问题 %v, want %d 个", len(issues), issues, len(tt.want))
+			}
+			for _, w := range tt.want {
+				if !slices.ContainsFunc(issues, func(i Issue) bool {
+					return i.Severity == w.sev && i.Field == w.field && strings.Contains(i.Message, w.msg)
+				}) {
+					t.Errorf("缺少问题 %v %s %q, got %v", w.sev, w.field, w.msg, issues)
+				}
+			}
+		})
+	}
+}
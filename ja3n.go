@@ -0,0 +1,127 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ja3nTemplates 是 JA3FromJA3N 参考的浏览器扩展顺序，不含 GREASE
+var ja3nTemplates = [][]uint16{
+	// Chrome，实际每个连接随机排列
+	{0, 23, 65281, 10, 11, 35, 16, 5, 13, 18, 51, 45, 43, 27, 17513, 17613, 65037, 21, 41},
+	// Firefox
+	{0, 23, 65281, 10, 11, 35, 16, 5, 34, 51, 43, 13, 45, 28, 65037, 21, 41},
+	// Safari
+	{0, 23, 65281, 10, 11, 16, 5, 13, 18, 51, 45, 43, 27, 21},
+}
+
+// ja3n 返回 ClientHello 的 JA3N 字符串，即扩展按数值升序排列的 JA3
+func (h *clientHelloInfo) ja3n() string {
+	n := *h
+	n.extensions = slices.Sorted(slices.Values(h.extensions))
+	return n.ja3()
+}
+
+// JA3N 返回 JA3 字符串的规范化形式 (JA3N)：扩展按数值升序排列，并去除其中的 GREASE 值
+//
+// Chrome 110 起每个连接随机排列扩展，同一浏览器的 JA3 每次都不同，JA3N 不受顺序影响，
+// 许多公开的指纹库因此只提供 JA3N。JA3N 的哈希同 JA3，是 JA3N 字符串的 MD5。
+func JA3N(ja3 string) (string, error) {
+	parts, exts, err := splitJA3N(ja3)
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(exts)
+	parts[2] = joinJA3List(exts)
+	return strings.Join(parts, ","), nil
+}
+
+// JA3FromJA3N 为只知道 JA3N 的指纹生成浏览器可能使用的扩展顺序，返回可以用于
+// Transport.JA3 的 JA3 字符串
+//
+// 顺序取自扩展最接近的浏览器 (Chrome、Firefox 或 Safari)，参考顺序中没有的扩展
+// 按数值排在其后，padding (21) 和 pre_shared_key (41) 总是在最后。
+// 与 Chrome 比较时扩展顺序本就随机，可以同时设置 RandomJA3。
+func JA3FromJA3N(ja3n string) (string, error) {
+	parts, exts, err := splitJA3N(ja3n)
+	if err != nil {
+		return "", err
+	}
+	template := ja3nTemplates[0]
+	bestScore, bestMissing := -1, 0
+	for _, t := range ja3nTemplates {
+		// 得分是共有的扩展数减去参考顺序中没有的扩展数，相同时选缺少扩展少的
+		score, missing := 0, 0
+		for _, e := range exts {
+			if slices.Contains(t, e) {
+				score++
+			} else {
+				score--
+			}
+		}
+		for _, e := range t {
+			if !slices.Contains(exts, e) {
+				missing++
+			}
+		}
+		if score > bestScore || score == bestScore && missing < bestMissing {
+			template, bestScore, bestMissing = t, score, missing
+		}
+	}
+
+	var ordered []uint16
+	for _, e := range template {
+		if e != 21 && e != 41 && slices.Contains(exts, e) {
+			ordered = append(ordered, e)
+		}
+	}
+	rest := slices.DeleteFunc(slices.Clone(exts), func(e uint16) bool {
+		return e == 21 || e == 41 || slices.Contains(template, e)
+	})
+	slices.Sort(rest)
+	ordered = append(ordered, rest...)
+	for _, e := range []uint16{21, 41} {
+		if slices.Contains(exts, e) {
+			ordered = append(ordered, e)
+		}
+	}
+	parts[2] = joinJA3List(ordered)
+	return strings.Join(parts, ","), nil
+}
+
+// splitJA3N 把 JA3 字符串分为 5 个部分并解析扩展列表，去除其中的 GREASE 值和重复值
+func splitJA3N(ja3 string) ([]string, []uint16, error) {
+	parts := strings.Split(ja3, ",")
+	if len(parts) != 5 {
+		return nil, nil, fmt.Errorf("无效的 JA3 格式，应为 5 个部分，实际为 %d 个", len(parts))
+	}
+	var exts []uint16
+	for _, s := range strings.Split(parts[2], "-") {
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的扩展: %s", s)
+		}
+		if !isGREASEValue(uint16(v)) && !slices.Contains(exts, uint16(v)) {
+			exts = append(exts, uint16(v))
+		}
+	}
+	return parts, exts, nil
+}
+
+// joinJA3List 返回 JA3 中 "-" 分隔的列表
+func joinJA3List(vs []uint16) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, "-")
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	ctls "crypto/tls"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestJA3N 测试 JA3N 的计算和从 JA3N 生成扩展顺序
+func TestJA3N(t *testing.T) {
+	const (
+		firefoxJA3 = "771,4865-4867-4866-49195-49199-52393-52392-49196-49200-49162-49161-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-34-51-43-13-45-28-65037,29-23-24-25-256-257,0"
+		safariJA3  = "771,4865-4866-4867-49196-49195-52393-49200-49199-52392-49162-49161-49172-49171-157-156-53-47-49160-49170-10,0-23-65281-10-11-16-5-13-18-51-45-43-27-21,29-23-24-25,0"
+	)
+	got, err := JA3N(chromeJA4JA3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-5-10-11-13-16-18-21-23-27-35-43-45-51-17513-65281,29-23-24,0"
+	if got != want {
+		t.Errorf("JA3N got %v, want %v", got, want)
+	}
+	if got, _ := JA3N("771,4865,2570-0-10-10,29,0"); got != "771,4865,0-10,29,0" {
+		t.Errorf("GREASE 和重复值 got %v", got)
+	}
+
+	for _, ja3 := range []string{firefoxJA3, safariJA3, chromeJA4JA3} {
+		ja3n, _ := JA3N(ja3)
+		got, err := JA3FromJA3N(ja3n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ja3 != chromeJA4JA3 && got != ja3 {
+			t.Errorf("JA3FromJA3N got %v, want %v", got, ja3)
+		}
+		if n, _ := JA3N(got); n != ja3n {
+			t.Errorf("JA3FromJA3N 的 JA3N got %v, want %v", n, ja3n)
+		}
+		if issues := ValidateJA3(got); len(issues) != 0 {
+			t.Errorf("JA3FromJA3N(%v) 的问题: %v", ja3n, issues)
+		}
+	}
+	// 参考顺序中没有的扩展排在后面，padding 和 pre_shared_key 在最后
+	if got, _ := JA3FromJA3N("771,4865,0-10-11-21-41-45-51-43-57,29,0"); got != "771,4865,0-10-11-51-43-45-57-21-41,29,0" {
+		t.Errorf("JA3FromJA3N got %v", got)
+	}
+
+	for _, bad := range []string{"771,4865,0-10", "771,4865,0-x,29,0"} {
+		if _, err := JA3N(bad); err == nil {
+			t.Errorf("JA3N(%q) 没有返回错误", bad)
+		}
+		if _, err := JA3FromJA3N(bad); err == nil {
+			t.Errorf("JA3FromJA3N(%q) 没有返回错误", bad)
+		}
+	}
+}
+
+// TestResponseMetaJA3N 测试扩展顺序随机时 JA3N 不变
+func TestResponseMetaJA3N(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{SessionTicketsDisabled: true}
+	ts.StartTLS()
+	defer ts.Close()
+	tr := &Transport{
+		JA3:               chromeJA4JA3,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"},
+		DisableKeepAlives: true,
+		PermuteExtensions: true,
+	}
+	ja3n, _ := JA3N(chromeJA4JA3)
+	ja3s := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		ja3s[resp.Meta.FingerprintHash] = true
+		if resp.Meta.JA3N != ja3Hash(ja3n) {
+			t.Errorf("JA3N got %v, want %v", resp.Meta.JA3N, ja3Hash(ja3n))
+		}
+	}
+	if len(ja3s) < 2 {
+		t.Errorf("5 个连接只有 %d 种 JA3", len(ja3s))
+	}
+}
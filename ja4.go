@@ -0,0 +1,235 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// ja4Fields 是计算 JA4 (TLS 客户端) 指纹所需的 ClientHello 字段
+// 各列表保持报文中的原始顺序，可以包含 GREASE 值
+type ja4Fields struct {
+	quic       bool     // 是否为 QUIC，决定协议字符 q/t
+	version    uint16   // supported_versions 中的最高版本，没有该扩展时为 legacy_version
+	sni        bool     // 是否发送了 SNI 扩展
+	ciphers    []uint16 // 密码套件
+	extensions []uint16 // 扩展类型
+	sigAlgs    []uint16 // signature_algorithms (13)，保持原始顺序
+	alpn       string   // 第一个 ALPN 值
+}
+
+// ja4 返回 FoxIO 规范的 JA4 字符串，如 t13d1516h2_8daaf6152771_e5627efa2ab1
+//
+// 第一段为协议、TLS 版本、SNI (d 为域名，i 为无 SNI)、密码套件数和扩展数
+// (均不含 GREASE，最多 99) 以及第一个 ALPN 值的首尾字符；第二段为排序后
+// 密码套件的 SHA-256 前 12 位；第三段为排序后扩展 (不含 SNI 和 ALPN)
+// 与原始顺序签名算法的 SHA-256 前 12 位。
+func (f *ja4Fields) ja4() string {
+	var b strings.Builder
+	if f.quic {
+		b.WriteByte('q')
+	} else {
+		b.WriteByte('t')
+	}
+	b.WriteString(ja4Version(f.version))
+	if f.sni {
+		b.WriteByte('d')
+	} else {
+		b.WriteByte('i')
+	}
+	ciphers := withoutGREASE(f.ciphers)
+	exts := withoutGREASE(f.extensions)
+	fmt.Fprintf(&b, "%02d%02d", min(len(ciphers), 99), min(len(exts), 99))
+	b.WriteString(ja4ALPN(f.alpn))
+
+	b.WriteByte('_')
+	slices.Sort(ciphers)
+	b.WriteString(ja4Hash(ja4HexList(ciphers)))
+
+	b.WriteByte('_')
+	exts = slices.DeleteFunc(exts, func(e uint16) bool { return e == 0 || e == 16 })
+	slices.Sort(exts)
+	s := ja4HexList(exts)
+	if s != "" && len(f.sigAlgs) > 0 {
+		s += "_" + ja4HexList(f.sigAlgs)
+	}
+	b.WriteString(ja4Hash(s))
+	return b.String()
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0200:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	}
+	return "00"
+}
+
+// ja4ALPN 返回 ALPN 值的首尾字符，不是字母或数字时改用其十六进制表示的首尾字符
+func ja4ALPN(alpn string) string {
+	if alpn == "" {
+		return "00"
+	}
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(alpn))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func isAlnum(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func ja4HexList(vs []uint16) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ja4Hash 返回 s 的 SHA-256 前 12 位，s 为空时返回 12 个 0
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+func withoutGREASE(vs []uint16) []uint16 {
+	out := make([]uint16, 0, len(vs))
+	for _, v := range vs {
+		if !isGREASEValue(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ja4FieldsFromSpec 从 spec 中提取 JA4 字段
+//
+// 内容为空、握手时不会发送的扩展不计入；SNI 扩展总是计入，
+// 连接 IP 地址时 applyIPSNIPolicy 已按 IPSNI 移除或替换了该扩展。
+func ja4FieldsFromSpec(spec *tls.ClientHelloSpec) *ja4Fields {
+	f := &ja4Fields{ciphers: spec.CipherSuites, version: spec.TLSVersMax}
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.UtlsGREASEExtension:
+			f.extensions = append(f.extensions, tls.GREASE_PLACEHOLDER)
+		case *tls.SNIExtension:
+			f.sni = true
+			f.extensions = append(f.extensions, 0)
+		case *tls.UtlsPaddingExtension:
+			f.extensions = append(f.extensions, 21)
+		case *tls.ALPNExtension:
+			if len(e.AlpnProtocols) > 0 {
+				f.alpn = e.AlpnProtocols[0]
+			}
+			f.extensions = append(f.extensions, 16)
+		case *tls.SignatureAlgorithmsExtension:
+			for _, s := range e.SupportedSignatureAlgorithms {
+				f.sigAlgs = append(f.sigAlgs, uint16(s))
+			}
+			f.extensions = append(f.extensions, 13)
+		default:
+			// 其余扩展从其编码中读取类型
+			n := e.Len()
+			if n < 4 {
+				continue
+			}
+			buf := make([]byte, n)
+			if m, _ := e.Read(buf); m < 4 {
+				continue
+			}
+			typ := uint16(buf[0])<<8 | uint16(buf[1])
+			if typ == 0 { // IPSNISend 时以 GenericExtension 发送的 SNI
+				f.sni = true
+			}
+			f.extensions = append(f.extensions, typ)
+		}
+	}
+	return f
+}
+
+// applyJA4Target 调整 spec 使其 JA4 与 target 一致，无法一致时返回错误
+//
+// JA4 对密码套件和扩展排序，因此扩展顺序（包括 RandomJA3 随机化后的顺序）
+// 不影响结果。ALPN 段可以直接调整：00 移除 ALPN 扩展，h2 使用
+// h2 和 http/1.1，h1 只使用 http/1.1。其余各段由 JA3 等指纹配置决定，
+// 只做校验；SNI 字符取决于连接的主机，不做校验。
+func applyJA4Target(spec *tls.ClientHelloSpec, target string) error {
+	parts := strings.Split(target, "_")
+	if len(parts) != 3 || len(parts[0]) != 10 {
+		return fmt.Errorf("无效的 JA4 格式: %s", target)
+	}
+	switch parts[0][8:] {
+	case "00":
+		spec.Extensions = slices.DeleteFunc(spec.Extensions, func(e tls.TLSExtension) bool {
+			_, ok := e.(*tls.ALPNExtension)
+			return ok
+		})
+	case "h2", "h1":
+		protos := []string{"h2", "http/1.1"}
+		if parts[0][8:] == "h1" {
+			protos = []string{"http/1.1"}
+		}
+		for _, e := range spec.Extensions {
+			if alpn, ok := e.(*tls.ALPNExtension); ok {
+				alpn.AlpnProtocols = protos
+			}
+		}
+	}
+
+	got := ja4FieldsFromSpec(spec).ja4()
+	gotParts := strings.Split(got, "_")
+	var diff []string
+	if got, want := gotParts[0][:3], parts[0][:3]; got != want {
+		diff = append(diff, "协议或 TLS 版本")
+	}
+	if got, want := gotParts[0][4:6], parts[0][4:6]; got != want {
+		diff = append(diff, "密码套件数量")
+	}
+	if got, want := gotParts[0][6:8], parts[0][6:8]; got != want {
+		diff = append(diff, "扩展数量")
+	}
+	if got, want := gotParts[0][8:], parts[0][8:]; got != want {
+		diff = append(diff, "ALPN")
+	}
+	if gotParts[1] != parts[1] {
+		diff = append(diff, "密码套件")
+	}
+	if gotParts[2] != parts[2] {
+		diff = append(diff, "扩展或签名算法")
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("ClientHello 的 JA4 为 %s，与目标 %s 的%s不一致", got, target, strings.Join(diff, "、"))
+	}
+	return nil
+}
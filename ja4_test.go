@@ -0,0 +1,91 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"strings"
+	"testing"
+)
+
+// chromeJA4 是 FoxIO JA4 规范中的 Chrome 示例
+const chromeJA4 = "t13d1516h2_8daaf6152771_e5627efa2ab1"
+
+// chromeJA4JA3 是与 chromeJA4 对应的 JA3（GREASE 由 User-Agent 自动加入）
+const chromeJA4JA3 = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0"
+
+// TestJA4 测试 JA4 字符串的计算
+func TestJA4(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields ja4Fields
+		want   string
+	}{
+		{
+			name: "规范示例",
+			fields: ja4Fields{
+				version:    0x0304,
+				sni:        true,
+				ciphers:    []uint16{0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+				extensions: []uint16{0x1a1a, 0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010, 0x0005, 0x000d, 0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x4469, 0x0015},
+				sigAlgs:    []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601},
+				alpn:       "h2",
+			},
+			want: chromeJA4,
+		},
+		{
+			name:   "没有密码套件和扩展",
+			fields: ja4Fields{version: 0x0303},
+			want:   "t12i000000_000000000000_000000000000",
+		},
+		{
+			name:   "非字母数字的 ALPN",
+			fields: ja4Fields{quic: true, version: 0x0304, ciphers: []uint16{0x1301}, alpn: "\xab\xcd"},
+			want:   "q13i0100ad_" + ja4Hash("1301") + "_000000000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fields.ja4(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTransportJA4Target 测试按目标 JA4 构建并校验 ClientHello
+func TestTransportJA4Target(t *testing.T) {
+	chromeUA := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	tests := []struct {
+		name    string
+		ja4     string
+		random  bool
+		wantErr string
+	}{
+		{name: "一致", ja4: chromeJA4},
+		{name: "扩展随机化不影响 JA4", ja4: chromeJA4, random: true},
+		{name: "调整为 HTTP/1.1", ja4: "t13d1516h1_8daaf6152771_e5627efa2ab1"},
+		{name: "移除 ALPN", ja4: "t13d151500_8daaf6152771_e5627efa2ab1"},
+		{name: "密码套件不一致", ja4: "t13d1516h2_000000000000_e5627efa2ab1", wantErr: "密码套件"},
+		{name: "无效格式", ja4: "t13d1516h2", wantErr: "无效的 JA4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{JA3: chromeJA4JA3, UserAgent: chromeUA, JA4: tt.ja4, RandomJA3: tt.random}
+			spec, err := tr.BuildSpec("example.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildSpec() 失败: %v", err)
+			}
+			if got := ja4FieldsFromSpec(spec).ja4(); got != tt.ja4 {
+				t.Errorf("got %s, want %s", got, tt.ja4)
+			}
+		})
+	}
+}
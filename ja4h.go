@@ -0,0 +1,294 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ja4hFields 是计算 JA4H (HTTP 客户端) 指纹所需的请求字段
+type ja4hFields struct {
+	method  string
+	version string   // HTTP 版本，如 "11"、"20"
+	headers []string // 按发送顺序的头部名称，不含伪头部
+	cookies []string // Cookie 头部的值
+	referer bool
+	lang    string // Accept-Language 头部的值
+}
+
+// add 记录一个发送的头部
+func (f *ja4hFields) add(name, value string) {
+	switch {
+	case strings.HasPrefix(name, ":"):
+	case strings.EqualFold(name, "cookie"):
+		f.cookies = append(f.cookies, value)
+	case strings.EqualFold(name, "referer"):
+		f.referer = true
+	default:
+		if f.lang == "" && strings.EqualFold(name, "accept-language") {
+			f.lang = value
+		}
+		f.headers = append(f.headers, name)
+	}
+}
+
+// ja4h 返回 FoxIO 规范的 JA4H 字符串，如 ge11cn060000_4e59edc1297a_000000000000_000000000000
+//
+// 第一段为方法的前两个字母、HTTP 版本、是否有 Cookie (c/n)、是否有 Referer (r/n)、
+// 头部数量 (不含 Cookie 和 Referer，最多 99) 以及 Accept-Language 的前 4 个字符；
+// 第二段为按发送顺序的头部名称的 SHA-256 前 12 位；第三、四段分别为排序后的
+// Cookie 名称和 Cookie 名称=值的 SHA-256 前 12 位。
+func (f *ja4hFields) ja4h() string {
+	var b strings.Builder
+	method := strings.ToLower(f.method)
+	if len(method) > 2 {
+		method = method[:2]
+	}
+	b.WriteString(method)
+	b.WriteString(f.version)
+
+	var names, pairs []string
+	for _, v := range f.cookies {
+		for _, c := range strings.Split(v, ";") {
+			c = strings.TrimSpace(c)
+			if c == "" {
+				continue
+			}
+			name, _, _ := strings.Cut(c, "=")
+			names = append(names, name)
+			pairs = append(pairs, c)
+		}
+	}
+	if len(pairs) > 0 {
+		b.WriteByte('c')
+	} else {
+		b.WriteByte('n')
+	}
+	if f.referer {
+		b.WriteByte('r')
+	} else {
+		b.WriteByte('n')
+	}
+	fmt.Fprintf(&b, "%02d", min(len(f.headers), 99))
+	b.WriteString(ja4hLang(f.lang))
+
+	b.WriteByte('_')
+	b.WriteString(ja4Hash(strings.Join(f.headers, ",")))
+	slices.Sort(names)
+	slices.Sort(pairs)
+	b.WriteByte('_')
+	b.WriteString(ja4Hash(strings.Join(names, ",")))
+	b.WriteByte('_')
+	b.WriteString(ja4Hash(strings.Join(pairs, ",")))
+	return b.String()
+}
+
+// ja4hLang 返回第一个语言去掉 - 后的前 4 个小写字符，不足时补 0
+func ja4hLang(lang string) string {
+	lang, _, _ = strings.Cut(lang, ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "-", ""))
+	lang += "0000"
+	return lang[:4]
+}
+
+// JA4HError 表示请求的 JA4H 与 Transport.JA4H 不一致，请求没有发出，使用的连接也不受影响
+type JA4HError struct {
+	Target string   // 目标 JA4H
+	Got    string   // 请求实际的 JA4H
+	Parts  []string // 不一致的部分
+}
+
+func (e *JA4HError) Error() string {
+	return fmt.Sprintf("请求的 JA4H 为 %s，与目标 %s 的%s不一致", e.Got, e.Target, strings.Join(e.Parts, "、"))
+}
+
+// checkJA4H 校验请求的 JA4H 是否与 target 一致
+//
+// Cookie 名称和值 (第三、四段) 取决于站点下发的 Cookie，不做校验，
+// 只校验第一段和头部名称顺序 (第二段)。
+func checkJA4H(target string, f *ja4hFields) error {
+	parts := strings.Split(target, "_")
+	if len(parts) != 4 || len(parts[0]) != 12 {
+		return fmt.Errorf("无效的 JA4H 格式: %s", target)
+	}
+	got := f.ja4h()
+	gotParts := strings.Split(got, "_")
+	var diff []string
+	if gotParts[0][:2] != parts[0][:2] {
+		diff = append(diff, "请求方法")
+	}
+	if gotParts[0][2:4] != parts[0][2:4] {
+		diff = append(diff, "HTTP 版本")
+	}
+	if gotParts[0][4] != parts[0][4] {
+		diff = append(diff, "Cookie")
+	}
+	if gotParts[0][5] != parts[0][5] {
+		diff = append(diff, "Referer")
+	}
+	if gotParts[0][6:8] != parts[0][6:8] {
+		diff = append(diff, "头部数量")
+	}
+	if gotParts[0][8:] != parts[0][8:] {
+		diff = append(diff, "Accept-Language")
+	}
+	if gotParts[1] != parts[1] {
+		diff = append(diff, "头部名称或顺序")
+	}
+	if len(diff) > 0 {
+		return &JA4HError{Target: target, Got: got, Parts: diff}
+	}
+	return nil
+}
+
+// ja4hFromHeaderBlock 从 HTTP/1 请求的请求行和头部中提取 JA4H 字段
+func ja4hFromHeaderBlock(block []byte) *ja4hFields {
+	lines := strings.Split(string(block), "\r\n")
+	f := &ja4hFields{version: "11"}
+	if fields := strings.Fields(lines[0]); len(fields) == 3 {
+		f.method = fields[0]
+		if fields[2] == "HTTP/1.0" {
+			f.version = "10"
+		}
+	}
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		f.add(name, strings.TrimSpace(value))
+	}
+	return f
+}
+
+// shapeJA4H 按 t.JA4H 调整 req 的头部，需要调整时返回头部为副本的新请求
+//
+// 只调整可以从 JA4H 直接得出的部分：Accept-Language 的前 4 个字符与目标不一致时
+// 改为由目标得出的值 (目标为 0000 时删除)，目标没有 Referer 时删除 Referer。
+// 方法、Cookie、头部数量和名称顺序由请求决定，只在 checkRequestJA4H 中校验。
+func (t *Transport) shapeJA4H(req *Request) *Request {
+	parts := strings.Split(t.JA4H, "_")
+	if len(parts) != 4 || len(parts[0]) != 12 {
+		return req // 格式错误由 checkRequestJA4H 报告
+	}
+	lang := parts[0][8:]
+	var h Header
+	clone := func() {
+		if h == nil {
+			h = req.Header.Clone()
+		}
+	}
+	if al := req.Header.Get("Accept-Language"); (al == "") != (lang == "0000") || (al != "" && ja4hLang(al) != lang) {
+		clone()
+		if lang == "0000" {
+			h.Del("Accept-Language")
+		} else {
+			h.Set("Accept-Language", ja4hLangValue(lang))
+		}
+	}
+	if parts[0][5] == 'n' && req.Header.has("Referer") {
+		clone()
+		h.Del("Referer")
+	}
+	if h == nil {
+		return req
+	}
+	r2 := *req
+	r2.Header = h
+	return &r2
+}
+
+// ja4hLangValue 返回 JA4H 语言段对应的 Accept-Language 值，如 enus 对应 en-US
+func ja4hLangValue(lang string) string {
+	lang = strings.TrimRight(lang, "0")
+	if len(lang) == 4 {
+		return lang[:2] + "-" + strings.ToUpper(lang[2:])
+	}
+	return lang
+}
+
+// checkRequestJA4H 在取得连接前校验 req 的 JA4H 是否与 t.JA4H 一致
+//
+// 请求按目标的 HTTP 版本计算 JA4H，连接实际协商的版本由 checkConnJA4H 校验。
+func (t *Transport) checkRequestJA4H(req *Request, cm connectMethod) error {
+	parts := strings.Split(t.JA4H, "_")
+	if len(parts) != 4 || len(parts[0]) != 12 {
+		return fmt.Errorf("无效的 JA4H 格式: %s", t.JA4H)
+	}
+	h2 := parts[0][2:4] == "20" && cm.targetScheme == "https"
+	return checkJA4H(t.JA4H, t.requestJA4HFields(req, cm, h2))
+}
+
+// checkConnJA4H 校验连接协商的 HTTP 版本是否与 checkRequestJA4H 所用的一致
+func (t *Transport) checkConnJA4H(req *Request, cm connectMethod, pconn *persistConn) error {
+	h2 := pconn.alt != nil
+	if h2 == (t.JA4H[2:4] == "20") {
+		return nil
+	}
+	return checkJA4H(t.JA4H, t.requestJA4HFields(req, cm, h2))
+}
+
+// requestJA4HFields 返回 req 经由 cm 以 HTTP/2 (h2 为 true 时) 或 HTTP/1.1
+// 发送时的 JA4H 字段，包括 Transport 自动添加的头部
+func (t *Transport) requestJA4HFields(req *Request, cm connectMethod, h2 bool) *ja4hFields {
+	if h2 {
+		f := &ja4hFields{method: valueOrDefault(req.Method, MethodGet), version: "20"}
+		trailers, _ := http2commaSeparatedTrailers(req)
+		http2enumerateRegularHeaders(t, req, trailers, http2actualContentLength(req), func(name, value string) {
+			if name == HeaderOrderKey || name == PHeaderOrderKey || name == UnChangedHeaderKey {
+				return
+			}
+			if !req.Header.ContainsUnChangedHeaderKeys(name) {
+				name, _ = http2lowerHeader(name)
+			}
+			f.add(name, value)
+		})
+		return f
+	}
+
+	// 与 persistConn.roundTrip 添加的头部一致
+	extra := make(Header)
+	usingProxy := cm.proxyURL != nil && cm.targetScheme == "http"
+	if usingProxy {
+		if pa := t.proxyAuth(&cm); pa != "" {
+			extra.Set("Proxy-Authorization", pa)
+		}
+	}
+	if !t.DisableCompression && req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" && req.Method != "HEAD" {
+		extra.Set("Accept-Encoding", "gzip")
+	}
+	if t.DisableKeepAlives && !req.wantsClose() && !isProtocolSwitchHeader(req.Header) {
+		extra.Set("Connection", "close")
+	}
+
+	// 以不读取请求体的副本写出请求行和头部：请求体换成不会结束的 Reader，
+	// 使 Content-Length 和 Transfer-Encoding 与实际发送时相同，写完头部后
+	// 由 waitForContinue 中止
+	r2 := *req
+	r2.ctx = context.Background()
+	r2.Header = req.Header.Clone()
+	if req.Body != nil && req.Body != NoBody {
+		r2.Body = io.NopCloser(ja4hBody{})
+	}
+	var buf bytes.Buffer
+	r2.write(&buf, usingProxy, extra, func() bool { return false })
+	block, _, _ := bytes.Cut(buf.Bytes(), []byte("\r\n\r\n"))
+	return ja4hFromHeaderBlock(block)
+}
+
+// ja4hBody 是 requestJA4HFields 使用的请求体，读取时总是返回数据
+type ja4hBody struct{}
+
+func (ja4hBody) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// TestJA4H 测试 JA4H 字符串的计算
+func TestJA4H(t *testing.T) {
+	headers := []string{"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding"}
+	tests := []struct {
+		name string
+		f    ja4hFields
+		want string
+	}{
+		{
+			name: "无 Cookie 和 Referer",
+			f:    ja4hFields{method: "GET", version: "11", headers: headers, lang: "en-US,en;q=0.9"},
+			want: "ge11nn05enus_f3bb7aa45ec4_000000000000_000000000000",
+		},
+		{
+			name: "Cookie 按名称排序",
+			f:    ja4hFields{method: "POST", version: "20", headers: headers, cookies: []string{"b=2; a=1"}, referer: true, lang: "zh"},
+			want: "po20cr05zh00_f3bb7aa45ec4_1eb7c54d5283_06beefe2b477",
+		},
+		{
+			name: "没有 Accept-Language",
+			f:    ja4hFields{method: "HEAD", version: "10"},
+			want: "he10nn000000_000000000000_000000000000_000000000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.ja4h(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestJA4HFromHeaderBlock 测试从 HTTP/1 头部提取字段时排除 Cookie 和 Referer
+func TestJA4HFromHeaderBlock(t *testing.T) {
+	block := "GET / HTTP/1.1\r\nHost: a\r\nUser-Agent: x\r\nAccept: */*\r\nAccept-Language: en-US\r\n" +
+		"Cookie: b=2; a=1\r\nReferer: https://a/\r\nAccept-Encoding: gzip"
+	got := ja4hFromHeaderBlock([]byte(block)).ja4h()
+	want := "ge11cr05enus_f3bb7aa45ec4_1eb7c54d5283_06beefe2b477"
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestTransportJA4H 测试按 JA4H 调整请求，不一致时请求不会发出且连接仍可复用
+func TestTransportJA4H(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		hits.Add(1)
+		if r.Header.Get("Referer") != "" || r.Header.Get("Accept-Language") != "en-US" {
+			t.Errorf("请求没有按 JA4H 调整: %v", r.Header)
+		}
+		io.WriteString(w, "ok")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		forceHTTP1 bool
+		version    string
+	}{
+		{"HTTP/1.1", true, "11"},
+		{"HTTP/2", false, "20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			tr := &Transport{
+				JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				JA4H:              "ge" + tt.version + "nn02enus_000000000000_000000000000_000000000000",
+				ForceHTTP1:        tt.forceHTTP1,
+				ForceAttemptHTTP2: !tt.forceHTTP1,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			}
+			defer tr.CloseIdleConnections()
+
+			var reused []bool
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+			})
+			newReq := func() *Request {
+				req, _ := NewRequestWithContext(ctx, "GET", srv.URL, nil)
+				req.Header.Set("Referer", srv.URL+"/from")
+				return req
+			}
+			do := func() error {
+				resp, err := tr.RoundTrip(newReq())
+				if err != nil {
+					return err
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return nil
+			}
+
+			var e *JA4HError
+			if err := do(); !errors.As(err, &e) {
+				t.Fatalf("err = %v, want *JA4HError", err)
+			}
+			if hits.Load() != 0 || len(reused) != 0 {
+				t.Fatalf("JA4H 不一致时不应取得连接和发出请求")
+			}
+			if got, want := e.Got[:12], "ge"+tt.version+"nn"+e.Got[6:8]+"enus"; got != want {
+				t.Errorf("JA4H 第一段 got %v, want %v", got, want)
+			}
+
+			// 使用请求实际的 JA4H 作为目标
+			good := e.Got
+			tr.JA4H = good
+			if err := do(); err != nil {
+				t.Fatalf("JA4H 一致时请求失败: %v", err)
+			}
+
+			// 不一致的请求不影响已有的连接
+			tr.JA4H = "ge" + tt.version + "nn02enus_000000000000_000000000000_000000000000"
+			if err := do(); !errors.As(err, &e) {
+				t.Fatalf("err = %v, want *JA4HError", err)
+			}
+			tr.JA4H = good
+			if err := do(); err != nil {
+				t.Fatal(err)
+			}
+			if hits.Load() != 2 || len(reused) != 2 || !reused[1] {
+				t.Errorf("服务端收到 %d 个请求, 连接复用 %v, want 2 个请求且复用连接", hits.Load(), reused)
+			}
+		})
+	}
+
+	// 连接协商的 HTTP 版本与目标不一致
+	t.Run("协议不一致", func(t *testing.T) {
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			ForceAttemptHTTP2: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		defer tr.CloseIdleConnections()
+		req, _ := NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Accept-Language", "en-US")
+		tr.JA4H = tr.requestJA4HFields(req, connectMethod{targetScheme: "https"}, false).ja4h()
+		hits.Store(0)
+		_, err := tr.RoundTrip(req)
+		var e *JA4HError
+		if !errors.As(err, &e) || e.Got[2:4] != "20" || hits.Load() != 0 {
+			t.Errorf("err = %v, want HTTP 版本不一致的 *JA4HError", err)
+		}
+	})
+}
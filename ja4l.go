@@ -0,0 +1,161 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// parseJA4L 解析目标 JA4L，返回其延迟部分
+//
+// JA4L 的格式为 <延迟微秒>_<TTL>，如 2500_128，TTL 部分可以省略，
+// 设置时会被忽略。
+func parseJA4L(s string) (time.Duration, error) {
+	latency, _, _ := strings.Cut(s, "_")
+	us, err := strconv.ParseUint(latency, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("无效的 JA4L 格式: %s", s)
+	}
+	return time.Duration(us) * time.Microsecond, nil
+}
+
+// formatJA4L 返回单程延迟 d 对应的 JA4L 延迟部分
+func formatJA4L(d time.Duration) string {
+	return strconv.FormatInt(d.Microseconds(), 10)
+}
+
+// ja4lConn 包装 TLS 握手使用的底层连接，测量 ClientHello 到 ServerHello
+// 的往返时间，并在设置了目标 JA4L 时推迟客户端的第二轮握手消息
+//
+// 服务端以 ServerHello 到客户端下一条消息的间隔的一半作为客户端的 JA4L 延迟，
+// 推迟 2×目标延迟−实测往返时间 即可使其接近目标。目标小于实际延迟时无法调整。
+type ja4lConn struct {
+	net.Conn
+	t      *Transport
+	target time.Duration // 目标单程延迟，零表示不调整
+
+	// 以下字段只在握手期间由握手所在的 goroutine 访问
+	handshaking       atomic.Bool
+	helloSent, helloR time.Time // 发出 ClientHello、收到 ServerHello 的时间
+	paced             bool      // 是否已处理第二轮消息
+	hello             helloCapture
+	sent              handshakeMessages // 发出的握手消息，只在设置了 OnClientHello 时解析
+	clientHellos      [][]byte          // 发出的 ClientHello，HelloRetryRequest 后有两个
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (t *Transport) newJA4LConn(conn net.Conn) (*ja4lConn, error) {
+	c := &ja4lConn{Conn: conn, t: t, closed: make(chan struct{})}
+	if t.JA4L != "" {
+		target, err := parseJA4L(t.JA4L)
+		if err != nil {
+			return nil, err
+		}
+		c.target = target
+	}
+	c.handshaking.Store(true)
+	return c, nil
+}
+
+func (c *ja4lConn) Write(p []byte) (int, error) {
+	if c.handshaking.Load() {
+		switch {
+		case c.helloSent.IsZero():
+			c.helloSent = c.t.now()
+		case !c.helloR.IsZero() && !c.paced:
+			c.paced = true
+			if err := c.pace(); err != nil {
+				return 0, err
+			}
+		}
+		if c.t.OnClientHello != nil {
+			for _, msg := range c.sent.write(p) {
+				if msg[0] == 1 { // client_hello
+					c.clientHellos = append(c.clientHellos, msg)
+				}
+			}
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *ja4lConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.handshaking.Load() {
+		if !c.helloSent.IsZero() && c.helloR.IsZero() {
+			c.helloR = c.t.now()
+		}
+		c.hello.write(p[:n])
+	}
+	return n, err
+}
+
+func (c *ja4lConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+var errJA4LConnClosed = errors.New("连接在 JA4L 延迟期间被关闭")
+
+// pace 按目标延迟推迟第二轮握手消息，连接关闭时提前返回
+func (c *ja4lConn) pace() error {
+	if c.target == 0 {
+		return nil
+	}
+	d := 2*c.target - c.helloR.Sub(c.helloSent)
+	if d <= 0 {
+		return nil
+	}
+	timer := c.t.newTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-c.closed:
+		return errJA4LConnClosed
+	}
+}
+
+// handshakeDone 结束测量，返回服务端的 JA4L 延迟部分，没有测量到时返回空
+//
+// JA4L 以 TCP 的 SYN 到 SYN-ACK 或 ClientHello 到 ServerHello 的往返时间的
+// 一半作为单程延迟。拨号时间包含 DNS 解析且经过代理时测得的是到代理的延迟，
+// 因此这里使用后者。TTL 需要读取原始 IP 报文，无法获得，因此结果只有延迟部分。
+func (c *ja4lConn) handshakeDone() string {
+	c.handshaking.Store(false)
+	if c.helloSent.IsZero() || c.helloR.IsZero() {
+		return ""
+	}
+	return formatJA4L(c.helloR.Sub(c.helloSent) / 2)
+}
+
+// serverFingerprints 返回握手中服务端的 JA3S 哈希和 JA4S，没有解析到 ServerHello 时返回空，
+// 在 handshakeDone 之后调用
+func (c *ja4lConn) serverFingerprints() (ja3s, ja4s string) {
+	if c.hello.hello == nil {
+		return "", ""
+	}
+	return ja3Hash(c.hello.hello.ja3s()), c.hello.hello.ja4s()
+}
+
+// reportClientHellos 以 host 和 c 发出的每个 ClientHello 调用 OnClientHello，
+// 在握手结束后调用
+func (t *Transport) reportClientHellos(host string, c *ja4lConn) {
+	if t.OnClientHello == nil {
+		return
+	}
+	for _, raw := range c.clientHellos {
+		t.OnClientHello(host, raw)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestParseJA4L 测试目标 JA4L 的解析
+func TestParseJA4L(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"2500", 2500 * time.Microsecond, false},
+		{"2500_128", 2500 * time.Microsecond, false},
+		{"0_64", 0, false},
+		{"", 0, true},
+		{"-1_64", 0, true},
+		{"t13d1715h2", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseJA4L(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJA4L(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseJA4L(%q) got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestTransportJA4L 测试 JA4L 的测量和按目标推迟握手
+func TestTransportJA4L(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		target  string
+		minTime time.Duration // 握手时间下限
+		wantErr bool
+	}{
+		{"未设置", "", 0, false},
+		{"目标 25ms", "25000_128", 40 * time.Millisecond, false},
+		{"格式错误", "t13d1715h2", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				JA4L:            tt.target,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(srv.URL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("格式错误的 JA4L 应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if _, err := strconv.ParseUint(resp.Meta.JA4L, 10, 64); err != nil {
+				t.Errorf("Meta.JA4L got %q, want 微秒数", resp.Meta.JA4L)
+			}
+			if resp.Meta.TLSHandshake < tt.minTime {
+				t.Errorf("TLSHandshake got %v, want >= %v", resp.Meta.TLSHandshake, tt.minTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// maxHandshakeCapture 是为解析握手消息缓存的数据的上限
+const maxHandshakeCapture = 1 << 16
+
+// helloRetryRequestRandom 是 HelloRetryRequest 的 random 字段，见 RFC 8446 4.1.3 节
+var helloRetryRequestRandom = []byte{
+	0xCF, 0x21, 0xAD, 0x74, 0xE5, 0x9A, 0x61, 0x11,
+	0xBE, 0x1D, 0x8C, 0x02, 0x1E, 0x65, 0xB8, 0x91,
+	0xC2, 0xA2, 0x11, 0x16, 0x7A, 0xBB, 0x8C, 0x5E,
+	0x07, 0x9E, 0x09, 0xE2, 0xC8, 0xA8, 0x33, 0x9C,
+}
+
+// serverHello 是计算 JA3S 和 JA4S 所需的 ServerHello 字段
+type serverHello struct {
+	vers       uint16   // legacy_version
+	version    uint16   // supported_versions 选择的版本，没有该扩展时为 legacy_version
+	cipher     uint16   // 选择的密码套件
+	extensions []uint16 // 扩展类型，保持报文中的原始顺序
+	alpn       string   // 选择的 ALPN 值
+	retry      bool     // 是否为 HelloRetryRequest
+}
+
+var errNotServerHello = errors.New("不是 ServerHello 消息")
+
+// parseServerHello 解析包括 4 字节握手消息头的 ServerHello
+func parseServerHello(msg []byte) (*serverHello, error) {
+	s := cryptobyte.String(msg)
+	var typ uint8
+	var body cryptobyte.String
+	if !s.ReadUint8(&typ) || typ != 2 || !s.ReadUint24LengthPrefixed(&body) {
+		return nil, errNotServerHello
+	}
+	h := &serverHello{}
+	var random []byte
+	var sessionID, exts cryptobyte.String
+	var compression uint8
+	if !body.ReadUint16(&h.vers) || !body.ReadBytes(&random, 32) ||
+		!body.ReadUint8LengthPrefixed(&sessionID) || !body.ReadUint16(&h.cipher) ||
+		!body.ReadUint8(&compression) {
+		return nil, errors.New("ServerHello 格式错误")
+	}
+	h.version = h.vers
+	h.retry = bytes.Equal(random, helloRetryRequestRandom)
+	if body.Empty() {
+		return h, nil
+	}
+	if !body.ReadUint16LengthPrefixed(&exts) {
+		return nil, errors.New("ServerHello 扩展格式错误")
+	}
+	for !exts.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !exts.ReadUint16(&typ) || !exts.ReadUint16LengthPrefixed(&data) {
+			return nil, errors.New("ServerHello 扩展格式错误")
+		}
+		h.extensions = append(h.extensions, typ)
+		switch typ {
+		case 43: // supported_versions
+			data.ReadUint16(&h.version)
+		case 16: // application_layer_protocol_negotiation
+			var list, proto cryptobyte.String
+			if data.ReadUint16LengthPrefixed(&list) && list.ReadUint8LengthPrefixed(&proto) {
+				h.alpn = string(proto)
+			}
+		}
+	}
+	return h, nil
+}
+
+// ja3s 返回 JA3S 字符串：legacy_version、密码套件和以 - 连接的扩展类型，均为十进制
+func (h *serverHello) ja3s() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(h.vers)))
+	b.WriteByte(',')
+	b.WriteString(strconv.Itoa(int(h.cipher)))
+	b.WriteByte(',')
+	for i, e := range h.extensions {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(strconv.Itoa(int(e)))
+	}
+	return b.String()
+}
+
+// ja4s 返回 FoxIO 规范的 JA4S 字符串，如 t130200_1301_234ea6891581
+//
+// 第一段为协议、TLS 版本、扩展数 (最多 99) 和 ALPN 值的首尾字符；
+// 第二段为密码套件；第三段为原始顺序扩展的 SHA-256 前 12 位。
+func (h *serverHello) ja4s() string {
+	return fmt.Sprintf("t%s%02d%s_%04x_%s", ja4Version(h.version), min(len(h.extensions), 99),
+		ja4ALPN(h.alpn), h.cipher, ja4Hash(ja4HexList(h.extensions)))
+}
+
+// handshakeMessages 从 TLS 记录流中提取明文的握手消息，跳过其他类型的记录，
+// 零值可以直接使用
+type handshakeMessages struct {
+	records []byte // 未解析的记录
+	msg     []byte // 握手消息的数据
+	failed  bool   // 缓存的数据超过上限
+}
+
+// write 追加数据 p，返回其中完整的握手消息，包括 4 字节的消息头
+func (m *handshakeMessages) write(p []byte) [][]byte {
+	if m.failed {
+		return nil
+	}
+	m.records = append(m.records, p...)
+	var msgs [][]byte
+	for len(m.records) >= 5 {
+		n := int(m.records[3])<<8 | int(m.records[4])
+		if len(m.records) < 5+n {
+			break
+		}
+		if m.records[0] == 22 { // handshake
+			m.msg = append(m.msg, m.records[5:5+n]...)
+		}
+		m.records = m.records[5+n:]
+		for len(m.msg) >= 4 {
+			size := 4 + (int(m.msg[1])<<16 | int(m.msg[2])<<8 | int(m.msg[3]))
+			if len(m.msg) < size {
+				break
+			}
+			msgs = append(msgs, append([]byte(nil), m.msg[:size]...))
+			m.msg = m.msg[size:]
+		}
+	}
+	if len(m.records)+len(m.msg) > maxHandshakeCapture {
+		m.failed = true
+		m.records, m.msg = nil, nil
+	}
+	return msgs
+}
+
+// helloCapture 从握手期间读到的数据中提取服务端的 ServerHello，
+// 跳过 HelloRetryRequest，零值可以直接使用
+type helloCapture struct {
+	msgs   handshakeMessages
+	hello  *serverHello
+	failed bool
+}
+
+// write 追加从服务端读到的数据 p
+func (c *helloCapture) write(p []byte) {
+	if c.hello != nil || c.failed {
+		return
+	}
+	for _, msg := range c.msgs.write(p) {
+		h, err := parseServerHello(msg)
+		if err != nil {
+			c.failed = true
+			return
+		}
+		if !h.retry {
+			c.hello = h
+			c.msgs = handshakeMessages{}
+			return
+		}
+	}
+}
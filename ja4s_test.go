@@ -0,0 +1,116 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// buildServerHello 返回包括握手消息头的 ServerHello，exts 为编码好的扩展，按顺序写入
+func buildServerHello(random []byte, cipher uint16, exts ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(2)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(tls.VersionTLS12)
+		b.AddBytes(random)
+		b.AddUint8(0)
+		b.AddUint16(cipher)
+		b.AddUint8(0)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, e := range exts {
+				b.AddBytes(e)
+			}
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+// tlsRecord 返回内容类型为 typ 的 TLS 记录
+func tlsRecord(typ uint8, data []byte) []byte {
+	return append([]byte{typ, 3, 3, byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+var (
+	extSupportedVersions13 = []byte{0, 43, 0, 2, 3, 4}
+	extKeyShare            = []byte{0, 51, 0, 4, 0, 29, 0, 0}
+	extALPNh2              = []byte{0, 16, 0, 5, 0, 3, 2, 'h', '2'}
+)
+
+// TestServerHelloFingerprints 测试从握手数据中提取 ServerHello 并计算 JA3S 和 JA4S
+func TestServerHelloFingerprints(t *testing.T) {
+	random := make([]byte, 32)
+	hello13 := buildServerHello(random, 0x1301, extKeyShare, extSupportedVersions13)
+	hello12 := buildServerHello(random, 0xc02f, []byte{0xff, 0x01, 0, 1, 0}, extALPNh2)
+	hrr := buildServerHello(helloRetryRequestRandom, 0x1301, extSupportedVersions13)
+	tests := []struct {
+		name     string
+		reads    [][]byte
+		wantJA3S string
+		wantJA4S string
+	}{
+		{
+			name:     "TLS 1.3",
+			reads:    [][]byte{tlsRecord(22, hello13)},
+			wantJA3S: "771,4865,51-43",
+			wantJA4S: "t130200_1301_" + ja4Hash("0033,002b"),
+		},
+		{
+			name:     "TLS 1.2 和 ALPN",
+			reads:    [][]byte{tlsRecord(22, hello12)},
+			wantJA3S: "771,49199,65281-16",
+			wantJA4S: "t1202h2_c02f_" + ja4Hash("ff01,0010"),
+		},
+		{
+			name:     "跳过 HelloRetryRequest，分多次读到",
+			reads:    [][]byte{tlsRecord(22, hrr), tlsRecord(20, []byte{1}), tlsRecord(22, hello13[:10]), tlsRecord(22, hello13[10:])[:3], tlsRecord(22, hello13[10:])[3:]},
+			wantJA3S: "771,4865,51-43",
+			wantJA4S: "t130200_1301_" + ja4Hash("0033,002b"),
+		},
+		{
+			name:  "不是 ServerHello",
+			reads: [][]byte{tlsRecord(21, []byte{2, 40}), tlsRecord(22, []byte{11, 0, 0, 0})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c helloCapture
+			for _, p := range tt.reads {
+				c.write(p)
+			}
+			var ja3s, ja4s string
+			if c.hello != nil {
+				ja3s, ja4s = c.hello.ja3s(), c.hello.ja4s()
+			}
+			if ja3s != tt.wantJA3S || ja4s != tt.wantJA4S {
+				t.Errorf("got %q %q, want %q %q", ja3s, ja4s, tt.wantJA3S, tt.wantJA4S)
+			}
+		})
+	}
+}
+
+// TestTransportJA4S 测试响应中的 JA3S 和 JA4S
+func TestTransportJA4S(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	for _, ja3 := range []string{"771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0", ""} {
+		tr := &Transport{JA3: ja3, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		resp, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tr.CloseIdleConnections()
+		prefix := fmt.Sprintf("t13%02d00_%04x_", 2, resp.TLS.CipherSuite)
+		if m := resp.Meta; len(m.JA3S) != 32 || len(m.JA4S) != len(prefix)+12 || m.JA4S[:len(prefix)] != prefix {
+			t.Errorf("JA3 %q: JA3S got %q, JA4S got %q, want 前缀 %q", ja3, m.JA3S, m.JA4S, prefix)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// JA4X 返回证书的 JA4X (X.509 证书) 指纹，如 a373a9f83c6b_2bab15409345_7bf9a7bf7029
+//
+// 三段分别为颁发者 RDN 属性类型、主题 RDN 属性类型和扩展的 OID
+// (DER 编码内容的十六进制，按证书中的顺序以逗号连接) 的 SHA-256 前 12 位。
+// JA4X 不依赖证书内容的具体值，反映的是生成证书的程序，
+// 可用于识别同一工具签发的证书。
+func JA4X(cert *x509.Certificate) string {
+	issuer := ja4xNameOIDs(cert.RawIssuer)
+	subject := ja4xNameOIDs(cert.RawSubject)
+	var exts []string
+	for _, e := range cert.Extensions {
+		if oid := ja4xOID(e.Id); oid != "" {
+			exts = append(exts, oid)
+		}
+	}
+	return ja4Hash(strings.Join(issuer, ",")) + "_" +
+		ja4Hash(strings.Join(subject, ",")) + "_" +
+		ja4Hash(strings.Join(exts, ","))
+}
+
+// ja4xNameOIDs 返回 DER 编码的 Name 中各属性类型 OID 的十六进制
+func ja4xNameOIDs(raw []byte) []string {
+	var oids []string
+	input := cryptobyte.String(raw)
+	var rdns cryptobyte.String
+	if !input.ReadASN1(&rdns, cbasn1.SEQUENCE) {
+		return nil
+	}
+	for !rdns.Empty() {
+		var set cryptobyte.String
+		if !rdns.ReadASN1(&set, cbasn1.SET) {
+			return oids
+		}
+		for !set.Empty() {
+			var atv, oid cryptobyte.String
+			if !set.ReadASN1(&atv, cbasn1.SEQUENCE) || !atv.ReadASN1(&oid, cbasn1.OBJECT_IDENTIFIER) {
+				return oids
+			}
+			oids = append(oids, hex.EncodeToString(oid))
+		}
+	}
+	return oids
+}
+
+// ja4xOID 返回 OID 的 DER 编码内容的十六进制
+func ja4xOID(id asn1.ObjectIdentifier) string {
+	der, err := asn1.Marshal(id)
+	if err != nil {
+		return ""
+	}
+	input := cryptobyte.String(der)
+	var oid cryptobyte.String
+	if !input.ReadASN1(&oid, cbasn1.OBJECT_IDENTIFIER) {
+		return ""
+	}
+	return hex.EncodeToString(oid)
+}
+
+// peerJA4X 返回服务端证书链中每个证书的 JA4X，叶子证书在前
+func peerJA4X(cs *tls.ConnectionState) []string {
+	if cs == nil {
+		return nil
+	}
+	ja4x := make([]string, len(cs.PeerCertificates))
+	for i, cert := range cs.PeerCertificates {
+		ja4x[i] = JA4X(cert)
+	}
+	return ja4x
+}
+
+// JA4XError 表示服务端叶子证书的 JA4X 与 Transport.JA4X 固定的值不一致
+type JA4XError struct {
+	Want []string // 固定的 JA4X
+	Got  string   // 叶子证书的 JA4X，没有证书时为空
+}
+
+func (e *JA4XError) Error() string {
+	return fmt.Sprintf("服务端证书的 JA4X 为 %q，不是固定的 %s 之一", e.Got, strings.Join(e.Want, ", "))
+}
+
+// checkJA4X 校验叶子证书的 JA4X 是否为 t.JA4X 中的一个
+func (t *Transport) checkJA4X(ja4x []string) error {
+	if t.JA4X == "" {
+		return nil
+	}
+	want := strings.Split(t.JA4X, ",")
+	for i := range want {
+		want[i] = strings.TrimSpace(want[i])
+	}
+	var got string
+	if len(ja4x) > 0 {
+		got = ja4x[0]
+	}
+	if got == "" || !slices.Contains(want, got) {
+		return &JA4XError{Want: want, Got: got}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestJA4X 测试证书 JA4X 的计算
+func TestJA4X(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Country: []string{"US"}, Organization: []string{"tlshttp"}, CommonName: "example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{0x05, 0x00}},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C (2.5.4.6)、O (2.5.4.10)、CN (2.5.4.3)；扩展只有 1.2.3.4
+	name := ja4Hash("550406,55040a,550403")
+	want := name + "_" + name + "_" + ja4Hash("2a0304")
+	if got := JA4X(cert); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestTransportJA4X 测试响应中的 JA4X 和 JA4X 固定
+func TestTransportJA4X(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	leaf := JA4X(srv.Certificate())
+
+	tests := []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{"未固定", "", false},
+		{"匹配", "a373a9f83c6b_2bab15409345_7bf9a7bf7029, " + leaf, false},
+		{"不匹配", "a373a9f83c6b_2bab15409345_7bf9a7bf7029", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				JA4X:            tt.pin,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(srv.URL)
+			if tt.wantErr {
+				var e *JA4XError
+				if !errors.As(err, &e) {
+					t.Fatalf("err = %v, want *JA4XError", err)
+				}
+				if e.Got != leaf {
+					t.Errorf("JA4XError.Got got %v, want %v", e.Got, leaf)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if len(resp.Meta.JA4X) != 1 || resp.Meta.JA4X[0] != leaf {
+				t.Errorf("Meta.JA4X got %v, want [%v]", resp.Meta.JA4X, leaf)
+			}
+		})
+	}
+}
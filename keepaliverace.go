@@ -0,0 +1,171 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryReason 是空闲连接被服务端关闭的方式，即 keep-alive 竞争的类型
+//
+// 服务端关闭空闲连接的同时客户端复用它发送请求时，请求失败并在新连接上
+// 重试；关闭先被发现时，连接在复用前被丢弃。两者出现得多说明
+// IdleConnTimeout 长于服务端的 keep-alive 超时。
+type RetryReason int
+
+const (
+	RetryReason408   RetryReason = iota + 1 // 服务端关闭前发送了 408 Request Timeout
+	RetryReasonFIN                          // 服务端正常关闭了连接，读到 EOF
+	RetryReasonRST                          // 连接被重置，如 ECONNRESET、EPIPE
+	RetryReasonOther                        // 其他读写错误
+)
+
+func (r RetryReason) String() string {
+	switch r {
+	case RetryReason408:
+		return "408"
+	case RetryReasonFIN:
+		return "FIN"
+	case RetryReasonRST:
+		return "RST"
+	case RetryReasonOther:
+		return "other"
+	}
+	return "RetryReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// KeepAliveRaceStats 汇总一个目标的空闲连接被服务端关闭的情况
+type KeepAliveRaceStats struct {
+	Addr string // 目标地址 host:port，汇总所有目标时为空
+
+	// Retries 是请求在复用的连接上失败并重试的次数，IdleClosed 是空闲连接
+	// 在复用前被发现已关闭的次数，均按原因统计
+	Retries    map[RetryReason]int64
+	IdleClosed map[RetryReason]int64
+
+	// MinIdle 和 MaxIdle 是上述情况发生时连接已空闲的最短和最长时间，
+	// MinIdle 接近服务端的 keep-alive 超时，IdleConnTimeout 应小于它
+	MinIdle time.Duration
+	MaxIdle time.Duration
+}
+
+// Total 返回重试和复用前关闭的总次数
+func (s KeepAliveRaceStats) Total() int64 {
+	var n int64
+	for _, v := range s.Retries {
+		n += v
+	}
+	for _, v := range s.IdleClosed {
+		n += v
+	}
+	return n
+}
+
+func (s *KeepAliveRaceStats) add(o KeepAliveRaceStats) {
+	if s.Retries == nil {
+		s.Retries = make(map[RetryReason]int64)
+		s.IdleClosed = make(map[RetryReason]int64)
+	}
+	for r, n := range o.Retries {
+		s.Retries[r] += n
+	}
+	for r, n := range o.IdleClosed {
+		s.IdleClosed[r] += n
+	}
+	if o.MinIdle > 0 && (s.MinIdle == 0 || o.MinIdle < s.MinIdle) {
+		s.MinIdle = o.MinIdle
+	}
+	s.MaxIdle = max(s.MaxIdle, o.MaxIdle)
+}
+
+// keepAliveRaces 按目标地址记录 keep-alive 竞争，零值可以直接使用
+type keepAliveRaces struct {
+	mu    sync.Mutex
+	stats map[string]*KeepAliveRaceStats // 键为目标地址
+}
+
+// record 记录到 addr 的连接空闲 idle 后因 reason 被关闭，retried 表示请求因此重试
+func (k *keepAliveRaces) record(addr string, reason RetryReason, idle time.Duration, retried bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stats == nil {
+		k.stats = make(map[string]*KeepAliveRaceStats)
+	}
+	s := k.stats[addr]
+	if s == nil {
+		s = &KeepAliveRaceStats{Addr: addr}
+		k.stats[addr] = s
+	}
+	ev := KeepAliveRaceStats{MinIdle: idle, MaxIdle: idle}
+	if retried {
+		ev.Retries = map[RetryReason]int64{reason: 1}
+	} else {
+		ev.IdleClosed = map[RetryReason]int64{reason: 1}
+	}
+	s.add(ev)
+}
+
+// KeepAliveRaces 返回到 addr (host:port) 的空闲连接被服务端关闭的统计，
+// addr 为空时汇总所有目标
+func (t *Transport) KeepAliveRaces(addr string) KeepAliveRaceStats {
+	t.keepAliveRaces.mu.Lock()
+	defer t.keepAliveRaces.mu.Unlock()
+	out := KeepAliveRaceStats{
+		Addr:       addr,
+		Retries:    make(map[RetryReason]int64),
+		IdleClosed: make(map[RetryReason]int64),
+	}
+	for a, s := range t.keepAliveRaces.stats {
+		if addr == "" || a == addr {
+			out.add(*s)
+		}
+	}
+	return out
+}
+
+// closeReason 返回关闭空闲连接的错误对应的原因
+func closeReason(err error) RetryReason {
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), err == errServerClosedIdle:
+		return RetryReasonFIN
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, syscall.ECONNABORTED):
+		return RetryReasonRST
+	}
+	return RetryReasonOther
+}
+
+// retryReason 返回 pc 上的请求因 err 失败并重试的原因
+func (pc *persistConn) retryReason(err error) RetryReason {
+	if e, ok := err.(nothingWrittenError); ok {
+		err = e.error
+	}
+	if e, ok := err.(transportReadFromServerError); ok {
+		err = e.err
+	}
+	if err == errServerClosedIdle {
+		pc.mu.Lock()
+		defer pc.mu.Unlock()
+		if pc.idleCloseReason != 0 {
+			return pc.idleCloseReason
+		}
+	}
+	return closeReason(err)
+}
+
+// recordKeepAliveRace 记录空闲连接 pc 因 reason 被关闭，pc 从未空闲过时忽略
+func (t *Transport) recordKeepAliveRace(pc *persistConn, reason RetryReason, retried bool) {
+	t.idleMu.Lock()
+	idleAt := pc.idleAt
+	t.idleMu.Unlock()
+	if idleAt.IsZero() {
+		return
+	}
+	t.keepAliveRaces.record(pc.cacheKey.addr, reason, t.now().Sub(idleAt), retried)
+}
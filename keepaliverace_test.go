@@ -0,0 +1,135 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeMode 是测试服务端关闭连接的方式
+type closeMode int
+
+const (
+	closeFIN closeMode = iota
+	closeRST
+	close408
+)
+
+// closeConn 按 mode 关闭 c
+func closeConn(c net.Conn, mode closeMode) {
+	switch mode {
+	case closeRST:
+		c.(*net.TCPConn).SetLinger(0)
+	case close408:
+		io.WriteString(c, "HTTP/1.1 408 Request Timeout\r\nConnection: close\r\nContent-Length: 0\r\n\r\n")
+	}
+	c.Close()
+}
+
+// raceServer 返回一个 HTTP/1.1 服务端，第一个连接响应第一个请求后按 mode 关闭：
+// idle 为 true 时在空闲期间关闭，否则在读到第二个请求后不响应直接关闭
+func raceServer(t *testing.T, mode closeMode, idle bool) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var conns atomic.Int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			first := conns.Add(1) == 1
+			go func() {
+				defer c.Close()
+				br := bufio.NewReader(c)
+				for n := 0; ; n++ {
+					req, err := ReadRequest(br)
+					if err != nil {
+						return
+					}
+					io.Copy(io.Discard, req.Body)
+					if first && n == 1 {
+						closeConn(c, mode)
+						return
+					}
+					io.WriteString(c, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+					if first && idle {
+						time.Sleep(10 * time.Millisecond)
+						closeConn(c, mode)
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// TestKeepAliveRaces 测试按原因记录空闲连接被服务端关闭和因此重试的请求
+func TestKeepAliveRaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   closeMode
+		idle   bool
+		reason RetryReason
+	}{
+		{"空闲时 408", close408, true, RetryReason408},
+		{"空闲时 FIN", closeFIN, true, RetryReasonFIN},
+		{"空闲时 RST", closeRST, true, RetryReasonRST},
+		{"复用时 FIN", closeFIN, false, RetryReasonFIN},
+		{"复用时 RST", closeRST, false, RetryReasonRST},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := raceServer(t, tt.mode, tt.idle)
+			tr := &Transport{}
+			defer tr.CloseIdleConnections()
+			c := &Client{Transport: tr}
+			get := func() *Response {
+				t.Helper()
+				resp, err := c.Get("http://" + addr + "/")
+				if err != nil {
+					t.Fatal(err)
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return resp
+			}
+
+			get()
+			if tt.idle {
+				deadline := time.Now().Add(5 * time.Second)
+				for tr.KeepAliveRaces(addr).IdleClosed[tt.reason] == 0 {
+					if time.Now().After(deadline) {
+						t.Fatalf("没有记录空闲连接被关闭: %+v", tr.KeepAliveRaces(addr))
+					}
+					time.Sleep(time.Millisecond)
+				}
+				return
+			}
+
+			resp := get()
+			if want := []RetryReason{tt.reason}; !slices.Equal(resp.Meta.RetryReasons, want) {
+				t.Errorf("RetryReasons got %v, want %v", resp.Meta.RetryReasons, want)
+			}
+			s := tr.KeepAliveRaces("")
+			if s.Retries[tt.reason] != 1 || s.Total() != 1 {
+				t.Errorf("KeepAliveRaces got %+v, want 一次 %v 重试", s, tt.reason)
+			}
+			if s.MinIdle <= 0 || s.MinIdle > s.MaxIdle {
+				t.Errorf("MinIdle got %v, MaxIdle got %v", s.MinIdle, s.MaxIdle)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// sslKeyLog 是 SSLKEYLOGFILE 环境变量指定的文件，所有 Transport 共享
+var sslKeyLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File // 打开失败时为 nil
+}
+
+// sslKeyLogWriter 返回 SSLKEYLOGFILE 指定的文件，变量为空或文件无法打开时返回 nil。
+// 变量改变时打开新的文件，之前的文件保持打开，因为可能仍有握手在写入
+func sslKeyLogWriter() io.Writer {
+	path := os.Getenv("SSLKEYLOGFILE")
+	if path == "" {
+		return nil
+	}
+	sslKeyLog.mu.Lock()
+	defer sslKeyLog.mu.Unlock()
+	if path != sslKeyLog.path {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			log.Printf("http: SSLKEYLOGFILE: %v", err)
+			f = nil
+		}
+		sslKeyLog.path, sslKeyLog.f = path, f
+	}
+	if sslKeyLog.f == nil {
+		return nil
+	}
+	return sslKeyLog.f
+}
+
+// keyLogWriter 返回记录 TLS 密钥的 Writer，优先使用 KeyLogWriter，
+// 设置了 KeyLogFromEnv 时使用 SSLKEYLOGFILE 指定的文件，都没有时返回 nil
+func (t *Transport) keyLogWriter() io.Writer {
+	if t.KeyLogWriter != nil {
+		return t.KeyLogWriter
+	}
+	if !t.KeyLogFromEnv {
+		return nil
+	}
+	return sslKeyLogWriter()
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTransportKeyLog 测试 KeyLogWriter 和 KeyLogFromEnv 记录指纹连接和标准 TLS 连接的密钥
+func TestTransportKeyLog(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+
+	const ja3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+	tests := []struct {
+		name   string
+		ja3    string
+		envLog bool // 使用 SSLKEYLOGFILE 而不是 KeyLogWriter
+	}{
+		{"指纹连接", ja3, false},
+		{"标准 TLS 连接", "", false},
+		{"指纹连接，SSLKEYLOGFILE", ja3, true},
+		{"标准 TLS 连接，SSLKEYLOGFILE", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tr := &Transport{
+				JA3:               tt.ja3,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			}
+			path := filepath.Join(t.TempDir(), "keys.log")
+			if tt.envLog {
+				tr.KeyLogFromEnv = true
+				t.Setenv("SSLKEYLOGFILE", path)
+				// 关闭文件，以便删除临时目录
+				t.Cleanup(func() {
+					sslKeyLog.mu.Lock()
+					defer sslKeyLog.mu.Unlock()
+					sslKeyLog.f.Close()
+					sslKeyLog.path, sslKeyLog.f = "", nil
+				})
+			} else {
+				t.Setenv("SSLKEYLOGFILE", "")
+				tr.KeyLogWriter = &buf
+			}
+			resp, err := (&Client{Transport: tr}).Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			got := buf.String()
+			if tt.envLog {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+			for _, label := range []string{"CLIENT_HANDSHAKE_TRAFFIC_SECRET ", "SERVER_TRAFFIC_SECRET_0 "} {
+				if !strings.Contains(got, label) {
+					t.Errorf("密钥日志中没有 %s: %q", label, got)
+				}
+			}
+		})
+	}
+}
+
+// TestTransportKeyLogEnvOptIn 测试没有设置 KeyLogFromEnv 时不读取 SSLKEYLOGFILE
+func TestTransportKeyLogEnvOptIn(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "keys.log")
+	t.Setenv("SSLKEYLOGFILE", path)
+
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}
+	resp, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("没有设置 KeyLogFromEnv 时写入了 SSLKEYLOGFILE: %v", err)
+	}
+}
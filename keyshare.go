@@ -0,0 +1,91 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/sha512"
+	"fmt"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// keyShareCurve 返回 key_share 组 g 使用的 ECDH 曲线，后量子混合组为其 X25519 部分
+func keyShareCurve(g tls.CurveID) (ecdh.Curve, bool) {
+	switch g {
+	case tls.X25519, tls.X25519MLKEM768, tls.X25519Kyber768Draft00:
+		return ecdh.X25519(), true
+	case tls.CurveP256:
+		return ecdh.P256(), true
+	case tls.CurveP384:
+		return ecdh.P384(), true
+	case tls.CurveP521:
+		return ecdh.P521(), true
+	}
+	return nil, false
+}
+
+// fillKeyShares 用 t.KeyShareKey 提供的私钥填充 spec 中 key_share 扩展的公钥，
+// 返回对应的私钥，ApplyPreset 之后设置到 HandshakeState.State13.KeyShareKeys。
+// KeyShareKey 为 nil 时返回 nil，由 utls 随机生成密钥
+//
+// 已有内容的密钥共享 (如 TLSFingerprint 给出的) 保持不变。
+func (t *Transport) fillKeyShares(spec *tls.ClientHelloSpec) (*tls.KeySharePrivateKeys, error) {
+	if t.KeyShareKey == nil {
+		return nil, nil
+	}
+	var ks *tls.KeyShareExtension
+	for _, e := range spec.Extensions {
+		if e, ok := e.(*tls.KeyShareExtension); ok {
+			ks = e
+		}
+	}
+	if ks == nil {
+		return nil, nil
+	}
+	// spec 中的扩展可能来自共享的配置，修改副本
+	ks.KeyShares = slices.Clone(ks.KeyShares)
+	keys := &tls.KeySharePrivateKeys{}
+	for i, s := range ks.KeyShares {
+		if isGREASEValue(uint16(s.Group)) || len(s.Data) > 1 {
+			continue
+		}
+		curve, ok := keyShareCurve(s.Group)
+		if !ok {
+			return nil, fmt.Errorf("KeyShareKey: 不支持的 key_share 组 %v", s.Group)
+		}
+		key, err := t.KeyShareKey(s.Group)
+		if err != nil {
+			return nil, fmt.Errorf("KeyShareKey(%v): %w", s.Group, err)
+		}
+		if key == nil || key.Curve() != curve {
+			return nil, fmt.Errorf("KeyShareKey(%v) 返回的私钥不是 %v 曲线的", s.Group, curve)
+		}
+		switch s.Group {
+		case tls.X25519MLKEM768, tls.X25519Kyber768Draft00:
+			// ML-KEM 的种子由 X25519 私钥导出，同一私钥得到同一密钥共享
+			seed := sha512.Sum512(key.Bytes())
+			dk, err := mlkem.NewDecapsulationKey768(seed[:mlkem.SeedSize])
+			if err != nil {
+				return nil, err
+			}
+			if s.Group == tls.X25519MLKEM768 {
+				ks.KeyShares[i].Data = append(dk.EncapsulationKey().Bytes(), key.PublicKey().Bytes()...)
+			} else {
+				ks.KeyShares[i].Data = append(key.PublicKey().Bytes(), dk.EncapsulationKey().Bytes()...)
+			}
+			keys.Mlkem, keys.MlkemEcdhe = dk, key
+		default:
+			ks.KeyShares[i].Data = key.PublicKey().Bytes()
+			if keys.Ecdhe == nil {
+				// 与 utls 相同，只保留第一个非混合组的私钥
+				keys.CurveID, keys.Ecdhe = s.Group, key
+			}
+		}
+	}
+	return keys, nil
+}
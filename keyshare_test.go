@@ -0,0 +1,98 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/sha256"
+	mrand "math/rand"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// fixedKeyShareKey 为每个组返回由组 ID 导出的固定私钥
+func fixedKeyShareKey(g tls.CurveID) (*ecdh.PrivateKey, error) {
+	curve, _ := keyShareCurve(g)
+	seed := sha256.Sum256([]byte{byte(g >> 8), byte(g)})
+	return curve.NewPrivateKey(seed[:])
+}
+
+// TestKeyShareKeyReproducible 测试固定私钥和随机数时 ClientHello 逐字节相同，且可以完成握手
+func TestKeyShareKeyReproducible(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	for _, curves := range []string{"4588-29-23-24", "29-23-24", "23-24"} {
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			UserAgent:         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+			ForceAttemptHTTP2: true,
+			KeyShareKey:       fixedKeyShareKey,
+		}
+		tr.JA3 = tr.JA3[:len(tr.JA3)-len("29-23-24,0")] + curves + ",0"
+		hello := func() []byte {
+			tr.TLSClientConfig = &tls.Config{ServerName: "example.com", Rand: mrand.New(mrand.NewSource(1))}
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			defer c2.Close()
+			uc, err := tr.NewUConn(c1, tr.TLSClientConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := uc.BuildHandshakeState(); err != nil {
+				t.Fatal(err)
+			}
+			return uc.HandshakeState.Hello.Raw
+		}
+		h1, h2 := hello(), hello()
+		if !bytes.Equal(h1, h2) {
+			t.Errorf("%s: 两次生成的 ClientHello 不同", curves)
+		}
+		want, _ := fixedKeyShareKey(tls.X25519)
+		if curves == "23-24" {
+			want, _ = fixedKeyShareKey(tls.CurveP256)
+		}
+		if !bytes.Contains(h1, want.PublicKey().Bytes()) {
+			t.Errorf("%s: ClientHello 不包含 KeyShareKey 提供的公钥", curves)
+		}
+
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", curves, err)
+		}
+		resp.Body.Close()
+		tr.CloseIdleConnections()
+	}
+}
+
+func TestKeyShareKeyErrors(t *testing.T) {
+	for name, key := range map[string]func(tls.CurveID) (*ecdh.PrivateKey, error){
+		"曲线不符": func(tls.CurveID) (*ecdh.PrivateKey, error) {
+			return fixedKeyShareKey(tls.CurveP256)
+		},
+		"nil": func(tls.CurveID) (*ecdh.PrivateKey, error) { return nil, nil },
+	} {
+		tr := &Transport{
+			JA3:             "771,4865,0-10-11-43-51,29,0",
+			KeyShareKey:     key,
+			TLSClientConfig: &tls.Config{ServerName: "example.com"},
+		}
+		c1, c2 := net.Pipe()
+		if _, err := tr.NewUConn(c1, tr.TLSClientConfig); err == nil {
+			t.Errorf("%s: 期望错误", name)
+		}
+		c1.Close()
+		c2.Close()
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// MixedContentPolicy 决定 HTTPS 页面的 http:// 子资源请求 (混合内容) 如何处理，
+// 见 Transport.MixedContent
+type MixedContentPolicy int
+
+const (
+	// MixedContentAllow 不检查混合内容，照常发出请求
+	MixedContentAllow MixedContentPolicy = iota
+
+	// MixedContentBlock 阻止所有混合内容，RoundTrip 返回 *MixedContentError
+	MixedContentBlock
+
+	// MixedContentBrowser 与 Chrome 86 及之后的版本一致：图片、音频和视频
+	// (Sec-Fetch-Dest 为 image、audio、video) 自动升级为 https://，其余混合内容被阻止
+	MixedContentBrowser
+)
+
+// MixedContentError 在 Transport.MixedContent 阻止混合内容时由 RoundTrip 返回
+type MixedContentError struct {
+	Document string // 发起请求的 HTTPS 页面
+	URL      string // 被阻止的 http:// 子资源
+}
+
+func (e *MixedContentError) Error() string {
+	return fmt.Sprintf("混合内容被阻止: HTTPS 页面 %s 不能加载 %s", e.Document, e.URL)
+}
+
+// mixedContentUpgradable 是 MixedContentBrowser 自动升级的子资源类型 (Sec-Fetch-Dest)
+var mixedContentUpgradable = map[string]bool{"image": true, "audio": true, "video": true}
+
+// applyMixedContent 按 t.MixedContent 处理 WithDocument 页面发起的混合内容请求：
+// 需要升级时返回改为 https:// 的请求副本，需要阻止时返回 *MixedContentError
+func (t *Transport) applyMixedContent(req *Request) (*Request, error) {
+	if t.MixedContent == MixedContentAllow || req.URL.Scheme != "http" {
+		return req, nil
+	}
+	doc, ok := DocumentFromContext(req.Context())
+	if !ok || doc.Scheme != "https" || isTrustworthyHost(req.URL.Hostname()) {
+		return req, nil
+	}
+	// 顶层导航打开的是新页面，不是子资源
+	if req.Header.Get("Sec-Fetch-Mode") == "navigate" && req.Header.Get("Sec-Fetch-Dest") == "document" {
+		return req, nil
+	}
+	if t.MixedContent == MixedContentBrowser && mixedContentUpgradable[req.Header.Get("Sec-Fetch-Dest")] {
+		u := *req.URL
+		u.Scheme = "https"
+		if u.Port() == "80" {
+			u.Host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		r2 := *req
+		r2.URL = &u
+		return &r2, nil
+	}
+	return nil, &MixedContentError{Document: doc.String(), URL: req.URL.String()}
+}
+
+// isTrustworthyHost 报告浏览器是否认为 host 可信，到它的 http:// 请求不算混合内容：
+// localhost 及其子域名和环回地址
+func isTrustworthyHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestMixedContent 测试 HTTPS 页面的 http:// 子资源按 MixedContent 阻止或升级
+func TestMixedContent(t *testing.T) {
+	var gotScheme string
+	h := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		gotScheme = "http"
+		if r.TLS != nil {
+			gotScheme = "https"
+		}
+	})
+	plain := httptest.NewServer(h)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(h)
+	defer secure.Close()
+	// example.test 不是环回地址，80 端口发往 plain，443 端口发往 secure
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		target := plain.Listener.Addr().String()
+		if addr == "example.test:443" {
+			target = secure.Listener.Addr().String()
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, target)
+	}
+	https, _ := url.Parse("https://example.test/page")
+	http, _ := url.Parse("http://example.test/page")
+
+	tests := []struct {
+		name       string
+		policy     MixedContentPolicy
+		doc        *url.URL
+		url        string
+		dest, mode string
+		wantErr    bool
+		wantScheme string // 服务端收到请求的协议
+	}{
+		{name: "默认不检查", policy: MixedContentAllow, doc: https, url: "http://example.test/a.js", wantScheme: "http"},
+		{name: "阻止脚本", policy: MixedContentBrowser, doc: https, url: "http://example.test/a.js", dest: "script", wantErr: true},
+		{name: "升级图片", policy: MixedContentBrowser, doc: https, url: "http://example.test:80/a.png", dest: "image", wantScheme: "https"},
+		{name: "Block 不升级图片", policy: MixedContentBlock, doc: https, url: "http://example.test/a.png", dest: "image", wantErr: true},
+		{name: "HTTP 页面", policy: MixedContentBlock, doc: http, url: "http://example.test/a.js", wantScheme: "http"},
+		{name: "没有页面", policy: MixedContentBlock, url: "http://example.test/a.js", wantScheme: "http"},
+		{name: "顶层导航", policy: MixedContentBlock, doc: https, url: "http://example.test/", dest: "document", mode: "navigate", wantScheme: "http"},
+		{name: "HTTPS 子资源", policy: MixedContentBlock, doc: https, url: "https://example.test/a.js", wantScheme: "https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotScheme = ""
+			tr := &Transport{
+				JA3:              "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				MixedContent:     tt.policy,
+				DialContext:      dial,
+				TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+				FingerprintStats: &FingerprintStats{},
+			}
+			defer tr.CloseIdleConnections()
+			req, _ := NewRequestWithContext(WithDocument(context.Background(), tt.doc), "GET", tt.url, nil)
+			if tt.dest != "" {
+				req.Header.Set("Sec-Fetch-Dest", tt.dest)
+			}
+			if tt.mode != "" {
+				req.Header.Set("Sec-Fetch-Mode", tt.mode)
+			}
+			resp, err := tr.RoundTrip(req)
+			var mce *MixedContentError
+			if tt.wantErr {
+				if !errors.As(err, &mce) {
+					t.Fatalf("got %v, want *MixedContentError", err)
+				}
+				if mce.URL != tt.url || mce.Document != tt.doc.String() {
+					t.Errorf("got %+v", mce)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if gotScheme != tt.wantScheme {
+				t.Errorf("服务端收到 %q, want %q", gotScheme, tt.wantScheme)
+			}
+			if resp.Request != req {
+				t.Errorf("resp.Request 不是调用方的请求: %v", resp.Request.URL)
+			}
+			if got := tr.FingerprintStats.Snapshot(); len(got) != 1 || got[0].Fingerprint != tr.configuredFingerprint(tt.wantScheme) {
+				t.Errorf("FingerprintStats got %+v, want %s 的指纹", got, tt.wantScheme)
+			}
+		})
+	}
+}
+
+func TestIsTrustworthyHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":     true,
+		"app.localhost": true,
+		"127.0.0.1":     true,
+		"::1":           true,
+		"example.com":   false,
+		"10.0.0.1":      false,
+		"localhost.com": false,
+	} {
+		if got := isTrustworthyHost(host); got != want {
+			t.Errorf("isTrustworthyHost(%q) got %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NoProxyList is a parsed NO_PROXY-style list of hosts that must be
+// reached without a proxy. The zero value matches nothing.
+//
+// Entries are separated by commas or whitespace and may be:
+//   - "*", matching every host;
+//   - an IP address ("10.1.2.3", "::1") or CIDR range ("10.0.0.0/8");
+//   - a domain name ("example.com"), matching the domain and all of
+//     its subdomains;
+//   - a domain with a leading dot or "*." (".example.com",
+//     "*.example.com"), matching only subdomains;
+//
+// and any entry except "*" and CIDR ranges may carry a ":port" suffix
+// to match only that port.
+type NoProxyList struct {
+	all      bool
+	prefixes []netip.Prefix
+	hosts    []noProxyHost
+}
+
+type noProxyHost struct {
+	name       string // lower case, without leading dot
+	port       string // empty matches any port
+	subdomOnly bool
+	ip         netip.Addr // valid if the entry is an IP literal
+}
+
+// ParseNoProxy parses a NO_PROXY-style list. Malformed entries are ignored.
+func ParseNoProxy(list string) *NoProxyList {
+	l := &NoProxyList{}
+	for _, e := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "*" {
+			l.all = true
+			continue
+		}
+		if p, err := netip.ParsePrefix(e); err == nil {
+			l.prefixes = append(l.prefixes, p.Masked())
+			continue
+		}
+		h := noProxyHost{name: e}
+		if host, port, err := net.SplitHostPort(e); err == nil {
+			h.name, h.port = host, port
+		} else {
+			h.name = strings.Trim(e, "[]")
+		}
+		if ip, err := netip.ParseAddr(h.name); err == nil {
+			h.ip = ip.Unmap()
+		} else if rest, ok := strings.CutPrefix(h.name, "*."); ok {
+			h.name, h.subdomOnly = rest, true
+		} else if rest, ok := strings.CutPrefix(h.name, "."); ok {
+			h.name, h.subdomOnly = rest, true
+		}
+		if h.name == "" {
+			continue
+		}
+		l.hosts = append(l.hosts, h)
+	}
+	return l
+}
+
+// Match reports whether hostport, a host name or IP address with
+// an optional ":port", should bypass the proxy.
+func (l *NoProxyList) Match(hostport string) bool {
+	if l == nil {
+		return false
+	}
+	if l.all {
+		return true
+	}
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = strings.Trim(hostport, "[]"), ""
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip, ipErr := netip.ParseAddr(host)
+	if ipErr == nil {
+		ip = ip.Unmap()
+		for _, p := range l.prefixes {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, h := range l.hosts {
+		if h.port != "" && h.port != port {
+			continue
+		}
+		if h.ip.IsValid() {
+			if ipErr == nil && h.ip == ip {
+				return true
+			}
+			continue
+		}
+		if host == h.name && !h.subdomOnly {
+			return true
+		}
+		if strings.HasSuffix(host, "."+h.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyConfig is a proxy configuration in terms of the conventional
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY settings. Unlike
+// ProxyFromEnvironment, which reads the environment once for the whole
+// process, a ProxyConfig can be built per Transport:
+//
+//	tr.Proxy = http.ProxyConfigFromEnvironment().ProxyFunc()
+type ProxyConfig struct {
+	// HTTPProxy and HTTPSProxy are the proxies for http and https
+	// requests, as URLs or "host[:port]". Empty means no proxy.
+	HTTPProxy  string
+	HTTPSProxy string
+
+	// NoProxy lists hosts to reach directly; see NoProxyList.
+	NoProxy string
+
+	// CGI reports whether the program is running as a CGI
+	// handler, in which case HTTP_PROXY is ignored for safety.
+	CGI bool
+}
+
+// ProxyConfigFromEnvironment returns the proxy configuration currently
+// set in the environment, as used by ProxyFromEnvironment.
+func ProxyConfigFromEnvironment() *ProxyConfig {
+	c := httpproxy.FromEnvironment()
+	return &ProxyConfig{
+		HTTPProxy:  c.HTTPProxy,
+		HTTPSProxy: c.HTTPSProxy,
+		NoProxy:    c.NoProxy,
+		CGI:        c.CGI,
+	}
+}
+
+// ProxyFunc returns a function, suitable for Transport.Proxy, that
+// chooses the proxy for a request according to c. Requests to
+// localhost and loopback addresses are never proxied.
+func (c *ProxyConfig) ProxyFunc() func(*Request) (*url.URL, error) {
+	fn := c.urlProxyFunc()
+	return func(req *Request) (*url.URL, error) {
+		return fn(req.URL)
+	}
+}
+
+func (c *ProxyConfig) urlProxyFunc() func(*url.URL) (*url.URL, error) {
+	fn := (&httpproxy.Config{
+		HTTPProxy:  c.HTTPProxy,
+		HTTPSProxy: c.HTTPSProxy,
+		CGI:        c.CGI,
+	}).ProxyFunc()
+	noProxy := ParseNoProxy(c.NoProxy)
+	return func(u *url.URL) (*url.URL, error) {
+		if noProxy.Match(canonicalAddr(u)) {
+			return nil, nil
+		}
+		return fn(u)
+	}
+}
+
+// envProxy holds the configuration used by ProxyFromEnvironment.
+var envProxy struct {
+	fn atomic.Pointer[func(*url.URL) (*url.URL, error)]
+
+	mu      sync.Mutex // guards following and the creation of fn
+	noProxy *string    // set by SetNoProxy
+}
+
+// envProxyFunc returns a function that reads the
+// environment variable to determine the proxy address.
+func envProxyFunc() func(*url.URL) (*url.URL, error) {
+	if fn := envProxy.fn.Load(); fn != nil {
+		return *fn
+	}
+	envProxy.mu.Lock()
+	defer envProxy.mu.Unlock()
+	if fn := envProxy.fn.Load(); fn != nil {
+		return *fn
+	}
+	c := ProxyConfigFromEnvironment()
+	if envProxy.noProxy != nil {
+		c.NoProxy = *envProxy.noProxy
+	}
+	fn := c.urlProxyFunc()
+	envProxy.fn.Store(&fn)
+	return fn
+}
+
+// ResetProxyEnvironment makes ProxyFromEnvironment read the proxy
+// environment variables again on its next use and discards any list
+// set with SetNoProxy.
+func ResetProxyEnvironment() {
+	envProxy.mu.Lock()
+	defer envProxy.mu.Unlock()
+	envProxy.noProxy = nil
+	envProxy.fn.Store(nil)
+}
+
+// SetNoProxy replaces the NO_PROXY list used by ProxyFromEnvironment
+// with list, which uses the syntax described by NoProxyList. It takes
+// effect for subsequent requests.
+func SetNoProxy(list string) {
+	envProxy.mu.Lock()
+	defer envProxy.mu.Unlock()
+	envProxy.noProxy = &list
+	envProxy.fn.Store(nil)
+}
+
+// SetNoProxy sets a list of hosts, in the syntax described by
+// NoProxyList, that t reaches directly regardless of what t.Proxy
+// returns. An empty list removes the override. It may be called
+// while t is in use and affects subsequent requests.
+func (t *Transport) SetNoProxy(list string) {
+	if list == "" {
+		t.noProxy.Store(nil)
+		return
+	}
+	t.noProxy.Store(ParseNoProxy(list))
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/url"
+	"testing"
+)
+
+// TestNoProxyListMatch 测试 NO_PROXY 列表对 CIDR、通配符和端口的匹配
+func TestNoProxyListMatch(t *testing.T) {
+	l := ParseNoProxy("10.0.0.0/8, .corp.example,*.lab.example example.org, api.example:8443, 192.168.1.5, [::1]:80, fd00::/8")
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"10.2.3.4:443", true},
+		{"11.2.3.4:443", false},
+		{"[fd00::1]:443", true},
+		{"wiki.corp.example:80", true},
+		{"corp.example:80", false},
+		{"a.lab.example", true},
+		{"lab.example", false},
+		{"example.org:443", true},
+		{"www.example.org:443", true},
+		{"notexample.org:443", false},
+		{"api.example:8443", true},
+		{"api.example:443", false},
+		{"192.168.1.5:80", true},
+		{"192.168.1.6:80", false},
+		{"[::1]:80", true},
+		{"[::1]:81", false},
+		{"WIKI.CORP.EXAMPLE.:80", true},
+	}
+	for _, tt := range tests {
+		if got := l.Match(tt.host); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if !ParseNoProxy("*").Match("anything:80") {
+		t.Error(`"*" 应匹配所有主机`)
+	}
+	var nilList *NoProxyList
+	if nilList.Match("example.com:80") {
+		t.Error("nil 列表不应匹配任何主机")
+	}
+}
+
+// TestProxyFromEnvironmentReset 测试 SetNoProxy 与 ResetProxyEnvironment 在运行时生效
+func TestProxyFromEnvironmentReset(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.example:3128")
+	t.Setenv("NO_PROXY", "")
+	ResetProxyEnvironment()
+	defer ResetProxyEnvironment()
+
+	proxyFor := func(raw string) string {
+		req, _ := NewRequest("GET", raw, nil)
+		u, err := ProxyFromEnvironment(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u == nil {
+			return ""
+		}
+		return u.Host
+	}
+	if got := proxyFor("http://10.1.1.1/"); got != "proxy.example:3128" {
+		t.Fatalf("got %q, want proxy.example:3128", got)
+	}
+	SetNoProxy("10.0.0.0/8")
+	if got := proxyFor("http://10.1.1.1/"); got != "" {
+		t.Errorf("SetNoProxy 之后 got %q, want 直连", got)
+	}
+	t.Setenv("HTTP_PROXY", "http://other.example:8080")
+	ResetProxyEnvironment()
+	if got := proxyFor("http://10.1.1.1/"); got != "other.example:8080" {
+		t.Errorf("ResetProxyEnvironment 之后 got %q, want other.example:8080", got)
+	}
+}
+
+// TestTransportSetNoProxy 测试每个 Transport 独立的 NO_PROXY 覆盖
+func TestTransportSetNoProxy(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.example:3128")
+	tr := &Transport{Proxy: ProxyURL(proxy)}
+	tr.SetNoProxy(".internal.example, 172.16.0.0/12")
+	tr2 := tr.Clone()
+	other := &Transport{Proxy: ProxyURL(proxy)}
+
+	tests := []struct {
+		tr   *Transport
+		url  string
+		want bool // 是否走代理
+	}{
+		{tr, "http://svc.internal.example/", false},
+		{tr, "http://172.20.0.1/", false},
+		{tr, "http://www.example.com/", true},
+		{tr2, "http://svc.internal.example/", false},
+		{other, "http://svc.internal.example/", true},
+	}
+	for _, tt := range tests {
+		req, _ := NewRequest("GET", tt.url, nil)
+		cm, err := tt.tr.connectMethodForRequest(&transportRequest{Request: req})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cm.proxyURL != nil; got != tt.want {
+			t.Errorf("%s: 走代理 = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	tr.SetNoProxy("")
+	req, _ := NewRequest("GET", "http://svc.internal.example/", nil)
+	cm, _ := tr.connectMethodForRequest(&transportRequest{Request: req})
+	if cm.proxyURL == nil {
+		t.Error("清除覆盖后应走代理")
+	}
+}
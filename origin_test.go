@@ -0,0 +1,84 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// TestHTTP2OriginFrame 测试 ORIGIN 帧中的源集合通过 GotConnInfo 报告
+func TestHTTP2OriginFrame(t *testing.T) {
+	originFrame := func(origins ...string) []byte {
+		var p []byte
+		for _, o := range origins {
+			p = binary.BigEndian.AppendUint16(p, uint16(len(o)))
+			p = append(p, o...)
+		}
+		return p
+	}
+	ts := newH2FrameServer(t)
+	ts.greet = func(fr *http2Framer) {
+		fr.WriteRawFrame(http2frameOrigin, 0, 0, originFrame(
+			"https://A.example.com", "https://b.example.com:443", "https://c.example.com:8443",
+			"http://plain.example.com", "https://path.example.com/x", "https://a.example.com",
+		))
+		// 非 0 流上的 ORIGIN 帧被忽略
+		fr.WriteRawFrame(http2frameOrigin, 0, 1, originFrame("https://stream.example.com"))
+		// 截断的条目丢弃帧的其余部分
+		fr.WriteRawFrame(http2frameOrigin, 0, 0, append(originFrame("https://[::1]:443"), 0, 50, 'x'))
+	}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+
+	var got []string
+	for range 2 {
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { got = info.Origins },
+		})
+		req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	want := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com:8443", "https://[::1]"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Origins got %q, want %q", got, want)
+	}
+}
+
+// TestHTTP2CanonicalOrigin 测试源的规范化
+func TestHTTP2CanonicalOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"https://Example.COM", "https://example.com"},
+		{"https://example.com:443/", "https://example.com"},
+		{"HTTPS://example.com:8443", "https://example.com:8443"},
+		{"https://[2001:db8::1]:443", "https://[2001:db8::1]"},
+		{"https://[2001:db8::1]:8443", "https://[2001:db8::1]:8443"},
+		{"http://example.com", ""},
+		{"https://user@example.com", ""},
+		{"https://example.com/path", ""},
+		{"https://example.com?q", ""},
+		{"https://", ""},
+	} {
+		got, ok := http2canonicalOrigin(tt.in)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("http2canonicalOrigin(%q) got %q, %v, want %q", tt.in, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A PACFunc is a compiled proxy auto-config script: it evaluates the
+// script's FindProxyForURL(url, host) function and returns its result,
+// such as "PROXY proxy.example:8080; DIRECT".
+type PACFunc func(url, host string) (string, error)
+
+// PACResolver resolves proxies using a proxy auto-config (PAC) file.
+// Its Proxy method is meant to be used as Transport.Proxy and its
+// Failover method as Transport.ProxyFailover:
+//
+//	pac := &http.PACResolver{URL: "http://wpad.corp.example/proxy.pac", Compile: compile}
+//	tr := &http.Transport{Proxy: pac.Proxy, ProxyFailover: pac.Failover}
+//
+// This package does not include a JavaScript engine; Compile must be
+// provided, typically by wrapping an embeddable interpreter and
+// installing the standard PAC helper functions (isPlainHostName,
+// dnsDomainIs, shExpMatch, ...).
+//
+// The script is cached for ScriptTTL and results are cached per scheme
+// and host for ResultTTL, so FindProxyForURL scripts that depend on the
+// URL path are not supported.
+//
+// A PACResolver must not be copied after first use.
+type PACResolver struct {
+	// URL is the location of the PAC file. It is fetched with Client.
+	// "file" URLs are not supported; use Script instead.
+	URL string
+
+	// Script, if non-empty, is the PAC script to use instead of
+	// fetching URL.
+	Script string
+
+	// Compile compiles a PAC script. It must be set.
+	Compile func(script string) (PACFunc, error)
+
+	// Client is used to fetch URL. If nil, a Client with a Transport
+	// that uses no proxy is used.
+	Client *Client
+
+	// ScriptTTL is how long a fetched script is used before it is
+	// fetched again. If zero, one hour is used. If refetching fails,
+	// the previous script keeps being used.
+	ScriptTTL time.Duration
+
+	// ResultTTL is how long the proxy list for a scheme and host is
+	// cached. If zero, five minutes is used.
+	ResultTTL time.Duration
+
+	// FailureCooldown is how long a proxy reported to Failover is
+	// skipped. If zero, 30 seconds is used.
+	FailureCooldown time.Duration
+
+	mu        sync.Mutex
+	fn        PACFunc
+	fetchedAt time.Time
+	results   map[string]pacResult
+	failed    map[string]time.Time // proxy URL string → failure time
+}
+
+type pacResult struct {
+	proxies []*url.URL // nil entries mean DIRECT
+	at      time.Time
+}
+
+// Proxy returns the first usable proxy from the PAC result for req,
+// skipping proxies that recently failed. It returns a nil URL for
+// DIRECT. If every proxy in the list has recently failed, the first
+// one is returned anyway.
+func (r *PACResolver) Proxy(req *Request) (*url.URL, error) {
+	proxies, err := r.proxies(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range proxies {
+		if !r.failedLocked(p) {
+			return p, nil
+		}
+	}
+	return proxies[0], nil
+}
+
+// Failover records that failed could not be reached and returns the
+// next proxy from the PAC result for req that has not recently failed.
+// A nil URL with retry true means to connect directly.
+// retry is false when there is nothing left to try.
+func (r *PACResolver) Failover(req *Request, failed *url.URL, err error) (next *url.URL, retry bool) {
+	proxies, perr := r.proxies(req)
+	if perr != nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if failed != nil {
+		if r.failed == nil {
+			r.failed = make(map[string]time.Time)
+		}
+		r.failed[failed.String()] = time.Now()
+	}
+	for _, p := range proxies {
+		if !r.failedLocked(p) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// failedLocked reports whether proxy p is in its failure cooldown.
+// A nil p (DIRECT) never fails. r.mu must be held.
+func (r *PACResolver) failedLocked(p *url.URL) bool {
+	if p == nil {
+		return false
+	}
+	at, ok := r.failed[p.String()]
+	if !ok {
+		return false
+	}
+	cooldown := r.FailureCooldown
+	if cooldown == 0 {
+		cooldown = 30 * time.Second
+	}
+	if time.Since(at) >= cooldown {
+		delete(r.failed, p.String())
+		return false
+	}
+	return true
+}
+
+// proxies returns the parsed PAC result for req, using the cache.
+func (r *PACResolver) proxies(req *Request) ([]*url.URL, error) {
+	key := req.URL.Scheme + "://" + req.URL.Host
+	ttl := r.ResultTTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+	r.mu.Lock()
+	if res, ok := r.results[key]; ok && time.Since(res.at) < ttl {
+		r.mu.Unlock()
+		return res.proxies, nil
+	}
+	r.mu.Unlock()
+
+	fn, err := r.script(req)
+	if err != nil {
+		return nil, err
+	}
+	// As browsers do, don't expose the path and query of https URLs.
+	u := req.URL.String()
+	if req.URL.Scheme == "https" {
+		u = "https://" + req.URL.Host + "/"
+	}
+	out, err := fn(u, req.URL.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("net/http: evaluating PAC script: %w", err)
+	}
+	proxies, err := ParsePACResult(out)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.results == nil {
+		r.results = make(map[string]pacResult)
+	}
+	r.results[key] = pacResult{proxies: proxies, at: time.Now()}
+	r.mu.Unlock()
+	return proxies, nil
+}
+
+// script returns the compiled PAC script, fetching it if needed.
+func (r *PACResolver) script(req *Request) (PACFunc, error) {
+	if r.Compile == nil {
+		return nil, errors.New("net/http: PACResolver.Compile is nil")
+	}
+	ttl := r.ScriptTTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	r.mu.Lock()
+	fn, fetchedAt := r.fn, r.fetchedAt
+	r.mu.Unlock()
+	if fn != nil && (r.Script != "" || time.Since(fetchedAt) < ttl) {
+		return fn, nil
+	}
+
+	src := r.Script
+	if src == "" {
+		var err error
+		src, err = r.fetch(req)
+		if err != nil {
+			if fn != nil {
+				return fn, nil // keep using the stale script
+			}
+			return nil, err
+		}
+	}
+	newFn, err := r.Compile(src)
+	if err != nil {
+		if fn != nil {
+			return fn, nil
+		}
+		return nil, fmt.Errorf("net/http: compiling PAC script: %w", err)
+	}
+	r.mu.Lock()
+	r.fn, r.fetchedAt = newFn, time.Now()
+	r.results = nil // results may differ under the new script
+	r.mu.Unlock()
+	return newFn, nil
+}
+
+func (r *PACResolver) fetch(req *Request) (string, error) {
+	if r.URL == "" {
+		return "", errors.New("net/http: PACResolver has neither URL nor Script")
+	}
+	c := r.Client
+	if c == nil {
+		c = &Client{Transport: &Transport{}}
+	}
+	preq, err := NewRequestWithContext(req.Context(), "GET", r.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.Do(preq)
+	if err != nil {
+		return "", fmt.Errorf("net/http: fetching PAC file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != StatusOK {
+		return "", fmt.Errorf("net/http: fetching PAC file: unexpected status %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("net/http: fetching PAC file: %w", err)
+	}
+	return string(b), nil
+}
+
+// ParsePACResult parses the return value of a PAC FindProxyForURL
+// function, such as "PROXY a.example:8080; SOCKS5 b.example:1080; DIRECT",
+// into an ordered list of proxy URLs. DIRECT is represented by a nil
+// entry. PROXY and HTTP map to http URLs, HTTPS to https, and SOCKS and
+// SOCKS5 to socks5. Unsupported entries such as SOCKS4 are skipped.
+// An empty result means DIRECT.
+func ParsePACResult(s string) ([]*url.URL, error) {
+	var out []*url.URL
+	seen := false
+	for _, entry := range strings.Split(s, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		seen = true
+		kind := strings.ToUpper(fields[0])
+		if kind == "DIRECT" {
+			out = append(out, nil)
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("net/http: invalid PAC result entry %q", strings.TrimSpace(entry))
+		}
+		var scheme string
+		switch kind {
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			continue
+		}
+		u, err := url.Parse(scheme + "://" + fields[1])
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("net/http: invalid PAC proxy address %q", fields[1])
+		}
+		out = append(out, u)
+	}
+	if !seen {
+		return []*url.URL{nil}, nil
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("net/http: no supported proxy in PAC result %q", s)
+	}
+	return out, nil
+}
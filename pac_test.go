@@ -0,0 +1,107 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// TestParsePACResult 测试 PAC 返回值解析
+func TestParsePACResult(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string // "" 表示 DIRECT
+		wantErr bool
+	}{
+		{"DIRECT", []string{""}, false},
+		{"", []string{""}, false},
+		{"PROXY a.example:8080; DIRECT", []string{"http://a.example:8080", ""}, false},
+		{"HTTPS b.example:443;SOCKS5 c.example:1080", []string{"https://b.example:443", "socks5://c.example:1080"}, false},
+		{"SOCKS4 d.example:1080; proxy e.example:3128", []string{"http://e.example:3128"}, false},
+		{"SOCKS4 d.example:1080", nil, true},
+		{"PROXY", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePACResult(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePACResult(%q) 错误 = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePACResult(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			for i, u := range got {
+				s := ""
+				if u != nil {
+					s = u.String()
+				}
+				if s != tt.want[i] {
+					t.Errorf("ParsePACResult(%q)[%d] = %q, want %q", tt.in, i, s, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestPACResolverFailover 测试 PAC 脚本获取、缓存和代理故障转移
+func TestPACResolverFailover(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var fetches atomic.Int32
+	pacSrv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		fetches.Add(1)
+		// 127.0.0.1:1 上没有监听，连接会被拒绝
+		io.WriteString(w, "PROXY 127.0.0.1:1; DIRECT")
+	}))
+	defer pacSrv.Close()
+
+	var evals atomic.Int32
+	pac := &PACResolver{
+		URL: pacSrv.URL,
+		Compile: func(script string) (PACFunc, error) {
+			return func(u, host string) (string, error) {
+				evals.Add(1)
+				return script, nil
+			}, nil
+		},
+	}
+	tr := &Transport{Proxy: pac.Proxy, ProxyFailover: pac.Failover}
+	defer tr.CloseIdleConnections()
+	client := &Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("第 %d 次请求失败: %v", i+1, err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("PAC 文件获取次数 = %d, want 1", got)
+	}
+	if got := evals.Load(); got != 1 {
+		t.Errorf("FindProxyForURL 调用次数 = %d, want 1（结果应被缓存）", got)
+	}
+
+	req, _ := NewRequest("GET", srv.URL, nil)
+	if p, err := pac.Proxy(req); err != nil || p != nil {
+		t.Errorf("Proxy() = %v, %v, want 冷却期内跳过失败的代理并直连", p, err)
+	}
+	failed, _ := url.Parse("http://127.0.0.1:1")
+	if _, retry := pac.Failover(req, failed, nil); !retry {
+		t.Error("列表中还有 DIRECT，Failover 应该返回 retry=true")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// PaddingMode 决定 ClientHello 中 padding (21) 扩展的长度，见 PaddingConfig
+type PaddingMode int
+
+const (
+	// PaddingDefault 保持指纹来源的计算方式：JA3 和 ClientHelloID 与 BoringSSL 相同，
+	// ClientHelloHexStream 补齐到抓取时的握手消息长度，SNI 等长度不同时 padding 的长度随之改变
+	PaddingDefault PaddingMode = iota
+
+	// PaddingBoringSSL 与 Chrome 相同：握手消息在 256 到 511 字节之间时补齐到 512 字节，
+	// 其余长度不发送
+	PaddingBoringSSL
+
+	// PaddingTarget 在握手消息短于 PaddingConfig.TargetSize 时补齐到 TargetSize
+	PaddingTarget
+
+	// PaddingFixed 总是发送 PaddingConfig.Length 字节的 padding
+	PaddingFixed
+
+	// PaddingNone 不发送 padding 扩展，与 Firefox 和 Safari 相同
+	PaddingNone
+
+	// PaddingReplay 总是使用 ClientHelloHexStream 中抓取的 padding 长度，
+	// 指纹来自其他来源时与 PaddingDefault 相同。SNI、会话等长度与抓取时不同时
+	// 握手消息的长度随之改变，padding 扩展本身与抓取的相同
+	PaddingReplay
+)
+
+// PaddingConfig 控制指纹连接 ClientHello 中 padding 扩展 (RFC 7685) 的长度，
+// 见 Transport.Padding。只改变指纹中已有的 padding 扩展，不添加它
+type PaddingConfig struct {
+	// Mode 决定 padding 的长度
+	Mode PaddingMode
+
+	// Length 是 PaddingFixed 的 padding 数据长度，不含 4 字节的扩展头
+	Length int
+
+	// TargetSize 是 PaddingTarget 补齐到的握手消息长度，不含 5 字节的 TLS 记录头
+	TargetSize int
+}
+
+// applyPadding 按 t.Padding 设置 spec 中 padding 扩展的长度
+func (t *Transport) applyPadding(spec *tls.ClientHelloSpec) error {
+	p := t.Padding
+	if p == nil || p.Mode == PaddingDefault || p.Mode == PaddingReplay {
+		return nil
+	}
+	switch p.Mode {
+	case PaddingFixed:
+		if p.Length < 0 || p.Length > 0xffff-4 {
+			return fmt.Errorf("PaddingConfig.Length %d 超出范围", p.Length)
+		}
+	case PaddingTarget:
+		if p.TargetSize <= 0 || p.TargetSize > 0xffff {
+			return fmt.Errorf("PaddingConfig.TargetSize %d 超出范围", p.TargetSize)
+		}
+	}
+	for _, e := range spec.Extensions {
+		pe, ok := e.(*tls.UtlsPaddingExtension)
+		if !ok {
+			continue
+		}
+		switch p.Mode {
+		case PaddingBoringSSL:
+			*pe = tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}
+		case PaddingTarget:
+			*pe = tls.UtlsPaddingExtension{GetPaddingLen: tls.AlwaysPadToLen(p.TargetSize)}
+		case PaddingFixed:
+			*pe = tls.UtlsPaddingExtension{PaddingLen: p.Length, WillPad: true}
+		case PaddingNone:
+			*pe = tls.UtlsPaddingExtension{}
+		default:
+			return fmt.Errorf("未知的 PaddingMode %d", p.Mode)
+		}
+	}
+	return nil
+}
+
+// replayPadding 在 t.Padding 为 PaddingReplay 时，把从 raw 解析出的 spec 中的
+// padding 扩展设为 raw 中抓取的长度。raw 可以带 TLS 记录头
+func (t *Transport) replayPadding(spec *tls.ClientHelloSpec, raw []byte) error {
+	if t.Padding == nil || t.Padding.Mode != PaddingReplay {
+		return nil
+	}
+	if len(raw) > 5 && raw[0] == 0x16 {
+		raw = raw[5:]
+	}
+	h, err := parseClientHello(raw)
+	if err != nil {
+		return err
+	}
+	if h.paddingLen < 0 {
+		return nil
+	}
+	for _, e := range spec.Extensions {
+		if pe, ok := e.(*tls.UtlsPaddingExtension); ok {
+			*pe = tls.UtlsPaddingExtension{PaddingLen: h.paddingLen, WillPad: true}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+const paddingTestJA3 = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-21,29-23-24,0"
+
+// helloPadding 返回 tr 生成的 ClientHello 握手消息 (含 4 字节消息头) 的长度和其中
+// padding 扩展的数据长度，没有 padding 扩展时为 -1
+func helloPadding(t *testing.T, tr *Transport, serverName string) (raw []byte, paddingLen int) {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc, err := tr.NewUConn(c1, &tls.Config{ServerName: serverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	raw = uc.HandshakeState.Hello.Raw
+	h, err := parseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw, h.paddingLen
+}
+
+func TestPadding(t *testing.T) {
+	unpadded, _ := helloPadding(t, &Transport{JA3: paddingTestJA3, Padding: &PaddingConfig{Mode: PaddingNone}}, "example.com")
+	if n := len(unpadded); n < 256 || n >= 512 {
+		t.Fatalf("未补齐的 ClientHello 长度 %d 不在 BoringSSL 补齐的范围内", n)
+	}
+
+	tests := []struct {
+		name       string
+		padding    *PaddingConfig
+		wantLen    int // 握手消息长度，0 表示不检查
+		wantPadLen int
+	}{
+		{name: "默认", wantLen: 512},
+		{name: "BoringSSL", padding: &PaddingConfig{Mode: PaddingBoringSSL}, wantLen: 512},
+		{name: "补齐到 600", padding: &PaddingConfig{Mode: PaddingTarget, TargetSize: 600}, wantLen: 600},
+		{name: "已超过目标长度", padding: &PaddingConfig{Mode: PaddingTarget, TargetSize: 200}, wantLen: len(unpadded), wantPadLen: -1},
+		{name: "固定长度", padding: &PaddingConfig{Mode: PaddingFixed, Length: 37}, wantLen: len(unpadded) + 4 + 37, wantPadLen: 37},
+		{name: "固定长度 0", padding: &PaddingConfig{Mode: PaddingFixed}, wantPadLen: 0},
+		{name: "不发送", padding: &PaddingConfig{Mode: PaddingNone}, wantPadLen: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, padLen := helloPadding(t, &Transport{JA3: paddingTestJA3, Padding: tt.padding}, "example.com")
+			if tt.wantLen != 0 && len(raw) != tt.wantLen {
+				t.Errorf("ClientHello 长度 got %d, want %d", len(raw), tt.wantLen)
+			}
+			if tt.wantPadLen != 0 && padLen != tt.wantPadLen || tt.wantPadLen == 0 && tt.wantLen == 0 && padLen != 0 {
+				t.Errorf("padding 长度 got %d, want %d", padLen, tt.wantPadLen)
+			}
+		})
+	}
+
+	for _, p := range []*PaddingConfig{{Mode: PaddingTarget}, {Mode: PaddingFixed, Length: -1}} {
+		c1, c2 := net.Pipe()
+		if _, err := (&Transport{JA3: paddingTestJA3, Padding: p}).NewUConn(c1, &tls.Config{ServerName: "example.com"}); err == nil {
+			t.Errorf("%+v: 期望错误", *p)
+		}
+		c1.Close()
+		c2.Close()
+	}
+}
+
+// TestPaddingReplay 测试 PaddingReplay 使重放的 ClientHelloHexStream 使用抓取的 padding 长度，
+// 默认补齐到抓取时的长度
+func TestPaddingReplay(t *testing.T) {
+	captured, _ := helloPadding(t, &Transport{JA3: paddingTestJA3, Padding: &PaddingConfig{Mode: PaddingFixed, Length: 37}}, "example.com")
+	record := append([]byte{0x16, 0x03, 0x01, byte(len(captured) >> 8), byte(len(captured))}, captured...)
+	stream := hex.EncodeToString(record)
+
+	// SNI 比抓取时长 4 字节
+	raw, padLen := helloPadding(t, &Transport{ClientHelloHexStream: stream}, "www.example.com")
+	if padLen != 33 || len(raw) != len(captured) {
+		t.Errorf("默认 got padding %d、长度 %d, want 33、%d", padLen, len(raw), len(captured))
+	}
+	raw, padLen = helloPadding(t, &Transport{ClientHelloHexStream: stream, Padding: &PaddingConfig{Mode: PaddingReplay}}, "www.example.com")
+	if padLen != 37 || len(raw) != len(captured)+4 {
+		t.Errorf("PaddingReplay got padding %d、长度 %d, want 37、%d", padLen, len(raw), len(captured)+4)
+	}
+}
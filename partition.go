@@ -0,0 +1,162 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// connPartition 是连接池在目标之外的划分，划分不同的请求从不共享连接
+type connPartition struct {
+	fingerprint string // 请求指定的指纹的摘要，见 WithFingerprint
+	credentials string // 请求凭据的摘要，见 Transport.IsolateCredentials
+	affinity    string // 亲和组，见 WithAffinity
+
+	// proxy 是代理 URL 的摘要，只用于 HTTP/2 连接池，见 connectMethodKey.h2Partition
+	proxy string
+}
+
+// requestPartition 返回 req 所属的划分
+func (t *Transport) requestPartition(req *Request) connPartition {
+	var p connPartition
+	if fp, ok := FingerprintFromContext(req.Context()); ok {
+		p.fingerprint = fp.poolKey()
+	}
+	if t.IsolateCredentials {
+		p.credentials = credentialsKey(req.Header, t.CredentialCookies)
+	}
+	p.affinity, _ = AffinityFromContext(req.Context())
+	return p
+}
+
+// String 返回划分在连接池键中的形式，如 ~1a2b3c4d5e6f7a8b!8b7a6f5e4d3c2b1a#acct1，
+// 不划分时为空
+func (p connPartition) String() string {
+	var s string
+	if p.fingerprint != "" {
+		s += "~" + p.fingerprint
+	}
+	if p.credentials != "" {
+		s += "!" + p.credentials
+	}
+	if p.proxy != "" {
+		s += "@" + p.proxy
+	}
+	if p.affinity != "" {
+		s += "#" + p.affinity
+	}
+	return s
+}
+
+// splitPartition 将 addr 加上 connPartition.String 得到的键拆回地址和划分
+func splitPartition(key string) (addr string, p connPartition) {
+	addr, p.affinity, _ = strings.Cut(key, "#")
+	addr, p.proxy, _ = strings.Cut(addr, "@")
+	addr, p.credentials, _ = strings.Cut(addr, "!")
+	addr, p.fingerprint, _ = strings.Cut(addr, "~")
+	return addr, p
+}
+
+// h2Partition 返回以 k 建立的 HTTP/2 连接在 HTTP/2 连接池中的划分
+//
+// HTTP/2 连接池只按目标地址区分连接，经不同代理 (包括用户名中的会话不同) 建立的
+// 连接因此要加上代理的摘要，以免被互相复用。
+func (k connectMethodKey) h2Partition() connPartition {
+	p := k.partition
+	if k.proxy != "" {
+		sum := sha256.Sum256([]byte(k.proxy))
+		p.proxy = hex.EncodeToString(sum[:8])
+	}
+	return p
+}
+
+// h2PartitionKey 是 RoundTrip 传给 HTTP/2 连接池的 connectMethodKey.h2Partition 的 context 键
+type h2PartitionKey struct{}
+
+// credentialsKey 返回 h 中凭据所属身份的摘要，没有凭据时返回空
+//
+// Authorization 按认证方案取稳定的身份，而不是每个请求都可能变化的值：Basic、Digest
+// 取用户名，AWS4-HMAC-SHA256 取 Access Key ID，NTLM、Negotiate 等基于连接的方案
+// 只取方案名，使握手的各个请求留在同一个划分，其余方案取整个凭据。Cookie 只取
+// cookies 中列出的 Cookie 的值，见 Transport.CredentialCookies。
+func credentialsKey(h Header, cookies []string) string {
+	var ids []string
+	for _, v := range h.Values("Authorization") {
+		ids = append(ids, authIdentity(v))
+	}
+	if len(cookies) > 0 {
+		for _, v := range h.Values("Cookie") {
+			for _, c := range strings.Split(v, ";") {
+				name, _, _ := strings.Cut(strings.TrimSpace(c), "=")
+				if slices.Contains(cookies, name) {
+					ids = append(ids, "cookie:"+strings.TrimSpace(c))
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	slices.Sort(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// authIdentity 返回 Authorization 头部的值 v 所属的身份
+func authIdentity(v string) string {
+	scheme, param, _ := strings.Cut(strings.TrimSpace(v), " ")
+	scheme = strings.ToLower(scheme)
+	param = strings.TrimSpace(param)
+	switch scheme {
+	case "ntlm", "negotiate", "kerberos":
+		return scheme
+	case "basic":
+		if b, err := base64.StdEncoding.DecodeString(param); err == nil {
+			user, _, _ := strings.Cut(string(b), ":")
+			return scheme + ":" + user
+		}
+	case "digest":
+		if user, ok := authParam(param, "username"); ok {
+			return scheme + ":" + user
+		}
+	case "aws4-hmac-sha256":
+		if cred, ok := authParam(param, "Credential"); ok {
+			key, _, _ := strings.Cut(cred, "/")
+			return scheme + ":" + key
+		}
+	}
+	return scheme + ":" + param
+}
+
+// authParam 返回以逗号分隔的认证参数 params 中 name 的值，忽略大小写并去掉引号
+func authParam(params, name string) (string, bool) {
+	for _, p := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.Trim(strings.TrimSpace(v), `"`), true
+		}
+	}
+	return "", false
+}
+
+// partitionSessionCache 在会话键前加上划分，使不同划分的 TLS 会话互不可见
+type partitionSessionCache struct {
+	tls.ClientSessionCache
+	prefix string
+}
+
+func (c partitionSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	return c.ClientSessionCache.Get(c.prefix + "\x00" + sessionKey)
+}
+
+func (c partitionSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.ClientSessionCache.Put(c.prefix+"\x00"+sessionKey, cs)
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/base64"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestSplitPartition 测试从连接池键中拆出地址和划分
+func TestSplitPartition(t *testing.T) {
+	tests := []connPartition{
+		{},
+		{fingerprint: "1a2b3c4d5e6f7a8b"},
+		{credentials: "8b7a6f5e4d3c2b1a"},
+		{affinity: "acct#1~!"},
+		{fingerprint: "1a2b3c4d5e6f7a8b", credentials: "8b7a6f5e4d3c2b1a", affinity: "acct1"},
+	}
+	for _, want := range tests {
+		addr, got := splitPartition("example.com:443" + want.String())
+		if addr != "example.com:443" || got != want {
+			t.Errorf("%q: got %q %+v, want %+v", want.String(), addr, got, want)
+		}
+	}
+}
+
+// TestTransportIsolateCredentials 测试凭据不同的请求不共享连接
+func TestTransportIsolateCredentials(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			io.WriteString(w, r.RemoteAddr)
+		}))
+		ts.EnableHTTP2 = h2
+		ts.StartTLS()
+		for _, isolate := range []bool{false, true} {
+			tr := &Transport{
+				JA3:                "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				ForceAttemptHTTP2:  h2,
+				TLSClientConfig:    &tls.Config{InsecureSkipVerify: true},
+				IsolateCredentials: isolate,
+				CredentialCookies:  []string{"session"},
+			}
+			get := func(header, value string) string {
+				t.Helper()
+				req, _ := NewRequest("GET", ts.URL, nil)
+				if header != "" {
+					req.Header.Set(header, value)
+				}
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				b, _ := io.ReadAll(resp.Body)
+				return string(b)
+			}
+
+			conns := map[string]string{
+				"a":      get("Authorization", "Bearer a"),
+				"b":      get("Authorization", "Bearer b"),
+				"cookie": get("Cookie", "session=a"),
+				"none":   get("", ""),
+			}
+			if got := get("Authorization", "Bearer a"); got != conns["a"] {
+				t.Errorf("h2 %v, isolate %v: 相同凭据没有复用连接: got %s, want %s", h2, isolate, got, conns["a"])
+			}
+			seen := make(map[string]bool)
+			for _, c := range conns {
+				seen[c] = true
+			}
+			want := 1
+			if isolate {
+				want = len(conns)
+			}
+			if len(seen) != want {
+				t.Errorf("h2 %v, isolate %v: got %d 个连接, want %d: %v", h2, isolate, len(seen), want, conns)
+			}
+			tr.CloseIdleConnections()
+		}
+		ts.Close()
+	}
+}
+
+// TestCredentialsKey 测试同一身份随请求变化的凭据得到相同的划分
+func TestCredentialsKey(t *testing.T) {
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+	sigv4 := func(key, sig string) string {
+		return "AWS4-HMAC-SHA256 Credential=" + key + "/20250101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=" + sig
+	}
+	tests := []struct {
+		name     string
+		a, b     string // Authorization
+		sameUser bool
+	}{
+		{"Basic 同一用户", basic("alice", "1"), basic("alice", "2"), true},
+		{"Basic 不同用户", basic("alice", "1"), basic("bob", "1"), false},
+		{"Digest nc 变化", `Digest username="alice", nc=00000001, response="a"`, `Digest username="alice", nc=00000002, response="b"`, true},
+		{"Digest 不同用户", `Digest username="alice", nc=00000001`, `Digest username="bob", nc=00000001`, false},
+		{"SigV4 签名变化", sigv4("AKIA1", "aa"), sigv4("AKIA1", "bb"), true},
+		{"SigV4 不同密钥", sigv4("AKIA1", "aa"), sigv4("AKIA2", "aa"), false},
+		{"NTLM 握手", "NTLM TlRMTVNTUAABAAAA", "NTLM TlRMTVNTUAADAAAA", true},
+		{"Bearer", "Bearer a", "Bearer b", false},
+	}
+	for _, tt := range tests {
+		ka := credentialsKey(Header{"Authorization": {tt.a}}, nil)
+		kb := credentialsKey(Header{"Authorization": {tt.b}}, nil)
+		if ka == "" || (ka == kb) != tt.sameUser {
+			t.Errorf("%s: got %q 和 %q, want 相同 %v", tt.name, ka, kb, tt.sameUser)
+		}
+	}
+
+	// 只有列出的 Cookie 参与划分
+	cookies := []string{"session"}
+	k1 := credentialsKey(Header{"Cookie": {"session=a; _ga=1"}}, cookies)
+	if k2 := credentialsKey(Header{"Cookie": {"_ga=2; session=a"}}, cookies); k1 == "" || k1 != k2 {
+		t.Errorf("其他 Cookie 变化改变了划分: %q %q", k1, k2)
+	}
+	if k2 := credentialsKey(Header{"Cookie": {"session=b; _ga=1"}}, cookies); k1 == k2 {
+		t.Errorf("会话 Cookie 不同时划分相同")
+	}
+	if k := credentialsKey(Header{"Cookie": {"session=a"}}, nil); k != "" {
+		t.Errorf("没有 CredentialCookies 时 Cookie 参与了划分: %q", k)
+	}
+}
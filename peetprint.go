@@ -0,0 +1,288 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// PeetPrint 是 tls.peet.ws 报告的客户端指纹，可以与浏览器访问该网站的结果逐项比较
+type PeetPrint struct {
+	// TLS 是 peetprint 字符串，TLSHash 是它的 MD5 (peetprint_hash)
+	TLS     string
+	TLSHash string
+
+	// HTTP2 是 Akamai 格式的 HTTP/2 指纹 (akamai_fingerprint)，
+	// HTTP2Hash 是它的 MD5 (akamai_fingerprint_hash)。不使用 HTTP/2 时为空
+	HTTP2     string
+	HTTP2Hash string
+}
+
+// peetPrintSections 和 akamaiSections 是两种指纹中 "|" 分隔的各部分的名称
+var (
+	peetPrintSections = []string{"versions", "protocols", "groups", "signature_algorithms",
+		"psk_key_exchange_modes", "certificate_compression", "ciphers", "extensions"}
+	akamaiSections = []string{"settings", "window_update", "priority", "pseudo_header_order"}
+)
+
+// Diff 比较 p 和 want，返回不一致之处的说明，一致时返回空。want 中为空的字段不比较，
+// 只有哈希时比较哈希。字符串不一致时逐部分说明，如 "peetprint ciphers: got ..., want ..."
+func (p PeetPrint) Diff(want PeetPrint) []string {
+	var diffs []string
+	compare := func(name string, sections []string, got, want, gotHash, wantHash string) {
+		switch {
+		case want != "" && got != want:
+			g, w := strings.Split(got, "|"), strings.Split(want, "|")
+			if len(g) != len(sections) || len(w) != len(sections) {
+				diffs = append(diffs, fmt.Sprintf("%s: got %q, want %q", name, got, want))
+				return
+			}
+			for i, s := range sections {
+				if g[i] != w[i] {
+					diffs = append(diffs, fmt.Sprintf("%s %s: got %q, want %q", name, s, g[i], w[i]))
+				}
+			}
+		case want == "" && wantHash != "" && gotHash != wantHash:
+			diffs = append(diffs, fmt.Sprintf("%s hash: got %s, want %s", name, gotHash, wantHash))
+		}
+	}
+	compare("peetprint", peetPrintSections, p.TLS, want.TLS, p.TLSHash, want.TLSHash)
+	compare("akamai_fingerprint", akamaiSections, p.HTTP2, want.HTTP2, p.HTTP2Hash, want.HTTP2Hash)
+	return diffs
+}
+
+// PeetPrint 返回 t 建连时的 peetprint 和 HTTP/2 指纹，不进行任何网络操作
+//
+// ClientHello 与 Fingerprint 一样按建连时的方式构建，HTTP/2 指纹取自 HTTP2Settings，
+// 未设置时为 Go 默认的 SETTINGS。可以用 PeetPrint.Diff 与浏览器在 tls.peet.ws
+// 上的结果比较，SetHTTP2Fingerprint 可以让 HTTP/2 部分与浏览器一致。
+// peetprint 的扩展部分不含 padding (21)，因为它是否出现取决于 ClientHello 的长度。
+func (t *Transport) PeetPrint() (PeetPrint, error) {
+	if !t.usesCustomTLS() {
+		return PeetPrint{}, errNoFingerprint
+	}
+	host := "example.com"
+	if t.TLSClientConfig != nil && t.TLSClientConfig.ServerName != "" {
+		host = t.TLSClientConfig.ServerName
+	}
+	spec, err := t.BuildSpec(host)
+	if err != nil {
+		return PeetPrint{}, err
+	}
+	p := PeetPrint{TLS: peetPrintFromSpec(spec)}
+	p.TLSHash = ja3Hash(p.TLS)
+
+	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
+	t2, ok := t.H2Transport.(*HTTP2Transport)
+	if !ok || !specOffersH2(spec) {
+		return p, nil
+	}
+	init, err := t2.initialFrames()
+	if err != nil {
+		return PeetPrint{}, err
+	}
+	var pseudo []string
+	if s := t2.http2Settings(); s != nil {
+		pseudo = s.PseudoHeaderOrder
+	}
+	p.HTTP2 = akamaiFingerprint(init, pseudo)
+	p.HTTP2Hash = ja3Hash(p.HTTP2)
+	return p, nil
+}
+
+// specOffersH2 报告 spec 的 ALPN 是否包含 h2
+func specOffersH2(spec *tls.ClientHelloSpec) bool {
+	for _, e := range spec.Extensions {
+		if alpn, ok := e.(*tls.ALPNExtension); ok {
+			return slices.Contains(alpn.AlpnProtocols, "h2")
+		}
+	}
+	return false
+}
+
+// peetPrintFromSpec 返回 spec 的 peetprint 字符串
+func peetPrintFromSpec(spec *tls.ClientHelloSpec) string {
+	var versions, groups, sigAlgs, pskModes, certComp []uint16
+	var protocols []string
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.SupportedVersionsExtension:
+			versions = e.Versions
+		case *tls.ALPNExtension:
+			for _, proto := range e.AlpnProtocols {
+				protocols = append(protocols, strings.TrimPrefix(strings.TrimPrefix(proto, "http/"), "h"))
+			}
+		case *tls.SupportedCurvesExtension:
+			for _, c := range e.Curves {
+				groups = append(groups, uint16(c))
+			}
+		case *tls.SignatureAlgorithmsExtension:
+			for _, s := range e.SupportedSignatureAlgorithms {
+				sigAlgs = append(sigAlgs, uint16(s))
+			}
+		case *tls.PSKKeyExchangeModesExtension:
+			for _, m := range e.Modes {
+				pskModes = append(pskModes, uint16(m))
+			}
+		case *tls.UtlsCompressCertExtension:
+			for _, a := range e.Algorithms {
+				certComp = append(certComp, uint16(a))
+			}
+		}
+	}
+	var exts []string
+	for _, e := range ja4FieldsFromSpec(spec).extensions {
+		if e != 21 {
+			exts = append(exts, peetPrintValue(e))
+		}
+	}
+	// peetprint 按字符串排序扩展，GREASE 排在最后
+	slices.Sort(exts)
+
+	join := func(vs []uint16) string {
+		s := make([]string, len(vs))
+		for i, v := range vs {
+			s[i] = peetPrintValue(v)
+		}
+		return strings.Join(s, "-")
+	}
+	return strings.Join([]string{
+		join(versions),
+		strings.Join(protocols, "-"),
+		join(groups),
+		join(sigAlgs),
+		join(pskModes),
+		join(certComp),
+		join(spec.CipherSuites),
+		strings.Join(exts, "-"),
+	}, "|")
+}
+
+// peetPrintValue 返回 peetprint 中的值，GREASE 值统一为 "GREASE"
+func peetPrintValue(v uint16) string {
+	if isGREASEValue(v) {
+		return "GREASE"
+	}
+	return strconv.Itoa(int(v))
+}
+
+// akamaiPseudoHeaders 是 Akamai 指纹中伪头部的缩写
+var akamaiPseudoHeaders = map[string]string{
+	":method":    "m",
+	":authority": "a",
+	":scheme":    "s",
+	":path":      "p",
+}
+
+// akamaiFingerprint 返回新连接发送 init 中的帧、请求按 pseudo 排列伪头部时的
+// Akamai HTTP/2 指纹，pseudo 为空时为默认顺序
+func akamaiFingerprint(init http2initialFrames, pseudo []string) string {
+	settings := make([]string, len(init.settings))
+	for i, s := range init.settings {
+		settings[i] = fmt.Sprintf("%d:%d", s.ID, s.Val)
+	}
+	priorities := []string{"0"}
+	if len(init.priorities) > 0 {
+		priorities = priorities[:0]
+		for _, p := range init.priorities {
+			excl := 0
+			if p.Exclusive {
+				excl = 1
+			}
+			// 帧中的权重比实际权重小 1
+			priorities = append(priorities, fmt.Sprintf("%d:%d:%d:%d", p.StreamID, excl, p.StreamDep, int(p.Weight)+1))
+		}
+	}
+	if len(pseudo) == 0 {
+		pseudo = []string{":authority", ":method", ":path", ":scheme"}
+	}
+	order := make([]string, len(pseudo))
+	for i, h := range pseudo {
+		order[i] = akamaiPseudoHeaders[h]
+	}
+	return strings.Join([]string{
+		strings.Join(settings, ";"),
+		strconv.Itoa(init.connFlow),
+		strings.Join(priorities, ","),
+		strings.Join(order, ","),
+	}, "|")
+}
+
+// SetHTTP2Fingerprint 按 Akamai 格式的 HTTP/2 指纹 (tls.peet.ws 的 akamai_fingerprint，
+// 如 "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p") 设置 t.HTTP2Settings，
+// 使新连接的 SETTINGS、WINDOW_UPDATE、PRIORITY 帧和请求的伪头部顺序与之一致
+//
+// 已有的 HTTP2Settings.HeaderPriority 保留，其余字段被替换。不支持不发送
+// WINDOW_UPDATE (第二部分为 0) 的指纹。
+func (t *Transport) SetHTTP2Fingerprint(akamai string) error {
+	parts := strings.Split(akamai, "|")
+	if len(parts) != 4 {
+		return fmt.Errorf("无效的 HTTP/2 指纹，应为 | 分隔的 4 个部分，实际为 %d 个", len(parts))
+	}
+	s := &HTTP2Settings{}
+	if t.HTTP2Settings != nil {
+		s.HeaderPriority = t.HTTP2Settings.HeaderPriority
+	}
+	for _, kv := range strings.Split(parts[0], ";") {
+		id, val, ok := strings.Cut(kv, ":")
+		i, err1 := strconv.ParseUint(id, 10, 16)
+		v, err2 := strconv.ParseUint(val, 10, 32)
+		if !ok || err1 != nil || err2 != nil {
+			return fmt.Errorf("无效的 HTTP/2 SETTINGS: %q", kv)
+		}
+		s.Settings = append(s.Settings, HTTP2Setting{ID: HTTP2SettingID(i), Val: uint32(v)})
+	}
+	flow, err := strconv.ParseUint(parts[1], 10, 31)
+	if err != nil {
+		return fmt.Errorf("无效的 HTTP/2 WINDOW_UPDATE: %q", parts[1])
+	}
+	if flow == 0 {
+		return fmt.Errorf("不支持不发送 WINDOW_UPDATE 的 HTTP/2 指纹")
+	}
+	s.ConnectionFlow = int(flow)
+	if parts[2] != "0" {
+		for _, pf := range strings.Split(parts[2], ",") {
+			f := strings.Split(pf, ":")
+			var v [4]uint64
+			for i := range f {
+				if i < len(v) {
+					v[i], err = strconv.ParseUint(f[i], 10, 31)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if len(f) != 4 || err != nil || v[0] == 0 || v[1] > 1 || v[3] < 1 || v[3] > 256 {
+				return fmt.Errorf("无效的 HTTP/2 PRIORITY: %q", pf)
+			}
+			frame := HTTP2PriorityFrame{HTTP2PriorityParam: HTTP2PriorityParam{
+				StreamDep: uint32(v[2]),
+				Exclusive: v[1] == 1,
+				Weight:    uint8(v[3] - 1),
+			}}
+			frame.StreamID = uint32(v[0])
+			s.PriorityFrames = append(s.PriorityFrames, frame)
+		}
+	}
+	for _, a := range strings.Split(parts[3], ",") {
+		var name string
+		for h, abbr := range akamaiPseudoHeaders {
+			if abbr == a {
+				name = h
+			}
+		}
+		if name == "" || slices.Contains(s.PseudoHeaderOrder, name) {
+			return fmt.Errorf("无效的 HTTP/2 伪头部顺序: %q", parts[3])
+		}
+		s.PseudoHeaderOrder = append(s.PseudoHeaderOrder, name)
+	}
+	t.HTTP2Settings = s
+	return nil
+}
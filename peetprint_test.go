@@ -0,0 +1,193 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// chromeAkamai 是 Chrome 在 tls.peet.ws 上的 akamai_fingerprint
+const chromeAkamai = "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+
+// TestPeetPrint 测试 PeetPrint 计算的 peetprint 和默认的 HTTP/2 指纹
+func TestPeetPrint(t *testing.T) {
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281-21,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	p, err := tr.PeetPrint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(p.TLS, "|")
+	if len(parts) != len(peetPrintSections) {
+		t.Fatalf("peetprint %q 有 %d 个部分, want %d", p.TLS, len(parts), len(peetPrintSections))
+	}
+	for i, want := range map[int]string{
+		1: "2-1.1",
+		2: "29-23-24",
+		6: "4865-4866-4867-49195-49199",
+		// 按字符串排序，不含 padding
+		7: "0-10-11-13-16-23-43-45-51-65281",
+	} {
+		if parts[i] != want {
+			t.Errorf("peetprint %s got %q, want %q", peetPrintSections[i], parts[i], want)
+		}
+	}
+	if p.TLSHash != ja3Hash(p.TLS) {
+		t.Errorf("TLSHash got %s, want %s", p.TLSHash, ja3Hash(p.TLS))
+	}
+	if want := "2:0;4:4194304;6:10485760|1073741824|0|a,m,p,s"; p.HTTP2 != want {
+		t.Errorf("HTTP2 got %q, want %q", p.HTTP2, want)
+	}
+	if d := p.Diff(p); len(d) != 0 {
+		t.Errorf("Diff 自身 got %q, want 空", d)
+	}
+
+	if p, err := (&Transport{JA3: tr.JA3, TLSClientConfig: tr.TLSClientConfig}).PeetPrint(); err != nil || p.HTTP2 != "" {
+		t.Errorf("未启用 HTTP/2 时 got %q, %v, want 空", p.HTTP2, err)
+	}
+
+	if _, err := (&Transport{}).PeetPrint(); err != errNoFingerprint {
+		t.Errorf("未设置指纹时 got %v, want errNoFingerprint", err)
+	}
+}
+
+// TestSetHTTP2Fingerprint 测试按 Akamai 指纹设置的 HTTP/2 帧与 PeetPrint 和实际发送的一致
+func TestSetHTTP2Fingerprint(t *testing.T) {
+	ts := newH2FrameServer(t)
+	headerPriority := &HTTP2PriorityParam{Exclusive: true, Weight: 255}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		HTTP2Settings:     &HTTP2Settings{HeaderPriority: headerPriority},
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	if err := tr.SetHTTP2Fingerprint(chromeAkamai); err != nil {
+		t.Fatal(err)
+	}
+	if tr.HTTP2Settings.HeaderPriority != headerPriority {
+		t.Error("SetHTTP2Fingerprint 没有保留 HeaderPriority")
+	}
+	p, err := tr.PeetPrint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := p.Diff(PeetPrint{HTTP2: chromeAkamai}); len(d) != 0 {
+		t.Errorf("Diff got %q, want 空", d)
+	}
+	if d := p.Diff(PeetPrint{HTTP2Hash: ja3Hash(chromeAkamai)}); len(d) != 0 {
+		t.Errorf("按哈希 Diff got %q, want 空", d)
+	}
+
+	req, _ := NewRequest("GET", ts.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tr.CloseIdleConnections()
+
+	// 由服务端收到的帧重建 Akamai 指纹
+	var settings, order []string
+	var flow uint32
+	ts.mu.Lock()
+	for _, f := range ts.frames {
+		switch f := f.(type) {
+		case *http2SettingsFrame:
+			f.ForeachSetting(func(s HTTP2Setting) error {
+				settings = append(settings, fmt.Sprintf("%d:%d", s.ID, s.Val))
+				return nil
+			})
+		case *http2WindowUpdateFrame:
+			if f.StreamID == 0 && flow == 0 {
+				flow = f.Increment
+			}
+		case *http2MetaHeadersFrame:
+			for _, h := range f.PseudoFields() {
+				order = append(order, akamaiPseudoHeaders[h.Name])
+			}
+		}
+	}
+	ts.mu.Unlock()
+	got := fmt.Sprintf("%s|%d|0|%s", strings.Join(settings, ";"), flow, strings.Join(order, ","))
+	if got != chromeAkamai {
+		t.Errorf("服务端收到的指纹 got %q, want %q", got, chromeAkamai)
+	}
+}
+
+// TestSetHTTP2FingerprintPriority 测试 PRIORITY 帧部分的解析和输出
+func TestSetHTTP2FingerprintPriority(t *testing.T) {
+	const fp = "1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s"
+	tr := &Transport{}
+	if err := tr.SetHTTP2Fingerprint(fp); err != nil {
+		t.Fatal(err)
+	}
+	want := HTTP2PriorityFrame{HTTP2PriorityParam: HTTP2PriorityParam{StreamDep: 7, Weight: 0}}
+	want.StreamID = 9
+	if got := tr.HTTP2Settings.PriorityFrames[3]; got != want {
+		t.Errorf("PriorityFrames[3] got %+v, want %+v", got, want)
+	}
+	t2 := &HTTP2Transport{t1: tr}
+	init, err := t2.initialFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := akamaiFingerprint(init, tr.HTTP2Settings.PseudoHeaderOrder); got != fp {
+		t.Errorf("got %q, want %q", got, fp)
+	}
+}
+
+func TestSetHTTP2FingerprintErrors(t *testing.T) {
+	for _, fp := range []string{
+		"",
+		"1:65536|15663105|0",
+		"1:65536;2|15663105|0|m,a,s,p",
+		"1:65536|0|0|m,a,s,p",
+		"1:65536|x|0|m,a,s,p",
+		"1:65536|15663105|3:0:0|m,a,s,p",
+		"1:65536|15663105|3:2:0:201|m,a,s,p",
+		"1:65536|15663105|3:0:0:257|m,a,s,p",
+		"1:65536|15663105|0|m,a,s,x",
+		"1:65536|15663105|0|m,a,m,p",
+	} {
+		tr := &Transport{}
+		if err := tr.SetHTTP2Fingerprint(fp); err == nil {
+			t.Errorf("%q: 期望错误", fp)
+		}
+		if tr.HTTP2Settings != nil {
+			t.Errorf("%q: 出错时修改了 HTTP2Settings", fp)
+		}
+	}
+}
+
+// TestPeetPrintDiff 测试 Diff 逐部分报告不一致
+func TestPeetPrintDiff(t *testing.T) {
+	got := PeetPrint{
+		TLS:   "772-771|2-1.1|29-23-24|1027|1|2|4865-4866|0-10",
+		HTTP2: chromeAkamai,
+	}
+	want := PeetPrint{
+		TLS:   "772-771|2-1.1|29-23-24|1027|1|2|4865-4867|0-10-11",
+		HTTP2: "1:65536|15663105|0|m,a,s,p",
+	}
+	d := got.Diff(want)
+	wantDiffs := []string{
+		`peetprint ciphers: got "4865-4866", want "4865-4867"`,
+		`peetprint extensions: got "0-10", want "0-10-11"`,
+		`akamai_fingerprint settings: got "1:65536;2:0;4:6291456;6:262144", want "1:65536"`,
+	}
+	if strings.Join(d, "\n") != strings.Join(wantDiffs, "\n") {
+		t.Errorf("got %q, want %q", d, wantDiffs)
+	}
+	if d := got.Diff(PeetPrint{TLSHash: "x"}); len(d) != 1 || !strings.HasPrefix(d[0], "peetprint hash:") {
+		t.Errorf("按哈希 Diff got %q", d)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+// SetMaxIdleConns 在运行时修改 MaxIdleConns，立即生效
+//
+// 调小时关闭超出上限的最久未使用的空闲连接。Transport 使用中不能直接
+// 修改字段，否则会与连接池产生数据竞争。HTTP/2 连接没有单独设置上限时，
+// 按同样的上限单独计数。
+func (t *Transport) SetMaxIdleConns(n int) {
+	t.idleMu.Lock()
+	t.MaxIdleConns = n
+	for n > 0 && t.idleLRU.len() > n {
+		oldest := t.idleLRU.removeOldest()
+		oldest.close(errTooManyIdle)
+		t.removeIdleConnLocked(oldest)
+	}
+	t.idleMu.Unlock()
+	t.trimHTTP2IdleConns()
+}
+
+// SetMaxIdleConnsPerHost 在运行时修改 MaxIdleConnsPerHost，立即生效
+//
+// 调小时关闭每个地址超出上限的最早放回的 HTTP/1 空闲连接，小于零时关闭
+// 所有空闲连接。HTTP/2 连接没有单独设置上限时同样生效，关闭的是最久
+// 未使用的没有活动流的连接。
+func (t *Transport) SetMaxIdleConnsPerHost(n int) {
+	t.idleMu.Lock()
+	t.MaxIdleConnsPerHost = n
+	limit := max(t.maxIdleConnsPerHost(), 0)
+	for key := range t.idleConn {
+		t.trimIdleConnsLocked(key, len(t.idleConn[key])-limit, errTooManyIdleHost)
+	}
+	t.idleMu.Unlock()
+	t.trimHTTP2IdleConns()
+}
+
+// SetMaxConnsPerHost 在运行时修改 MaxConnsPerHost，立即生效
+//
+// 调大或取消限制时，排队等待拨号的请求立即开始拨号。调小时关闭超出上限的
+// HTTP/1 空闲连接；使用中的连接不会被中断，在连接数降到上限以下之前，
+// 新的拨号继续排队。
+func (t *Transport) SetMaxConnsPerHost(n int) {
+	t.connsPerHostMu.Lock()
+	t.MaxConnsPerHost = n
+	for key, q := range t.connsPerHostWait {
+		for q.len() > 0 && (n <= 0 || t.connsPerHost[key] < n) {
+			if w := q.popFront(); w.waiting() {
+				t.connsPerHost[key]++
+				t.startDialConnForLocked(w)
+			}
+		}
+		if q.len() == 0 {
+			delete(t.connsPerHostWait, key)
+		} else {
+			t.connsPerHostWait[key] = q
+		}
+	}
+	excess := make(map[connectMethodKey]int)
+	for key, count := range t.connsPerHost {
+		if n > 0 && count > n {
+			excess[key] = count - n
+		}
+	}
+	t.connsPerHostMu.Unlock()
+
+	// 关闭连接会回到 decConnsPerHost，因此释放 connsPerHostMu 后再关闭
+	t.idleMu.Lock()
+	defer t.idleMu.Unlock()
+	for key, k := range excess {
+		t.trimIdleConnsLocked(key, k, errTooManyIdleHost)
+	}
+}
+
+// trimIdleConnsLocked 关闭 key 最早放回的至多 n 个 HTTP/1 空闲连接
+// t.idleMu must be held.
+func (t *Transport) trimIdleConnsLocked(key connectMethodKey, n int, err error) {
+	var victims []*persistConn
+	for _, pconn := range t.idleConn[key] {
+		if len(victims) == n {
+			break
+		}
+		if pconn.alt == nil {
+			victims = append(victims, pconn)
+		}
+	}
+	for _, pconn := range victims {
+		pconn.close(err)
+		t.removeIdleConnLocked(pconn)
+	}
+}
+
+// trimHTTP2IdleConns 按当前的上限关闭 HTTP/2 的空闲连接
+func (t *Transport) trimHTTP2IdleConns() {
+	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
+	if t2, ok := t.H2Transport.(*HTTP2Transport); ok {
+		if p := t2.clientConnPool(); p != nil {
+			p.trimIdle(nil)
+		}
+	}
+}
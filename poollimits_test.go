@@ -0,0 +1,267 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// idleConnCount 返回 t 中的空闲连接数
+func idleConnCount(t *Transport) int {
+	t.idleMu.Lock()
+	defer t.idleMu.Unlock()
+	return t.idleLRU.len()
+}
+
+// TestSetPoolLimitsShrink 测试运行时调小连接池上限时关闭多余的空闲连接
+func TestSetPoolLimitsShrink(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*Transport)
+		want int
+	}{
+		{"SetMaxIdleConnsPerHost", func(tr *Transport) { tr.SetMaxIdleConnsPerHost(1) }, 1},
+		{"SetMaxIdleConnsPerHost 负数", func(tr *Transport) { tr.SetMaxIdleConnsPerHost(-1) }, 0},
+		{"SetMaxIdleConns", func(tr *Transport) { tr.SetMaxIdleConns(2) }, 2},
+		{"SetMaxConnsPerHost", func(tr *Transport) { tr.SetMaxConnsPerHost(1) }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(3)
+			srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				wg.Done()
+				wg.Wait()
+			}))
+			defer srv.Close()
+			tr := &Transport{MaxIdleConnsPerHost: 3}
+			defer tr.CloseIdleConnections()
+
+			var reqs sync.WaitGroup
+			for range 3 {
+				reqs.Add(1)
+				go func() {
+					defer reqs.Done()
+					resp, err := (&Client{Transport: tr}).Get(srv.URL)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					io.ReadAll(resp.Body)
+					resp.Body.Close()
+				}()
+			}
+			reqs.Wait()
+			if n := idleConnCount(tr); n != 3 {
+				t.Fatalf("空闲连接数 got %v, want 3", n)
+			}
+			tt.set(tr)
+			if n := idleConnCount(tr); n != tt.want {
+				t.Errorf("空闲连接数 got %v, want %v", n, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetMaxConnsPerHostRaise 测试调大 MaxConnsPerHost 后排队的请求立即开始拨号
+func TestSetMaxConnsPerHostRaise(t *testing.T) {
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+	tr := &Transport{MaxConnsPerHost: 1}
+	defer tr.CloseIdleConnections()
+
+	var reqs sync.WaitGroup
+	for range 2 {
+		reqs.Add(1)
+		go func() {
+			defer reqs.Done()
+			resp, err := (&Client{Transport: tr}).Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	defer reqs.Wait()
+	defer close(release)
+
+	<-arrived
+	select {
+	case <-arrived:
+		t.Fatal("MaxConnsPerHost 为 1 时第二个请求不应到达服务端")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tr.SetMaxConnsPerHost(2)
+	select {
+	case <-arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("调大 MaxConnsPerHost 后第二个请求没有开始拨号")
+	}
+}
+
+// h2ConnCount 返回 t 的 HTTP/2 连接池中未关闭的连接数
+func h2ConnCount(t *Transport) int {
+	p := t.H2Transport.(*HTTP2Transport).clientConnPool()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for cc := range p.keys {
+		cc.mu.Lock()
+		if !cc.closed {
+			n++
+		}
+		cc.mu.Unlock()
+	}
+	return n
+}
+
+// newH2PoolTest 返回一个 HTTP/2 服务端和把所有主机都拨到该服务端的 Transport
+func newH2PoolTest(t *testing.T) *Transport {
+	srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+	t.Cleanup(tr.CloseIdleConnections)
+	return tr
+}
+
+// getHosts 依次请求 hosts，每个主机建立一个 HTTP/2 连接
+func getHosts(t *testing.T, tr *Transport, hosts ...string) {
+	t.Helper()
+	for _, host := range hosts {
+		resp, err := (&Client{Transport: tr}).Get("https://" + host + "/")
+		if err != nil {
+			t.Fatalf("请求 %s 失败: %v", host, err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("请求 %s 使用了 %s", host, resp.Proto)
+		}
+	}
+}
+
+// waitH2ConnCount 等待 HTTP/2 连接数变为 want，连接在流结束后异步回到空闲状态
+func waitH2ConnCount(t *testing.T, tr *Transport, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := h2ConnCount(tr)
+		if n == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("HTTP/2 连接数 got %v, want %v", n, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestHTTP2IdleConnLimits 测试 HTTP/2 空闲连接遵守与 HTTP/1 相同的上限
+func TestHTTP2IdleConnLimits(t *testing.T) {
+	t.Run("MaxIdleConns", func(t *testing.T) {
+		tr := newH2PoolTest(t)
+		tr.MaxIdleConns = 2
+		getHosts(t, tr, "a.test", "b.test", "c.test")
+		waitH2ConnCount(t, tr, 2)
+		// 关闭的是最久未使用的 a.test
+		p := tr.H2Transport.(*HTTP2Transport).clientConnPool()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, cc := range p.conns["a.test:443"] {
+			if _, idle := cc.idleSince(); idle {
+				t.Error("a.test 的连接没有被关闭")
+			}
+		}
+	})
+	t.Run("默认不限制", func(t *testing.T) {
+		// 服务端每个连接只允许一个流，三个并发请求建立三个连接
+		var arrived sync.WaitGroup
+		arrived.Add(3)
+		srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			arrived.Done()
+			arrived.Wait()
+		}))
+		srv.EnableHTTP2 = true
+		srv.Config.HTTP2 = &nethttp.HTTP2Config{MaxConcurrentStreams: 1}
+		srv.StartTLS()
+		defer srv.Close()
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}
+		defer tr.CloseIdleConnections()
+		var reqs sync.WaitGroup
+		for range 3 {
+			reqs.Add(1)
+			go func() {
+				defer reqs.Done()
+				resp, err := (&Client{Transport: tr}).Get(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+				if resp.ProtoMajor != 2 {
+					t.Errorf("请求使用了 %s", resp.Proto)
+				}
+			}()
+		}
+		reqs.Wait()
+		time.Sleep(50 * time.Millisecond)
+		if n := h2ConnCount(tr); n != 3 {
+			t.Errorf("HTTP/2 连接数 got %v, want 3", n)
+		}
+	})
+	t.Run("SetMaxIdleConnsPerHost", func(t *testing.T) {
+		tr := newH2PoolTest(t)
+		getHosts(t, tr, "a.test", "b.test")
+		waitH2ConnCount(t, tr, 2)
+		tr.SetMaxIdleConnsPerHost(-1)
+		waitH2ConnCount(t, tr, 0)
+	})
+	t.Run("HTTP2Transport.MaxIdleConns", func(t *testing.T) {
+		tr := newH2PoolTest(t)
+		tr.MaxIdleConns = 1
+		t2, err := HTTP2ConfigureTransports(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t2.MaxIdleConns = 3
+		getHosts(t, tr, "a.test", "b.test", "c.test")
+		waitH2ConnCount(t, tr, 3)
+	})
+	t.Run("CloseIdleConnectionsFor", func(t *testing.T) {
+		tr := newH2PoolTest(t)
+		getHosts(t, tr, "a.test", "b.test")
+		waitH2ConnCount(t, tr, 2)
+		tr.H2Transport.(*HTTP2Transport).CloseIdleConnectionsFor("a.test:443")
+		waitH2ConnCount(t, tr, 1)
+	})
+}
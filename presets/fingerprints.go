@@ -12,6 +12,8 @@
 package presets
 
 import (
+	"context"
+
 	http "github.com/vanling1111/tlshttp"
 )
 
@@ -21,6 +23,15 @@ type BrowserFingerprint struct {
 	JA3       string              // JA3 指纹字符串
 	UserAgent string              // User-Agent 字符串
 	HTTP2     *http.HTTP2Settings // HTTP/2 设置
+	Headers   http.Header         // 默认请求头，http.HeaderOrderKey 决定发送顺序，由 SetHeaders 写入请求
+	Behavior  Behavior            // Transport 行为开关
+}
+
+// Behavior 是随指纹一起分发的 Transport 行为开关
+type Behavior struct {
+	RandomJA3         bool // 对应 Transport.RandomJA3
+	ForceHTTP1        bool // 对应 Transport.ForceHTTP1
+	PermuteExtensions bool // 对应 Transport.PermuteExtensions
 }
 
 // ===== Chrome 浏览器指纹 =====
@@ -70,7 +81,7 @@ var Chrome117Windows = BrowserFingerprint{
 // Chrome133Windows 是 Chrome 133 (Windows 10) 的指纹配置
 var Chrome133Windows = BrowserFingerprint{
 	Name:      "Chrome 133 (Windows 10)",
-	JA3:       "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-21,29-23-24,0",
+	JA3:       "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-21,4588-29-23-24,0",
 	UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
 	HTTP2: &http.HTTP2Settings{
 		Settings: []http.HTTP2Setting{
@@ -186,6 +197,9 @@ func (bf *BrowserFingerprint) ApplyToTransport(transport *http.Transport) {
 
 	transport.JA3 = bf.JA3
 	transport.UserAgent = bf.UserAgent
+	transport.RandomJA3 = bf.Behavior.RandomJA3
+	transport.ForceHTTP1 = bf.Behavior.ForceHTTP1
+	transport.PermuteExtensions = bf.Behavior.PermuteExtensions
 
 	if bf.HTTP2 != nil {
 		// 深度克隆 HTTP2Settings
@@ -198,18 +212,29 @@ func (bf *BrowserFingerprint) ApplyToTransport(transport *http.Transport) {
 
 // NewTransport 创建一个使用指定浏览器指纹的 Transport
 func (bf *BrowserFingerprint) NewTransport() *http.Transport {
-	transport := &http.Transport{
-		JA3:       bf.JA3,
-		UserAgent: bf.UserAgent,
-	}
+	transport := &http.Transport{}
+	bf.ApplyToTransport(transport)
+	return transport
+}
 
-	if bf.HTTP2 != nil {
-		// 深度克隆 HTTP2Settings
-		clonedHTTP2, err := bf.HTTP2.Clone()
-		if err == nil {
-			transport.HTTP2Settings = clonedHTTP2
+// WithContext 返回带有该指纹的 ctx 副本，使用它的请求以该指纹建立 TLS 连接，
+// 无需修改共享的 Transport，见 http.WithFingerprint。HTTP2 设置不随请求生效
+func (bf *BrowserFingerprint) WithContext(ctx context.Context) context.Context {
+	return http.WithFingerprint(ctx, http.RequestFingerprint{
+		JA3:        bf.JA3,
+		UserAgent:  bf.UserAgent,
+		ForceHTTP1: bf.Behavior.ForceHTTP1,
+	})
+}
+
+// SetHeaders 将指纹的默认请求头写入 req，req 中已有的头部保持不变
+func (bf *BrowserFingerprint) SetHeaders(req *http.Request) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	for k, vv := range bf.Headers {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = append([]string(nil), vv...)
 		}
 	}
-
-	return transport
 }
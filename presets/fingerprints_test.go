@@ -5,6 +5,7 @@
 package presets
 
 import (
+	"context"
 	"testing"
 
 	http "github.com/vanling1111/tlshttp"
@@ -90,6 +91,17 @@ func TestBrowserFingerprintApplyToTransport(t *testing.T) {
 	}
 }
 
+// TestBrowserFingerprintWithContext 测试 WithContext 方法
+func TestBrowserFingerprintWithContext(t *testing.T) {
+	fp, ok := http.FingerprintFromContext(Firefox120Windows.WithContext(context.Background()))
+	if !ok {
+		t.Fatal("context 中没有指纹")
+	}
+	if fp.JA3 != Firefox120Windows.JA3 || fp.UserAgent != Firefox120Windows.UserAgent {
+		t.Errorf("got %+v, want JA3 %v, UserAgent %v", fp, Firefox120Windows.JA3, Firefox120Windows.UserAgent)
+	}
+}
+
 // TestBrowserFingerprintHTTP2Settings 测试 HTTP/2 设置
 func TestBrowserFingerprintHTTP2Settings(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,283 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package presets
+
+import (
+	"bytes"
+	"context"
+	ctls "crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	http "github.com/vanling1111/tlshttp"
+	"github.com/vanling1111/tlshttp/internal/testcert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// 用法：确认指纹改动符合预期后，运行
+//
+//	go test ./presets -run TestPresetGolden -update
+//
+// 重新生成 testdata/*.golden，并在代码评审中检查其 diff。
+var update = flag.Bool("update", false, "重新生成 testdata 中的 golden 文件")
+
+// TestPresetGolden 将每个预设的 ClientHello、HTTP/2 SETTINGS 等帧和默认请求头
+// 序列化后与 golden 文件比较，用于发现依赖升级等带来的意外指纹变化
+func TestPresetGolden(t *testing.T) {
+	names := make([]string, 0, len(AllPresets))
+	for name := range AllPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			got := snapshotPreset(t, AllPresets[name])
+			path := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败: %v（使用 -update 生成）", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s 与当前指纹不一致，确认改动符合预期后使用 -update 重新生成\n%s", path, firstDiff(want, got))
+			}
+		})
+	}
+}
+
+// snapshotPreset 返回预设的确定性快照
+func snapshotPreset(t *testing.T, fp *BrowserFingerprint) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n", fp.Name)
+
+	// ClientHello 使用不联网构建的 spec，GREASE 为占位符，不含随机数和密钥
+	spec, err := fp.NewTransport().BuildSpec("example.com")
+	if err != nil {
+		t.Fatalf("BuildSpec() 失败: %v", err)
+	}
+	writeClientHello(&b, spec)
+
+	// HTTP/2 帧和请求头从实际连接中捕获
+	writeH2Capture(&b, captureH2(t, fp))
+	return b.Bytes()
+}
+
+func writeClientHello(b *bytes.Buffer, spec *tls.ClientHelloSpec) {
+	b.WriteString("\n[client_hello]\n")
+	fmt.Fprintf(b, "versions: %04x-%04x\n", spec.TLSVersMin, spec.TLSVersMax)
+	ciphers := make([]string, len(spec.CipherSuites))
+	for i, c := range spec.CipherSuites {
+		ciphers[i] = fmt.Sprintf("%04x", c)
+	}
+	fmt.Fprintf(b, "ciphers: %s\n", strings.Join(ciphers, ","))
+	fmt.Fprintf(b, "compression: %x\n", spec.CompressionMethods)
+	b.WriteString("extensions:\n")
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.UtlsGREASEExtension:
+			b.WriteString("  grease\n")
+		case *tls.SNIExtension:
+			fmt.Fprintf(b, "  0000 server_name %s\n", e.ServerName)
+		case *tls.UtlsPaddingExtension:
+			b.WriteString("  0015 padding\n")
+		case *tls.GREASEEncryptedClientHelloExtension:
+			// 内容在构建时随机生成
+			b.WriteString("  fe0d ech_grease\n")
+		default:
+			n := e.Len()
+			if n < 4 {
+				fmt.Fprintf(b, "  %T\n", e)
+				continue
+			}
+			buf := make([]byte, n)
+			e.Read(buf)
+			fmt.Fprintf(b, "  %x %x\n", buf[:2], buf[4:])
+		}
+	}
+}
+
+// h2Capture 是服务端收到的 HTTP/2 连接前言之后的帧
+type h2Capture struct {
+	alpn     string
+	frames   []string // 请求头之前的帧，按收到的顺序
+	priority string   // HEADERS 帧携带的优先级
+	headers  []hpack.HeaderField
+}
+
+func writeH2Capture(b *bytes.Buffer, c *h2Capture) {
+	b.WriteString("\n[http2]\n")
+	fmt.Fprintf(b, "alpn: %s\n", c.alpn)
+	for _, f := range c.frames {
+		fmt.Fprintf(b, "%s\n", f)
+	}
+	fmt.Fprintf(b, "headers_priority: %s\n", c.priority)
+	b.WriteString("\n[headers]\n")
+	for _, h := range c.headers {
+		fmt.Fprintf(b, "%s: %s\n", h.Name, h.Value)
+	}
+}
+
+// captureH2 使用预设向本地 HTTP/2 服务端发送 GET https://example.com/，
+// 返回服务端收到的帧和请求头
+func captureH2(t *testing.T, fp *BrowserFingerprint) *h2Capture {
+	cert, err := ctls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := ctls.Listen("tcp", "127.0.0.1:0", &ctls.Config{
+		Certificates: []ctls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+		// 只使用经典组，避免服务端对后量子组发起 HelloRetryRequest
+		CurvePreferences: []ctls.CurveID{ctls.X25519, ctls.CurveP256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	result := make(chan *h2Capture, 1)
+	errc := make(chan error, 1)
+	go func() {
+		c, err := serveH2Once(ln)
+		if err != nil {
+			errc <- err
+			return
+		}
+		result <- c
+	}()
+
+	tr := fp.NewTransport()
+	// 自定义 TLSClientConfig 和 DialContext 时需要显式启用 HTTP/2
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	tr.ForceAttemptHTTP2 = true
+	tr.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ln.Addr().String())
+	}
+	defer tr.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/", nil)
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		select {
+		case serr := <-errc:
+			t.Fatalf("请求失败: %v（服务端: %v）", err, serr)
+		default:
+			t.Fatalf("请求失败: %v", err)
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	select {
+	case c := <-result:
+		return c
+	case err := <-errc:
+		t.Fatalf("服务端失败: %v", err)
+	}
+	return nil
+}
+
+// serveH2Once 接受一个连接，记录请求之前的帧和请求头，并返回 200
+func serveH2Once(ln net.Listener) (*h2Capture, error) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tc := conn.(*ctls.Conn)
+	if err := tc.Handshake(); err != nil {
+		return nil, err
+	}
+	c := &h2Capture{alpn: tc.ConnectionState().NegotiatedProtocol}
+	if c.alpn != "h2" {
+		return nil, fmt.Errorf("协商的 ALPN 为 %q", c.alpn)
+	}
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return nil, err
+	}
+
+	fr := http2.NewFramer(conn, conn)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			var settings []string
+			f.ForeachSetting(func(s http2.Setting) error {
+				settings = append(settings, fmt.Sprintf("%v=%d", s.ID, s.Val))
+				return nil
+			})
+			c.frames = append(c.frames, "SETTINGS "+strings.Join(settings, ","))
+			fr.WriteSettings()
+			fr.WriteSettingsAck()
+		case *http2.WindowUpdateFrame:
+			c.frames = append(c.frames, fmt.Sprintf("WINDOW_UPDATE stream=%d increment=%d", f.StreamID, f.Increment))
+		case *http2.PriorityFrame:
+			c.frames = append(c.frames, fmt.Sprintf("PRIORITY stream=%d dep=%d weight=%d exclusive=%v",
+				f.StreamID, f.StreamDep, f.Weight, f.Exclusive))
+		case *http2.MetaHeadersFrame:
+			p := f.Priority
+			c.priority = fmt.Sprintf("dep=%d weight=%d exclusive=%v", p.StreamDep, p.Weight, p.Exclusive)
+			c.headers = f.Fields
+			var hbuf bytes.Buffer
+			enc := hpack.NewEncoder(&hbuf)
+			enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+			err := fr.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      f.StreamID,
+				BlockFragment: hbuf.Bytes(),
+				EndStream:     true,
+				EndHeaders:    true,
+			})
+			return c, err
+		}
+	}
+}
+
+// firstDiff 返回 want 与 got 第一处不同的行
+func firstDiff(want, got []byte) string {
+	wl := strings.Split(string(want), "\n")
+	gl := strings.Split(string(got), "\n")
+	for i := 0; i < max(len(wl), len(gl)); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("第 %d 行:\n- %s\n+ %s", i+1, w, g)
+		}
+	}
+	return ""
+}
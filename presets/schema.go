@@ -0,0 +1,286 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package presets
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	http "github.com/vanling1111/tlshttp"
+)
+
+// SchemaVersion 是 MarshalFingerprint 输出的指纹 JSON 格式版本
+//
+// 格式发生不兼容的变化时递增，并在 migrations 中添加从上一版本迁移的函数，
+// 使外部工具生成的旧版本文档仍能被 ParseFingerprint 读取。
+//
+// 版本历史：
+//
+//   - 0：没有 schema_version 字段，即直接 json.Marshal(BrowserFingerprint)
+//     得到的格式，字段名为 Go 字段名 (Name、JA3、UserAgent、HTTP2)
+//   - 1：分为 tls、http2、headers、behavior 四部分，见 Document
+const SchemaVersion = 1
+
+// Document 是 BrowserFingerprint 的稳定 JSON 格式 (当前版本)
+//
+// 外部工具和远程指纹库应按此格式生成文档，示例：
+//
+//	{
+//	  "schema_version": 1,
+//	  "name": "Chrome 120 (Windows 10)",
+//	  "tls": {"ja3": "771,4865-4866-...,0-23-65281-...,29-23-24,0"},
+//	  "http2": {
+//	    "settings": [{"id": 1, "value": 65536}, {"id": 2, "value": 0}],
+//	    "connection_flow": 15663105,
+//	    "header_priority": {"stream_dep": 0, "exclusive": true, "weight": 255}
+//	  },
+//	  "headers": {
+//	    "user_agent": "Mozilla/5.0 ...",
+//	    "fields": [{"name": "accept-language", "value": "en-US,en;q=0.9"}]
+//	  },
+//	  "behavior": {"random_ja3": false, "force_http1": false}
+//	}
+type Document struct {
+	SchemaVersion int              `json:"schema_version"`
+	Name          string           `json:"name"`
+	TLS           TLSDocument      `json:"tls"`
+	HTTP2         *HTTP2Document   `json:"http2,omitempty"`
+	Headers       HeadersDocument  `json:"headers"`
+	Behavior      BehaviorDocument `json:"behavior"`
+}
+
+// TLSDocument 是 Document 的 TLS 部分
+type TLSDocument struct {
+	JA3 string `json:"ja3"`
+}
+
+// HTTP2Document 是 Document 的 HTTP/2 部分，对应 http.HTTP2Settings
+type HTTP2Document struct {
+	Settings       []HTTP2SettingDocument  `json:"settings"`
+	ConnectionFlow int                     `json:"connection_flow"`
+	HeaderPriority *HTTP2PriorityDocument  `json:"header_priority,omitempty"`
+	PriorityFrames []HTTP2PriorityDocument `json:"priority_frames,omitempty"`
+}
+
+// HTTP2SettingDocument 是 SETTINGS 帧中的一项，按发送顺序排列
+type HTTP2SettingDocument struct {
+	ID    uint16 `json:"id"`
+	Value uint32 `json:"value"`
+}
+
+// HTTP2PriorityDocument 是优先级参数，weight 为帧中的原始值 (实际权重减 1)
+// stream_id 只用于 priority_frames
+type HTTP2PriorityDocument struct {
+	StreamID  uint32 `json:"stream_id,omitempty"`
+	StreamDep uint32 `json:"stream_dep"`
+	Exclusive bool   `json:"exclusive"`
+	Weight    uint8  `json:"weight"`
+}
+
+// HeadersDocument 是 Document 的请求头部分，fields 的顺序即发送顺序
+type HeadersDocument struct {
+	UserAgent string                `json:"user_agent"`
+	Fields    []HeaderFieldDocument `json:"fields,omitempty"`
+}
+
+// HeaderFieldDocument 是一个请求头，同名头部可以出现多次
+type HeaderFieldDocument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// BehaviorDocument 是 Document 的行为开关部分，对应 Behavior
+type BehaviorDocument struct {
+	RandomJA3         bool `json:"random_ja3"`
+	ForceHTTP1        bool `json:"force_http1"`
+	PermuteExtensions bool `json:"permute_extensions,omitempty"`
+}
+
+// migrations[i] 将版本 i 的文档迁移为版本 i+1
+var migrations = []func([]byte) ([]byte, error){
+	migrateV0,
+}
+
+// Migrate 将任意已知版本的指纹文档迁移为当前版本 (SchemaVersion)
+//
+// 文档已是当前版本时原样返回；版本高于 SchemaVersion 时返回错误，
+// 说明需要升级本库。
+func Migrate(data []byte) ([]byte, error) {
+	var header struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("解析指纹文档失败: %w", err)
+	}
+	version := 0
+	if header.SchemaVersion != nil {
+		version = *header.SchemaVersion
+	}
+	if version < 0 || version > SchemaVersion {
+		return nil, fmt.Errorf("不支持的指纹文档版本 %d，当前支持到 %d", version, SchemaVersion)
+	}
+	for ; version < SchemaVersion; version++ {
+		var err error
+		if data, err = migrations[version](data); err != nil {
+			return nil, fmt.Errorf("指纹文档从版本 %d 迁移失败: %w", version, err)
+		}
+	}
+	return data, nil
+}
+
+// migrateV0 将 json.Marshal(BrowserFingerprint) 的格式迁移为版本 1
+func migrateV0(data []byte) ([]byte, error) {
+	var v0 struct {
+		Name      string
+		JA3       string
+		UserAgent string
+		HTTP2     *http.HTTP2Settings
+	}
+	if err := json.Unmarshal(data, &v0); err != nil {
+		return nil, err
+	}
+	doc := &Document{
+		SchemaVersion: 1,
+		Name:          v0.Name,
+		TLS:           TLSDocument{JA3: v0.JA3},
+		HTTP2:         http2Document(v0.HTTP2),
+		Headers:       HeadersDocument{UserAgent: v0.UserAgent},
+	}
+	return json.Marshal(doc)
+}
+
+// ParseFingerprint 解析任意已知版本的指纹文档
+func ParseFingerprint(data []byte) (*BrowserFingerprint, error) {
+	data, err := Migrate(data)
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析指纹文档失败: %w", err)
+	}
+	if doc.TLS.JA3 == "" {
+		return nil, fmt.Errorf("指纹文档 %q 缺少 tls.ja3", doc.Name)
+	}
+	return doc.Fingerprint(), nil
+}
+
+// MarshalFingerprint 将 bf 序列化为当前版本的指纹文档
+func MarshalFingerprint(bf *BrowserFingerprint) ([]byte, error) {
+	return json.MarshalIndent(NewDocument(bf), "", "  ")
+}
+
+// NewDocument 返回 bf 对应的当前版本文档
+func NewDocument(bf *BrowserFingerprint) *Document {
+	return &Document{
+		SchemaVersion: SchemaVersion,
+		Name:          bf.Name,
+		TLS:           TLSDocument{JA3: bf.JA3},
+		HTTP2:         http2Document(bf.HTTP2),
+		Headers: HeadersDocument{
+			UserAgent: bf.UserAgent,
+			Fields:    headerFields(bf.Headers),
+		},
+		Behavior: BehaviorDocument{
+			RandomJA3:         bf.Behavior.RandomJA3,
+			ForceHTTP1:        bf.Behavior.ForceHTTP1,
+			PermuteExtensions: bf.Behavior.PermuteExtensions,
+		},
+	}
+}
+
+// Fingerprint 返回 doc 对应的 BrowserFingerprint
+func (doc *Document) Fingerprint() *BrowserFingerprint {
+	bf := &BrowserFingerprint{
+		Name:      doc.Name,
+		JA3:       doc.TLS.JA3,
+		UserAgent: doc.Headers.UserAgent,
+		Behavior: Behavior{
+			RandomJA3:         doc.Behavior.RandomJA3,
+			ForceHTTP1:        doc.Behavior.ForceHTTP1,
+			PermuteExtensions: doc.Behavior.PermuteExtensions,
+		},
+	}
+	if h := doc.HTTP2; h != nil {
+		bf.HTTP2 = &http.HTTP2Settings{ConnectionFlow: h.ConnectionFlow}
+		for _, s := range h.Settings {
+			bf.HTTP2.Settings = append(bf.HTTP2.Settings, http.HTTP2Setting{ID: http.HTTP2SettingID(s.ID), Val: s.Value})
+		}
+		if p := h.HeaderPriority; p != nil {
+			bf.HTTP2.HeaderPriority = &http.HTTP2PriorityParam{StreamDep: p.StreamDep, Exclusive: p.Exclusive, Weight: p.Weight}
+		}
+		for _, p := range h.PriorityFrames {
+			var f http.HTTP2PriorityFrame
+			f.StreamID = p.StreamID
+			f.HTTP2PriorityParam = http.HTTP2PriorityParam{StreamDep: p.StreamDep, Exclusive: p.Exclusive, Weight: p.Weight}
+			bf.HTTP2.PriorityFrames = append(bf.HTTP2.PriorityFrames, f)
+		}
+	}
+	if len(doc.Headers.Fields) > 0 {
+		bf.Headers = make(http.Header)
+		var order []string
+		for _, f := range doc.Headers.Fields {
+			name := strings.ToLower(f.Name)
+			if !slices.Contains(order, name) {
+				order = append(order, name)
+			}
+			bf.Headers.Add(f.Name, f.Value)
+		}
+		bf.Headers[http.HeaderOrderKey] = order
+	}
+	return bf
+}
+
+func http2Document(s *http.HTTP2Settings) *HTTP2Document {
+	if s == nil {
+		return nil
+	}
+	h := &HTTP2Document{ConnectionFlow: s.ConnectionFlow}
+	for _, setting := range s.Settings {
+		h.Settings = append(h.Settings, HTTP2SettingDocument{ID: uint16(setting.ID), Value: setting.Val})
+	}
+	if p := s.HeaderPriority; p != nil {
+		h.HeaderPriority = &HTTP2PriorityDocument{StreamDep: p.StreamDep, Exclusive: p.Exclusive, Weight: p.Weight}
+	}
+	for _, f := range s.PriorityFrames {
+		h.PriorityFrames = append(h.PriorityFrames, HTTP2PriorityDocument{
+			StreamID:  f.StreamID,
+			StreamDep: f.StreamDep,
+			Exclusive: f.Exclusive,
+			Weight:    f.Weight,
+		})
+	}
+	return h
+}
+
+// headerFields 按 http.HeaderOrderKey 的顺序展开 h，未列出的头部按名称排序放在最后
+func headerFields(h http.Header) []HeaderFieldDocument {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, name := range h[http.HeaderOrderKey] {
+		for k := range h {
+			if strings.EqualFold(k, name) && !seen[k] {
+				keys = append(keys, k)
+				seen[k] = true
+			}
+		}
+	}
+	var rest []string
+	for k := range h {
+		if !seen[k] && k != http.HeaderOrderKey && k != http.PHeaderOrderKey {
+			rest = append(rest, k)
+		}
+	}
+	slices.Sort(rest)
+	var fields []HeaderFieldDocument
+	for _, k := range append(keys, rest...) {
+		for _, v := range h[k] {
+			fields = append(fields, HeaderFieldDocument{Name: k, Value: v})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package presets
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	http "github.com/vanling1111/tlshttp"
+)
+
+// TestFingerprintSchemaRoundTrip 测试所有预设序列化后再解析保持不变
+func TestFingerprintSchemaRoundTrip(t *testing.T) {
+	for name, fp := range AllPresets {
+		t.Run(name, func(t *testing.T) {
+			data, err := MarshalFingerprint(fp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFingerprint(data)
+			if err != nil {
+				t.Fatalf("ParseFingerprint() 失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, fp) {
+				t.Errorf("got %+v, want %+v", got, fp)
+			}
+		})
+	}
+}
+
+// TestFingerprintSchemaHeaders 测试请求头按顺序序列化
+func TestFingerprintSchemaHeaders(t *testing.T) {
+	fp := &BrowserFingerprint{
+		Name: "test",
+		JA3:  Chrome120Windows.JA3,
+		Headers: http.Header{
+			"Accept":            {"*/*"},
+			"Accept-Language":   {"en-US"},
+			"Sec-Ch-Ua":         {"a", "b"},
+			http.HeaderOrderKey: {"sec-ch-ua", "accept-language"},
+		},
+		Behavior: Behavior{RandomJA3: true, PermuteExtensions: true},
+	}
+	doc := NewDocument(fp)
+	want := []HeaderFieldDocument{
+		{"Sec-Ch-Ua", "a"}, {"Sec-Ch-Ua", "b"}, {"Accept-Language", "en-US"}, {"Accept", "*/*"},
+	}
+	if !reflect.DeepEqual(doc.Headers.Fields, want) {
+		t.Errorf("got %v, want %v", doc.Headers.Fields, want)
+	}
+
+	got := doc.Fingerprint()
+	if order := got.Headers[http.HeaderOrderKey]; !reflect.DeepEqual(order, []string{"sec-ch-ua", "accept-language", "accept"}) {
+		t.Errorf("HeaderOrderKey got %v", order)
+	}
+	if !got.Behavior.RandomJA3 || !got.Behavior.PermuteExtensions {
+		t.Errorf("Behavior 未保留: %+v", got.Behavior)
+	}
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	req.Header.Set("Accept", "text/html")
+	got.SetHeaders(req)
+	if v := req.Header.Get("Accept"); v != "text/html" {
+		t.Errorf("SetHeaders 覆盖了已有的头部: %v", v)
+	}
+	if v := req.Header.Values("Sec-Ch-Ua"); len(v) != 2 {
+		t.Errorf("Sec-Ch-Ua got %v", v)
+	}
+}
+
+// TestFingerprintSchemaMigrate 测试旧版本文档的迁移
+func TestFingerprintSchemaMigrate(t *testing.T) {
+	// 版本 0：直接序列化的 BrowserFingerprint
+	v0, err := json.Marshal(struct {
+		Name      string
+		JA3       string
+		UserAgent string
+		HTTP2     *http.HTTP2Settings
+	}{Chrome120Windows.Name, Chrome120Windows.JA3, Chrome120Windows.UserAgent, Chrome120Windows.HTTP2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseFingerprint(v0)
+	if err != nil {
+		t.Fatalf("ParseFingerprint() 失败: %v", err)
+	}
+	if !reflect.DeepEqual(got, &Chrome120Windows) {
+		t.Errorf("got %+v, want %+v", got, Chrome120Windows)
+	}
+
+	migrated, err := Migrate(v0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc Document
+	json.Unmarshal(migrated, &doc)
+	if doc.SchemaVersion != SchemaVersion {
+		t.Errorf("schema_version got %v, want %v", doc.SchemaVersion, SchemaVersion)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"未来版本", `{"schema_version": 99, "tls": {"ja3": "771,,,,"}}`, "不支持的指纹文档版本 99"},
+		{"缺少 JA3", `{"schema_version": 1, "name": "x"}`, "缺少 tls.ja3"},
+		{"无效 JSON", `{`, "解析指纹文档失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFingerprint([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want 包含 %q", err, tt.want)
+			}
+		})
+	}
+}
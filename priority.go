@@ -0,0 +1,194 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"slices"
+)
+
+// FetchPriority 是请求的优先级提示，对应 HTML 和 fetch() 的 fetchpriority 属性，见 WithPriority
+type FetchPriority int
+
+const (
+	// PriorityAuto 不改变优先级，使用 HTTP2Settings.HeaderPriority，
+	// 浏览器预设中即顶层文档请求的优先级
+	PriorityAuto FetchPriority = iota
+
+	// PriorityHigh 是 fetchpriority="high" 的请求的优先级，对应 Chrome 的 HIGHEST
+	PriorityHigh
+
+	// PriorityLow 是 fetchpriority="low" 的请求和图片等子资源的优先级，对应 Chrome 的 LOWEST
+	PriorityLow
+)
+
+func (p FetchPriority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	}
+	return "auto"
+}
+
+// h2Weight 返回 p 在 HTTP/2 HEADERS 帧中的权重 (从 0 开始)，PriorityAuto 返回 false
+//
+// 与 Chrome 的 net::RequestPriority 到 HTTP/2 权重的映射相同：HIGHEST 为 256，LOWEST 为 147
+func (p FetchPriority) h2Weight() (uint8, bool) {
+	switch p {
+	case PriorityHigh:
+		return 255, true
+	case PriorityLow:
+		return 146, true
+	}
+	return 0, false
+}
+
+// chromeLevel 返回 p 在 Chrome 优先级中的级别，0 最高
+func (p FetchPriority) chromeLevel() int {
+	if p == PriorityLow {
+		return 1
+	}
+	return 0
+}
+
+// http2priorityTree 记录 HTTP/2 连接上已发送 HEADERS 且仍未结束的流，
+// 像 Chrome 的 HttpPriorityDependencies 一样为新流选择依赖的流。
+// 由 http2ClientConn.mu 保护
+type http2priorityTree struct {
+	levels  [2][]uint32       // 每级的流，按打开的顺序
+	parents map[uint32]uint32 // 各流依赖的流
+}
+
+// parent 返回优先级为 p 的流 id 依赖的流，并记录 id。
+// id 已记录时 (如 trailer 的 HEADERS) 返回之前的结果
+func (t *http2priorityTree) parent(id uint32, p FetchPriority) uint32 {
+	if dep, ok := t.parents[id]; ok {
+		return dep
+	}
+	var dep uint32
+	for level := p.chromeLevel(); level >= 0; level-- {
+		if ids := t.levels[level]; len(ids) > 0 {
+			dep = ids[len(ids)-1]
+			break
+		}
+	}
+	if t.parents == nil {
+		t.parents = make(map[uint32]uint32)
+	}
+	t.parents[id] = dep
+	t.levels[p.chromeLevel()] = append(t.levels[p.chromeLevel()], id)
+	return dep
+}
+
+// forget 在流 id 结束时删除它的记录
+func (t *http2priorityTree) forget(id uint32) {
+	if _, ok := t.parents[id]; !ok {
+		return
+	}
+	delete(t.parents, id)
+	for i, ids := range t.levels {
+		t.levels[i] = slices.DeleteFunc(ids, func(v uint32) bool { return v == id })
+	}
+}
+
+// priorityKey 是 WithPriority 的 context 键
+type priorityKey struct{}
+
+// WithPriority 返回带有优先级提示 p 的 ctx 副本
+//
+// 使用返回的 context 的 HTTP/2 请求按 p 调整 HEADERS 帧中的优先级，
+// 使图片、脚本等子资源的请求相对于文档请求有浏览器那样的优先级：
+//   - 权重按 Chrome 的映射调整
+//   - HTTP2Settings.HeaderPriority 为独占 (Chrome 的设置) 时，依赖的流也像
+//     Chrome 一样选择：同一连接上最近打开、仍未结束的同级流，没有时取更高一级
+//     的，都没有时为 0。PriorityAuto 和 PriorityHigh 同属最高一级，
+//     没有 WithPriority 的请求也按此选择
+//   - HeaderPriority 非独占 (如 Firefox 的设置) 时，依赖的流和独占标志保持配置的值，
+//     只调整权重
+//
+// 没有设置 HeaderPriority (不在 HEADERS 中发送优先级的浏览器) 时没有影响。
+// HTTP/1.1 请求不受影响。
+func WithPriority(ctx context.Context, p FetchPriority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext 返回 WithPriority 在 ctx 中设置的优先级，没有时返回 PriorityAuto
+func PriorityFromContext(ctx context.Context) FetchPriority {
+	p, _ := ctx.Value(priorityKey{}).(FetchPriority)
+	return p
+}
+
+// ExtensiblePriorities 配置 RFC 9218 的可扩展优先级，见 Transport.ExtensiblePriorities
+//
+// 新版 Chrome 在 HTTP/2 和 HTTP/3 请求中以 priority 头部声明优先级，如 "u=0, i"，
+// 取代 HTTP/2 的依赖树。头部的值按请求的 FetchPriority (见 WithPriority) 选择。
+type ExtensiblePriorities struct {
+	// Values 按 FetchPriority 覆盖优先级的值 (RFC 9218 的 Priority 结构化字段)，
+	// 为空字符串时不发送。没有覆盖时 urgency 与 Chrome 相同：PriorityAuto 和
+	// PriorityHigh 为 "u=0, i"，PriorityLow 为 "u=3, i"
+	Values map[FetchPriority]string
+
+	// Frames 为 true 时，HTTP/2 请求在 HEADERS 帧之前先发送携带相同值的
+	// PRIORITY_UPDATE 帧 (RFC 9218 第 7.1 节)。HTTP/3 由 Transport.HTTP3 负责，不发送
+	Frames bool
+
+	// NoHeader 为 true 时不发送 priority 头部，只在 Frames 为 true 时发送帧
+	NoHeader bool
+}
+
+// defaultPriorityValues 是 Chrome 各优先级的 priority 头部
+var defaultPriorityValues = map[FetchPriority]string{
+	PriorityAuto: "u=0, i",
+	PriorityHigh: "u=0, i",
+	PriorityLow:  "u=3, i",
+}
+
+// value 返回 fp 对应的优先级的值
+func (p *ExtensiblePriorities) value(fp FetchPriority) string {
+	if v, ok := p.Values[fp]; ok {
+		return v
+	}
+	return defaultPriorityValues[fp]
+}
+
+// priorityHeader 返回 req 应添加的 priority 头部的值，不需要添加时返回空。
+// 请求已带有 priority 头部时不添加
+func (t *Transport) priorityHeader(req *Request) string {
+	p := t.ExtensiblePriorities
+	if p == nil || p.NoHeader || req.Header.Get("Priority") != "" {
+		return ""
+	}
+	return p.value(PriorityFromContext(req.Context()))
+}
+
+// priorityUpdate 返回 HTTP/2 请求 req 的 PRIORITY_UPDATE 帧的值，不需要发送时返回空。
+// 请求已带有 priority 头部时使用该头部的值
+func (t *Transport) priorityUpdate(req *Request) string {
+	p := t.ExtensiblePriorities
+	if p == nil || !p.Frames {
+		return ""
+	}
+	if v := req.Header.Get("Priority"); v != "" {
+		return v
+	}
+	return p.value(PriorityFromContext(req.Context()))
+}
+
+// withPriorityHeader 返回添加了 priority 头部的 req 副本，用于交给 Transport.HTTP3 的请求
+func (t *Transport) withPriorityHeader(req *Request) *Request {
+	v := t.priorityHeader(req)
+	if v == "" {
+		return req
+	}
+	r2 := *req
+	r2.Header = req.Header.Clone()
+	if r2.Header == nil {
+		r2.Header = make(Header)
+	}
+	r2.Header.Set("Priority", v)
+	return &r2
+}
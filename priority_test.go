@@ -0,0 +1,290 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	ctls "crypto/tls"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2/hpack"
+)
+
+// h2FrameServer 是记录客户端发送的 HTTP/2 帧的最简服务端，对每个请求回复空的 200
+type h2FrameServer struct {
+	*httptest.Server
+
+	// greet 在服务端的 SETTINGS 之后调用，可以发送额外的帧
+	greet func(fr *http2Framer)
+
+	// respond 不为 nil 时代替默认的 200 回复请求
+	respond func(fr *http2Framer, h *http2MetaHeadersFrame)
+
+	mu     sync.Mutex
+	frames []http2Frame
+}
+
+func newH2FrameServer(t *testing.T) *h2FrameServer {
+	s := &h2FrameServer{Server: httptest.NewUnstartedServer(nil)}
+	s.TLS = &ctls.Config{NextProtos: []string{"h2"}}
+	s.Config.TLSNextProto = map[string]func(*nethttp.Server, *ctls.Conn, nethttp.Handler){
+		"h2": func(_ *nethttp.Server, c *ctls.Conn, _ nethttp.Handler) {
+			s.serve(c)
+		},
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *h2FrameServer) serve(c *ctls.Conn) {
+	defer c.Close()
+	if _, err := io.ReadFull(c, make([]byte, len(http2ClientPreface))); err != nil {
+		return
+	}
+	fr := http2NewFramer(c, c)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	fr.WriteSettings()
+	if s.greet != nil {
+		s.greet(fr)
+	}
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		// 未知帧和 SETTINGS 帧的负载在下次 ReadFrame 后失效，保存副本
+		switch g := f.(type) {
+		case *http2UnknownFrame:
+			f = &http2UnknownFrame{g.HTTP2FrameHeader, slices.Clone(g.Payload())}
+		case *http2SettingsFrame:
+			f = &http2SettingsFrame{g.HTTP2FrameHeader, slices.Clone(g.p)}
+		}
+		s.mu.Lock()
+		s.frames = append(s.frames, f)
+		s.mu.Unlock()
+		switch f := f.(type) {
+		case *http2SettingsFrame:
+			if !f.IsAck() {
+				fr.WriteSettingsAck()
+			}
+		case *http2MetaHeadersFrame:
+			if s.respond != nil {
+				s.respond(fr, f)
+				continue
+			}
+			buf.Reset()
+			enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+			fr.WriteHeaders(http2HeadersFrameParam{
+				StreamID:      f.StreamID,
+				BlockFragment: buf.Bytes(),
+				EndStream:     true,
+				EndHeaders:    true,
+			})
+		}
+	}
+}
+
+// headers 返回收到的 HEADERS 帧
+func (s *h2FrameServer) headers() []*http2MetaHeadersFrame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hs []*http2MetaHeadersFrame
+	for _, f := range s.frames {
+		if h, ok := f.(*http2MetaHeadersFrame); ok {
+			hs = append(hs, h)
+		}
+	}
+	return hs
+}
+
+// TestWithPriority 测试优先级提示调整 HEADERS 帧中的权重
+func TestWithPriority(t *testing.T) {
+	for _, configured := range []bool{true, false} {
+		ts := newH2FrameServer(t)
+		settings := &HTTP2Settings{}
+		if configured {
+			settings.HeaderPriority = &HTTP2PriorityParam{StreamDep: 0, Exclusive: true, Weight: 255}
+		}
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			ForceAttemptHTTP2: true,
+			HTTP2Settings:     settings,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		for _, p := range []FetchPriority{PriorityAuto, PriorityLow, PriorityHigh} {
+			req, _ := NewRequestWithContext(WithPriority(context.Background(), p), "GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("%v: %v", p, err)
+			}
+			resp.Body.Close()
+		}
+		tr.CloseIdleConnections()
+
+		want := []HTTP2PriorityParam{
+			{Exclusive: true, Weight: 255},
+			{Exclusive: true, Weight: 146},
+			{Exclusive: true, Weight: 255},
+		}
+		if !configured {
+			want = make([]HTTP2PriorityParam, 3)
+		}
+		hs := ts.headers()
+		if len(hs) != len(want) {
+			t.Fatalf("收到 %d 个 HEADERS 帧, want %d", len(hs), len(want))
+		}
+		for i, h := range hs {
+			if h.Priority != want[i] {
+				t.Errorf("HeaderPriority %v: 请求 %d 的优先级 got %+v, want %+v", configured, i, h.Priority, want[i])
+			}
+		}
+	}
+
+	if got := PriorityFromContext(context.Background()); got != PriorityAuto {
+		t.Errorf("PriorityFromContext got %v, want auto", got)
+	}
+}
+
+// TestWithPriorityDependency 测试独占的 HeaderPriority 像 Chrome 一样选择依赖的流
+func TestWithPriorityDependency(t *testing.T) {
+	ts := newH2FrameServer(t)
+	// 收到全部请求后才回复，使各请求的流同时处于打开状态
+	const n = 4
+	seen := make(chan struct{}, n)
+	var open []uint32
+	ts.respond = func(fr *http2Framer, h *http2MetaHeadersFrame) {
+		open = append(open, h.StreamID)
+		seen <- struct{}{}
+		if len(open) < n {
+			return
+		}
+		var buf bytes.Buffer
+		hpack.NewEncoder(&buf).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		for _, id := range open {
+			fr.WriteHeaders(http2HeadersFrameParam{StreamID: id, BlockFragment: buf.Bytes(), EndStream: true, EndHeaders: true})
+		}
+	}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		HTTP2Settings:     &HTTP2Settings{HeaderPriority: &HTTP2PriorityParam{StreamDep: 0, Exclusive: true, Weight: 255}},
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+
+	var wg sync.WaitGroup
+	for _, p := range []FetchPriority{PriorityAuto, PriorityLow, PriorityLow, PriorityHigh} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := NewRequestWithContext(WithPriority(context.Background(), p), "GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		<-seen
+	}
+	wg.Wait()
+
+	want := []HTTP2PriorityParam{
+		{StreamDep: 0, Exclusive: true, Weight: 255}, // 文档
+		{StreamDep: 1, Exclusive: true, Weight: 146}, // 没有同级的流，依赖更高一级的流 1
+		{StreamDep: 3, Exclusive: true, Weight: 146}, // 依赖最近的同级流 3
+		{StreamDep: 1, Exclusive: true, Weight: 255}, // 不依赖更低一级的流
+	}
+	hs := ts.headers()
+	if len(hs) != len(want) {
+		t.Fatalf("收到 %d 个 HEADERS 帧, want %d", len(hs), len(want))
+	}
+	for i, h := range hs {
+		if h.Priority != want[i] {
+			t.Errorf("请求 %d 的优先级 got %+v, want %+v", i, h.Priority, want[i])
+		}
+	}
+}
+
+// TestExtensiblePriorities 测试 RFC 9218 的 priority 头部和 PRIORITY_UPDATE 帧
+func TestExtensiblePriorities(t *testing.T) {
+	ts := newH2FrameServer(t)
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ExtensiblePriorities: &ExtensiblePriorities{
+			Frames: true,
+			Values: map[FetchPriority]string{PriorityLow: "u=5"},
+		},
+	}
+	defer tr.CloseIdleConnections()
+	send := func(p FetchPriority, header string) {
+		t.Helper()
+		req, _ := NewRequestWithContext(WithPriority(context.Background(), p), "GET", ts.URL, nil)
+		if header != "" {
+			req.Header.Set("Priority", header)
+		}
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	send(PriorityAuto, "")
+	send(PriorityLow, "")
+	send(PriorityHigh, "u=2")
+
+	want := []string{"u=0, i", "u=5", "u=2"}
+	var got []string
+	updates := make(map[uint32]string)
+	ts.mu.Lock()
+	for _, f := range ts.frames {
+		switch f := f.(type) {
+		case *http2MetaHeadersFrame:
+			var v string
+			for _, hf := range f.Fields {
+				if hf.Name == "priority" {
+					v = hf.Value
+				}
+			}
+			got = append(got, v)
+			if u, ok := updates[f.StreamID]; !ok || u != v {
+				t.Errorf("流 %d 的 PRIORITY_UPDATE got %q (发送: %v), want %q", f.StreamID, u, ok, v)
+			}
+		case *http2UnknownFrame:
+			if f.Type != http2framePriorityUpdate || f.StreamID != 0 {
+				t.Errorf("未知帧 %v 流 %d", f.Type, f.StreamID)
+				continue
+			}
+			p := f.p
+			updates[uint32(p[0])<<24|uint32(p[1])<<16|uint32(p[2])<<8|uint32(p[3])] = string(p[4:])
+		}
+	}
+	ts.mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Errorf("priority 头部 got %q, want %q", got, want)
+	}
+
+	// 交给 HTTP3 的请求加上头部，原请求不变
+	req, _ := NewRequest("GET", "https://example.com/", nil)
+	if r2 := tr.withPriorityHeader(req); r2.Header.Get("Priority") != "u=0, i" || req.Header.Get("Priority") != "" {
+		t.Errorf("HTTP/3 请求的 priority got %q, 原请求 %q", r2.Header.Get("Priority"), req.Header.Get("Priority"))
+	}
+	tr.ExtensiblePriorities.NoHeader = true
+	if r2 := tr.withPriorityHeader(req); r2 != req {
+		t.Error("NoHeader 时不应添加头部")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// SystemProxyConfig is the operating system's proxy configuration, as
+// read by ReadSystemProxyConfig.
+type SystemProxyConfig struct {
+	// HTTPProxy, HTTPSProxy and SOCKSProxy are the proxies configured
+	// for each protocol, as URLs or "host:port". Empty means none.
+	HTTPProxy  string
+	HTTPSProxy string
+	SOCKSProxy string
+
+	// Bypass lists hosts that are reached directly, in the
+	// NO_PROXY syntax understood by ProxyFromEnvironment.
+	Bypass []string
+
+	// BypassSimpleHostnames reports whether host names without a
+	// dot are reached directly.
+	BypassSimpleHostnames bool
+
+	// AutoConfigURL is the configured proxy auto-config URL, if any.
+	// ProxyFromSystem does not evaluate it; use a PACResolver.
+	AutoConfigURL string
+}
+
+// ReadSystemProxyConfig reads the proxy settings of the current user
+// from the operating system: the Internet Settings registry key on
+// Windows and the output of scutil --proxy on macOS. On other systems
+// it returns a nil config and a nil error.
+func ReadSystemProxyConfig() (*SystemProxyConfig, error) {
+	return readSystemProxyConfig()
+}
+
+// systemProxyTTL is how long ProxyFromSystem caches the system settings.
+const systemProxyTTL = 30 * time.Second
+
+var systemProxy struct {
+	sync.Mutex
+	fn     func(*url.URL) (*url.URL, error)
+	readAt time.Time
+}
+
+// ProxyFromSystem returns the URL of the proxy to use for a given
+// request according to the operating system's proxy settings (see
+// ReadSystemProxyConfig), so that desktop applications honor the
+// proxy configured in the system's network preferences. Settings are
+// re-read at most every 30 seconds.
+//
+// Where no system configuration is available, such as on Linux, or
+// it cannot be read, ProxyFromSystem behaves like
+// ProxyFromEnvironment. Like ProxyFromEnvironment, requests to
+// localhost are never proxied.
+func ProxyFromSystem(req *Request) (*url.URL, error) {
+	systemProxy.Lock()
+	if systemProxy.fn == nil || time.Since(systemProxy.readAt) >= systemProxyTTL {
+		systemProxy.fn = envProxyFunc()
+		if cfg, err := readSystemProxyConfig(); err == nil && cfg != nil {
+			systemProxy.fn = cfg.proxyFunc()
+		}
+		systemProxy.readAt = time.Now()
+	}
+	fn := systemProxy.fn
+	systemProxy.Unlock()
+	return fn(req.URL)
+}
+
+// proxyFunc returns a function reporting the proxy for a URL under c.
+func (c *SystemProxyConfig) proxyFunc() func(*url.URL) (*url.URL, error) {
+	httpProxy, httpsProxy := c.HTTPProxy, c.HTTPSProxy
+	if c.SOCKSProxy != "" {
+		socks := c.SOCKSProxy
+		if !strings.Contains(socks, "://") {
+			socks = "socks5://" + socks
+		}
+		if httpProxy == "" {
+			httpProxy = socks
+		}
+		if httpsProxy == "" {
+			httpsProxy = socks
+		}
+	}
+	fn := (&httpproxy.Config{
+		HTTPProxy:  httpProxy,
+		HTTPSProxy: httpsProxy,
+		NoProxy:    strings.Join(c.Bypass, ","),
+	}).ProxyFunc()
+	if !c.BypassSimpleHostnames {
+		return fn
+	}
+	return func(u *url.URL) (*url.URL, error) {
+		if h := u.Hostname(); !strings.Contains(h, ".") && !strings.Contains(h, ":") {
+			return nil, nil
+		}
+		return fn(u)
+	}
+}
+
+// parseWindowsProxySettings builds a config from the ProxyServer and
+// ProxyOverride values of the Windows Internet Settings registry key.
+// ProxyServer is either "host:port" for all protocols or a list like
+// "http=host:port;https=host:port;socks=host:port". ProxyOverride is a
+// ';'-separated list of host patterns, where "<local>" stands for
+// simple host names.
+func parseWindowsProxySettings(server, override string) *SystemProxyConfig {
+	c := &SystemProxyConfig{}
+	if !strings.Contains(server, "=") {
+		c.HTTPProxy, c.HTTPSProxy = server, server
+	} else {
+		for _, part := range strings.Split(server, ";") {
+			proto, addr, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(proto) {
+			case "http":
+				c.HTTPProxy = addr
+			case "https":
+				c.HTTPSProxy = addr
+			case "socks":
+				c.SOCKSProxy = addr
+			}
+		}
+	}
+	for _, p := range strings.Split(override, ";") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+		case strings.EqualFold(p, "<local>"):
+			c.BypassSimpleHostnames = true
+		default:
+			c.Bypass = append(c.Bypass, bypassPattern(p))
+		}
+	}
+	return c
+}
+
+// parseScutilProxy builds a config from the output of macOS's
+// "scutil --proxy", which looks like:
+//
+//	<dictionary> {
+//	  ExceptionsList : <array> {
+//	    0 : *.local
+//	  }
+//	  HTTPEnable : 1
+//	  HTTPPort : 8080
+//	  HTTPProxy : proxy.example.com
+//	}
+func parseScutilProxy(out string) *SystemProxyConfig {
+	kv := make(map[string]string)
+	c := &SystemProxyConfig{}
+	inExceptions := false
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "}" {
+			inExceptions = false
+			continue
+		}
+		k, v, ok := strings.Cut(line, " : ")
+		if !ok {
+			continue
+		}
+		if inExceptions {
+			c.Bypass = append(c.Bypass, bypassPattern(v))
+			continue
+		}
+		if k == "ExceptionsList" {
+			inExceptions = true
+			continue
+		}
+		kv[k] = v
+	}
+	hostPort := func(proto string) string {
+		if kv[proto+"Enable"] != "1" || kv[proto+"Proxy"] == "" {
+			return ""
+		}
+		if port := kv[proto+"Port"]; port != "" {
+			return kv[proto+"Proxy"] + ":" + port
+		}
+		return kv[proto+"Proxy"]
+	}
+	c.HTTPProxy = hostPort("HTTP")
+	c.HTTPSProxy = hostPort("HTTPS")
+	c.SOCKSProxy = hostPort("SOCKS")
+	c.BypassSimpleHostnames = kv["ExcludeSimpleHostnames"] == "1"
+	if kv["ProxyAutoConfigEnable"] == "1" {
+		c.AutoConfigURL = kv["ProxyAutoConfigURLString"]
+	}
+	return c
+}
+
+// bypassPattern converts an OS bypass pattern into NO_PROXY syntax.
+// IP wildcards such as "10.*" or "192.168.*.*" become CIDR blocks and
+// abbreviated CIDRs such as "169.254/16" are expanded.
+func bypassPattern(p string) string {
+	addr, bits, hasBits := strings.Cut(p, "/")
+	octets := strings.Split(addr, ".")
+	if len(octets) > 4 {
+		return p
+	}
+	n := 0
+	for _, o := range octets {
+		if o == "*" {
+			break
+		}
+		if _, err := strconv.ParseUint(o, 10, 8); err != nil {
+			return p
+		}
+		n++
+	}
+	if n == 0 {
+		return p
+	}
+	for i := n; i < len(octets); i++ {
+		if octets[i] != "*" {
+			return p
+		}
+	}
+	if !hasBits {
+		if n == 4 {
+			return p
+		}
+		bits = strconv.Itoa(8 * n)
+	}
+	for len(octets) < 4 || n < len(octets) {
+		if n < len(octets) {
+			octets[n] = "0"
+		} else {
+			octets = append(octets, "0")
+		}
+		n++
+	}
+	return strings.Join(octets, ".") + "/" + bits
+}
@@ -0,0 +1,19 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin
+
+package http
+
+import "os/exec"
+
+// readSystemProxyConfig reads the settings from SCDynamicStore through
+// scutil, which avoids linking against the system frameworks with cgo.
+func readSystemProxyConfig() (*SystemProxyConfig, error) {
+	out, err := exec.Command("/usr/sbin/scutil", "--proxy").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseScutilProxy(string(out)), nil
+}
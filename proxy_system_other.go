@@ -0,0 +1,11 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !darwin
+
+package http
+
+func readSystemProxyConfig() (*SystemProxyConfig, error) {
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// TestBypassPattern 测试系统代理例外规则到 NO_PROXY 语法的转换
+func TestBypassPattern(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"10.*", "10.0.0.0/8"},
+		{"192.168.*.*", "192.168.0.0/16"},
+		{"169.254/16", "169.254.0.0/16"},
+		{"192.168.1.5", "192.168.1.5"},
+		{"*.corp.example", "*.corp.example"},
+		{"*", "*"},
+		{"intranet", "intranet"},
+	}
+	for _, tt := range tests {
+		if got := bypassPattern(tt.in); got != tt.want {
+			t.Errorf("bypassPattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestParseSystemProxySettings 测试 Windows 注册表和 macOS scutil 输出的解析
+func TestParseSystemProxySettings(t *testing.T) {
+	const scutil = `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+    1 : 169.254/16
+  }
+  ExcludeSimpleHostnames : 1
+  HTTPEnable : 1
+  HTTPPort : 8080
+  HTTPProxy : proxy.example.com
+  HTTPSEnable : 0
+  HTTPSPort : 8443
+  HTTPSProxy : secure.example.com
+  ProxyAutoConfigEnable : 1
+  ProxyAutoConfigURLString : http://wpad.example.com/proxy.pac
+}
+`
+	tests := []struct {
+		name string
+		got  *SystemProxyConfig
+		want *SystemProxyConfig
+	}{
+		{
+			name: "Windows 单一代理",
+			got:  parseWindowsProxySettings("proxy.example.com:3128", "<local>;*.corp.example;10.*"),
+			want: &SystemProxyConfig{
+				HTTPProxy:             "proxy.example.com:3128",
+				HTTPSProxy:            "proxy.example.com:3128",
+				Bypass:                []string{"*.corp.example", "10.0.0.0/8"},
+				BypassSimpleHostnames: true,
+			},
+		},
+		{
+			name: "Windows 按协议配置",
+			got:  parseWindowsProxySettings("http=h.example:80;https=s.example:443;socks=k.example:1080", ""),
+			want: &SystemProxyConfig{
+				HTTPProxy:  "h.example:80",
+				HTTPSProxy: "s.example:443",
+				SOCKSProxy: "k.example:1080",
+			},
+		},
+		{
+			name: "macOS scutil",
+			got:  parseScutilProxy(scutil),
+			want: &SystemProxyConfig{
+				HTTPProxy:             "proxy.example.com:8080",
+				Bypass:                []string{"*.local", "169.254.0.0/16"},
+				BypassSimpleHostnames: true,
+				AutoConfigURL:         "http://wpad.example.com/proxy.pac",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSystemProxyConfigProxyFunc 测试系统代理配置的代理选择
+func TestSystemProxyConfigProxyFunc(t *testing.T) {
+	fn := parseWindowsProxySettings("http=h.example:80;socks=k.example:1080", "<local>;*.corp.example").proxyFunc()
+	tests := []struct{ url, want string }{
+		{"http://www.example.com/", "http://h.example:80"},
+		{"https://www.example.com/", "socks5://k.example:1080"},
+		{"http://intranet/", ""},
+		{"http://wiki.corp.example/", ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		p, err := fn(u)
+		got := ""
+		if p != nil {
+			got = p.String()
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("proxy(%s) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
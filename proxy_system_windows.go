@@ -0,0 +1,70 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package http
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const internetSettingsKey = `Software\Microsoft\Windows\CurrentVersion\Internet Settings`
+
+func readSystemProxyConfig() (*SystemProxyConfig, error) {
+	path, err := syscall.UTF16PtrFromString(internetSettingsKey)
+	if err != nil {
+		return nil, err
+	}
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_CURRENT_USER, path, 0, syscall.KEY_READ, &key); err != nil {
+		return nil, err
+	}
+	defer syscall.RegCloseKey(key)
+
+	c := &SystemProxyConfig{}
+	if enabled, _ := regDWORD(key, "ProxyEnable"); enabled != 0 {
+		server, _ := regString(key, "ProxyServer")
+		override, _ := regString(key, "ProxyOverride")
+		c = parseWindowsProxySettings(server, override)
+	}
+	c.AutoConfigURL, _ = regString(key, "AutoConfigURL")
+	return c, nil
+}
+
+func regString(key syscall.Handle, name string) (string, error) {
+	n, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	var typ, size uint32
+	if err := syscall.RegQueryValueEx(key, n, nil, &typ, nil, &size); err != nil {
+		return "", err
+	}
+	if (typ != syscall.REG_SZ && typ != syscall.REG_EXPAND_SZ) || size < 2 {
+		return "", nil
+	}
+	buf := make([]uint16, size/2)
+	if err := syscall.RegQueryValueEx(key, n, nil, &typ, (*byte)(unsafe.Pointer(&buf[0])), &size); err != nil {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf), nil
+}
+
+func regDWORD(key syscall.Handle, name string) (uint32, error) {
+	n, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	var typ, v uint32
+	size := uint32(unsafe.Sizeof(v))
+	if err := syscall.RegQueryValueEx(key, n, nil, &typ, (*byte)(unsafe.Pointer(&v)), &size); err != nil {
+		return 0, err
+	}
+	if typ != syscall.REG_DWORD {
+		return 0, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// maxProxyAuthAttempts is the number of 407 responses from a proxy
+// per request for which GetProxyCredentials is consulted.
+const maxProxyAuthAttempts = 3
+
+// proxyAuthRequiredError is returned by dialConn when a CONNECT request
+// got a 407 response and GetProxyCredentials is set. roundTrip refreshes
+// the credentials and retries the request, or returns err once the
+// request has seen maxProxyAuthAttempts 407 responses.
+type proxyAuthRequiredError struct {
+	proxyURL *url.URL
+	err      error // the error reported without GetProxyCredentials
+}
+
+func (e *proxyAuthRequiredError) Error() string { return e.err.Error() }
+
+// errProxyAuthRequired is the error recorded for a retry after a 407
+// response to a request sent through a plain HTTP proxy.
+var errProxyAuthRequired = errors.New("net/http: proxy answered 407 Proxy Authentication Required")
+
+// proxyCredentials is the state kept per proxy for GetProxyCredentials.
+type proxyCredentials struct {
+	auth string // Proxy-Authorization value; empty until refreshed
+}
+
+func proxyCredentialsKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// proxyAuth is like cm.proxyAuth but prefers credentials obtained
+// from GetProxyCredentials.
+func (t *Transport) proxyAuth(cm *connectMethod) string {
+	if cm.proxyURL != nil && t.GetProxyCredentials != nil {
+		t.proxyCredsMu.Lock()
+		pc := t.proxyCreds[proxyCredentialsKey(cm.proxyURL)]
+		t.proxyCredsMu.Unlock()
+		if pc != nil && pc.auth != "" {
+			return pc.auth
+		}
+	}
+	return cm.proxyAuth()
+}
+
+// refreshProxyCredentials handles the attempt'th 407 response from
+// proxyURL to one request. It reports whether the request should be
+// retried with the new credentials, which is false once attempt
+// exceeds maxProxyAuthAttempts.
+func (t *Transport) refreshProxyCredentials(ctx context.Context, proxyURL *url.URL, attempt int) (retry bool, err error) {
+	if attempt > maxProxyAuthAttempts {
+		return false, nil
+	}
+	user, pass, err := t.GetProxyCredentials(ctx, proxyURL, attempt)
+	if err != nil {
+		return false, err
+	}
+	key := proxyCredentialsKey(proxyURL)
+	t.proxyCredsMu.Lock()
+	if t.proxyCreds == nil {
+		t.proxyCreds = make(map[string]*proxyCredentials)
+	}
+	t.proxyCreds[key] = &proxyCredentials{auth: "Basic " + basicAuth(user, pass)}
+	t.proxyCredsMu.Unlock()
+	return true, nil
+}
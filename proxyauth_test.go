@@ -0,0 +1,212 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// newAuthProxy 返回一个要求 Basic 认证的代理，支持普通 HTTP 转发和 CONNECT 隧道
+// 只有当前有效的凭据能通过认证，valid 可在测试中修改
+func newAuthProxy(t *testing.T, valid *string, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		want := "Basic " + base64.StdEncoding.EncodeToString([]byte(*valid))
+		mu.Unlock()
+		if r.Header.Get("Proxy-Authorization") != want {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+			w.WriteHeader(nethttp.StatusProxyAuthRequired)
+			return
+		}
+		if r.Method == "CONNECT" {
+			dst, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(nethttp.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(nethttp.StatusOK)
+			src, _, _ := w.(nethttp.Hijacker).Hijack()
+			go func() { io.Copy(dst, src); dst.Close() }()
+			io.Copy(src, dst)
+			src.Close()
+			return
+		}
+		resp, err := nethttp.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+}
+
+// TestTransportGetProxyCredentials 测试 407 响应触发代理凭据刷新
+func TestTransportGetProxyCredentials(t *testing.T) {
+	target := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	tlsTarget := httptest.NewTLSServer(target.Config.Handler)
+	defer tlsTarget.Close()
+
+	var mu sync.Mutex
+	valid := "user:secret-1"
+	proxy := newAuthProxy(t, &valid, &mu)
+	defer proxy.Close()
+
+	for _, targetURL := range []string{target.URL, tlsTarget.URL} {
+		t.Run(targetURL[:5], func(t *testing.T) {
+			mu.Lock()
+			valid = "user:secret-1"
+			mu.Unlock()
+
+			proxyURL, _ := url.Parse(proxy.URL)
+			proxyURL.User = url.UserPassword("user", "stale")
+			var attempts []int
+			tr := &Transport{
+				Proxy:             ProxyURL(proxyURL),
+				DisableKeepAlives: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				GetProxyCredentials: func(ctx context.Context, u *url.URL, attempt int) (string, string, error) {
+					attempts = append(attempts, attempt)
+					mu.Lock()
+					defer mu.Unlock()
+					_, pass, _ := strings.Cut(valid, ":")
+					return "user", pass, nil
+				},
+			}
+			get := func() *Response {
+				req, _ := NewRequest("GET", targetURL, nil)
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("请求失败: %v", err)
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return resp
+			}
+
+			if resp := get(); resp.StatusCode != 200 {
+				t.Fatalf("StatusCode = %d, want 200", resp.StatusCode)
+			}
+			if len(attempts) != 1 || attempts[0] != 1 {
+				t.Fatalf("attempts = %v, want [1]", attempts)
+			}
+			// 刷新后的凭据用于后续连接，不再触发 407
+			get()
+			if len(attempts) != 1 {
+				t.Fatalf("attempts = %v, want [1]", attempts)
+			}
+
+			// 凭据轮换后再次刷新
+			mu.Lock()
+			valid = "user:secret-2"
+			mu.Unlock()
+			get()
+			if len(attempts) != 2 || attempts[1] != 1 {
+				t.Fatalf("attempts = %v, want [1 1]", attempts)
+			}
+		})
+	}
+}
+
+// TestTransportGetProxyCredentialsLimits 测试回调错误和连续 407 的次数上限
+func TestTransportGetProxyCredentialsLimits(t *testing.T) {
+	target := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer target.Close()
+	var mu sync.Mutex
+	valid := "user:never"
+	proxy := newAuthProxy(t, &valid, &mu)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	var attempts []int
+	tr := &Transport{
+		Proxy: ProxyURL(proxyURL),
+		GetProxyCredentials: func(ctx context.Context, u *url.URL, attempt int) (string, string, error) {
+			attempts = append(attempts, attempt)
+			return "user", "wrong", nil
+		},
+	}
+	defer tr.CloseIdleConnections()
+	req, _ := NewRequest("GET", target.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != StatusProxyAuthRequired {
+		t.Errorf("StatusCode = %d, want 407", resp.StatusCode)
+	}
+	if len(attempts) != maxProxyAuthAttempts {
+		t.Errorf("attempts = %v, want %d 次", attempts, maxProxyAuthAttempts)
+	}
+
+	// 次数按请求计算，并发的请求各自最多刷新 maxProxyAuthAttempts 次
+	var amu sync.Mutex
+	perAttempt := make(map[int]int)
+	tr.GetProxyCredentials = func(ctx context.Context, u *url.URL, attempt int) (string, string, error) {
+		amu.Lock()
+		perAttempt[attempt]++
+		amu.Unlock()
+		return "user", "wrong", nil
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := NewRequest("GET", target.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != StatusProxyAuthRequired {
+				t.Errorf("StatusCode = %d, want 407", resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+	for attempt := 1; attempt <= maxProxyAuthAttempts; attempt++ {
+		if perAttempt[attempt] != 4 {
+			t.Errorf("并发请求的 attempt 计数 got %v, want 每个请求 1..%d", perAttempt, maxProxyAuthAttempts)
+			break
+		}
+	}
+
+	// 重试受 MaxRetries 限制
+	tr.MaxRetries = 1
+	req, _ = NewRequest("GET", target.URL, nil)
+	var rle *RetryLimitError
+	if _, err := tr.RoundTrip(req); !errors.As(err, &rle) {
+		t.Errorf("err = %v, want *RetryLimitError", err)
+	}
+	tr.MaxRetries = 0
+
+	errCreds := errors.New("凭据服务不可用")
+	tr.GetProxyCredentials = func(ctx context.Context, u *url.URL, attempt int) (string, string, error) {
+		return "", "", errCreds
+	}
+	req, _ = NewRequest("GET", target.URL, nil)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, errCreds) {
+		t.Errorf("err = %v, want %v", err, errCreds)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxySessions 为在用户名中编码会话的代理 (如住宅代理) 分配、固定和轮换会话 ID
+//
+// Transport.Proxy 返回的代理 URL 的用户名和密码中，{session} 被替换为会话 ID，
+// 其他 {name} 占位符被替换为 Params[name]，如 user-session-{session}-country-{country}。
+// 不含 {session} 的代理 URL 原样使用。url.Parse 不接受用户名中的花括号，
+// 模板可以用 url.UserPassword 构造，或写作 %7Bsession%7D。
+//
+// 每个亲和组 (见 WithAffinity) 分配并固定一个会话 ID，没有亲和键的请求共用一个。
+// 会话 ID 在使用满 Lifetime 后、RotateOnError 为 true 时经该会话连接代理失败后，
+// 或调用 Transport.RotateProxySession 时更换，旧会话的空闲连接随之关闭。
+// 会话 ID 不同的连接从不共享，HTTP/2 连接也是如此。
+//
+// 首次使用后不应再修改其字段。
+type ProxySessions struct {
+	// Params 是 {session} 以外的占位符的值
+	Params map[string]string
+
+	// Lifetime 大于 0 时，会话 ID 使用满 Lifetime 后更换
+	Lifetime time.Duration
+
+	// RotateOnError 为 true 时，连接代理失败或代理拒绝 CONNECT (如返回 502) 后
+	// 更换该会话 ID，之后的请求使用新会话。请求本身仍返回错误，
+	// 除非 ProxyFailover 对连接失败返回了同一模板，此时以新会话重试
+	RotateOnError bool
+
+	// NewID 返回新的会话 ID，为 nil 时使用 16 位随机十六进制字符串
+	NewID func() string
+}
+
+// proxySession 是一个亲和组当前的会话
+type proxySession struct {
+	id    string
+	start time.Time
+	used  map[string]bool // 使用过的代理 URL，更换会话时关闭它们的空闲连接
+}
+
+// proxySessionState 记录 Transport.ProxySessions 分配的会话，按亲和组
+type proxySessionState struct {
+	mu       sync.Mutex
+	sessions map[string]*proxySession
+}
+
+func (s *ProxySessions) newID() string {
+	if s.NewID != nil {
+		return s.NewID()
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// hasSessionPlaceholder 报告 u 的用户名或密码是否包含 {session}
+func hasSessionPlaceholder(u *url.URL) bool {
+	if u == nil || u.User == nil {
+		return false
+	}
+	pass, _ := u.User.Password()
+	return strings.Contains(u.User.Username(), "{session}") || strings.Contains(pass, "{session}")
+}
+
+// proxyForSession 按 t.ProxySessions 将 u 中的占位符替换为亲和组 group 的会话
+func (t *Transport) proxyForSession(u *url.URL, group string) *url.URL {
+	s := t.ProxySessions
+	if s == nil || !hasSessionPlaceholder(u) {
+		return u
+	}
+	st := &t.proxySessions
+	st.mu.Lock()
+	sess := st.sessions[group]
+	var retired map[string]bool
+	if now := t.now(); sess == nil || (s.Lifetime > 0 && now.Sub(sess.start) >= s.Lifetime) {
+		if sess != nil {
+			retired = sess.used
+		}
+		sess = &proxySession{id: s.newID(), start: now, used: make(map[string]bool)}
+		if st.sessions == nil {
+			st.sessions = make(map[string]*proxySession)
+		}
+		st.sessions[group] = sess
+	}
+	oldnew := []string{"{session}", sess.id}
+	for k, v := range s.Params {
+		oldnew = append(oldnew, "{"+k+"}", v)
+	}
+	r := strings.NewReplacer(oldnew...)
+	u2 := *u
+	if pass, ok := u.User.Password(); ok {
+		u2.User = url.UserPassword(r.Replace(u.User.Username()), r.Replace(pass))
+	} else {
+		u2.User = url.User(r.Replace(u.User.Username()))
+	}
+	sess.used[u2.String()] = true
+	st.mu.Unlock()
+
+	t.closeIdleProxies(retired)
+	return &u2
+}
+
+// RotateProxySession 为亲和组 group 更换 ProxySessions 分配的会话 ID，
+// group 为空表示没有亲和键的请求。旧会话的空闲连接被关闭，正在使用的连接不受影响
+func (t *Transport) RotateProxySession(group string) {
+	t.rotateProxySession(group, nil)
+}
+
+// ProxySession 返回亲和组 group 当前的会话 ID，还没有分配时返回空
+func (t *Transport) ProxySession(group string) string {
+	st := &t.proxySessions
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if sess := st.sessions[group]; sess != nil {
+		return sess.id
+	}
+	return ""
+}
+
+// rotateProxySession 丢弃亲和组 group 的会话，proxy 非 nil 时只在会话使用过它时丢弃
+func (t *Transport) rotateProxySession(group string, proxy *url.URL) {
+	st := &t.proxySessions
+	st.mu.Lock()
+	sess := st.sessions[group]
+	if sess == nil || (proxy != nil && !sess.used[proxy.String()]) {
+		st.mu.Unlock()
+		return
+	}
+	delete(st.sessions, group)
+	st.mu.Unlock()
+	t.closeIdleProxies(sess.used)
+}
+
+// rotateProxySessionOnError 在经 cm 的代理建立连接失败或 CONNECT 被拒绝后按 RotateOnError 更换会话
+func (t *Transport) rotateProxySessionOnError(cm *connectMethod, err error) {
+	if t.ProxySessions == nil || !t.ProxySessions.RotateOnError || cm.proxyURL == nil {
+		return
+	}
+	var oe *net.OpError
+	var se *proxyConnectStatusError
+	if (errors.As(err, &oe) && oe.Op == "proxyconnect") || errors.As(err, &se) {
+		t.rotateProxySession(cm.partition.affinity, cm.proxyURL)
+	}
+}
+
+// closeIdleProxies 关闭经 proxies 中的代理建立的空闲连接
+func (t *Transport) closeIdleProxies(proxies map[string]bool) {
+	if len(proxies) == 0 {
+		return
+	}
+	t.closeIdleMatching(func(k connectMethodKey) bool { return proxies[k.proxy] })
+	if t2, ok := t.H2Transport.(*HTTP2Transport); ok {
+		if p := t2.clientConnPool(); p != nil {
+			hashes := make(map[string]bool)
+			for proxy := range proxies {
+				hashes[connectMethodKey{proxy: proxy}.h2Partition().proxy] = true
+			}
+			p.closeIdleConnectionsMatching(func(_ string, part connPartition) bool { return hashes[part.proxy] })
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// applyQUICTransportParameters 用 t.QUICTransportParameters 替换 spec 中
+// quic_transport_parameters (57) 扩展的传输参数
+//
+// 每个连接使用参数的副本，*tls.GREASETransportParameter 未指定的 ID 和内容
+// 因此在每个连接中重新随机生成。同一 ID 出现两次时返回错误 (RFC 9000 7.4 节)
+func (t *Transport) applyQUICTransportParameters(spec *tls.ClientHelloSpec) error {
+	if t.QUICTransportParameters == nil {
+		return nil
+	}
+	params := make(tls.TransportParameters, 0, len(t.QUICTransportParameters))
+	seen := make(map[uint64]bool)
+	for _, p := range t.QUICTransportParameters {
+		switch tp := p.(type) {
+		case nil:
+			return fmt.Errorf("QUICTransportParameters 包含 nil")
+		case *tls.GREASETransportParameter:
+			g := *tp
+			p = &g
+		case *tls.FakeQUICTransportParameter:
+			if tp.Id == 0 {
+				return fmt.Errorf("QUICTransportParameters 中的 FakeQUICTransportParameter 没有设置 Id")
+			}
+		}
+		id := p.ID()
+		if seen[id] {
+			return fmt.Errorf("QUICTransportParameters 包含重复的参数 %#x", id)
+		}
+		seen[id] = true
+		params = append(params, p)
+	}
+	for _, e := range spec.Extensions {
+		if qe, ok := e.(*tls.QUICTransportParametersExtension); ok {
+			*qe = tls.QUICTransportParametersExtension{TransportParameters: params}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+const quicParamsTestJA3 = "771,4865-4866,0-10-11-13-16-43-51-57,29-23,0"
+
+// quicParamsExtension 返回 spec 中的 57 扩展
+func quicParamsExtension(t *testing.T, spec *tls.ClientHelloSpec) *tls.QUICTransportParametersExtension {
+	t.Helper()
+	for _, e := range spec.Extensions {
+		if qe, ok := e.(*tls.QUICTransportParametersExtension); ok {
+			return qe
+		}
+	}
+	t.Fatal("没有 57 扩展")
+	return nil
+}
+
+func TestQUICTransportParameters(t *testing.T) {
+	spec, err := (&Transport{JA3: quicParamsTestJA3}).BuildSpec("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := quicParamsExtension(t, spec).Len(); n != 4 {
+		t.Errorf("默认 57 扩展长度 got %d, want 4", n)
+	}
+
+	grease := &tls.GREASETransportParameter{Length: 3}
+	tr := &Transport{
+		JA3: quicParamsTestJA3,
+		QUICTransportParameters: tls.TransportParameters{
+			tls.MaxIdleTimeout(30000),
+			tls.InitialMaxData(15728640),
+			grease,
+		},
+	}
+	var greaseIDs []uint64
+	for range 2 {
+		spec, err := tr.BuildSpec("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		qe := quicParamsExtension(t, spec)
+		buf := make([]byte, qe.Len())
+		if _, err := qe.Read(buf); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		// max_idle_timeout (1) = 30000 和 initial_max_data (4) = 15728640，值为变长整数
+		want := []byte{0x01, 0x04, 0x80, 0x00, 0x75, 0x30, 0x04, 0x04, 0x80, 0xf0, 0x00, 0x00}
+		if !bytes.HasPrefix(buf[4:], want) {
+			t.Errorf("got %x, want 前缀 %x", buf[4:], want)
+		}
+		g := qe.TransportParameters[2].(*tls.GREASETransportParameter)
+		if g == grease || len(g.Value()) != 3 {
+			t.Errorf("GREASE 参数没有被复制: %+v", g)
+		}
+		greaseIDs = append(greaseIDs, g.ID())
+	}
+	if greaseIDs[0] == greaseIDs[1] || grease.IdOverride != 0 {
+		t.Errorf("GREASE ID 没有在每个连接中重新生成: %x, 原参数 %x", greaseIDs, grease.IdOverride)
+	}
+
+	for name, params := range map[string]tls.TransportParameters{
+		"重复的参数":  {tls.MaxIdleTimeout(1), tls.MaxIdleTimeout(2)},
+		"没有 Id":  {&tls.FakeQUICTransportParameter{Val: []byte{1}}},
+		"nil 参数": {nil},
+	} {
+		if _, err := (&Transport{JA3: quicParamsTestJA3, QUICTransportParameters: params}).BuildSpec("example.com"); err == nil {
+			t.Errorf("%s: 期望错误", name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"math/rand"
+	"sync"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// seededRand 是以固定种子创建的伪随机数序列，见 GREASEConfig.Seed
+type seededRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// do 持锁以序列调用 f，序列在首次使用时以 seed 创建
+func (s *seededRand) do(seed int64, f func(r *rand.Rand)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.r == nil {
+		s.r = rand.New(rand.NewSource(seed))
+	}
+	f(s.r)
+}
+
+// connRand 返回第 n 个指纹连接使用的伪随机数序列，由 RandomSeed 和 n 派生，
+// 各连接互不影响，也不受 BuildSpec 等调用的影响
+func (t *Transport) connRand(n uint64) *rand.Rand {
+	// SplitMix64，使相邻的 n 得到不相关的种子
+	z := uint64(t.RandomSeed) + (n+1)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return rand.New(rand.NewSource(int64(z ^ z>>31)))
+}
+
+// prng 返回 pc 的伪随机数序列，首次调用时取得下一个连接序号，
+// RandomSeed 为 0 时返回 nil
+func (pc *persistConn) prng() *rand.Rand {
+	if pc.t.RandomSeed == 0 {
+		return nil
+	}
+	if pc.rng == nil {
+		pc.rng = pc.t.connRand(pc.t.connSeq.Add(1) - 1)
+	}
+	return pc.rng
+}
+
+// peekSeededRand 让 pc 使用下一个连接将使用的序列而不占用连接序号，
+// 用于 BuildSpec 等不建连的调用
+func (pc *persistConn) peekSeededRand() {
+	if pc.t.RandomSeed != 0 {
+		pc.rng = pc.t.connRand(pc.t.connSeq.Load())
+	}
+}
+
+// shuffleExtensions 按 RandomJA3、RandomizeFingerprint 和 PermuteExtensions 打乱 exts 的顺序
+//
+// RandomSeed 为 0 时使用 utls 的 ShuffleChromeTLSExtensions，否则使用本连接的
+// 序列，规则与前者相同：GREASE、padding 和 pre_shared_key 扩展保持原位，
+// 其余扩展均匀随机排列。
+func (pc *persistConn) shuffleExtensions(exts []tls.TLSExtension) []tls.TLSExtension {
+	r := pc.prng()
+	if r == nil {
+		return tls.ShuffleChromeTLSExtensions(exts)
+	}
+	var movable []int
+	for i, ext := range exts {
+		switch ext.(type) {
+		case *tls.UtlsGREASEExtension, *tls.UtlsPaddingExtension, tls.PreSharedKeyExtension:
+		default:
+			movable = append(movable, i)
+		}
+	}
+	r.Shuffle(len(movable), func(i, j int) {
+		exts[movable[i]], exts[movable[j]] = exts[movable[j]], exts[movable[i]]
+	})
+	return exts
+}
+
+// helloSeed 返回 utls 随机化 ClientHello 使用的种子，RandomSeed 为 0 时返回 nil，
+// 由 utls 使用密码学随机数
+func (pc *persistConn) helloSeed() *tls.PRNGSeed {
+	r := pc.prng()
+	if r == nil {
+		return nil
+	}
+	seed := new(tls.PRNGSeed)
+	for i := range seed {
+		seed[i] = byte(r.Intn(256))
+	}
+	return seed
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	ctls "crypto/tls"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestRandomSeed 测试种子相同时随机化的扩展顺序序列可以重现
+func TestRandomSeed(t *testing.T) {
+	sequence := func(seed int64) []string {
+		t.Helper()
+		tr := &Transport{
+			JA3:        "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			UserAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			RandomJA3:  true,
+			RandomSeed: seed,
+		}
+		var got []string
+		for i := 0; i < 5; i++ {
+			ja3, _, err := tr.Fingerprint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Fingerprint 不占用连接序号，再次调用结果相同
+			if again, _, _ := tr.Fingerprint(); again != ja3 {
+				t.Fatalf("#%d: Fingerprint 改变了下一个连接的结果: %v, %v", i, ja3, again)
+			}
+			got = append(got, ja3)
+			tr.connSeq.Add(1) // 模拟一次建连
+		}
+		return got
+	}
+
+	a := sequence(42)
+	if b := sequence(42); !reflect.DeepEqual(a, b) {
+		t.Errorf("相同种子的序列不同:\n%v\n%v", a, b)
+	}
+	if c := sequence(43); reflect.DeepEqual(a, c) {
+		t.Errorf("不同种子的序列相同: %v", a)
+	}
+	seen := make(map[string]bool)
+	for _, ja3 := range a {
+		seen[ja3] = true
+	}
+	if len(seen) == 1 {
+		t.Errorf("扩展顺序没有随机化: %v", a)
+	}
+}
+
+// TestRandomSeedFixedExtensions 测试带种子的随机化不移动 GREASE、padding 和 pre_shared_key
+func TestRandomSeedFixedExtensions(t *testing.T) {
+	tr := &Transport{RandomSeed: 1}
+	for i := 0; i < 20; i++ {
+		exts := []tls.TLSExtension{
+			&tls.UtlsGREASEExtension{},
+			&tls.SNIExtension{},
+			&tls.SupportedCurvesExtension{},
+			&tls.ALPNExtension{},
+			&tls.SessionTicketExtension{},
+			&tls.StatusRequestExtension{},
+			&tls.UtlsGREASEExtension{},
+			&tls.UtlsPaddingExtension{},
+		}
+		exts = (&persistConn{t: tr}).shuffleExtensions(exts)
+		for _, i := range []int{0, 6} {
+			if _, ok := exts[i].(*tls.UtlsGREASEExtension); !ok {
+				t.Fatalf("#%d: GREASE 被移动: %T", i, exts[i])
+			}
+		}
+		if _, ok := exts[7].(*tls.UtlsPaddingExtension); !ok {
+			t.Fatalf("padding 被移动: %T", exts[7])
+		}
+	}
+}
+
+// TestRandomSeedUniform 测试带种子的随机化均匀地排列可移动的扩展
+func TestRandomSeedUniform(t *testing.T) {
+	tr := &Transport{RandomSeed: 1}
+	counts := make(map[string]int)
+	const n = 6000
+	for range n {
+		exts := []tls.TLSExtension{
+			&tls.UtlsGREASEExtension{},
+			&tls.SNIExtension{},
+			&tls.ALPNExtension{},
+			&tls.StatusRequestExtension{},
+			&tls.UtlsPaddingExtension{},
+		}
+		exts = (&persistConn{t: tr}).shuffleExtensions(exts)
+		var order string
+		for _, ext := range exts[1:4] {
+			order += fmt.Sprintf("%T ", ext)
+		}
+		counts[order]++
+	}
+	if len(counts) != 6 {
+		t.Fatalf("排列数 got %d, want 6: %v", len(counts), counts)
+	}
+	for order, c := range counts {
+		if c < n/6*8/10 || c > n/6*12/10 {
+			t.Errorf("排列 %s 出现 %d 次, want 约 %d", order, c, n/6)
+		}
+	}
+}
+
+// TestTransportPermuteExtensions 测试 PermuteExtensions 为每个连接重新排列扩展且 JA4 不变
+func TestTransportPermuteExtensions(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	// 恢复会话时加入 pre_shared_key 会改变 JA3，禁用会话票据以免干扰
+	ts.TLS = &ctls.Config{SessionTicketsDisabled: true}
+	ts.StartTLS()
+	defer ts.Close()
+	for _, permute := range []bool{false, true} {
+		tr := &Transport{
+			JA3:               chromeJA4JA3,
+			UserAgent:         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"},
+			DisableKeepAlives: true,
+			PermuteExtensions: permute,
+			RandomSeed:        7,
+		}
+		ja3s := make(map[string]bool)
+		for i := 0; i < 5; i++ {
+			req, _ := NewRequest("GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			ja3s[resp.Meta.FingerprintHash] = true
+			if _, ja4, err := tr.Fingerprint(); err != nil || ja4 != chromeJA4 {
+				t.Errorf("permute %v: JA4 got %v, %v, want %v", permute, ja4, err, chromeJA4)
+			}
+		}
+		if got := len(ja3s) > 1; got != permute {
+			t.Errorf("permute %v: 5 个连接有 %d 种 JA3", permute, len(ja3s))
+		}
+	}
+}
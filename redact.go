@@ -0,0 +1,230 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// A Redactor masks secrets in HTTP messages before they are dumped or
+// logged, so that wire capture can be left enabled in production.
+// It is used by RedactHeader and RedactWire, by the Redacted dump
+// functions of package httputil and, through Transport.Redactor, by
+// the transport's debug logging.
+type Redactor interface {
+	// RedactHeader returns the value to record for the header field
+	// with the given canonical name.
+	RedactHeader(name, value string) string
+
+	// RedactBody returns the body to record. contentType is the
+	// message's Content-Type, possibly empty. RedactBody may modify
+	// body in place.
+	RedactBody(contentType string, body []byte) []byte
+}
+
+// DefaultRedactionHeaders lists the header fields masked by a
+// Redaction whose Headers field is nil.
+var DefaultRedactionHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// DefaultRedactor is the Redactor used when none is given.
+var DefaultRedactor Redactor = &Redaction{}
+
+// Redaction is a configurable Redactor.
+//
+// Values of the listed headers are replaced by Mask, with some
+// structure kept for readability: the scheme of Authorization and
+// Proxy-Authorization ("Bearer ***"), the cookie names of Cookie
+// ("a=***; b=***") and the name and attributes of Set-Cookie
+// ("id=***; Path=/; HttpOnly").
+type Redaction struct {
+	// Headers lists the header fields to mask, case-insensitively.
+	// If nil, DefaultRedactionHeaders is used.
+	Headers []string
+
+	// BodyPatterns are applied to message bodies. If a pattern has a
+	// capturing group, only the text matched by the first group is
+	// masked; otherwise the whole match is masked. For example,
+	// `"password"\s*:\s*"([^"]*)"` masks JSON password values.
+	BodyPatterns []*regexp.Regexp
+
+	// Mask replaces redacted values. If empty, "***" is used.
+	Mask string
+}
+
+func (r *Redaction) mask() string {
+	if r.Mask == "" {
+		return "***"
+	}
+	return r.Mask
+}
+
+func (r *Redaction) redacts(name string) bool {
+	headers := r.Headers
+	if headers == nil {
+		headers = DefaultRedactionHeaders
+	}
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeader implements Redactor.
+func (r *Redaction) RedactHeader(name, value string) string {
+	if !r.redacts(name) {
+		return value
+	}
+	mask := r.mask()
+	switch CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization":
+		if scheme, _, ok := strings.Cut(strings.TrimSpace(value), " "); ok {
+			return scheme + " " + mask
+		}
+	case "Cookie":
+		parts := strings.Split(value, ";")
+		for i, p := range parts {
+			if k, _, ok := strings.Cut(p, "="); ok {
+				parts[i] = k + "=" + mask
+			} else if v := strings.TrimSpace(p); v != "" {
+				// A value without a name, which browsers also send.
+				parts[i] = strings.TrimSuffix(p, strings.TrimLeft(p, " \t")) + mask
+			}
+		}
+		return strings.Join(parts, ";")
+	case "Set-Cookie":
+		k, rest, ok := strings.Cut(value, "=")
+		if !ok {
+			break
+		}
+		_, attrs, _ := strings.Cut(rest, ";")
+		if attrs != "" {
+			return k + "=" + mask + ";" + attrs
+		}
+		return k + "=" + mask
+	}
+	return mask
+}
+
+// RedactBody implements Redactor.
+func (r *Redaction) RedactBody(contentType string, body []byte) []byte {
+	mask := []byte(r.mask())
+	for _, re := range r.BodyPatterns {
+		var out []byte
+		last := 0
+		for _, m := range re.FindAllSubmatchIndex(body, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			out = append(out, body[last:start]...)
+			out = append(out, mask...)
+			last = end
+		}
+		if out != nil {
+			body = append(out, body[last:]...)
+		}
+	}
+	return body
+}
+
+// RedactHeader returns a copy of h with its values passed through r.
+// If r is nil, DefaultRedactor is used.
+func RedactHeader(h Header, r Redactor) Header {
+	if r == nil {
+		r = DefaultRedactor
+	}
+	h2 := make(Header, len(h))
+	for k, vv := range h {
+		vv2 := make([]string, len(vv))
+		for i, v := range vv {
+			vv2[i] = r.RedactHeader(CanonicalHeaderKey(k), v)
+		}
+		h2[k] = vv2
+	}
+	return h2
+}
+
+// RedactWire redacts an HTTP/1.x message in wire format, such as the
+// output of Request.Write or Response.Write, passing each header
+// field and the body through r. Trailers are treated as part of the
+// body. If r is nil, DefaultRedactor is used. msg is not modified.
+//
+// Redacting the body may change its length, so the result is meant
+// for display and not to be parsed again.
+func RedactWire(msg []byte, r Redactor) []byte {
+	if r == nil {
+		r = DefaultRedactor
+	}
+	head, body, hasBody := bytes.Cut(msg, []byte("\r\n\r\n"))
+	lines := bytes.Split(head, []byte("\r\n"))
+	var out bytes.Buffer
+	out.Grow(len(msg))
+	contentType := ""
+	for i, line := range lines {
+		if i > 0 {
+			out.WriteString("\r\n")
+			if k, v, ok := bytes.Cut(line, []byte(":")); ok {
+				name := CanonicalHeaderKey(string(bytes.TrimSpace(k)))
+				value := string(bytes.TrimSpace(v))
+				if name == "Content-Type" {
+					contentType = value
+				}
+				out.Write(k)
+				out.WriteString(": ")
+				out.WriteString(r.RedactHeader(name, value))
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	if hasBody {
+		out.WriteString("\r\n\r\n")
+		if len(body) > 0 {
+			out.Write(r.RedactBody(contentType, bytes.Clone(body)))
+		}
+	}
+	return out.Bytes()
+}
+
+// redactLogArgs returns a copy of args in which Header values and the
+// headers and trailers of *Request and *Response values are passed
+// through r, so that log lines formatting them do not leak secrets.
+// Other arguments are returned unchanged.
+func redactLogArgs(r Redactor, args []any) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		out[i] = a
+		switch v := a.(type) {
+		case Header:
+			out[i] = RedactHeader(v, r)
+		case *Request:
+			if v != nil {
+				c := *v
+				c.Header = RedactHeader(v.Header, r)
+				c.Trailer = RedactHeader(v.Trailer, r)
+				out[i] = &c
+			}
+		case *Response:
+			if v != nil {
+				c := *v
+				c.Header = RedactHeader(v.Header, r)
+				c.Trailer = RedactHeader(v.Trailer, r)
+				out[i] = &c
+			}
+		}
+	}
+	return out
+}
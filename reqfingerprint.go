@@ -0,0 +1,62 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RequestFingerprint 是单个请求使用的 TLS 指纹，见 WithFingerprint
+type RequestFingerprint struct {
+	JA3        string // JA3 字符串，不能为空
+	UserAgent  string // 用户代理字符串，用于识别浏览器类型，为空时与 Transport.UserAgent 为空时相同
+	ForceHTTP1 bool   // 只在 ALPN 中提供 HTTP/1.1
+}
+
+// fingerprintKey 是 WithFingerprint 的 context 键
+type fingerprintKey struct{}
+
+// WithFingerprint 返回带有指纹 fp 的 ctx 副本
+//
+// 使用返回的 context 的请求以 fp 代替 Transport 的 JA3、UserAgent 和 ForceHTTP1
+// (以及 ClientHelloHexStream 和 TLSFingerprint) 建立 TLS 连接，无需修改共享的
+// Transport。连接池按指纹划分，请求只复用以相同指纹建立的连接，HTTP/2 连接也是如此。
+// JA4、StrictTLS、RandomJA3 等其余指纹设置照常生效，HTTP/2 设置仍为 Transport 的设置。
+// 这样的请求不使用 Transport.HTTP3。fp.JA3 为空时等同于没有设置指纹。
+// 预设指纹见 presets.BrowserFingerprint.WithContext。
+func WithFingerprint(ctx context.Context, fp RequestFingerprint) context.Context {
+	return context.WithValue(ctx, fingerprintKey{}, fp)
+}
+
+// FingerprintFromContext 返回 WithFingerprint 在 ctx 中设置的指纹
+func FingerprintFromContext(ctx context.Context) (RequestFingerprint, bool) {
+	fp, ok := ctx.Value(fingerprintKey{}).(RequestFingerprint)
+	return fp, ok && fp.JA3 != ""
+}
+
+// poolKey 返回区分连接池的指纹摘要，fp 为 nil 时返回空
+func (fp *RequestFingerprint) poolKey() string {
+	if fp == nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(fp.JA3))
+	h.Write([]byte{0})
+	h.Write([]byte(fp.UserAgent))
+	if fp.ForceHTTP1 {
+		h.Write([]byte{0, 1})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// requestFingerprint 返回 req 使用的指纹，用于 FingerprintStats 和 ResponseMeta
+func (t *Transport) requestFingerprint(req *Request, scheme string) string {
+	if fp, ok := FingerprintFromContext(req.Context()); ok && scheme == "https" {
+		return fp.JA3
+	}
+	return t.configuredFingerprint(scheme)
+}
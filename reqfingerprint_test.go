@@ -0,0 +1,71 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTransportRequestFingerprint 测试请求指定的指纹和按指纹划分的连接池
+func TestTransportRequestFingerprint(t *testing.T) {
+	const (
+		ja3      = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+		otherJA3 = "771,4865-4867-4866-49199-49195,0-10-11-13-16-23-43-45-51-65281,29-23,0"
+	)
+	for _, h2 := range []bool{false, true} {
+		ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			io.WriteString(w, r.RemoteAddr)
+		}))
+		ts.EnableHTTP2 = h2
+		ts.StartTLS()
+		tr := &Transport{
+			JA3:               ja3,
+			ForceAttemptHTTP2: h2,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		type result struct {
+			remoteAddr string
+			meta       *ResponseMeta
+		}
+		get := func(fp *RequestFingerprint) result {
+			t.Helper()
+			ctx := context.Background()
+			if fp != nil {
+				ctx = WithFingerprint(ctx, *fp)
+			}
+			req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return result{string(b), resp.Meta}
+		}
+
+		other := &RequestFingerprint{JA3: otherJA3}
+		def, o1, o2, def2 := get(nil), get(other), get(other), get(nil)
+		if def.remoteAddr == o1.remoteAddr {
+			t.Errorf("h2 %v: 不同指纹的请求共享了连接 %s", h2, def.remoteAddr)
+		}
+		if o1.remoteAddr != o2.remoteAddr || def.remoteAddr != def2.remoteAddr {
+			t.Errorf("h2 %v: 相同指纹的请求没有复用连接: %s %s %s %s", h2, def.remoteAddr, o1.remoteAddr, o2.remoteAddr, def2.remoteAddr)
+		}
+		if def.meta.FingerprintHash == "" || def.meta.FingerprintHash == o1.meta.FingerprintHash {
+			t.Errorf("h2 %v: FingerprintHash got %q 和 %q, want 不同", h2, def.meta.FingerprintHash, o1.meta.FingerprintHash)
+		}
+		if def.meta.Fingerprint != ja3 || o1.meta.Fingerprint != otherJA3 {
+			t.Errorf("h2 %v: Fingerprint got %q 和 %q, want %q 和 %q", h2, def.meta.Fingerprint, o1.meta.Fingerprint, ja3, otherJA3)
+		}
+		tr.CloseIdleConnections()
+		ts.Close()
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync/atomic"
+)
+
+// requestIDKey is the context key for WithRequestID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+// A Transport uses it for requests made with the returned context
+// instead of generating its own, so that transport traces, logs and
+// errors can be correlated with the caller's own logs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by
+// WithRequestID, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+var (
+	requestIDPrefix  = newRequestIDPrefix()
+	requestIDCounter atomic.Uint64
+)
+
+func newRequestIDPrefix() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestID returns the ID for req: the one set with WithRequestID,
+// or a new process-unique one.
+func requestID(req *Request) string {
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return id
+	}
+	return requestIDPrefix + "-" + strconv.FormatUint(requestIDCounter.Add(1), 10)
+}
+
+// RequestIDError is returned by Transport.RoundTrip when a request
+// fails. It records the request ID so the failure can be matched with
+// trace and log output for the same request. Its Error method returns
+// the underlying error's message unchanged; use errors.As or
+// errors.Is rather than type assertions to inspect the underlying error.
+type RequestIDError struct {
+	ID  string // the request ID
+	Err error  // the underlying error
+}
+
+func (e *RequestIDError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestIDError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the underlying error is a timeout.
+func (e *RequestIDError) Timeout() bool {
+	te, ok := e.Err.(interface{ Timeout() bool })
+	return ok && te.Timeout()
+}
+
+// Temporary reports whether the underlying error is temporary.
+func (e *RequestIDError) Temporary() bool {
+	te, ok := e.Err.(interface{ Temporary() bool })
+	return ok && te.Temporary()
+}
+
+// RequestIDFromError returns the request ID recorded in err's chain,
+// if any.
+func RequestIDFromError(err error) (string, bool) {
+	var e *RequestIDError
+	if errors.As(err, &e) {
+		return e.ID, true
+	}
+	return "", false
+}
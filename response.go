@@ -120,6 +120,11 @@ type Response struct {
 	// The pointer is shared between responses and should not be
 	// modified.
 	TLS *tls.ConnectionState
+
+	// Meta describes how the Transport obtained the response: the
+	// number of attempts, connection reuse, timings, fingerprint and
+	// proxy. It is only populated by Transport.
+	Meta *ResponseMeta
 }
 
 // Cookies parses and returns the cookies set in the Set-Cookie headers.
@@ -0,0 +1,229 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// ResponseMeta describes how a Transport obtained a Response.
+// It is available as Response.Meta and spares callers from assembling
+// the same information from httptrace.ClientTrace callbacks.
+//
+// Durations are zero for steps that did not happen, such as dialing
+// on a reused connection.
+type ResponseMeta struct {
+	// Attempts is the number of times the request was sent,
+	// including retries on failed idle connections.
+	Attempts int
+
+	// RetryReasons records why each retry happened, in order: how the
+	// server closed the idle connection the previous attempt used.
+	// See Transport.KeepAliveRaces.
+	RetryReasons []RetryReason
+
+	// Reused reports whether the connection had already served
+	// another request, and WasIdle whether it came from the idle pool.
+	Reused  bool
+	WasIdle bool
+
+	// Protocol is the application protocol: "http/1.1" or "h2".
+	Protocol string
+
+	// RemoteAddr is the address of the connection's peer, which is
+	// the proxy when one is used.
+	RemoteAddr string
+
+	// Proxy is the proxy URL with any password redacted, or empty
+	// for a direct connection.
+	Proxy string
+
+	// Fingerprint is the configured TLS fingerprint (the preset name
+	// or JA3 string), as in EgressInfo.Fingerprint, and
+	// FingerprintHash the JA3 hash of the ClientHello actually sent.
+	Fingerprint     string
+	FingerprintHash string
+
+	// JA3N is the JA3N hash of the same ClientHello, as in
+	// httptrace.GotConnInfo.JA3N.
+	JA3N string
+
+	// JA4X is the JA4X fingerprint of each certificate the server
+	// presented, leaf first. See JA4X.
+	JA4X []string
+
+	// JA4L is the latency part of the server's JA4L fingerprint
+	// measured during the TLS handshake, as in
+	// httptrace.GotConnInfo.JA4L.
+	JA4L string
+
+	// JA3S and JA4S fingerprint the server's ServerHello, as in
+	// httptrace.GotConnInfo.
+	JA3S string
+	JA4S string
+
+	// ECHAccepted reports whether the connection used Encrypted
+	// Client Hello. See Transport.ECH.
+	ECHAccepted bool
+
+	// SCTs lists the Signed Certificate Timestamps the server
+	// provided and whether each was verified, as in
+	// httptrace.GotConnInfo.SCTs. See Transport.CTLogs.
+	SCTs []httptrace.SCT
+
+	// Coalesced reports whether the request was sent on an HTTP/2
+	// connection dialed for another host, and ConnHosts lists the
+	// hosts sharing the connection, as in httptrace.GotConnInfo.
+	// See Transport.HTTP2Coalescing.
+	Coalesced bool
+	ConnHosts []string
+
+	// Connect is the time to dial the TCP connection, including DNS
+	// resolution, to the server or proxy. TLSHandshake is the time of
+	// the TLS handshake with the server.
+	Connect      time.Duration
+	TLSHandshake time.Duration
+
+	// DialWait and IdleWait are how long the request was queued for
+	// a connection, as in httptrace.ConnWaitInfo. Time spent queued
+	// points at client-side limits such as MaxConnsPerHost rather
+	// than a slow server.
+	DialWait time.Duration
+	IdleWait time.Duration
+
+	// TimeToFirstByte is the time from the start of RoundTrip to the
+	// first byte of the response headers.
+	TimeToFirstByte time.Duration
+}
+
+// metaRecorder collects a ResponseMeta from trace events. Dial events
+// can arrive from a dial goroutine after the request has completed on
+// another connection, so all fields are guarded by mu.
+type metaRecorder struct {
+	t     *Transport
+	start time.Time
+
+	mu                                 sync.Mutex
+	meta                               ResponseMeta
+	connectStart, tlsStart             time.Time
+	connected, handshaken, gotResponse bool
+	handshakeFailed                    bool
+}
+
+func newMetaRecorder(t *Transport) *metaRecorder {
+	return &metaRecorder{t: t, start: t.now()}
+}
+
+// trace returns the ClientTrace that feeds r.
+func (r *metaRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			r.mu.Lock()
+			if r.connectStart.IsZero() {
+				r.connectStart = r.t.now()
+			}
+			r.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			r.mu.Lock()
+			if err == nil && !r.connected {
+				r.connected = true
+				r.meta.Connect = r.t.now().Sub(r.connectStart)
+			}
+			r.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			r.tlsStart = r.t.now()
+			r.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			r.mu.Lock()
+			if err != nil {
+				r.handshakeFailed = true
+			}
+			if err == nil && !r.handshaken {
+				r.handshaken = true
+				r.meta.TLSHandshake = r.t.now().Sub(r.tlsStart)
+			}
+			r.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.meta.Reused = info.Reused
+			r.meta.WasIdle = info.WasIdle
+			r.meta.Protocol = info.Protocol
+			r.meta.Proxy = info.Proxy
+			r.meta.FingerprintHash = info.FingerprintHash
+			r.meta.JA3N = info.JA3N
+			r.meta.JA4X = info.JA4X
+			r.meta.JA4L = info.JA4L
+			r.meta.JA3S = info.JA3S
+			r.meta.JA4S = info.JA4S
+			r.meta.ECHAccepted = info.ECHAccepted
+			r.meta.SCTs = info.SCTs
+			r.meta.Coalesced = info.Coalesced
+			r.meta.ConnHosts = info.ConnHosts
+			if info.Conn != nil && info.Conn.RemoteAddr() != nil {
+				r.meta.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+			r.mu.Unlock()
+		},
+		ConnWaitDone: func(info httptrace.ConnWaitInfo) {
+			r.mu.Lock()
+			r.meta.DialWait = info.DialWait
+			r.meta.IdleWait = info.IdleWait
+			r.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			if !r.gotResponse {
+				r.gotResponse = true
+				r.meta.TimeToFirstByte = r.t.now().Sub(r.start)
+			}
+			r.mu.Unlock()
+		},
+	}
+}
+
+// attempt records that the request is about to be sent over a
+// connection for fingerprint, resetting the per-connection state
+// left by a failed previous attempt.
+func (r *metaRecorder) attempt(fingerprint string) {
+	r.mu.Lock()
+	r.meta.Attempts++
+	r.meta.Fingerprint = fingerprint
+	r.gotResponse = false
+	r.mu.Unlock()
+}
+
+// retried records that the previous attempt failed for reason and
+// the request is being retried.
+func (r *metaRecorder) retried(reason RetryReason) {
+	r.mu.Lock()
+	r.meta.RetryReasons = append(r.meta.RetryReasons, reason)
+	r.mu.Unlock()
+}
+
+// failedHandshake reports whether a TLS handshake for the request failed.
+func (r *metaRecorder) failedHandshake() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.handshakeFailed
+}
+
+// snapshot returns a copy of the collected ResponseMeta.
+func (r *metaRecorder) snapshot() *ResponseMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.meta
+	m.RetryReasons = slices.Clone(m.RetryReasons)
+	return &m
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestResponseMetaConnWait 测试 MaxConnsPerHost 排队时间的记录
+func TestResponseMetaConnWait(t *testing.T) {
+	const hold = 50 * time.Millisecond
+	tests := []struct {
+		name              string
+		disableKeepAlives bool
+		wantDial          bool // 等待拨号许可，否则等待其他请求释放的连接
+	}{
+		{"复用释放的连接", false, false},
+		{"等待拨号许可", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				if r.URL.Path == "/slow" {
+					<-release
+				}
+				io.WriteString(w, "ok")
+			}))
+			defer srv.Close()
+			tr := &Transport{MaxConnsPerHost: 1, DisableKeepAlives: tt.disableKeepAlives}
+			defer tr.CloseIdleConnections()
+			c := &Client{Transport: tr}
+
+			first := make(chan error, 1)
+			go func() {
+				resp, err := c.Get(srv.URL + "/slow")
+				if err == nil {
+					io.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+				first <- err
+			}()
+			time.Sleep(10 * time.Millisecond) // 等第一个请求占用连接
+			time.AfterFunc(hold, func() { close(release) })
+
+			resp, err := c.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err := <-first; err != nil {
+				t.Fatal(err)
+			}
+
+			got, zero := resp.Meta.IdleWait, resp.Meta.DialWait
+			if tt.wantDial {
+				got, zero = zero, got
+			}
+			if got < hold-10*time.Millisecond || zero != 0 {
+				t.Errorf("DialWait = %v, IdleWait = %v", resp.Meta.DialWait, resp.Meta.IdleWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// RetryLimitError 在请求的自动重试次数达到 Transport.MaxRetries 后仍然失败时返回
+type RetryLimitError struct {
+	Retries int   // 已重试的次数
+	Err     error // 最后一次尝试的错误
+}
+
+func (e *RetryLimitError) Error() string {
+	return fmt.Sprintf("重试 %d 次后仍然失败: %v", e.Retries, e.Err)
+}
+
+func (e *RetryLimitError) Unwrap() error { return e.Err }
+
+// RetryStats 是 Transport 自动重试请求的统计，见 Transport.RetryStats
+type RetryStats struct {
+	Retries   int64 // 重试的总次数
+	Exhausted int64 // 因达到 MaxRetries 而失败的请求数
+}
+
+// retryCounters 记录 RetryStats，零值可以直接使用
+type retryCounters struct {
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// RetryStats 返回 t 自动重试请求的统计
+//
+// 重试发生在复用的空闲连接已被服务端关闭，或 HTTP/2 连接在请求发出前失效等情况，
+// Exhausted 持续增长通常说明连接池在频繁失效，如服务端或负载均衡器不断重启。
+func (t *Transport) RetryStats() RetryStats {
+	return RetryStats{
+		Retries:   t.retryCounters.retries.Load(),
+		Exhausted: t.retryCounters.exhausted.Load(),
+	}
+}
+
+// ExponentialRetryBackoff 可用作 Transport.RetryBackoff：第一次立即重试，
+// 之后从 10ms 开始加倍，最长 1s，带 10% 的随机抖动
+func ExponentialRetryBackoff(n int) time.Duration {
+	if n <= 1 {
+		return 0
+	}
+	d := 10 * time.Millisecond << min(n-2, 7)
+	d = min(d, time.Second)
+	return d + time.Duration(rand.Float64()*0.1*float64(d))
+}
+
+// beforeRetry 在请求第 n 次重试前调用，err 是上一次尝试的错误。超过 MaxRetries 时
+// 返回 *RetryLimitError，否则按 RetryBackoff 等待，ctx 结束时返回其原因
+func (t *Transport) beforeRetry(ctx context.Context, n int, err error) error {
+	if limit := t.MaxRetries; limit != 0 && n > max(limit, 0) {
+		t.retryCounters.exhausted.Add(1)
+		return &RetryLimitError{Retries: n - 1, Err: err}
+	}
+	t.retryCounters.retries.Add(1)
+	if t.RetryBackoff == nil {
+		return nil
+	}
+	d := t.RetryBackoff(n)
+	if d <= 0 {
+		return nil
+	}
+	tm := t.newTimer(d)
+	defer tm.Stop()
+	select {
+	case <-tm.C():
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closingServer 对每个连接的第一个请求回复 200，收到第二个请求时关闭连接，
+// 模拟不断重启的服务端。前 idle 个请求在全部到达后才回复，以便客户端留下 idle 个空闲连接
+func closingServer(t *testing.T, idle int) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var wg sync.WaitGroup
+	wg.Add(idle)
+	var mu sync.Mutex
+	n := 0
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				br := bufio.NewReader(c)
+				if _, err := nethttp.ReadRequest(br); err != nil {
+					return
+				}
+				mu.Lock()
+				n++
+				first := n <= idle
+				mu.Unlock()
+				if first {
+					wg.Done()
+					wg.Wait()
+				}
+				c.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+				nethttp.ReadRequest(br)
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+// TestTransportMaxRetries 测试重试次数上限、RetryBackoff 和 RetryStats
+func TestTransportMaxRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxRetries  int
+		wantErr     bool
+		wantBackoff []int
+		wantStats   RetryStats
+	}{
+		{"达到上限", 2, true, []int{1, 2}, RetryStats{Retries: 2, Exhausted: 1}},
+		{"默认不限制", 0, false, []int{1, 2, 3, 4, 5}, RetryStats{Retries: 5}},
+		{"不重试", -1, true, nil, RetryStats{Exhausted: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const idle = 5
+			url := closingServer(t, idle)
+			var backoff []int
+			tr := &Transport{
+				MaxRetries:          tt.maxRetries,
+				MaxIdleConnsPerHost: idle,
+				RetryBackoff: func(n int) time.Duration {
+					backoff = append(backoff, n)
+					return time.Millisecond
+				},
+			}
+			defer tr.CloseIdleConnections()
+			var wg sync.WaitGroup
+			for range idle {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, _ := NewRequest("GET", url, nil)
+					resp, err := tr.RoundTrip(req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+				}()
+			}
+			wg.Wait()
+
+			req, _ := NewRequest("GET", url, nil)
+			resp, err := tr.RoundTrip(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			var rle *RetryLimitError
+			if got := errors.As(err, &rle); got != tt.wantErr {
+				t.Fatalf("got %v, want RetryLimitError %v", err, tt.wantErr)
+			}
+			if rle != nil && (rle.Retries != max(tt.maxRetries, 0) || rle.Err == nil) {
+				t.Errorf("RetryLimitError got %+v", rle)
+			}
+			if !slices.Equal(backoff, tt.wantBackoff) {
+				t.Errorf("RetryBackoff 的调用 got %v, want %v", backoff, tt.wantBackoff)
+			}
+			if got := tr.RetryStats(); got != tt.wantStats {
+				t.Errorf("RetryStats got %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+
+	for n, want := range map[int]time.Duration{1: 0, 2: 10 * time.Millisecond, 3: 20 * time.Millisecond, 20: time.Second} {
+		if got := ExponentialRetryBackoff(n); got < want || got > want+want/10 {
+			t.Errorf("ExponentialRetryBackoff(%d) got %v, want %v 到 %v", n, got, want, want+want/10)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package robots 提供遵守 robots.txt (RFC 9309) 和读取 sitemap 的爬取工具
+//
+// Checker 通过指纹化的 http.Client 获取并缓存各主机的 robots.txt，
+// 可以单独查询，也可以作为 Transport.EgressPolicy 拒绝被禁止的请求、
+// 按 Crawl-delay 限速。FetchSitemap 和 WalkSitemaps 读取 sitemap。
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/vanling1111/tlshttp"
+)
+
+// maxRobotsSize 是读取的 robots.txt 的最大长度，RFC 9309 要求至少解析 500 KiB
+const maxRobotsSize = 500 << 10
+
+// Rules 是 robots.txt 中适用于一个爬虫的规则
+type Rules struct {
+	rules []rule
+
+	// CrawlDelay 是适用的组中的 Crawl-delay，没有时为 0
+	CrawlDelay time.Duration
+
+	// Sitemaps 是文件中所有 Sitemap 行的 URL，与组无关
+	Sitemaps []string
+}
+
+// rule 是一条 Allow 或 Disallow 规则
+type rule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp // pattern 含 * 或 $ 时非 nil
+}
+
+// Parse 解析 robots.txt 的内容 data，返回其中适用于 agent 的规则
+//
+// agent 可以是完整的 User-Agent，如 "MyBot/1.0 (+https://example.com/bot)"，
+// 只使用开头的产品名 (MyBot) 不区分大小写地匹配 User-agent 行。
+// 没有匹配的组时使用 "*" 组，也没有时不限制任何路径。
+func Parse(data []byte, agent string) *Rules {
+	agent = productToken(agent)
+	type group struct {
+		agents []string
+		rules  []rule
+		delay  time.Duration
+	}
+	var groups []*group
+	var cur *group
+	r := &Rules{}
+	inAgents := false
+	// 忽略开头的 UTF-8 BOM，否则第一行无法识别
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, maxRobotsSize)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				cur = &group{}
+				groups = append(groups, cur)
+				inAgents = true
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			continue
+		case "sitemap":
+			r.Sitemaps = append(r.Sitemaps, value)
+			continue
+		}
+		inAgents = false
+		if cur == nil {
+			continue
+		}
+		switch key {
+		case "allow", "disallow":
+			if value != "" {
+				cur.rules = append(cur.rules, newRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			if d, err := strconv.ParseFloat(value, 64); err == nil && d > 0 {
+				cur.delay = time.Duration(d * float64(time.Second))
+			}
+		}
+	}
+
+	// 匹配 agent 的所有组合并使用，没有时使用 * 组
+	for _, name := range []string{strings.ToLower(agent), "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if a == name && name != "" {
+					matched = true
+					r.rules = append(r.rules, g.rules...)
+					r.CrawlDelay = max(r.CrawlDelay, g.delay)
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return r
+}
+
+// productToken 返回 User-Agent ua 开头的产品名
+func productToken(ua string) string {
+	for i, c := range ua {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return ua[:i]
+		}
+	}
+	return ua
+}
+
+func newRule(allow bool, pattern string) rule {
+	r := rule{allow: allow, pattern: pattern}
+	if strings.ContainsAny(pattern, "*$") {
+		anchored := strings.HasSuffix(pattern, "$")
+		expr := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+		expr = "^" + strings.ReplaceAll(expr, `\*`, ".*")
+		if anchored {
+			expr += "$"
+		}
+		r.re = regexp.MustCompile(expr)
+	}
+	return r
+}
+
+func (r rule) match(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	return strings.HasPrefix(path, r.pattern)
+}
+
+// Allowed 报告是否允许访问 path (包括查询部分，如 "/search?q=1")
+//
+// 按 RFC 9309 使用匹配的规则中最长的一条，Allow 和 Disallow 一样长时允许。
+// /robots.txt 总是允许访问。
+func (r *Rules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	allow, best := true, -1
+	for _, rl := range r.rules {
+		if !rl.match(path) {
+			continue
+		}
+		if n := len(rl.pattern); n > best || (n == best && rl.allow) {
+			allow, best = rl.allow, n
+		}
+	}
+	return allow
+}
+
+// Checker 获取、缓存并按 robots.txt 检查 URL，可以被并发使用
+type Checker struct {
+	// Client 用于获取 robots.txt，为 nil 时使用 http.DefaultClient。
+	// 它的 Transport 可以使用本 Checker 的 EgressPolicy
+	Client *http.Client
+
+	// Agent 是爬虫的 User-Agent，用于选择 robots.txt 中的组，
+	// 也作为获取 robots.txt 的请求的 User-Agent (为空时不设置)
+	Agent string
+
+	// TTL 是 robots.txt 的缓存时间，为 0 时使用 24 小时。
+	// 获取失败 (网络错误、5xx、429) 时该主机的所有路径暂时禁止访问，1 分钟后重试
+	TTL time.Duration
+
+	// CrawlDelay 为 true 时，EgressPolicy 按 Crawl-delay 推迟同一主机的请求
+	CrawlDelay bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostRules
+}
+
+// hostRules 是一个主机的 robots.txt
+type hostRules struct {
+	ready   chan struct{} // 获取完成后关闭
+	rules   *Rules
+	err     error
+	expires time.Time
+	next    time.Time // Crawl-delay 允许的下一个请求的时间
+}
+
+// errTTL 是 robots.txt 获取失败时的缓存时间
+const errTTL = time.Minute
+
+var disallowAll = &Rules{rules: []rule{{pattern: "/"}}}
+
+// Rules 返回 u 所在主机的规则，缓存过期时重新获取 robots.txt；
+// 获取失败时返回禁止所有路径的规则和错误
+func (c *Checker) Rules(ctx context.Context, u *url.URL) (*Rules, error) {
+	h, err := c.host(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return h.rules, h.err
+}
+
+// Allowed 报告 robots.txt 是否允许访问 u
+func (c *Checker) Allowed(ctx context.Context, u *url.URL) (bool, error) {
+	r, err := c.Rules(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	return r.Allowed(u.RequestURI()), nil
+}
+
+// Wait 按 u 所在主机的 Crawl-delay 等待到可以发送下一个请求，没有 Crawl-delay 时立即返回
+func (c *Checker) Wait(ctx context.Context, u *url.URL) error {
+	h, err := c.host(ctx, u)
+	if err != nil {
+		return err
+	}
+	delay := h.rules.CrawlDelay
+	if delay <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := time.Now()
+	at := now
+	if h.next.After(now) {
+		at = h.next
+	}
+	h.next = at.Add(delay)
+	c.mu.Unlock()
+	if at.Equal(now) {
+		return nil
+	}
+	t := time.NewTimer(at.Sub(now))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EgressPolicy 返回一个 Transport.EgressPolicy，拒绝 robots.txt 禁止的请求，
+// CrawlDelay 为 true 时按 Crawl-delay 推迟请求。允许的请求交给 next 决定，
+// next 为 nil 时允许。被拒绝的请求返回 *http.EgressDeniedError
+func (c *Checker) EgressPolicy(next func(*http.EgressInfo) http.EgressDecision) func(*http.EgressInfo) http.EgressDecision {
+	return func(info *http.EgressInfo) http.EgressDecision {
+		// robots.txt 本身及其重定向不受限制，否则获取它时会等待自己
+		if req := info.Request; info.Phase == http.EgressBeforeRoundTrip && req != nil && req.URL.Path != "/robots.txt" && req.Context().Value(fetchKey{}) == nil {
+			ctx := req.Context()
+			allowed, err := c.Allowed(ctx, req.URL)
+			if err != nil {
+				return http.EgressDecision{Action: http.EgressDeny, Reason: err.Error()}
+			}
+			if !allowed {
+				return http.EgressDecision{Action: http.EgressDeny, Reason: "robots.txt 禁止访问 " + req.URL.RequestURI()}
+			}
+			if c.CrawlDelay {
+				if err := c.Wait(ctx, req.URL); err != nil {
+					return http.EgressDecision{Action: http.EgressDeny, Reason: err.Error()}
+				}
+			}
+		}
+		if next != nil {
+			return next(info)
+		}
+		return http.EgressDecision{}
+	}
+}
+
+// host 返回 u 所在主机的 robots.txt，并发的调用只获取一次
+func (c *Checker) host(ctx context.Context, u *url.URL) (*hostRules, error) {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	h := c.hosts[key]
+	if h != nil {
+		select {
+		case <-h.ready:
+			if time.Now().After(h.expires) {
+				h = nil
+			}
+		default:
+		}
+	}
+	if h == nil {
+		h = &hostRules{ready: make(chan struct{})}
+		if old := c.hosts[key]; old != nil {
+			h.next = old.next
+		}
+		if c.hosts == nil {
+			c.hosts = make(map[string]*hostRules)
+		}
+		c.hosts[key] = h
+		c.mu.Unlock()
+		h.rules, h.err = c.fetch(ctx, key)
+		ttl := c.TTL
+		if ttl == 0 {
+			ttl = 24 * time.Hour
+		}
+		if h.err != nil {
+			h.rules, ttl = disallowAll, errTTL
+			if ctx.Err() != nil {
+				ttl = 0 // 调用方取消的获取不缓存
+			}
+		}
+		h.expires = time.Now().Add(ttl)
+		close(h.ready)
+		return h, nil
+	}
+	c.mu.Unlock()
+	select {
+	case <-h.ready:
+		return h, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchKey 是 Checker 获取 robots.txt 的请求的 context 标记，
+// 重定向后的请求继承它，EgressPolicy 据此放行
+type fetchKey struct{}
+
+// fetch 获取 origin 的 robots.txt
+func (c *Checker) fetch(ctx context.Context, origin string) (*Rules, error) {
+	ctx = context.WithValue(ctx, fetchKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.Agent != "" {
+		req.Header.Set("User-Agent", c.Agent)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		if err != nil {
+			return nil, err
+		}
+		return Parse(data, c.Agent), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		// RFC 9309: robots.txt 不可用 (如 404) 时不限制
+		return &Rules{}, nil
+	}
+	return nil, fmt.Errorf("robots: 获取 %s/robots.txt: %s", origin, resp.Status)
+}
@@ -0,0 +1,284 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package robots
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	http "github.com/vanling1111/tlshttp"
+)
+
+const testRobots = `# 注释
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Crawl-delay: 2
+
+User-agent: MyBot
+User-agent: OtherBot
+Disallow: /
+Allow: /open
+Disallow: /*.pdf$
+Allow: /docs/*
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+// TestParse 测试按 User-agent 选择组和最长匹配规则
+func TestParse(t *testing.T) {
+	tests := []struct {
+		agent, path string
+		want        bool
+	}{
+		{"SomeBot", "/", true},
+		{"SomeBot", "/private", false},
+		{"SomeBot", "/private/x", false},
+		{"SomeBot", "/private/public/x", true},
+		{"MyBot/1.0 (+https://example.com/bot)", "/", false},
+		{"mybot", "/open/page", true},
+		{"OtherBot", "/robots.txt", true},
+		{"MyBot", "/docs/a", true},
+		{"MyBot", "/docs/a.pdf", true}, // /docs/* 和 /*.pdf$ 一样长，允许
+		{"MyBot", "/open/a.pdf", false},
+		{"MyBot", "/open/a.pdf?x=1", true},
+	}
+	for _, tt := range tests {
+		r := Parse([]byte(testRobots), tt.agent)
+		if got := r.Allowed(tt.path); got != tt.want {
+			t.Errorf("Parse(%q).Allowed(%q) got %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+
+	r := Parse([]byte(testRobots), "MyBot")
+	if r.CrawlDelay != 500*time.Millisecond {
+		t.Errorf("CrawlDelay got %v, want 500ms", r.CrawlDelay)
+	}
+	if want := []string{"https://example.com/sitemap.xml"}; !reflect.DeepEqual(r.Sitemaps, want) {
+		t.Errorf("Sitemaps got %v, want %v", r.Sitemaps, want)
+	}
+	if r := Parse(nil, "MyBot"); !r.Allowed("/anything") {
+		t.Error("空 robots.txt 应允许所有路径")
+	}
+	if r := Parse([]byte("\xef\xbb\xbfUser-agent: *\nDisallow: /\n"), "MyBot"); r.Allowed("/") {
+		t.Error("带 BOM 的 robots.txt 的第一行没有被识别")
+	}
+}
+
+// TestCheckerEgressPolicy 测试 Checker 作为 EgressPolicy 拒绝被禁止的请求并缓存 robots.txt
+func TestCheckerEgressPolicy(t *testing.T) {
+	var fetches atomic.Int32
+	var agent atomic.Value
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/robots.txt" {
+			if fetches.Add(1) == 1 {
+				agent.Store(r.Header.Get("User-Agent"))
+			}
+			io.WriteString(w, "User-agent: *\nDisallow: /admin\n")
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Checker{Client: &http.Client{Transport: tr}, Agent: "TestBot/1.0"}
+	tr.EgressPolicy = c.EgressPolicy(nil)
+	client := &http.Client{Transport: tr}
+
+	get := func(path string) error {
+		t.Helper()
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err := get("/index"); err != nil {
+		t.Fatal(err)
+	}
+	var denied *http.EgressDeniedError
+	if err := get("/admin/users"); !errors.As(err, &denied) {
+		t.Fatalf("err got %v, want *http.EgressDeniedError", err)
+	}
+	if err := get("/robots.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("robots.txt 获取了 %d 次, want 2 (一次缓存，一次直接请求)", n)
+	}
+	if got := agent.Load(); got != "TestBot/1.0" {
+		t.Errorf("User-Agent got %v, want TestBot/1.0", got)
+	}
+}
+
+// TestCheckerEgressPolicyRedirect 测试 robots.txt 重定向时获取它的请求不被 EgressPolicy 拦住
+func TestCheckerEgressPolicyRedirect(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			nethttp.Redirect(w, r, "/static/robots.txt", nethttp.StatusMovedPermanently)
+		case "/static/robots.txt":
+			io.WriteString(w, "User-agent: *\nDisallow: /admin\n")
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer ts.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Checker{Client: &http.Client{Transport: tr}}
+	tr.EgressPolicy = c.EgressPolicy(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/admin", nil)
+	_, err := (&http.Client{Transport: tr}).Do(req)
+	var denied *http.EgressDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("err got %v, want *http.EgressDeniedError", err)
+	}
+}
+
+// TestCheckerStatus 测试 robots.txt 的状态码: 4xx 不限制，5xx 禁止所有路径
+func TestCheckerStatus(t *testing.T) {
+	status := nethttp.StatusNotFound
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/page")
+	ctx := context.Background()
+
+	c := &Checker{}
+	if ok, err := c.Allowed(ctx, u); !ok || err != nil {
+		t.Errorf("404: got %v, %v, want true, nil", ok, err)
+	}
+
+	status = nethttp.StatusServiceUnavailable
+	c = &Checker{}
+	r, err := c.Rules(ctx, u)
+	if err == nil {
+		t.Error("503: 应返回错误")
+	}
+	if r.Allowed("/page") {
+		t.Error("503: 应禁止所有路径")
+	}
+}
+
+// TestCheckerWait 测试按 Crawl-delay 推迟同一主机的请求
+func TestCheckerWait(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "User-agent: *\nCrawl-delay: 0.1\n")
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/")
+	c := &Checker{}
+	start := time.Now()
+	for range 3 {
+		if err := c.Wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("3 个请求用时 %v, want >= 200ms", d)
+	}
+}
+
+// TestParseSitemap 测试解析 urlset、sitemapindex、gzip 和文本 sitemap
+func TestParseSitemap(t *testing.T) {
+	urlset := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/a </loc><lastmod>2024-05-01</lastmod><changefreq>daily</changefreq><priority>0.8</priority></url>
+  <url><loc>https://example.com/b</loc><lastmod>2024-05-01T10:20:30+02:00</lastmod></url>
+</urlset>`
+	sm, err := ParseSitemap(strings.NewReader(urlset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SitemapURL{
+		{Loc: "https://example.com/a", LastMod: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ChangeFreq: "daily", Priority: 0.8},
+		{Loc: "https://example.com/b", LastMod: time.Date(2024, 5, 1, 8, 20, 30, 0, time.UTC)},
+	}
+	if len(sm.URLs) != len(want) {
+		t.Fatalf("URLs got %+v, want %+v", sm.URLs, want)
+	}
+	for i, u := range sm.URLs {
+		if u.Loc != want[i].Loc || !u.LastMod.Equal(want[i].LastMod) || u.ChangeFreq != want[i].ChangeFreq || u.Priority != want[i].Priority {
+			t.Errorf("URLs[%d] got %+v, want %+v", i, u, want[i])
+		}
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, `<sitemapindex><sitemap><loc>https://example.com/s1.xml</loc></sitemap></sitemapindex>`)
+	zw.Close()
+	sm, err = ParseSitemap(&gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://example.com/s1.xml"}; !reflect.DeepEqual(sm.Sitemaps, want) {
+		t.Errorf("Sitemaps got %v, want %v", sm.Sitemaps, want)
+	}
+
+	sm, err = ParseSitemap(strings.NewReader("https://example.com/x\n\nhttps://example.com/y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 2 || sm.URLs[1].Loc != "https://example.com/y" {
+		t.Errorf("文本 sitemap got %+v", sm.URLs)
+	}
+
+	if _, err := ParseSitemap(strings.NewReader("<html></html>")); err == nil {
+		t.Error("未知根元素应返回错误")
+	}
+}
+
+// TestWalkSitemaps 测试递归进入 sitemap 索引且每个 sitemap 只获取一次
+func TestWalkSitemaps(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/index.xml":
+			io.WriteString(w, `<sitemapindex><sitemap><loc>`+ts.URL+`/a.xml</loc></sitemap><sitemap><loc>`+ts.URL+`/index.xml</loc></sitemap></sitemapindex>`)
+		case "/a.xml":
+			io.WriteString(w, `<urlset><url><loc>`+ts.URL+`/page1</loc></url><url><loc>`+ts.URL+`/page2</loc></url></urlset>`)
+		default:
+			nethttp.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	var got []string
+	err := WalkSitemaps(context.Background(), nil, []string{ts.URL + "/index.xml"}, func(u SitemapURL) error {
+		got = append(got, strings.TrimPrefix(u.Loc, ts.URL))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/page1", "/page2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := WalkSitemaps(context.Background(), nil, []string{ts.URL + "/missing.xml"}, func(SitemapURL) error { return nil }); err == nil {
+		t.Error("404 的 sitemap 应返回错误")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/vanling1111/tlshttp"
+)
+
+// maxSitemapSize 是 sitemap 解压后的最大长度，与 sitemaps.org 协议的限制相同
+const maxSitemapSize = 50 << 20
+
+// SitemapURL 是 sitemap 中的一个 URL
+type SitemapURL struct {
+	Loc        string
+	LastMod    time.Time // 没有或无法解析时为零
+	ChangeFreq string
+	Priority   float64 // 没有时为 0
+}
+
+// Sitemap 是一个 sitemap 文件的内容
+type Sitemap struct {
+	URLs     []SitemapURL // <urlset> 或文本 sitemap 中的 URL
+	Sitemaps []string     // <sitemapindex> 中的子 sitemap
+}
+
+// ParseSitemap 解析 XML (<urlset> 或 <sitemapindex>) 或每行一个 URL 的文本 sitemap，
+// gzip 压缩的内容会被自动解压
+func ParseSitemap(r io.Reader) (*Sitemap, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+	data, err := io.ReadAll(io.LimitReader(br, maxSitemapSize))
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '<' {
+		sm := &Sitemap{}
+		for line := range strings.Lines(string(data)) {
+			if line = strings.TrimSpace(line); line != "" {
+				sm.URLs = append(sm.URLs, SitemapURL{Loc: line})
+			}
+		}
+		return sm, nil
+	}
+
+	var doc struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc        string `xml:"loc"`
+			LastMod    string `xml:"lastmod"`
+			ChangeFreq string `xml:"changefreq"`
+			Priority   string `xml:"priority"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("robots: 解析 sitemap: %w", err)
+	}
+	if n := doc.XMLName.Local; n != "urlset" && n != "sitemapindex" {
+		return nil, fmt.Errorf("robots: 未知的 sitemap 根元素 <%s>", n)
+	}
+	sm := &Sitemap{}
+	for _, u := range doc.URLs {
+		su := SitemapURL{
+			Loc:        strings.TrimSpace(u.Loc),
+			LastMod:    parseW3CDate(strings.TrimSpace(u.LastMod)),
+			ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+		}
+		su.Priority, _ = strconv.ParseFloat(strings.TrimSpace(u.Priority), 64)
+		sm.URLs = append(sm.URLs, su)
+	}
+	for _, s := range doc.Sitemaps {
+		sm.Sitemaps = append(sm.Sitemaps, strings.TrimSpace(s.Loc))
+	}
+	return sm, nil
+}
+
+// parseW3CDate 解析 sitemap 使用的 W3C 日期时间格式
+func parseW3CDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00", time.DateOnly, "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FetchSitemap 用 client 获取并解析 url 的 sitemap，client 为 nil 时使用 http.DefaultClient
+func FetchSitemap(ctx context.Context, client *http.Client, url string) (*Sitemap, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("robots: 获取 sitemap %s: %s", url, resp.Status)
+	}
+	return ParseSitemap(resp.Body)
+}
+
+// WalkSitemaps 从 roots 开始获取 sitemap，递归进入 sitemap 索引，对每个 URL 调用 fn
+//
+// 每个 sitemap 只获取一次。fn 返回错误时停止并返回该错误；获取或解析某个 sitemap
+// 失败时也停止并返回错误。roots 通常来自 Rules.Sitemaps。
+func WalkSitemaps(ctx context.Context, client *http.Client, roots []string, fn func(SitemapURL) error) error {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		url := queue[0]
+		queue = queue[1:]
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		sm, err := FetchSitemap(ctx, client, url)
+		if err != nil {
+			return err
+		}
+		for _, u := range sm.URLs {
+			if err := fn(u); err != nil {
+				return err
+			}
+		}
+		queue = append(queue, sm.Sitemaps...)
+	}
+	return nil
+}
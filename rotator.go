@@ -0,0 +1,269 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// BlockClassifier 判断响应是否表示请求被目标站点拦截
+// 只能检查状态码和响应头，不应读取响应体
+type BlockClassifier func(resp *Response) bool
+
+// DefaultBlockClassifier 是默认的拦截判断规则
+//
+// 以下响应视为被拦截：403 和 429；带有 cf-mitigated 头或
+// Server 为 cloudflare 的 503；以及 Location 指向验证码页面的重定向。
+func DefaultBlockClassifier(resp *Response) bool {
+	switch resp.StatusCode {
+	case StatusForbidden, StatusTooManyRequests:
+		return true
+	case StatusServiceUnavailable:
+		return resp.Header.Get("Cf-Mitigated") != "" ||
+			strings.EqualFold(resp.Header.Get("Server"), "cloudflare")
+	case StatusFound, StatusSeeOther, StatusTemporaryRedirect:
+		loc := strings.ToLower(resp.Header.Get("Location"))
+		return strings.Contains(loc, "captcha") || strings.Contains(loc, "challenge")
+	}
+	return false
+}
+
+// RotationCandidate 是 FingerprintRotator 可切换到的一组指纹和代理
+type RotationCandidate struct {
+	// Fingerprint 为该候选使用的 TLS 指纹，nil 表示沿用 Base 的指纹配置
+	Fingerprint *TLSFingerprintConfig
+
+	// Proxy 为该候选使用的代理，nil 表示沿用 Base.Proxy
+	Proxy *url.URL
+}
+
+// RotationEvent 描述一次自动轮换，传给 FingerprintRotator.OnRotate
+type RotationEvent struct {
+	Host      string            // 触发轮换的目标地址 (host:port)
+	From, To  int               // 轮换前后的候选下标
+	Blocked   int               // 触发轮换时连续被拦截的次数
+	Candidate RotationCandidate // 轮换后使用的候选
+	Response  *Response         // 触发轮换的响应，其 Body 仍可读取
+}
+
+// FingerprintRotator 在目标站点连续拦截请求时自动切换指纹和代理
+//
+// 每个候选使用一个从 Base 克隆的 Transport。每个目标地址独立计数：
+// 连续 Threshold 个响应被 Classifier 判定为拦截后，该地址切换到下一个
+// 候选，关闭旧候选到该地址的空闲连接，并调用 OnRotate。
+// Base.FingerprintStats 非 nil 时按各候选指纹在该地址上的成功率选择
+// 下一个候选，见 nextLocked。
+// 未被拦截的响应会清零计数。触发轮换的响应照常返回给调用方，
+// 是否重试由调用方决定。
+//
+// FingerprintRotator 实现了 RoundTripper，可直接用作 Client.Transport。
+// 首次使用后不应再修改其字段。
+type FingerprintRotator struct {
+	// Base 是各候选 Transport 的模板，nil 表示使用零值 Transport
+	Base *Transport
+
+	// Candidates 是按顺序轮换的候选，为空时 RoundTrip 返回错误
+	Candidates []RotationCandidate
+
+	// Threshold 是触发轮换所需的连续拦截次数，零表示 3
+	Threshold int
+
+	// Classifier 判断响应是否被拦截，nil 表示 DefaultBlockClassifier
+	Classifier BlockClassifier
+
+	// OnRotate 在发生轮换时被同步调用（可选）
+	OnRotate func(RotationEvent)
+
+	mu         sync.Mutex
+	transports []*Transport
+	hosts      map[string]*hostRotation
+}
+
+// hostRotation 记录一个目标地址的轮换状态
+type hostRotation struct {
+	index   int // 当前候选下标
+	blocked int // 连续被拦截次数
+}
+
+var errNoRotationCandidates = errors.New("FingerprintRotator 没有可用的候选")
+
+// RoundTrip 使用目标地址当前的候选发送请求，并根据响应更新轮换状态
+func (r *FingerprintRotator) RoundTrip(req *Request) (*Response, error) {
+	if len(r.Candidates) == 0 {
+		req.closeBody()
+		return nil, errNoRotationCandidates
+	}
+	addr := canonicalAddr(req.URL)
+	r.mu.Lock()
+	hr := r.hostLocked(addr)
+	index := hr.index
+	t := r.transportLocked(index)
+	r.mu.Unlock()
+
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if old := r.observe(addr, index, resp); old != nil && resp.Body != nil {
+		// 触发轮换的响应所在的连接在响应体关闭后才会回到空闲池
+		resp.Body = &closeIdleOnCloseBody{ReadCloser: resp.Body, t: old, addr: addr}
+	}
+	return resp, nil
+}
+
+// closeIdleOnCloseBody 在关闭响应体后关闭 t 到 addr 的空闲连接
+type closeIdleOnCloseBody struct {
+	io.ReadCloser
+	t    *Transport
+	addr string
+}
+
+func (b *closeIdleOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.t.CloseIdleConnectionsFor(b.addr)
+	return err
+}
+
+// Current 返回目标地址 host:port 当前使用的候选下标
+func (r *FingerprintRotator) Current(addr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if hr := r.hosts[addr]; hr != nil {
+		return hr.index
+	}
+	return 0
+}
+
+// CloseIdleConnections 关闭所有候选 Transport 的空闲连接
+func (r *FingerprintRotator) CloseIdleConnections() {
+	r.mu.Lock()
+	transports := append([]*Transport(nil), r.transports...)
+	r.mu.Unlock()
+	for _, t := range transports {
+		if t != nil {
+			t.CloseIdleConnections()
+		}
+	}
+}
+
+// observe 根据 index 候选返回的 resp 更新 addr 的轮换状态
+// 发生轮换时返回被换下的 Transport
+func (r *FingerprintRotator) observe(addr string, index int, resp *Response) *Transport {
+	classify := r.Classifier
+	if classify == nil {
+		classify = DefaultBlockClassifier
+	}
+	blocked := classify(resp)
+
+	r.mu.Lock()
+	hr := r.hostLocked(addr)
+	if hr.index != index {
+		// 并发请求已经触发了轮换，旧候选的结果不再计数
+		r.mu.Unlock()
+		return nil
+	}
+	if !blocked {
+		hr.blocked = 0
+		r.mu.Unlock()
+		return nil
+	}
+	hr.blocked++
+	threshold := r.Threshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if hr.blocked < threshold {
+		r.mu.Unlock()
+		return nil
+	}
+	ev := RotationEvent{
+		Host:     addr,
+		From:     hr.index,
+		To:       r.nextLocked(addr, resp.Request.URL.Scheme, hr.index),
+		Blocked:  hr.blocked,
+		Response: resp,
+	}
+	ev.Candidate = r.Candidates[ev.To]
+	hr.index, hr.blocked = ev.To, 0
+	old := r.transports[ev.From]
+	r.mu.Unlock()
+
+	old.CloseIdleConnectionsFor(addr)
+	if r.OnRotate != nil {
+		r.OnRotate(ev)
+	}
+	return old
+}
+
+// nextLocked 返回 addr 从第 from 个候选轮换到的候选下标，r.mu 必须已持有
+//
+// Base.FingerprintStats 为 nil 时按顺序取下一个候选；否则取在 addr 上
+// 成功率最高的其他候选，没有记录的候选优先，成功率相同时按顺序。
+func (r *FingerprintRotator) nextLocked(addr, scheme string, from int) int {
+	n := len(r.Candidates)
+	next := (from + 1) % n
+	if r.Base == nil || r.Base.FingerprintStats == nil {
+		return next
+	}
+	best, bestScore := next, -1.0
+	for k := 1; k < n; k++ {
+		i := (from + k) % n
+		fp := r.transportLocked(i).configuredFingerprint(scheme)
+		score := 2.0
+		if st := r.Base.FingerprintStats.Get(fp, addr); st.Total > 0 {
+			score = st.SuccessRate()
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// hostLocked 返回 addr 的轮换状态，r.mu 必须已持有
+func (r *FingerprintRotator) hostLocked(addr string) *hostRotation {
+	hr := r.hosts[addr]
+	if hr == nil {
+		if r.hosts == nil {
+			r.hosts = make(map[string]*hostRotation)
+		}
+		hr = &hostRotation{}
+		r.hosts[addr] = hr
+	}
+	return hr
+}
+
+// transportLocked 返回第 i 个候选的 Transport，必要时从 Base 克隆，
+// r.mu 必须已持有
+func (r *FingerprintRotator) transportLocked(i int) *Transport {
+	if r.transports == nil {
+		r.transports = make([]*Transport, len(r.Candidates))
+	}
+	if t := r.transports[i]; t != nil {
+		return t
+	}
+	var t *Transport
+	if r.Base != nil {
+		t = r.Base.Clone()
+	} else {
+		t = &Transport{}
+	}
+	c := r.Candidates[i]
+	if c.Fingerprint != nil {
+		fp := *c.Fingerprint
+		t.JA3 = ""
+		t.ClientHelloHexStream = ""
+		t.TLSFingerprint = &fp
+	}
+	if c.Proxy != nil {
+		t.Proxy = ProxyURL(c.Proxy)
+	}
+	r.transports[i] = t
+	return t
+}
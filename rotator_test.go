@@ -0,0 +1,113 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestDefaultBlockClassifier 测试默认拦截判断规则
+func TestDefaultBlockClassifier(t *testing.T) {
+	tests := []struct {
+		status int
+		header map[string]string
+		want   bool
+	}{
+		{200, nil, false},
+		{403, nil, true},
+		{429, nil, true},
+		{503, nil, false},
+		{503, map[string]string{"Cf-Mitigated": "challenge"}, true},
+		{503, map[string]string{"Server": "cloudflare"}, true},
+		{302, map[string]string{"Location": "/login"}, false},
+		{302, map[string]string{"Location": "https://example.com/captcha?r=1"}, true},
+	}
+	for _, tt := range tests {
+		resp := &Response{StatusCode: tt.status, Header: Header{}}
+		for k, v := range tt.header {
+			resp.Header.Set(k, v)
+		}
+		if got := DefaultBlockClassifier(resp); got != tt.want {
+			t.Errorf("status %d %v: got %v, want %v", tt.status, tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestFingerprintRotator 测试连续拦截后自动轮换候选并关闭旧连接
+func TestFingerprintRotator(t *testing.T) {
+	var blocking atomic.Bool
+	blocking.Store(true)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if blocking.Load() {
+			w.WriteHeader(nethttp.StatusForbidden)
+		}
+		io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	var events []RotationEvent
+	r := &FingerprintRotator{
+		Candidates: []RotationCandidate{
+			{},
+			{Fingerprint: &TLSFingerprintConfig{PresetFingerprint: "firefox"}},
+		},
+		Threshold: 2,
+		OnRotate:  func(ev RotationEvent) { events = append(events, ev) },
+	}
+	defer r.CloseIdleConnections()
+	c := &Client{Transport: r}
+	get := func() {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	get()
+	if len(events) != 0 || r.Current(addr) != 0 {
+		t.Fatalf("一次拦截不应轮换: events = %v", events)
+	}
+	get()
+	if len(events) != 1 {
+		t.Fatalf("OnRotate 调用次数 = %d, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.Host != addr || ev.From != 0 || ev.To != 1 || ev.Blocked != 2 || ev.Response.StatusCode != 403 {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.Candidate.Fingerprint.PresetFingerprint != "firefox" {
+		t.Errorf("Candidate = %+v, want firefox", ev.Candidate)
+	}
+	if r.Current(addr) != 1 {
+		t.Errorf("Current = %d, want 1", r.Current(addr))
+	}
+	old := r.transports[0]
+	old.idleMu.Lock()
+	n := len(old.idleConn)
+	old.idleMu.Unlock()
+	if n != 0 {
+		t.Errorf("旧候选仍有 %d 个空闲连接", n)
+	}
+
+	// 未被拦截的响应清零计数
+	blocking.Store(false)
+	get()
+	if fp := r.transports[1].TLSFingerprint; fp == nil || fp.PresetFingerprint != "firefox" {
+		t.Errorf("候选 Transport 的指纹 = %+v", fp)
+	}
+	blocking.Store(true)
+	get()
+	if len(events) != 1 {
+		t.Errorf("OnRotate 调用次数 = %d, want 1", len(events))
+	}
+}
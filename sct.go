@@ -0,0 +1,251 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ocsp"
+)
+
+// CTLog 是一个 Certificate Transparency 日志，用于校验服务端提供的 SCT
+type CTLog struct {
+	Description string           // 日志的描述，如 "Google 'Argon2025h2' log"
+	Key         crypto.PublicKey // 日志的公钥，*ecdsa.PublicKey 或 *rsa.PublicKey
+}
+
+var (
+	oidSCTList     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2} // 证书中嵌入的 SCT
+	oidOCSPSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5} // OCSP 响应中的 SCT
+
+	errSCTUnknownLog = errors.New("SCT 来自未知的 CT 日志")
+	errSCTMalformed  = errors.New("SCT 格式错误")
+)
+
+// SCT 的 LogEntryType，见 RFC 6962 3.1 节
+const (
+	sctX509Entry    = 0
+	sctPrecertEntry = 1
+)
+
+// ctLogKey 是日志 ID 对应的日志
+type ctLogKey struct {
+	desc string
+	key  crypto.PublicKey
+}
+
+// verifySCTs 用 t.CTLogs 校验握手 cs 中服务端通过 TLS 扩展、证书和 OCSP
+// 响应提供的 SCT。TLS 扩展中的 SCT 只有 ClientHello 包含
+// signed_certificate_timestamp 扩展时服务端才会发送
+func (t *Transport) verifySCTs(cs *tls.ConnectionState) []httptrace.SCT {
+	if len(t.CTLogs) == 0 || cs == nil || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	logs := make(map[[32]byte]ctLogKey, len(t.CTLogs))
+	for _, l := range t.CTLogs {
+		der, err := x509.MarshalPKIXPublicKey(l.Key)
+		if err != nil {
+			continue
+		}
+		logs[sha256.Sum256(der)] = ctLogKey{l.Description, l.Key}
+	}
+	leaf := cs.PeerCertificates[0]
+	var issuer *x509.Certificate
+	if len(cs.PeerCertificates) > 1 {
+		issuer = cs.PeerCertificates[1]
+	}
+	now := t.now()
+
+	var out []httptrace.SCT
+	for _, raw := range cs.SignedCertificateTimestamps {
+		out = append(out, verifySCT(raw, "tls", sctX509Entry, leaf, issuer, logs, now))
+	}
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			out = append(out, verifySCTList(ext.Value, "certificate", sctPrecertEntry, leaf, issuer, logs, now)...)
+		}
+	}
+	if len(cs.OCSPResponse) > 0 && issuer != nil {
+		if resp, err := ocsp.ParseResponseForCert(cs.OCSPResponse, leaf, issuer); err == nil {
+			for _, ext := range resp.Extensions {
+				if ext.Id.Equal(oidOCSPSCTList) {
+					out = append(out, verifySCTList(ext.Value, "ocsp", sctX509Entry, leaf, issuer, logs, now)...)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// verifySCTList 校验 DER 编码的 OCTET STRING 中的 SignedCertificateTimestampList
+func verifySCTList(der []byte, source string, entryType uint16, leaf, issuer *x509.Certificate, logs map[[32]byte]ctLogKey, now time.Time) []httptrace.SCT {
+	input := cryptobyte.String(der)
+	var octets, list cryptobyte.String
+	if !input.ReadASN1(&octets, cbasn1.OCTET_STRING) || !octets.ReadUint16LengthPrefixed(&list) {
+		return []httptrace.SCT{{Source: source, Err: errSCTMalformed}}
+	}
+	var out []httptrace.SCT
+	for !list.Empty() {
+		var raw cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&raw) {
+			return append(out, httptrace.SCT{Source: source, Err: errSCTMalformed})
+		}
+		out = append(out, verifySCT(raw, source, entryType, leaf, issuer, logs, now))
+	}
+	return out
+}
+
+// verifySCT 解析并校验一个 SignedCertificateTimestamp，见 RFC 6962 3.2 节
+func verifySCT(raw []byte, source string, entryType uint16, leaf, issuer *x509.Certificate, logs map[[32]byte]ctLogKey, now time.Time) httptrace.SCT {
+	sct := httptrace.SCT{Source: source}
+	input := cryptobyte.String(raw)
+	var version, hashAlg, sigAlg uint8
+	var logID []byte
+	var ts uint64
+	var exts, sig cryptobyte.String
+	if !input.ReadUint8(&version) || !input.ReadBytes(&logID, 32) || !input.ReadUint64(&ts) ||
+		!input.ReadUint16LengthPrefixed(&exts) || !input.ReadUint8(&hashAlg) || !input.ReadUint8(&sigAlg) ||
+		!input.ReadUint16LengthPrefixed(&sig) || !input.Empty() {
+		sct.Err = errSCTMalformed
+		return sct
+	}
+	copy(sct.LogID[:], logID)
+	sct.Timestamp = time.UnixMilli(int64(ts))
+	log, ok := logs[sct.LogID]
+	if !ok {
+		sct.Err = errSCTUnknownLog
+		return sct
+	}
+	sct.Log = log.desc
+	if version != 0 {
+		sct.Err = fmt.Errorf("不支持的 SCT 版本 %d", version)
+		return sct
+	}
+	if sct.Timestamp.After(now) {
+		sct.Err = fmt.Errorf("SCT 的时间 %v 晚于当前时间", sct.Timestamp)
+		return sct
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(version)
+	b.AddUint8(0) // signature_type = certificate_timestamp
+	b.AddUint64(ts)
+	b.AddUint16(entryType)
+	if entryType == sctPrecertEntry {
+		if issuer == nil {
+			sct.Err = errors.New("服务端没有提供签发证书，无法校验嵌入的 SCT")
+			return sct
+		}
+		tbs, err := precertTBS(leaf.RawTBSCertificate)
+		if err != nil {
+			sct.Err = err
+			return sct
+		}
+		keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+		b.AddBytes(keyHash[:])
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	} else {
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(leaf.Raw) })
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(exts) })
+	signed, err := b.Bytes()
+	if err != nil {
+		sct.Err = err
+		return sct
+	}
+	sct.Err = verifySCTSignature(log.key, hashAlg, sigAlg, signed, sig)
+	return sct
+}
+
+// verifySCTSignature 校验 DigitallySigned 签名，CT 日志只使用 SHA-256
+// 的 ECDSA 和 RSA PKCS#1 v1.5 签名
+func verifySCTSignature(key crypto.PublicKey, hashAlg, sigAlg uint8, signed, sig []byte) error {
+	const sha256Alg, rsaAlg, ecdsaAlg = 4, 1, 3
+	if hashAlg != sha256Alg {
+		return fmt.Errorf("不支持的 SCT 哈希算法 %d", hashAlg)
+	}
+	digest := sha256.Sum256(signed)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if sigAlg == ecdsaAlg && ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if sigAlg == rsaAlg && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("不支持的 CT 日志公钥类型 %T", key)
+	}
+	return errors.New("SCT 签名无效")
+}
+
+// precertTBS 返回去掉 SCT 扩展后的 TBSCertificate，即日志为预证书签名的内容
+func precertTBS(raw []byte) ([]byte, error) {
+	input := cryptobyte.String(raw)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cbasn1.SEQUENCE) {
+		return nil, errSCTMalformed
+	}
+	var b cryptobyte.Builder
+	var err error
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var elem cryptobyte.String
+			var tag cbasn1.Tag
+			if !tbs.ReadAnyASN1Element(&elem, &tag) {
+				err = errSCTMalformed
+				return
+			}
+			if tag != cbasn1.Tag(3).ContextSpecific().Constructed() {
+				b.AddBytes(elem)
+				continue
+			}
+			// [3] EXPLICIT Extensions，去掉 SCT 列表扩展
+			var outer, exts cryptobyte.String
+			if !elem.ReadASN1(&outer, tag) || !outer.ReadASN1(&exts, cbasn1.SEQUENCE) {
+				err = errSCTMalformed
+				return
+			}
+			b.AddASN1(tag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !exts.Empty() {
+						var ext, body cryptobyte.String
+						var id asn1.ObjectIdentifier
+						if !exts.ReadASN1Element(&ext, cbasn1.SEQUENCE) {
+							err = errSCTMalformed
+							return
+						}
+						body = ext
+						if !body.ReadASN1(&body, cbasn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&id) {
+							err = errSCTMalformed
+							return
+						}
+						if id.Equal(oidSCTList) {
+							continue
+						}
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// signSCT 返回 key 对应的日志为 entry (按 entryType 编码的证书) 签发的 SCT
+func signSCT(t *testing.T, key *ecdsa.PrivateKey, ts time.Time, entryType uint16, entry []byte) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := sha256.Sum256(der)
+	var signed cryptobyte.Builder
+	signed.AddUint8(0)
+	signed.AddUint8(0)
+	signed.AddUint64(uint64(ts.UnixMilli()))
+	signed.AddUint16(entryType)
+	signed.AddBytes(entry)
+	signed.AddUint16(0)
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b cryptobyte.Builder
+	b.AddUint8(0)
+	b.AddBytes(logID[:])
+	b.AddUint64(uint64(ts.UnixMilli()))
+	b.AddUint16(0)
+	b.AddUint8(4) // sha256
+	b.AddUint8(3) // ecdsa
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+	return b.BytesOrPanic()
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// TestTransportSCTs 测试校验 TLS 扩展和证书中嵌入的 SCT
+func TestTransportSCTs(t *testing.T) {
+	logKey, otherLogKey := newECDSAKey(t), newECDSAKey(t)
+	caKey, leafKey := newECDSAKey(t), newECDSAKey(t)
+	now := time.Now()
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tlshttp test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	// 先签发不含 SCT 扩展的预证书，日志为它的 TBSCertificate 签名
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	}
+	preDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pre, _ := x509.ParseCertificate(preDER)
+	keyHash := sha256.Sum256(ca.RawSubjectPublicKeyInfo)
+	var precert cryptobyte.Builder
+	precert.AddBytes(keyHash[:])
+	precert.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(pre.RawTBSCertificate) })
+	embedded := signSCT(t, logKey, now.Add(-time.Minute), sctPrecertEntry, precert.BytesOrPanic())
+
+	var list cryptobyte.Builder
+	list.AddASN1(cbasn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(embedded) })
+		})
+	})
+	leafTmpl.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier(oidSCTList), Value: list.BytesOrPanic()}}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x509Entry cryptobyte.Builder
+	x509Entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(leafDER) })
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{Certificates: []ctls.Certificate{{
+		Certificate: [][]byte{leafDER, caDER},
+		PrivateKey:  leafKey,
+		SignedCertificateTimestamps: [][]byte{
+			signSCT(t, logKey, now.Add(-time.Minute), sctX509Entry, x509Entry.BytesOrPanic()),
+			signSCT(t, otherLogKey, now.Add(-time.Minute), sctX509Entry, x509Entry.BytesOrPanic()),
+			signSCT(t, logKey, now.Add(time.Hour), sctX509Entry, x509Entry.BytesOrPanic()),
+		},
+	}}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	tr := &Transport{
+		JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-18-23-43-45-51-65281,29-23-24,0",
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		CTLogs:          []CTLog{{Description: "test log", Key: &logKey.PublicKey}},
+	}
+	defer tr.CloseIdleConnections()
+	resp, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		source  string
+		log     string
+		wantErr bool
+	}{
+		{"tls", "test log", false},
+		{"tls", "", true},         // 未知的日志
+		{"tls", "test log", true}, // 时间晚于当前时间
+		{"certificate", "test log", false},
+	}
+	got := resp.Meta.SCTs
+	if len(got) != len(tests) {
+		t.Fatalf("got %d 个 SCT, want %d: %+v", len(got), len(tests), got)
+	}
+	for i, tt := range tests {
+		if got[i].Source != tt.source || got[i].Log != tt.log || (got[i].Err != nil) != tt.wantErr {
+			t.Errorf("SCTs[%d] got %+v, want source %v, log %q, err %v", i, got[i], tt.source, tt.log, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	tls "github.com/refraction-networking/utls"
+)
+
+// SessionTicketMode 决定指纹连接是否使用会话票据恢复 TLS 会话，
+// 见 TLSExtensionsConfig.SessionTickets
+type SessionTicketMode int
+
+const (
+	// SessionTicketsAuto 由 ClientHello 的扩展决定：包含 session_ticket (35)、
+	// pre_shared_key (41) 或 psk_key_exchange_modes (45) 时恢复会话，否则不恢复
+	SessionTicketsAuto SessionTicketMode = iota
+
+	// SessionTicketsEnabled 总是允许恢复会话。恢复仍然需要 ClientHello 中有对应的扩展
+	SessionTicketsEnabled
+
+	// SessionTicketsDisabled 不恢复会话，也不保存服务端发来的票据。
+	// ClientHello 中的扩展保持不变，像没有可用票据的浏览器一样发送空的 session_ticket
+	SessionTicketsDisabled
+)
+
+// sessionTicketMode 返回 TLSExtensions 或 TLSFingerprint.CustomExtensions 中的 SessionTickets
+func (t *Transport) sessionTicketMode() SessionTicketMode {
+	if t.TLSExtensions != nil && t.TLSExtensions.SessionTickets != SessionTicketsAuto {
+		return t.TLSExtensions.SessionTickets
+	}
+	if t.TLSFingerprint != nil && t.TLSFingerprint.CustomExtensions != nil {
+		return t.TLSFingerprint.CustomExtensions.SessionTickets
+	}
+	return SessionTicketsAuto
+}
+
+// sessionTicketsDisabled 报告发送 spec 的连接是否禁用会话票据。
+// cfg.SessionTicketsDisabled 总是优先
+func (t *Transport) sessionTicketsDisabled(spec *tls.ClientHelloSpec, cfg *tls.Config) bool {
+	if cfg.SessionTicketsDisabled {
+		return true
+	}
+	switch t.sessionTicketMode() {
+	case SessionTicketsEnabled:
+		return false
+	case SessionTicketsDisabled:
+		return true
+	}
+	return !specAdvertisesResumption(spec)
+}
+
+// specAdvertisesResumption 报告 spec 是否包含恢复会话使用的扩展
+func specAdvertisesResumption(spec *tls.ClientHelloSpec) bool {
+	for _, e := range spec.Extensions {
+		switch e.(type) {
+		case *tls.SessionTicketExtension, tls.PreSharedKeyExtension, *tls.PSKKeyExchangeModesExtension:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// 各浏览器 signature_algorithms (13) 扩展的内容，顺序与浏览器实际发送的一致
+var (
+	chromeSignatureAlgorithms = []tls.SignatureScheme{
+		tls.ECDSAWithP256AndSHA256,
+		tls.PSSWithSHA256,
+		tls.PKCS1WithSHA256,
+		tls.ECDSAWithP384AndSHA384,
+		tls.PSSWithSHA384,
+		tls.PKCS1WithSHA384,
+		tls.PSSWithSHA512,
+		tls.PKCS1WithSHA512,
+	}
+
+	firefoxSignatureAlgorithms = []tls.SignatureScheme{
+		tls.ECDSAWithP256AndSHA256,
+		tls.ECDSAWithP384AndSHA384,
+		tls.ECDSAWithP521AndSHA512,
+		tls.PSSWithSHA256,
+		tls.PSSWithSHA384,
+		tls.PSSWithSHA512,
+		tls.PKCS1WithSHA256,
+		tls.PKCS1WithSHA384,
+		tls.PKCS1WithSHA512,
+		tls.ECDSAWithSHA1,
+		tls.PKCS1WithSHA1,
+	}
+
+	// Safari 确实会重复发送 rsa_pss_rsae_sha384
+	safariSignatureAlgorithms = []tls.SignatureScheme{
+		tls.ECDSAWithP256AndSHA256,
+		tls.PSSWithSHA256,
+		tls.PKCS1WithSHA256,
+		tls.ECDSAWithP384AndSHA384,
+		tls.ECDSAWithSHA1,
+		tls.PSSWithSHA384,
+		tls.PSSWithSHA384,
+		tls.PKCS1WithSHA384,
+		tls.PSSWithSHA512,
+		tls.PKCS1WithSHA512,
+		tls.PKCS1WithSHA1,
+	}
+)
+
+// signatureAlgorithmsFor 返回 userAgent 所属浏览器发送的签名算法列表
+// 无法识别浏览器时按 Chrome 处理，返回的切片可以自由修改
+func signatureAlgorithmsFor(userAgent string) []tls.SignatureScheme {
+	switch userAgentFamily(userAgent) {
+	case "firefox":
+		return slices.Clone(firefoxSignatureAlgorithms)
+	case "safari":
+		return slices.Clone(safariSignatureAlgorithms)
+	}
+	return slices.Clone(chromeSignatureAlgorithms)
+}
+
+// setSignatureAlgorithms 设置扩展映射表中 13 和 50 扩展的内容
+//
+// 默认使用 userAgent 所属浏览器的签名算法，signature_algorithms_cert (50)
+// 与 signature_algorithms 相同；override 中对应字段非 nil 时以其为准。
+// 覆盖配置会被复制，避免多个连接共享同一个扩展实例。
+func setSignatureAlgorithms(extMap map[string]tls.TLSExtension, userAgent string, override *TLSExtensionsConfig) {
+	sigAlgs := signatureAlgorithmsFor(userAgent)
+	certAlgs := slices.Clone(sigAlgs)
+	if override != nil {
+		if o := override.SupportedSignatureAlgorithms; o != nil {
+			sigAlgs = slices.Clone(o.SupportedSignatureAlgorithms)
+		}
+		if o := override.SignatureAlgorithmsCert; o != nil {
+			certAlgs = slices.Clone(o.SupportedSignatureAlgorithms)
+		}
+	}
+	extMap["13"] = &tls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: sigAlgs}
+	extMap["50"] = &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: certAlgs}
+}
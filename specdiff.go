@@ -0,0 +1,204 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/refraction-networking/utls/dicttls"
+)
+
+// DifferenceKind 是 CompareSpecs 发现的差异的类别
+type DifferenceKind int
+
+const (
+	// DiffVersion 是 TLSVersMin 或 TLSVersMax 不同，Name 为 "min" 或 "max"
+	DiffVersion DifferenceKind = iota
+
+	// DiffCipherSuite 是只在一方中出现的密码套件
+	DiffCipherSuite
+
+	// DiffCipherOrder 是双方共有的密码套件顺序不同
+	DiffCipherOrder
+
+	// DiffCompression 是压缩方法列表不同
+	DiffCompression
+
+	// DiffExtension 是只在一方中出现的扩展
+	DiffExtension
+
+	// DiffExtensionOrder 是双方共有的扩展顺序不同
+	DiffExtensionOrder
+
+	// DiffExtensionParams 是同一扩展的参数不同，如 supported_groups 的组或 ALPN 的协议
+	DiffExtensionParams
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case DiffVersion:
+		return "version"
+	case DiffCipherSuite:
+		return "cipher_suite"
+	case DiffCipherOrder:
+		return "cipher_order"
+	case DiffCompression:
+		return "compression"
+	case DiffExtension:
+		return "extension"
+	case DiffExtensionOrder:
+		return "extension_order"
+	case DiffExtensionParams:
+		return "extension_params"
+	}
+	return "DifferenceKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Difference 是两个 ClientHelloSpec 之间的一处差异，见 CompareSpecs
+type Difference struct {
+	Kind DifferenceKind
+
+	// Name 是差异所在的对象：密码套件如 "0x1301 (TLS_AES_128_GCM_SHA256)"，
+	// 扩展如 "10 (supported_groups)"，版本为 "min" 或 "max"，顺序和压缩方法为空
+	Name string
+
+	// A 和 B 是该对象在两个 spec 中的值，不存在时为空。只在一方出现的密码套件和扩展
+	// 的值为 "present"；顺序差异的值是按顺序排列的共有项；参数差异的值是
+	// MarshalClientHelloSpec 格式的扩展参数
+	A, B string
+}
+
+func (d Difference) String() string {
+	s := d.Kind.String()
+	if d.Name != "" {
+		s += " " + d.Name
+	}
+	return fmt.Sprintf("%s: %q != %q", s, d.A, d.B)
+}
+
+// CompareSpecs 比较 a 和 b，返回版本、密码套件、压缩方法、扩展及其参数和顺序的差异，
+// 相同时返回空。可以用来检查配置是否与浏览器抓包的 ClientHello 一致，
+// 抓包可以用 utls 的 Fingerprinter 转换为 ClientHelloSpec
+//
+// GREASE 值视为相同；SNI、key_share 的密钥、PSK 等握手时才确定的内容不比较。
+// Chrome 110 起每个连接随机排列扩展，与 Chrome 比较时应忽略 DiffExtensionOrder。
+func CompareSpecs(a, b *tls.ClientHelloSpec) []Difference {
+	if a == nil {
+		a = &tls.ClientHelloSpec{}
+	}
+	if b == nil {
+		b = &tls.ClientHelloSpec{}
+	}
+	var diffs []Difference
+	if a.TLSVersMin != b.TLSVersMin {
+		diffs = append(diffs, Difference{DiffVersion, "min", versionString(a.TLSVersMin), versionString(b.TLSVersMin)})
+	}
+	if a.TLSVersMax != b.TLSVersMax {
+		diffs = append(diffs, Difference{DiffVersion, "max", versionString(a.TLSVersMax), versionString(b.TLSVersMax)})
+	}
+
+	cipherName := func(v uint16) string {
+		if v == specGREASE {
+			return "GREASE"
+		}
+		s := fmt.Sprintf("0x%04x", v)
+		if name, ok := dicttls.DictCipherSuiteValueIndexed[v]; ok {
+			s += " (" + name + ")"
+		}
+		return s
+	}
+	diffs = append(diffs, compareLists(specValues(a.CipherSuites), specValues(b.CipherSuites),
+		cipherName, DiffCipherSuite, DiffCipherOrder)...)
+
+	if !slices.Equal(a.CompressionMethods, b.CompressionMethods) {
+		diffs = append(diffs, Difference{Kind: DiffCompression,
+			A: fmt.Sprint(a.CompressionMethods), B: fmt.Sprint(b.CompressionMethods)})
+	}
+
+	extsA, paramsA := specExtensionKeys(a)
+	extsB, paramsB := specExtensionKeys(b)
+	diffs = append(diffs, compareLists(extsA, extsB, func(k string) string { return k },
+		DiffExtension, DiffExtensionOrder)...)
+	for _, k := range extsA {
+		if pb, ok := paramsB[k]; ok && paramsA[k] != pb {
+			diffs = append(diffs, Difference{DiffExtensionParams, k, paramsA[k], pb})
+		}
+	}
+	return diffs
+}
+
+// compareLists 比较两个无重复的列表，返回只在一方中的项 (kind) 和共有项的顺序差异 (order)
+func compareLists[T comparable](a, b []T, name func(T) string, kind, order DifferenceKind) []Difference {
+	var diffs []Difference
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			diffs = append(diffs, Difference{Kind: kind, Name: name(v), A: "present"})
+		}
+	}
+	for _, v := range b {
+		if !slices.Contains(a, v) {
+			diffs = append(diffs, Difference{Kind: kind, Name: name(v), B: "present"})
+		}
+	}
+	commonA := slices.DeleteFunc(slices.Clone(a), func(v T) bool { return !slices.Contains(b, v) })
+	commonB := slices.DeleteFunc(slices.Clone(b), func(v T) bool { return !slices.Contains(a, v) })
+	if !slices.Equal(commonA, commonB) {
+		join := func(vs []T) string {
+			names := make([]string, len(vs))
+			for i, v := range vs {
+				names[i] = name(v)
+			}
+			return strings.Join(names, ", ")
+		}
+		diffs = append(diffs, Difference{Kind: order, A: join(commonA), B: join(commonB)})
+	}
+	return diffs
+}
+
+// specExtensionKeys 返回 spec 中扩展的键 (如 "10 (supported_groups)"，多个 GREASE 扩展
+// 依次为 "GREASE"、"GREASE 2") 和每个扩展的参数
+func specExtensionKeys(spec *tls.ClientHelloSpec) ([]string, map[string]string) {
+	var keys []string
+	params := make(map[string]string)
+	grease := 0
+	for _, e := range spec.Extensions {
+		ej, err := marshalSpecExtension(e)
+		var key string
+		switch {
+		case err != nil:
+			// 无法编码的扩展只按类型比较
+			key = fmt.Sprintf("%T", e)
+		case ej.GREASE:
+			grease++
+			key = "GREASE"
+			if grease > 1 {
+				key += " " + strconv.Itoa(grease)
+			}
+		default:
+			key = strconv.Itoa(int(ej.Type))
+			if name := specExtensionName(ej.Type); name != "" {
+				key += " (" + name + ")"
+			}
+		}
+		ej.Type, ej.Name = 0, ""
+		p, _ := json.Marshal(ej)
+		keys = append(keys, key)
+		params[key] = string(p)
+	}
+	return keys, params
+}
+
+// versionString 返回 TLS 版本 v 的名称，为 0 时为空
+func versionString(v uint16) string {
+	if v == 0 {
+		return ""
+	}
+	return tls.VersionName(v)
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"slices"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestCompareSpecs 测试 ClientHelloSpec 之间的差异
+func TestCompareSpecs(t *testing.T) {
+	a, err := tls.UTLSIdToSpec(tls.HelloFirefox_120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := CompareSpecs(&a, cloneClientHelloSpec(&a)); len(diffs) != 0 {
+		t.Fatalf("相同的 spec got %v, want 无差异", diffs)
+	}
+
+	// GREASE 值和握手时填充的内容不算差异
+	g1 := &tls.ClientHelloSpec{CipherSuites: []uint16{0x0a0a, 0x1301}, Extensions: []tls.TLSExtension{
+		&tls.UtlsGREASEExtension{Value: 0x1a1a}, &tls.SNIExtension{ServerName: "a.example"},
+		&tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: 0x2a2a, Data: []byte{0}}, {Group: tls.X25519, Data: []byte{1, 2}}}},
+	}}
+	g2 := &tls.ClientHelloSpec{CipherSuites: []uint16{0xfafa, 0x1301}, Extensions: []tls.TLSExtension{
+		&tls.UtlsGREASEExtension{Value: 0x3a3a}, &tls.SNIExtension{ServerName: "b.example"},
+		&tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: 0x4a4a, Data: []byte{0}}, {Group: tls.X25519}}},
+	}}
+	if diffs := CompareSpecs(g1, g2); len(diffs) != 0 {
+		t.Errorf("GREASE 和握手内容 got %v, want 无差异", diffs)
+	}
+
+	b := cloneClientHelloSpec(&a)
+	b.TLSVersMax = tls.VersionTLS12
+	// 去掉第一个密码套件，加入一个新的，交换剩余的前两个
+	b.CipherSuites = append([]uint16{a.CipherSuites[2], a.CipherSuites[1]}, a.CipherSuites[3:]...)
+	b.CipherSuites = append(b.CipherSuites, tls.TLS_RSA_WITH_RC4_128_SHA)
+	var alpn int
+	for i, e := range b.Extensions {
+		switch e := e.(type) {
+		case *tls.SupportedCurvesExtension:
+			e.Curves = e.Curves[1:]
+		case *tls.ALPNExtension:
+			alpn = i
+		}
+	}
+	b.Extensions = slices.Delete(b.Extensions, alpn, alpn+1)
+	b.Extensions[0], b.Extensions[1] = b.Extensions[1], b.Extensions[0]
+
+	type key struct {
+		kind DifferenceKind
+		name string
+	}
+	var got []key
+	for _, d := range CompareSpecs(&a, b) {
+		got = append(got, key{d.Kind, d.Name})
+		if d.A == d.B {
+			t.Errorf("%v: A 和 B 相同", d)
+		}
+	}
+	want := []key{
+		{DiffVersion, "max"},
+		{DiffCipherSuite, "0x1301 (TLS_AES_128_GCM_SHA256)"},
+		{DiffCipherSuite, "0x0005 (TLS_RSA_WITH_RC4_128_SHA)"},
+		{DiffCipherOrder, ""},
+		{DiffExtension, "16 (application_layer_protocol_negotiation)"},
+		{DiffExtensionOrder, ""},
+		{DiffExtensionParams, "10 (supported_groups)"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("差异 got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/refraction-networking/utls/dicttls"
+)
+
+// SpecJSONSchema 是 MarshalClientHelloSpec 输出的 JSON 的 schema 标识，
+// 不兼容的格式变化会使用新的标识
+const SpecJSONSchema = "tlshttp.clienthello/v1"
+
+// specJSON 是 ClientHelloSpec 的 JSON 表示，字段说明见 MarshalClientHelloSpec
+type specJSON struct {
+	Schema             string              `json:"schema"`
+	JA4                string              `json:"ja4,omitempty"`
+	TLSVersMin         uint16              `json:"tls_vers_min"`
+	TLSVersMax         uint16              `json:"tls_vers_max"`
+	CipherSuites       []uint16            `json:"cipher_suites"`
+	CompressionMethods []uint16            `json:"compression_methods"`
+	Extensions         []specExtensionJSON `json:"extensions"`
+}
+
+// specExtensionJSON 是一个扩展的 JSON 表示
+type specExtensionJSON struct {
+	Type      uint16       `json:"type"`
+	Name      string       `json:"name,omitempty"`
+	GREASE    bool         `json:"grease,omitempty"`
+	Values    []uint16     `json:"values,omitempty"`
+	Protocols []string     `json:"protocols,omitempty"`
+	Padding   string       `json:"padding,omitempty"`
+	ECHGREASE *specECHJSON `json:"ech_grease,omitempty"`
+	Data      *string      `json:"data,omitempty"`
+}
+
+// specECHJSON 是 GREASE ECH 扩展的参数
+type specECHJSON struct {
+	CipherSuites [][2]uint16 `json:"cipher_suites"` // [KDF, AEAD]
+	PayloadLens  []uint16    `json:"payload_lens"`
+}
+
+// specExtensionNames 补充 dicttls 中没有的扩展名称
+var specExtensionNames = map[uint16]string{
+	65037: "encrypted_client_hello",
+}
+
+// specExtensionName 返回扩展类型 typ 的 IANA 名称，未知时为空
+func specExtensionName(typ uint16) string {
+	if name, ok := dicttls.DictExtTypeValueIndexed[typ]; ok {
+		return name
+	}
+	return specExtensionNames[typ]
+}
+
+// specGREASE 是 JSON 中所有 GREASE 值的统一写法，加载后由 utls 在握手时替换为随机值
+const specGREASE = tls.GREASE_PLACEHOLDER
+
+// MarshalClientHelloSpec 把 spec 序列化为稳定的 JSON，用于审计、代码审查中比较指纹，
+// 以及与非 Go 工具共享。UnmarshalClientHelloSpec 可以加载结果
+//
+// 通常序列化 Transport.BuildSpec 的结果。格式 (schema 为 SpecJSONSchema)：
+//
+//   - cipher_suites、compression_methods 和扩展中的值都是 IANA 代码点的十进制数，
+//     所有 GREASE 值写作 2570 (0x0a0a)，表示握手时生成的随机 GREASE
+//   - extensions 按发送顺序排列，每项的 type 是扩展类型，name 是 IANA 名称，只供阅读
+//   - GREASE 扩展的 grease 为 true
+//   - supported_groups、ec_point_formats、signature_algorithms、signature_algorithms_cert、
+//     supported_versions、psk_key_exchange_modes、compress_certificate、
+//     delegated_credentials 的 values 是列表中的值；key_share 的 values 是发送密钥的组，
+//     密钥在握手时生成；record_size_limit 的 values 是唯一的上限值
+//   - ALPN 和 ALPS 的 protocols 是协议列表
+//   - padding 的 padding 为 "boringssl" (按 BoringSSL 的规则填充) 或 "none"，
+//     固定长度时 values 是长度
+//   - GREASE ECH 的 ech_grease 包含候选的 HPKE 套件 [KDF, AEAD] 和负载长度
+//   - server_name、session_ticket 和 pre_shared_key 没有参数，内容在握手时填充
+//   - 其余扩展的 data 是扩展内容 (不含类型和长度) 的十六进制
+//
+// ja4 是 spec 的 JA4，只供阅读，加载时忽略。
+func MarshalClientHelloSpec(spec *tls.ClientHelloSpec) ([]byte, error) {
+	if spec == nil {
+		return nil, errors.New("spec 为 nil")
+	}
+	s := specJSON{
+		Schema:       SpecJSONSchema,
+		JA4:          ja4FieldsFromSpec(spec).ja4(),
+		TLSVersMin:   spec.TLSVersMin,
+		TLSVersMax:   spec.TLSVersMax,
+		CipherSuites: specValues(spec.CipherSuites),
+		Extensions:   make([]specExtensionJSON, 0, len(spec.Extensions)),
+	}
+	for _, m := range spec.CompressionMethods {
+		s.CompressionMethods = append(s.CompressionMethods, uint16(m))
+	}
+	for i, e := range spec.Extensions {
+		ej, err := marshalSpecExtension(e)
+		if err != nil {
+			return nil, fmt.Errorf("扩展 %d (%T): %w", i, e, err)
+		}
+		if ej.Name == "" {
+			ej.Name = specExtensionName(ej.Type)
+		}
+		s.Extensions = append(s.Extensions, ej)
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// marshalSpecExtension 返回扩展 e 的 JSON 表示
+func marshalSpecExtension(e tls.TLSExtension) (specExtensionJSON, error) {
+	switch e := e.(type) {
+	case *tls.UtlsGREASEExtension:
+		ej := specExtensionJSON{Type: specGREASE, Name: "GREASE", GREASE: true}
+		if len(e.Body) > 0 {
+			ej.Data = specHex(e.Body)
+		}
+		return ej, nil
+	case *tls.SNIExtension:
+		return specExtensionJSON{Type: 0}, nil
+	case *tls.SupportedCurvesExtension:
+		return specExtensionJSON{Type: 10, Values: specValues(e.Curves)}, nil
+	case *tls.SupportedPointsExtension:
+		return specExtensionJSON{Type: 11, Values: specValues(e.SupportedPoints)}, nil
+	case *tls.SignatureAlgorithmsExtension:
+		return specExtensionJSON{Type: 13, Values: specValues(e.SupportedSignatureAlgorithms)}, nil
+	case *tls.ALPNExtension:
+		return specExtensionJSON{Type: 16, Protocols: e.AlpnProtocols}, nil
+	case *tls.UtlsPaddingExtension:
+		switch {
+		case e.GetPaddingLen != nil:
+			return specExtensionJSON{Type: 21, Padding: "boringssl"}, nil
+		case !e.WillPad:
+			return specExtensionJSON{Type: 21, Padding: "none"}, nil
+		}
+		return specExtensionJSON{Type: 21, Values: []uint16{uint16(e.PaddingLen)}}, nil
+	case *tls.UtlsCompressCertExtension:
+		return specExtensionJSON{Type: 27, Values: specValues(e.Algorithms)}, nil
+	case *tls.FakeRecordSizeLimitExtension:
+		return specExtensionJSON{Type: 28, Values: []uint16{e.Limit}}, nil
+	case *tls.FakeDelegatedCredentialsExtension:
+		return specExtensionJSON{Type: 34, Values: specValues(e.SupportedSignatureAlgorithms)}, nil
+	case *tls.SessionTicketExtension:
+		return specExtensionJSON{Type: 35}, nil
+	case *tls.UtlsPreSharedKeyExtension, *tls.FakePreSharedKeyExtension:
+		return specExtensionJSON{Type: 41}, nil
+	case *tls.SupportedVersionsExtension:
+		return specExtensionJSON{Type: 43, Values: specValues(e.Versions)}, nil
+	case *tls.PSKKeyExchangeModesExtension:
+		return specExtensionJSON{Type: 45, Values: specValues(e.Modes)}, nil
+	case *tls.SignatureAlgorithmsCertExtension:
+		return specExtensionJSON{Type: 50, Values: specValues(e.SupportedSignatureAlgorithms)}, nil
+	case *tls.KeyShareExtension:
+		ej := specExtensionJSON{Type: 51, Values: []uint16{}}
+		for _, ks := range e.KeyShares {
+			ej.Values = append(ej.Values, specValue(uint16(ks.Group)))
+		}
+		return ej, nil
+	case *tls.ApplicationSettingsExtension:
+		return specExtensionJSON{Type: 17513, Protocols: e.SupportedProtocols}, nil
+	case *tls.ApplicationSettingsExtensionNew:
+		return specExtensionJSON{Type: 17613, Protocols: e.SupportedProtocols}, nil
+	case *tls.GREASEEncryptedClientHelloExtension:
+		ech := &specECHJSON{PayloadLens: e.CandidatePayloadLens}
+		for _, cs := range e.CandidateCipherSuites {
+			ech.CipherSuites = append(ech.CipherSuites, [2]uint16{uint16(cs.KdfId), uint16(cs.AeadId)})
+		}
+		return specExtensionJSON{Type: 65037, ECHGREASE: ech}, nil
+	case *tls.GenericExtension:
+		return specExtensionJSON{Type: e.Id, Data: specHex(e.Data)}, nil
+	}
+
+	// 其余扩展按编码保存
+	buf := make([]byte, e.Len())
+	if n, _ := e.Read(buf); n != len(buf) || n < 4 {
+		return specExtensionJSON{}, errors.New("无法编码扩展")
+	}
+	return specExtensionJSON{Type: uint16(buf[0])<<8 | uint16(buf[1]), Data: specHex(buf[4:])}, nil
+}
+
+// UnmarshalClientHelloSpec 从 MarshalClientHelloSpec 格式的 JSON 构建 ClientHelloSpec，
+// 结果可以用作 Transport.ClientHelloSpec
+//
+// data 扩展按类型使用 utls 的对应实现解析，utls 不支持的类型作为 GenericExtension 原样发送。
+// pre_shared_key 加载为 UtlsPreSharedKeyExtension，只在恢复会话时发送。
+func UnmarshalClientHelloSpec(data []byte) (*tls.ClientHelloSpec, error) {
+	var s specJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Schema != SpecJSONSchema {
+		return nil, fmt.Errorf("不支持的 schema: %q", s.Schema)
+	}
+	spec := &tls.ClientHelloSpec{
+		TLSVersMin:   s.TLSVersMin,
+		TLSVersMax:   s.TLSVersMax,
+		CipherSuites: s.CipherSuites,
+	}
+	for _, m := range s.CompressionMethods {
+		if m > 0xff {
+			return nil, fmt.Errorf("无效的压缩方法: %d", m)
+		}
+		spec.CompressionMethods = append(spec.CompressionMethods, uint8(m))
+	}
+	for i, ej := range s.Extensions {
+		e, err := unmarshalSpecExtension(ej)
+		if err != nil {
+			return nil, fmt.Errorf("扩展 %d (类型 %d): %w", i, ej.Type, err)
+		}
+		spec.Extensions = append(spec.Extensions, e)
+	}
+	return spec, nil
+}
+
+// unmarshalSpecExtension 从 JSON 表示构建扩展
+func unmarshalSpecExtension(ej specExtensionJSON) (tls.TLSExtension, error) {
+	var body []byte
+	if ej.Data != nil {
+		b, err := hex.DecodeString(*ej.Data)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 data: %w", err)
+		}
+		body = b
+	}
+	if ej.GREASE {
+		return &tls.UtlsGREASEExtension{Body: body}, nil
+	}
+	if ej.Data != nil {
+		e := tls.ExtensionFromID(ej.Type)
+		if w, ok := e.(tls.TLSExtensionWriter); ok {
+			if _, err := w.Write(body); err != nil {
+				return nil, fmt.Errorf("无效的 data: %w", err)
+			}
+			return e, nil
+		}
+		return &tls.GenericExtension{Id: ej.Type, Data: body}, nil
+	}
+
+	switch ej.Type {
+	case 0:
+		return &tls.SNIExtension{}, nil
+	case 10:
+		return &tls.SupportedCurvesExtension{Curves: specIDs[tls.CurveID](ej.Values)}, nil
+	case 11:
+		v, err := specBytes(ej.Values)
+		return &tls.SupportedPointsExtension{SupportedPoints: v}, err
+	case 13:
+		return &tls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: specIDs[tls.SignatureScheme](ej.Values)}, nil
+	case 16:
+		return &tls.ALPNExtension{AlpnProtocols: ej.Protocols}, nil
+	case 21:
+		switch ej.Padding {
+		case "boringssl":
+			return &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}, nil
+		case "none":
+			return &tls.UtlsPaddingExtension{}, nil
+		case "":
+			if len(ej.Values) == 1 {
+				return &tls.UtlsPaddingExtension{PaddingLen: int(ej.Values[0]), WillPad: true}, nil
+			}
+		}
+		return nil, fmt.Errorf("无效的 padding: %q", ej.Padding)
+	case 27:
+		return &tls.UtlsCompressCertExtension{Algorithms: specIDs[tls.CertCompressionAlgo](ej.Values)}, nil
+	case 28:
+		if len(ej.Values) != 1 {
+			return nil, errors.New("record_size_limit 需要一个值")
+		}
+		return &tls.FakeRecordSizeLimitExtension{Limit: ej.Values[0]}, nil
+	case 34:
+		return &tls.FakeDelegatedCredentialsExtension{SupportedSignatureAlgorithms: specIDs[tls.SignatureScheme](ej.Values)}, nil
+	case 35:
+		return &tls.SessionTicketExtension{}, nil
+	case 41:
+		return &tls.UtlsPreSharedKeyExtension{}, nil
+	case 43:
+		return &tls.SupportedVersionsExtension{Versions: ej.Values}, nil
+	case 45:
+		v, err := specBytes(ej.Values)
+		return &tls.PSKKeyExchangeModesExtension{Modes: v}, err
+	case 50:
+		return &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: specIDs[tls.SignatureScheme](ej.Values)}, nil
+	case 51:
+		ks := &tls.KeyShareExtension{KeyShares: []tls.KeyShare{}}
+		for _, g := range ej.Values {
+			share := tls.KeyShare{Group: tls.CurveID(g)}
+			if isGREASEValue(g) {
+				share.Data = []byte{0}
+			}
+			ks.KeyShares = append(ks.KeyShares, share)
+		}
+		return ks, nil
+	case 17513:
+		return &tls.ApplicationSettingsExtension{SupportedProtocols: ej.Protocols}, nil
+	case 17613:
+		return &tls.ApplicationSettingsExtensionNew{SupportedProtocols: ej.Protocols}, nil
+	case 65037:
+		if ej.ECHGREASE == nil {
+			return nil, errors.New("缺少 ech_grease")
+		}
+		e := &tls.GREASEEncryptedClientHelloExtension{CandidatePayloadLens: ej.ECHGREASE.PayloadLens}
+		for _, cs := range ej.ECHGREASE.CipherSuites {
+			e.CandidateCipherSuites = append(e.CandidateCipherSuites, tls.HPKESymmetricCipherSuite{
+				KdfId:  tls.HPKE_KDF_ID(cs[0]),
+				AeadId: tls.HPKE_AEAD_ID(cs[1]),
+			})
+		}
+		return e, nil
+	}
+	return nil, errors.New("缺少 data")
+}
+
+// specValue 把 GREASE 值统一为 specGREASE
+func specValue(v uint16) uint16 {
+	if isGREASEValue(v) {
+		return specGREASE
+	}
+	return v
+}
+
+// specValues 把代码点列表转换为 JSON 中的值，GREASE 值统一为 specGREASE
+func specValues[T ~uint8 | ~uint16](vs []T) []uint16 {
+	out := make([]uint16, len(vs))
+	for i, v := range vs {
+		out[i] = specValue(uint16(v))
+	}
+	return out
+}
+
+// specIDs 把 JSON 中的值转换为代码点列表
+func specIDs[T ~uint16](vs []uint16) []T {
+	out := make([]T, len(vs))
+	for i, v := range vs {
+		out[i] = T(v)
+	}
+	return out
+}
+
+// specBytes 把 JSON 中的值转换为单字节代码点列表
+func specBytes(vs []uint16) ([]uint8, error) {
+	out := make([]uint8, len(vs))
+	for i, v := range vs {
+		if v > 0xff {
+			return nil, fmt.Errorf("值 %d 超出单字节范围", v)
+		}
+		out[i] = uint8(v)
+	}
+	return out, nil
+}
+
+// specHex 返回 b 的十六进制
+func specHex(b []byte) *string {
+	s := hex.EncodeToString(b)
+	return &s
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestClientHelloSpecJSON 测试 ClientHelloSpec 的 JSON 导出和加载
+func TestClientHelloSpecJSON(t *testing.T) {
+	fingerprint := func(spec *tls.ClientHelloSpec) (string, string) {
+		t.Helper()
+		tr := &Transport{ClientHelloSpec: spec, TLSClientConfig: &tls.Config{ServerName: "example.com"}}
+		ja3, ja4, err := tr.Fingerprint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ja3, ja4
+	}
+
+	ja3Transport := &Transport{JA3: "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"}
+	fromJA3, err := ja3Transport.BuildSpec("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := map[string]*tls.ClientHelloSpec{"JA3": fromJA3}
+	for _, id := range []tls.ClientHelloID{tls.HelloChrome_133, tls.HelloFirefox_120, tls.HelloSafari_16_0} {
+		spec, err := tls.UTLSIdToSpec(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs[id.Str()] = &spec
+	}
+	// 没有专门表示的扩展按编码保存；pre_shared_key 必须在最后
+	specs["JA3"].Extensions = slices.Insert(specs["JA3"].Extensions, 1, []tls.TLSExtension{
+		&tls.StatusRequestExtension{}, &tls.GenericExtension{Id: 0xfe0d + 1, Data: []byte{1, 2}},
+		&tls.UtlsPaddingExtension{PaddingLen: 7, WillPad: true}}...)
+
+	for name, spec := range specs {
+		data, err := MarshalClientHelloSpec(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded, err := UnmarshalClientHelloSpec(data)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, data)
+		}
+		again, err := MarshalClientHelloSpec(loaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("%s: 加载后再导出不一致\ngot  %s\nwant %s", name, again, data)
+		}
+		ja3, ja4 := fingerprint(spec)
+		if gotJA3, gotJA4 := fingerprint(loaded); gotJA3 != ja3 || gotJA4 != ja4 {
+			t.Errorf("%s: 指纹 got %s %s, want %s %s", name, gotJA3, gotJA4, ja3, ja4)
+		}
+	}
+
+	// 输出便于阅读：扩展带有名称，GREASE 值统一
+	data, _ := MarshalClientHelloSpec(specs[tls.HelloChrome_133.Str()])
+	for _, s := range []string{`"schema": "tlshttp.clienthello/v1"`, `"name": "key_share"`, `"grease": true`, `"ech_grease"`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("导出的 JSON 中没有 %s", s)
+		}
+	}
+}
+
+// TestUnmarshalClientHelloSpecErrors 测试无效 JSON 的错误
+func TestUnmarshalClientHelloSpecErrors(t *testing.T) {
+	valid := func(exts ...specExtensionJSON) []byte {
+		b, _ := json.Marshal(specJSON{Schema: SpecJSONSchema, CipherSuites: []uint16{4865}, Extensions: exts})
+		return b
+	}
+	hex := "zz"
+	for name, data := range map[string][]byte{
+		"schema":            []byte(`{"schema":"other/v2"}`),
+		"padding":           valid(specExtensionJSON{Type: 21, Padding: "random"}),
+		"point_formats":     valid(specExtensionJSON{Type: 11, Values: []uint16{256}}),
+		"record_size_limit": valid(specExtensionJSON{Type: 28}),
+		"data":              valid(specExtensionJSON{Type: 23, Data: &hex}),
+		"no_params":         valid(specExtensionJSON{Type: 23}),
+	} {
+		if _, err := UnmarshalClientHelloSpec(data); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	if _, err := MarshalClientHelloSpec(nil); err == nil {
+		t.Error("nil spec 应返回错误")
+	}
+}
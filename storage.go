@@ -0,0 +1,344 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// Storage 是有状态组件的持久化存储，使 Cookie、TLS 会话、Alt-Svc、缓存验证器和
+// 指纹统计可以在进程重启后保留，或在多个进程之间共享
+//
+// 各组件使用不同的命名空间 (见 StorageNamespaceCookies 等)，值的格式由组件决定。
+// 实现必须可以被并发使用。
+type Storage interface {
+	// Get 返回 namespace 中 key 的值，不存在或已过期时 ok 为 false
+	Get(namespace, key string) (value []byte, ok bool, err error)
+
+	// Set 保存 namespace 中 key 的值，ttl 大于 0 时在 ttl 之后过期，否则不过期
+	Set(namespace, key string, value []byte, ttl time.Duration) error
+
+	// Delete 删除 namespace 中的 key，key 不存在时不是错误
+	Delete(namespace, key string) error
+}
+
+// StorageLister 是可以列出命名空间中所有键的 Storage。
+// FingerprintStats 用它在首次使用时加载之前的统计
+type StorageLister interface {
+	Storage
+
+	// Keys 返回 namespace 中所有未过期的键，顺序不定
+	Keys(namespace string) ([]string, error)
+}
+
+// 各组件使用的 Storage 命名空间
+const (
+	StorageNamespaceCookies          = "cookies"           // cookiejar.Options.Storage，键为 eTLD+1
+	StorageNamespaceTLSSessions      = "tls-sessions"      // Transport.Storage，键为 TLS 会话缓存键
+	StorageNamespaceAltSvc           = "alt-svc"           // Transport.Storage，键为源站 host:port
+	StorageNamespaceValidators       = "validators"        // ValidatorCache.Storage，键为 URL
+	StorageNamespaceFingerprintStats = "fingerprint-stats" // FingerprintStats.Storage
+)
+
+// MemoryStorage 是保存在内存中的 Storage，用于测试或在同一进程的多个组件之间共享状态。
+// 零值可以直接使用，并发安全
+type MemoryStorage struct {
+	mu    sync.Mutex
+	items map[string]map[string]memoryItem // 命名空间 -> 键 -> 值
+
+	now func() time.Time // 测试用，nil 表示 time.Now
+}
+
+type memoryItem struct {
+	value   []byte
+	expires time.Time // 零值表示不过期
+}
+
+func (s *MemoryStorage) timeNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// Get 实现 Storage
+func (s *MemoryStorage) Get(namespace, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[namespace][key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !it.expires.IsZero() && !s.timeNow().Before(it.expires) {
+		delete(s.items[namespace], key)
+		return nil, false, nil
+	}
+	return append([]byte(nil), it.value...), true, nil
+}
+
+// Set 实现 Storage
+func (s *MemoryStorage) Set(namespace, key string, value []byte, ttl time.Duration) error {
+	it := memoryItem{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		it.expires = s.timeNow().Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = make(map[string]map[string]memoryItem)
+	}
+	ns := s.items[namespace]
+	if ns == nil {
+		ns = make(map[string]memoryItem)
+		s.items[namespace] = ns
+	}
+	ns[key] = it
+	return nil
+}
+
+// Delete 实现 Storage
+func (s *MemoryStorage) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items[namespace], key)
+	return nil
+}
+
+// Keys 实现 StorageLister
+func (s *MemoryStorage) Keys(namespace string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timeNow()
+	var keys []string
+	for k, it := range s.items[namespace] {
+		if !it.expires.IsZero() && !now.Before(it.expires) {
+			delete(s.items[namespace], k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// FileStorage 是保存在目录 Dir 中的 Storage，每个命名空间一个子目录，每个键一个文件。
+// 写入先写临时文件再重命名，多个进程可以共享同一目录。
+// 过期的键在读取时删除
+type FileStorage struct {
+	// Dir 是存储的根目录，不存在时自动创建
+	Dir string
+
+	now func() time.Time // 测试用，nil 表示 time.Now
+}
+
+// fileItem 是 FileStorage 中一个文件的内容
+type fileItem struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func (s *FileStorage) timeNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// nsDir 返回命名空间的目录
+func (s *FileStorage) nsDir(namespace string) (string, error) {
+	if namespace == "" || namespace == "." || namespace == ".." || strings.ContainsAny(namespace, `/\`) {
+		return "", fmt.Errorf("FileStorage: 无效的命名空间 %q", namespace)
+	}
+	return filepath.Join(s.Dir, namespace), nil
+}
+
+// path 返回键的文件路径，文件名为键的 SHA-256，避免键中的特殊字符
+func (s *FileStorage) path(namespace, key string) (string, error) {
+	dir, err := s.nsDir(namespace)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])), nil
+}
+
+// read 读取文件 name，过期时删除文件并返回 ok 为 false
+func (s *FileStorage) read(name string) (it fileItem, ok bool, err error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return it, false, nil
+	}
+	if err != nil {
+		return it, false, err
+	}
+	if err := json.Unmarshal(data, &it); err != nil {
+		return it, false, fmt.Errorf("FileStorage: %s: %w", name, err)
+	}
+	if !it.Expires.IsZero() && !s.timeNow().Before(it.Expires) {
+		os.Remove(name)
+		return it, false, nil
+	}
+	return it, true, nil
+}
+
+// Get 实现 Storage
+func (s *FileStorage) Get(namespace, key string) ([]byte, bool, error) {
+	name, err := s.path(namespace, key)
+	if err != nil {
+		return nil, false, err
+	}
+	it, ok, err := s.read(name)
+	if !ok || it.Key != key {
+		return nil, false, err
+	}
+	return it.Value, true, nil
+}
+
+// Set 实现 Storage
+func (s *FileStorage) Set(namespace, key string, value []byte, ttl time.Duration) error {
+	name, err := s.path(namespace, key)
+	if err != nil {
+		return err
+	}
+	it := fileItem{Key: key, Value: value}
+	if ttl > 0 {
+		it.Expires = s.timeNow().Add(ttl)
+	}
+	data, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete 实现 Storage
+func (s *FileStorage) Delete(namespace, key string) error {
+	name, err := s.path(namespace, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Keys 实现 StorageLister
+func (s *FileStorage) Keys(namespace string) ([]string, error) {
+	dir, err := s.nsDir(namespace)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range ents {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		it, ok, err := s.read(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			keys = append(keys, it.Key)
+		}
+	}
+	return keys, nil
+}
+
+// storedSessionTTL 是保存在 Storage 中的 TLS 会话的有效期，即 TLS 1.3 票据的最长有效期。
+// 服务端拒绝过期的票据时握手照常完成，只是不恢复会话
+const storedSessionTTL = 7 * 24 * time.Hour
+
+// storageSessionCache 是同时保存在 Storage 中的 TLS 会话缓存，
+// mem 中没有的会话从 Storage 加载
+type storageSessionCache struct {
+	mem     tls.ClientSessionCache
+	storage Storage
+}
+
+// storedSession 是 Storage 中一个 TLS 会话的记录
+type storedSession struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"` // tls.SessionState.Bytes
+}
+
+func (c storageSessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	if cs, ok := c.mem.Get(key); ok {
+		return cs, true
+	}
+	data, ok, err := c.storage.Get(StorageNamespaceTLSSessions, key)
+	if !ok || err != nil {
+		return nil, false
+	}
+	var stored storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, false
+	}
+	state, err := tls.ParseSessionState(stored.State)
+	if err != nil {
+		return nil, false
+	}
+	cs, err := tls.NewResumptionState(stored.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	c.mem.Put(key, cs)
+	return cs, true
+}
+
+func (c storageSessionCache) Put(key string, cs *tls.ClientSessionState) {
+	c.mem.Put(key, cs)
+	if cs == nil {
+		c.storage.Delete(StorageNamespaceTLSSessions, key)
+		return
+	}
+	ticket, state, err := cs.ResumptionState()
+	if err != nil || state == nil {
+		return
+	}
+	b, err := state.Bytes()
+	if err != nil {
+		return
+	}
+	data, _ := json.Marshal(storedSession{Ticket: ticket, State: b})
+	c.storage.Set(StorageNamespaceTLSSessions, key, data, storedSessionTTL)
+}
@@ -0,0 +1,187 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestStorage 测试 MemoryStorage 和 FileStorage 的 Get、Set、Delete、过期和 Keys
+func TestStorage(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	for name, s := range map[string]StorageLister{
+		"MemoryStorage": &MemoryStorage{now: clock},
+		"FileStorage":   &FileStorage{Dir: t.TempDir(), now: clock},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := s.Get("a", "k"); ok || err != nil {
+				t.Fatalf("空存储 got %v, %v", ok, err)
+			}
+			if err := s.Set("a", "k", []byte("v1"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Set("a", "k/../tmp", []byte("v2"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Set("b", "k", []byte("other"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if v, ok, _ := s.Get("a", "k"); !ok || string(v) != "v1" {
+				t.Errorf("Get got %q, %v, want v1", v, ok)
+			}
+			if v, _, _ := s.Get("b", "k"); string(v) != "other" {
+				t.Errorf("命名空间 b got %q, want other", v)
+			}
+			keys, err := s.Keys("a")
+			slices.Sort(keys)
+			if err != nil || !slices.Equal(keys, []string{"k", "k/../tmp"}) {
+				t.Errorf("Keys got %q, %v", keys, err)
+			}
+
+			now = now.Add(time.Minute)
+			if _, ok, _ := s.Get("a", "k/../tmp"); ok {
+				t.Error("过期的键仍然存在")
+			}
+			if keys, _ := s.Keys("a"); !slices.Equal(keys, []string{"k"}) {
+				t.Errorf("过期后 Keys got %q", keys)
+			}
+
+			if err := s.Delete("a", "k"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete("a", "missing"); err != nil {
+				t.Errorf("删除不存在的键: %v", err)
+			}
+			if _, ok, _ := s.Get("a", "k"); ok {
+				t.Error("删除的键仍然存在")
+			}
+		})
+	}
+
+	fs := &FileStorage{Dir: t.TempDir()}
+	for _, ns := range []string{"", "..", "a/b"} {
+		if err := fs.Set(ns, "k", nil, 0); err == nil {
+			t.Errorf("命名空间 %q: 期望错误", ns)
+		}
+	}
+}
+
+// TestStorageSessionCache 测试共享 Storage 的 Transport 恢复另一个 Transport 的 TLS 会话
+func TestStorageSessionCache(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.TLS.DidResume {
+			w.Header().Set("X-Resumed", "1")
+		}
+	}))
+	defer ts.Close()
+
+	storage := &FileStorage{Dir: t.TempDir()}
+	for i, want := range []bool{false, true} {
+		tr := &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+			Storage:           storage,
+		}
+		resp, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tr.CloseIdleConnections()
+		if got := resp.Header.Get("X-Resumed") != ""; got != want {
+			t.Errorf("第 %d 个 Transport 恢复会话 got %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestAltSvcStorage(t *testing.T) {
+	storage := &MemoryStorage{}
+	now := time.Now()
+	var a, b altSvcCache
+	a.update("example.com:443", `h3=":8443"; ma=60`, now, storage)
+	if got, ok := b.lookup("example.com:443", now, storage); !ok || got != "example.com:8443" {
+		t.Errorf("got %q, %v, want example.com:8443", got, ok)
+	}
+	if _, ok := b.lookup("example.com:443", now.Add(2*time.Minute), storage); ok {
+		t.Error("过期的服务仍然可用")
+	}
+	a.update("example.com:443", "clear", now, storage)
+	var c altSvcCache
+	if _, ok := c.lookup("example.com:443", now, storage); ok {
+		t.Error("clear 后仍然可用")
+	}
+}
+
+func TestValidatorCacheStorage(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		got = append(got, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(304)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer ts.Close()
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	storage := &MemoryStorage{}
+
+	for range 2 {
+		c := &ValidatorCache{Base: tr, Storage: storage}
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := c.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	if !slices.Equal(got, []string{"", `"v1"`}) {
+		t.Errorf("If-None-Match got %q", got)
+	}
+
+	c := &ValidatorCache{Storage: storage}
+	c.Forget(ts.URL)
+	if _, ok := (&ValidatorCache{Storage: storage}).Get(ts.URL); ok {
+		t.Error("Forget 后 Storage 中仍有记录")
+	}
+}
+
+func TestFingerprintStatsStorage(t *testing.T) {
+	storage := &MemoryStorage{}
+	a := &FingerprintStats{Storage: storage}
+	a.Record("chrome", "a.com:443", OutcomeSuccess)
+	a.Record("chrome", "b.com:443", OutcomeForbidden)
+
+	b := &FingerprintStats{Storage: storage}
+	b.Record("chrome", "a.com:443", OutcomeSuccess)
+	if s := b.Get("chrome", "a.com:443"); s.Total != 2 || s.Success != 2 {
+		t.Errorf("got %+v, want 2 次成功", s)
+	}
+	if s := b.Get("chrome", ""); s.Total != 3 || s.Forbidden != 1 {
+		t.Errorf("汇总 got %+v, want 3 次", s)
+	}
+
+	// 没有 Keys 的 Storage 按需加载单个组合
+	c := &FingerprintStats{Storage: storageOnly{storage}}
+	if s := c.Get("chrome", "b.com:443"); s.Forbidden != 1 {
+		t.Errorf("got %+v, want 1 次 403", s)
+	}
+
+	b.Reset()
+	if s := (&FingerprintStats{Storage: storage}).Get("chrome", ""); s.Total != 0 {
+		t.Errorf("Reset 后 got %+v", s)
+	}
+}
+
+// storageOnly 隐藏 StorageLister 的 Keys 方法
+type storageOnly struct{ Storage }
@@ -0,0 +1,79 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrStopStream 可以由 Client.Stream 的回调返回，表示正常地提前结束读取，
+// 此时 Stream 返回的错误为 nil
+var ErrStopStream = errors.New("tlshttp: stop stream")
+
+const (
+	// streamChunkSize 是 Client.Stream 每次读取的最大长度
+	streamChunkSize = 32 << 10
+
+	// streamDrainLimit 是提前结束时为复用连接最多丢弃的剩余响应体长度
+	streamDrainLimit = 256 << 10
+)
+
+// Stream 发送 req，并把响应体逐块交给 fn，适合下载大文件而不把整个响应体放进内存
+//
+// fn 同步调用，处理完一块才读取下一块，慢的 fn 通过 TCP 或 HTTP/2 的流量控制
+// 减慢服务端的发送。chunk 在 fn 返回后会被复用，需要保留时应复制。
+// 任何状态码的响应体都会交给 fn，需要先检查状态码时应使用 Do。
+//
+// fn 返回错误时停止读取，返回 ErrStopStream 时 Stream 返回 nil 错误，否则返回该错误。
+// 提前结束时，剩余响应体不超过 256 KiB 的 HTTP/1.1 连接会读完剩余部分后回到连接池，
+// 更长的响应体关闭连接；HTTP/2 只重置该流，连接照常复用。
+// 取消 req 的 context 同样会结束读取，并返回 context 的错误。
+//
+// 返回的 resp 的 Body 已被关闭，其余字段 (StatusCode、Header、Trailer 等) 可以照常使用。
+// 发送失败时 resp 为 nil，否则即使 err 非 nil 也返回 resp。
+func (c *Client) Stream(req *Request, fn func(chunk []byte) error) (*Response, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, streamChunkSize)
+	var read int64
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			read += int64(n)
+			if err := fn(buf[:n]); err != nil {
+				drainStreamBody(resp, read)
+				if err == ErrStopStream {
+					err = nil
+				}
+				return resp, err
+			}
+		}
+		// 读到 EOF 或取消前已读入的数据不会让 Read 报告取消，需要单独检查
+		if err := resp.Request.Context().Err(); err != nil {
+			resp.Body.Close()
+			return resp, err
+		}
+		if rerr == io.EOF {
+			return resp, resp.Body.Close()
+		}
+		if rerr != nil {
+			resp.Body.Close()
+			return resp, rerr
+		}
+	}
+}
+
+// drainStreamBody 在剩余部分不超过 streamDrainLimit 时读完 resp 的响应体以复用连接，
+// 再关闭它。read 是已经读取的长度
+func drainStreamBody(resp *Response, read int64) {
+	tooLong := resp.ContentLength >= 0 && resp.ContentLength-read > streamDrainLimit
+	if resp.ProtoMajor == 1 && !tooLong && resp.Request.Context().Err() == nil {
+		io.CopyN(io.Discard, resp.Body, streamDrainLimit)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// TestClientStream 测试逐块读取、提前结束后复用连接和回调错误
+func TestClientStream(t *testing.T) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+		w.Write(bytes.Repeat([]byte("x"), n))
+	}))
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	stream := func(n int, fn func([]byte) error) (*Response, error) {
+		t.Helper()
+		req, _ := NewRequest("GET", ts.URL+"?n="+strconv.Itoa(n), nil)
+		return c.Stream(req, fn)
+	}
+
+	// 完整读取
+	total := 0
+	resp, err := stream(100<<10, func(b []byte) error {
+		total += len(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 100<<10 || resp.StatusCode != 200 {
+		t.Errorf("got %d 字节, 状态码 %d, want %d, 200", total, resp.StatusCode, 100<<10)
+	}
+
+	// 提前结束，剩余部分较短时连接回到连接池
+	resp, err = stream(100<<10, func([]byte) error { return ErrStopStream })
+	if err != nil {
+		t.Fatalf("ErrStopStream: err got %v, want nil", err)
+	}
+	if !resp.Meta.Reused {
+		t.Error("完整读取后连接未被复用")
+	}
+	resp, err = stream(10, func([]byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Meta.Reused {
+		t.Error("提前结束后连接未被复用")
+	}
+
+	// 回调的错误原样返回，剩余部分过长时不复用连接
+	errAbort := errors.New("abort")
+	if _, err := stream(4<<20, func([]byte) error { return errAbort }); err != errAbort {
+		t.Errorf("err got %v, want %v", err, errAbort)
+	}
+	resp, err = stream(10, func([]byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Meta.Reused {
+		t.Error("剩余部分过长时连接不应被复用")
+	}
+
+	// 回调中取消 context 结束读取，包括取消时响应体已经读完的情况
+	for _, n := range []int{4 << 20, 10} {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := NewRequestWithContext(ctx, "GET", ts.URL+"?n="+strconv.Itoa(n), nil)
+		calls := 0
+		_, err = c.Stream(req, func([]byte) error {
+			calls++
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("n=%d: 取消后 err got %v、回调 %d 次, want context.Canceled、1 次", n, err, calls)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import "fmt"
+
+// HTTP2ErrCode 是 HTTP/2 的错误码 (RFC 9113 第 7 节)
+type HTTP2ErrCode uint32
+
+const (
+	HTTP2ErrCodeNo                 = HTTP2ErrCode(http2ErrCodeNo)
+	HTTP2ErrCodeProtocol           = HTTP2ErrCode(http2ErrCodeProtocol)
+	HTTP2ErrCodeInternal           = HTTP2ErrCode(http2ErrCodeInternal)
+	HTTP2ErrCodeFlowControl        = HTTP2ErrCode(http2ErrCodeFlowControl)
+	HTTP2ErrCodeSettingsTimeout    = HTTP2ErrCode(http2ErrCodeSettingsTimeout)
+	HTTP2ErrCodeStreamClosed       = HTTP2ErrCode(http2ErrCodeStreamClosed)
+	HTTP2ErrCodeFrameSize          = HTTP2ErrCode(http2ErrCodeFrameSize)
+	HTTP2ErrCodeRefusedStream      = HTTP2ErrCode(http2ErrCodeRefusedStream)
+	HTTP2ErrCodeCancel             = HTTP2ErrCode(http2ErrCodeCancel)
+	HTTP2ErrCodeCompression        = HTTP2ErrCode(http2ErrCodeCompression)
+	HTTP2ErrCodeConnect            = HTTP2ErrCode(http2ErrCodeConnect)
+	HTTP2ErrCodeEnhanceYourCalm    = HTTP2ErrCode(http2ErrCodeEnhanceYourCalm)
+	HTTP2ErrCodeInadequateSecurity = HTTP2ErrCode(http2ErrCodeInadequateSecurity)
+	HTTP2ErrCodeHTTP11Required     = HTTP2ErrCode(http2ErrCodeHTTP11Required)
+)
+
+// String 返回错误码的名称，如 "REFUSED_STREAM"
+func (c HTTP2ErrCode) String() string {
+	return http2ErrCode(c).String()
+}
+
+// streamResetHints 是常见重置原因的说明
+var streamResetHints = map[HTTP2ErrCode]string{
+	HTTP2ErrCodeRefusedStream:   "服务端没有处理该请求，可以安全重试",
+	HTTP2ErrCodeEnhanceYourCalm: "服务端认为请求过于频繁，应降低速率后再试",
+	HTTP2ErrCodeHTTP11Required:  "服务端要求使用 HTTP/1.1",
+	HTTP2ErrCodeInternal:        "服务端内部错误",
+	HTTP2ErrCodeProtocol:        "服务端认为请求违反了协议，连接不再复用",
+	HTTP2ErrCodeCancel:          "服务端取消了该流",
+}
+
+// StreamResetError 在服务端用 RST_STREAM 帧重置请求所在的 HTTP/2 流时，
+// 由 RoundTrip 或读取响应体返回
+//
+// REFUSED_STREAM 表示服务端没有处理请求 (RFC 9113 8.7)，Transport 会在请求体
+// 可以重放时自动重试，重试仍失败才返回该错误。
+//
+// 它包装了 HTTP/2 传输层的原始错误，errors.As 仍可以将其匹配为
+// golang.org/x/net/http2.StreamError。
+type StreamResetError struct {
+	StreamID uint32
+	Code     HTTP2ErrCode
+
+	err error // 原始的 http2StreamError
+}
+
+func (e *StreamResetError) Error() string {
+	s := fmt.Sprintf("服务端重置了 HTTP/2 流 %d: %v", e.StreamID, e.Code)
+	if hint, ok := streamResetHints[e.Code]; ok {
+		s += " (" + hint + ")"
+	}
+	return s
+}
+
+func (e *StreamResetError) Unwrap() error { return e.err }
+
+// RetrySafe 报告服务端是否保证没有处理请求，即使请求不是幂等的也可以安全重试
+func (e *StreamResetError) RetrySafe() bool {
+	return e.Code == HTTP2ErrCodeRefusedStream
+}
+
+// http2streamResetError 把服务端发送的 RST_STREAM 转换为 *StreamResetError，
+// 其他错误原样返回
+//
+// 内部的重试和清理逻辑依赖 http2StreamError，只在返回给调用方时转换。
+func http2streamResetError(err error) error {
+	if se, ok := err.(http2StreamError); ok && se.Cause == http2errFromPeer {
+		return &StreamResetError{StreamID: se.StreamID, Code: HTTP2ErrCode(se.Code), err: se}
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// writeTestHeaders 在流 id 上回复 200 的 HEADERS 帧
+func writeTestHeaders(fr *http2Framer, id uint32, endStream bool) {
+	var buf bytes.Buffer
+	hpack.NewEncoder(&buf).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+	fr.WriteHeaders(http2HeadersFrameParam{
+		StreamID:      id,
+		BlockFragment: buf.Bytes(),
+		EndStream:     endStream,
+		EndHeaders:    true,
+	})
+}
+
+// TestStreamResetError 测试服务端重置流时返回的错误和 REFUSED_STREAM 的自动重试
+func TestStreamResetError(t *testing.T) {
+	newTransport := func() *Transport {
+		return &Transport{
+			JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			ForceAttemptHTTP2: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	t.Run("REFUSED_STREAM 自动重试", func(t *testing.T) {
+		ts := newH2FrameServer(t)
+		var n atomic.Int32
+		ts.respond = func(fr *http2Framer, h *http2MetaHeadersFrame) {
+			if n.Add(1) == 1 {
+				fr.WriteRSTStream(h.StreamID, http2ErrCodeRefusedStream)
+				return
+			}
+			writeTestHeaders(fr, h.StreamID, true)
+		}
+		tr := newTransport()
+		defer tr.CloseIdleConnections()
+		req, _ := NewRequest("POST", ts.URL, strings.NewReader("body"))
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := n.Load(); got != 2 {
+			t.Errorf("请求次数 got %d, want 2", got)
+		}
+	})
+
+	t.Run("ENHANCE_YOUR_CALM", func(t *testing.T) {
+		ts := newH2FrameServer(t)
+		var n atomic.Int32
+		ts.respond = func(fr *http2Framer, h *http2MetaHeadersFrame) {
+			n.Add(1)
+			fr.WriteRSTStream(h.StreamID, http2ErrCodeEnhanceYourCalm)
+		}
+		tr := newTransport()
+		defer tr.CloseIdleConnections()
+		req, _ := NewRequest("GET", ts.URL, nil)
+		_, err := tr.RoundTrip(req)
+		var se *StreamResetError
+		if !errors.As(err, &se) {
+			t.Fatalf("got %T %v, want *StreamResetError", err, err)
+		}
+		if se.Code != HTTP2ErrCodeEnhanceYourCalm || se.RetrySafe() {
+			t.Errorf("got Code %v RetrySafe %v, want ENHANCE_YOUR_CALM false", se.Code, se.RetrySafe())
+		}
+		var xse http2.StreamError
+		if !errors.As(err, &xse) || xse.Code != http2.ErrCodeEnhanceYourCalm {
+			t.Errorf("errors.As 没有匹配 http2.StreamError: %+v", xse)
+		}
+		if !strings.Contains(err.Error(), "ENHANCE_YOUR_CALM") {
+			t.Errorf("错误信息 %q 不包含错误码", err)
+		}
+		if got := n.Load(); got != 1 {
+			t.Errorf("请求次数 got %d, want 1", got)
+		}
+	})
+
+	t.Run("读取响应体时重置", func(t *testing.T) {
+		ts := newH2FrameServer(t)
+		ts.respond = func(fr *http2Framer, h *http2MetaHeadersFrame) {
+			writeTestHeaders(fr, h.StreamID, false)
+			fr.WriteData(h.StreamID, false, []byte("partial"))
+			fr.WriteRSTStream(h.StreamID, http2ErrCodeInternal)
+		}
+		tr := newTransport()
+		defer tr.CloseIdleConnections()
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		var se *StreamResetError
+		if !errors.As(err, &se) || se.Code != HTTP2ErrCodeInternal {
+			t.Fatalf("got %T %v, want INTERNAL_ERROR 的 *StreamResetError", err, err)
+		}
+	})
+
+	if got := (&StreamResetError{StreamID: 3, Code: HTTP2ErrCodeRefusedStream}); !got.RetrySafe() {
+		t.Errorf("REFUSED_STREAM 的 RetrySafe got false, want true")
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// Timings is the timing breakdown of one request, similar to the
+// metrics reported by curl -w. Phases that did not happen, such as
+// DNS and Connect on a reused connection, are zero.
+type Timings struct {
+	Start time.Time // when the request was sent to the RoundTripper
+
+	DNS          time.Duration // DNS lookup of the server or proxy
+	Connect      time.Duration // TCP connect, after DNS
+	TLSHandshake time.Duration // TLS handshake with the server
+
+	// TimeToFirstByte is the time from Start to the first byte of
+	// the response headers.
+	TimeToFirstByte time.Duration
+
+	// Download is the time from the first byte of the response
+	// headers until the body was read to EOF or closed.
+	Download time.Duration
+
+	// Total is the time from Start until the body was read to EOF
+	// or closed, or until RoundTrip failed.
+	Total time.Duration
+
+	Reused bool // whether the connection was reused
+}
+
+// TimingTransport is a RoundTripper that collects Timings for every
+// request it sends. Use ResponseTimings to get the Timings of a
+// response, or OnDone to receive them once the response is complete.
+//
+// DNS lookups are only observed when the Transport dials with its
+// default dialer; a custom DialContext resolves names on its own.
+type TimingTransport struct {
+	// Transport is the underlying RoundTripper. If nil,
+	// DefaultTransport is used.
+	Transport RoundTripper
+
+	// OnDone, if non-nil, is called once per request when the
+	// response body has been read to EOF or closed, or when
+	// RoundTrip fails.
+	OnDone func(req *Request, t *Timings)
+}
+
+// EnableTimings wraps c.Transport in a TimingTransport and returns it.
+func EnableTimings(c *Client) *TimingTransport {
+	tt := &TimingTransport{Transport: c.Transport}
+	c.Transport = tt
+	return tt
+}
+
+type timingsKey struct{}
+
+// ResponseTimings returns the Timings collected for resp by a
+// TimingTransport, or nil if there are none. Download and Total are
+// only set once the response body has been read to EOF or closed.
+func ResponseTimings(resp *Response) *Timings {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	tc, _ := resp.Request.Context().Value(timingsKey{}).(*timingCollector)
+	if tc == nil {
+		return nil
+	}
+	return tc.snapshot()
+}
+
+// RoundTrip implements RoundTripper.
+func (tt *TimingTransport) RoundTrip(req *Request) (*Response, error) {
+	rt := tt.Transport
+	if rt == nil {
+		rt = DefaultTransport
+	}
+	tc := &timingCollector{onDone: tt.OnDone}
+	tc.t.Start = time.Now()
+	ctx := context.WithValue(req.Context(), timingsKey{}, tc)
+	ctx = httptrace.WithClientTrace(ctx, tc.trace())
+	req2 := req.WithContext(ctx)
+	tc.req = req2
+
+	resp, err := rt.RoundTrip(req2)
+	if err != nil {
+		tc.finish()
+		return nil, err
+	}
+	resp.Request = req2
+	if resp.Body == nil || resp.Body == NoBody {
+		tc.finish()
+	} else {
+		resp.Body = &timingBody{ReadCloser: resp.Body, tc: tc}
+	}
+	return resp, nil
+}
+
+// timingCollector builds a Timings from trace events. Events can
+// arrive from dial and read goroutines, so t is guarded by mu.
+type timingCollector struct {
+	req    *Request
+	onDone func(*Request, *Timings)
+
+	mu                              sync.Mutex
+	t                               Timings
+	dnsStart, dnsDone, connectStart time.Time
+	tlsStart, firstByte             time.Time
+	done                            bool
+}
+
+func (tc *timingCollector) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tc.mu.Lock()
+			tc.dnsStart = time.Now()
+			tc.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tc.mu.Lock()
+			tc.dnsDone = time.Now()
+			tc.t.DNS = tc.dnsDone.Sub(tc.dnsStart)
+			tc.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			tc.mu.Lock()
+			tc.connectStart = time.Now()
+			tc.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			tc.mu.Lock()
+			if err == nil {
+				start := tc.connectStart
+				if tc.dnsDone.After(start) {
+					start = tc.dnsDone
+				}
+				tc.t.Connect = time.Since(start)
+			}
+			tc.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			tc.mu.Lock()
+			tc.tlsStart = time.Now()
+			tc.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			tc.mu.Lock()
+			if err == nil {
+				tc.t.TLSHandshake = time.Since(tc.tlsStart)
+			}
+			tc.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tc.mu.Lock()
+			tc.t.Reused = info.Reused
+			tc.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			tc.mu.Lock()
+			tc.firstByte = time.Now()
+			tc.t.TimeToFirstByte = tc.firstByte.Sub(tc.t.Start)
+			tc.mu.Unlock()
+		},
+	}
+}
+
+// finish records the end of the request and calls onDone, once.
+func (tc *timingCollector) finish() {
+	tc.mu.Lock()
+	if tc.done {
+		tc.mu.Unlock()
+		return
+	}
+	tc.done = true
+	now := time.Now()
+	tc.t.Total = now.Sub(tc.t.Start)
+	if !tc.firstByte.IsZero() {
+		tc.t.Download = now.Sub(tc.firstByte)
+	}
+	t := tc.t
+	tc.mu.Unlock()
+	if tc.onDone != nil {
+		tc.onDone(tc.req, &t)
+	}
+}
+
+func (tc *timingCollector) snapshot() *Timings {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	t := tc.t
+	return &t
+}
+
+// timingBody finishes its collector at EOF or Close.
+type timingBody struct {
+	io.ReadCloser
+	tc *timingCollector
+}
+
+func (b *timingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.tc.finish()
+	}
+	return n, err
+}
+
+func (b *timingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.tc.finish()
+	return err
+}
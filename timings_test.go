@@ -0,0 +1,109 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestTimingTransport 测试 TimingTransport 收集的各阶段耗时
+func TestTimingTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.(nethttp.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+	// 通过 localhost 访问，以便观察到 DNS 解析
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	tr := &Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	var done []*Timings
+	EnableTimings(c).OnDone = func(req *Request, tm *Timings) { done = append(done, tm) }
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(u)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		if b, _ := io.ReadAll(resp.Body); string(b) != "hello" {
+			t.Fatalf("body = %q, want hello", b)
+		}
+		resp.Body.Close()
+		tm := ResponseTimings(resp)
+		if tm == nil {
+			t.Fatal("ResponseTimings 返回 nil")
+		}
+		if tm.TimeToFirstByte <= 0 || tm.Download < 15*time.Millisecond {
+			t.Errorf("TimeToFirstByte = %v, Download = %v", tm.TimeToFirstByte, tm.Download)
+		}
+		if tm.Total < tm.TimeToFirstByte+tm.Download-time.Millisecond {
+			t.Errorf("Total = %v, 小于 TTFB + Download", tm.Total)
+		}
+		if i == 0 && (tm.DNS <= 0 || tm.Connect <= 0 || tm.TLSHandshake <= 0 || tm.Reused) {
+			t.Errorf("首个请求: %+v", tm)
+		}
+		if i == 1 && (tm.DNS != 0 || tm.Connect != 0 || tm.TLSHandshake != 0 || !tm.Reused) {
+			t.Errorf("复用连接的请求: %+v", tm)
+		}
+	}
+	if len(done) != 2 {
+		t.Errorf("OnDone 调用次数 = %d, want 2", len(done))
+	}
+}
+
+// TestDialTracingDNSFallback 测试设置了 DNS 钩子时按 net.Dialer 的方式拨号：
+// 按地址族分组，首选地址族全部失败时立即改用另一地址族
+func TestDialTracingDNSFallback(t *testing.T) {
+	primaries, fallbacks := partitionDialAddrs([]string{"[::1]:1", "127.0.0.1:1", "[::2]:1", "127.0.0.2:1"})
+	if !slices.Equal(primaries, []string{"[::1]:1", "[::2]:1"}) || !slices.Equal(fallbacks, []string{"127.0.0.1:1", "127.0.0.2:1"}) {
+		t.Errorf("partitionDialAddrs got %v, %v", primaries, fallbacks)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := closed.Addr().String()
+	closed.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	c, err := dialParallel(ctx, "tcp", []string{refused}, []string{ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("首选地址失败后 %v 才尝试备选地址", d)
+	}
+
+	c, err = dialSerial(ctx, "tcp", []string{refused, ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if _, err := dialParallel(ctx, "tcp", []string{refused}, []string{refused}); err == nil {
+		t.Error("所有地址都失败时期望错误")
+	}
+}
@@ -5,6 +5,12 @@
 package http
 
 import (
+	"bytes"
+	ctls "crypto/tls"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
 
 	tls "github.com/refraction-networking/utls"
@@ -608,33 +614,6 @@ func TestPersistConnFixPSKExtension(t *testing.T) {
 	}
 }
 
-// TestPersistConnApplyJA4Fingerprint 测试 JA4 指纹应用
-func TestPersistConnApplyJA4Fingerprint(t *testing.T) {
-	pc := &persistConn{
-		t: &Transport{
-			CustomJA4: true,
-			JA4L:      "t13d1715h2",
-			JA4X:      "x509_abcd",
-		},
-	}
-
-	spec := &tls.ClientHelloSpec{
-		CipherSuites: []uint16{0x1301, 0x1302},
-		Extensions:   []tls.TLSExtension{&tls.SNIExtension{}},
-	}
-
-	result := pc.applyJA4Fingerprint(spec)
-
-	if result == nil {
-		t.Error("applyJA4Fingerprint() 不应该返回 nil")
-	}
-
-	// JA4 目前是占位符实现，只验证不崩溃
-	if result != spec {
-		t.Error("applyJA4Fingerprint() 应该返回相同的 spec")
-	}
-}
-
 // TestTransportEnsureInitialized 测试 Transport 初始化
 func TestTransportEnsureInitialized(t *testing.T) {
 	tr := &Transport{}
@@ -783,3 +762,710 @@ func BenchmarkGetCompleteExtensionMap(b *testing.B) {
 		_ = getCompleteExtensionMap()
 	}
 }
+
+// TestTLSVersionPolicy 测试从 JA3 推导 TLS 版本范围以及版本字段冲突的检测
+func TestTLSVersionPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		ja3              string
+		wantMin, wantMax uint16
+		wantErr          bool
+	}{
+		{
+			name:    "带 supported_versions 的 TLS 1.3",
+			ja3:     "771,4865-4866-49195,0-10-11-43-51,29-23,0",
+			wantMin: tls.VersionTLS12,
+			wantMax: tls.VersionTLS13,
+		},
+		{
+			name:    "没有 supported_versions 只协商 TLS 1.2",
+			ja3:     "771,49195-49199,0-10-11,29-23,0",
+			wantMin: tls.VersionTLS12,
+			wantMax: tls.VersionTLS12,
+		},
+		{
+			name:    "supported_versions 与版本字段矛盾",
+			ja3:     "772,4865-4866,0-10-11-43-51,29-23,0",
+			wantErr: true,
+		},
+		{
+			name:    "版本字段为 TLS 1.3 但没有 supported_versions",
+			ja3:     "772,4865-4866,0-10-11,29-23,0",
+			wantErr: true,
+		},
+		{
+			name:    "最高只支持 TLS 1.1",
+			ja3:     "770,49171-49172,0-10-11,29-23,0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext := &TLSExtensionsConfig{NotUsedGREASE: true}
+			spec, err := ext.StringToSpec(tt.ja3, "Mozilla/5.0 Firefox/120.0", false, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StringToSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if spec.TLSVersMin != tt.wantMin || spec.TLSVersMax != tt.wantMax {
+				t.Errorf("版本范围 got %x-%x, want %x-%x", spec.TLSVersMin, spec.TLSVersMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+// TestApplyTLSVersionPolicyGREASE 测试版本范围忽略 GREASE 并且最低不低于 TLS 1.2
+func TestApplyTLSVersionPolicyGREASE(t *testing.T) {
+	spec := &tls.ClientHelloSpec{
+		Extensions: []tls.TLSExtension{
+			&tls.SupportedVersionsExtension{Versions: []uint16{
+				tls.GREASE_PLACEHOLDER, tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10,
+			}},
+		},
+	}
+	if err := applyTLSVersionPolicy(spec); err != nil {
+		t.Fatalf("applyTLSVersionPolicy() 失败: %v", err)
+	}
+	if spec.TLSVersMin != tls.VersionTLS12 || spec.TLSVersMax != tls.VersionTLS13 {
+		t.Errorf("版本范围 got %x-%x, want %x-%x", spec.TLSVersMin, spec.TLSVersMax, tls.VersionTLS12, tls.VersionTLS13)
+	}
+}
+
+// TestSignatureAlgorithmsPerBrowser 测试 13 和 50 扩展按浏览器选择并可被覆盖
+func TestSignatureAlgorithmsPerBrowser(t *testing.T) {
+	const ja3 = "771,4865-4866,0-10-11-13-43-50-51,29-23,0"
+	override := []tls.SignatureScheme{tls.Ed25519, tls.ECDSAWithP256AndSHA256}
+	tests := []struct {
+		name      string
+		userAgent string
+		ext       *TLSExtensionsConfig
+		wantSig   []tls.SignatureScheme
+		wantCert  []tls.SignatureScheme
+	}{
+		{
+			name:      "Chrome",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			wantSig:   chromeSignatureAlgorithms,
+			wantCert:  chromeSignatureAlgorithms,
+		},
+		{
+			name:      "Firefox",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			wantSig:   firefoxSignatureAlgorithms,
+			wantCert:  firefoxSignatureAlgorithms,
+		},
+		{
+			name:      "Safari",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+			wantSig:   safariSignatureAlgorithms,
+			wantCert:  safariSignatureAlgorithms,
+		},
+		{
+			name:      "覆盖 signature_algorithms_cert",
+			userAgent: "Mozilla/5.0 Firefox/120.0",
+			ext: &TLSExtensionsConfig{
+				SignatureAlgorithmsCert: &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: override},
+			},
+			wantSig:  firefoxSignatureAlgorithms,
+			wantCert: override,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext := tt.ext
+			if ext == nil {
+				ext = &TLSExtensionsConfig{}
+			}
+			ext.NotUsedGREASE = true
+			spec, err := ext.StringToSpec(ja3, tt.userAgent, false, false)
+			if err != nil {
+				t.Fatalf("StringToSpec() 失败: %v", err)
+			}
+			var gotSig, gotCert []tls.SignatureScheme
+			for _, e := range spec.Extensions {
+				switch e := e.(type) {
+				case *tls.SignatureAlgorithmsExtension:
+					gotSig = e.SupportedSignatureAlgorithms
+				case *tls.SignatureAlgorithmsCertExtension:
+					gotCert = e.SupportedSignatureAlgorithms
+				}
+			}
+			if !slices.Equal(gotSig, tt.wantSig) {
+				t.Errorf("signature_algorithms got %v, want %v", gotSig, tt.wantSig)
+			}
+			if !slices.Equal(gotCert, tt.wantCert) {
+				t.Errorf("signature_algorithms_cert got %v, want %v", gotCert, tt.wantCert)
+			}
+		})
+	}
+}
+
+// TestCertCompression 测试 27 扩展的算法按浏览器选择，并可被 TLSExtensionsConfig
+// 和 Transport.CertCompression 覆盖
+func TestCertCompression(t *testing.T) {
+	const (
+		ja3       = "771,4865-4866,0-10-11-13-27-43-51,29-23,0"
+		chromeUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"
+		firefoxUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"
+		safariUA  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+	)
+	zstd := []tls.CertCompressionAlgo{tls.CertCompressionZstd}
+	tests := []struct {
+		name string
+		tr   *Transport
+		want []tls.CertCompressionAlgo // nil 表示没有 27 扩展
+	}{
+		{"Chrome", &Transport{JA3: ja3, UserAgent: chromeUA}, chromeCertCompression},
+		{"Firefox", &Transport{JA3: ja3, UserAgent: firefoxUA}, firefoxCertCompression},
+		{"Safari", &Transport{JA3: ja3, UserAgent: safariUA}, safariCertCompression},
+		{
+			"TLSExtensionsConfig 覆盖",
+			&Transport{JA3: ja3, UserAgent: firefoxUA, TLSExtensions: &TLSExtensionsConfig{
+				NotUsedGREASE:       true,
+				CertCompressionAlgo: &tls.UtlsCompressCertExtension{Algorithms: zstd},
+			}},
+			zstd,
+		},
+		{"Transport 覆盖", &Transport{JA3: ja3, UserAgent: chromeUA, CertCompression: firefoxCertCompression}, firefoxCertCompression},
+		{"ClientHelloID", &Transport{ClientHelloID: tls.HelloChrome_133, CertCompression: zstd}, zstd},
+		{"不发送", &Transport{JA3: ja3, UserAgent: firefoxUA, CertCompression: []tls.CertCompressionAlgo{}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := tt.tr.BuildSpec("example.com:443")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []tls.CertCompressionAlgo
+			for _, e := range spec.Extensions {
+				if e, ok := e.(*tls.UtlsCompressCertExtension); ok {
+					got = e.Algorithms
+				}
+			}
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestALPS 测试 Transport.ALPS 替换和删除 ALPS 扩展，以及客户端设置的来源
+func TestALPS(t *testing.T) {
+	const ja3 = "771,4865-4866,0-10-11-13-16-43-51-17513,29-23,0"
+	tests := []struct {
+		name string
+		tr   *Transport
+		want []string // nil 表示没有 ALPS 扩展
+	}{
+		{"JA3 默认", &Transport{JA3: ja3}, []string{"h2"}},
+		{"JA3 替换协议", &Transport{JA3: ja3, ALPS: &ALPSConfig{Protocols: []string{"h2", "h3"}}}, []string{"h2", "h3"}},
+		{"JA3 删除", &Transport{JA3: ja3, ALPS: &ALPSConfig{Omit: true}}, nil},
+		{"17613 替换协议", &Transport{ClientHelloID: tls.HelloChrome_133, ALPS: &ALPSConfig{Protocols: []string{"http/1.1"}}}, []string{"http/1.1"}},
+		{"17613 删除", &Transport{ClientHelloID: tls.HelloChrome_133, ALPS: &ALPSConfig{Omit: true}}, nil},
+		{"只设置 Settings", &Transport{ClientHelloID: tls.HelloChrome_120, ALPS: &ALPSConfig{Settings: map[string][]byte{"h2": {0}}}}, []string{"h2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := tt.tr.BuildSpec("example.com:443")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range spec.Extensions {
+				switch e := e.(type) {
+				case *tls.ApplicationSettingsExtension:
+					got = e.SupportedProtocols
+				case *tls.ApplicationSettingsExtensionNew:
+					got = e.SupportedProtocols
+				}
+			}
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cfg := &tls.Config{ApplicationSettings: map[string][]byte{"h2": []byte("cfg")}}
+	tr := &Transport{JA3: ja3}
+	if got := tr.alpsSettings(cfg); string(got["h2"]) != "cfg" {
+		t.Errorf("ALPS 为 nil 时 got %q, want TLSClientConfig 的设置", got["h2"])
+	}
+	tr.ALPS = &ALPSConfig{Settings: map[string][]byte{"h2": []byte("alps")}}
+	if got := tr.alpsSettings(cfg); string(got["h2"]) != "alps" {
+		t.Errorf("got %q, want ALPSConfig.Settings", got["h2"])
+	}
+}
+
+// TestSupportedGroupsAndKeyShare 测试 supported_groups 与 key_share 分别配置及其校验
+func TestSupportedGroupsAndKeyShare(t *testing.T) {
+	tests := []struct {
+		name       string
+		ja3        string
+		ua         string // 为空时为 Firefox
+		ext        *TLSExtensionsConfig
+		wantGroups []tls.CurveID
+		wantShares []tls.CurveID
+		wantErr    bool
+	}{
+		{
+			name:       "默认密钥共享只保留声明的组",
+			ja3:        "771,4865,0-10-11-43-51,23-24,0",
+			ext:        &TLSExtensionsConfig{},
+			wantGroups: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+			wantShares: []tls.CurveID{tls.CurveP256},
+		},
+		{
+			name: "声明的组多于密钥共享",
+			ja3:  "771,4865,0-10-11-43-51,29-23,0",
+			ext: &TLSExtensionsConfig{
+				SupportedGroups: &tls.SupportedCurvesExtension{Curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}},
+				KeyShareCurves:  &tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: tls.X25519}}},
+			},
+			wantGroups: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+			wantShares: []tls.CurveID{tls.X25519},
+		},
+		{
+			name:       "后量子混合组在 X25519 之前发送密钥共享",
+			ja3:        "771,4865,0-10-11-43-51,4588-29-23,0",
+			ext:        &TLSExtensionsConfig{},
+			wantGroups: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256},
+			wantShares: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256},
+		},
+		{
+			name:       "X25519Kyber768Draft00",
+			ja3:        "771,4865,0-10-11-43-51,25497-29-23,0",
+			ext:        &TLSExtensionsConfig{},
+			wantGroups: []tls.CurveID{tls.X25519Kyber768Draft00, tls.X25519, tls.CurveP256},
+			wantShares: []tls.CurveID{tls.X25519Kyber768Draft00, tls.X25519, tls.CurveP256},
+		},
+		{
+			// 与 Chrome 一致：声明 5 个组 (含 GREASE)，只为 GREASE、混合组和 X25519 发送密钥共享
+			name:       "Chrome 默认",
+			ja3:        "771,4865-4866-4867-49195,0-10-11-13-43-45-51-65281,4588-29-23-24,0",
+			ua:         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+			ext:        &TLSExtensionsConfig{},
+			wantGroups: []tls.CurveID{tls.GREASE_PLACEHOLDER, tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+			wantShares: []tls.CurveID{tls.GREASE_PLACEHOLDER, tls.X25519MLKEM768, tls.X25519},
+		},
+		{
+			name: "密钥共享的组不在 supported_groups 中",
+			ja3:  "771,4865,0-10-11-43-51,29-23,0",
+			ext: &TLSExtensionsConfig{
+				KeyShareCurves: &tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: tls.CurveP384}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ua := tt.ua
+			if ua == "" {
+				ua = "Mozilla/5.0 Firefox/120.0"
+				tt.ext.NotUsedGREASE = true
+			}
+			spec, err := tt.ext.StringToSpec(tt.ja3, ua, false, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StringToSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var groups, shares []tls.CurveID
+			for _, e := range spec.Extensions {
+				switch e := e.(type) {
+				case *tls.SupportedCurvesExtension:
+					groups = e.Curves
+				case *tls.KeyShareExtension:
+					for _, s := range e.KeyShares {
+						shares = append(shares, s.Group)
+					}
+				}
+			}
+			if !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("supported_groups got %v, want %v", groups, tt.wantGroups)
+			}
+			if !slices.Equal(shares, tt.wantShares) {
+				t.Errorf("key_share got %v, want %v", shares, tt.wantShares)
+			}
+		})
+	}
+}
+
+// TestTransportHybridKeyShare 测试服务端只支持 X25519MLKEM768 时使用混合密钥共享
+// 完成握手，utls 不支持在 HelloRetryRequest 后选择该组，因此握手成功说明首个
+// ClientHello 已包含其密钥共享
+func TestTransportHybridKeyShare(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("X-Curve", r.TLS.CurveID.String())
+	}))
+	ts.TLS = &ctls.Config{CurvePreferences: []ctls.CurveID{ctls.X25519MLKEM768}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	tr := &Transport{
+		JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,4588-29-23-24,0",
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+	resp, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Curve"); got != "X25519MLKEM768" {
+		t.Errorf("协商的组 got %v, want X25519MLKEM768", got)
+	}
+}
+
+// TestTransportSessionResumption 测试指纹连接恢复之前的 TLS 1.3 会话
+func TestTransportSessionResumption(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.TLS.DidResume {
+			w.Header().Set("X-Resumed", "1")
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		ja3         string
+		disabled    bool
+		wantResumed bool
+	}{
+		{
+			name:        "JA3 中没有 PSK 扩展",
+			ja3:         "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+			wantResumed: true,
+		},
+		{
+			name:        "JA3 中有 PSK 扩展",
+			ja3:         "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-35-43-45-51-65281-41,29-23-24,0",
+			wantResumed: true,
+		},
+		{
+			name:     "禁用会话票据",
+			ja3:      "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-35-43-45-51-65281-41,29-23-24,0",
+			disabled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:               tt.ja3,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, SessionTicketsDisabled: tt.disabled},
+				DisableKeepAlives: true,
+			}
+			defer tr.CloseIdleConnections()
+			c := &Client{Transport: tr}
+			for i, want := range []bool{false, tt.wantResumed} {
+				resp, err := c.Get(ts.URL)
+				if err != nil {
+					t.Fatalf("第 %d 次请求失败: %v", i+1, err)
+				}
+				resp.Body.Close()
+				if got := resp.Header.Get("X-Resumed") != ""; got != want {
+					t.Errorf("第 %d 次连接恢复会话 got %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestSessionTicketMode 测试 TLSExtensionsConfig.SessionTickets 和按扩展推导的默认值
+func TestSessionTicketMode(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.TLS.DidResume {
+			w.Header().Set("X-Resumed", "1")
+		}
+	}))
+	defer ts.Close()
+
+	const (
+		resumable = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-35-43-45-51-65281,29-23-24,0"
+		// 密码套件 0x0029 的十进制 41 出现在 JA3 中不影响判断
+		tls12Only = "771,49195-49199-41,0-10-11-13-23-65281,29-23-24,0"
+	)
+	tests := []struct {
+		name        string
+		ja3         string
+		mode        SessionTicketMode
+		wantResumed bool
+	}{
+		{"默认，有 session_ticket", resumable, SessionTicketsAuto, true},
+		{"显式禁用", resumable, SessionTicketsDisabled, false},
+		{"默认，TLS 1.2 且没有恢复扩展", tls12Only, SessionTicketsAuto, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:               tt.ja3,
+				TLSExtensions:     &TLSExtensionsConfig{SessionTickets: tt.mode},
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			}
+			defer tr.CloseIdleConnections()
+			c := &Client{Transport: tr}
+			for i, want := range []bool{false, tt.wantResumed} {
+				resp, err := c.Get(ts.URL)
+				if err != nil {
+					t.Fatalf("第 %d 次请求失败: %v", i+1, err)
+				}
+				resp.Body.Close()
+				if got := resp.Header.Get("X-Resumed") != ""; got != want {
+					t.Errorf("第 %d 次连接恢复会话 got %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+
+	for ja3, want := range map[string]bool{
+		resumable:                     true,
+		tls12Only:                     false,
+		"771,4865,0-10-43-51,29,0":    false,
+		"771,4865,0-10-43-45-51,29,0": true,
+	} {
+		spec, err := (&TLSExtensionsConfig{}).StringToSpec(ja3, "", false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := specAdvertisesResumption(spec); got != want {
+			t.Errorf("specAdvertisesResumption(%s) got %v, want %v", ja3, got, want)
+		}
+	}
+}
+
+// TestTransportClientSessionCache 测试 Transport.ClientSessionCache 在指纹连接和
+// 标准 TLS 连接之间共享，并按 SNI 区分
+func TestTransportClientSessionCache(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.TLS.DidResume {
+			w.Header().Set("X-Resumed", "1")
+		}
+	}))
+	defer ts.Close()
+
+	cache := tls.NewLRUClientSessionCache(0)
+	newTransport := func(ja3, sni string) *Transport {
+		return &Transport{
+			JA3:                ja3,
+			TLSClientConfig:    &tls.Config{InsecureSkipVerify: true, ServerName: sni},
+			ClientSessionCache: cache,
+			DisableKeepAlives:  true,
+		}
+	}
+	const ja3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+	tests := []struct {
+		name string
+		tr   *Transport
+		want bool
+	}{
+		{"指纹连接", newTransport(ja3, "a.example"), false},
+		{"标准 TLS 连接，相同 SNI", newTransport("", "a.example"), true},
+		{"指纹连接，不同 SNI", newTransport(ja3, "b.example"), false},
+		{"Clone 后的指纹连接，相同 SNI", newTransport(ja3, "b.example").Clone(), true},
+	}
+	for _, tt := range tests {
+		resp, err := (&Client{Transport: tt.tr}).Get(ts.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Resumed") != ""; got != tt.want {
+			t.Errorf("%s: 恢复会话 got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTransportOnClientHello 测试 OnClientHello 收到实际发出的 ClientHello
+func TestTransportOnClientHello(t *testing.T) {
+	tests := []struct {
+		name       string
+		ja3        string
+		curves     []ctls.CurveID // 服务端支持的曲线，不含 X25519 时触发 HelloRetryRequest
+		wantHellos int
+	}{
+		{"指纹连接", "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0", nil, 1},
+		{"标准 TLS 连接", "", nil, 1},
+		{"HelloRetryRequest", "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0", []ctls.CurveID{ctls.CurveP256}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+			ts.TLS = &ctls.Config{CurvePreferences: tt.curves}
+			ts.StartTLS()
+			defer ts.Close()
+
+			var hosts []string
+			var hellos [][]byte
+			tr := &Transport{
+				JA3:             tt.ja3,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				OnClientHello: func(host string, raw []byte) {
+					hosts = append(hosts, host)
+					hellos = append(hellos, raw)
+				},
+			}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if len(hellos) != tt.wantHellos {
+				t.Fatalf("ClientHello 数 got %d, want %d", len(hellos), tt.wantHellos)
+			}
+			if hosts[0] != "127.0.0.1" {
+				t.Errorf("host got %q, want 127.0.0.1", hosts[0])
+			}
+			hello, err := parseClientHello(hellos[len(hellos)-1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ja3 != "" && ja3Hash(hello.ja3()) != resp.Meta.FingerprintHash {
+				t.Errorf("JA3 哈希 got %v, want %v", ja3Hash(hello.ja3()), resp.Meta.FingerprintHash)
+			}
+		})
+	}
+}
+
+// TestRawExtensions 测试 RawExtensions 逐字节发送 JA3 中的扩展内容
+func TestRawExtensions(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{SessionTicketsDisabled: true}
+	ts.StartTLS()
+	defer ts.Close()
+
+	raw := &TLSExtensionsConfig{RawExtensions: map[uint16][]byte{
+		0xfe02: {1, 2, 3},           // 未知扩展
+		17513:  {0, 3, 2, 'h', '2'}, // 替换内置的 ALPS
+		30:     {9},                 // 不在 JA3 中，忽略
+	}}
+	tr := &Transport{
+		JA3:             "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-17513-65026-65281,29-23-24,0",
+		TLSExtensions:   raw,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"},
+	}
+	defer tr.CloseIdleConnections()
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc, err := tr.NewUConn(c1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	hello := uc.HandshakeState.Hello.Raw
+	for _, want := range [][]byte{
+		{0xfe, 0x02, 0, 3, 1, 2, 3},
+		{0x44, 0x69, 0, 5, 0, 3, 2, 'h', '2'},
+	} {
+		if !bytes.Contains(hello, want) {
+			t.Errorf("ClientHello 中没有 % x", want)
+		}
+	}
+	if bytes.Contains(hello, []byte{0, 30, 0, 1, 9}) {
+		t.Error("不在 JA3 中的扩展被发送")
+	}
+
+	// 未知扩展不影响握手
+	tr.TLSClientConfig.ServerName = ""
+	req, _ := NewRequest("GET", ts.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spec, err := raw.StringToSpec("771,4865,0-10-65026,29,0", "Mozilla/5.0 Chrome/120.0", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(spec.Extensions, func(e tls.TLSExtension) bool {
+		g, ok := e.(*tls.GenericExtension)
+		return ok && g.Id == 0xfe02 && bytes.Equal(g.Data, []byte{1, 2, 3})
+	}) {
+		t.Error("StringToSpec 没有使用 RawExtensions")
+	}
+
+	bad := &Transport{
+		JA3:           "771,4865,0-10-51,29,0",
+		TLSExtensions: &TLSExtensionsConfig{RawExtensions: map[uint16][]byte{51: {0}}},
+	}
+	if _, err := bad.BuildSpec("example.com"); err == nil {
+		t.Error("替换 key_share 应返回错误")
+	}
+}
+
+// TestReplaceExtensions 测试删除和替换 JA3 中的扩展
+func TestReplaceExtensions(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	alpn := &tls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}}
+	ext := &TLSExtensionsConfig{
+		RemoveExtensions:  []uint16{17513},
+		ReplaceExtensions: map[uint16]tls.TLSExtension{16: alpn},
+	}
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-17513-65281,29-23-24,0",
+		TLSExtensions:     ext,
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+
+	spec, err := tr.BuildSpec("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.ApplicationSettingsExtension:
+			t.Error("17513 没有被删除")
+		case *tls.ALPNExtension:
+			if e == alpn {
+				t.Error("替换的扩展没有被复制")
+			}
+			if !slices.Equal(e.AlpnProtocols, []string{"http/1.1"}) {
+				t.Errorf("ALPN got %v, want [http/1.1]", e.AlpnProtocols)
+			}
+		}
+	}
+
+	req, _ := NewRequest("GET", ts.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("got HTTP/%d, want HTTP/1.1", resp.ProtoMajor)
+	}
+
+	clone, err := ext.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.ReplaceExtensions[16] != alpn || !slices.Equal(clone.RemoveExtensions, ext.RemoveExtensions) {
+		t.Errorf("Clone got %+v", clone)
+	}
+
+	spec, err = ext.StringToSpec("771,4865,0-10-16-17513,29,0", "Mozilla/5.0 Firefox/120.0", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(spec.Extensions); n != 3 {
+		t.Errorf("StringToSpec 的扩展数 got %d, want 3", n)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"fmt"
+	"slices"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// checkJA3Version 校验 JA3 的版本字段与扩展列表是否矛盾，返回 ClientHello 可协商的最高版本
+//
+// JA3 的版本字段是 ClientHello 的 legacy_version。发送 supported_versions (43)
+// 扩展时，TLS 1.3 要求 legacy_version 固定为 TLS 1.2 (771)，实际版本由扩展决定，
+// 此时返回 0；没有该扩展时 legacy_version 就是最高版本，且不可能高于 TLS 1.2。
+func checkJA3Version(version uint16, extensions []string) (uint16, error) {
+	if slices.Contains(extensions, "43") {
+		if version != tls.VersionTLS12 {
+			return 0, fmt.Errorf("JA3 版本字段 %d 与 supported_versions 扩展矛盾，带该扩展时应为 771", version)
+		}
+		return 0, nil
+	}
+	if version > tls.VersionTLS12 {
+		return 0, fmt.Errorf("JA3 版本字段 %d 需要 supported_versions 扩展 (43)", version)
+	}
+	return version, nil
+}
+
+// applyTLSVersionPolicy 按浏览器的版本策略设置 spec 的 TLSVersMin 和 TLSVersMax
+//
+// 最高和最低版本取自 supported_versions 扩展（忽略 GREASE 值）；没有该扩展时
+// 只能协商 spec.TLSVersMax，未设置则为 TLS 1.2。与现代浏览器一样，
+// 最低版本不低于 TLS 1.2，最高版本低于 TLS 1.2 的配置直接报错。
+func applyTLSVersionPolicy(spec *tls.ClientHelloSpec) error {
+	var vmin, vmax uint16
+	for _, e := range spec.Extensions {
+		sv, ok := e.(*tls.SupportedVersionsExtension)
+		if !ok {
+			continue
+		}
+		for _, v := range sv.Versions {
+			if isGREASEValue(v) {
+				continue
+			}
+			if vmax == 0 || v > vmax {
+				vmax = v
+			}
+			if vmin == 0 || v < vmin {
+				vmin = v
+			}
+		}
+	}
+	if vmax == 0 {
+		vmax = spec.TLSVersMax
+		if vmax == 0 {
+			vmax = tls.VersionTLS12
+		}
+		vmin = vmax
+	}
+	if vmax < tls.VersionTLS12 {
+		return fmt.Errorf("指纹最高只支持 %s，浏览器已不再使用 TLS 1.2 以下的版本", tls.VersionName(vmax))
+	}
+	spec.TLSVersMin = max(vmin, tls.VersionTLS12)
+	spec.TLSVersMax = vmax
+	return nil
+}
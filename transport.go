@@ -34,16 +34,19 @@ import (
 	"compress/gzip"
 	"container/list"
 	"context"
+	"crypto/ecdh"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"maps"
+	"math/rand"
 	"net"
 	"net/textproto"
 	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -55,12 +58,12 @@ import (
 	"github.com/fxamacker/cbor"
 	tls "github.com/refraction-networking/utls"
 
+	"github.com/vanling1111/tlshttp/httptesthooks"
 	"github.com/vanling1111/tlshttp/httptrace"
 	"github.com/vanling1111/tlshttp/internal/ascii"
 	"github.com/vanling1111/tlshttp/internal/godebug"
 
 	"golang.org/x/net/http/httpguts"
-	"golang.org/x/net/http/httpproxy"
 )
 
 // TLSFingerprintConfig 配置 TLS 指纹控制
@@ -93,6 +96,9 @@ type TLSFingerprintConfig struct {
 // TLS 扩展管理系统
 type TLSExtensionsConfig struct {
 	// 基础扩展配置
+	// SupportedSignatureAlgorithms 和 SignatureAlgorithmsCert 为 nil 时，
+	// 13 和 50 扩展使用 UserAgent 所属浏览器（Chrome、Firefox、Safari）的签名算法；
+	// CertCompressionAlgo 为 nil 时，27 扩展同样使用该浏览器的证书压缩算法
 	SupportedSignatureAlgorithms *tls.SignatureAlgorithmsExtension
 	CertCompressionAlgo          *tls.UtlsCompressCertExtension
 	RecordSizeLimit              *tls.FakeRecordSizeLimitExtension
@@ -100,13 +106,84 @@ type TLSExtensionsConfig struct {
 	SupportedVersions            *tls.SupportedVersionsExtension
 	PSKKeyExchangeModes          *tls.PSKKeyExchangeModesExtension
 	SignatureAlgorithmsCert      *tls.SignatureAlgorithmsCertExtension
-	KeyShareCurves               *tls.KeyShareExtension
+
+	// SupportedGroups 替换 supported_groups (10) 扩展中来自 JA3 的组，
+	// KeyShareCurves 替换 key_share (51) 扩展的密钥共享。浏览器声明的组
+	// 多于生成密钥的组，KeyShareCurves 中的组必须都在 supported_groups 中
+	SupportedGroups *tls.SupportedCurvesExtension
+	KeyShareCurves  *tls.KeyShareExtension
+
+	// RawExtensions 按扩展 ID 给出扩展的内容 (不含类型和长度字段)，JA3 中的这些扩展
+	// 原样发送，代替内置的实现或未知扩展的空内容，用于逐字节重现实验性或厂商私有的扩展。
+	// 不在 JA3 中的 ID 被忽略。握手依赖其内容的扩展 (server_name、supported_groups、
+	// signature_algorithms、ALPN、supported_versions、psk_key_exchange_modes、key_share、
+	// pre_shared_key、encrypted_client_hello) 不能使用，否则构建 ClientHello 时返回错误
+	RawExtensions map[uint16][]byte
+
+	// RemoveExtensions 从 JA3 生成的扩展中删除这些 ID 的扩展，如 17513 (ALPS)，
+	// 无需改写 JA3。发送的 ClientHello 的 JA3 因此与配置的 JA3 不同
+	RemoveExtensions []uint16
+
+	// ReplaceExtensions 用给定的扩展代替 JA3 中相同 ID 的扩展，如用自定义协议的
+	// *tls.ALPNExtension 代替 16，优先于 RawExtensions 和 ForceHTTP1 等设置。
+	// 每个连接使用其深拷贝。不在 JA3 中的 ID 被忽略。Clone 共享其中的扩展
+	ReplaceExtensions map[uint16]tls.TLSExtension `cbor:"-"`
+
+	// SessionTickets 决定是否使用会话票据恢复 TLS 会话，默认由 ClientHello 中的
+	// 扩展决定。TLSClientConfig.SessionTicketsDisabled 为 true 时总是禁用
+	SessionTickets SessionTicketMode
 
 	// 高级配置
 	NotUsedGREASE        bool   // 是否不使用 GREASE
 	ClientHelloHexStream string // 十六进制 ClientHello 流
 }
 
+// handshakeExtensions 是 utls 需要理解其内容的扩展，不能被 RawExtensions 替换
+var handshakeExtensions = map[uint16]string{
+	0:     "server_name",
+	10:    "supported_groups",
+	13:    "signature_algorithms",
+	16:    "application_layer_protocol_negotiation",
+	41:    "pre_shared_key",
+	43:    "supported_versions",
+	45:    "psk_key_exchange_modes",
+	51:    "key_share",
+	65037: "encrypted_client_hello",
+}
+
+// overrideExtension 返回 ext.ReplaceExtensions 或 ext.RawExtensions 中代替 JA3 中的扩展 extID 的扩展
+func (ext *TLSExtensionsConfig) overrideExtension(extID string) (tls.TLSExtension, bool, error) {
+	if ext == nil || len(ext.ReplaceExtensions) == 0 && len(ext.RawExtensions) == 0 {
+		return nil, false, nil
+	}
+	id, err := strconv.ParseUint(extID, 10, 16)
+	if err != nil {
+		return nil, false, nil
+	}
+	if e, ok := ext.ReplaceExtensions[uint16(id)]; ok && e != nil {
+		return cloneTLSExtension(e), true, nil
+	}
+	data, ok := ext.RawExtensions[uint16(id)]
+	if !ok {
+		return nil, false, nil
+	}
+	if name, ok := handshakeExtensions[uint16(id)]; ok {
+		return nil, false, fmt.Errorf("扩展 %d (%s) 的内容由握手决定，不能使用 RawExtensions", id, name)
+	}
+	return &tls.GenericExtension{Id: uint16(id), Data: slices.Clone(data)}, true, nil
+}
+
+// filterExtensions 返回删除 ext.RemoveExtensions 后的 JA3 扩展列表
+func (ext *TLSExtensionsConfig) filterExtensions(extensions []string) []string {
+	if ext == nil || len(ext.RemoveExtensions) == 0 {
+		return extensions
+	}
+	return slices.DeleteFunc(slices.Clone(extensions), func(e string) bool {
+		id, err := strconv.ParseUint(e, 10, 16)
+		return err == nil && slices.Contains(ext.RemoveExtensions, uint16(id))
+	})
+}
+
 // HTTP2Config 配置 HTTP/2 连接（Go 1.25 新特性）
 // 注意：这是 Go 1.25 新增的类型，目前在 Go 标准库中也还未完全实现
 // 根据 Go issue #67813，此功能仍在开发中
@@ -212,36 +289,41 @@ const DefaultMaxIdleConnsPerHost = 2
 
 // ensureInitialized 确保 Transport 的所有 map 都已初始化
 // 这是修复内存泄漏和并发问题的关键方法
+// 每个 map 都在其对应的锁下初始化，可被多个 RoundTrip 并发调用
 func (t *Transport) ensureInitialized() {
-	// 确保 idleConn map 已初始化
+	// 确保 idleConn / idleConnWait map 已初始化
+	t.idleMu.Lock()
 	if t.idleConn == nil {
 		t.idleConn = make(map[connectMethodKey][]*persistConn)
 	}
-
-	// 确保 idleConnWait map 已初始化
 	if t.idleConnWait == nil {
 		t.idleConnWait = make(map[connectMethodKey]wantConnQueue)
 	}
+	t.idleMu.Unlock()
 
 	// 确保 reqCanceler map 已初始化
+	t.reqMu.Lock()
 	if t.reqCanceler == nil {
 		t.reqCanceler = make(map[*Request]context.CancelCauseFunc)
 	}
+	t.reqMu.Unlock()
 
-	// 确保 connsPerHost map 已初始化
+	// 确保 connsPerHost / connsPerHostWait map 已初始化
+	t.connsPerHostMu.Lock()
 	if t.connsPerHost == nil {
 		t.connsPerHost = make(map[connectMethodKey]int)
 	}
-
-	// 确保 connsPerHostWait map 已初始化
 	if t.connsPerHostWait == nil {
 		t.connsPerHostWait = make(map[connectMethodKey]wantConnQueue)
 	}
+	t.connsPerHostMu.Unlock()
 
-	// 确保 ALPNProtocols slice 已初始化
-	if t.ALPNProtocols == nil {
-		t.ALPNProtocols = make([]string, 0)
-	}
+	// 确保 ALPNProtocols slice 已初始化（导出字段，只写一次）
+	t.alpnInitOnce.Do(func() {
+		if t.ALPNProtocols == nil {
+			t.ALPNProtocols = make([]string, 0)
+		}
+	})
 }
 
 // Transport is an implementation of [RoundTripper] that supports HTTP,
@@ -291,11 +373,44 @@ type Transport struct {
 	altMu    sync.Mutex   // guards changing altProto only
 	altProto atomic.Value // of nil or map[string]RoundTripper, key is URI scheme
 
+	altSvc altSvcCache // HTTP/3 services advertised via Alt-Svc, used with HTTP3
+
+	unsolicited    unsolicitedTracker // unsolicited data seen on idle conns
+	keepAliveRaces keepAliveRaces     // idle conns closed by servers, by target
+	retryCounters  retryCounters      // see RetryStats
+	certIntel      certIntelCache     // server certificate chains, by target and proxy
+	affinity       affinityProxies    // proxies pinned by affinity groups, see WithAffinity
+	rotation       rotationState      // see Rotation
+	proxySessions  proxySessionState  // see ProxySessions
+	connSeq        atomic.Uint64      // index of the next fingerprinted connection, see RandomSeed
+	greaseRand     seededRand         // see GREASEConfig.Seed
+	geo            geoCache           // exit countries, see GeoConsistency
+	corsCache      corsPreflightCache // see CORSPreflight
+
+	sessionCacheOnce sync.Once
+	sessionCache     tls.ClientSessionCache // default TLS session cache for fingerprinted conns
+
 	connsPerHostMu   sync.Mutex
 	connsPerHost     map[connectMethodKey]int
 	connsPerHostWait map[connectMethodKey]wantConnQueue // waiting getConns
 	dialsInProgress  wantConnQueue
 
+	alpnInitOnce sync.Once // guards ALPNProtocols initialization in ensureInitialized
+
+	// 存活的 readLoop/writeLoop goroutine 数量，仅在 tlshttpdebug 构建标签下统计
+	liveReadLoops  atomic.Int64
+	liveWriteLoops atomic.Int64
+
+	// connIdentities maps a net.Conn to its *connIdentity while the
+	// conn is being handed to a TLSNextProto function, so that the
+	// HTTP/2 ClientConn built from it can report the same identity.
+	connIdentities sync.Map
+
+	noProxy atomic.Pointer[NoProxyList] // set by SetNoProxy
+
+	proxyCredsMu sync.Mutex
+	proxyCreds   map[string]*proxyCredentials // see GetProxyCredentials
+
 	// Proxy specifies a function to return a proxy for a given
 	// Request. If the function returns a non-nil error, the
 	// request is aborted with the provided error.
@@ -312,6 +427,13 @@ type Transport struct {
 	// If Proxy is nil or returns a nil *URL, no proxy is used.
 	Proxy func(*Request) (*url.URL, error)
 
+	// ProxyFailover, if non-nil, is called when the Transport
+	// cannot connect to the proxy chosen for req (failed), with the
+	// error it got. If retry is true, the connection is attempted
+	// again through next, or directly if next is nil.
+	// See PACResolver.Failover.
+	ProxyFailover func(req *Request, failed *url.URL, err error) (next *url.URL, retry bool)
+
 	// OnProxyConnectResponse is called when the Transport gets an HTTP response from
 	// a proxy for a CONNECT request. It's called before the check for a 200 OK response.
 	// If it returns an error, the request fails with that error.
@@ -386,13 +508,27 @@ type Transport struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// StrictContentLength, if true, makes reads from a response Body
+	// whose length disagrees with the server's declared Content-Length
+	// fail with a *ContentLengthMismatchError instead of a bare
+	// io.ErrUnexpectedEOF. When the Transport transparently decompresses
+	// the body, the check is applied to the compressed bytes received
+	// on the wire before decompression.
+	StrictContentLength bool
+
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
 	// connections across all hosts. Zero means no limit.
+	// Idle HTTP/2 connections are counted separately against the same
+	// limit unless HTTP2Transport.MaxIdleConns is set.
+	// Use SetMaxIdleConns to change it while the Transport is in use.
 	MaxIdleConns int
 
 	// MaxIdleConnsPerHost, if non-zero, controls the maximum idle
 	// (keep-alive) connections to keep per-host. If zero,
-	// DefaultMaxIdleConnsPerHost is used.
+	// DefaultMaxIdleConnsPerHost is used. A non-zero value also applies
+	// to HTTP/2 connections unless HTTP2Transport.MaxIdleConnsPerHost is
+	// set; if zero, idle HTTP/2 connections are not limited per host.
+	// Use SetMaxIdleConnsPerHost to change it while the Transport is in use.
 	MaxIdleConnsPerHost int
 
 	// MaxConnsPerHost optionally limits the total number of
@@ -400,6 +536,7 @@ type Transport struct {
 	// active, and idle states. On limit violation, dials will block.
 	//
 	// Zero means no limit.
+	// Use SetMaxConnsPerHost to change it while the Transport is in use.
 	MaxConnsPerHost int
 
 	// IdleConnTimeout is the maximum amount of time an idle
@@ -412,8 +549,18 @@ type Transport struct {
 	// time to wait for a server's response headers after fully
 	// writing the request (including its body, if any). This
 	// time does not include the time to read the response body.
+	//
+	// The timeout may be overridden per host with
+	// ResponseHeaderTimeoutByHost, or per request with
+	// WithResponseHeaderTimeout.
 	ResponseHeaderTimeout time.Duration
 
+	// ResponseHeaderTimeoutByHost optionally overrides
+	// ResponseHeaderTimeout for specific hosts. Keys are matched
+	// against the request's "host:port" first and then against the
+	// bare host name. A zero value disables the timeout for that host.
+	ResponseHeaderTimeoutByHost map[string]time.Duration
+
 	// ExpectContinueTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's first response headers after fully
 	// writing the request headers if the request has an
@@ -423,6 +570,32 @@ type Transport struct {
 	// This time does not include the time to send the request header.
 	ExpectContinueTimeout time.Duration
 
+	// Clock, if non-nil, is the time source for the Transport's own
+	// timers: idle connection expiry, TLSHandshakeTimeout,
+	// ResponseHeaderTimeout, ExpectContinueTimeout, and the HTTP/2
+	// ping and idle timers. Tests may set it to a *FakeClock and
+	// advance time explicitly instead of sleeping.
+	// If nil, the system clock is used.
+	//
+	// Deadlines carried by request contexts and net.Dialer timeouts
+	// are not affected.
+	Clock Clock
+
+	// RequestIDHeader, if non-empty, is the name of a header that
+	// the Transport sets to the request ID (see WithRequestID) on
+	// outgoing requests that don't already have it. It is empty by
+	// default, so no identifying header is sent; request IDs are
+	// then only visible locally, in traces, logs and errors.
+	RequestIDHeader string
+
+	// EgressPolicy, if non-nil, is consulted before each request
+	// is sent (EgressBeforeRoundTrip) and after each new connection
+	// is dialed (EgressAfterDial). It can allow the traffic, deny it
+	// so that RoundTrip fails with an *EgressDeniedError, or
+	// redirect it through a different proxy or address.
+	// EgressPolicy may be called concurrently.
+	EgressPolicy func(*EgressInfo) EgressDecision
+
 	// TLSNextProto specifies how the Transport switches to an
 	// alternate protocol (such as HTTP/2) after a TLS ALPN
 	// protocol negotiation. If Transport dials a TLS connection
@@ -450,6 +623,19 @@ type Transport struct {
 	// ignored.
 	GetProxyConnectHeader func(ctx context.Context, proxyURL *url.URL, target string) (Header, error)
 
+	// GetProxyCredentials optionally returns new credentials for
+	// proxyURL after the proxy answered 407 Proxy Authentication
+	// Required, either to a CONNECT request or to a request sent
+	// through it. attempt counts the 407 responses the request has
+	// received, starting at 1. The request is then retried, subject
+	// to MaxRetries and RetryBackoff, and this and later connections
+	// to the proxy use the returned credentials for Basic
+	// authentication instead of the proxy URL's userinfo.
+	// If it returns an error, the Transport's RoundTrip fails with
+	// that error. After 3 407 responses to the same request, the 407
+	// is reported as if GetProxyCredentials were nil.
+	GetProxyCredentials func(ctx context.Context, proxyURL *url.URL, attempt int) (user, pass string, err error)
+
 	// MaxResponseHeaderBytes specifies a limit on how many
 	// response bytes are allowed in the server's response
 	// header.
@@ -512,9 +698,11 @@ type Transport struct {
 	CustomALPN    bool     // 是否使用自定义 ALPN 协议
 
 	// JA4+ 指纹控制框架
-	JA4L      string // JA4L (距离/位置) 指纹控制
-	JA4X      string // JA4X (X509 证书) 指纹控制
-	CustomJA4 bool   // 是否使用自定义 JA4 指纹
+	JA4       string // 目标 JA4 (TLS 客户端) 指纹，设置后建连前按其调整并校验 ClientHello
+	JA4H      string // 目标 JA4H (HTTP 客户端) 指纹，设置后取得连接前按其调整并校验每个请求的头部，不一致时返回 *JA4HError，不使用 HTTP3
+	JA4L      string // 目标 JA4L (距离) 指纹，如 "2500_128"，设置后推迟第二轮握手消息使服务端测得的延迟 (微秒) 接近目标，TTL 部分被忽略
+	JA4X      string // 固定服务端叶子证书的 JA4X，多个值以逗号分隔，不一致时中止连接并返回 *JA4XError
+	CustomJA4 bool   // 已不再使用：上面的 JA4+ 字段设置后即生效
 
 	// HTTP/2 设置完整控制
 	HTTP2Settings *HTTP2Settings // HTTP/2 设置控制
@@ -524,6 +712,225 @@ type Transport struct {
 	TLSFingerprint       *TLSFingerprintConfig // 完整配置，用于高级用户
 	UseCustomTLS         bool                  // 手动启用自定义 TLS
 	RandomizeFingerprint bool                  // 手动启用指纹随机化
+
+	// 合规模式：StrictTLS 为 true 时，即使 JA3 或十六进制流中包含，
+	// 也拒绝发送 EXPORT、NULL、RC4 密码套件和 SSL3 时代的扩展，建连返回
+	// *WeakTLSError。AllowWeakTLS 显式关闭该检查，用于有意模拟老旧客户端
+	StrictTLS    bool
+	AllowWeakTLS bool
+
+	// FIPSMode 为 true 时，即使进程未启用 GODEBUG=fips140，ClientHello 也只保留
+	// FIPS 140-3 批准的算法，详见 FIPSError。启用 fips140 时总是如此
+	FIPSMode bool
+
+	// FingerprintStats 非 nil 时，按配置的指纹和目标地址记录每个请求的结果
+	// (2xx、403、429、握手失败等)，用于发现被识别的指纹。Clone 共享同一个统计
+	FingerprintStats *FingerprintStats
+
+	// ConnSelection 决定有多个可用连接时使用哪一个，默认使用最近使用的连接
+	ConnSelection ConnSelectionPolicy
+
+	// ECH 非 nil 时，指纹包含 encrypted_client_hello 扩展的连接通过它查询
+	// 目标的 DNS HTTPS 记录，有 ECH 配置时发送加密的 ClientHello，详见 ECHResolver
+	ECH *ECHResolver
+
+	// HTTP3 非 nil 时，服务端通过 Alt-Svc 通告了 h3 的源站，其后直连的 HTTPS 请求
+	// 改由它发送，如基于 uquic 的 HTTP/3 实现。本包不实现 QUIC，QUIC Initial 的
+	// 指纹完全由它控制。请求的 URL 仍为源站，要连接的备选服务地址由
+	// HTTP3AddrFromContext 取得。Transport.Proxy 等为请求选择了代理时不使用它。
+	// 它返回错误时，该源站的 HTTP/3 服务暂停使用 5 分钟，请求回退到 TCP
+	HTTP3 RoundTripper
+
+	// OnUnsolicitedData 非 nil 时，空闲的 HTTP/1 连接收到未请求的数据 (如代理注入的
+	// 内容) 后以该数据和对端地址调用，UnsolicitedDataPolicy 决定是否记录日志和
+	// 禁止复用到该目标的连接。次数可以通过 UnsolicitedDataCount 查询
+	OnUnsolicitedData     func(UnsolicitedData)
+	UnsolicitedDataPolicy UnsolicitedDataPolicy
+
+	// ClientSessionCache 是所有连接共享的 TLS 会话缓存，条目按 SNI 区分，
+	// 同一 SNI 的后续连接通过它恢复会话。TLSClientConfig.ClientSessionCache
+	// 优先；都为 nil 时指纹连接使用 Transport 内部的缓存，标准 TLS 连接不恢复会话。
+	// Clone 共享同一个缓存
+	ClientSessionCache tls.ClientSessionCache
+
+	// KeyLogWriter 非 nil 时，指纹连接和标准 TLS 连接的握手密钥以 NSS key log
+	// 格式写入其中，Wireshark 等工具可以用它解密流量。TLSClientConfig.KeyLogWriter 优先。
+	// 使用它会破坏 TLS 的安全性，只应用于调试
+	KeyLogWriter io.Writer
+
+	// KeyLogFromEnv 为 true 且没有设置 KeyLogWriter 时，握手密钥写入 SSLKEYLOGFILE
+	// 环境变量指定的文件。默认不读取该变量，避免环境中遗留的变量悄悄泄露密钥
+	KeyLogFromEnv bool
+
+	// CTLogs 非空时，用这些 CT 日志的公钥校验服务端通过 TLS 扩展、证书和
+	// OCSP 响应提供的 SCT，结果见 httptrace.GotConnInfo.SCTs 和
+	// ResponseMeta.SCTs。校验结果只用于报告，不会中止连接
+	CTLogs []CTLog
+
+	// IPSNI 决定连接 IP 地址的指纹连接是否发送 server_name 扩展，
+	// 默认与浏览器一样不发送。TLSClientConfig.ServerName 非空时不适用
+	IPSNI IPSNIPolicy
+
+	// OnClientHello 非 nil 时，每次 TLS 握手 (包括到 HTTPS 代理的握手) 结束后，
+	// 无论成功与否，以连接的主机名和实际发出的 ClientHello 握手消息
+	// (包括 4 字节的消息头) 调用。服务端发送 HelloRetryRequest 时调用两次。
+	// raw 归调用方所有，可用于离线校验指纹或与抓包结果比对
+	OnClientHello func(host string, raw []byte)
+
+	// IsolateCredentials 为 true 时，连接池按请求凭据所属的身份划分，身份不同的
+	// 请求 (包括没有凭据的请求) 从不共享连接，以免服务端据此关联账号，或 NTLM 等
+	// 基于连接的认证被其他请求继承。身份取自 Authorization 的用户名等不随请求变化的
+	// 部分，NTLM 和 Negotiate 只按方案划分，以便握手在同一个连接上完成；
+	// Cookie 只在列入 CredentialCookies 时参与划分。需要显式的隔离键时使用 WithAffinity
+	IsolateCredentials bool
+
+	// CredentialCookies 是 IsolateCredentials 时标识账号的 Cookie 名称，如
+	// 登录会话的 Cookie。其余 Cookie 的值常常随响应更新，不参与划分
+	CredentialCookies []string
+
+	// Rotation 非 nil 时，HTTPS 请求按它的策略在多个指纹之间自动轮换，
+	// 连接池按指纹划分，详见 FingerprintRotation。Clone 共享同一个配置，
+	// 但各自从头开始轮换
+	Rotation *FingerprintRotation
+
+	// ProxySessions 非 nil 时，代理 URL 用户名和密码中的 {session} 等占位符
+	// 按亲和组替换为分配的会话 ID，详见 ProxySessions。Clone 共享同一个配置，
+	// 但各自分配会话
+	ProxySessions *ProxySessions
+
+	// RandomSeed 非 0 时，RandomJA3 和 RandomizeFingerprint 的扩展顺序随机化
+	// 使用由它和连接序号派生的伪随机数序列：种子相同时，第 n 个指纹连接的
+	// 扩展顺序在每次运行中相同，用于调试和可重放的测试。并发建连时各连接取得
+	// 哪个序号取决于建连顺序。BuildSpec 和 Fingerprint 返回下一个连接的结果，
+	// 不占用序号。GREASE 取值、密钥等仍使用密码学随机数。Clone 从序号 0 重新开始
+	RandomSeed int64
+
+	// PermuteExtensions 为 true 时，像 Chrome 110 及之后的版本一样为每个新连接
+	// 重新随机排列 ClientHello 的扩展，JA3、十六进制流、TLSFingerprint 等来源的
+	// 指纹都是如此。GREASE、padding 和 pre_shared_key 扩展保持原位，JA4 因此不变，
+	// JA3 则每个连接不同。RandomSeed 非 0 时使用其序列
+	PermuteExtensions bool
+
+	// GeoConsistency 非 nil 时，发送请求前检查出口国家与 Accept-Language 等头部
+	// 是否一致，详见 GeoConsistency。Clone 共享同一个配置，但各自缓存出口国家
+	GeoConsistency *GeoConsistency
+
+	// ClientHelloID 非零时直接使用 utls 内置的浏览器指纹，如 tls.HelloChrome_Auto、
+	// tls.HelloFirefox_120，不解析 JA3。它优先于 JA3、ClientHelloHexStream 和
+	// TLSFingerprint，WithFingerprint 指定的请求指纹仍优先于它。ALPN 按 ForceHTTP1
+	// 和 ALPNProtocols 调整，StrictTLS、FIPSMode、JA4 等照常生效。
+	//
+	// tls.HelloRandomized、HelloRandomizedALPN 和 HelloRandomizedNoALPN 为每个连接
+	// 生成一个不同但有效的 ClientHello，无需提供 JA3，适合不在意指纹一致性的场景。
+	// ID 的 Seed 非 nil 时每个连接相同；RandomSeed 非 0 时按其序列生成，可以重现。
+	// 不支持 HelloGolang 和 HelloCustom
+	ClientHelloID tls.ClientHelloID
+
+	// ClientHelloSpec 非 nil 时直接使用这个自行构建的 ClientHello，不解析 JA3，
+	// 适合需要完全控制扩展及其内容的场景。每个连接使用它的深拷贝，
+	// 设置后不应再修改。它优先于 ClientHelloID、JA3 等其他来源，WithFingerprint
+	// 指定的请求指纹仍优先于它。ALPN 和 SNI 以外的内容原样发送，
+	// StrictTLS、FIPSMode、JA4 等照常生效。Clone 共享同一个 spec
+	ClientHelloSpec *tls.ClientHelloSpec
+
+	// MutateClientHelloSpec 非 nil 时，在每次指纹握手前以最终的 spec 调用，
+	// 可以按主机修改扩展等内容，如按 SNI 选择 ALPN。host 是 SNI 使用的主机名，
+	// 连接 IP 地址时为该 IP。spec 是这个连接独有的副本，已经过 StrictTLS、
+	// FIPSMode、JA4 和 IPSNI 等处理，修改后不再检查。返回错误时连接失败。
+	// BuildSpec 和 Fingerprint 也会调用它。可能被并发调用
+	MutateClientHelloSpec func(spec *tls.ClientHelloSpec, host string) error
+
+	// ExtensiblePriorities 非 nil 时，HTTP/2 和 HTTP/3 请求像新版 Chrome 一样
+	// 发送 RFC 9218 的 priority 头部，可选地发送 PRIORITY_UPDATE 帧，
+	// 详见 ExtensiblePriorities。HTTP/1.1 请求不受影响
+	ExtensiblePriorities *ExtensiblePriorities
+
+	// HTTP2Coalescing 决定发往其他主机名的请求能否像浏览器一样复用已有的
+	// HTTP/2 连接，默认 CoalescingOff，每个主机名建立自己的连接，详见 CoalescingPolicy
+	HTTP2Coalescing CoalescingPolicy
+
+	// MaxRetries 是请求因连接失效自动重试的最多次数，如复用的空闲连接已被服务端关闭、
+	// HTTP/2 连接在请求发出前收到 GOAWAY。0 表示不限制，负数表示不重试。
+	// 超过后 RoundTrip 返回包含最后一次错误的 *RetryLimitError，见 RetryStats
+	MaxRetries int
+
+	// RetryBackoff 非 nil 时返回第 n 次 (从 1 开始) 重试前的等待时间，
+	// 如 ExponentialRetryBackoff。nil 时立即重试
+	RetryBackoff func(n int) time.Duration
+
+	// ConnMaxLifetime 大于 0 时，建立超过该时长的连接不再用于新请求，
+	// 进行中的请求不受影响，连接在请求完成后关闭
+	ConnMaxLifetime time.Duration
+
+	// ShouldReuseConnection 非 nil 时在连接完成请求后和从连接池取出时调用，
+	// 返回 false 则连接不再用于新请求，可以实现如 "100 个请求或 2 分钟后更换连接"
+	// 的策略，详见 ConnReuseInfo。调用时持有连接池的锁，不能调用 Transport 的方法
+	ShouldReuseConnection func(ConnReuseInfo) bool
+
+	// MixedContent 决定 WithDocument 的 HTTPS 页面发起的 http:// 子资源请求
+	// 如何处理，默认 MixedContentAllow 照常发出，详见 MixedContentPolicy。
+	// 到 localhost 和环回地址的请求不算混合内容
+	MixedContent MixedContentPolicy
+
+	// KeyShareKey 非 nil 时为指纹连接 key_share 扩展的每个组 (GREASE 除外) 提供
+	// ECDH 私钥，代替随机生成，用于让测试抓取的 ClientHello 逐字节可重现，
+	// 与 golden 文件比较。私钥必须属于该组的曲线，后量子混合组为其 X25519 私钥，
+	// ML-KEM 密钥由它导出。ClientHello 的随机数、会话 ID 和 GREASE 值取自
+	// TLSClientConfig.Rand，也需要固定。固定的私钥没有前向安全性，只能用于测试
+	KeyShareKey func(group tls.CurveID) (*ecdh.PrivateKey, error)
+
+	// CORSPreflight 为 true 时，WithDocument 页面发起的跨源 fetch/XHR 请求
+	// (Sec-Fetch-Mode 为空或 cors) 在方法或头部需要时，像浏览器一样先发送 OPTIONS
+	// 预检请求，带有 Origin、Access-Control-Request-Method/Headers 和 Sec-Fetch-* 头部。
+	// 预检失败时 RoundTrip 返回 *CORSError，不发出实际的请求。预检结果按
+	// Access-Control-Max-Age 缓存 (默认 5 秒，最长 2 小时)
+	CORSPreflight bool
+
+	// FetchMetadata 为 true 时，WithDocument 页面发起的请求像浏览器一样补全
+	// Origin 和 Sec-Fetch-Site/Mode/Dest 头部。Sec-Fetch-Dest 为空的请求按 fetch 处理，
+	// WebSocket 升级请求按 websocket 处理；WithSandboxedDocument 等不透明的源发送
+	// Origin: null。请求中已有的头部不变
+	FetchMetadata bool
+
+	// Storage 非 nil 时持久化指纹连接的 TLS 会话 (ClientSessionCache 为 nil 时的
+	// 内部缓存) 和 Alt-Svc 通告的 HTTP/3 服务，进程重启后仍可恢复会话和使用 HTTP/3。
+	// 见 MemoryStorage 和 FileStorage。Clone 共享同一个 Storage
+	Storage Storage
+
+	// GREASE 非 nil 时控制指纹连接 ClientHello 中 GREASE 值的选择 (每个连接随机、
+	// 以种子生成或固定) 和位置，见 GREASEConfig。nil 时使用 utls 为每个连接随机
+	// 选择的值和指纹中的位置。Clone 共享同一个配置
+	GREASE *GREASEConfig
+
+	// Padding 非 nil 时控制指纹连接 ClientHello 中 padding 扩展的长度 (BoringSSL 方式、
+	// 补齐到指定长度、固定长度、不发送或使用抓取的长度)，见 PaddingConfig。
+	// nil 时与 PaddingDefault 相同。Clone 共享同一个配置
+	Padding *PaddingConfig
+
+	// CertCompression 非 nil 时替换指纹连接 ClientHello 中 compress_certificate (27)
+	// 扩展声明的算法，如 Firefox 的 zlib、brotli、zstd，空切片表示不发送该扩展。
+	// nil 时使用指纹中的算法，JA3 按 UserAgent 所属浏览器选择 (Chrome 只声明 brotli)。
+	// 只改变指纹中已有的扩展，不添加它
+	CertCompression []tls.CertCompressionAlgo
+
+	// ALPS 非 nil 时控制指纹连接 ClientHello 中 ALPS (17513、17613) 扩展声明的协议
+	// 和握手时发送的客户端设置，或删除这两个扩展，见 ALPSConfig。
+	// nil 时使用指纹中的扩展和 TLSClientConfig.ApplicationSettings。Clone 共享同一个配置
+	ALPS *ALPSConfig
+
+	// BodyBudget 非 nil 时限制响应体中已读取但尚未关闭的总字节数，预算不足时
+	// 响应体的 Read 等待，见 BodyBudget。多个 Transport 可以共享同一个预算，Clone 也共享它。
+	// 单个请求可以用 WithoutBodyBudget 跳过
+	BodyBudget *BodyBudget
+
+	// QUICTransportParameters 非 nil 时替换指纹连接 ClientHello 中 quic_transport_parameters (57)
+	// 扩展的传输参数，如 tls.InitialMaxData、tls.MaxIdleTimeout，使其与特定浏览器相同。
+	// nil 时使用指纹中的参数，JA3 中的 57 扩展为空。只改变指纹中已有的扩展，不添加它
+	QUICTransportParameters tls.TransportParameters
+
+	// Redactor 遮盖 Transport 调试日志中请求和响应的敏感头部，nil 时使用 DefaultRedactor。
+	// 转储报文时使用 httputil 的 DumpRequestOutRedacted 等函数
+	Redactor Redactor
 }
 
 func (t *Transport) writeBufferSize() int {
@@ -548,6 +955,7 @@ func (t *Transport) Clone() *Transport {
 	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
 	t2 := &Transport{
 		Proxy:                  t.Proxy,
+		ProxyFailover:          t.ProxyFailover,
 		OnProxyConnectResponse: t.OnProxyConnectResponse,
 		DialContext:            t.DialContext,
 		Dial:                   t.Dial,
@@ -556,14 +964,19 @@ func (t *Transport) Clone() *Transport {
 		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
 		DisableKeepAlives:      t.DisableKeepAlives,
 		DisableCompression:     t.DisableCompression,
+		StrictContentLength:    t.StrictContentLength,
 		MaxIdleConns:           t.MaxIdleConns,
 		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
 		MaxConnsPerHost:        t.MaxConnsPerHost,
 		IdleConnTimeout:        t.IdleConnTimeout,
 		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
 		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		Clock:                  t.Clock,
+		RequestIDHeader:        t.RequestIDHeader,
+		EgressPolicy:           t.EgressPolicy,
 		ProxyConnectHeader:     t.ProxyConnectHeader.Clone(),
 		GetProxyConnectHeader:  t.GetProxyConnectHeader,
+		GetProxyCredentials:    t.GetProxyCredentials,
 		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
 		ForceAttemptHTTP2:      t.ForceAttemptHTTP2,
 		WriteBufferSize:        t.WriteBufferSize,
@@ -572,6 +985,8 @@ func (t *Transport) Clone() *Transport {
 	if t.TLSClientConfig != nil {
 		t2.TLSClientConfig = t.TLSClientConfig.Clone()
 	}
+	t2.ResponseHeaderTimeoutByHost = maps.Clone(t.ResponseHeaderTimeoutByHost)
+	t2.noProxy.Store(t.noProxy.Load())
 	if t.HTTP2 != nil {
 		t2.HTTP2 = &HTTP2Config{}
 		*t2.HTTP2 = *t.HTTP2
@@ -596,6 +1011,49 @@ func (t *Transport) Clone() *Transport {
 	t2.ClientHelloHexStream = t.ClientHelloHexStream
 	t2.UseCustomTLS = t.UseCustomTLS
 	t2.RandomizeFingerprint = t.RandomizeFingerprint
+	t2.StrictTLS = t.StrictTLS
+	t2.AllowWeakTLS = t.AllowWeakTLS
+	t2.FIPSMode = t.FIPSMode
+	t2.FingerprintStats = t.FingerprintStats
+	t2.ConnSelection = t.ConnSelection
+	t2.ECH = t.ECH
+	t2.HTTP3 = t.HTTP3
+	t2.OnUnsolicitedData = t.OnUnsolicitedData
+	t2.UnsolicitedDataPolicy = t.UnsolicitedDataPolicy
+	t2.ClientSessionCache = t.ClientSessionCache
+	t2.KeyLogWriter = t.KeyLogWriter
+	t2.KeyLogFromEnv = t.KeyLogFromEnv
+	t2.CTLogs = t.CTLogs
+	t2.IPSNI = t.IPSNI
+	t2.OnClientHello = t.OnClientHello
+	t2.IsolateCredentials = t.IsolateCredentials
+	t2.CredentialCookies = slices.Clone(t.CredentialCookies)
+	t2.Rotation = t.Rotation
+	t2.ProxySessions = t.ProxySessions
+	t2.RandomSeed = t.RandomSeed
+	t2.PermuteExtensions = t.PermuteExtensions
+	t2.GeoConsistency = t.GeoConsistency
+	t2.ClientHelloID = t.ClientHelloID
+	t2.ClientHelloSpec = t.ClientHelloSpec
+	t2.MutateClientHelloSpec = t.MutateClientHelloSpec
+	t2.ExtensiblePriorities = t.ExtensiblePriorities
+	t2.HTTP2Coalescing = t.HTTP2Coalescing
+	t2.MaxRetries = t.MaxRetries
+	t2.RetryBackoff = t.RetryBackoff
+	t2.ConnMaxLifetime = t.ConnMaxLifetime
+	t2.ShouldReuseConnection = t.ShouldReuseConnection
+	t2.MixedContent = t.MixedContent
+	t2.KeyShareKey = t.KeyShareKey
+	t2.CORSPreflight = t.CORSPreflight
+	t2.FetchMetadata = t.FetchMetadata
+	t2.Storage = t.Storage
+	t2.GREASE = t.GREASE
+	t2.Padding = t.Padding
+	t2.CertCompression = slices.Clone(t.CertCompression)
+	t2.ALPS = t.ALPS
+	t2.BodyBudget = t.BodyBudget
+	t2.QUICTransportParameters = slices.Clone(t.QUICTransportParameters)
+	t2.Redactor = t.Redactor
 
 	// 复制 ALPN 控制字段
 	t2.ALPNProtocols = make([]string, len(t.ALPNProtocols))
@@ -603,6 +1061,8 @@ func (t *Transport) Clone() *Transport {
 	t2.CustomALPN = t.CustomALPN
 
 	// 复制 JA4+ 控制字段
+	t2.JA4 = t.JA4
+	t2.JA4H = t.JA4H
 	t2.JA4L = t.JA4L
 	t2.JA4X = t.JA4X
 	t2.CustomJA4 = t.CustomJA4
@@ -839,6 +1299,9 @@ type transportRequest struct {
 
 	ctx    context.Context // canceled when we are done with the request
 	cancel context.CancelCauseFunc
+	id     string // request ID, for logs
+
+	redactor Redactor // masks secrets in logf output, DefaultRedactor if nil
 
 	mu  sync.Mutex // guards err
 	err error      // first setError value for mapRoundTripError to consider
@@ -885,6 +1348,10 @@ func (t *Transport) alternateRoundTripper(req *Request) RoundTripper {
 
 func validateHeaders(hdrs Header) string {
 	for k, vv := range hdrs {
+		if reqWriteExcludeHeader[k] {
+			// HeaderOrderKey 等控制用的键不会写到线上
+			continue
+		}
 		if !httpguts.ValidHeaderFieldName(k) {
 			return fmt.Sprintf("field name %q", k)
 		}
@@ -900,7 +1367,7 @@ func validateHeaders(hdrs Header) string {
 }
 
 // roundTrip implements a RoundTripper over HTTP.
-func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
+func (t *Transport) roundTrip(req *Request) (res *Response, err error) {
 	// 修复内存泄漏和并发问题：确保所有 map 都已初始化
 	t.ensureInitialized()
 
@@ -908,6 +1375,16 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 	ctx := req.Context()
 	trace := httptrace.ContextClientTrace(ctx)
 
+	reqID := requestID(req)
+	if trace != nil && trace.GotRequestID != nil {
+		trace.GotRequestID(reqID)
+	}
+	defer func() {
+		if err != nil {
+			err = &RequestIDError{ID: reqID, Err: err}
+		}
+	}()
+
 	if req.URL == nil {
 		req.closeBody()
 		return nil, errors.New("http: nil Request.URL")
@@ -932,11 +1409,54 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 		}
 	}
 
+	// origReq 是调用方的请求，用于取消和 resp.Request，下面的调整都作用于副本
 	origReq := req
+	mreq, err := t.applyMixedContent(req)
+	if err != nil {
+		req.closeBody()
+		return nil, err
+	}
+	scheme = mreq.URL.Scheme // 混合内容可能升级为 https
+	req = t.applyFetchMetadata(mreq)
+	if err := t.corsPreflight(req); err != nil {
+		req.closeBody()
+		return nil, err
+	}
+	req = t.rotateFingerprint(req)
+	if h := t.RequestIDHeader; h != "" && req.Header.Get(h) == "" {
+		r2 := *req
+		r2.Header = req.Header.Clone()
+		r2.Header.Set(h, reqID)
+		req = &r2
+	}
+	meta := newMetaRecorder(t)
+	if t.BodyBudget != nil {
+		ctx := req.Context()
+		defer func() {
+			if err == nil {
+				t.applyBodyBudget(ctx, res)
+			}
+		}()
+	}
+	if stats := t.FingerprintStats; stats != nil {
+		addr := canonicalAddr(req.URL)
+		defer func() {
+			if o, ok := classifyOutcome(res, err, meta.failedHandshake()); ok {
+				stats.Record(t.requestFingerprint(req, scheme), addr, o)
+			}
+		}()
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), meta.trace()))
+	trace = httptrace.ContextClientTrace(req.Context())
 	req = setupRewindBody(req)
 
-	if altRT := t.alternateRoundTripper(req); altRT != nil {
+	// 设置了 JA4H 时 HTTP/2 请求也经由下面的循环取得连接，以便发送前调整和校验
+	if altRT := t.alternateRoundTripper(req); altRT != nil && !(t.JA4H != "" && isHTTP) {
 		if resp, err := altRT.RoundTrip(req); err != ErrSkipAltProtocol {
+			if err == nil {
+				meta.attempt(t.requestFingerprint(req, scheme))
+				resp.Meta = meta.snapshot()
+			}
 			return resp, err
 		}
 		var err error
@@ -986,6 +1506,10 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 		}
 	}()
 
+	echRetried := false
+	h3Tried := false
+	retries := 0
+	proxyAuthAttempts := 0 // 本请求收到代理 407 响应的次数
 	for {
 		select {
 		case <-ctx.Done():
@@ -995,27 +1519,99 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 		}
 
 		// treq gets modified by roundTrip, so we need to recreate for each retry.
-		treq := &transportRequest{Request: req, trace: trace, ctx: ctx, cancel: cancel}
+		treq := &transportRequest{Request: req, trace: trace, ctx: ctx, cancel: cancel, id: reqID, redactor: t.Redactor}
 		cm, err := t.connectMethodForRequest(treq)
+		if err == nil && t.EgressPolicy != nil {
+			err = t.applyEgressPolicy(treq, &cm)
+		}
+		if err == nil && t.GeoConsistency != nil && t.GeoConsistency.Lookup != nil {
+			req = t.checkGeo(req, cm.proxyURL)
+			treq.Request = req
+		}
+		if err == nil && t.JA4H != "" {
+			// 在取得连接前调整和校验，不一致时不占用连接
+			req = t.shapeJA4H(req)
+			treq.Request = req
+			err = t.checkRequestJA4H(req, cm)
+		}
 		if err != nil {
 			req.closeBody()
 			return nil, err
 		}
 
+		// HTTP/3 只用于直连的请求：Transport.HTTP3 不经过代理，
+		// 使用它会绕过代理暴露真实 IP
+		if !h3Tried && cm.proxyURL == nil {
+			if h3req := t.http3Request(req); h3req != nil {
+				h3Tried = true
+				meta.attempt(t.requestFingerprint(req, scheme))
+				resp, err := t.HTTP3.RoundTrip(t.withPriorityHeader(h3req))
+				if err == nil {
+					cancel(errRequestDone)
+					t.observeAltSvc(req, resp)
+					resp.Request = origReq
+					resp.Meta = meta.snapshot()
+					if resp.Meta.Protocol == "" {
+						resp.Meta.Protocol = "h3"
+					}
+					return resp, nil
+				}
+				// 回退到 TCP
+				t.altSvc.markBroken(canonicalAddr(req.URL), t.now())
+				if req, err = rewindBody(req); err != nil {
+					return nil, err
+				}
+				treq.Request = req
+			}
+		}
+
 		// Get the cached or newly-created connection to either the
 		// host (for http or https), the http proxy, or the http proxy
 		// pre-CONNECTed to https server. In any case, we'll be ready
 		// to send it requests.
 		pconn, err := t.getConn(treq, cm)
+		if err != nil {
+			t.rotateProxySessionOnError(&cm, err)
+		}
+		if err != nil && t.ProxyFailover != nil {
+			pconn, err = t.failoverProxy(treq, &cm, err)
+		}
+		var pae *proxyAuthRequiredError
+		if errors.As(err, &pae) {
+			proxyAuthAttempts++
+			retry, rerr := t.refreshProxyCredentials(ctx, pae.proxyURL, proxyAuthAttempts)
+			if retry {
+				retries++
+				if rerr = t.beforeRetry(ctx, retries, pae); rerr == nil {
+					continue
+				}
+			}
+			err = pae.err
+			if rerr != nil {
+				err = rerr
+			}
+		}
+		if err != nil && !echRetried && t.echRetryable(err) {
+			// 服务端拒绝了 ECH 并提供了新配置，用新配置重试一次
+			echRetried = true
+			continue
+		}
+		if err == nil && t.JA4H != "" {
+			if err = t.checkConnJA4H(req, cm, pconn); err != nil && pconn.alt == nil {
+				t.putOrCloseIdleConn(pconn)
+			}
+		}
 		if err != nil {
 			req.closeBody()
 			return nil, err
 		}
 
+		meta.attempt(t.requestFingerprint(req, cm.targetScheme))
 		var resp *Response
 		if pconn.alt != nil {
 			// HTTP/2 path.
-			resp, err = pconn.alt.RoundTrip(req)
+			ctx := context.WithValue(req.Context(), h2PartitionKey{}, pconn.cacheKey.h2Partition())
+			resp, err = pconn.alt.RoundTrip(req.WithContext(ctx))
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -1028,7 +1624,36 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 				// canceling the context after the response body is read.
 				cancel(errRequestDone)
 			}
+			if pconn.isProxy && t.GetProxyCredentials != nil && resp.StatusCode == StatusProxyAuthRequired {
+				proxyAuthAttempts++
+				retry, err := t.refreshProxyCredentials(ctx, cm.proxyURL, proxyAuthAttempts)
+				if err != nil {
+					resp.Body.Close()
+					return nil, err
+				}
+				if retry {
+					if newReq, err := rewindBody(req); err == nil {
+						resp.Body.Close()
+						req = newReq
+						// The read loop cancels ctx once the body is
+						// closed, so the retry needs a fresh one.
+						ctx, cancel = context.WithCancelCause(req.Context())
+						if origReq.Cancel != nil {
+							go awaitLegacyCancel(ctx, cancel, origReq)
+						}
+						cancel = t.prepareTransportCancel(origReq, cancel)
+						retries++
+						if err := t.beforeRetry(ctx, retries, errProxyAuthRequired); err != nil {
+							req.closeBody()
+							return nil, err
+						}
+						continue
+					}
+				}
+			}
+			t.observeAltSvc(req, resp)
 			resp.Request = origReq
+			resp.Meta = meta.snapshot()
 			return resp, nil
 		}
 
@@ -1054,8 +1679,19 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 			}
 			return nil, err
 		}
+		if pconn.alt == nil {
+			reason := pconn.retryReason(err)
+			t.recordKeepAliveRace(pconn, reason, true)
+			meta.retried(reason)
+		}
 		testHookRoundTripRetried()
 
+		retries++
+		if err := t.beforeRetry(ctx, retries, err); err != nil {
+			req.closeBody()
+			return nil, err
+		}
+
 		// Rewind the body if we're able to.
 		req, err = rewindBody(req)
 		if err != nil {
@@ -1233,6 +1869,40 @@ func (t *Transport) CloseIdleConnections() {
 	}
 }
 
+// CloseIdleConnectionsFor is like CloseIdleConnections but only closes
+// idle connections to addr, a "host:port" target address.
+func (t *Transport) CloseIdleConnectionsFor(addr string) {
+	t.closeIdleMatching(func(key connectMethodKey) bool { return key.addr == addr })
+	if t2, ok := t.H2Transport.(*HTTP2Transport); ok {
+		t2.CloseIdleConnectionsFor(addr)
+	}
+}
+
+// closeIdleMatching closes the idle HTTP/1 connections whose key
+// satisfies match.
+func (t *Transport) closeIdleMatching(match func(connectMethodKey) bool) {
+	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
+	t.idleMu.Lock()
+	var closing []*persistConn
+	for key, conns := range t.idleConn {
+		if !match(key) {
+			continue
+		}
+		for _, pconn := range conns {
+			if pconn.idleTimer != nil {
+				pconn.idleTimer.Stop()
+			}
+			t.idleLRU.remove(pconn)
+		}
+		closing = append(closing, conns...)
+		delete(t.idleConn, key)
+	}
+	t.idleMu.Unlock()
+	for _, pconn := range closing {
+		pconn.close(errCloseIdleConns)
+	}
+}
+
 // prepareTransportCancel sets up state to convert Transport.CancelRequest into context cancelation.
 func (t *Transport) prepareTransportCancel(req *Request, origCancel context.CancelCauseFunc) context.CancelCauseFunc {
 	// Historically, RoundTrip has not modified the Request in any way.
@@ -1276,36 +1946,84 @@ func (t *Transport) CancelRequest(req *Request) {
 // Private implementation past this point.
 //
 
-var (
-	envProxyOnce      sync.Once
-	envProxyFuncValue func(*url.URL) (*url.URL, error)
-)
-
-// envProxyFunc returns a function that reads the
-// environment variable to determine the proxy address.
-func envProxyFunc() func(*url.URL) (*url.URL, error) {
-	envProxyOnce.Do(func() {
-		envProxyFuncValue = httpproxy.FromEnvironment().ProxyFunc()
-	})
-	return envProxyFuncValue
-}
-
 // resetProxyConfig is used by tests.
 func resetProxyConfig() {
-	envProxyOnce = sync.Once{}
-	envProxyFuncValue = nil
+	ResetProxyEnvironment()
 }
 
 func (t *Transport) connectMethodForRequest(treq *transportRequest) (cm connectMethod, err error) {
 	cm.targetScheme = treq.URL.Scheme
 	cm.targetAddr = canonicalAddr(treq.URL)
+	cm.partition = t.requestPartition(treq.Request)
+	if fp, ok := FingerprintFromContext(treq.Request.Context()); ok {
+		cm.fingerprint = &fp
+	}
 	if t.Proxy != nil {
-		cm.proxyURL, err = t.Proxy(treq.Request)
+		if a := cm.partition.affinity; a != "" {
+			cm.proxyURL, err = t.affinity.proxy(cm.affinityTarget(), func() (*url.URL, error) {
+				return t.Proxy(treq.Request)
+			})
+		} else {
+			cm.proxyURL, err = t.Proxy(treq.Request)
+		}
 	}
+	if cm.proxyURL != nil && t.noProxy.Load().Match(cm.targetAddr) {
+		cm.proxyURL = nil
+	}
+	cm.proxyURL = t.proxyForSession(cm.proxyURL, cm.partition.affinity)
 	cm.onlyH1 = treq.requiresHTTP1()
 	return cm, err
 }
 
+// maxProxyFailovers bounds the number of ProxyFailover attempts per request.
+const maxProxyFailovers = 8
+
+// failoverProxy retries getConn through the proxies suggested by
+// t.ProxyFailover after getConn failed with err to connect to the
+// proxy in cm.
+func (t *Transport) failoverProxy(treq *transportRequest, cm *connectMethod, err error) (*persistConn, error) {
+	for i := 0; i < maxProxyFailovers; i++ {
+		var oe *net.OpError
+		if cm.proxyURL == nil || !errors.As(err, &oe) || oe.Op != "proxyconnect" {
+			return nil, err
+		}
+		failed := cm.proxyURL
+		next, retry := t.ProxyFailover(treq.Request, failed, err)
+		if !retry {
+			return nil, err
+		}
+		// With ProxySessions, returning the failed template retries it with
+		// a new session if RotateOnError rotated the old one.
+		cm.proxyURL = t.proxyForSession(next, cm.partition.affinity)
+		if next != nil && cm.proxyURL.String() == failed.String() {
+			return nil, err
+		}
+		if t.EgressPolicy != nil {
+			if perr := t.applyEgressPolicy(treq, cm); perr != nil {
+				return nil, perr
+			}
+		}
+		var pconn *persistConn
+		if pconn, err = t.getConn(treq, *cm); err == nil {
+			if cm.partition.affinity != "" {
+				t.affinity.pin(cm.affinityTarget(), next)
+			}
+			return pconn, nil
+		}
+		t.rotateProxySessionOnError(cm, err)
+	}
+	return nil, err
+}
+
+// proxyConnectStatusError is returned by dialConn when the proxy
+// answers CONNECT with a non-200 status.
+type proxyConnectStatusError struct {
+	code int
+	text string
+}
+
+func (e *proxyConnectStatusError) Error() string { return e.text }
+
 // proxyAuth returns the Proxy-Authorization header to set
 // on requests, if applicable.
 func (cm *connectMethod) proxyAuth() string {
@@ -1375,17 +2093,28 @@ func (t *Transport) maxIdleConnsPerHost() int {
 // an error explaining why it wasn't registered.
 // tryPutIdleConn does not close pconn. Use putOrCloseIdleConn instead for that.
 func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
-	if t.DisableKeepAlives || t.MaxIdleConnsPerHost < 0 {
+	if t.DisableKeepAlives {
 		return errKeepAlivesDisabled
 	}
 	if pconn.isBroken() {
 		return errConnBroken
 	}
+	if pconn.alt == nil && t.unsolicited.banned(pconn.cacheKey.addr, t.now()) {
+		return errUnsolicitedBanned
+	}
 	pconn.markReused()
+	if pconn.alt == nil && !t.shouldReuseConn(pconn.reuseInfo(0)) {
+		return errConnRetired
+	}
 
 	t.idleMu.Lock()
 	defer t.idleMu.Unlock()
 
+	// Checked under idleMu, which guards changes by SetMaxIdleConnsPerHost.
+	if t.MaxIdleConnsPerHost < 0 {
+		return errKeepAlivesDisabled
+	}
+
 	// HTTP/2 (pconn.alt != nil) connections do not come out of the idle list,
 	// because multiple goroutines can use them simultaneously.
 	// If this is an HTTP/2 connection being “returned,” we're done.
@@ -1405,7 +2134,7 @@ func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
 			// Loop over the waiting list until we find a w that isn't done already, and hand it pconn.
 			for q.len() > 0 {
 				w := q.popFront()
-				if w.tryDeliver(pconn, nil, time.Time{}) {
+				if w.tryHandOff(pconn) {
 					done = true
 					break
 				}
@@ -1417,7 +2146,7 @@ func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
 			// list unconditionally, for any future clients too.
 			for q.len() > 0 {
 				w := q.popFront()
-				w.tryDeliver(pconn, nil, time.Time{})
+				w.tryHandOff(pconn)
 			}
 		}
 		if q.len() == 0 {
@@ -1460,10 +2189,10 @@ func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
 		if pconn.idleTimer != nil {
 			pconn.idleTimer.Reset(t.IdleConnTimeout)
 		} else {
-			pconn.idleTimer = time.AfterFunc(t.IdleConnTimeout, pconn.closeConnIfStillIdle)
+			pconn.idleTimer = t.afterFunc(t.IdleConnTimeout, pconn.closeConnIfStillIdle)
 		}
 	}
-	pconn.idleAt = time.Now()
+	pconn.idleAt = t.now()
 	return nil
 }
 
@@ -1492,20 +2221,28 @@ func (t *Transport) queueForIdleConn(w *wantConn) (delivered bool) {
 	// conn.
 	var oldTime time.Time
 	if t.IdleConnTimeout > 0 {
-		oldTime = time.Now().Add(-t.IdleConnTimeout)
+		oldTime = t.now().Add(-t.IdleConnTimeout)
 	}
 
-	// Look for most recently-used idle connection.
+	// Look for most recently-used idle connection, or the least
+	// recently used one under the other ConnSelection policies.
 	if list, ok := t.idleConn[w.key]; ok {
 		stop := false
 		delivered := false
 		for len(list) > 0 && !stop {
-			pconn := list[len(list)-1]
+			i := len(list) - 1
+			if t.ConnSelection != ConnSelectionMRU {
+				i = 0
+			}
+			pconn := list[i]
 
 			// See whether this connection has been idle too long, considering
 			// only the wall time (the Round(0)), in case this is a laptop or VM
 			// coming out of suspend with previously cached idle connections.
 			tooOld := !oldTime.IsZero() && pconn.idleAt.Round(0).Before(oldTime)
+			if !tooOld && pconn.alt == nil {
+				tooOld = !t.shouldReuseConn(pconn.reuseInfo(t.now().Sub(pconn.idleAt)))
+			}
 			if tooOld {
 				// Async cleanup. Launch in its own goroutine (as if a
 				// time.AfterFunc called it); it acquires idleMu, which we're
@@ -1518,7 +2255,7 @@ func (t *Transport) queueForIdleConn(w *wantConn) (delivered bool) {
 				// from the idle list, or if this persistConn is too old (it was
 				// idle too long), then ignore it and look for another. In both
 				// cases it's already in the process of being closed.
-				list = list[:len(list)-1]
+				list = append(list[:i], list[i+1:]...)
 				continue
 			}
 			delivered = w.tryDeliver(pconn, nil, pconn.idleAt)
@@ -1530,7 +2267,7 @@ func (t *Transport) queueForIdleConn(w *wantConn) (delivered bool) {
 					// HTTP/1: only one client can use pconn.
 					// Remove it from the list.
 					t.idleLRU.remove(pconn)
-					list = list[:len(list)-1]
+					list = append(list[:i], list[i+1:]...)
 				}
 			}
 			stop = true
@@ -1613,9 +2350,185 @@ func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, e
 		}
 		return c, err
 	}
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && (trace.DNSStart != nil || trace.DNSDone != nil) {
+		return dialTracingDNS(ctx, trace, network, addr)
+	}
 	return zeroDialer.DialContext(ctx, network, addr)
 }
 
+// dialTracingDNS is like zeroDialer.DialContext but resolves addr
+// itself to report DNSStart and DNSDone, which the net package only
+// reports to its own trace hooks. The resolved addresses are dialed
+// the way net.Dialer dials them, so that tracing does not change how
+// connections are made: addresses of the first address family are
+// tried in order, each with a share of the remaining timeout, and
+// after FallbackDelay the other family is raced against them
+// (RFC 6555 Happy Eyeballs).
+func dialTracingDNS(ctx context.Context, trace *httptrace.ClientTrace, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return zeroDialer.DialContext(ctx, network, addr)
+	}
+	if trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ips, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, ip := range ips {
+		is4 := ip.IP.To4() != nil
+		if network == "tcp4" && !is4 || network == "tcp6" && is4 {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	if len(addrs) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+
+	if zeroDialer.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, zeroDialer.Timeout)
+		defer cancel()
+	}
+	primaries, fallbacks := partitionDialAddrs(addrs)
+	if len(fallbacks) == 0 || zeroDialer.FallbackDelay < 0 {
+		return dialSerial(ctx, network, primaries)
+	}
+	return dialParallel(ctx, network, primaries, fallbacks)
+}
+
+// partitionDialAddrs splits addrs into those of the same address
+// family as the first one and the rest, keeping their order.
+func partitionDialAddrs(addrs []string) (primaries, fallbacks []string) {
+	is4 := func(a string) bool {
+		host, _, _ := net.SplitHostPort(a)
+		return net.ParseIP(host).To4() != nil
+	}
+	first := is4(addrs[0])
+	for _, a := range addrs {
+		if is4(a) == first {
+			primaries = append(primaries, a)
+		} else {
+			fallbacks = append(fallbacks, a)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialParallel races dialSerial over primaries against dialSerial
+// over fallbacks, giving the primaries a head start of FallbackDelay,
+// like net.Dialer does for dual-stack hosts.
+func dialParallel(ctx context.Context, network string, primaries, fallbacks []string) (net.Conn, error) {
+	type dialResult struct {
+		net.Conn
+		error
+		primary bool
+		done    bool
+	}
+	results := make(chan dialResult) // unbuffered
+	returned := make(chan struct{})
+	defer close(returned)
+
+	startRacer := func(ctx context.Context, primary bool) {
+		addrs := primaries
+		if !primary {
+			addrs = fallbacks
+		}
+		c, err := dialSerial(ctx, network, addrs)
+		select {
+		case results <- dialResult{Conn: c, error: err, primary: primary, done: true}:
+		case <-returned:
+			if c != nil {
+				c.Close()
+			}
+		}
+	}
+
+	var primary, fallback dialResult
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go startRacer(primaryCtx, true)
+
+	delay := zeroDialer.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	for {
+		select {
+		case <-fallbackTimer.C:
+			fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+			defer fallbackCancel()
+			go startRacer(fallbackCtx, false)
+
+		case res := <-results:
+			if res.error == nil {
+				return res.Conn, nil
+			}
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+			if primary.done && fallback.done {
+				return nil, primary.error
+			}
+			if res.primary && fallbackTimer.Stop() {
+				// The primaries failed before the timer fired:
+				// start the fallbacks now.
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
+
+// dialSerial dials addrs in order and returns the first successful
+// connection or the first error. Like net.Dialer, each address gets
+// an equal share of the time remaining before the deadline of ctx,
+// but at least two seconds.
+func dialSerial(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for i, addr := range addrs {
+		dialCtx := ctx
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				break
+			}
+			timeout := remaining / time.Duration(len(addrs)-i)
+			if timeout < 2*time.Second {
+				timeout = min(remaining, 2*time.Second)
+			}
+			var cancel context.CancelFunc
+			dialCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		c, err := zeroDialer.DialContext(dialCtx, network, addr)
+		if err == nil {
+			return c, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = context.Cause(ctx)
+	}
+	return nil, firstErr
+}
+
 // A wantConn records state about a wanted connection
 // (that is, an active call to getConn).
 // The conn may be gotten by dialing or by finding an idle connection,
@@ -1637,12 +2550,19 @@ type wantConn struct {
 	cancelCtx context.CancelFunc
 	done      bool             // true after delivered or canceled
 	result    chan connOrError // channel to deliver connection or error
+
+	queuedAt time.Time // when getConn started waiting
+
+	// Both guarded by Transport.connsPerHostMu:
+	dialQueuedAt time.Time     // when w was queued in connsPerHostWait
+	dialWait     time.Duration // time w spent in connsPerHostWait
 }
 
 type connOrError struct {
-	pc     *persistConn
-	err    error
-	idleAt time.Time
+	pc        *persistConn
+	err       error
+	idleAt    time.Time
+	handedOff bool // pc was released by another request while w waited
 }
 
 // waiting reports whether w is still waiting for an answer (connection or error).
@@ -1663,19 +2583,29 @@ func (w *wantConn) getCtxForDial() context.Context {
 
 // tryDeliver attempts to deliver pc, err to w and reports whether it succeeded.
 func (w *wantConn) tryDeliver(pc *persistConn, err error, idleAt time.Time) bool {
+	return w.deliver(connOrError{pc: pc, err: err, idleAt: idleAt})
+}
+
+// tryHandOff is like tryDeliver for a connection released by another
+// request while w was waiting in idleConnWait.
+func (w *wantConn) tryHandOff(pc *persistConn) bool {
+	return w.deliver(connOrError{pc: pc, handedOff: true})
+}
+
+func (w *wantConn) deliver(r connOrError) bool {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	if w.done {
 		return false
 	}
-	if (pc == nil) == (err == nil) {
+	if (r.pc == nil) == (r.err == nil) {
 		panic("net/http: internal error: misuse of tryDeliver")
 	}
 	w.ctx = nil
 	w.done = true
 
-	w.result <- connOrError{pc: pc, err: err, idleAt: idleAt}
+	w.result <- r
 	close(w.result)
 
 	return true
@@ -1829,6 +2759,7 @@ func (t *Transport) getConn(treq *transportRequest, cm connectMethod) (_ *persis
 		result:     make(chan connOrError, 1),
 		beforeDial: testHookPrePendingDial,
 		afterDial:  testHookPostPendingDial,
+		queuedAt:   t.now(),
 	}
 	defer func() {
 		if err != nil {
@@ -1844,6 +2775,9 @@ func (t *Transport) getConn(treq *transportRequest, cm connectMethod) (_ *persis
 	// Wait for completion or cancellation.
 	select {
 	case r := <-w.result:
+		if r.pc != nil && trace != nil && trace.ConnWaitDone != nil {
+			trace.ConnWaitDone(t.connWaitInfo(w, r))
+		}
 		// Trace success but only for HTTP/1.
 		// HTTP/2 calls trace.GotConn itself.
 		if r.pc != nil && r.pc.alt == nil && trace != nil && trace.GotConn != nil {
@@ -1851,9 +2785,10 @@ func (t *Transport) getConn(treq *transportRequest, cm connectMethod) (_ *persis
 				Conn:   r.pc.conn,
 				Reused: r.pc.isReused(),
 			}
+			r.pc.identity.fill(&info, "http/1.1")
 			if !r.idleAt.IsZero() {
 				info.WasIdle = true
-				info.IdleTime = time.Since(r.idleAt)
+				info.IdleTime = t.now().Sub(r.idleAt)
 			}
 			trace.GotConn(info)
 		}
@@ -1890,17 +2825,14 @@ func (t *Transport) queueForDial(w *wantConn) {
 	t.connsPerHostMu.Lock()
 	defer t.connsPerHostMu.Unlock()
 
-	if t.MaxConnsPerHost <= 0 {
-		t.startDialConnForLocked(w)
-		return
-	}
-
 	// 修复并发问题：确保 connsPerHost map 已初始化
 	if t.connsPerHost == nil {
 		t.connsPerHost = make(map[connectMethodKey]int)
 	}
 
-	if n := t.connsPerHost[w.key]; n < t.MaxConnsPerHost {
+	// Connections are counted even without a limit, so that
+	// SetMaxConnsPerHost can impose one at run time.
+	if n := t.connsPerHost[w.key]; t.MaxConnsPerHost <= 0 || n < t.MaxConnsPerHost {
 		t.connsPerHost[w.key] = n + 1
 		t.startDialConnForLocked(w)
 		return
@@ -1913,11 +2845,27 @@ func (t *Transport) queueForDial(w *wantConn) {
 	q.cleanFrontNotWaiting()
 	q.pushBack(w)
 	t.connsPerHostWait[w.key] = q
+	w.dialQueuedAt = t.now()
+}
+
+// connWaitInfo returns how long w was queued before it received r.
+func (t *Transport) connWaitInfo(w *wantConn, r connOrError) httptrace.ConnWaitInfo {
+	var info httptrace.ConnWaitInfo
+	if r.handedOff {
+		info.IdleWait = t.now().Sub(w.queuedAt)
+	}
+	t.connsPerHostMu.Lock()
+	info.DialWait = w.dialWait
+	t.connsPerHostMu.Unlock()
+	return info
 }
 
 // startDialConnFor calls dialConn in a new goroutine.
 // t.connsPerHostMu must be held.
 func (t *Transport) startDialConnForLocked(w *wantConn) {
+	if !w.dialQueuedAt.IsZero() {
+		w.dialWait = t.now().Sub(w.dialQueuedAt)
+	}
 	t.dialsInProgress.cleanFrontCanceled()
 	t.dialsInProgress.pushBack(w)
 	go func() {
@@ -1955,10 +2903,6 @@ func (t *Transport) dialConnFor(w *wantConn) {
 // decConnsPerHost decrements the per-host connection count for key,
 // which may in turn give a different waiting goroutine permission to dial.
 func (t *Transport) decConnsPerHost(key connectMethodKey) {
-	if t.MaxConnsPerHost <= 0 {
-		return
-	}
-
 	t.connsPerHostMu.Lock()
 	defer t.connsPerHostMu.Unlock()
 
@@ -1978,7 +2922,9 @@ func (t *Transport) decConnsPerHost(key connectMethodKey) {
 	// (Some goroutines on the wait list may have timed out or
 	// gotten a connection another way. If they're all gone,
 	// we don't want to kick off any spurious dial operations.)
-	if q := t.connsPerHostWait[key]; q.len() > 0 {
+	// If SetMaxConnsPerHost lowered the limit below n, the count
+	// is not handed on until enough connections have closed.
+	if q := t.connsPerHostWait[key]; q.len() > 0 && (t.MaxConnsPerHost <= 0 || n <= t.MaxConnsPerHost) {
 		done := false
 		for q.len() > 0 {
 			w := q.popFront()
@@ -2020,37 +2966,65 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 	if pconn.cacheKey.onlyH1 {
 		cfg.NextProtos = nil
 	}
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = pconn.t.ClientSessionCache
+	}
+	if cfg.KeyLogWriter == nil {
+		cfg.KeyLogWriter = pconn.t.keyLogWriter()
+	}
 	plainConn := pconn.conn
 
 	// ===== 我们原创的 TLS 指纹控制逻辑 =====
 	// 检查是否启用了自定义 TLS（支持简洁 API）
-	useCustomTLS := pconn.t.UseCustomTLS ||
-		pconn.t.JA3 != "" ||
-		pconn.t.ClientHelloHexStream != "" ||
-		pconn.t.TLSFingerprint != nil
+	useCustomTLS := pconn.t.usesCustomTLS() || pconn.fingerprint != nil
+
+	// 不同凭据和亲和组的连接不共享 TLS 会话
+	if p := pconn.cacheKey.partition; p.credentials != "" || p.affinity != "" {
+		cache := cfg.ClientSessionCache
+		if useCustomTLS {
+			cache = pconn.t.clientSessionCache(cfg)
+		}
+		if cache != nil {
+			cfg.ClientSessionCache = partitionSessionCache{cache, p.credentials + "#" + p.affinity}
+		}
+	}
 
 	var tlsConn interface {
 		net.Conn
 		HandshakeContext(context.Context) error
 		ConnectionState() tls.ConnectionState
 	}
-	var err error
+	// 测量握手往返时间，按目标 JA4L 调整握手节奏
+	lc, err := pconn.t.newJA4LConn(plainConn)
+	if err != nil {
+		plainConn.Close()
+		return err
+	}
 
 	if useCustomTLS {
+		// ClientHello 只构建一次，判断是否查询 ECH 配置和握手使用同一个 spec
+		spec, err := pconn.buildClientHelloSpec()
+		if err != nil {
+			return err
+		}
+		// 指纹包含 ECH 扩展时查询目标的 ECH 配置，代理自身的 TLS 不使用
+		if host, _, _ := net.SplitHostPort(pconn.cacheKey.addr); pconn.t.ECH != nil && host == name && cfg.EncryptedClientHelloConfigList == nil && specUsesECH(spec) {
+			cfg.EncryptedClientHelloConfigList = pconn.t.echConfigList(ctx, pconn.cacheKey.addr)
+		}
 		// 使用 utls 进行自定义 TLS 握手
-		tlsConn, err = pconn.createCustomTLSConn(plainConn, cfg)
+		tlsConn, err = pconn.uconnFromSpec(lc, cfg, spec)
 		if err != nil {
 			return err
 		}
 		// 注意：这里 tlsConn 已经是 *tls.UConn 类型
 	} else {
 		// 使用标准的 TLS 连接（tls.Client 返回 *tls.Conn）
-		tlsConn = tls.Client(plainConn, cfg)
+		tlsConn = tls.Client(lc, cfg)
 	}
 	errc := make(chan error, 2)
-	var timer *time.Timer // for canceling TLS handshake
+	var timer Timer // for canceling TLS handshake
 	if d := pconn.t.TLSHandshakeTimeout; d != 0 {
-		timer = time.AfterFunc(d, func() {
+		timer = pconn.t.afterFunc(d, func() {
 			errc <- tlsHandshakeTimeoutError{}
 		})
 	}
@@ -2065,7 +3039,11 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 		errc <- err
 	}()
 	if err := <-errc; err != nil {
-		plainConn.Close()
+		lc.Close()
+		var rej *tls.ECHRejectionError
+		if pconn.t.ECH != nil && errors.As(err, &rej) && len(rej.RetryConfigList) > 0 {
+			pconn.t.ECH.setRetryConfigs(pconn.cacheKey.addr, rej.RetryConfigList)
+		}
 		if err == (tlsHandshakeTimeoutError{}) {
 			// Now that we have closed the connection,
 			// wait for the call to HandshakeContext to return.
@@ -2074,12 +3052,22 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 		if trace != nil && trace.TLSHandshakeDone != nil {
 			trace.TLSHandshakeDone(tls.ConnectionState{}, err)
 		}
+		pconn.t.reportClientHellos(name, lc)
 		return err
 	}
+	pconn.t.reportClientHellos(name, lc)
 	cs := tlsConn.ConnectionState()
 	if trace != nil && trace.TLSHandshakeDone != nil {
 		trace.TLSHandshakeDone(cs, nil)
 	}
+	if uc, ok := tlsConn.(*tls.UConn); ok && uc.HandshakeState.Hello != nil {
+		if hello, err := parseClientHello(uc.HandshakeState.Hello.Raw); err == nil {
+			pconn.fingerprintHash = ja3Hash(hello.ja3())
+			pconn.ja3n = ja3Hash(hello.ja3n())
+		}
+	}
+	pconn.ja4l = lc.handshakeDone()
+	pconn.ja3s, pconn.ja4s = lc.serverFingerprints()
 	pconn.tlsState = &cs
 	pconn.conn = tlsConn
 	return nil
@@ -2089,17 +3077,71 @@ type erringRoundTripper interface {
 	RoundTripErr() error
 }
 
-var testHookProxyConnectTimeout = context.WithTimeout
-
-func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *persistConn, err error) {
-	pconn = &persistConn{
+var testHookProxyConnectTimeout = context.WithTimeout
+
+func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *persistConn, err error) {
+	pconn, err = t.dialAndHandshake(ctx, cm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Possible unencrypted HTTP/2 with prior knowledge.
+	unencryptedHTTP2 := pconn.tlsState == nil &&
+		t.Protocols != nil &&
+		t.Protocols.UnencryptedHTTP2() &&
+		!t.Protocols.HTTP1()
+	if unencryptedHTTP2 {
+		next, ok := t.TLSNextProto[nextProtoUnencryptedHTTP2]
+		if !ok {
+			return nil, errors.New("http: Transport does not support unencrypted HTTP/2")
+		}
+		conn := unencryptedTLSConn(pconn.conn)
+		t.connIdentities.Store(conn, pconn.identity)
+		alt := next(cm.targetAddr, conn)
+		t.connIdentities.Delete(conn)
+		if e, ok := alt.(erringRoundTripper); ok {
+			// pconn.conn was closed by next (http2configureTransports.upgradeFn).
+			return nil, e.RoundTripErr()
+		}
+		return &persistConn{t: t, cacheKey: pconn.cacheKey, identity: pconn.identity, alt: alt}, nil
+	}
+
+	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
+		if next, ok := t.TLSNextProto[s.NegotiatedProtocol]; ok {
+			// 直接传递连接（支持 *tls.Conn 和 *tls.UConn）
+			t.connIdentities.Store(pconn.conn, pconn.identity)
+			alt := next(cm.targetAddr, pconn.conn)
+			t.connIdentities.Delete(pconn.conn)
+			if e, ok := alt.(erringRoundTripper); ok {
+				// pconn.conn was closed by next (http2configureTransports.upgradeFn).
+				return nil, e.RoundTripErr()
+			}
+			return &persistConn{t: t, cacheKey: pconn.cacheKey, identity: pconn.identity, alt: alt}, nil
+		}
+	}
+
+	pconn.br = bufio.NewReaderSize(pconn, t.readBufferSize())
+	pconn.bw = bufio.NewWriterSize(persistConnWriter{pconn}, t.writeBufferSize())
+
+	go pconn.readLoop()
+	go pconn.writeLoop()
+	return pconn, nil
+}
+
+// dialAndHandshake dials the connection for cm, sets up any proxy
+// tunnel and completes the TLS handshake, but does not start the
+// read and write loops or hand the conn to TLSNextProto.
+func (t *Transport) dialAndHandshake(ctx context.Context, cm connectMethod) (*persistConn, error) {
+	pconn := &persistConn{
 		t:             t,
 		cacheKey:      cm.key(),
+		fingerprint:   cm.fingerprint,
 		reqch:         make(chan requestAndChan, 1),
 		writech:       make(chan writeRequest, 1),
 		closech:       make(chan struct{}),
 		writeErrCh:    make(chan error, 1),
 		writeLoopDone: make(chan struct{}),
+		createdAt:     t.now(),
 	}
 	trace := httptrace.ContextClientTrace(ctx)
 	wrapErr := func(err error) error {
@@ -2110,6 +3152,13 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		return err
 	}
 	if cm.scheme() == "https" && t.hasCustomTLSDialer() {
+		// DialTLS handshakes before returning the conn, so check before dialing.
+		if t.EgressPolicy != nil {
+			host, _, _ := net.SplitHostPort(cm.addr())
+			if err := t.checkDialedEgress(&cm, net.ParseIP(host)); err != nil {
+				return nil, err
+			}
+		}
 		var err error
 		pconn.conn, err = t.customDialTLS(ctx, "tcp", cm.addr())
 		if err != nil {
@@ -2135,10 +3184,29 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			pconn.tlsState = &cs
 		}
 	} else {
+		// The net package reports per-address connects only to its own
+		// trace hooks, which this package cannot install, so report
+		// the dial as a whole.
+		if trace != nil && trace.ConnectStart != nil {
+			trace.ConnectStart("tcp", cm.addr())
+		}
 		conn, err := t.dial(ctx, "tcp", cm.addr())
+		if trace != nil && trace.ConnectDone != nil {
+			trace.ConnectDone("tcp", cm.addr(), err)
+		}
 		if err != nil {
 			return nil, wrapErr(err)
 		}
+		if t.EgressPolicy != nil {
+			var ip net.IP
+			if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+				ip = addr.IP
+			}
+			if err := t.checkDialedEgress(&cm, ip); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
 		pconn.conn = conn
 		if cm.scheme() == "https" {
 			var firstTLSHost string
@@ -2175,7 +3243,14 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	case cm.targetScheme == "http":
 		pconn.isProxy = true
-		if pa := cm.proxyAuth(); pa != "" {
+		if t.GetProxyCredentials != nil {
+			// Credentials may be refreshed while the conn is in use.
+			pconn.mutateHeaderFunc = func(h Header) {
+				if pa := t.proxyAuth(&cm); pa != "" {
+					h.Set("Proxy-Authorization", pa)
+				}
+			}
+		} else if pa := cm.proxyAuth(); pa != "" {
 			pconn.mutateHeaderFunc = func(h Header) {
 				h.Set("Proxy-Authorization", pa)
 			}
@@ -2196,7 +3271,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		if hdr == nil {
 			hdr = make(Header)
 		}
-		if pa := cm.proxyAuth(); pa != "" {
+		if pa := t.proxyAuth(&cm); pa != "" {
 			hdr = hdr.Clone()
 			hdr.Set("Proxy-Authorization", pa)
 		}
@@ -2255,9 +3330,13 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			_, text, ok := strings.Cut(resp.Status, " ")
 			conn.Close()
 			if !ok {
-				return nil, errors.New("unknown status code")
+				text = "unknown status code"
 			}
-			return nil, errors.New(text)
+			err := error(&proxyConnectStatusError{code: resp.StatusCode, text: text})
+			if t.GetProxyCredentials != nil && resp.StatusCode == StatusProxyAuthRequired {
+				err = &proxyAuthRequiredError{proxyURL: cm.proxyURL, err: err}
+			}
+			return nil, err
 		}
 	}
 
@@ -2267,41 +3346,21 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	}
 
-	// Possible unencrypted HTTP/2 with prior knowledge.
-	unencryptedHTTP2 := pconn.tlsState == nil &&
-		t.Protocols != nil &&
-		t.Protocols.UnencryptedHTTP2() &&
-		!t.Protocols.HTTP1()
-	if unencryptedHTTP2 {
-		next, ok := t.TLSNextProto[nextProtoUnencryptedHTTP2]
-		if !ok {
-			return nil, errors.New("http: Transport does not support unencrypted HTTP/2")
-		}
-		alt := next(cm.targetAddr, unencryptedTLSConn(pconn.conn))
-		if e, ok := alt.(erringRoundTripper); ok {
-			// pconn.conn was closed by next (http2configureTransports.upgradeFn).
-			return nil, e.RoundTripErr()
+	ja4x := peerJA4X(pconn.tlsState)
+	if pconn.tlsState != nil {
+		if err := t.checkJA4X(ja4x); err != nil {
+			pconn.conn.Close()
+			return nil, err
 		}
-		return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: alt}, nil
 	}
-
-	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
-		if next, ok := t.TLSNextProto[s.NegotiatedProtocol]; ok {
-			// 直接传递连接（支持 *tls.Conn 和 *tls.UConn）
-			alt := next(cm.targetAddr, pconn.conn)
-			if e, ok := alt.(erringRoundTripper); ok {
-				// pconn.conn was closed by next (http2configureTransports.upgradeFn).
-				return nil, e.RoundTripErr()
-			}
-			return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: alt}, nil
-		}
+	var scts []httptrace.SCT
+	if cm.targetScheme == "https" {
+		scts = t.verifySCTs(pconn.tlsState)
+	}
+	pconn.identity = newConnIdentity(cm, pconn, ja4x, scts)
+	if cm.targetScheme == "https" {
+		t.certIntel.record(pconn.cacheKey.addr, pconn.identity.proxy, pconn.tlsState, ja4x, t.now())
 	}
-
-	pconn.br = bufio.NewReaderSize(pconn, t.readBufferSize())
-	pconn.bw = bufio.NewWriterSize(persistConnWriter{pconn}, t.writeBufferSize())
-
-	go pconn.readLoop()
-	go pconn.writeLoop()
 	return pconn, nil
 }
 
@@ -2348,6 +3407,7 @@ var _ io.ReaderFrom = (*persistConnWriter)(nil)
 //	socks5://proxy.com|https|foo.com  socks5 to proxy, then https to foo.com
 //	https://proxy.com|https|foo.com   https to proxy, then CONNECT to foo.com
 //	https://proxy.com|http            https to proxy, http to anywhere after that
+//	|https|foo.com#acct1              https directly to server, in affinity group acct1 (see connPartition)
 type connectMethod struct {
 	_            incomparable
 	proxyURL     *url.URL // nil for no proxy, else full proxy URL
@@ -2356,7 +3416,13 @@ type connectMethod struct {
 	// then targetAddr is not included in the connect method key, because the socket can
 	// be reused for different targetAddr values.
 	targetAddr string
-	onlyH1     bool // whether to disable HTTP/2 and force HTTP/1
+	onlyH1     bool   // whether to disable HTTP/2 and force HTTP/1
+	dialAddr   string // if non-empty, dialed instead of targetAddr for direct connections (see EgressDecision.Addr)
+
+	// partition separates connections of different per-request
+	// fingerprints, credentials and affinity groups.
+	partition   connPartition
+	fingerprint *RequestFingerprint // per-request fingerprint set with WithFingerprint, or nil
 }
 
 func (cm *connectMethod) key() connectMethodKey {
@@ -2368,11 +3434,17 @@ func (cm *connectMethod) key() connectMethodKey {
 			targetAddr = ""
 		}
 	}
+	dialAddr := ""
+	if cm.proxyURL == nil {
+		dialAddr = cm.dialAddr
+	}
 	return connectMethodKey{
-		proxy:  proxyStr,
-		scheme: cm.targetScheme,
-		addr:   targetAddr,
-		onlyH1: cm.onlyH1,
+		proxy:     proxyStr,
+		scheme:    cm.targetScheme,
+		addr:      targetAddr,
+		onlyH1:    cm.onlyH1,
+		dialAddr:  dialAddr,
+		partition: cm.partition,
 	}
 }
 
@@ -2389,6 +3461,9 @@ func (cm *connectMethod) addr() string {
 	if cm.proxyURL != nil {
 		return canonicalAddr(cm.proxyURL)
 	}
+	if cm.dialAddr != "" {
+		return cm.dialAddr
+	}
 	return cm.targetAddr
 }
 
@@ -2408,15 +3483,20 @@ func (cm *connectMethod) tlsHost() string {
 type connectMethodKey struct {
 	proxy, scheme, addr string
 	onlyH1              bool
+	dialAddr            string
+	partition           connPartition
 }
 
 func (k connectMethodKey) String() string {
-	// Only used by tests.
 	var h1 string
 	if k.onlyH1 {
 		h1 = ",h1"
 	}
-	return fmt.Sprintf("%s|%s%s|%s", k.proxy, k.scheme, h1, k.addr)
+	partition := k.partition.String()
+	if k.dialAddr != "" {
+		return fmt.Sprintf("%s|%s%s|%s@%s%s", k.proxy, k.scheme, h1, k.addr, k.dialAddr, partition)
+	}
+	return fmt.Sprintf("%s|%s%s|%s%s", k.proxy, k.scheme, h1, k.addr, partition)
 }
 
 // persistConn wraps a connection, usually a persistent one
@@ -2429,6 +3509,7 @@ type persistConn struct {
 
 	t         *Transport
 	cacheKey  connectMethodKey
+	identity  *connIdentity // reported via httptrace.GotConnInfo
 	conn      net.Conn
 	tlsState  *tls.ConnectionState
 	br        *bufio.Reader       // from conn
@@ -2448,16 +3529,44 @@ type persistConn struct {
 
 	writeLoopDone chan struct{} // closed when write loop ends
 
+	// fingerprint is the per-request fingerprint the conn is dialed
+	// with (see WithFingerprint), or nil for the Transport's own.
+	fingerprint *RequestFingerprint
+
+	// rng is the pseudo-random sequence derived from RandomSeed for
+	// this conn's ClientHello, or nil until first used. See prng.
+	rng *rand.Rand
+
+	// fingerprintHash is the JA3 hash of the ClientHello sent by
+	// addTLS, if it used a custom fingerprint.
+	fingerprintHash string
+
+	// ja3n is the JA3N hash of the same ClientHello, which ignores
+	// the extension order.
+	ja3n string
+
+	// ja4l is the latency part of the server's JA4L measured by
+	// addTLS. See ja4lConn.
+	ja4l string
+
+	// ja3s and ja4s are the server's JA3S hash and JA4S computed
+	// from the ServerHello read by addTLS.
+	ja3s, ja4s string
+
+	createdAt time.Time // when dialConn created it; see ConnReuseInfo.Age
+
 	// Both guarded by Transport.idleMu:
-	idleAt    time.Time   // time it last become idle
-	idleTimer *time.Timer // holding an AfterFunc to close it
+	idleAt    time.Time // time it last become idle
+	idleTimer Timer     // holding an AfterFunc to close it
 
 	mu                   sync.Mutex // guards following fields
 	numExpectedResponses int
-	closed               error // set non-nil when conn is closed, before closech is closed
-	canceledErr          error // set non-nil if conn is canceled
-	broken               bool  // an error has happened on this connection; marked broken so it's not reused.
-	reused               bool  // whether conn has had successful request/response and is being reused.
+	closed               error       // set non-nil when conn is closed, before closech is closed
+	canceledErr          error       // set non-nil if conn is canceled
+	broken               bool        // an error has happened on this connection; marked broken so it's not reused.
+	reused               bool        // whether conn has had successful request/response and is being reused.
+	requests             int         // number of requests completed, counted by markReused
+	idleCloseReason      RetryReason // why the server closed the conn while idle, if it did
 	// mutateHeaderFunc is an optional func to modify extra
 	// headers on each outbound request before it's written. (the
 	// original Request given to RoundTrip is not modified)
@@ -2596,6 +3705,10 @@ func (pc *persistConn) mapRoundTripError(req *transportRequest, startBytesWritte
 var errCallerOwnsConn = errors.New("read loop ending; caller owns writable underlying conn")
 
 func (pc *persistConn) readLoop() {
+	if debugGoroutines {
+		pc.t.liveReadLoops.Add(1)
+		defer pc.t.liveReadLoops.Add(-1)
+	}
 	closeErr := errReadLoopExiting // default value, if not changed below
 	defer func() {
 		pc.close(closeErr)
@@ -2635,8 +3748,15 @@ func (pc *persistConn) readLoop() {
 
 		pc.mu.Lock()
 		if pc.numExpectedResponses == 0 {
-			pc.readLoopPeekFailLocked(err)
+			unsolicited := pc.readLoopPeekFailLocked(err)
+			reason := pc.idleCloseReason
 			pc.mu.Unlock()
+			if reason != 0 {
+				pc.t.recordKeepAliveRace(pc, reason, false)
+			}
+			if unsolicited != nil {
+				pc.t.handleUnsolicitedData(pc, unsolicited, err)
+			}
 			return
 		}
 		pc.mu.Unlock()
@@ -2710,6 +3830,12 @@ func (pc *persistConn) readLoop() {
 			continue
 		}
 
+		var verifier *contentLengthVerifier
+		if pc.t.StrictContentLength && resp.ContentLength >= 0 {
+			verifier = &contentLengthVerifier{body: resp.Body, declared: resp.ContentLength}
+			resp.Body = verifier
+		}
+
 		waitForBodyRead := make(chan bool, 2)
 		body := &bodyEOFSignal{
 			body: resp.Body,
@@ -2740,6 +3866,9 @@ func (pc *persistConn) readLoop() {
 			resp.Header.Del("Content-Length")
 			resp.ContentLength = -1
 			resp.Uncompressed = true
+			if verifier != nil {
+				verifier.decompressed = true
+			}
 		}
 
 		select {
@@ -2773,18 +3902,23 @@ func (pc *persistConn) readLoop() {
 	}
 }
 
-func (pc *persistConn) readLoopPeekFailLocked(peekErr error) {
+// readLoopPeekFailLocked closes pc after it became readable while idle.
+// It returns a copy of the unsolicited data the server sent, if any,
+// which the caller passes to handleUnsolicitedData after unlocking pc.mu.
+func (pc *persistConn) readLoopPeekFailLocked(peekErr error) (unsolicited []byte) {
 	if pc.closed != nil {
-		return
+		return nil
 	}
 	if n := pc.br.Buffered(); n > 0 {
 		buf, _ := pc.br.Peek(n)
 		if is408Message(buf) {
+			pc.idleCloseReason = RetryReason408
 			pc.closeLocked(errServerClosedIdle)
-			return
-		} else {
-			log.Printf("Unsolicited response received on idle HTTP channel starting with %q; err=%v", buf, peekErr)
+			return nil
 		}
+		unsolicited = append([]byte(nil), buf...)
+	} else {
+		pc.idleCloseReason = closeReason(peekErr)
 	}
 	if peekErr == io.EOF {
 		// common case.
@@ -2792,6 +3926,7 @@ func (pc *persistConn) readLoopPeekFailLocked(peekErr error) {
 	} else {
 		pc.closeLocked(fmt.Errorf("readLoopPeekFailLocked: %w", peekErr))
 	}
+	return unsolicited
 }
 
 // is408Message reports whether buf has the prefix of an
@@ -2887,13 +4022,13 @@ func (pc *persistConn) waitForContinue(continueCh <-chan struct{}) func() bool {
 		return nil
 	}
 	return func() bool {
-		timer := time.NewTimer(pc.t.ExpectContinueTimeout)
+		timer := pc.t.newTimer(pc.t.ExpectContinueTimeout)
 		defer timer.Stop()
 
 		select {
 		case _, ok := <-continueCh:
 			return ok
-		case <-timer.C:
+		case <-timer.C():
 			return true
 		case <-pc.closech:
 			return false
@@ -2951,6 +4086,10 @@ func (nwe nothingWrittenError) Unwrap() error {
 }
 
 func (pc *persistConn) writeLoop() {
+	if debugGoroutines {
+		pc.t.liveWriteLoops.Add(1)
+		defer pc.t.liveWriteLoops.Add(-1)
+	}
 	defer close(pc.writeLoopDone)
 	for {
 		select {
@@ -3015,12 +4154,12 @@ func (pc *persistConn) wroteRequest() bool {
 		// but the server has already replied. In this case, we don't
 		// want to wait too long, and we want to return false so this
 		// connection isn't re-used.
-		t := time.NewTimer(maxWriteWaitBeforeConnReuse)
+		t := pc.t.newTimer(maxWriteWaitBeforeConnReuse)
 		defer t.Stop()
 		select {
 		case err := <-pc.writeErrCh:
 			return err == nil
-		case <-t.C:
+		case <-t.C():
 			return false
 		}
 	}
@@ -3080,6 +4219,35 @@ func (e *timeoutError) Is(err error) bool { return err == context.DeadlineExceed
 
 var errTimeout error = &timeoutError{"net/http: timeout awaiting response headers"}
 
+// responseHeaderTimeoutKey is the context key for WithResponseHeaderTimeout.
+type responseHeaderTimeoutKey struct{}
+
+// WithResponseHeaderTimeout returns a copy of ctx that overrides the
+// Transport's ResponseHeaderTimeout (and ResponseHeaderTimeoutByHost)
+// for requests made with it. A zero or negative d disables the
+// response header timeout for those requests.
+func WithResponseHeaderTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, responseHeaderTimeoutKey{}, d)
+}
+
+// responseHeaderTimeout returns the response header timeout that applies
+// to req. A per-request context override takes precedence over a
+// per-host override, which takes precedence over the Transport-wide value.
+func (t *Transport) responseHeaderTimeout(req *Request) time.Duration {
+	if d, ok := req.Context().Value(responseHeaderTimeoutKey{}).(time.Duration); ok {
+		return d
+	}
+	if len(t.ResponseHeaderTimeoutByHost) > 0 && req.URL != nil {
+		if d, ok := t.ResponseHeaderTimeoutByHost[canonicalAddr(req.URL)]; ok {
+			return d
+		}
+		if d, ok := t.ResponseHeaderTimeoutByHost[idnaASCIIFromURL(req.URL)]; ok {
+			return d
+		}
+	}
+	return t.ResponseHeaderTimeout
+}
+
 // errRequestCanceled is set to be identical to the one from h2 to facilitate
 // testing.
 var errRequestCanceled = http2errRequestCanceled
@@ -3092,17 +4260,31 @@ var errRequestDone = errors.New("net/http: request completed")
 func nop() {}
 
 // testHooks. Always non-nil.
+// The ones mirrored in package httptesthooks forward to the hooks
+// installed there by default, so they work outside this package too.
 var (
 	testHookEnterRoundTrip   = nop
 	testHookWaitResLoop      = nop
-	testHookRoundTripRetried = nop
-	testHookPrePendingDial   = nop
-	testHookPostPendingDial  = nop
+	testHookRoundTripRetried = exportedTestHook(func(h *httptesthooks.Hooks) func() { return h.RoundTripRetried })
+	testHookPrePendingDial   = exportedTestHook(func(h *httptesthooks.Hooks) func() { return h.PrePendingDial })
+	testHookPostPendingDial  = exportedTestHook(func(h *httptesthooks.Hooks) func() { return h.PostPendingDial })
 
 	testHookMu                     sync.Locker = fakeLocker{} // guards following
-	testHookReadLoopBeforeNextRead             = nop
+	testHookReadLoopBeforeNextRead             = exportedTestHook(func(h *httptesthooks.Hooks) func() { return h.ReadLoopBeforeNextRead })
 )
 
+// exportedTestHook returns a hook that calls the field of the currently
+// installed httptesthooks.Hooks selected by field, if any.
+func exportedTestHook(field func(*httptesthooks.Hooks) func()) func() {
+	return func() {
+		if h := httptesthooks.Current(); h != nil {
+			if f := field(h); f != nil {
+				f()
+			}
+		}
+	}
+}
+
 func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err error) {
 	testHookEnterRoundTrip()
 	pc.mu.Lock()
@@ -3198,13 +4380,13 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 				pc.close(fmt.Errorf("write error: %w", err))
 				return nil, pc.mapRoundTripError(req, startBytesWritten, err)
 			}
-			if d := pc.t.ResponseHeaderTimeout; d > 0 {
+			if d := pc.t.responseHeaderTimeout(req.Request); d > 0 {
 				if debugRoundTrip {
 					req.logf("starting timer for %v", d)
 				}
-				timer := time.NewTimer(d)
+				timer := pc.t.newTimer(d)
 				defer timer.Stop() // prevent leaks
-				respHeaderTimer = timer.C
+				respHeaderTimer = timer.C()
 			}
 		case <-pcClosed:
 			select {
@@ -3247,7 +4429,7 @@ type tLogKey struct{}
 
 func (tr *transportRequest) logf(format string, args ...any) {
 	if logf, ok := tr.Request.Context().Value(tLogKey{}).(func(string, ...any)); ok {
-		logf(time.Now().Format(time.RFC3339Nano)+": "+format, args...)
+		logf(time.Now().Format(time.RFC3339Nano)+" ["+tr.id+"]: "+format, redactLogArgs(tr.redactor, args)...)
 	}
 }
 
@@ -3256,6 +4438,7 @@ func (tr *transportRequest) logf(format string, args ...any) {
 func (pc *persistConn) markReused() {
 	pc.mu.Lock()
 	pc.reused = true
+	pc.requests++
 	pc.mu.Unlock()
 }
 
@@ -3424,6 +4607,51 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// ContentLengthMismatchError is returned by reads from a response Body
+// when [Transport.StrictContentLength] is set and the number of body
+// bytes received disagrees with the declared Content-Length, usually
+// because the connection was closed before the body was complete.
+//
+// It wraps [io.ErrUnexpectedEOF].
+type ContentLengthMismatchError struct {
+	Declared     int64 // Content-Length declared by the server
+	Received     int64 // body bytes received before the mismatch was detected
+	Decompressed bool  // whether the body was transparently decompressed
+}
+
+func (e *ContentLengthMismatchError) Error() string {
+	return fmt.Sprintf("net/http: response body length mismatch: Content-Length %d, received %d bytes", e.Declared, e.Received)
+}
+
+func (e *ContentLengthMismatchError) Unwrap() error { return io.ErrUnexpectedEOF }
+
+// contentLengthVerifier wraps a response body framed by a declared
+// Content-Length and converts early EOFs into a *ContentLengthMismatchError.
+// It sits below any decompression layer, so it always counts wire bytes.
+type contentLengthVerifier struct {
+	body         io.ReadCloser
+	declared     int64
+	received     int64
+	decompressed bool
+}
+
+func (v *contentLengthVerifier) Read(p []byte) (n int, err error) {
+	n, err = v.body.Read(p)
+	v.received += int64(n)
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && v.received != v.declared) {
+		err = &ContentLengthMismatchError{
+			Declared:     v.declared,
+			Received:     v.received,
+			Decompressed: v.decompressed,
+		}
+	}
+	return n, err
+}
+
+func (v *contentLengthVerifier) Close() error {
+	return v.body.Close()
+}
+
 type tlsHandshakeTimeoutError struct{}
 
 func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
@@ -3502,46 +4730,141 @@ func (cl *connLRU) len() int {
 // createCustomTLSConn 创建自定义 TLS 连接
 // 这是我们原创的 TLS 指纹控制核心方法，支持简洁 API
 func (pc *persistConn) createCustomTLSConn(plainConn net.Conn, cfg *tls.Config) (*tls.UConn, error) {
+	spec, err := pc.buildClientHelloSpec()
+	if err != nil {
+		return nil, err
+	}
+	return pc.uconnFromSpec(plainConn, cfg, spec)
+}
+
+// uconnFromSpec 用 buildClientHelloSpec 构建的 spec 创建自定义 TLS 连接
+func (pc *persistConn) uconnFromSpec(plainConn net.Conn, cfg *tls.Config, spec *tls.ClientHelloSpec) (*tls.UConn, error) {
 	// 创建 utls 配置
+	// 会话缓存在连接间共享，同一主机的后续连接像浏览器一样发送 PSK 恢复会话
 	utlsConfig := &tls.Config{
 		ServerName:         cfg.ServerName,
 		InsecureSkipVerify: cfg.InsecureSkipVerify,
 		RootCAs:            cfg.RootCAs,
-		ClientSessionCache: tls.NewLRUClientSessionCache(0),
-		// 修复 PSK 扩展问题：禁用 PSK 恢复以避免 panic
-		SessionTicketsDisabled: true,
-		// 或者使用 PreferSkipResumptionOnNilExtension 来避免 panic
+		ClientSessionCache: pc.t.clientSessionCache(cfg),
+		KeyLogWriter:       cfg.KeyLogWriter,
+		Rand:               cfg.Rand,
+		// 指纹中没有 session_ticket 扩展时不恢复 TLS 1.2 会话
 		PreferSkipResumptionOnNilExtension: true,
-		// 隐藏空的 PSK 扩展
-		OmitEmptyPsk: true,
+		// 没有可恢复的会话时不发送 PSK 扩展
+		OmitEmptyPsk:        true,
+		ApplicationSettings: pc.t.alpsSettings(cfg),
 	}
 
-	// 关键修复：根据 JA3 内容决定是否禁用 SessionTickets
-	// 如果 JA3 包含 "0029"（SessionTicket 扩展），则不禁用
-	if pc.t.JA3 != "" && strings.Index(pc.t.JA3, "0029") == -1 {
-		utlsConfig.SessionTicketsDisabled = true
-	} else {
-		utlsConfig.SessionTicketsDisabled = false
+	if err := pc.t.finishClientHelloSpec(spec, cfg.ServerName); err != nil {
+		return nil, err
+	}
+	utlsConfig.SessionTicketsDisabled = pc.t.sessionTicketsDisabled(spec, cfg)
+
+	// 指纹包含 ECH 扩展且有 ECH 配置时发送真正的 ECH，ECH 要求 TLS 1.3
+	if len(cfg.EncryptedClientHelloConfigList) > 0 && specUsesECH(spec) {
+		utlsConfig.EncryptedClientHelloConfigList = cfg.EncryptedClientHelloConfigList
+		utlsConfig.EncryptedClientHelloRejectionVerify = cfg.EncryptedClientHelloRejectionVerify
+		if utlsConfig.EncryptedClientHelloRejectionVerify == nil {
+			utlsConfig.EncryptedClientHelloRejectionVerify = echRejectionVerify(cfg, echPublicName(cfg.EncryptedClientHelloConfigList))
+		}
+		utlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	keys, err := pc.t.fillKeyShares(spec)
+	if err != nil {
+		return nil, err
+	}
+	grease, err := pc.t.greaseValues(cfg.Rand)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建 utls 客户端
 	tlsConn := tls.UClient(plainConn, utlsConfig, tls.HelloCustom)
 
+	// 应用 ClientHello 配置
+	if err := tlsConn.ApplyPreset(spec); err != nil {
+		return nil, fmt.Errorf("应用 ClientHello 配置失败: %w", err)
+	}
+	if keys != nil {
+		tlsConn.HandshakeState.State13.KeyShareKeys = keys
+	}
+	applyGREASEValues(tlsConn, grease)
+
+	return tlsConn, nil
+}
+
+// finishClientHelloSpec 对 buildClientHelloSpec 构建的 spec 做连接 serverName
+// 前的最后处理：GREASE 位置、填充、证书压缩、ALPS、QUIC 传输参数、IP 地址的
+// SNI 策略，最后调用 MutateClientHelloSpec。建连和 BuildSpec 都经过这里
+func (t *Transport) finishClientHelloSpec(spec *tls.ClientHelloSpec, serverName string) error {
+	t.placeGREASE(spec)
+	if err := t.applyPadding(spec); err != nil {
+		return err
+	}
+	t.applyCertCompression(spec)
+	t.applyALPS(spec)
+	if err := t.applyQUICTransportParameters(spec); err != nil {
+		return err
+	}
+	t.applyIPSNIPolicy(spec, serverName)
+	return t.mutateClientHelloSpec(spec, serverName)
+}
+
+// mutateClientHelloSpec 以 spec 和 host 调用 t.MutateClientHelloSpec
+func (t *Transport) mutateClientHelloSpec(spec *tls.ClientHelloSpec, host string) error {
+	if t.MutateClientHelloSpec == nil {
+		return nil
+	}
+	if err := t.MutateClientHelloSpec(spec, host); err != nil {
+		return fmt.Errorf("MutateClientHelloSpec: %w", err)
+	}
+	return nil
+}
+
+// clientSessionCache 返回指纹连接恢复 TLS 会话使用的缓存
+// 优先使用 cfg.ClientSessionCache (来自 TLSClientConfig 或 Transport.ClientSessionCache)，
+// 否则使用 Transport 内部的缓存，Storage 非 nil 时内部缓存的会话同时保存在其中
+func (t *Transport) clientSessionCache(cfg *tls.Config) tls.ClientSessionCache {
+	if cfg.ClientSessionCache != nil {
+		return cfg.ClientSessionCache
+	}
+	t.sessionCacheOnce.Do(func() {
+		t.sessionCache = tls.NewLRUClientSessionCache(0)
+		if t.Storage != nil {
+			t.sessionCache = storageSessionCache{t.sessionCache, t.Storage}
+		}
+	})
+	return t.sessionCache
+}
+
+// buildClientHelloSpec 按 Transport 的指纹配置构建最终的 ClientHelloSpec
+// 包括 TLS 版本策略、FIPS 降级和合规检查，不涉及网络
+func (pc *persistConn) buildClientHelloSpec() (*tls.ClientHelloSpec, error) {
 	// 根据配置类型应用不同的指纹策略（支持简洁 API）
 	var spec *tls.ClientHelloSpec
 	var err error
 
-	// 优先级：简洁 API > 高级 API > 默认
-	if pc.t.JA3 != "" {
+	// 请求指定的指纹代替 Transport 的 JA3、UserAgent 和 ForceHTTP1
+	ja3, userAgent, forceHTTP1 := pc.t.JA3, pc.t.UserAgent, pc.t.ForceHTTP1
+	if fp := pc.fingerprint; fp != nil {
+		ja3, userAgent, forceHTTP1 = fp.JA3, fp.UserAgent, fp.ForceHTTP1
+	}
+
+	// 优先级：请求指纹 > 自定义 spec > 内置 ClientHelloID > 简洁 API > 高级 API > 默认
+	if pc.t.ClientHelloSpec != nil && pc.fingerprint == nil {
+		spec = cloneClientHelloSpec(pc.t.ClientHelloSpec)
+	} else if id := pc.t.ClientHelloID; id.Client != "" && pc.fingerprint == nil {
+		spec, err = pc.buildClientHelloFromID(id)
+	} else if ja3 != "" {
 		// 简洁 API：直接使用 JA3
-		userAgent := pc.t.UserAgent
 		if userAgent == "" {
 			userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
 		}
 		spec, err = pc.buildClientHelloFromJA3(
-			pc.t.JA3,
+			ja3,
 			userAgent,
-			pc.t.ForceHTTP1,
+			forceHTTP1,
 		)
 	} else if pc.t.ClientHelloHexStream != "" {
 		// 简洁 API：直接使用十六进制流
@@ -3554,25 +4877,152 @@ func (pc *persistConn) createCustomTLSConn(plainConn net.Conn, cfg *tls.Config)
 		} else if fingerprint.JA3 != "" {
 			spec, err = pc.buildClientHelloFromJA3(fingerprint.JA3, fingerprint.UserAgent, fingerprint.ForceHTTP1)
 		} else if fingerprint.PresetFingerprint != "" {
-			spec, err = pc.buildClientHelloFromPreset(fingerprint.PresetFingerprint)
+			// 预设名称由 presets 包解析，这里只作为标识，回退到默认指纹
+			spec, _ = pc.buildClientHelloFromPreset(fingerprint.PresetFingerprint)
 		}
 	}
 
+	// JA3 或十六进制流无效时报错，而不是悄悄发送默认指纹
+	if err != nil {
+		return nil, fmt.Errorf("构建 ClientHello 失败: %w", err)
+	}
+
 	// 如果没有配置，使用默认
 	if spec == nil {
 		spec, err = pc.buildDefaultClientHello()
+		if err != nil {
+			return nil, fmt.Errorf("构建 ClientHello 失败: %w", err)
+		}
 	}
 
-	if err != nil {
+	// 像 Chrome 一样为每个连接重新排列扩展
+	if pc.t.PermuteExtensions {
+		spec.Extensions = pc.shuffleExtensions(spec.Extensions)
+	}
+
+	// 按浏览器的版本策略确定最低和最高 TLS 版本
+	if err := applyTLSVersionPolicy(spec); err != nil {
 		return nil, fmt.Errorf("构建 ClientHello 失败: %w", err)
 	}
 
-	// 应用 ClientHello 配置
-	if err := tlsConn.ApplyPreset(spec); err != nil {
-		return nil, fmt.Errorf("应用 ClientHello 配置失败: %w", err)
+	// FIPS 模式：移除不批准的算法，无法降级时返回 *FIPSError
+	if pc.t.fipsMode() {
+		if err := applyFIPSPolicy(spec); err != nil {
+			return nil, err
+		}
 	}
 
-	return tlsConn, nil
+	// 合规模式：拒绝不安全的密码套件和扩展
+	if pc.t.StrictTLS && !pc.t.AllowWeakTLS {
+		if err := checkSpecCompliance(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	// 目标 JA4：调整 ALPN 并校验其余各段
+	if pc.t.JA4 != "" {
+		if err := applyJA4Target(spec, pc.t.JA4); err != nil {
+			return nil, err
+		}
+	}
+
+	return spec, nil
+}
+
+// buildClientHelloFromID 从 utls 内置的 id 构建 ClientHello
+//
+// 与 tls.UClient(conn, cfg, id) 在握手时生成的 spec 相同，但经过与其他来源
+// 相同的策略处理。ALPN 按 ForceHTTP1 和 ALPNProtocols 调整
+func (pc *persistConn) buildClientHelloFromID(id tls.ClientHelloID) (*tls.ClientHelloSpec, error) {
+	alpnProtocols := []string{"h2", "http/1.1"}
+	if pc.t.CustomALPN && len(pc.t.ALPNProtocols) > 0 {
+		alpnProtocols = append([]string(nil), pc.t.ALPNProtocols...)
+	} else if pc.t.ForceHTTP1 {
+		alpnProtocols = []string{"http/1.1"}
+	}
+	if isRandomizedID(id) {
+		return pc.buildRandomizedClientHello(id, alpnProtocols)
+	}
+
+	spec, err := tls.UTLSIdToSpec(id)
+	if err != nil {
+		return nil, fmt.Errorf("ClientHelloID %s: %w", id.Str(), err)
+	}
+	for _, e := range spec.Extensions {
+		if alpn, ok := e.(*tls.ALPNExtension); ok {
+			alpn.AlpnProtocols = alpnProtocols
+		}
+	}
+	return &spec, nil
+}
+
+// isRandomizedID 报告 id 是否为 utls 的 HelloRandomized 系列
+func isRandomizedID(id tls.ClientHelloID) bool {
+	switch id.Client {
+	case tls.HelloRandomized.Client, tls.HelloRandomizedALPN.Client, tls.HelloRandomizedNoALPN.Client:
+		return true
+	}
+	return false
+}
+
+// buildRandomizedClientHello 为随机化的 id 生成一个新的 ClientHello
+//
+// utls 只在握手时生成随机化的 spec，这里在内存管道上生成一次 ClientHello，
+// 再像十六进制流一样解析回 spec，使其经过与其他来源相同的策略处理。
+// RandomSeed 非 0 时随机数种子取自其序列，生成的 ClientHello 可以重现
+func (pc *persistConn) buildRandomizedClientHello(id tls.ClientHelloID, alpnProtocols []string) (*tls.ClientHelloSpec, error) {
+	if id.Seed == nil {
+		id.Seed = pc.helloSeed()
+	}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc := tls.UClient(c1, &tls.Config{ServerName: "example.com", NextProtos: alpnProtocols}, id)
+	if err := uc.BuildHandshakeState(); err != nil {
+		return nil, fmt.Errorf("ClientHelloID %s: %w", id.Str(), err)
+	}
+
+	fingerprinter := &tls.Fingerprinter{
+		AllowBluntMimicry: true,
+		RealPSKResumption: true,
+	}
+	// Fingerprinter 解析的是 TLS 记录，加上记录头
+	raw := uc.HandshakeState.Hello.Raw
+	record := append([]byte{22, 3, 1, byte(len(raw) >> 8), byte(len(raw))}, raw...)
+	spec, err := fingerprinter.FingerprintClientHello(record)
+	if err != nil {
+		return nil, fmt.Errorf("ClientHello 指纹解析失败: %w", err)
+	}
+	// utls 不能在 HelloRetryRequest 中为 X25519MLKEM768 生成密钥，随机化的 ClientHello
+	// 声明了它却没有发送它的 key_share 时，偏好它的服务端 (如 Go) 会使握手失败
+	var mlkemShare bool
+	for _, e := range spec.Extensions {
+		if ks, ok := e.(*tls.KeyShareExtension); ok {
+			mlkemShare = slices.ContainsFunc(ks.KeyShares, func(k tls.KeyShare) bool { return k.Group == tls.X25519MLKEM768 })
+		}
+	}
+	for _, e := range spec.Extensions {
+		switch e := e.(type) {
+		case *tls.SNIExtension:
+			e.ServerName = "" // 握手时使用连接的 ServerName
+		case *tls.SupportedCurvesExtension:
+			if !mlkemShare {
+				e.Curves = slices.DeleteFunc(e.Curves, func(c tls.CurveID) bool { return c == tls.X25519MLKEM768 })
+			}
+		}
+	}
+	return pc.fixPSKExtension(spec), nil
+}
+
+// usesCustomTLS 报告 t 是否使用 utls 进行自定义 TLS 握手（支持简洁 API）
+func (t *Transport) usesCustomTLS() bool {
+	return t.UseCustomTLS ||
+		t.ClientHelloID.Client != "" ||
+		t.ClientHelloSpec != nil ||
+		t.JA3 != "" ||
+		t.JA4 != "" ||
+		t.ClientHelloHexStream != "" ||
+		t.TLSFingerprint != nil
 }
 
 // buildClientHelloFromHexStream 从十六进制流构建 ClientHello
@@ -3582,10 +5032,6 @@ func (pc *persistConn) buildClientHelloFromHexStream(hexStream string) (*tls.Cli
 		return nil, fmt.Errorf("十六进制流不能为空")
 	}
 
-	// 检查是否包含 SessionTicket 扩展 (0029)
-	// 如果不包含，禁用 SessionTickets
-	hasSessionTicket := strings.Contains(hexStream, "0029")
-
 	// 将十六进制字符串转换为字节数组
 	clientHelloHexStreamBytes := []byte(hexStream)
 	clientHelloBytes := make([]byte, hex.DecodedLen(len(clientHelloHexStreamBytes)))
@@ -3595,12 +5041,11 @@ func (pc *persistConn) buildClientHelloFromHexStream(hexStream string) (*tls.Cli
 		return nil, fmt.Errorf("十六进制解码失败: %w", err)
 	}
 
-	// 使用 tls.Fingerprinter 解析 ClientHello
 	// 使用 utls 的 Fingerprinter 解析 ClientHello
+	// 抓取的 PSK 扩展替换为真正的 PSK 扩展，使用本地缓存的会话恢复
 	fingerprinter := &tls.Fingerprinter{
 		AllowBluntMimicry: true, // 允许直接模仿
-		// 修复 PSK 问题：禁用 PSK 恢复以避免 panic
-		RealPSKResumption: false, // 禁用 PSK 恢复
+		RealPSKResumption: true,
 	}
 
 	spec, err := fingerprinter.FingerprintClientHello(clientHelloBytes)
@@ -3608,18 +5053,12 @@ func (pc *persistConn) buildClientHelloFromHexStream(hexStream string) (*tls.Cli
 		return nil, fmt.Errorf("ClientHello 指纹解析失败: %w", err)
 	}
 
-	// 根据 SessionTicket 扩展调整配置
-	if !hasSessionTicket {
-		// 如果没有 SessionTicket 扩展，我们需要调整配置
-		// 这里可以添加更多的配置调整逻辑
+	if err := pc.t.replayPadding(spec, clientHelloBytes); err != nil {
+		return nil, fmt.Errorf("ClientHello 指纹解析失败: %w", err)
 	}
 
-	// 修复 PSK 扩展问题：确保正确处理 PSK 扩展
 	spec = pc.fixPSKExtension(spec)
 
-	// 应用 JA4+ 指纹控制
-	spec = pc.applyJA4Fingerprint(spec)
-
 	return spec, nil
 }
 
@@ -3638,10 +5077,14 @@ func (pc *persistConn) buildClientHelloFromJA3(ja3, userAgent string, forceHTTP1
 	pointFormats := strings.Split(parts[4], "-")
 
 	// 解析 TLS 版本
-	_, err := pc.parseTLSVersion(version)
+	tlsVersion, err := pc.parseTLSVersion(version)
 	if err != nil {
 		return nil, fmt.Errorf("解析 TLS 版本失败: %w", err)
 	}
+	maxVersion, err := checkJA3Version(tlsVersion, extensions)
+	if err != nil {
+		return nil, err
+	}
 
 	// 解析密码套件
 	cipherSuites, err := pc.parseCipherSuites(ciphers)
@@ -3678,22 +5121,17 @@ func (pc *persistConn) buildClientHelloFromJA3(ja3, userAgent string, forceHTTP1
 	}
 
 	// 创建 ClientHelloSpec
-	// 不设置 TLSVersMin/TLSVersMax，让 utls 自动处理
-	// 这样可以更好地模拟真实浏览器的行为
+	// TLSVersMin/TLSVersMax 在应用前由 applyTLSVersionPolicy 根据
+	// supported_versions 扩展确定，没有该扩展时以 JA3 版本字段为上限
 	spec := &tls.ClientHelloSpec{
-		// TLSVersMin:         tlsVersion,  // 不设置，让 utls 自动处理
-		// TLSVersMax:         tlsVersion,  // 不设置，让 utls 自动处理
+		TLSVersMax:         maxVersion,
 		CipherSuites:       cipherSuites,
 		CompressionMethods: []byte{0}, // 标准压缩方法
 		Extensions:         tlsExtensions,
 	}
 
-	// 修复 PSK 扩展问题：确保正确处理 PSK 扩展
 	spec = pc.fixPSKExtension(spec)
 
-	// 应用 JA4+ 指纹控制
-	spec = pc.applyJA4Fingerprint(spec)
-
 	return spec, nil
 }
 
@@ -3728,75 +5166,24 @@ func (pc *persistConn) buildDefaultClientHello() (*tls.ClientHelloSpec, error) {
 	return nil, fmt.Errorf("请明确指定 JA3 或使用 presets 包，避免使用容易被检测的默认指纹")
 }
 
-// fixPSKExtension 修复 PSK 扩展问题，避免 initPskExt failed panic
-// 确保 PSK 扩展存在并正确初始化
+// fixPSKExtension 确保 spec 的末尾有 pre_shared_key (41) 扩展
+//
+// 浏览器首次连接不发送 PSK，JA3 中通常没有 41；恢复会话时在最后发送 PSK。
+// 因此缺少时在末尾加入，没有可恢复的会话时该扩展不会发送 (OmitEmptyPsk)。
 func (pc *persistConn) fixPSKExtension(spec *tls.ClientHelloSpec) *tls.ClientHelloSpec {
 	if spec == nil {
 		return spec
 	}
 
 	// 检查是否包含 PSK 扩展 (扩展 ID 41)
-	hasPSKExtension := false
 	for _, ext := range spec.Extensions {
-		if _, ok := ext.(*tls.UtlsPreSharedKeyExtension); ok {
-			hasPSKExtension = true
-			// PSK 扩展的初始化由 utls 内部处理
-			// 不需要手动设置字段
-			break
+		if _, ok := ext.(tls.PreSharedKeyExtension); ok {
+			return spec
 		}
 	}
 
-	// 如果没有 PSK 扩展，添加一个空的 PSK 扩展以避免 panic
-	if !hasPSKExtension {
-		// 添加空的 PSK 扩展
-		// 注意：UtlsPreSharedKeyExtension 的字段初始化由 utls 内部处理
-		pskExt := &tls.UtlsPreSharedKeyExtension{}
-		spec.Extensions = append(spec.Extensions, pskExt)
-	}
-
-	return spec
-}
-
-// applyJA4Fingerprint 应用 JA4+ 指纹控制
-// 支持 JA4L (距离/位置) 和 JA4X (X509 证书) 指纹
-func (pc *persistConn) applyJA4Fingerprint(spec *tls.ClientHelloSpec) *tls.ClientHelloSpec {
-	if spec == nil || !pc.t.CustomJA4 {
-		return spec
-	}
-
-	// JA4L (距离/位置) 指纹控制
-	// JA4L 格式: <TLS版本><扩展数量><第一个ALPN值长度><最后一个ALPN值长度>
-	// 例如: "t13d1715h2_c02f"
-	if pc.t.JA4L != "" {
-		// TODO: JA4L 实现
-		// JA4L 是2023年提出的新标准，用于更精确的 TLS 指纹识别
-		// 主要控制：
-		// 1. TLS 扩展的数量和顺序
-		// 2. ALPN 协议的长度特征
-		// 3. 扩展之间的距离（字节偏移）
-		//
-		// 当前状态：JA3 已经能满足大部分反爬虫需求
-		// 如果需要 JA4L，建议：
-		// - 等待 JA4 标准稳定
-		// - 参考 FoxIO 的 JA4 规范实现
-		// - 或提交 issue 请求支持
-	}
-
-	// JA4X (X509 证书) 指纹控制
-	// JA4X 格式: <证书哈希>_<扩展哈希>
-	if pc.t.JA4X != "" {
-		// TODO: JA4X 实现
-		// JA4X 用于 X.509 证书指纹识别
-		// 主要控制：
-		// 1. 证书链的构成
-		// 2. 证书扩展（Subject Alternative Name, Key Usage等）
-		// 3. 证书签名算法
-		//
-		// 当前状态：TLS 客户端指纹（JA3）已经足够
-		// 如果需要 JA4X，建议：
-		// - 使用自定义证书验证回调
-		// - 或等待社区提供参考实现
-	}
+	// PSK 的身份和 binder 由 utls 在加载会话时填充
+	spec.Extensions = append(spec.Extensions, &tls.UtlsPreSharedKeyExtension{})
 
 	return spec
 }
@@ -3916,6 +5303,15 @@ func (pc *persistConn) buildTLSExtensions(extensions []string, userAgent string,
 	// 获取扩展映射表
 	extensionMap := pc.getExtensionMap()
 
+	// 签名算法和证书压缩算法按浏览器选择，可被扩展配置覆盖（支持简洁 API）
+	override := pc.t.TLSExtensions
+	if pc.t.TLSFingerprint != nil && pc.t.TLSFingerprint.CustomExtensions != nil {
+		override = pc.t.TLSFingerprint.CustomExtensions
+	}
+	setSignatureAlgorithms(extensionMap, userAgent, override)
+	setCertCompression(extensionMap, userAgent, override)
+	extensions = override.filterExtensions(extensions)
+
 	// 解析用户代理类型
 	browserType := pc.parseBrowserType(userAgent)
 
@@ -3934,7 +5330,13 @@ func (pc *persistConn) buildTLSExtensions(extensions []string, userAgent string,
 		}
 
 		// 检查是否为特殊扩展
-		if extID == "10" {
+		repl, replaced, err := override.overrideExtension(extID)
+		if err != nil {
+			return nil, err
+		}
+		if replaced {
+			tlsExtensions = append(tlsExtensions, repl)
+		} else if extID == "10" {
 			// Supported Curves 扩展
 			tlsExtensions = append(tlsExtensions, &tls.SupportedCurvesExtension{
 				Curves: curves,
@@ -3994,10 +5396,15 @@ func (pc *persistConn) buildTLSExtensions(extensions []string, userAgent string,
 		}
 	}
 
+	// supported_groups 与 key_share 分别配置，并校验两者一致
+	if err := applyGroupsPolicy(tlsExtensions, override); err != nil {
+		return nil, err
+	}
+
 	// 扩展随机化支持（支持简洁 API）
 	useRandomization := pc.t.RandomizeFingerprint || pc.t.RandomJA3
 	if useRandomization {
-		tlsExtensions = tls.ShuffleChromeTLSExtensions(tlsExtensions)
+		tlsExtensions = pc.shuffleExtensions(tlsExtensions)
 	}
 
 	return tlsExtensions, nil
@@ -4099,12 +5506,22 @@ func (ext *TLSExtensionsConfig) StringToSpec(ja3, userAgent string, forceHTTP1,
 		return nil, fmt.Errorf("无效的 JA3 格式，应为 5 个部分，实际为 %d 个", len(tokens))
 	}
 
-	_ = tokens[0] // version - 不使用，让 utls 自动处理
 	ciphers := strings.Split(tokens[1], "-")
 	extensions := strings.Split(tokens[2], "-")
 	curves := strings.Split(tokens[3], "-")
 	pointFormats := strings.Split(tokens[4], "-")
 
+	// 解析 TLS 版本，并校验与 supported_versions 扩展是否矛盾
+	version, err := strconv.ParseUint(tokens[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 TLS 版本: %s", tokens[0])
+	}
+	maxVersion, err := checkJA3Version(uint16(version), extensions)
+	if err != nil {
+		return nil, err
+	}
+	extensions = ext.filterExtensions(extensions)
+
 	// 处理空曲线和点格式
 	if len(curves) == 1 && curves[0] == "" {
 		curves = []string{}
@@ -4175,12 +5592,8 @@ func (ext *TLSExtensionsConfig) StringToSpec(ja3, userAgent string, forceHTTP1,
 	}
 
 	// 自定义 TLS 扩展处理
-	if ext.SupportedSignatureAlgorithms != nil {
-		extMap["13"] = ext.SupportedSignatureAlgorithms
-	}
-	if ext.CertCompressionAlgo != nil {
-		extMap["27"] = ext.CertCompressionAlgo
-	}
+	setSignatureAlgorithms(extMap, userAgent, ext)
+	setCertCompression(extMap, userAgent, ext)
 	if ext.RecordSizeLimit != nil {
 		extMap["28"] = ext.RecordSizeLimit
 	}
@@ -4193,12 +5606,6 @@ func (ext *TLSExtensionsConfig) StringToSpec(ja3, userAgent string, forceHTTP1,
 	if ext.PSKKeyExchangeModes != nil {
 		extMap["45"] = ext.PSKKeyExchangeModes
 	}
-	if ext.SignatureAlgorithmsCert != nil {
-		extMap["50"] = ext.SignatureAlgorithmsCert
-	}
-	if ext.KeyShareCurves != nil {
-		extMap["51"] = ext.KeyShareCurves
-	}
 
 	// 构建扩展列表
 	var exts []tls.TLSExtension
@@ -4211,6 +5618,13 @@ func (ext *TLSExtensionsConfig) StringToSpec(ja3, userAgent string, forceHTTP1,
 	// 处理 JA3 中的扩展
 	for i, e := range extensions {
 		te, ok := extMap[e]
+		repl, replaced, err := ext.overrideExtension(e)
+		if err != nil {
+			return nil, err
+		}
+		if replaced {
+			te, ok = repl, true
+		}
 		if !ok {
 			return nil, fmt.Errorf("不支持的扩展: %s", e)
 		}
@@ -4250,17 +5664,27 @@ func (ext *TLSExtensionsConfig) StringToSpec(ja3, userAgent string, forceHTTP1,
 		suites = append(suites, uint16(cid))
 	}
 
+	// supported_groups 与 key_share 分别配置，并校验两者一致
+	if err := applyGroupsPolicy(exts, ext); err != nil {
+		return nil, err
+	}
+
 	// 随机化扩展
 	if randomJA3 {
 		exts = tls.ShuffleChromeTLSExtensions(exts)
 	}
 
 	// 创建 ClientHelloSpec
-	return &tls.ClientHelloSpec{
+	spec := &tls.ClientHelloSpec{
+		TLSVersMax:         maxVersion,
 		CipherSuites:       suites,
 		CompressionMethods: []byte{0},
 		Extensions:         exts,
-	}, nil
+	}
+	if err := applyTLSVersionPolicy(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
 }
 
 // getExtensionMap 获取 TLS 扩展映射表
@@ -4309,6 +5733,8 @@ func (ext *TLSExtensionsConfig) Clone() (*TLSExtensionsConfig, error) {
 	if err := cbor.Unmarshal(data, &clone); err != nil {
 		return nil, fmt.Errorf("CBOR 反序列化失败: %w", err)
 	}
+	// ReplaceExtensions 不能序列化，扩展在使用时深拷贝
+	clone.ReplaceExtensions = maps.Clone(ext.ReplaceExtensions)
 
 	return clone, nil
 }
@@ -4330,6 +5756,9 @@ func (cfg *TLSFingerprintConfig) Clone() (*TLSFingerprintConfig, error) {
 	if err := cbor.Unmarshal(data, &clone); err != nil {
 		return nil, fmt.Errorf("CBOR 反序列化失败: %w", err)
 	}
+	if cfg.CustomExtensions != nil {
+		clone.CustomExtensions.ReplaceExtensions = maps.Clone(cfg.CustomExtensions.ReplaceExtensions)
+	}
 
 	return clone, nil
 }
@@ -4348,7 +5777,7 @@ func getCompleteExtensionMap() map[string]tls.TLSExtension {
 		// "10": &tls.SupportedCurvesExtension{...} // 动态设置
 		// "11": &tls.SupportedPointsExtension{...} // 动态设置
 
-		// 签名算法
+		// 签名算法，建连时由 setSignatureAlgorithms 按浏览器替换
 		"13": &tls.SignatureAlgorithmsExtension{
 			SupportedSignatureAlgorithms: []tls.SignatureScheme{
 				tls.ECDSAWithP256AndSHA256,
@@ -4388,7 +5817,7 @@ func getCompleteExtensionMap() map[string]tls.TLSExtension {
 		// 令牌绑定
 		"24": &tls.FakeTokenBindingExtension{},
 
-		// 证书压缩
+		// 证书压缩，建连时由 setCertCompression 按浏览器替换
 		"27": &tls.UtlsCompressCertExtension{
 			Algorithms: []tls.CertCompressionAlgo{tls.CertCompressionBrotli},
 		},
@@ -4431,7 +5860,7 @@ func getCompleteExtensionMap() map[string]tls.TLSExtension {
 		// 握手后认证
 		"49": &tls.GenericExtension{Id: 49},
 
-		// 证书签名算法
+		// 证书签名算法，建连时由 setSignatureAlgorithms 按浏览器替换
 		"50": &tls.SignatureAlgorithmsCertExtension{
 			SupportedSignatureAlgorithms: []tls.SignatureScheme{
 				tls.ECDSAWithP256AndSHA256,
@@ -4454,18 +5883,18 @@ func getCompleteExtensionMap() map[string]tls.TLSExtension {
 			// 注意: CurveP384 有已知 bug，暂时不包含
 		}},
 
-		// QUIC 传输参数
+		// QUIC 传输参数，内容可由 Transport.QUICTransportParameters 设置
 		"57": &tls.QUICTransportParametersExtension{},
 
 		// NPN 扩展
 		"13172": &tls.NPNExtension{},
 
-		// HTTP/3 应用设置
+		// ALPS，协议和设置可由 Transport.ALPS 替换
 		"17513": &tls.ApplicationSettingsExtension{
 			SupportedProtocols: []string{"h2"},
 		},
 
-		// HTTP/3 应用设置 (新版本)
+		// ALPS (Chrome 133 起的新码点)
 		"17613": &tls.ApplicationSettingsExtensionNew{
 			SupportedProtocols: []string{"h2"},
 		},
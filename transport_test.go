@@ -5,12 +5,24 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	nethttp "net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	tls "github.com/refraction-networking/utls"
+	"github.com/vanling1111/tlshttp/httptrace"
 )
 
 // TestTransportCreation 测试 Transport 的创建
@@ -418,6 +430,550 @@ func TestTransportTimeouts(t *testing.T) {
 	}
 }
 
+// TestTransportResponseHeaderTimeoutOverrides 测试按主机和按请求覆盖响应头超时
+func TestTransportResponseHeaderTimeoutOverrides(t *testing.T) {
+	tr := &Transport{
+		ResponseHeaderTimeout: 30 * time.Second,
+		ResponseHeaderTimeoutByHost: map[string]time.Duration{
+			"slow.example.com":     2 * time.Minute,
+			"api.example.com:8443": 5 * time.Second,
+			"nolimit.example.com":  0,
+		},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		ctx  context.Context
+		want time.Duration
+	}{
+		{"默认", "https://other.example.com/", context.Background(), 30 * time.Second},
+		{"按主机名", "https://slow.example.com/a", context.Background(), 2 * time.Minute},
+		{"按主机和端口", "https://api.example.com:8443/", context.Background(), 5 * time.Second},
+		{"端口不匹配回退", "https://api.example.com/", context.Background(), 30 * time.Second},
+		{"按主机禁用", "http://nolimit.example.com/", context.Background(), 0},
+		{"按请求覆盖", "https://slow.example.com/", WithResponseHeaderTimeout(context.Background(), time.Second), time.Second},
+		{"按请求禁用", "https://other.example.com/", WithResponseHeaderTimeout(context.Background(), 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewRequestWithContext(tt.ctx, "GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tr.responseHeaderTimeout(req); got != tt.want {
+				t.Errorf("responseHeaderTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cloned := tr.Clone()
+	cloned.ResponseHeaderTimeoutByHost["slow.example.com"] = time.Second
+	if tr.ResponseHeaderTimeoutByHost["slow.example.com"] != 2*time.Minute {
+		t.Error("ResponseHeaderTimeoutByHost 不是深度克隆：修改克隆影响了原始对象")
+	}
+}
+
+// serveRawOnce 启动一个只处理一个连接的本地服务器，读取请求头后写出 raw 并关闭连接
+func serveRawOnce(t *testing.T, raw []byte) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		br := bufio.NewReader(c)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil || line == "\r\n" {
+				break
+			}
+		}
+		c.Write(raw)
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+// TestTransportStrictContentLength 测试严格模式下的 Content-Length 截断检测
+func TestTransportStrictContentLength(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(bytes.Repeat([]byte("tlshttp"), 100))
+	zw.Close()
+	gzBody := gz.Bytes()
+
+	tests := []struct {
+		name             string
+		raw              string
+		wantDeclared     int64
+		wantDecompressed bool
+	}{
+		{
+			name:         "明文截断",
+			raw:          "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nabcd",
+			wantDeclared: 10,
+		},
+		{
+			name:             "gzip 截断",
+			raw:              fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n%s", len(gzBody), gzBody[:len(gzBody)/2]),
+			wantDeclared:     int64(len(gzBody)),
+			wantDecompressed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{StrictContentLength: true}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(serveRawOnce(t, []byte(tt.raw)))
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			defer resp.Body.Close()
+			_, err = io.ReadAll(resp.Body)
+
+			var mismatch *ContentLengthMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("ReadAll() 错误 = %v, want *ContentLengthMismatchError", err)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Error("ContentLengthMismatchError 应该包装 io.ErrUnexpectedEOF")
+			}
+			if mismatch.Declared != tt.wantDeclared || mismatch.Received >= mismatch.Declared {
+				t.Errorf("mismatch = %+v, want Declared=%d 且 Received < Declared", mismatch, tt.wantDeclared)
+			}
+			if mismatch.Decompressed != tt.wantDecompressed {
+				t.Errorf("Decompressed = %v, want %v", mismatch.Decompressed, tt.wantDecompressed)
+			}
+		})
+	}
+
+	t.Run("完整响应", func(t *testing.T) {
+		tr := &Transport{StrictContentLength: true}
+		defer tr.CloseIdleConnections()
+		resp, err := (&Client{Transport: tr}).Get(serveRawOnce(t, []byte("HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\nabcd")))
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		defer resp.Body.Close()
+		if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "abcd" {
+			t.Errorf("ReadAll() = %q, %v, want \"abcd\", nil", b, err)
+		}
+	})
+}
+
+// waitPendingTimers 等待 clock 上至少有 n 个待触发的定时器
+func waitPendingTimers(t *testing.T, clock *FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.PendingTimers() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待定时器超时: PendingTimers() = %d, want >= %d", clock.PendingTimers(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestTransportFakeClock 测试使用 FakeClock 驱动超时，无需真实等待
+func TestTransportFakeClock(t *testing.T) {
+	t.Run("ResponseHeaderTimeout", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("监听失败: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			io.Copy(io.Discard, c) // 从不响应
+		}()
+
+		clock := NewFakeClock(time.Unix(0, 0))
+		tr := &Transport{Clock: clock, ResponseHeaderTimeout: time.Hour}
+		defer tr.CloseIdleConnections()
+
+		errc := make(chan error, 1)
+		go func() {
+			_, err := (&Client{Transport: tr}).Get("http://" + ln.Addr().String())
+			errc <- err
+		}()
+		waitPendingTimers(t, clock, 1)
+		clock.Advance(time.Hour)
+
+		select {
+		case err := <-errc:
+			if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+				t.Errorf("Get() 错误 = %v, want 响应头超时", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Advance 之后请求没有超时")
+		}
+	})
+
+	t.Run("Timer", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		fired := 0
+		clock.AfterFunc(2*time.Second, func() { fired++ })
+		timer := clock.NewTimer(time.Second)
+
+		clock.Advance(time.Second)
+		select {
+		case now := <-timer.C():
+			if want := time.Unix(1, 0); !now.Equal(want) {
+				t.Errorf("timer 触发时间 = %v, want %v", now, want)
+			}
+		default:
+			t.Error("timer 应该已触发")
+		}
+		if fired != 0 {
+			t.Errorf("AfterFunc 过早触发")
+		}
+		if timer.Reset(time.Second) {
+			t.Error("已触发的 timer Reset() 应该返回 false")
+		}
+		if !timer.Stop() {
+			t.Error("未触发的 timer Stop() 应该返回 true")
+		}
+		clock.Advance(time.Second)
+		if fired != 1 || clock.PendingTimers() != 0 {
+			t.Errorf("fired = %d, PendingTimers() = %d, want 1, 0", fired, clock.PendingTimers())
+		}
+	})
+}
+
+// TestGotConnInfoIdentity 测试 GotConnInfo 中的连接身份信息
+func TestGotConnInfoIdentity(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	const ja3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+	// 服务器地址是 IP，不会发送 SNI (0) 扩展
+	wantHash := ja3Hash("771,4865-4866-4867-49195-49199,10-11-13-16-23-43-45-51-65281,29-23-24,0")
+
+	tests := []struct {
+		name         string
+		forceHTTP1   bool
+		wantProtocol string
+	}{
+		{"HTTP/1.1", true, "http/1.1"},
+		{"HTTP/2", false, "h2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				JA3:               ja3,
+				ForceHTTP1:        tt.forceHTTP1,
+				ForceAttemptHTTP2: !tt.forceHTTP1,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			}
+			defer tr.CloseIdleConnections()
+
+			var infos []httptrace.GotConnInfo
+			for i := 0; i < 2; i++ {
+				req, _ := NewRequest("GET", srv.URL, nil)
+				req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+					GotConn: func(info httptrace.GotConnInfo) { infos = append(infos, info) },
+				}))
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("请求失败: %v", err)
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+
+			if len(infos) != 2 {
+				t.Fatalf("GotConn 调用次数 = %d, want 2", len(infos))
+			}
+			want := "|https|" + strings.TrimPrefix(srv.URL, "https://")
+			for _, info := range infos {
+				if info.ConnKey != want {
+					t.Errorf("ConnKey = %q, want %q", info.ConnKey, want)
+				}
+				if info.Protocol != tt.wantProtocol {
+					t.Errorf("Protocol = %q, want %q", info.Protocol, tt.wantProtocol)
+				}
+				if info.FingerprintHash != wantHash {
+					t.Errorf("FingerprintHash = %q, want %q", info.FingerprintHash, wantHash)
+				}
+				if info.Proxy != "" {
+					t.Errorf("Proxy = %q, want 空", info.Proxy)
+				}
+			}
+			if !infos[1].Reused {
+				t.Error("第二个请求应该复用连接")
+			}
+		})
+	}
+}
+
+// TestResponseMeta 测试 Response.Meta 中的连接复用、协议、指纹与耗时信息
+func TestResponseMeta(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	const ja3 = "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0"
+	tr := &Transport{
+		JA3:               ja3,
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+
+	var metas []*ResponseMeta
+	for i := 0; i < 2; i++ {
+		req, _ := NewRequest("GET", srv.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Meta == nil {
+			t.Fatal("Response.Meta 为 nil")
+		}
+		metas = append(metas, resp.Meta)
+	}
+
+	first, second := metas[0], metas[1]
+	if first.Attempts != 1 || second.Attempts != 1 {
+		t.Errorf("Attempts = %d, %d, want 1, 1", first.Attempts, second.Attempts)
+	}
+	if first.Reused || !second.Reused {
+		t.Errorf("Reused = %v, %v, want false, true", first.Reused, second.Reused)
+	}
+	for _, m := range metas {
+		if m.Protocol != "h2" {
+			t.Errorf("Protocol = %q, want h2", m.Protocol)
+		}
+		if m.Fingerprint != ja3 {
+			t.Errorf("Fingerprint = %q, want %q", m.Fingerprint, ja3)
+		}
+		if m.FingerprintHash == "" {
+			t.Error("FingerprintHash 为空")
+		}
+		if m.RemoteAddr != srv.Listener.Addr().String() {
+			t.Errorf("RemoteAddr = %q, want %q", m.RemoteAddr, srv.Listener.Addr())
+		}
+		if m.TimeToFirstByte <= 0 {
+			t.Errorf("TimeToFirstByte = %v, want > 0", m.TimeToFirstByte)
+		}
+	}
+	if first.Connect <= 0 || first.TLSHandshake <= 0 {
+		t.Errorf("首个连接 Connect = %v, TLSHandshake = %v, want > 0", first.Connect, first.TLSHandshake)
+	}
+	if second.Connect != 0 || second.TLSHandshake != 0 {
+		t.Errorf("复用连接 Connect = %v, TLSHandshake = %v, want 0", second.Connect, second.TLSHandshake)
+	}
+}
+
+// TestTransportRequestID 测试请求 ID 在 trace、错误和请求头中的传播
+func TestTransportRequestID(t *testing.T) {
+	gotHeader := make(chan string, 1)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		gotHeader <- r.Header.Get("X-Request-Id")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		header     string
+		ctxID      string
+		wantHeader bool
+	}{
+		{"默认不发送请求头", "", "", false},
+		{"自定义请求头", "X-Request-Id", "", true},
+		{"使用调用方的 ID", "X-Request-Id", "app-123", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{RequestIDHeader: tt.header}
+			defer tr.CloseIdleConnections()
+
+			var traced string
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				GotRequestID: func(id string) { traced = id },
+			})
+			if tt.ctxID != "" {
+				ctx = WithRequestID(ctx, tt.ctxID)
+			}
+			req, _ := NewRequestWithContext(ctx, "GET", srv.URL, nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			resp.Body.Close()
+
+			if traced == "" || (tt.ctxID != "" && traced != tt.ctxID) {
+				t.Errorf("GotRequestID = %q, want ctx ID %q", traced, tt.ctxID)
+			}
+			sent := <-gotHeader
+			if tt.wantHeader && sent != traced {
+				t.Errorf("请求头 = %q, want %q", sent, traced)
+			}
+			if !tt.wantHeader && sent != "" {
+				t.Errorf("默认不应发送请求头, got %q", sent)
+			}
+			if req.Header.Get("X-Request-Id") != "" {
+				t.Error("不应修改调用方的请求头")
+			}
+		})
+	}
+
+	t.Run("错误中包含 ID", func(t *testing.T) {
+		ctx := WithRequestID(context.Background(), "app-456")
+		req, _ := NewRequestWithContext(ctx, "GET", "ftp://example.com", nil)
+		_, err := (&Transport{}).RoundTrip(req)
+		if id, ok := RequestIDFromError(err); !ok || id != "app-456" {
+			t.Errorf("RequestIDFromError() = %q, %v, want \"app-456\", true", id, ok)
+		}
+		if strings.Contains(err.Error(), "app-456") {
+			t.Errorf("错误信息不应改变: %v", err)
+		}
+	})
+
+	t.Run("Client 仍能识别底层错误", func(t *testing.T) {
+		_, err := (&Client{Transport: &Transport{}}).Get(strings.Replace(srv.URL, "http:", "https:", 1))
+		if !errors.Is(err, ErrSchemeMismatch) {
+			t.Errorf("got %v, want ErrSchemeMismatch", err)
+		}
+	})
+}
+
+// TestTransportEgressPolicy 测试出站策略的允许、拒绝和重定向
+func TestTransportEgressPolicy(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer srv.Close()
+	srvAddr := strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		name      string
+		url       string
+		policy    func(*EgressInfo) EgressDecision
+		wantBody  string
+		wantPhase EgressPhase
+		wantDeny  bool
+	}{
+		{
+			name:     "允许",
+			url:      srv.URL,
+			policy:   func(*EgressInfo) EgressDecision { return EgressDecision{} },
+			wantBody: srvAddr,
+		},
+		{
+			name: "禁止直接访问 IP",
+			url:  srv.URL,
+			policy: func(info *EgressInfo) EgressDecision {
+				if info.Phase == EgressBeforeRoundTrip && info.IP != nil {
+					return EgressDecision{Action: EgressDeny, Reason: "direct-to-IP"}
+				}
+				return EgressDecision{}
+			},
+			wantPhase: EgressBeforeRoundTrip,
+			wantDeny:  true,
+		},
+		{
+			name: "拨号后按 IP 拒绝",
+			url:  "http://service.test/",
+			policy: func(info *EgressInfo) EgressDecision {
+				switch {
+				case info.Phase == EgressBeforeRoundTrip:
+					return EgressDecision{Action: EgressRedirect, Addr: srvAddr}
+				case info.IP.IsLoopback():
+					return EgressDecision{Action: EgressDeny}
+				}
+				return EgressDecision{}
+			},
+			wantPhase: EgressAfterDial,
+			wantDeny:  true,
+		},
+		{
+			name: "重定向到其他地址",
+			url:  "http://service.test/",
+			policy: func(info *EgressInfo) EgressDecision {
+				if info.Host == "service.test" && info.Phase == EgressBeforeRoundTrip {
+					return EgressDecision{Action: EgressRedirect, Addr: srvAddr}
+				}
+				return EgressDecision{}
+			},
+			wantBody: "service.test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{EgressPolicy: tt.policy}
+			defer tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tr}).Get(tt.url)
+
+			var denied *EgressDeniedError
+			if tt.wantDeny {
+				if !errors.As(err, &denied) {
+					t.Fatalf("Get() 错误 = %v, want *EgressDeniedError", err)
+				}
+				if denied.Phase != tt.wantPhase {
+					t.Errorf("Phase = %v, want %v", denied.Phase, tt.wantPhase)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("请求失败: %v", err)
+			}
+			defer resp.Body.Close()
+			if b, _ := io.ReadAll(resp.Body); string(b) != tt.wantBody {
+				t.Errorf("body = %q, want %q", b, tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestTransportEgressPolicyDialTLS 测试设置 DialTLSContext 时在拨号前检查出站策略
+func TestTransportEgressPolicyDialTLS(t *testing.T) {
+	var dials atomic.Int32
+	tr := &Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return nil, errors.New("不应拨号")
+		},
+		EgressPolicy: func(info *EgressInfo) EgressDecision {
+			if info.Phase == EgressAfterDial && info.IP.IsLoopback() {
+				return EgressDecision{Action: EgressDeny}
+			}
+			return EgressDecision{}
+		},
+	}
+	_, err := (&Client{Transport: tr}).Get("https://127.0.0.1:1/")
+	var denied *EgressDeniedError
+	if !errors.As(err, &denied) || denied.Phase != EgressAfterDial {
+		t.Fatalf("err got %v, want EgressAfterDial 的 *EgressDeniedError", err)
+	}
+	if n := dials.Load(); n != 0 {
+		t.Errorf("拒绝后仍调用了 DialTLSContext %d 次", n)
+	}
+}
+
 // BenchmarkTransportClone 性能测试：Transport 克隆
 func BenchmarkTransportClone(b *testing.B) {
 	tr := &Transport{
@@ -0,0 +1,479 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package transporttest 提供 tlshttp Transport 的行为契约测试
+//
+// 测试选自标准库 net/http 的 transport 测试，覆盖超时、连接重试、1xx 响应和代理，
+// 检查 Transport 在这些方面与上游 net/http 的行为相同。指纹相关的改动 (自定义
+// ClientHello、头部顺序、HTTP/2 设置等) 不应改变这些行为。
+//
+// 下游用户可以用自己的指纹配置运行同一组测试：
+//
+//	func TestMyTransportContract(t *testing.T) {
+//		transporttest.Run(t, func() *http.Transport {
+//			return presets.Chrome133Windows.NewTransport()
+//		})
+//	}
+//
+// 测试只使用本地的 httptest 服务器，不访问网络。
+package transporttest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+	http "github.com/vanling1111/tlshttp"
+	"github.com/vanling1111/tlshttp/httptrace"
+)
+
+// Run 以子测试运行全部契约测试。newTransport 为每个子测试返回一个新的 Transport，
+// 测试会修改它的 TLSClientConfig (信任测试服务器的证书)、Proxy 和超时字段，
+// 并在结束时调用 CloseIdleConnections
+func Run(t *testing.T, newTransport func() *http.Transport) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, *http.Transport)
+	}{
+		{"ResponseHeaderTimeout", testResponseHeaderTimeout},
+		{"ClientTimeoutDuringBody", testClientTimeoutDuringBody},
+		{"ContextCancel", testContextCancel},
+		{"TLSHandshakeTimeout", testTLSHandshakeTimeout},
+		{"RetryIdempotentOnReusedConn", testRetryIdempotent},
+		{"NoRetryNonIdempotent", testNoRetryNonIdempotent},
+		{"RetryWithIdempotencyKey", testRetryWithIdempotencyKey},
+		{"ExpectContinue", testExpectContinue},
+		{"ExpectContinueRejected", testExpectContinueRejected},
+		{"EarlyHints", testEarlyHints},
+		{"HTTPProxy", testHTTPProxy},
+		{"ConnectProxy", testConnectProxy},
+		{"ConnectProxyRejected", testConnectProxyRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTransport()
+			defer tr.CloseIdleConnections()
+			tt.fn(t, tr)
+		})
+	}
+}
+
+// insecure 使 tr 信任测试服务器的自签名证书
+func insecure(tr *http.Transport) {
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	} else {
+		tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+	}
+	tr.TLSClientConfig.InsecureSkipVerify = true
+}
+
+// isTimeout 报告 err 是否为超时错误
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// get 用 tr 发出 GET 请求并读取响应体
+func get(ctx context.Context, tr *http.Transport, url string) (*http.Response, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, string(body), err
+}
+
+// 上游 TestTransportResponseHeaderTimeout：服务端迟迟不返回响应头时请求超时
+func testResponseHeaderTimeout(t *testing.T, tr *http.Transport) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	tr.ResponseHeaderTimeout = 100 * time.Millisecond
+	if _, _, err := get(context.Background(), tr, ts.URL+"/fast"); err != nil {
+		t.Fatalf("快速响应: %v", err)
+	}
+	_, _, err := get(context.Background(), tr, ts.URL+"/slow")
+	if err == nil || !isTimeout(err) {
+		t.Fatalf("err = %v, want 超时错误", err)
+	}
+}
+
+// 上游 TestClientTimeout：读取响应体时超过 Client.Timeout 返回超时错误
+func testClientTimeoutDuringBody(t *testing.T, tr *http.Transport) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "partial")
+		w.(nethttp.Flusher).Flush()
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	c := &http.Client{Transport: tr, Timeout: 200 * time.Millisecond}
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("响应头: %v", err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	if err == nil || !isTimeout(err) || !strings.Contains(err.Error(), "Client.Timeout") {
+		t.Fatalf("err = %v, want Client.Timeout 超时错误", err)
+	}
+}
+
+// 上游 TestTransportCancelRequestInDial 等：等待响应时取消 context 立即返回 context.Canceled
+func testContextCancel(t *testing.T, tr *http.Transport) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, _, err := get(ctx, tr, ts.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("取消后 %v 才返回", d)
+	}
+}
+
+// 上游 TestTLSHandshakeTimeout：服务端接受连接但不响应 TLS 握手时请求超时
+func testTLSHandshakeTimeout(t *testing.T, tr *http.Transport) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var conns []net.Conn
+	var mu sync.Mutex
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	insecure(tr)
+	tr.TLSHandshakeTimeout = 100 * time.Millisecond
+	_, _, err = get(context.Background(), tr, "https://"+ln.Addr().String())
+	if err == nil || !isTimeout(err) || !strings.Contains(err.Error(), "handshake timeout") {
+		t.Fatalf("err = %v, want TLS handshake timeout", err)
+	}
+}
+
+// closingServer 是一个 HTTP/1.1 服务器，第一个连接在收到第二个请求后不响应直接关闭，
+// 模拟服务端关闭空闲连接与客户端复用连接之间的竞争
+type closingServer struct {
+	ln       net.Listener
+	conns    atomic.Int32
+	requests atomic.Int32
+}
+
+func newClosingServer(t *testing.T) *closingServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &closingServer{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, s.conns.Add(1) == 1)
+		}
+	}()
+	return s
+}
+
+func (s *closingServer) serve(c net.Conn, first bool) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for n := 1; ; n++ {
+		req, err := nethttp.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		s.requests.Add(1)
+		if first && n == 2 {
+			return
+		}
+		fmt.Fprintf(c, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	}
+}
+
+func (s *closingServer) url() string { return "http://" + s.ln.Addr().String() }
+
+// do 先发出一个 GET 建立可复用的连接，再在该连接上发出 req
+func (s *closingServer) do(tr *http.Transport, req *http.Request) (*http.Response, error) {
+	if _, _, err := get(context.Background(), tr, s.url()); err != nil {
+		return nil, fmt.Errorf("第一个请求: %w", err)
+	}
+	return tr.RoundTrip(req)
+}
+
+// 上游 TestRetryRequestsOnError：复用的连接被服务端关闭时，幂等请求在新连接上重试
+func testRetryIdempotent(t *testing.T, tr *http.Transport) {
+	s := newClosingServer(t)
+	defer s.ln.Close()
+	req, _ := http.NewRequest("GET", s.url(), nil)
+	resp, err := s.do(tr, req)
+	if err != nil {
+		t.Fatalf("GET 没有重试: %v", err)
+	}
+	resp.Body.Close()
+	if got := s.conns.Load(); got != 2 {
+		t.Errorf("连接数 = %d, want 2", got)
+	}
+}
+
+// 上游 TestTransportNoReuseAfterEarlyResponse 等：非幂等请求已经发出后连接断开时不重试
+func testNoRetryNonIdempotent(t *testing.T, tr *http.Transport) {
+	s := newClosingServer(t)
+	defer s.ln.Close()
+	req, _ := http.NewRequest("POST", s.url(), strings.NewReader("data"))
+	if resp, err := s.do(tr, req); err == nil {
+		resp.Body.Close()
+		t.Fatal("POST 被重试")
+	}
+	if got := s.requests.Load(); got != 2 {
+		t.Errorf("服务端收到 %d 个请求, want 2", got)
+	}
+}
+
+// 上游 TestRetryRequestsOnError：带有 Idempotency-Key 的 POST 可以重试
+func testRetryWithIdempotencyKey(t *testing.T, tr *http.Transport) {
+	s := newClosingServer(t)
+	defer s.ln.Close()
+	req, _ := http.NewRequest("POST", s.url(), strings.NewReader("data"))
+	req.Header.Set("Idempotency-Key", "k1")
+	resp, err := s.do(tr, req)
+	if err != nil {
+		t.Fatalf("带 Idempotency-Key 的 POST 没有重试: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// trackingReader 记录请求体是否被读取
+type trackingReader struct {
+	r    io.Reader
+	read atomic.Bool
+}
+
+func (r *trackingReader) Read(p []byte) (int, error) {
+	r.read.Store(true)
+	return r.r.Read(p)
+}
+
+// 上游 TestTransportExpectContinue：收到 100 Continue 后发送请求体
+func testExpectContinue(t *testing.T, tr *http.Transport) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s", body)
+	}))
+	defer ts.Close()
+
+	tr.ExpectContinueTimeout = 5 * time.Second
+	req, _ := http.NewRequest("PUT", ts.URL, strings.NewReader("payload"))
+	req.Header.Set("Expect", "100-continue")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "payload" {
+		t.Errorf("body = %q, want payload", body)
+	}
+}
+
+// 上游 TestTransportExpectContinue：服务端不读取请求体直接返回最终响应时不发送请求体
+func testExpectContinueRejected(t *testing.T, tr *http.Transport) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(nethttp.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	tr.ExpectContinueTimeout = 5 * time.Second
+	body := &trackingReader{r: strings.NewReader("payload")}
+	req, _ := http.NewRequest("PUT", ts.URL, body)
+	req.ContentLength = 7
+	req.Header.Set("Expect", "100-continue")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if body.read.Load() {
+		t.Error("被拒绝的请求发送了请求体")
+	}
+}
+
+// 上游 TestTransportGot1xxResponse：1xx 响应通过 httptrace 报告，不影响最终响应
+func testEarlyHints(t *testing.T, tr *http.Transport) {
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(nethttp.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("X-Final", "1")
+		io.WriteString(w, "done")
+	}))
+	defer ts.Close()
+
+	var got []string
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+			got = append(got, fmt.Sprintf("%d %s", code, h.Get("Link")))
+			return nil
+		},
+	})
+	resp, body, err := get(ctx, tr, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "103 </style.css>; rel=preload; as=style"; len(got) != 1 || got[0] != want {
+		t.Errorf("1xx 响应 = %q, want [%q]", got, want)
+	}
+	if resp.StatusCode != 200 || body != "done" || resp.Header.Get("X-Final") != "1" || resp.Header.Get("Link") != "" {
+		t.Errorf("最终响应 = %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+}
+
+// 上游 TestTransportProxy：http:// 请求以绝对 URI 发往代理，携带 Proxy-Authorization
+func testHTTPProxy(t *testing.T, tr *http.Transport) {
+	var uri, auth string
+	proxy := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		uri, auth = r.RequestURI, r.Header.Get("Proxy-Authorization")
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	pu.User = url.UserPassword("user", "pass")
+	tr.Proxy = http.ProxyURL(pu)
+	_, body, err := get(context.Background(), tr, "http://origin.example/path?q=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "proxied" || uri != "http://origin.example/path?q=1" || auth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("代理收到 %q (%q), 响应 %q", uri, auth, body)
+	}
+}
+
+// connectProxy 返回处理 CONNECT 的代理，status 不为 200 时拒绝隧道
+func connectProxy(t *testing.T, status int, gotAuth *string) *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != "CONNECT" {
+			t.Errorf("代理收到 %s, want CONNECT", r.Method)
+			return
+		}
+		*gotAuth = r.Header.Get("Proxy-Authorization")
+		if status != 200 {
+			w.WriteHeader(status)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadGateway)
+			return
+		}
+		c, brw, err := w.(nethttp.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(upstream, brw)
+			upstream.Close()
+		}()
+		io.Copy(c, upstream)
+		c.Close()
+	}))
+}
+
+// 上游 TestTransportProxyHTTPSConnect：https:// 请求通过 CONNECT 隧道，在隧道内进行 TLS 握手
+func testConnectProxy(t *testing.T, tr *http.Transport) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "tunneled")
+	}))
+	defer ts.Close()
+	var auth string
+	proxy := connectProxy(t, 200, &auth)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	pu.User = url.UserPassword("user", "pass")
+	tr.Proxy = http.ProxyURL(pu)
+	insecure(tr)
+	resp, body, err := get(context.Background(), tr, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "tunneled" || resp.TLS == nil || auth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("响应 %q, TLS %v, Proxy-Authorization %q", body, resp.TLS != nil, auth)
+	}
+}
+
+// 上游 TestTransportProxyConnectHeader 等：代理拒绝 CONNECT 时返回带有代理状态的错误
+func testConnectProxyRejected(t *testing.T, tr *http.Transport) {
+	var auth string
+	proxy := connectProxy(t, nethttp.StatusProxyAuthRequired, &auth)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	tr.Proxy = http.ProxyURL(pu)
+	insecure(tr)
+	_, _, err := get(context.Background(), tr, "https://origin.example/")
+	if err == nil || !strings.Contains(err.Error(), "Proxy Authentication Required") {
+		t.Fatalf("err = %v, want Proxy Authentication Required", err)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transporttest_test
+
+import (
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+	http "github.com/vanling1111/tlshttp"
+	"github.com/vanling1111/tlshttp/transporttest"
+)
+
+// TestContract 在不使用指纹和使用几种常见指纹来源时运行契约测试
+func TestContract(t *testing.T) {
+	for name, newTransport := range map[string]func() *http.Transport{
+		"默认": func() *http.Transport { return &http.Transport{} },
+		"JA3": func() *http.Transport {
+			return &http.Transport{
+				JA3:       "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+				UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+			}
+		},
+		"ClientHelloID": func() *http.Transport {
+			return &http.Transport{ClientHelloID: tls.HelloChrome_133}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transporttest.Run(t, newTransport)
+		})
+	}
+}
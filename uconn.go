@@ -0,0 +1,139 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"net"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// NewUConn 在 conn 上创建应用了指纹 fp 的 utls 客户端连接
+//
+// 设置了 DialTLSContext 的 Transport 不会再做 TLS 握手，指纹配置也随之失效。
+// 通过 SSH 隧道、SOCKS 链等自定义方式拨号时，可在 DialTLSContext 中先建立
+// 底层连接，再用 NewUConn 完成带指纹的握手：
+//
+//	DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+//		conn, err := tunnel.DialContext(ctx, network, addr)
+//		if err != nil {
+//			return nil, err
+//		}
+//		host, _, _ := net.SplitHostPort(addr)
+//		uconn, err := http.NewUConn(conn, &tls.Config{ServerName: host}, fp)
+//		if err != nil {
+//			conn.Close()
+//			return nil, err
+//		}
+//		if err := uconn.HandshakeContext(ctx); err != nil {
+//			conn.Close()
+//			return nil, err
+//		}
+//		return uconn, nil
+//	}
+//
+// ClientHello 的构建与 Transport 自身建连时相同。cfg 中只使用 ServerName、
+// InsecureSkipVerify、RootCAs 和 ECH 相关字段，ServerName 必须由调用方设置，
+// ALPN 由指纹决定。指纹包含 encrypted_client_hello 扩展且设置了
+// EncryptedClientHelloConfigList 时发送真正的 ECH。
+// fp 为 nil 时使用默认指纹。返回的连接尚未握手。
+func NewUConn(conn net.Conn, cfg *tls.Config, fp *TLSFingerprintConfig) (*tls.UConn, error) {
+	return (&Transport{TLSFingerprint: fp}).NewUConn(conn, cfg)
+}
+
+// NewUConn 在 conn 上创建应用了 t 的指纹配置的 utls 客户端连接
+//
+// 与包级的 NewUConn 相同，但使用 t 的全部指纹相关字段（JA3、UserAgent、
+// TLSExtensions、ALPNProtocols、StrictTLS、FIPSMode 等），适合在 t 自己的
+// DialTLSContext 中调用。cfg 为 nil 时使用 t.TLSClientConfig。
+func (t *Transport) NewUConn(conn net.Conn, cfg *tls.Config) (*tls.UConn, error) {
+	if cfg == nil {
+		cfg = cloneTLSConfig(t.TLSClientConfig)
+	}
+	pc := &persistConn{t: t}
+	return pc.createCustomTLSConn(conn, cfg)
+}
+
+var errNoFingerprint = errors.New("Transport 未配置 TLS 指纹，将使用 Go 默认的 ClientHello")
+
+// BuildSpec 返回 t 连接 host 时将发送的 ClientHelloSpec，不进行任何网络操作
+//
+// 返回的 spec 与建连时 ApplyPreset 使用的完全相同，经过了 TLS 版本策略、
+// FIPS 降级、StrictTLS 检查和 MutateClientHelloSpec，
+// 可用于检查、计算哈希或做快照测试。
+// host 可以带端口；为域名时会填入 SNI 扩展，为 IP 地址时按 IPSNI 处理，
+// TLSClientConfig.ServerName 非空时以其为准。GREASE 值以占位符表示，
+// 握手时才会替换为随机值。
+// RandomSeed 非 0 时返回下一个连接将发送的 spec，不影响之后的连接。
+// t 没有配置任何指纹时返回错误，因为此时发送的是 Go 默认的 ClientHello。
+func (t *Transport) BuildSpec(host string) (*tls.ClientHelloSpec, error) {
+	if !t.usesCustomTLS() {
+		return nil, errNoFingerprint
+	}
+	pc := &persistConn{t: t}
+	pc.peekSeededRand()
+	spec, err := pc.buildClientHelloSpec()
+	if err != nil {
+		return nil, err
+	}
+	serverName := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		serverName = h
+	}
+	if t.TLSClientConfig != nil && t.TLSClientConfig.ServerName != "" {
+		serverName = t.TLSClientConfig.ServerName
+	}
+	for _, e := range spec.Extensions {
+		if sni, ok := e.(*tls.SNIExtension); ok {
+			sni.ServerName = serverName
+		}
+	}
+	if err := t.finishClientHelloSpec(spec, serverName); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Fingerprint 返回 t 建连时发送的 ClientHello 的 JA3 哈希和 JA4，不进行任何网络操作
+//
+// ClientHello 的构建与建连时完全相同，可以代替访问 tls.peet.ws 等服务检查配置
+// 的实际效果。SNI 使用 TLSClientConfig.ServerName，为空时使用 example.com，
+// 连接 IP 地址时按 IPSNI 处理 SNI，实际的 JA3 和 JA4 可能与此不同。
+// 启用 RandomJA3 等随机化时每次调用的 JA3 可能不同，返回的是其中一次的结果；
+// RandomSeed 非 0 时返回下一个连接的结果。
+// t 没有配置任何指纹时返回错误，因为此时发送的是 Go 默认的 ClientHello。
+func (t *Transport) Fingerprint() (string, string, error) {
+	if !t.usesCustomTLS() {
+		return "", "", errNoFingerprint
+	}
+	cfg := cloneTLSConfig(t.TLSClientConfig)
+	if cfg.ServerName == "" {
+		cfg.ServerName = "example.com"
+	}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	pc := &persistConn{t: t}
+	pc.peekSeededRand()
+	// JA3 和 JA4 取自同一次构建，RandomJA3 或 MutateClientHelloSpec 每次
+	// 结果不同时两者仍然对应同一个 ClientHello
+	spec, err := pc.buildClientHelloSpec()
+	if err != nil {
+		return "", "", err
+	}
+	uc, err := pc.uconnFromSpec(c1, cfg, spec)
+	if err != nil {
+		return "", "", err
+	}
+	if err := uc.BuildHandshakeState(); err != nil {
+		return "", "", err
+	}
+	hello, err := parseClientHello(uc.HandshakeState.Hello.Raw)
+	if err != nil {
+		return "", "", err
+	}
+	return ja3Hash(hello.ja3()), ja4FieldsFromSpec(spec).ja4(), nil
+}
@@ -0,0 +1,417 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	ctls "crypto/tls"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// TestNewUConnCustomDialer 测试自定义 DialTLSContext 通过 NewUConn 保留指纹
+func TestNewUConnCustomDialer(t *testing.T) {
+	var mu sync.Mutex
+	var gotCiphers []uint16
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{
+		GetConfigForClient: func(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+			mu.Lock()
+			gotCiphers = hello.CipherSuites
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	fp := &TLSFingerprintConfig{
+		JA3:       "771,4865-4866-49195-49199,0-10-11-13-43-51-65281,29-23,0",
+		UserAgent: "Mozilla/5.0 Firefox/120.0",
+	}
+	dialed := 0
+	tr := &Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed++
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			uconn, err := NewUConn(conn, &tls.Config{ServerName: "example.com", InsecureSkipVerify: true}, fp)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if err := uconn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return uconn, nil
+		},
+	}
+	defer tr.CloseIdleConnections()
+
+	resp, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() 失败: %v", err)
+	}
+	resp.Body.Close()
+	if dialed != 1 {
+		t.Errorf("DialTLSContext 调用次数 got %d, want 1", dialed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []uint16{4865, 4866, 49195, 49199}
+	if !slices.Equal(gotCiphers, want) {
+		t.Errorf("ClientHello 密码套件 got %v, want %v", gotCiphers, want)
+	}
+}
+
+// TestTransportBuildSpec 测试不联网构建 ClientHelloSpec
+func TestTransportBuildSpec(t *testing.T) {
+	tr := &Transport{
+		JA3:       "771,4865-4866-49195,0-10-11-13-43-51,29-23,0",
+		UserAgent: "Mozilla/5.0 Firefox/120.0",
+	}
+	spec, err := tr.BuildSpec("example.com:443")
+	if err != nil {
+		t.Fatalf("BuildSpec() 失败: %v", err)
+	}
+	if want := []uint16{4865, 4866, 49195}; !slices.Equal(spec.CipherSuites, want) {
+		t.Errorf("CipherSuites got %v, want %v", spec.CipherSuites, want)
+	}
+	if spec.TLSVersMax != tls.VersionTLS13 {
+		t.Errorf("TLSVersMax got %x, want %x", spec.TLSVersMax, tls.VersionTLS13)
+	}
+	sni := ""
+	for _, e := range spec.Extensions {
+		if e, ok := e.(*tls.SNIExtension); ok {
+			sni = e.ServerName
+		}
+	}
+	if sni != "example.com" {
+		t.Errorf("SNI got %q, want %q", sni, "example.com")
+	}
+
+	// 无效的 JA3 报错，而不是回退到默认指纹
+	tr.JA3 = "772,4865,0-10-11-43-51,29,0"
+	if _, err := tr.BuildSpec("example.com"); err == nil {
+		t.Error("冲突的 JA3 版本应返回错误")
+	}
+
+	if _, err := (&Transport{}).BuildSpec("example.com"); err != errNoFingerprint {
+		t.Errorf("未配置指纹 got %v, want %v", err, errNoFingerprint)
+	}
+}
+
+// TestTransportFingerprint 测试不联网计算的 JA3 哈希和 JA4 与实际建连时一致
+func TestTransportFingerprint(t *testing.T) {
+	ts := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer ts.Close()
+	chromeUA := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	tests := []struct {
+		name    string
+		tr      *Transport
+		wantJA4 string
+	}{
+		{
+			name:    "Chrome",
+			tr:      &Transport{JA3: chromeJA4JA3, UserAgent: chromeUA},
+			wantJA4: chromeJA4,
+		},
+		{
+			name: "Firefox",
+			tr:   &Transport{JA3: "771,4865-4866-49195,0-10-11-13-16-43-51,29-23,0", UserAgent: "Mozilla/5.0 Firefox/120.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 连接 IP 地址时不发送 SNI，固定 SNI 使两者可比较
+			tt.tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"}
+			ja3, ja4, err := tt.tr.Fingerprint()
+			if err != nil {
+				t.Fatalf("Fingerprint() 失败: %v", err)
+			}
+			if tt.wantJA4 != "" && ja4 != tt.wantJA4 {
+				t.Errorf("JA4 got %v, want %v", ja4, tt.wantJA4)
+			}
+
+			defer tt.tr.CloseIdleConnections()
+			resp, err := (&Client{Transport: tt.tr}).Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if ja3 != resp.Meta.FingerprintHash {
+				t.Errorf("JA3 哈希 got %v, 实际建连 %v", ja3, resp.Meta.FingerprintHash)
+			}
+		})
+	}
+
+	if _, _, err := (&Transport{}).Fingerprint(); err != errNoFingerprint {
+		t.Errorf("未配置指纹 got %v, want %v", err, errNoFingerprint)
+	}
+
+	// JA3 和 JA4 来自同一次构建：MutateClientHelloSpec 只调用一次
+	calls := 0
+	tr := &Transport{JA3: chromeJA4JA3, UserAgent: chromeUA}
+	tr.MutateClientHelloSpec = func(*tls.ClientHelloSpec, string) error {
+		calls++
+		return nil
+	}
+	if _, _, err := tr.Fingerprint(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("MutateClientHelloSpec 调用 %d 次, want 1", calls)
+	}
+}
+
+// TestTransportClientHelloID 测试内置 ClientHelloID 与 utls 直接使用该 ID 时的 ClientHello 一致
+func TestTransportClientHelloID(t *testing.T) {
+	// utls 直接使用 HelloFirefox_120 时的 JA3
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc := tls.UClient(c1, &tls.Config{ServerName: "example.com"}, tls.HelloFirefox_120)
+	if err := uc.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	hello, err := parseClientHello(uc.HandshakeState.Hello.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &Transport{
+		ClientHelloID:   tls.HelloFirefox_120,
+		JA3:             "771,4865-4866-4867,0-10-11-13-16-43-51,29-23,0", // 被 ClientHelloID 覆盖
+		TLSClientConfig: &tls.Config{ServerName: "example.com", InsecureSkipVerify: true},
+	}
+	ja3, _, err := tr.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ja3Hash(hello.ja3()); ja3 != want {
+		t.Errorf("JA3 got %v, want %v", ja3, want)
+	}
+
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	for _, forceHTTP1 := range []bool{false, true} {
+		tr := &Transport{
+			ClientHelloID:     tls.HelloChrome_Auto,
+			ForceAttemptHTTP2: true,
+			ForceHTTP1:        forceHTTP1,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tr.CloseIdleConnections()
+		want := 2
+		if forceHTTP1 {
+			want = 1
+		}
+		if resp.ProtoMajor != want {
+			t.Errorf("ForceHTTP1 %v: got HTTP/%d, want HTTP/%d", forceHTTP1, resp.ProtoMajor, want)
+		}
+		if got := resp.Meta.Fingerprint; got != tls.HelloChrome_Auto.Str() {
+			t.Errorf("Meta.Fingerprint got %q, want %q", got, tls.HelloChrome_Auto.Str())
+		}
+	}
+}
+
+// TestTransportHelloRandomized 测试随机化的 ClientHelloID 每个连接不同且都能完成握手
+func TestTransportHelloRandomized(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.TLS = &ctls.Config{SessionTicketsDisabled: true}
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tr := &Transport{
+		ClientHelloID:     tls.HelloRandomizedALPN,
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	ja3s := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		resp.Body.Close()
+		ja3s[resp.Meta.FingerprintHash] = true
+	}
+	tr.CloseIdleConnections()
+	if len(ja3s) < 2 {
+		t.Errorf("8 个连接只有 %d 种 JA3", len(ja3s))
+	}
+
+	sequence := func() []string {
+		tr := &Transport{ClientHelloID: tls.HelloRandomized, RandomSeed: 5}
+		var got []string
+		for i := 0; i < 3; i++ {
+			ja3, _, err := tr.Fingerprint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ja3)
+		}
+		return got
+	}
+	if a, b := sequence(), sequence(); !slices.Equal(a, b) {
+		t.Errorf("相同 RandomSeed 的序列不同:\n%v\n%v", a, b)
+	}
+}
+
+// TestTransportClientHelloSpec 测试自定义 spec 被原样使用且每个连接使用独立的副本
+func TestTransportClientHelloSpec(t *testing.T) {
+	spec, err := tls.UTLSIdToSpec(tls.HelloFirefox_120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := func(s *tls.ClientHelloSpec) []string {
+		var got []string
+		for _, e := range s.Extensions {
+			got = append(got, reflect.TypeOf(e).String())
+		}
+		return got
+	}
+	before := order(&spec)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uc := tls.UClient(c1, &tls.Config{ServerName: "example.com"}, tls.HelloFirefox_120)
+	if err := uc.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	hello, err := parseClientHello(uc.HandshakeState.Hello.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &Transport{
+		ClientHelloSpec: &spec,
+		ClientHelloID:   tls.HelloChrome_Auto, // 被 ClientHelloSpec 覆盖
+		TLSClientConfig: &tls.Config{ServerName: "example.com"},
+	}
+	ja3, _, err := tr.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ja3Hash(hello.ja3()); ja3 != want {
+		t.Errorf("JA3 got %v, want %v", ja3, want)
+	}
+	built, err := tr.BuildSpec("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range built.Extensions {
+		// 零大小的扩展的指针可能相同
+		if e == spec.Extensions[i] && reflect.TypeOf(e).Elem().Size() > 0 {
+			t.Errorf("扩展 %d (%T) 与 Transport.ClientHelloSpec 共享", i, e)
+		}
+	}
+
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	tr = &Transport{
+		ClientHelloSpec:   &spec,
+		PermuteExtensions: true,
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+	defer tr.CloseIdleConnections()
+	for i := 0; i < 4; i++ {
+		req, _ := NewRequest("GET", ts.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("#%d: got HTTP/%d, want HTTP/2", i, resp.ProtoMajor)
+		}
+		if got := resp.Meta.Fingerprint; got != "custom" {
+			t.Errorf("Meta.Fingerprint got %q, want custom", got)
+		}
+	}
+	if got := order(&spec); !slices.Equal(got, before) {
+		t.Errorf("Transport.ClientHelloSpec 被修改:\ngot  %v\nwant %v", got, before)
+	}
+}
+
+// TestTransportMutateClientHelloSpec 测试握手前按主机修改 spec 及其错误
+func TestTransportMutateClientHelloSpec(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	var hosts []string
+	errMutate := errors.New("拒绝")
+	tr := &Transport{
+		JA3:               "771,4865-4866-4867-49195-49199,0-10-11-13-16-23-43-45-51-65281,29-23-24,0",
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		MutateClientHelloSpec: func(spec *tls.ClientHelloSpec, host string) error {
+			hosts = append(hosts, host)
+			if host == "example.com" {
+				return errMutate
+			}
+			for _, e := range spec.Extensions {
+				if alpn, ok := e.(*tls.ALPNExtension); ok {
+					alpn.AlpnProtocols = []string{"http/1.1"}
+				}
+			}
+			return nil
+		},
+	}
+	defer tr.CloseIdleConnections()
+	req, _ := NewRequest("GET", ts.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("got HTTP/%d, want HTTP/1.1", resp.ProtoMajor)
+	}
+	if want := []string{"127.0.0.1"}; !slices.Equal(hosts, want) {
+		t.Errorf("host got %v, want %v", hosts, want)
+	}
+
+	spec, err := tr.BuildSpec("127.0.0.1:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range spec.Extensions {
+		if alpn, ok := e.(*tls.ALPNExtension); ok && !slices.Equal(alpn.AlpnProtocols, []string{"http/1.1"}) {
+			t.Errorf("BuildSpec 的 ALPN got %v, want [http/1.1]", alpn.AlpnProtocols)
+		}
+	}
+	if _, err := tr.BuildSpec("example.com"); !errors.Is(err, errMutate) {
+		t.Errorf("err got %v, want %v", err, errMutate)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// unsolicitedBanDuration 是 UnsolicitedDataBan 策略下不复用连接的时间
+const unsolicitedBanDuration = 5 * time.Minute
+
+// UnsolicitedDataPolicy 决定空闲的 HTTP/1 连接收到未请求的数据时的处理方式
+//
+// 这类数据无法与任何请求对应，通常来自向连接注入内容的代理，连接总是被关闭。
+type UnsolicitedDataPolicy int
+
+const (
+	// UnsolicitedDataLog 记录日志并关闭连接，是默认的处理方式
+	UnsolicitedDataLog UnsolicitedDataPolicy = iota
+
+	// UnsolicitedDataClose 只计数并关闭连接，不记录日志
+	UnsolicitedDataClose
+
+	// UnsolicitedDataBan 关闭连接，并在 5 分钟内不再复用到同一目标的连接，
+	// 该目标已有的空闲连接也被关闭
+	UnsolicitedDataBan
+)
+
+// UnsolicitedData 描述空闲连接上收到的未请求数据，传给 Transport.OnUnsolicitedData
+type UnsolicitedData struct {
+	Addr       string   // 目标地址 host:port
+	Proxy      string   // 使用的代理，密码已隐去，没有时为空
+	RemoteAddr net.Addr // 连接的对端地址，经过代理时为代理的地址
+	Data       []byte   // 已读入缓冲区的数据
+	Err        error    // 读取数据时的错误，数据完整读入时为 nil
+	Count      int64    // 包括本次在内，该目标收到未请求数据的次数
+}
+
+var errUnsolicitedBanned = errors.New("http: putIdleConn: connection reuse banned after unsolicited data")
+
+// unsolicitedTracker 按目标地址记录收到未请求数据的次数和禁止复用的期限，
+// 零值可以直接使用
+type unsolicitedTracker struct {
+	mu          sync.Mutex
+	total       int64
+	counts      map[string]int64     // 键为目标地址
+	bannedUntil map[string]time.Time // 键为目标地址
+}
+
+// record 记录 addr 收到一次未请求的数据，返回该目标的累计次数
+func (u *unsolicitedTracker) record(addr string) int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.counts == nil {
+		u.counts = make(map[string]int64)
+	}
+	u.total++
+	u.counts[addr]++
+	return u.counts[addr]
+}
+
+// ban 在 until 之前禁止复用到 addr 的连接
+func (u *unsolicitedTracker) ban(addr string, now, until time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.bannedUntil == nil {
+		u.bannedUntil = make(map[string]time.Time)
+	}
+	for k, t := range u.bannedUntil {
+		if now.After(t) {
+			delete(u.bannedUntil, k)
+		}
+	}
+	u.bannedUntil[addr] = until
+}
+
+// banned 报告 now 时是否禁止复用到 addr 的连接
+func (u *unsolicitedTracker) banned(addr string, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	until, ok := u.bannedUntil[addr]
+	return ok && now.Before(until)
+}
+
+// UnsolicitedDataCount 返回到 addr (host:port) 的空闲连接收到未请求数据的次数，
+// addr 为空时返回所有目标的总数
+func (t *Transport) UnsolicitedDataCount(addr string) int64 {
+	t.unsolicited.mu.Lock()
+	defer t.unsolicited.mu.Unlock()
+	if addr == "" {
+		return t.unsolicited.total
+	}
+	return t.unsolicited.counts[addr]
+}
+
+// handleUnsolicitedData 按 UnsolicitedDataPolicy 处理空闲连接 pc 上收到的数据，
+// 连接已经关闭，调用时不能持有 pc.mu
+func (t *Transport) handleUnsolicitedData(pc *persistConn, data []byte, peekErr error) {
+	addr := pc.cacheKey.addr
+	ev := UnsolicitedData{
+		Addr:       addr,
+		RemoteAddr: pc.conn.RemoteAddr(),
+		Data:       data,
+		Err:        peekErr,
+		Count:      t.unsolicited.record(addr),
+	}
+	if pc.identity != nil {
+		ev.Proxy = pc.identity.proxy
+	}
+	switch t.UnsolicitedDataPolicy {
+	case UnsolicitedDataLog:
+		log.Printf("Unsolicited response received on idle HTTP channel starting with %q; err=%v", data, peekErr)
+	case UnsolicitedDataBan:
+		now := t.now()
+		t.unsolicited.ban(addr, now, now.Add(unsolicitedBanDuration))
+		t.CloseIdleConnectionsFor(addr)
+	}
+	if t.OnUnsolicitedData != nil {
+		t.OnUnsolicitedData(ev)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unsolicitedServer 返回一个 HTTP/1.1 服务端，第一个连接的每个响应之后向连接
+// 写入 inject，以及已接受的连接数
+func unsolicitedServer(t *testing.T, inject string) (net.Listener, *atomic.Int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var conns atomic.Int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			first := conns.Add(1) == 1
+			go func() {
+				defer c.Close()
+				br := bufio.NewReader(c)
+				for {
+					req, err := ReadRequest(br)
+					if err != nil {
+						return
+					}
+					io.Copy(io.Discard, req.Body)
+					io.WriteString(c, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+					if first && inject != "" {
+						time.Sleep(20 * time.Millisecond)
+						io.WriteString(c, inject)
+					}
+				}
+			}()
+		}
+	}()
+	return ln, &conns
+}
+
+// TestUnsolicitedDataCallback 测试空闲连接收到未请求的数据时调用回调并计数
+func TestUnsolicitedDataCallback(t *testing.T) {
+	const inject = "HTTP/1.1 302 Found\r\nLocation: http://ads.example/\r\n\r\n"
+	ln, _ := unsolicitedServer(t, inject)
+	got := make(chan UnsolicitedData, 1)
+	tr := &Transport{
+		UnsolicitedDataPolicy: UnsolicitedDataClose,
+		OnUnsolicitedData:     func(ev UnsolicitedData) { got <- ev },
+	}
+	defer tr.CloseIdleConnections()
+
+	addr := ln.Addr().String()
+	resp, err := (&Client{Transport: tr}).Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	select {
+	case ev := <-got:
+		if string(ev.Data) != inject {
+			t.Errorf("Data got %q, want %q", ev.Data, inject)
+		}
+		if ev.Addr != addr || ev.RemoteAddr.String() != addr {
+			t.Errorf("Addr got %v, RemoteAddr got %v, want %v", ev.Addr, ev.RemoteAddr, addr)
+		}
+		if ev.Count != 1 {
+			t.Errorf("Count got %v, want 1", ev.Count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有调用 OnUnsolicitedData")
+	}
+	if n := tr.UnsolicitedDataCount(addr); n != 1 {
+		t.Errorf("UnsolicitedDataCount(addr) got %v, want 1", n)
+	}
+	if n := tr.UnsolicitedDataCount(""); n != 1 {
+		t.Errorf("UnsolicitedDataCount(\"\") got %v, want 1", n)
+	}
+}
+
+// TestUnsolicitedDataProxyRedacted 测试回调收到的代理地址隐去了密码
+func TestUnsolicitedDataProxyRedacted(t *testing.T) {
+	ln, _ := unsolicitedServer(t, "HTTP/1.1 200 OK\r\n\r\n")
+	got := make(chan UnsolicitedData, 1)
+	proxyURL := &url.URL{Scheme: "http", User: url.UserPassword("user", "secret"), Host: ln.Addr().String()}
+	tr := &Transport{
+		Proxy:                 ProxyURL(proxyURL),
+		UnsolicitedDataPolicy: UnsolicitedDataClose,
+		OnUnsolicitedData:     func(ev UnsolicitedData) { got <- ev },
+	}
+	defer tr.CloseIdleConnections()
+
+	resp, err := (&Client{Transport: tr}).Get("http://example.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	select {
+	case ev := <-got:
+		if want := proxyURL.Redacted(); ev.Proxy != want {
+			t.Errorf("Proxy got %q, want %q", ev.Proxy, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有调用 OnUnsolicitedData")
+	}
+}
+
+// TestUnsolicitedDataBan 测试 UnsolicitedDataBan 策略下不再复用到该目标的连接
+func TestUnsolicitedDataBan(t *testing.T) {
+	ln, conns := unsolicitedServer(t, "garbage")
+	url := "http://" + ln.Addr().String() + "/"
+	done := make(chan struct{}, 1)
+	tr := &Transport{
+		UnsolicitedDataPolicy: UnsolicitedDataBan,
+		OnUnsolicitedData:     func(UnsolicitedData) { done <- struct{}{} },
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	get := func(url string) {
+		t.Helper()
+		resp, err := c.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	get(url)
+	<-done
+	// 之后的连接不再注入数据，但每个请求仍使用新连接
+	for range 3 {
+		get(url)
+	}
+	if n := conns.Load(); n != 4 {
+		t.Errorf("连接数 got %v, want 4", n)
+	}
+}
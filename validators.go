@@ -0,0 +1,227 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"container/list"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Validators 是响应的缓存验证器，即 ETag 和 Last-Modified 头部的值
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// notModifiedBody 是 ValidatorCache 自动发出的条件请求得到的 304 的响应体，
+// 记录本次发送的验证器，见 NotModified
+type notModifiedBody struct {
+	io.ReadCloser
+	sent Validators
+}
+
+// NotModified 报告 resp 是否是 ValidatorCache 自动附加条件头部后得到的 304，
+// 即调用方上次获取的内容仍然有效，并返回本次发送的验证器。
+// 调用方自己的条件请求得到的 304 返回 false
+func NotModified(resp *Response) (Validators, bool) {
+	if resp == nil || resp.StatusCode != StatusNotModified {
+		return Validators{}, false
+	}
+	b, ok := resp.Body.(notModifiedBody)
+	return b.sent, ok
+}
+
+// ValidatorCache 记录各 URL 最近一次 200 响应的 ETag 和 Last-Modified，
+// 再次 GET 或 HEAD 同一 URL 时自动发送 If-None-Match 和 If-Modified-Since
+//
+// 服务端返回 304 时，RoundTrip 照常返回该响应，调用方用 NotModified 判断
+// 内容未变而跳过处理，像浏览器一样重新抓取。
+// 请求已带有 If-None-Match 或 If-Modified-Since 时原样发送，NotModified 对其 304 返回 false。
+// 只保存验证器而不保存响应体，不处理 Vary，也不检查 Cache-Control。
+//
+// ValidatorCache 实现了 RoundTripper，可直接用作 Client.Transport，可以被并发使用。
+// 首次使用后不应再修改其字段。
+type ValidatorCache struct {
+	// Base 用于发送请求，nil 表示 DefaultTransport
+	Base RoundTripper
+
+	// MaxEntries 是最多记录的 URL 数，超出时淘汰最久未使用的，零表示 10000
+	MaxEntries int
+
+	// Storage 非 nil 时验证器同时保存在其中，不在内存中的 URL 从 Storage 加载，
+	// 进程重启后仍然可以发送条件请求。淘汰出内存的记录仍保留在 Storage 中
+	Storage Storage
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // 元素为 *validatorEntry，最近使用的在前
+}
+
+// validatorEntry 是 ValidatorCache 中一个 URL 的记录
+type validatorEntry struct {
+	key string
+	v   Validators
+}
+
+// RoundTrip 发送 req，需要时附加条件头部，并根据响应更新记录
+func (c *ValidatorCache) RoundTrip(req *Request) (*Response, error) {
+	base := c.Base
+	if base == nil {
+		base = DefaultTransport
+	}
+	if req.Method != "" && req.Method != "GET" && req.Method != "HEAD" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return base.RoundTrip(req)
+	}
+
+	key := validatorKey(req)
+	sent, ok := c.Get(key)
+	r2 := req
+	if ok {
+		r2 = new(Request)
+		*r2 = *req
+		r2.Header = req.Header.Clone()
+		if r2.Header == nil {
+			r2.Header = make(Header)
+		}
+		if sent.ETag != "" {
+			r2.Header.Set("If-None-Match", sent.ETag)
+		}
+		if sent.LastModified != "" {
+			r2.Header.Set("If-Modified-Since", sent.LastModified)
+		}
+	}
+
+	resp, err := base.RoundTrip(r2)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	switch resp.StatusCode {
+	case StatusNotModified:
+		if !ok {
+			return resp, nil
+		}
+		// 304 可能带有新的验证器
+		v := sent
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			v.ETag = etag
+		}
+		if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			v.LastModified = lm
+		}
+		c.set(key, v)
+		resp.Body = notModifiedBody{resp.Body, sent}
+	case StatusOK:
+		v := Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if v == (Validators{}) {
+			c.Forget(key)
+		} else {
+			c.set(key, v)
+		}
+	}
+	return resp, nil
+}
+
+// Get 返回为 url 记录的验证器
+func (c *ValidatorCache) Get(url string) (Validators, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		v, ok := c.load(url)
+		if ok {
+			c.setLocked(url, v)
+		}
+		return v, ok
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*validatorEntry).v, true
+}
+
+// load 从 c.Storage 加载 url 的验证器
+func (c *ValidatorCache) load(url string) (Validators, bool) {
+	var v Validators
+	if c.Storage == nil {
+		return v, false
+	}
+	data, ok, err := c.Storage.Get(StorageNamespaceValidators, url)
+	if !ok || err != nil || json.Unmarshal(data, &v) != nil {
+		return Validators{}, false
+	}
+	return v, true
+}
+
+// Forget 删除 url 的记录，下次请求不再是条件请求
+func (c *ValidatorCache) Forget(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[url]; ok {
+		c.lru.Remove(e)
+		delete(c.entries, url)
+	}
+	if c.Storage != nil {
+		c.Storage.Delete(StorageNamespaceValidators, url)
+	}
+}
+
+// Len 返回记录的 URL 数
+func (c *ValidatorCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// CloseIdleConnections 关闭 Base 的空闲连接
+func (c *ValidatorCache) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	base := c.Base
+	if base == nil {
+		base = DefaultTransport
+	}
+	if ci, ok := base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+func (c *ValidatorCache) set(key string, v Validators) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, v)
+	if c.Storage != nil {
+		data, _ := json.Marshal(v)
+		c.Storage.Set(StorageNamespaceValidators, key, data, 0)
+	}
+}
+
+func (c *ValidatorCache) setLocked(key string, v Validators) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*validatorEntry).v = v
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	c.entries[key] = c.lru.PushFront(&validatorEntry{key: key, v: v})
+	limit := c.MaxEntries
+	if limit <= 0 {
+		limit = 10000
+	}
+	for len(c.entries) > limit {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*validatorEntry).key)
+	}
+}
+
+// validatorKey 返回 req 的记录键，即去掉片段的 URL
+func validatorKey(req *Request) string {
+	u := *req.URL
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
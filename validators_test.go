@@ -0,0 +1,134 @@
+// Copyright 2025 The tlshttp Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestValidatorCache 测试记录验证器、自动发送条件请求和用 NotModified 识别 304
+func TestValidatorCache(t *testing.T) {
+	etag := `"v1"`
+	var conditional []string
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		if r.URL.Path == "/plain" {
+			io.WriteString(w, "plain")
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(nethttp.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "body "+etag)
+	}))
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	cache := &ValidatorCache{Base: tr}
+	client := &Client{Transport: cache}
+	errNotModified := errors.New("未修改")
+	get := func(path string) (string, error) {
+		t.Helper()
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if _, ok := NotModified(resp); ok {
+			return "", errNotModified
+		}
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), nil
+	}
+
+	if body, err := get("/doc#frag"); err != nil || body != `body "v1"` {
+		t.Fatalf("第一次请求 got %q, %v", body, err)
+	}
+	resp, err := client.Get(ts.URL + "/doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != StatusNotModified || resp.Request.URL.String() != ts.URL+"/doc" {
+		t.Fatalf("StatusCode got %d, URL got %v", resp.StatusCode, resp.Request.URL)
+	}
+	if sent, ok := NotModified(resp); !ok || sent.ETag != `"v1"` {
+		t.Errorf("NotModified got %+v, %v", sent, ok)
+	}
+
+	// 内容变化后返回新的响应并更新验证器
+	etag = `"v2"`
+	if body, err := get("/doc"); err != nil || body != `body "v2"` {
+		t.Fatalf("内容变化后 got %q, %v", body, err)
+	}
+	if v, _ := cache.Get(ts.URL + "/doc"); v.ETag != `"v2"` {
+		t.Errorf("ETag got %q, want \"v2\"", v.ETag)
+	}
+
+	// 没有验证器的响应不记录
+	get("/plain")
+	get("/plain")
+
+	want := []string{
+		"|",
+		`"v1"|Mon, 02 Jan 2006 15:04:05 GMT`,
+		`"v1"|Mon, 02 Jan 2006 15:04:05 GMT`,
+		"|",
+		"|",
+	}
+	if len(conditional) != len(want) {
+		t.Fatalf("请求 got %q, want %q", conditional, want)
+	}
+	for i := range want {
+		if conditional[i] != want[i] {
+			t.Errorf("请求 %d 的条件头部 got %q, want %q", i, conditional[i], want[i])
+		}
+	}
+
+	// 调用方自己的条件请求原样返回 304
+	req, _ := NewRequest("GET", ts.URL+"/doc", nil)
+	req.Header.Set("If-None-Match", `"v2"`)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != StatusNotModified {
+		t.Errorf("StatusCode got %d, want 304", resp.StatusCode)
+	}
+	if _, ok := NotModified(resp); ok {
+		t.Error("调用方自己的条件请求的 304 不应被 NotModified 识别")
+	}
+}
+
+// TestValidatorCacheEviction 测试超出 MaxEntries 时淘汰最久未使用的记录
+func TestValidatorCacheEviction(t *testing.T) {
+	c := &ValidatorCache{MaxEntries: 2}
+	c.set("a", Validators{ETag: "1"})
+	c.set("b", Validators{ETag: "2"})
+	c.Get("a")
+	c.set("c", Validators{ETag: "3"})
+	if _, ok := c.Get("b"); ok {
+		t.Error("b 应被淘汰")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a 不应被淘汰")
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len got %d, want 2", n)
+	}
+	c.Forget("a")
+	if n := c.Len(); n != 1 {
+		t.Errorf("Forget 后 Len got %d, want 1", n)
+	}
+}